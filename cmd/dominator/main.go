@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"fmt"
+	"io"
 	_ "net/http/pprof"
 	"os"
 	"path"
@@ -10,9 +11,12 @@ import (
 	"time"
 
 	"github.com/Symantec/Dominator/dom/herd"
+	"github.com/Symantec/Dominator/dom/maintenance"
 	"github.com/Symantec/Dominator/dom/rpcd"
+	"github.com/Symantec/Dominator/lib/configwatch"
 	"github.com/Symantec/Dominator/lib/constants"
 	"github.com/Symantec/Dominator/lib/flags/loadflags"
+	"github.com/Symantec/Dominator/lib/image/trust"
 	"github.com/Symantec/Dominator/lib/log"
 	"github.com/Symantec/Dominator/lib/log/serverlogger"
 	"github.com/Symantec/Dominator/lib/mdb"
@@ -34,6 +38,8 @@ var (
 	imageServerPortNum = flag.Uint("imageServerPortNum",
 		constants.ImageServerPortNumber,
 		"Port number of image server")
+	maintenancePolicy = flag.String("maintenancePolicy", "",
+		"URL or filename of JSON maintenance window and blackout policy")
 	mdbFile = flag.String("mdbFile", constants.DefaultMdbFile,
 		"File to read MDB data from")
 	minInterval = flag.Uint("minInterval", 1,
@@ -46,8 +52,41 @@ var (
 		"Port number to allocate and listen on for HTTP/RPC")
 	stateDir = flag.String("stateDir", "/var/lib/Dominator",
 		"Name of dominator state directory.")
+	trustedImageKeysFile = flag.String("trustedImageKeysFile", "",
+		"Filename of PEM public keys. If specified, only push images signed by one of the keys")
 )
 
+// loadMaintenancePolicy waits for the maintenance policy to be loaded and
+// returns the channel of later policies. Disruptive updates are refused while
+// the policy is missing or invalid.
+func loadMaintenancePolicy(herd *herd.Herd,
+	logger log.DebugLogger) <-chan interface{} {
+	herd.SetMaintenancePolicy(maintenance.Deny("policy not loaded"))
+	maintenanceChannel, err := configwatch.Watch(*maintenancePolicy,
+		5*time.Minute,
+		func(reader io.Reader) (interface{}, error) {
+			policy, err := maintenance.Decode(reader)
+			if err != nil {
+				logger.Println(err)
+				return maintenance.Deny(err.Error()), nil
+			}
+			return policy, nil
+		},
+		logger)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Cannot watch maintenance policy: %s\n", err)
+		os.Exit(1)
+	}
+	select {
+	case policy := <-maintenanceChannel:
+		herd.SetMaintenancePolicy(policy.(*maintenance.Policy))
+	case <-time.After(time.Minute):
+		logger.Println(
+			"timed out loading maintenance policy, refusing disruptive updates")
+	}
+	return maintenanceChannel
+}
+
 func showMdb(mdb *mdb.Mdb) {
 	fmt.Println()
 	mdb.DebugWrite(os.Stdout)
@@ -128,9 +167,23 @@ func main() {
 		fmt.Fprintf(os.Stderr, "Cannot create metrics directory: %s\n", err)
 		os.Exit(1)
 	}
+	var imageTrustPolicy *trust.Policy
+	if *trustedImageKeysFile != "" {
+		imageTrustPolicy, err = trust.LoadPolicy(*trustedImageKeysFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Cannot load trusted image keys: %s\n",
+				err)
+			os.Exit(1)
+		}
+	}
 	herd := herd.NewHerd(fmt.Sprintf("%s:%d", *imageServerHostname,
-		*imageServerPortNum), objectServer, metricsDir, logger)
+		*imageServerPortNum), objectServer, *stateDir, imageTrustPolicy,
+		metricsDir, logger)
 	herd.AddHtmlWriter(logger)
+	var maintenanceChannel <-chan interface{}
+	if *maintenancePolicy != "" {
+		maintenanceChannel = loadMaintenancePolicy(herd, logger)
+	}
 	rpcd.Setup(herd, logger)
 	if err = herd.StartServer(*portNum, true); err != nil {
 		fmt.Fprintf(os.Stderr, "Unable to create http server\t%s\n", err)
@@ -146,6 +199,8 @@ func main() {
 			if *debug {
 				showMdb(mdb)
 			}
+		case policy := <-maintenanceChannel:
+			herd.SetMaintenancePolicy(policy.(*maintenance.Policy))
 		case <-scanTokenChannel:
 			// Scan one sub.
 			if herd.PollNextSub() { // We've reached the end of a scan cycle.
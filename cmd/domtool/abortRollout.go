@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/dominator"
+)
+
+func abortRolloutSubcommand(client *srpc.Client, args []string) {
+	if err := abortRollout(client); err != nil {
+		fmt.Fprintf(os.Stderr, "Error aborting rollout: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func abortRollout(client *srpc.Client) error {
+	var request dominator.AbortRolloutRequest
+	var reply dominator.AbortRolloutResponse
+	return client.RequestReply("Dominator.AbortRollout", request, &reply)
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/Symantec/Dominator/lib/json"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/dominator"
+)
+
+func getRolloutStatusSubcommand(client *srpc.Client, args []string) {
+	if err := getRolloutStatus(client); err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting rollout status: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func getRolloutStatus(client *srpc.Client) error {
+	var request dominator.GetRolloutStatusRequest
+	var reply dominator.GetRolloutStatusResponse
+	if err := client.RequestReply("Dominator.GetRolloutStatus", request,
+		&reply); err != nil {
+		return err
+	}
+	if reply.Status == nil {
+		return errors.New("no rollout")
+	}
+	return json.WriteWithIndent(os.Stdout, "    ", reply.Status)
+}
@@ -4,6 +4,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/Symantec/Dominator/lib/constants"
 	"github.com/Symantec/Dominator/lib/flags/loadflags"
@@ -18,10 +19,25 @@ var (
 	networkSpeedPercent = flag.Uint("networkSpeedPercent",
 		constants.DefaultNetworkSpeedPercent,
 		"Network speed as percentage of capacity")
+	planImage = flag.String("planImage", "",
+		"Image to plan updates for (default: image required by each sub)")
+	planSubs               flagutil.StringList
+	planSubsRequiringImage = flag.String("planSubsRequiringImage", "",
+		"If specified, only plan updates for subs requiring this image")
+	rolloutAutoRollback = flag.Bool("rolloutAutoRollback", false,
+		"If true, roll back the rollout if the failure threshold is exceeded")
+	rolloutFailureThreshold = flag.Float64("rolloutFailureThreshold", 0.1,
+		"Maximum ratio of failed subs before the rollout is halted")
+	rolloutWaves        flagutil.StringList = []string{"1", "10", "50", "100"}
+	rolloutWaveSoakTime                     = flag.Duration(
+		"rolloutWaveSoakTime", 5*time.Minute,
+		"Time to wait after a wave is synced before starting the next wave")
 	scanExcludeList  flagutil.StringList = constants.ScanExcludeList
 	scanSpeedPercent                     = flag.Uint("scanSpeedPercent",
 		constants.DefaultScanSpeedPercent,
 		"Scan speed as percentage of capacity")
+	showSubPlans = flag.Bool("showSubPlans", false,
+		"If true, show the update plan for each sub")
 	domHostname = flag.String("domHostname", "localhost",
 		"Hostname of dominator")
 	domPortNum = flag.Uint("domPortNum", constants.DominatorPortNumber,
@@ -29,6 +45,10 @@ var (
 )
 
 func init() {
+	flag.Var(&planSubs, "planSubs",
+		"Comma separated list of subs to plan updates for (default: all)")
+	flag.Var(&rolloutWaves, "rolloutWaves",
+		"Comma separated list of waves: [key=value[;key=value...]:]percent")
 	flag.Var(&scanExcludeList, "scanExcludeList",
 		"Comma separated list of patterns to exclude from scanning")
 }
@@ -39,13 +59,19 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "Common flags:")
 	flag.PrintDefaults()
 	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintln(os.Stderr, "  abort-rollout")
 	fmt.Fprintln(os.Stderr, "  clear-safety-shutoff sub")
 	fmt.Fprintln(os.Stderr, "  configure-subs")
 	fmt.Fprintln(os.Stderr, "  disable-updates reason")
 	fmt.Fprintln(os.Stderr, "  enable-updates reason")
 	fmt.Fprintln(os.Stderr, "  get-default-image")
+	fmt.Fprintln(os.Stderr, "  get-rollout-status")
 	fmt.Fprintln(os.Stderr, "  get-subs-configuration")
+	fmt.Fprintln(os.Stderr, "  pause-rollout")
+	fmt.Fprintln(os.Stderr, "  plan-update")
+	fmt.Fprintln(os.Stderr, "  resume-rollout")
 	fmt.Fprintln(os.Stderr, "  set-default-image image")
+	fmt.Fprintln(os.Stderr, "  start-rollout image previous-image")
 }
 
 type commandFunc func(*srpc.Client, []string)
@@ -57,13 +83,19 @@ type subcommand struct {
 }
 
 var subcommands = []subcommand{
+	{"abort-rollout", 0, abortRolloutSubcommand},
 	{"clear-safety-shutoff", 1, clearSafetyShutoffSubcommand},
 	{"configure-subs", 0, configureSubsSubcommand},
 	{"disable-updates", 1, disableUpdatesSubcommand},
 	{"enable-updates", 1, enableUpdatesSubcommand},
 	{"get-default-image", 0, getDefaultImageSubcommand},
+	{"get-rollout-status", 0, getRolloutStatusSubcommand},
 	{"get-subs-configuration", 0, getSubsConfigurationSubcommand},
+	{"pause-rollout", 0, pauseRolloutSubcommand},
+	{"plan-update", 0, planUpdateSubcommand},
+	{"resume-rollout", 0, resumeRolloutSubcommand},
 	{"set-default-image", 1, setDefaultImageSubcommand},
+	{"start-rollout", 2, startRolloutSubcommand},
 }
 
 func main() {
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/dominator"
+)
+
+func pauseRolloutSubcommand(client *srpc.Client, args []string) {
+	if err := pauseRollout(client, false); err != nil {
+		fmt.Fprintf(os.Stderr, "Error pausing rollout: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func resumeRolloutSubcommand(client *srpc.Client, args []string) {
+	if err := pauseRollout(client, true); err != nil {
+		fmt.Fprintf(os.Stderr, "Error resuming rollout: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func pauseRollout(client *srpc.Client, resume bool) error {
+	request := dominator.PauseRolloutRequest{Resume: resume}
+	var reply dominator.PauseRolloutResponse
+	return client.RequestReply("Dominator.PauseRollout", request, &reply)
+}
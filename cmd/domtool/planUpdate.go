@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/Symantec/Dominator/lib/json"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/dominator"
+)
+
+func planUpdateSubcommand(client *srpc.Client, args []string) {
+	if err := planUpdate(client); err != nil {
+		fmt.Fprintf(os.Stderr, "Error planning update: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func planUpdate(client *srpc.Client) error {
+	request := dominator.PlanUpdateRequest{
+		Hostnames:          planSubs,
+		ImageName:          *planImage,
+		IncludeSubPlans:    *showSubPlans,
+		SubsRequiringImage: *planSubsRequiringImage,
+	}
+	var reply dominator.PlanUpdateResponse
+	if err := client.RequestReply("Dominator.PlanUpdate", request,
+		&reply); err != nil {
+		return err
+	}
+	return json.WriteWithIndent(os.Stdout, "    ", reply)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/lib/tags"
+	"github.com/Symantec/Dominator/proto/dominator"
+)
+
+func startRolloutSubcommand(client *srpc.Client, args []string) {
+	if err := startRollout(client, args[0], args[1]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error starting rollout: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+// parseRolloutWave parses a wave specification of the form:
+// [key=value[;key=value...]:]percent or key=value[;key=value...].
+func parseRolloutWave(value string) (dominator.RolloutWave, error) {
+	var wave dominator.RolloutWave
+	var tagsString, percentString string
+	if index := strings.LastIndex(value, ":"); index >= 0 {
+		tagsString = value[:index]
+		percentString = value[index+1:]
+	} else if strings.Contains(value, "=") {
+		tagsString = value
+	} else {
+		percentString = value
+	}
+	if percentString != "" {
+		percent, err := strconv.ParseUint(strings.TrimSuffix(percentString,
+			"%"), 10, 32)
+		if err != nil {
+			return wave, errors.New("bad wave percentage: " + value)
+		}
+		wave.Percent = uint(percent)
+	}
+	if tagsString != "" {
+		wave.RequiredTags = make(tags.Tags)
+		for _, tagString := range strings.Split(tagsString, ";") {
+			var tag tags.Tag
+			if err := tag.Set(tagString); err != nil {
+				return wave, err
+			}
+			wave.RequiredTags[tag.Key] = tag.Value
+		}
+	}
+	return wave, nil
+}
+
+func startRollout(client *srpc.Client, imageName, previousImageName string) error {
+	request := dominator.StartRolloutRequest{
+		ImageName:         imageName,
+		PreviousImageName: previousImageName,
+		FailureThreshold:  *rolloutFailureThreshold,
+		AutoRollback:      *rolloutAutoRollback,
+		WaveSoakTime:      *rolloutWaveSoakTime,
+	}
+	for _, waveString := range rolloutWaves {
+		wave, err := parseRolloutWave(waveString)
+		if err != nil {
+			return err
+		}
+		request.Waves = append(request.Waves, wave)
+	}
+	var reply dominator.StartRolloutResponse
+	return client.RequestReply("Dominator.StartRollout", request, &reply)
+}
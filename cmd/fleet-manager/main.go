@@ -8,6 +8,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/Symantec/Dominator/fleetmanager/dnsd"
 	"github.com/Symantec/Dominator/fleetmanager/httpd"
 	"github.com/Symantec/Dominator/fleetmanager/hypervisors"
 	"github.com/Symantec/Dominator/fleetmanager/hypervisors/fsstorer"
@@ -30,6 +31,13 @@ const (
 var (
 	checkTopology = flag.Bool("checkTopology", false,
 		"If true, perform a one-time check, write to stdout and exit")
+	dnsPortNum = flag.Uint("dnsPortNum", 0,
+		"Port number to serve DNS records for VMs and Hypervisors on (0=none)")
+	imageServerHostname = flag.String("imageServerHostname", "",
+		"Hostname of image server used to find the latest images for VM groups")
+	imageServerPortNum = flag.Uint("imageServerPortNum",
+		constants.ImageServerPortNumber,
+		"Port number of image server")
 	ipmiPasswordFile = flag.String("ipmiPasswordFile", "",
 		"Name of password file used to authenticate for IPMI requests")
 	ipmiUsername = flag.String("ipmiUsername", "",
@@ -90,15 +98,28 @@ func main() {
 	if err != nil {
 		logger.Fatalf("Cannot create DB: %s\n", err)
 	}
+	var imageServerAddress string
+	if *imageServerHostname != "" {
+		imageServerAddress = fmt.Sprintf("%s:%d",
+			*imageServerHostname, *imageServerPortNum)
+	}
 	hyperManager, err := hypervisors.New(hypervisors.StartOptions{
-		IpmiPasswordFile: *ipmiPasswordFile,
-		IpmiUsername:     *ipmiUsername,
-		Logger:           logger,
-		Storer:           storer,
+		ImageServerAddress: imageServerAddress,
+		IpmiPasswordFile:   *ipmiPasswordFile,
+		IpmiUsername:       *ipmiUsername,
+		Logger:             logger,
+		Storer:             storer,
 	})
 	if err != nil {
 		logger.Fatalf("Cannot create hypervisors manager: %s\n", err)
 	}
+	var dnsServer *dnsd.Server
+	if *dnsPortNum > 0 {
+		dnsServer, err = dnsd.StartServer(*dnsPortNum, hyperManager, logger)
+		if err != nil {
+			logger.Fatalf("Unable to create DNS server: %s\n", err)
+		}
+	}
 	rpcHtmlWriter, err := rpcd.Setup(hyperManager, logger)
 	if err != nil {
 		logger.Fatalf("Cannot start rpcd: %s\n", err)
@@ -114,5 +135,8 @@ func main() {
 		logger.Println("Received new topology")
 		webServer.UpdateTopology(topology)
 		hyperManager.UpdateTopology(topology)
+		if dnsServer != nil {
+			dnsServer.UpdateTopology(topology)
+		}
 	}
 }
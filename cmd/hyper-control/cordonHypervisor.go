@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/fleetmanager"
+)
+
+func cordonHypervisorSubcommand(args []string, logger log.DebugLogger) error {
+	if err := setCordon(true, logger); err != nil {
+		return fmt.Errorf("Error cordoning Hypervisor: %s", err)
+	}
+	return nil
+}
+
+func uncordonHypervisorSubcommand(args []string, logger log.DebugLogger) error {
+	if err := setCordon(false, logger); err != nil {
+		return fmt.Errorf("Error uncordoning Hypervisor: %s", err)
+	}
+	return nil
+}
+
+func setCordon(cordoned bool, logger log.DebugLogger) error {
+	if *hypervisorHostname == "" {
+		return errors.New("no hypervisorHostname specified")
+	}
+	request := proto.SetMachineCordonRequest{
+		Cordoned: cordoned,
+		Hostname: *hypervisorHostname,
+	}
+	var reply proto.SetMachineCordonResponse
+	clientName := fmt.Sprintf("%s:%d", *fleetManagerHostname,
+		*fleetManagerPortNum)
+	client, err := srpc.DialHTTPWithDialer("tcp", clientName, rrDialer)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	err = client.RequestReply("FleetManager.SetMachineCordon", request, &reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
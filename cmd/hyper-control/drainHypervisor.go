@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/fleetmanager"
+)
+
+func drainHypervisorSubcommand(args []string, logger log.DebugLogger) error {
+	if err := drainHypervisor(logger); err != nil {
+		return fmt.Errorf("Error draining Hypervisor: %s", err)
+	}
+	return nil
+}
+
+func drainHypervisor(logger log.DebugLogger) error {
+	if *hypervisorHostname == "" {
+		return errors.New("no hypervisorHostname specified")
+	}
+	clientName := fmt.Sprintf("%s:%d", *fleetManagerHostname,
+		*fleetManagerPortNum)
+	client, err := srpc.DialHTTPWithDialer("tcp", clientName, rrDialer)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	conn, err := client.Call("FleetManager.DrainHypervisor")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	request := proto.DrainHypervisorRequest{
+		Hostname:    *hypervisorHostname,
+		MaxParallel: *maxParallelMigrations,
+	}
+	if err := conn.Encode(request); err != nil {
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	for {
+		var reply proto.DrainHypervisorResponse
+		if err := conn.Decode(&reply); err != nil {
+			return err
+		}
+		if reply.VmError != "" {
+			logger.Printf("%s: %s\n", reply.VmIpAddress, reply.VmError)
+		}
+		if reply.ProgressMessage != "" {
+			if len(reply.VmIpAddress) > 0 {
+				logger.Debugf(0, "%s: %s\n",
+					reply.VmIpAddress, reply.ProgressMessage)
+			} else {
+				logger.Debugln(0, reply.ProgressMessage)
+			}
+		}
+		if reply.Error != "" {
+			return errors.New(reply.Error)
+		}
+		if reply.Final {
+			return nil
+		}
+	}
+}
@@ -40,6 +40,8 @@ var (
 		constants.InstallerPortNumber, "Port number of installer")
 	location = flag.String("location", "",
 		"Location to search for hypervisors")
+	maxParallelMigrations = flag.Uint("maxParallelMigrations", 0,
+		"Maximum number of concurrent migrations when draining")
 	offerTimeout = flag.Duration("offerTimeout", time.Minute+time.Second,
 		"How long to offer DHCP OFFERs and ACKs")
 	netbootFiles        tags.Tags
@@ -72,6 +74,8 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "  add-address MACaddr [IPaddr]")
 	fmt.Fprintln(os.Stderr, "  add-subnet ID IPgateway IPmask DNSserver...")
 	fmt.Fprintln(os.Stderr, "  change-tags")
+	fmt.Fprintln(os.Stderr, "  cordon-hypervisor")
+	fmt.Fprintln(os.Stderr, "  drain-hypervisor")
 	fmt.Fprintln(os.Stderr, "  get-machine-info hostname")
 	fmt.Fprintln(os.Stderr, "  get-updates")
 	fmt.Fprintln(os.Stderr, "  installer-shell hostname")
@@ -85,6 +89,7 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "  remove-mac-address MACaddr")
 	fmt.Fprintln(os.Stderr, "  rollout-image name")
 	fmt.Fprintln(os.Stderr, "  show-network-configuration")
+	fmt.Fprintln(os.Stderr, "  uncordon-hypervisor")
 	fmt.Fprintln(os.Stderr, "  update-network-configuration")
 	fmt.Fprintln(os.Stderr, "  write-netboot-files hostname dirname")
 }
@@ -102,6 +107,8 @@ var subcommands = []subcommand{
 	{"add-address", 1, 2, addAddressSubcommand},
 	{"add-subnet", 4, -1, addSubnetSubcommand},
 	{"change-tags", 0, 0, changeTagsSubcommand},
+	{"cordon-hypervisor", 0, 0, cordonHypervisorSubcommand},
+	{"drain-hypervisor", 0, 0, drainHypervisorSubcommand},
 	{"get-machine-info", 1, 1, getMachineInfoSubcommand},
 	{"get-updates", 0, 0, getUpdatesSubcommand},
 	{"installer-shell", 1, 1, installerShellSubcommand},
@@ -115,6 +122,7 @@ var subcommands = []subcommand{
 	{"remove-mac-address", 1, 1, removeMacAddressSubcommand},
 	{"rollout-image", 1, 1, rolloutImageSubcommand},
 	{"show-network-configuration", 0, 0, showNetworkConfigurationSubcommand},
+	{"uncordon-hypervisor", 0, 0, uncordonHypervisorSubcommand},
 	{"update-network-configuration", 0, 0,
 		updateNetworkConfigurationSubcommand},
 	{"write-netboot-files", 2, 2, writeNetbootFilesSubcommand},
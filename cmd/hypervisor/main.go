@@ -15,9 +15,12 @@ import (
 	"github.com/Symantec/Dominator/lib/constants"
 	"github.com/Symantec/Dominator/lib/flags/loadflags"
 	"github.com/Symantec/Dominator/lib/flagutil"
+	"github.com/Symantec/Dominator/lib/image/trust"
+	"github.com/Symantec/Dominator/lib/json"
 	"github.com/Symantec/Dominator/lib/log/serverlogger"
 	"github.com/Symantec/Dominator/lib/net"
 	"github.com/Symantec/Dominator/lib/srpc/setupserver"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
 	"github.com/Symantec/tricorder/go/tricorder"
 )
 
@@ -27,8 +30,12 @@ const (
 )
 
 var (
+	backupServerAddress = flag.String("backupServerAddress", "",
+		"Address (host:port) of object server for VM backups")
 	dhcpServerOnBridgesOnly = flag.Bool("dhcpServerOnBridgesOnly", false,
 		"If true, run the DHCP server on bridge interfaces only")
+	enableFirewall = flag.Bool("enableFirewall", false,
+		"If true, enforce VM firewall rules with nftables")
 	imageServerHostname = flag.String("imageServerHostname", "localhost",
 		"Hostname of image server")
 	imageServerPortNum = flag.Uint("imageServerPortNum",
@@ -39,13 +46,17 @@ var (
 	objectCacheSize = flagutil.Size(10 << 30)
 	portNum         = flag.Uint("portNum", constants.HypervisorPortNumber,
 		"Port number to allocate and listen on for HTTP/RPC")
-	showVGA  = flag.Bool("showVGA", false, "If true, show VGA console")
+	showVGA              = flag.Bool("showVGA", false, "If true, show VGA console")
+	snapshotPoliciesFile = flag.String("snapshotPoliciesFile", "",
+		"Filename of JSON snapshot policy rules to apply to VMs by tag")
 	stateDir = flag.String("stateDir", "/var/lib/hypervisor",
 		"Name of state directory")
 	testMemoryAvailable = flag.Uint64("testMemoryAvailable", 0,
 		"test if memory is allocatable and exit (units of MiB)")
 	tftpbootImageStream = flag.String("tftpbootImageStream", "",
 		"Name of default image stream for network booting")
+	trustedImageKeysFile = flag.String("trustedImageKeysFile", "",
+		"Filename of PEM public keys. If specified, only boot images signed by one of the keys")
 	username = flag.String("username", "nobody",
 		"Name of user to run VMs")
 	volumeDirectories flagutil.StringList
@@ -148,16 +159,34 @@ func main() {
 	if err != nil {
 		logger.Fatalf("Cannot start tftpboot server: %s\n", err)
 	}
+	var snapshotPolicyRules []proto.SnapshotPolicyRule
+	if *snapshotPoliciesFile != "" {
+		err := json.ReadFromFile(*snapshotPoliciesFile, &snapshotPolicyRules)
+		if err != nil {
+			logger.Fatalf("Cannot load snapshot policies: %s\n", err)
+		}
+	}
+	var imageTrustPolicy *trust.Policy
+	if *trustedImageKeysFile != "" {
+		imageTrustPolicy, err = trust.LoadPolicy(*trustedImageKeysFile)
+		if err != nil {
+			logger.Fatalf("Cannot load trusted image keys: %s\n", err)
+		}
+	}
 	managerObj, err := manager.New(manager.StartOptions{
-		ImageServerAddress: imageServerAddress,
-		DhcpServer:         dhcpServer,
-		Logger:             logger,
-		ObjectCacheBytes:   uint64(objectCacheSize),
-		ShowVgaConsole:     *showVGA,
-		StateDir:           *stateDir,
-		Username:           *username,
-		VlanIdToBridge:     vlanIdToBridge,
-		VolumeDirectories:  volumeDirectories,
+		BackupServerAddress: *backupServerAddress,
+		ImageServerAddress:  imageServerAddress,
+		ImageTrustPolicy:    imageTrustPolicy,
+		DhcpServer:          dhcpServer,
+		EnableFirewall:      *enableFirewall,
+		Logger:              logger,
+		ObjectCacheBytes:    uint64(objectCacheSize),
+		ShowVgaConsole:      *showVGA,
+		SnapshotPolicyRules: snapshotPolicyRules,
+		StateDir:            *stateDir,
+		Username:            *username,
+		VlanIdToBridge:      vlanIdToBridge,
+		VolumeDirectories:   volumeDirectories,
 	})
 	if err != nil {
 		logger.Fatalf("Cannot start hypervisor: %s\n", err)
@@ -10,10 +10,11 @@ import (
 	"github.com/Symantec/Dominator/imageserver/scanner"
 	"github.com/Symantec/Dominator/lib/constants"
 	"github.com/Symantec/Dominator/lib/flags/loadflags"
+	"github.com/Symantec/Dominator/lib/flagutil"
 	"github.com/Symantec/Dominator/lib/log/serverlogger"
-	"github.com/Symantec/Dominator/lib/objectserver/filesystem"
 	"github.com/Symantec/Dominator/lib/srpc/setupserver"
 	objectserverRpcd "github.com/Symantec/Dominator/objectserver/rpcd"
+	"github.com/Symantec/Dominator/proto/objectserver"
 	"github.com/Symantec/tricorder/go/tricorder"
 	"github.com/Symantec/tricorder/go/tricorder/units"
 )
@@ -27,17 +28,45 @@ var (
 	imageServerPortNum = flag.Uint("imageServerPortNum",
 		constants.ImageServerPortNumber,
 		"Port number of image server")
+	objectCacheDir = flag.String("objectCacheDir", "",
+		"Name of directory to cache objects read from the bucket (optional)")
 	objectDir = flag.String("objectDir", "/var/lib/objectserver",
 		"Name of image server data directory.")
+	objectServerBackend = flag.String("objectServerBackend", "filesystem",
+		"Object storage backend: filesystem or s3")
 	permitInsecureMode = flag.Bool("permitInsecureMode", false,
 		"If true, run in insecure mode. This gives remote access to all")
 	portNum = flag.Uint("portNum", constants.ImageServerPortNumber,
 		"Port number to allocate and listen on for HTTP/RPC")
+	s3AccountProfile = flag.String("s3AccountProfile", "",
+		"Name of AWS profile to use for the s3 backend (default: environment)")
+	s3Bucket = flag.String("s3Bucket", "",
+		"Name of bucket for the s3 backend")
+	s3Endpoint = flag.String("s3Endpoint", "",
+		"Endpoint URL of an S3-compatible service (default: AWS)")
+	s3KeyPrefix = flag.String("s3KeyPrefix", "objects/",
+		"Prefix for object keys in the bucket")
+	s3Region          = flag.String("s3Region", "", "Region of bucket")
+	vulnerabilityFeed = flag.String("vulnerabilityFeed", "",
+		"Filename or URL of OSV vulnerability feed (JSON) for package reports")
+
+	objectCacheSize   flagutil.Size = 10 << 30
+	objectCompression objectserver.Compression
+	s3MaxBytes        flagutil.Size
 )
 
+func init() {
+	flag.Var(&objectCacheSize, "objectCacheSize",
+		"Maximum size of the object cache")
+	flag.Var(&objectCompression, "objectCompression",
+		"Compression for new objects stored by the filesystem backend: none or gzip")
+	flag.Var(&s3MaxBytes, "s3MaxBytes",
+		"Bucket capacity before garbage collection (default: unlimited)")
+}
+
 type imageObjectServersType struct {
 	imdb   *scanner.ImageDataBase
-	objSrv *filesystem.ObjectServer
+	objSrv objectServer
 }
 
 func main() {
@@ -59,7 +88,7 @@ func main() {
 			logger.Fatalln(err)
 		}
 	}
-	objSrv, err := filesystem.NewObjectServer(*objectDir, logger)
+	objSrv, err := newObjectServer(logger)
 	if err != nil {
 		logger.Fatalf("Cannot create ObjectServer: %s\n", err)
 	}
@@ -88,6 +117,12 @@ func main() {
 	httpd.AddHtmlWriter(imgSrvRpcHtmlWriter)
 	httpd.AddHtmlWriter(objSrvRpcHtmlWriter)
 	httpd.AddHtmlWriter(logger)
+	if *vulnerabilityFeed != "" {
+		err := httpd.WatchVulnerabilityFeed(*vulnerabilityFeed, logger)
+		if err != nil {
+			logger.Fatalf("Cannot watch vulnerability feed: %s\n", err)
+		}
+	}
 	if err = httpd.StartServer(*portNum, imdb, objSrv, false); err != nil {
 		logger.Fatalf("Unable to create http server: %s\n", err)
 	}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"io"
+
+	"github.com/Symantec/Dominator/lib/awsutil"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/objectserver"
+	"github.com/Symantec/Dominator/lib/objectserver/bucket"
+	"github.com/Symantec/Dominator/lib/objectserver/bucket/s3"
+	"github.com/Symantec/Dominator/lib/objectserver/filesystem"
+)
+
+type objectServer interface {
+	objectserver.FullObjectServer
+	objectserver.StashingObjectServer
+	WriteHtml(writer io.Writer)
+}
+
+func newObjectServer(logger log.DebugLogger) (objectServer, error) {
+	switch *objectServerBackend {
+	case "filesystem":
+		objSrv, err := filesystem.NewObjectServer(*objectDir, logger)
+		if err != nil {
+			return nil, err
+		}
+		objSrv.SetCompression(objectCompression)
+		return objSrv, nil
+	case "s3":
+		return newS3ObjectServer(logger)
+	}
+	return nil, errors.New("unknown object server backend: " +
+		*objectServerBackend)
+}
+
+func newS3ObjectServer(logger log.DebugLogger) (objectServer, error) {
+	if *s3Bucket == "" {
+		return nil, errors.New("no bucket specified")
+	}
+	awsSession, err := awsutil.CreateSession(*s3AccountProfile)
+	if err != nil {
+		return nil, err
+	}
+	return bucket.NewObjectServer(bucket.Params{
+		Bucket: s3.NewBucket(awsSession, s3.Params{
+			BucketName: *s3Bucket,
+			Endpoint:   *s3Endpoint,
+			KeyPrefix:  *s3KeyPrefix,
+			Region:     *s3Region,
+		}),
+		CacheDirectory: *objectCacheDir,
+		MaxBytes:       uint64(s3MaxBytes),
+		MaxCachedBytes: uint64(objectCacheSize),
+	}, logger)
+}
@@ -20,6 +20,7 @@ import (
 	"github.com/Symantec/Dominator/lib/filter"
 	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/image/trust"
 	"github.com/Symantec/Dominator/lib/mbr"
 	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
 	"github.com/Symantec/Dominator/lib/srpc"
@@ -44,6 +45,15 @@ func addImage(imageSClient *srpc.Client, name string, img *image.Image) error {
 	if err := img.VerifyRequiredPaths(requiredPaths); err != nil {
 		return err
 	}
+	if *signingKeyFile != "" {
+		signer, err := trust.LoadSigner(*signingKeyFile)
+		if err != nil {
+			return err
+		}
+		if err := trust.Sign(img, signer); err != nil {
+			return errors.New("error signing image: " + err.Error())
+		}
+	}
 	if err := client.AddImage(imageSClient, name, img); err != nil {
 		return errors.New("remote error: " + err.Error())
 	}
@@ -63,7 +73,8 @@ func buildImage(imageSClient *srpc.Client, filter *filter.Filter,
 	imageFilename string) (*filesystem.FileSystem, error) {
 	var h hasher
 	var err error
-	h.objQ, err = objectclient.NewObjectAdderQueue(imageSClient)
+	h.objQ, err = objectclient.NewObjectAdderQueueWithCompression(
+		imageSClient, objectCompression)
 	if err != nil {
 		return nil, err
 	}
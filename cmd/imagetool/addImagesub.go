@@ -107,7 +107,8 @@ func copyMissingObjects(fs *filesystem.FileSystem, imageSClient *srpc.Client,
 			hashToFilename[hashVal] = files[0]
 		}
 	}
-	objAdderQueue, err := objectclient.NewObjectAdderQueue(imageSClient)
+	objAdderQueue, err := objectclient.NewObjectAdderQueueWithCompression(
+		imageSClient, objectCompression)
 	if err != nil {
 		return err
 	}
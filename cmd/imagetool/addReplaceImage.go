@@ -64,6 +64,7 @@ func addReplaceImage(imageSClient *srpc.Client,
 	if err := spliceComputedFiles(newImage.FileSystem); err != nil {
 		return err
 	}
+	newImage.Signatures = nil // Signatures of the base image are now invalid.
 	return addImage(imageSClient, name, newImage)
 }
 
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/image/packagediff"
+	"github.com/Symantec/Dominator/lib/osv"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/imageserver"
+)
+
+func diffPackagesSubcommand(args []string) {
+	imageClient, _ := getClients()
+	if err := diffPackages(imageClient, args[0], args[1]); err != nil {
+		fmt.Fprintf(os.Stderr, "Error diffing packages: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func diffPackages(imageClient *srpc.Client, left, right string) error {
+	var db *osv.Database
+	if *vulnerabilityFeed != "" {
+		var err error
+		if db, err = osv.Load(*vulnerabilityFeed); err != nil {
+			return err
+		}
+	}
+	leftImage, err := getImageMetadata(imageClient, left)
+	if err != nil {
+		return err
+	}
+	rightImage, err := getImageMetadata(imageClient, right)
+	if err != nil {
+		return err
+	}
+	changes := packagediff.Diff(leftImage.Packages, rightImage.Packages)
+	showPackageChanges(os.Stdout, changes)
+	fmt.Printf("Total size change: %s\n",
+		formatSizeDelta(packagediff.TotalSizeDelta(changes)))
+	if db == nil {
+		return nil
+	}
+	report := packagediff.CheckVulnerabilities(leftImage.Packages,
+		rightImage.Packages, db)
+	showFindings(os.Stdout, "Introduced vulnerabilities", report.Introduced)
+	showFindings(os.Stdout, "Remaining vulnerabilities", report.Remaining)
+	showFindings(os.Stdout, "Fixed vulnerabilities", report.Fixed)
+	return nil
+}
+
+func formatSizeDelta(delta int64) string {
+	if delta < 0 {
+		return "-" + format.FormatBytes(uint64(-delta))
+	}
+	return "+" + format.FormatBytes(uint64(delta))
+}
+
+// getImageMetadata will get an image without the file-system.
+func getImageMetadata(imageClient *srpc.Client, name string) (
+	*image.Image, error) {
+	request := imageserver.GetImageRequest{
+		ImageName:        name,
+		IgnoreFilesystem: true,
+		Timeout:          *timeout,
+	}
+	var reply imageserver.GetImageResponse
+	err := imageClient.RequestReply("ImageServer.GetImage", request, &reply)
+	if err != nil {
+		return nil, err
+	}
+	if reply.Image == nil {
+		return nil, errors.New(name + ": not found")
+	}
+	return reply.Image, nil
+}
+
+func showFindings(writer io.Writer, title string,
+	findings []packagediff.Finding) {
+	if len(findings) < 1 {
+		return
+	}
+	fmt.Fprintf(writer, "%s:\n", title)
+	for _, finding := range findings {
+		fmt.Fprintf(writer, "  %s %s %s: %s\n",
+			finding.Vulnerability.Id, finding.Package.Name,
+			finding.Package.Version, finding.Vulnerability.Summary)
+	}
+}
+
+func showPackageChanges(writer io.Writer, changes []packagediff.Change) {
+	for _, change := range changes {
+		if change.New == nil {
+			fmt.Fprintf(writer, "- %s %s (%s)\n", change.Name,
+				change.Old.Version, formatSizeDelta(change.SizeDelta()))
+		} else if change.Old == nil {
+			fmt.Fprintf(writer, "+ %s %s (%s)\n", change.Name,
+				change.New.Version, formatSizeDelta(change.SizeDelta()))
+		} else if change.Old.Version != change.New.Version {
+			fmt.Fprintf(writer, "~ %s %s -> %s (%s)\n", change.Name,
+				change.Old.Version, change.New.Version,
+				formatSizeDelta(change.SizeDelta()))
+		} else {
+			fmt.Fprintf(writer, "~ %s %s (digest %s -> %s)\n", change.Name,
+				change.Old.Version, change.Old.Digest, change.New.Digest)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Symantec/Dominator/lib/image/packagediff"
+	"github.com/Symantec/Dominator/lib/image/sbom"
+	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
+	"github.com/Symantec/Dominator/lib/srpc"
+)
+
+func diffSbomSubcommand(args []string) {
+	imageClient, objectClient := getClients()
+	err := diffSboms(imageClient, objectClient, args[0], args[1])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error diffing SBOMs: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func diffSboms(imageClient *srpc.Client,
+	objectClient *objectclient.ObjectClient, left, right string) error {
+	leftDoc, err := readSbom(imageClient, objectClient, left)
+	if err != nil {
+		return err
+	}
+	rightDoc, err := readSbom(imageClient, objectClient, right)
+	if err != nil {
+		return err
+	}
+	diffProvenance(os.Stdout, leftDoc.Provenance, rightDoc.Provenance)
+	showPackageChanges(os.Stdout,
+		packagediff.Diff(leftDoc.Packages, rightDoc.Packages))
+	return nil
+}
+
+func diffProvenance(writer io.Writer, left, right sbom.Provenance) {
+	diffString(writer, "StreamName", left.StreamName, right.StreamName)
+	diffString(writer, "SourceImage", left.SourceImage, right.SourceImage)
+	diffString(writer, "ManifestUrl", left.ManifestUrl, right.ManifestUrl)
+	diffString(writer, "ManifestDirectory", left.ManifestDirectory,
+		right.ManifestDirectory)
+	diffString(writer, "GitBranch", left.GitBranch, right.GitBranch)
+	diffString(writer, "GitCommit", left.GitCommit, right.GitCommit)
+	diffString(writer, "PackagerType", left.PackagerType, right.PackagerType)
+	diffString(writer, "BuildVariables",
+		strings.Join(left.BuildVariables, ","),
+		strings.Join(right.BuildVariables, ","))
+}
+
+func diffString(writer io.Writer, name, left, right string) {
+	if left != right {
+		fmt.Fprintf(writer, "%s: \"%s\" -> \"%s\"\n", name, left, right)
+	}
+}
+
+func readSbom(imageClient *srpc.Client, objectClient *objectclient.ObjectClient,
+	name string) (*sbom.Document, error) {
+	reader, _, err := getSbomReader(imageClient, objectClient, name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	doc, err := sbom.Read(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error reading SBOM for: %s: %s", name, err)
+	}
+	return doc, nil
+}
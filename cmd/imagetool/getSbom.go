@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/Symantec/Dominator/lib/fsutil"
+	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
+	"github.com/Symantec/Dominator/lib/srpc"
+)
+
+func getSbomSubcommand(args []string) {
+	imageClient, objectClient := getClients()
+	var outFileName string
+	if len(args) > 1 {
+		outFileName = args[1]
+	}
+	err := getSbom(imageClient, objectClient, args[0], outFileName)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error getting SBOM: %s\n", err)
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
+func getSbom(imageClient *srpc.Client, objectClient *objectclient.ObjectClient,
+	name, outFileName string) error {
+	reader, size, err := getSbomReader(imageClient, objectClient, name)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	if outFileName == "" {
+		_, err := io.Copy(os.Stdout, reader)
+		return err
+	}
+	return fsutil.CopyToFile(outFileName, filePerms, reader, size)
+}
+
+func getSbomReader(imageClient *srpc.Client,
+	objectClient *objectclient.ObjectClient, name string) (
+	io.ReadCloser, uint64, error) {
+	img, err := getImageMetadata(imageClient, name)
+	if err != nil {
+		return nil, 0, err
+	}
+	if img.Provenance == nil || img.Provenance.Object == nil {
+		return nil, 0, errors.New(name + ": no SBOM")
+	}
+	size, reader, err := objectClient.GetObject(*img.Provenance.Object)
+	if err != nil {
+		return nil, 0, err
+	}
+	return reader, size, nil
+}
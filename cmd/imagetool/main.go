@@ -17,6 +17,7 @@ import (
 	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
 	"github.com/Symantec/Dominator/lib/srpc"
 	"github.com/Symantec/Dominator/lib/srpc/setupclient"
+	"github.com/Symantec/Dominator/proto/objectserver"
 )
 
 var (
@@ -50,22 +51,31 @@ var (
 		"If true, make raw image bootable by installing GRUB")
 	minFreeBytes = flag.Uint64("minFreeBytes", 4<<20,
 		"minimum number of free bytes in raw image")
-	releaseNotes = flag.String("releaseNotes", "",
+	objectCompression objectserver.Compression
+	releaseNotes      = flag.String("releaseNotes", "",
 		"Filename or URL containing release notes")
 	requiredPaths = flagutil.StringToRuneMap(constants.RequiredPaths)
 	roundupPower  = flag.Uint64("roundupPower", 24,
 		"power of 2 to round up raw image size")
+	signingKeyFile = flag.String("signingKeyFile", "",
+		"Filename of PEM private key to sign added images with")
 	skipFields = flag.String("skipFields", "",
 		"Fields to skip when showing or diffing images")
 	tableType mbr.TableType = mbr.TABLE_TYPE_MSDOS
 	timeout                 = flag.Duration("timeout", 0,
 		"Timeout for get subcommand")
+	trustedImageKeysFile = flag.String("trustedImageKeysFile", "",
+		"Filename of PEM public keys to verify signatures with for show")
+	vulnerabilityFeed = flag.String("vulnerabilityFeed", "",
+		"Filename of OSV vulnerability feed (JSON) for diff-packages subcommand")
 
 	logger            log.DebugLogger
 	minimumExpiration = 15 * time.Minute
 )
 
 func init() {
+	flag.Var(&objectCompression, "objectCompression",
+		"Compression for objects sent to or fetched from the imageserver")
 	flag.Var(&requiredPaths, "requiredPaths",
 		"Comma separated list of required path:type entries")
 	flag.Var(&tableType, "tableType", "partition table type for make-raw-image")
@@ -97,12 +107,15 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "           i: name of an image on the imageserver")
 	fmt.Fprintln(os.Stderr, "           l: name of file containing an Image")
 	fmt.Fprintln(os.Stderr, "           s: name of sub to poll")
+	fmt.Fprintln(os.Stderr, "  diff-packages       left right")
+	fmt.Fprintln(os.Stderr, "  diff-sbom           left right")
 	fmt.Fprintln(os.Stderr, "  estimate-usage      name")
 	fmt.Fprintln(os.Stderr, "  find-latest-image   directory")
 	fmt.Fprintln(os.Stderr, "  get                 name directory")
 	fmt.Fprintln(os.Stderr, "  get-archive-data    name outfile")
 	fmt.Fprintln(os.Stderr, "  get-file-in-image   name imageFile [outfile]")
 	fmt.Fprintln(os.Stderr, "  get-image-expiration name")
+	fmt.Fprintln(os.Stderr, "  get-sbom            name [outfile]")
 	fmt.Fprintln(os.Stderr, "  list")
 	fmt.Fprintln(os.Stderr, "  listdirs")
 	fmt.Fprintln(os.Stderr, "  listunrefobj")
@@ -149,12 +162,15 @@ var subcommands = []subcommand{
 	{"delete", 1, 1, deleteImageSubcommand},
 	{"delunrefobj", 2, 2, deleteUnreferencedObjectsSubcommand},
 	{"diff", 3, 3, diffSubcommand},
+	{"diff-packages", 2, 2, diffPackagesSubcommand},
+	{"diff-sbom", 2, 2, diffSbomSubcommand},
 	{"estimate-usage", 1, 1, estimateImageUsageSubcommand},
 	{"find-latest-image", 1, 1, findLatestImageSubcommand},
 	{"get", 2, 2, getImageSubcommand},
 	{"get-archive-data", 2, 2, getImageArchiveDataSubcommand},
 	{"get-file-in-image", 2, 3, getFileInImageSubcommand},
 	{"get-image-expiration", 1, 1, getImageExpirationSubcommand},
+	{"get-sbom", 1, 2, getSbomSubcommand},
 	{"list", 0, 0, listImagesSubcommand},
 	{"listdirs", 0, 0, listDirectoriesSubcommand},
 	{"listunrefobj", 0, 0, listUnreferencedObjectsSubcommand},
@@ -185,6 +201,7 @@ func getClients() (*srpc.Client, *objectclient.ObjectClient) {
 			os.Exit(1)
 		}
 		theObjectClient = objectclient.NewObjectClient(clientName)
+		theObjectClient.SetCompression(objectCompression)
 	}
 	return imageSrpcClient, theObjectClient
 }
@@ -2,7 +2,11 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
+
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/image/trust"
 )
 
 func showImageSubcommand(args []string) {
@@ -13,10 +17,59 @@ func showImageSubcommand(args []string) {
 	os.Exit(0)
 }
 
-func showImage(image string) error {
-	fs, err := getTypedImage(image)
+func showImage(typedName string) error {
+	name := typedName
+	if len(typedName) >= 3 && typedName[1] == ':' {
+		if typedName[0] != 'i' {
+			fs, err := getTypedImage(typedName)
+			if err != nil {
+				return err
+			}
+			return fs.Listf(os.Stdout, listSelector, listFilter)
+		}
+		name = typedName[2:]
+	}
+	imageSClient, _ := getClients()
+	img, err := getImage(imageSClient, name)
 	if err != nil {
 		return err
 	}
-	return fs.Listf(os.Stdout, listSelector, listFilter)
+	if err := showSignatures(os.Stdout, img); err != nil {
+		return err
+	}
+	return img.FileSystem.Listf(os.Stdout, listSelector, listFilter)
+}
+
+func showSignatures(writer io.Writer, img *image.Image) error {
+	if len(img.Signatures) < 1 {
+		return nil
+	}
+	var policy *trust.Policy
+	var digest []byte
+	if *trustedImageKeysFile != "" {
+		var err error
+		if policy, err = trust.LoadPolicy(*trustedImageKeysFile); err != nil {
+			return err
+		}
+		if digest, err = img.ComputeSignatureDigest(); err != nil {
+			return err
+		}
+	}
+	for _, signature := range img.Signatures {
+		if policy == nil {
+			fmt.Fprintf(writer, "Signed by key: %s\n", signature.KeyId)
+			continue
+		}
+		status := "untrusted"
+		if publicKey, ok := policy.PublicKey(signature.KeyId); ok {
+			err := trust.VerifySignature(publicKey, digest, signature.Signature)
+			if err == nil {
+				status = "trusted, valid"
+			} else {
+				status = "trusted, INVALID"
+			}
+		}
+		fmt.Fprintf(writer, "Signed by key: %s (%s)\n", signature.KeyId, status)
+	}
+	return nil
 }
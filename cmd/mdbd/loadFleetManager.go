@@ -116,9 +116,14 @@ func (g *fleetManagerGeneratorType) Generate(unused_datacentre string,
 			if len(vm.OwnerGroups) > 0 {
 				ownerGroup = vm.OwnerGroups[0]
 			}
+			var ipv6Addr string
+			if len(vm.Address.Ipv6Address) > 0 {
+				ipv6Addr = vm.Address.Ipv6Address.String()
+			}
 			newMdb.Machines = append(newMdb.Machines, mdb.Machine{
 				Hostname:       ipAddr,
 				IpAddress:      ipAddr,
+				Ipv6Address:    ipv6Addr,
 				RequiredImage:  tags["RequiredImage"],
 				PlannedImage:   tags["PlannedImage"],
 				DisableUpdates: disableUpdates,
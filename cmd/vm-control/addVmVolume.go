@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	hyperclient "github.com/Symantec/Dominator/hypervisor/client"
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/log"
+)
+
+func addVmVolumeSubcommand(args []string, logger log.DebugLogger) error {
+	if err := addVmVolume(args[0], logger); err != nil {
+		return fmt.Errorf("Error adding VM volume: %s", err)
+	}
+	return nil
+}
+
+func addVmVolume(vmHostname string, logger log.DebugLogger) error {
+	if vmIP, hypervisor, err := lookupVmAndHypervisor(vmHostname); err != nil {
+		return err
+	} else {
+		return addVmVolumeOnHypervisor(hypervisor, vmIP, logger)
+	}
+}
+
+func addVmVolumeOnHypervisor(hypervisor string, ipAddr net.IP,
+	logger log.DebugLogger) error {
+	if volumeSize < 1 {
+		return errors.New("no volume size specified")
+	}
+	client, err := dialHypervisor(hypervisor)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return hyperclient.AddVmVolume(client, ipAddr, nil, uint64(volumeSize))
+}
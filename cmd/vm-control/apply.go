@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	hyperclient "github.com/Symantec/Dominator/hypervisor/client"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/srpc"
+	fm_proto "github.com/Symantec/Dominator/proto/fleetmanager"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func applySubcommand(args []string, logger log.DebugLogger) error {
+	for _, filename := range args {
+		if err := applyVmSpecFile(filename, false, logger); err != nil {
+			return fmt.Errorf("Error applying VM specification: %s: %s",
+				filename, err)
+		}
+	}
+	return nil
+}
+
+// applyVmSpecFile makes the VM match the specification in the file. If
+// planOnly is true, the changes are printed but not made.
+func applyVmSpecFile(filename string, planOnly bool,
+	logger log.DebugLogger) error {
+	rawSpec, err := loadVmSpec(filename)
+	if err != nil {
+		return err
+	}
+	spec := rawSpec.applyDefaults(filename)
+	if spec.IpAddress == nil {
+		if spec.IpAddress, err = readVmSpecState(filename); err != nil {
+			return err
+		}
+	}
+	if spec.IpAddress == nil {
+		if planOnly {
+			fmt.Printf("%s: create VM\n", filename)
+			return nil
+		}
+		return createVmFromSpec(filename, spec, logger)
+	}
+	hypervisor, err := findHypervisor(spec.IpAddress)
+	if err != nil {
+		return err
+	}
+	client, err := dialHypervisor(hypervisor)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	vmInfo, err := getVmInfoClient(client, spec.IpAddress)
+	if err != nil {
+		return err
+	}
+	changes, err := planVmChanges(spec, vmInfo, logger)
+	if err != nil {
+		return err
+	}
+	if planOnly {
+		if len(changes) < 1 {
+			fmt.Printf("%s: %s: no changes\n", filename, spec.IpAddress)
+		}
+		for _, change := range changes {
+			fmt.Printf("%s: %s: %s\n", filename, spec.IpAddress,
+				change.description)
+		}
+		return nil
+	}
+	return applyVmChanges(client, spec.IpAddress, vmInfo.State, changes,
+		logger)
+}
+
+// applyVmChanges makes the changes. If any change requires the VM to be
+// stopped and it is running, it is stopped first and started afterwards, even
+// if a change fails.
+func applyVmChanges(client *srpc.Client, ipAddr net.IP, state proto.State,
+	changes []vmChange, logger log.DebugLogger) error {
+	var needsStop bool
+	for _, change := range changes {
+		if change.needsStop {
+			needsStop = true
+		}
+	}
+	restart := false
+	if needsStop {
+		switch state {
+		case proto.StateStopped:
+		case proto.StateRunning:
+			logger.Printf("%s: stopping VM\n", ipAddr)
+			if err := hyperclient.StopVm(client, ipAddr, nil); err != nil {
+				return err
+			}
+			restart = true
+		default:
+			return fmt.Errorf("VM state: %s is not stopped/running", state)
+		}
+	}
+	var err error
+	for _, change := range changes {
+		logger.Printf("%s: %s\n", ipAddr, change.description)
+		if err = change.apply(client, ipAddr); err != nil {
+			break
+		}
+	}
+	if restart {
+		logger.Printf("%s: starting VM\n", ipAddr)
+		if startErr := hyperclient.StartVm(client, ipAddr, nil); err == nil {
+			err = startErr
+		} else if startErr != nil {
+			logger.Printf("%s: error starting VM: %s\n", ipAddr, startErr)
+		}
+	}
+	return err
+}
+
+// createVmFromSpec creates a VM placed by the Fleet Manager, prints the IP
+// address of the new VM and records it in the state file for the
+// specification. The VM is not created if the state file cannot be written.
+func createVmFromSpec(filename string, spec vmSpec,
+	logger log.DebugLogger) error {
+	if *fleetManagerHostname == "" {
+		return errors.New("no Fleet Manager specified to create VM")
+	}
+	if spec.ImageName == "" && spec.ImageURL == "" {
+		return errors.New("no image specified")
+	}
+	if err := checkVmSpecStateWritable(filename); err != nil {
+		return err
+	}
+	volumes, err := parseSizes(spec.SecondaryVolumeSizes)
+	if err != nil {
+		return err
+	}
+	request := proto.CreateVmRequest{
+		DhcpTimeout:      *dhcpTimeout,
+		MinimumFreeBytes: uint64(minFreeBytes),
+		RoundupPower:     *roundupPower,
+		SecondaryVolumes: volumes,
+		VmInfo:           spec.makeVmInfo(),
+	}
+	if spec.ImageName != "" {
+		request.ImageTimeout = *imageTimeout
+		request.SkipBootloader = *skipBootloader
+	}
+	var userDataReader io.Reader
+	if spec.UserDataFile != "" {
+		file, size, err := getReader(spec.UserDataFile)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		request.UserDataSize = uint64(size)
+		userDataReader = bufio.NewReader(io.LimitReader(file, size))
+	}
+	reply, err := callFleetManagerCreateVm(fm_proto.CreateVmRequest{
+		CreateVmRequest: request,
+		Location:        spec.Location,
+	}, nil, userDataReader, logger)
+	if err != nil {
+		return err
+	}
+	client, err := dialHypervisor(reply.HypervisorAddress)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := hyperclient.AcknowledgeVm(client, reply.IpAddress); err != nil {
+		return fmt.Errorf("error acknowledging VM: %s", err)
+	}
+	logger.Printf("%s: created VM: %s\n", filename, reply.IpAddress)
+	fmt.Println(reply.IpAddress)
+	if reply.DhcpTimedOut {
+		logger.Printf("%s: DHCP ACK timed out\n", reply.IpAddress)
+	}
+	if err := writeVmSpecState(filename, reply.IpAddress); err != nil {
+		return fmt.Errorf("error recording VM: %s: %s", reply.IpAddress, err)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/log"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func backupVmSubcommand(args []string, logger log.DebugLogger) error {
+	if err := backupVm(args[0], logger); err != nil {
+		return fmt.Errorf("Error backing up VM: %s", err)
+	}
+	return nil
+}
+
+func backupVm(vmHostname string, logger log.DebugLogger) error {
+	if vmIP, hypervisor, err := lookupVmAndHypervisor(vmHostname); err != nil {
+		return err
+	} else {
+		return backupVmOnHypervisor(hypervisor, vmIP, logger)
+	}
+}
+
+func backupVmOnHypervisor(hypervisor string, ipAddr net.IP,
+	logger log.DebugLogger) error {
+	request := proto.BackupVmRequest{
+		IpAddress:         ipAddr,
+		ForceIfNotStopped: *forceIfNotStopped,
+		ObjectServer:      *objectServer,
+		RootOnly:          *snapshotRootOnly,
+	}
+	client, err := dialHypervisor(hypervisor)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	var reply proto.BackupVmResponse
+	err = client.RequestReply("Hypervisor.BackupVm", request, &reply)
+	if err != nil {
+		return err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return err
+	}
+	logger.Debugf(0, "uploaded: %s of %s\n", format.FormatBytes(reply.NewBytes),
+		format.FormatBytes(reply.TotalBytes))
+	fmt.Printf("%x\n", reply.ManifestHash)
+	return nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/json"
+	"github.com/Symantec/Dominator/lib/log"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func changeVmFirewallSubcommand(args []string, logger log.DebugLogger) error {
+	if err := changeVmFirewall(args[0], logger); err != nil {
+		return fmt.Errorf("Error changing VM firewall: %s", err)
+	}
+	return nil
+}
+
+func changeVmFirewall(vmHostname string, logger log.DebugLogger) error {
+	if vmIP, hypervisor, err := lookupVmAndHypervisor(vmHostname); err != nil {
+		return err
+	} else {
+		return changeVmFirewallOnHypervisor(hypervisor, vmIP, logger)
+	}
+}
+
+func changeVmFirewallOnHypervisor(hypervisor string, ipAddr net.IP,
+	logger log.DebugLogger) error {
+	policy, err := loadFirewallPolicy()
+	if err != nil {
+		return err
+	}
+	request := proto.ChangeVmFirewallRequest{
+		IpAddress: ipAddr,
+		Firewall:  policy,
+	}
+	client, err := dialHypervisor(hypervisor)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	var reply proto.ChangeVmFirewallResponse
+	err = client.RequestReply("Hypervisor.ChangeVmFirewall", request, &reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
+
+func loadFirewallPolicy() (*proto.FirewallPolicy, error) {
+	if *firewallFile == "" {
+		return nil, nil
+	}
+	var policy proto.FirewallPolicy
+	if err := json.ReadFromFile(*firewallFile, &policy); err != nil {
+		return nil, err
+	}
+	if err := policy.CheckValid(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/log"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func changeVmLimitsSubcommand(args []string, logger log.DebugLogger) error {
+	if err := changeVmLimits(args[0], logger); err != nil {
+		return fmt.Errorf("Error changing VM limits: %s", err)
+	}
+	return nil
+}
+
+func changeVmLimits(vmHostname string, logger log.DebugLogger) error {
+	if vmIP, hypervisor, err := lookupVmAndHypervisor(vmHostname); err != nil {
+		return err
+	} else {
+		return changeVmLimitsOnHypervisor(hypervisor, vmIP, logger)
+	}
+}
+
+func changeVmLimitsOnHypervisor(hypervisor string, ipAddr net.IP,
+	logger log.DebugLogger) error {
+	request := proto.ChangeVmLimitsRequest{
+		IpAddress: ipAddr,
+		Limits:    makeVmLimitsFromFlags(),
+	}
+	client, err := dialHypervisor(hypervisor)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	var reply proto.ChangeVmLimitsResponse
+	err = client.RequestReply("Hypervisor.ChangeVmLimits", request, &reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
+
+// makeVmLimitsFromFlags returns nil if no limits were specified.
+func makeVmLimitsFromFlags() *proto.VmLimits {
+	limits := proto.VmLimits{
+		DiskBytesPerSecond:    uint64(diskBytesPerSecond),
+		DiskOpsPerSecond:      *diskOpsPerSecond,
+		EgressBytesPerSecond:  uint64(egressBytesPerSecond),
+		IngressBytesPerSecond: uint64(ingressBytesPerSecond),
+	}
+	if limits == (proto.VmLimits{}) {
+		return nil
+	}
+	return &limits
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	hyperclient "github.com/Symantec/Dominator/hypervisor/client"
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/log"
+)
+
+func changeVmSizeSubcommand(args []string, logger log.DebugLogger) error {
+	if err := changeVmSize(args[0], logger); err != nil {
+		return fmt.Errorf("Error changing VM size: %s", err)
+	}
+	return nil
+}
+
+func changeVmSize(vmHostname string, logger log.DebugLogger) error {
+	if vmIP, hypervisor, err := lookupVmAndHypervisor(vmHostname); err != nil {
+		return err
+	} else {
+		return changeVmSizeOnHypervisor(hypervisor, vmIP, logger)
+	}
+}
+
+func changeVmSizeOnHypervisor(hypervisor string, ipAddr net.IP,
+	logger log.DebugLogger) error {
+	if memory < 1 && *milliCPUs < 1 {
+		return errors.New("no memory or milliCPUs specified")
+	}
+	client, err := dialHypervisor(hypervisor)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return hyperclient.ChangeVmSize(client, ipAddr, nil, uint64(memory>>20),
+		*milliCPUs)
+}
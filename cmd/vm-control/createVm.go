@@ -8,10 +8,12 @@ import (
 	"math/rand"
 	"net"
 	"os"
+	"strings"
 	"time"
 
 	hyperclient "github.com/Symantec/Dominator/hypervisor/client"
 	"github.com/Symantec/Dominator/lib/flagutil"
+	"github.com/Symantec/Dominator/lib/fsutil"
 	"github.com/Symantec/Dominator/lib/log"
 	"github.com/Symantec/Dominator/lib/srpc"
 	"github.com/Symantec/Dominator/lib/tags"
@@ -19,6 +21,9 @@ import (
 	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
 )
 
+var errFleetManagerCannotCreateVm = errors.New(
+	"Fleet Manager does not support CreateVm")
+
 func init() {
 	rand.Seed(time.Now().Unix() + time.Now().UnixNano())
 }
@@ -41,21 +46,9 @@ func callCreateVm(client *srpc.Client, request hyper_proto.CreateVmRequest,
 	if err := conn.Encode(request); err != nil {
 		return fmt.Errorf("error encoding request: %s", err)
 	}
-	// Stream any required data.
-	if imageReader != nil {
-		logger.Debugln(0, "uploading image")
-		if _, err := io.Copy(conn, imageReader); err != nil {
-			return fmt.Errorf("error uploading image: %s", err)
-		}
-	}
-	if userDataReader != nil {
-		logger.Debugln(0, "uploading user data")
-		if _, err := io.Copy(conn, userDataReader); err != nil {
-			return fmt.Errorf("error uploading user data: %s", err)
-		}
-	}
-	if err := conn.Flush(); err != nil {
-		return fmt.Errorf("error flushing: %s", err)
+	if err := sendCreateVmData(conn, imageReader, userDataReader,
+		logger); err != nil {
+		return err
 	}
 	for {
 		var response hyper_proto.CreateVmResponse
@@ -75,6 +68,42 @@ func callCreateVm(client *srpc.Client, request hyper_proto.CreateVmRequest,
 	}
 }
 
+// sendCreateVmData will stream any required data and flush.
+func sendCreateVmData(conn *srpc.Conn, imageReader, userDataReader io.Reader,
+	logger log.DebugLogger) error {
+	if imageReader != nil {
+		logger.Debugln(0, "uploading image")
+		if _, err := io.Copy(conn, imageReader); err != nil {
+			return fmt.Errorf("error uploading image: %s", err)
+		}
+	}
+	if userDataReader != nil {
+		logger.Debugln(0, "uploading user data")
+		if _, err := io.Copy(conn, userDataReader); err != nil {
+			return fmt.Errorf("error uploading user data: %s", err)
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("error flushing: %s", err)
+	}
+	return nil
+}
+
+func acknowledgeAndWatchVm(client *srpc.Client, hypervisor string,
+	reply hyper_proto.CreateVmResponse, logger log.DebugLogger) error {
+	if err := hyperclient.AcknowledgeVm(client, reply.IpAddress); err != nil {
+		return fmt.Errorf("error acknowledging VM: %s", err)
+	}
+	fmt.Println(reply.IpAddress)
+	if reply.DhcpTimedOut {
+		return errors.New("DHCP ACK timed out")
+	}
+	if *dhcpTimeout > 0 {
+		logger.Debugln(0, "Received DHCP ACK")
+	}
+	return maybeWatchVm(client, hypervisor, reply.IpAddress, logger)
+}
+
 func createVm(logger log.DebugLogger) error {
 	if *vmHostname == "" {
 		if name := vmTags["Name"]; name == "" {
@@ -90,6 +119,14 @@ func createVm(logger log.DebugLogger) error {
 			vmTags["Name"] = *vmHostname
 		}
 	}
+	if *hypervisorHostname == "" && *adjacentVM == "" {
+		err := createVmWithFleetManager(logger)
+		if err != errFleetManagerCannotCreateVm {
+			return err
+		}
+		logger.Debugln(0,
+			"Fleet Manager does not support CreateVm, placing VM locally")
+	}
 	if hypervisor, err := getHypervisorAddress(); err != nil {
 		return err
 	} else {
@@ -100,10 +137,12 @@ func createVm(logger log.DebugLogger) error {
 
 func createVmInfoFromFlags() hyper_proto.VmInfo {
 	return hyper_proto.VmInfo{
+		ConfigDrive:        *configDrive,
 		ConsoleType:        consoleType,
 		DestroyProtection:  *destroyProtection,
 		DisableVirtIO:      *disableVirtIO,
 		Hostname:           *vmHostname,
+		Limits:             makeVmLimitsFromFlags(),
 		MemoryInMiB:        uint64(memory >> 20),
 		MilliCPUs:          *milliCPUs,
 		OwnerGroups:        ownerGroups,
@@ -115,7 +154,135 @@ func createVmInfoFromFlags() hyper_proto.VmInfo {
 }
 
 func createVmOnHypervisor(hypervisor string, logger log.DebugLogger) error {
-	request := hyper_proto.CreateVmRequest{
+	request, imageReader, userDataReader, cleanup, err := makeCreateVmRequest()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	client, err := dialHypervisor(hypervisor)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	var reply hyper_proto.CreateVmResponse
+	err = callCreateVm(client, request, &reply, imageReader, userDataReader,
+		logger)
+	if err != nil {
+		return err
+	}
+	return acknowledgeAndWatchVm(client, hypervisor, reply, logger)
+}
+
+func createVmWithFleetManager(logger log.DebugLogger) error {
+	request, imageReader, userDataReader, cleanup, err := makeCreateVmRequest()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	fmRequest := fm_proto.CreateVmRequest{
+		DryRun:   *dryRun,
+		Location: *location,
+		Placement: fm_proto.PlacementPolicy{
+			AffinityTagKeys: affinityTagKeys,
+			SpreadByOwner:   *spreadByOwner,
+			SpreadTagKeys:   spreadTagKeys,
+			StrictSpread:    *strictSpread,
+		},
+		CreateVmRequest: request,
+	}
+	reply, err := callFleetManagerCreateVm(fmRequest, imageReader,
+		userDataReader, logger)
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		fmt.Println(reply.HypervisorAddress)
+		fmt.Println(reply.PlacementReason)
+		return nil
+	}
+	client, err := dialHypervisor(reply.HypervisorAddress)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return acknowledgeAndWatchVm(client, reply.HypervisorAddress,
+		reply.CreateVmResponse, logger)
+}
+
+// callFleetManagerCreateVm asks the Fleet Manager to place and create a VM,
+// streaming any image and user data. The VM must then be acknowledged on the
+// Hypervisor given in the reply, unless this is a dry run.
+func callFleetManagerCreateVm(request fm_proto.CreateVmRequest,
+	imageReader, userDataReader io.Reader,
+	logger log.DebugLogger) (fm_proto.CreateVmResponse, error) {
+	fleetManager, err := dialFleetManager(fmt.Sprintf("%s:%d",
+		*fleetManagerHostname, *fleetManagerPortNum))
+	if err != nil {
+		return fm_proto.CreateVmResponse{}, err
+	}
+	defer fleetManager.Close()
+	conn, err := fleetManager.Call("FleetManager.CreateVm")
+	if err != nil {
+		// Older Fleet Managers do not have the method. Nothing has been sent
+		// yet, so the caller may fall back to placing the VM itself.
+		if strings.Contains(err.Error(), "unknown method") {
+			return fm_proto.CreateVmResponse{}, errFleetManagerCannotCreateVm
+		}
+		return fm_proto.CreateVmResponse{},
+			fmt.Errorf("error calling FleetManager.CreateVm: %s", err)
+	}
+	defer conn.Close()
+	if err := conn.Encode(request); err != nil {
+		return fm_proto.CreateVmResponse{},
+			fmt.Errorf("error encoding request: %s", err)
+	}
+	if request.DryRun {
+		imageReader = nil
+		userDataReader = nil
+	}
+	if err := sendCreateVmData(conn, imageReader, userDataReader,
+		logger); err != nil {
+		return fm_proto.CreateVmResponse{}, err
+	}
+	for {
+		var response fm_proto.CreateVmResponse
+		if err := conn.Decode(&response); err != nil {
+			return fm_proto.CreateVmResponse{},
+				fmt.Errorf("error decoding: %s", err)
+		}
+		if response.Error != "" {
+			return fm_proto.CreateVmResponse{}, errors.New(response.Error)
+		}
+		if response.PlacementReason != "" {
+			logger.Debugf(0, "placing VM on: %s: %s\n",
+				response.HypervisorAddress, response.PlacementReason)
+		}
+		if response.ProgressMessage != "" {
+			logger.Debugln(0, response.ProgressMessage)
+		}
+		if response.Final {
+			return response, nil
+		}
+	}
+}
+
+// makeCreateVmRequest will make a request from the command-line flags. If
+// there is no error, the returned cleanup function must be called once the
+// readers are no longer needed.
+func makeCreateVmRequest() (request hyper_proto.CreateVmRequest,
+	imageReader, userDataReader io.Reader, cleanup func(), err error) {
+	var closers []io.Closer
+	cleanup = func() {
+		for _, closer := range closers {
+			closer.Close()
+		}
+	}
+	defer func() {
+		if err != nil {
+			cleanup()
+		}
+	}()
+	request = hyper_proto.CreateVmRequest{
 		DhcpTimeout:      *dhcpTimeout,
 		MinimumFreeBytes: uint64(minFreeBytes),
 		RoundupPower:     *roundupPower,
@@ -127,10 +294,20 @@ func createVmOnHypervisor(hypervisor string, logger log.DebugLogger) error {
 	if request.VmInfo.MilliCPUs < 1 {
 		request.VmInfo.MilliCPUs = 250
 	}
+	if request.VmInfo.Firewall, err = loadFirewallPolicy(); err != nil {
+		return request, nil, nil, nil, err
+	}
+	if *sshPublicKeyFile != "" {
+		request.SshPublicKeys, err = fsutil.LoadLines(*sshPublicKeyFile)
+		if err != nil {
+			return request, nil, nil, nil, err
+		}
+	}
 	if len(requestIPs) > 0 && requestIPs[0] != "" {
 		ipAddr := net.ParseIP(requestIPs[0])
 		if ipAddr == nil {
-			return fmt.Errorf("invalid IP address: %s", requestIPs[0])
+			return request, nil, nil, nil,
+				fmt.Errorf("invalid IP address: %s", requestIPs[0])
 		}
 		request.Address.IpAddress = ipAddr
 	}
@@ -143,18 +320,18 @@ func createVmOnHypervisor(hypervisor string, logger log.DebugLogger) error {
 			}
 			ipAddr := net.ParseIP(addr)
 			if ipAddr == nil {
-				return fmt.Errorf("invalid IP address: %s", requestIPs[0])
+				return request, nil, nil, nil,
+					fmt.Errorf("invalid IP address: %s", requestIPs[0])
 			}
 			request.SecondaryAddresses[index] = hyper_proto.Address{
 				IpAddress: ipAddr}
 		}
 	}
 	if sizes, err := parseSizes(secondaryVolumeSizes); err != nil {
-		return err
+		return request, nil, nil, nil, err
 	} else {
 		request.SecondaryVolumes = sizes
 	}
-	var imageReader, userDataReader io.Reader
 	if *imageName != "" {
 		request.ImageName = *imageName
 		request.ImageTimeout = *imageTimeout
@@ -164,47 +341,26 @@ func createVmOnHypervisor(hypervisor string, logger log.DebugLogger) error {
 	} else if *imageFile != "" {
 		file, size, err := getReader(*imageFile)
 		if err != nil {
-			return err
+			return request, nil, nil, nil, err
 		} else {
-			defer file.Close()
+			closers = append(closers, file)
 			request.ImageDataSize = uint64(size)
 			imageReader = bufio.NewReader(io.LimitReader(file, size))
 		}
 	} else {
-		return errors.New("no image specified")
+		return request, nil, nil, nil, errors.New("no image specified")
 	}
 	if *userDataFile != "" {
 		file, size, err := getReader(*userDataFile)
 		if err != nil {
-			return err
+			return request, nil, nil, nil, err
 		} else {
-			defer file.Close()
+			closers = append(closers, file)
 			request.UserDataSize = uint64(size)
 			userDataReader = bufio.NewReader(io.LimitReader(file, size))
 		}
 	}
-	client, err := dialHypervisor(hypervisor)
-	if err != nil {
-		return err
-	}
-	defer client.Close()
-	var reply hyper_proto.CreateVmResponse
-	err = callCreateVm(client, request, &reply, imageReader, userDataReader,
-		logger)
-	if err != nil {
-		return err
-	}
-	if err := hyperclient.AcknowledgeVm(client, reply.IpAddress); err != nil {
-		return fmt.Errorf("error acknowledging VM: %s", err)
-	}
-	fmt.Println(reply.IpAddress)
-	if reply.DhcpTimedOut {
-		return errors.New("DHCP ACK timed out")
-	}
-	if *dhcpTimeout > 0 {
-		logger.Debugln(0, "Received DHCP ACK")
-	}
-	return maybeWatchVm(client, hypervisor, reply.IpAddress, logger)
+	return request, imageReader, userDataReader, cleanup, nil
 }
 
 func getHypervisorAddress() (string, error) {
@@ -165,7 +165,10 @@ func makeVolume(volume proto.Volume, index int,
 			Io:    "native",
 		},
 		Source: sourceType{File: exportFilename},
-		Target: targetType{Device: "vd" + string('a'+index), Bus: "virtio"},
-		Type:   "file",
+		Target: targetType{
+			Device: "vd" + string(rune('a'+index)),
+			Bus:    "virtio",
+		},
+		Type: "file",
 	}, nil
 }
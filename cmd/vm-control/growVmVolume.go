@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	hyperclient "github.com/Symantec/Dominator/hypervisor/client"
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/log"
+)
+
+func growVmVolumeSubcommand(args []string, logger log.DebugLogger) error {
+	if err := growVmVolume(args[0], logger); err != nil {
+		return fmt.Errorf("Error growing VM volume: %s", err)
+	}
+	return nil
+}
+
+func growVmVolume(vmHostname string, logger log.DebugLogger) error {
+	if vmIP, hypervisor, err := lookupVmAndHypervisor(vmHostname); err != nil {
+		return err
+	} else {
+		return growVmVolumeOnHypervisor(hypervisor, vmIP, logger)
+	}
+}
+
+func growVmVolumeOnHypervisor(hypervisor string, ipAddr net.IP,
+	logger log.DebugLogger) error {
+	if volumeSize < 1 {
+		return errors.New("no volume size specified")
+	}
+	client, err := dialHypervisor(hypervisor)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	return hyperclient.GrowVmVolume(client, ipAddr, nil, *volumeIndex,
+		uint64(volumeSize))
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/log"
+	proto "github.com/Symantec/Dominator/proto/fleetmanager"
+)
+
+func formatQuota(used, limit string, unlimited bool) string {
+	if unlimited {
+		return used
+	}
+	return used + "/" + limit
+}
+
+func listQuotasSubcommand(args []string, logger log.DebugLogger) error {
+	var location string
+	if len(args) > 0 {
+		location = args[0]
+	}
+	if err := listQuotas(location, logger); err != nil {
+		return fmt.Errorf("Error listing quotas: %s", err)
+	}
+	return nil
+}
+
+func listQuotas(location string, logger log.DebugLogger) error {
+	fleetManager := fmt.Sprintf("%s:%d",
+		*fleetManagerHostname, *fleetManagerPortNum)
+	client, err := dialFleetManager(fleetManager)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	request := proto.ListQuotasRequest{location}
+	var reply proto.ListQuotasResponse
+	err = client.RequestReply("FleetManager.ListQuotas", request, &reply)
+	if err != nil {
+		return err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return err
+	}
+	for _, quota := range reply.Quotas {
+		owner := "group:" + quota.Group
+		if quota.User != "" {
+			owner = "user:" + quota.User
+		}
+		_, err := fmt.Printf("%s %s VMs: %s CPUs: %s RAM: %s storage: %s\n",
+			quota.Location, owner,
+			formatQuota(fmt.Sprint(quota.Used.NumVMs),
+				fmt.Sprint(quota.Limit.NumVMs), quota.Limit.NumVMs < 1),
+			formatQuota(fmt.Sprint(float64(quota.Used.MilliCPUs)*1e-3),
+				fmt.Sprint(float64(quota.Limit.MilliCPUs)*1e-3),
+				quota.Limit.MilliCPUs < 1),
+			formatQuota(format.FormatBytes(quota.Used.MemoryInMiB<<20),
+				format.FormatBytes(quota.Limit.MemoryInMiB<<20),
+				quota.Limit.MemoryInMiB < 1),
+			formatQuota(format.FormatBytes(quota.Used.VolumeBytes),
+				format.FormatBytes(quota.Limit.VolumeBytes),
+				quota.Limit.VolumeBytes < 1))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"text/tabwriter"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/log"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func listVmSnapshotsSubcommand(args []string, logger log.DebugLogger) error {
+	if err := listVmSnapshots(args[0], logger); err != nil {
+		return fmt.Errorf("Error listing VM snapshots: %s", err)
+	}
+	return nil
+}
+
+func listVmSnapshots(vmHostname string, logger log.DebugLogger) error {
+	if vmIP, hypervisor, err := lookupVmAndHypervisor(vmHostname); err != nil {
+		return err
+	} else {
+		return listVmSnapshotsOnHypervisor(hypervisor, vmIP, logger)
+	}
+}
+
+func listVmSnapshotsOnHypervisor(hypervisor string, ipAddr net.IP,
+	logger log.DebugLogger) error {
+	request := proto.ListVmSnapshotsRequest{IpAddress: ipAddr}
+	client, err := dialHypervisor(hypervisor)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	var reply proto.ListVmSnapshotsResponse
+	err = client.RequestReply("Hypervisor.ListVmSnapshots", request, &reply)
+	if err != nil {
+		return err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return err
+	}
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tCREATED\tSIZE\tSCHEDULE\tVOLUMES")
+	for _, snapshot := range reply.Snapshots {
+		name := snapshot.Name
+		if name == "" {
+			name = "(unnamed)"
+		}
+		schedule := "manual"
+		if snapshot.Interval > 0 {
+			schedule = "every " + format.Duration(snapshot.Interval)
+		}
+		volumes := "all"
+		if snapshot.RootOnly {
+			volumes = "root"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", name,
+			snapshot.CreatedOn.Local().Format(format.TimeFormatSeconds),
+			format.FormatBytes(snapshot.Size), schedule, volumes)
+	}
+	return writer.Flush()
+}
@@ -21,6 +21,9 @@ import (
 var (
 	adjacentVM = flag.String("adjacentVM", "",
 		"IP address of VM adjacent (same Hypervisor) to VM being created")
+	affinityTagKeys flagutil.StringList
+	configDrive     = flag.Bool("configDrive", false,
+		"If true, attach a NoCloud config drive ISO to the VM")
 	consoleType       hyper_proto.ConsoleType
 	destroyProtection = flag.Bool("destroyProtection", false,
 		"If true, do not destroy running VM")
@@ -28,6 +31,14 @@ var (
 		"If true, disable virtio drivers, reducing I/O performance")
 	dhcpTimeout = flag.Duration("dhcpTimeout", time.Minute,
 		"Time to wait before timing out on DHCP request from VM")
+	diskBytesPerSecond flagutil.Size
+	diskOpsPerSecond   = flag.Uint64("diskOpsPerSecond", 0,
+		"Maximum disk operations per second per volume (default unlimited)")
+	dryRun = flag.Bool("dryRun", false,
+		"If true, only show which Hypervisor would be chosen for the VM")
+	egressBytesPerSecond flagutil.Size
+	firewallFile         = flag.String("firewallFile", "",
+		"Name of JSON file containing firewall policy (default allow all)")
 	fleetManagerHostname = flag.String("fleetManagerHostname", "",
 		"Hostname of Fleet Manager")
 	fleetManagerPortNum = flag.Uint("fleetManagerPortNum",
@@ -41,13 +52,16 @@ var (
 		constants.HypervisorPortNumber, "Port number of hypervisor")
 	includeUnhealthy = flag.Bool("includeUnhealthy", false,
 		"If true, list connected but unhealthy hypervisors")
-	imageFile = flag.String("imageFile", "",
+	ingressBytesPerSecond flagutil.Size
+	imageFile             = flag.String("imageFile", "",
 		"Name of RAW image file to boot with")
 	imageName    = flag.String("imageName", "", "Name of image to boot with")
 	imageTimeout = flag.Duration("imageTimeout", time.Minute,
 		"Time to wait before timing out on image fetch")
 	imageURL = flag.String("imageURL", "",
 		"Name of URL of image to boot with")
+	liveMigrate = flag.Bool("liveMigrate", false,
+		"If true, migrate a running VM without stopping it (if supported)")
 	localVmCreate = flag.String("localVmCreate", "",
 		"Command to make local VM when exporting. The VM name is given as the argument. The VM JSON is available on stdin")
 	localVmDestroy = flag.String("localVmDestroy", "",
@@ -57,6 +71,8 @@ var (
 	memory       flagutil.Size
 	milliCPUs    = flag.Uint("milliCPUs", 0, "milli CPUs (default 250)")
 	minFreeBytes = flagutil.Size(256 << 20)
+	objectServer = flag.String("objectServer", "",
+		"Address (host:port) of object server for backups (default Hypervisor backup server)")
 	ownerGroups  flagutil.StringList
 	ownerUsers   flagutil.StringList
 	probePortNum = flag.Uint("probePortNum", 0, "Port number on VM to probe")
@@ -68,13 +84,24 @@ var (
 		"Serial port number on VM")
 	skipBootloader = flag.Bool("skipBootloader", false,
 		"If true, directly boot into the kernel")
+	spreadByOwner = flag.Bool("spreadByOwner", false,
+		"If true, avoid Hypervisors with VMs owned by the same user")
+	spreadTagKeys    flagutil.StringList
+	sshPublicKeyFile = flag.String("sshPublicKeyFile", "",
+		"Name of file containing SSH public keys for the VM")
+	strictSpread = flag.Bool("strictSpread", false,
+		"If true, fail rather than place VM next to VMs to spread away from")
 	subnetId = flag.String("subnetId", "",
 		"Subnet ID to launch VM in")
 	requestIPs   flagutil.StringList
 	roundupPower = flag.Uint64("roundupPower", 28,
 		"power of 2 to round up root volume size")
+	snapshotName = flag.String("snapshotName", "",
+		"Name of snapshot (default unnamed snapshot)")
+	snapshotPolicyFile = flag.String("snapshotPolicyFile", "",
+		"Name of JSON file containing snapshot policy (default use tag policy)")
 	snapshotRootOnly = flag.Bool("snapshotRootOnly", false,
-		"If true, snapshot only the root volume")
+		"If true, snapshot or back up only the root volume")
 	traceMetadata = flag.Bool("traceMetadata", false,
 		"If true, trace metadata calls until interrupted")
 	userDataFile = flag.String("userDataFile", "",
@@ -86,15 +113,24 @@ var (
 	volumeFilename = flag.String("volumeFilename", "",
 		"Name of file to write volume data to")
 	volumeIndex = flag.Uint("volumeIndex", 0,
-		"Index of volume to get or delete")
+		"Index of volume to get, grow or delete")
+	volumeSize flagutil.Size
 
 	logger   log.DebugLogger
 	rrDialer *rrdialer.Dialer
 )
 
 func init() {
+	flag.Var(&affinityTagKeys, "affinityTagKeys",
+		"Prefer Hypervisors with VMs with the same values for these tags")
 	flag.Var(&consoleType, "consoleType",
 		"type of graphical console (default none)")
+	flag.Var(&diskBytesPerSecond, "diskBytesPerSecond",
+		"Maximum disk bandwidth per volume (default unlimited)")
+	flag.Var(&egressBytesPerSecond, "egressBytesPerSecond",
+		"Maximum network bandwidth from the VM (default unlimited)")
+	flag.Var(&ingressBytesPerSecond, "ingressBytesPerSecond",
+		"Maximum network bandwidth to the VM (default unlimited)")
 	flag.Var(&memory, "memory", "memory (default 1GiB)")
 	flag.Var(&minFreeBytes, "minFreeBytes",
 		"minimum number of free bytes in root volume")
@@ -104,7 +140,10 @@ func init() {
 	flag.Var(&secondarySubnetIDs, "secondarySubnetIDs", "Secondary Subnet IDs")
 	flag.Var(&secondaryVolumeSizes, "secondaryVolumeSizes",
 		"Sizes for secondary volumes")
+	flag.Var(&spreadTagKeys, "spreadTagKeys",
+		"Avoid Hypervisors with VMs with the same values for these tags")
 	flag.Var(&vmTags, "vmTags", "Tags to apply to VM")
+	flag.Var(&volumeSize, "volumeSize", "Size of volume to add or grow to")
 }
 
 func printUsage() {
@@ -113,10 +152,16 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "Common flags:")
 	flag.PrintDefaults()
 	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintln(os.Stderr, "  add-vm-volume IPaddr")
+	fmt.Fprintln(os.Stderr, "  apply spec-file...")
+	fmt.Fprintln(os.Stderr, "  backup-vm IPaddr")
 	fmt.Fprintln(os.Stderr, "  become-primary-vm-owner IPaddr")
 	fmt.Fprintln(os.Stderr, "  change-vm-console-type IPaddr")
 	fmt.Fprintln(os.Stderr, "  change-vm-destroy-protection IPaddr")
+	fmt.Fprintln(os.Stderr, "  change-vm-firewall IPaddr")
+	fmt.Fprintln(os.Stderr, "  change-vm-limits IPaddr")
 	fmt.Fprintln(os.Stderr, "  change-vm-owner-users IPaddr")
+	fmt.Fprintln(os.Stderr, "  change-vm-size IPaddr")
 	fmt.Fprintln(os.Stderr, "  change-vm-tags IPaddr")
 	fmt.Fprintln(os.Stderr, "  connect-to-vm-console IPaddr")
 	fmt.Fprintln(os.Stderr, "  connect-to-vm-serial-port IPaddr")
@@ -132,20 +177,26 @@ func printUsage() {
 	fmt.Fprintln(os.Stderr, "  get-vm-info IPaddr")
 	fmt.Fprintln(os.Stderr, "  get-vm-user-data IPaddr")
 	fmt.Fprintln(os.Stderr, "  get-vm-volume IPaddr")
+	fmt.Fprintln(os.Stderr, "  grow-vm-volume IPaddr")
 	fmt.Fprintln(os.Stderr, "  import-local-vm info-file root-volume")
 	fmt.Fprintln(os.Stderr, "  import-virsh-vm MACaddr domain [[MAC IP]...]")
 	fmt.Fprintln(os.Stderr, "  list-hypervisors")
 	fmt.Fprintln(os.Stderr, "  list-locations [TopLocation]")
+	fmt.Fprintln(os.Stderr, "  list-quotas [Location]")
+	fmt.Fprintln(os.Stderr, "  list-vm-snapshots IPaddr")
 	fmt.Fprintln(os.Stderr, "  list-vms")
 	fmt.Fprintln(os.Stderr, "  migrate-vm IPaddr")
 	fmt.Fprintln(os.Stderr, "  patch-vm-image IPaddr")
+	fmt.Fprintln(os.Stderr, "  plan spec-file...")
 	fmt.Fprintln(os.Stderr, "  probe-vm-port IPaddr")
 	fmt.Fprintln(os.Stderr, "  replace-vm-image IPaddr")
 	fmt.Fprintln(os.Stderr, "  replace-vm-user-data IPaddr")
+	fmt.Fprintln(os.Stderr, "  restore-vm-from-backup ManifestHash")
 	fmt.Fprintln(os.Stderr, "  restore-vm-from-snapshot IPaddr")
 	fmt.Fprintln(os.Stderr, "  restore-vm-image IPaddr")
 	fmt.Fprintln(os.Stderr, "  restore-vm-user-data IPaddr")
 	fmt.Fprintln(os.Stderr, "  set-vm-migrating IPaddr")
+	fmt.Fprintln(os.Stderr, "  set-vm-snapshot-policy IPaddr")
 	fmt.Fprintln(os.Stderr, "  snapshot-vm IPaddr")
 	fmt.Fprintln(os.Stderr, "  start-vm IPaddr")
 	fmt.Fprintln(os.Stderr, "  stop-vm IPaddr")
@@ -163,10 +214,16 @@ type subcommand struct {
 }
 
 var subcommands = []subcommand{
+	{"add-vm-volume", 1, 1, addVmVolumeSubcommand},
+	{"apply", 1, -1, applySubcommand},
+	{"backup-vm", 1, 1, backupVmSubcommand},
 	{"become-primary-vm-owner", 1, 1, becomePrimaryVmOwnerSubcommand},
 	{"change-vm-console-type", 1, 1, changeVmConsoleTypeSubcommand},
 	{"change-vm-destroy-protection", 1, 1, changeVmDestroyProtectionSubcommand},
+	{"change-vm-firewall", 1, 1, changeVmFirewallSubcommand},
+	{"change-vm-limits", 1, 1, changeVmLimitsSubcommand},
 	{"change-vm-owner-users", 1, 1, changeVmOwnerUsersSubcommand},
+	{"change-vm-size", 1, 1, changeVmSizeSubcommand},
 	{"change-vm-tags", 1, 1, changeVmTagsSubcommand},
 	{"connect-to-vm-console", 1, 1, connectToVmConsoleSubcommand},
 	{"connect-to-vm-serial-port", 1, 1, connectToVmSerialPortSubcommand},
@@ -182,20 +239,26 @@ var subcommands = []subcommand{
 	{"get-vm-info", 1, 1, getVmInfoSubcommand},
 	{"get-vm-user-data", 1, 1, getVmUserDataSubcommand},
 	{"get-vm-volume", 1, 1, getVmVolumeSubcommand},
+	{"grow-vm-volume", 1, 1, growVmVolumeSubcommand},
 	{"import-local-vm", 2, 2, importLocalVmSubcommand},
 	{"import-virsh-vm", 2, -1, importVirshVmSubcommand},
 	{"list-hypervisors", 0, 0, listHypervisorsSubcommand},
 	{"list-locations", 0, 1, listLocationsSubcommand},
+	{"list-quotas", 0, 1, listQuotasSubcommand},
+	{"list-vm-snapshots", 1, 1, listVmSnapshotsSubcommand},
 	{"list-vms", 0, 0, listVMsSubcommand},
 	{"migrate-vm", 1, 1, migrateVmSubcommand},
 	{"patch-vm-image", 1, 1, patchVmImageSubcommand},
+	{"plan", 1, -1, planSubcommand},
 	{"probe-vm-port", 1, 1, probeVmPortSubcommand},
 	{"replace-vm-image", 1, 1, replaceVmImageSubcommand},
 	{"replace-vm-user-data", 1, 1, replaceVmUserDataSubcommand},
+	{"restore-vm-from-backup", 1, 1, restoreVmFromBackupSubcommand},
 	{"restore-vm-from-snapshot", 1, 1, restoreVmFromSnapshotSubcommand},
 	{"restore-vm-image", 1, 1, restoreVmImageSubcommand},
 	{"restore-vm-user-data", 1, 1, restoreVmUserDataSubcommand},
 	{"set-vm-migrating", 1, 1, setVmMigratingSubcommand},
+	{"set-vm-snapshot-policy", 1, 1, setVmSnapshotPolicySubcommand},
 	{"snapshot-vm", 1, 1, snapshotVmSubcommand},
 	{"start-vm", 1, 1, startVmSubcommand},
 	{"stop-vm", 1, 1, stopVmSubcommand},
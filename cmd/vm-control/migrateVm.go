@@ -77,6 +77,7 @@ func migrateVmFromHypervisor(sourceHypervisorAddress string, vmIP net.IP,
 	request := hyper_proto.MigrateVmRequest{
 		AccessToken:      accessToken,
 		IpAddress:        vmIP,
+		Live:             *liveMigrate,
 		SourceHypervisor: sourceHypervisorAddress,
 	}
 	if err := conn.Encode(request); err != nil {
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Symantec/Dominator/lib/log"
+)
+
+func planSubcommand(args []string, logger log.DebugLogger) error {
+	for _, filename := range args {
+		if err := applyVmSpecFile(filename, true, logger); err != nil {
+			return fmt.Errorf("Error planning VM specification: %s: %s",
+				filename, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	hyperclient "github.com/Symantec/Dominator/hypervisor/client"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/srpc"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func restoreVmFromBackupSubcommand(args []string,
+	logger log.DebugLogger) error {
+	if err := restoreVmFromBackup(args[0], logger); err != nil {
+		return fmt.Errorf("Error restoring VM from backup: %s", err)
+	}
+	return nil
+}
+
+func callRestoreVmFromBackup(client *srpc.Client,
+	request hyper_proto.RestoreVmFromBackupRequest,
+	reply *hyper_proto.RestoreVmFromBackupResponse,
+	logger log.DebugLogger) error {
+	conn, err := client.Call("Hypervisor.RestoreVmFromBackup")
+	if err != nil {
+		return fmt.Errorf("error calling Hypervisor.RestoreVmFromBackup: %s",
+			err)
+	}
+	defer conn.Close()
+	if err := conn.Encode(request); err != nil {
+		return fmt.Errorf("error encoding RestoreVmFromBackup request: %s", err)
+	}
+	if err := conn.Flush(); err != nil {
+		return fmt.Errorf("error flushing RestoreVmFromBackup request: %s", err)
+	}
+	for {
+		var response hyper_proto.RestoreVmFromBackupResponse
+		if err := conn.Decode(&response); err != nil {
+			return fmt.Errorf("error decoding RestoreVmFromBackup response: %s",
+				err)
+		}
+		if response.Error != "" {
+			return errors.New(response.Error)
+		}
+		if response.ProgressMessage != "" {
+			logger.Debugln(0, response.ProgressMessage)
+		}
+		if response.Final {
+			*reply = response
+			return nil
+		}
+	}
+}
+
+func parseHash(text string) (hash.Hash, error) {
+	var hashVal hash.Hash
+	data, err := hex.DecodeString(text)
+	if err != nil {
+		return hashVal, err
+	}
+	if len(data) != len(hashVal) {
+		return hashVal, fmt.Errorf("hash length: %d, expected: %d",
+			len(data), len(hashVal))
+	}
+	copy(hashVal[:], data)
+	return hashVal, nil
+}
+
+func restoreVmFromBackup(manifestHashText string,
+	logger log.DebugLogger) error {
+	manifestHash, err := parseHash(manifestHashText)
+	if err != nil {
+		return err
+	}
+	hypervisorAddress, err := getHypervisorAddress()
+	if err != nil {
+		return err
+	}
+	client, err := dialHypervisor(hypervisorAddress)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	request := hyper_proto.RestoreVmFromBackupRequest{
+		ManifestHash: manifestHash,
+		ObjectServer: *objectServer,
+		VmInfo:       createVmInfoFromFlags(),
+	}
+	var reply hyper_proto.RestoreVmFromBackupResponse
+	logger.Debugf(0, "restoring VM to %s\n", hypervisorAddress)
+	err = callRestoreVmFromBackup(client, request, &reply, logger)
+	if err != nil {
+		return err
+	}
+	if err := hyperclient.AcknowledgeVm(client, reply.IpAddress); err != nil {
+		return fmt.Errorf("error acknowledging VM: %s", err)
+	}
+	fmt.Println(reply.IpAddress)
+	return nil
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/json"
+	"github.com/Symantec/Dominator/lib/log"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func setVmSnapshotPolicySubcommand(args []string,
+	logger log.DebugLogger) error {
+	if err := setVmSnapshotPolicy(args[0], logger); err != nil {
+		return fmt.Errorf("Error setting VM snapshot policy: %s", err)
+	}
+	return nil
+}
+
+func setVmSnapshotPolicy(vmHostname string, logger log.DebugLogger) error {
+	if vmIP, hypervisor, err := lookupVmAndHypervisor(vmHostname); err != nil {
+		return err
+	} else {
+		return setVmSnapshotPolicyOnHypervisor(hypervisor, vmIP, logger)
+	}
+}
+
+func setVmSnapshotPolicyOnHypervisor(hypervisor string, ipAddr net.IP,
+	logger log.DebugLogger) error {
+	request := proto.SetVmSnapshotPolicyRequest{IpAddress: ipAddr}
+	if *snapshotPolicyFile != "" {
+		request.Policy = new(proto.SnapshotPolicy)
+		err := json.ReadFromFile(*snapshotPolicyFile, request.Policy)
+		if err != nil {
+			return err
+		}
+	}
+	client, err := dialHypervisor(hypervisor)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	var reply proto.SetVmSnapshotPolicyResponse
+	err = client.RequestReply("Hypervisor.SetVmSnapshotPolicy", request,
+		&reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
@@ -0,0 +1,472 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	hyperclient "github.com/Symantec/Dominator/hypervisor/client"
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/fsutil"
+	libjson "github.com/Symantec/Dominator/lib/json"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/lib/tags"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+	"gopkg.in/yaml.v2"
+)
+
+type vmChange struct {
+	apply       func(client *srpc.Client, ipAddr net.IP) error
+	description string
+	needsStop   bool
+}
+
+// vmSpec is a declarative specification of a VM, in JSON or YAML. The
+// IpAddress identifies an existing VM. If it is not specified, the VM is
+// created and its IpAddress is recorded in a state file alongside the
+// specification file, which is not modified.
+type vmSpec struct {
+	ConfigDrive          bool                  `json:",omitempty"`
+	ConsoleType          proto.ConsoleType     `json:",omitempty"`
+	DestroyProtection    bool                  `json:",omitempty"`
+	Firewall             *proto.FirewallPolicy `json:",omitempty"`
+	Hostname             string                `json:",omitempty"`
+	ImageName            string                `json:",omitempty"`
+	ImageURL             string                `json:",omitempty"`
+	IpAddress            net.IP                `json:",omitempty"`
+	Limits               *proto.VmLimits       `json:",omitempty"`
+	Location             string                `json:",omitempty"`
+	MemoryInMiB          uint64                `json:",omitempty"`
+	MilliCPUs            uint                  `json:",omitempty"`
+	OwnerGroups          []string              `json:",omitempty"`
+	OwnerUsers           []string              `json:",omitempty"` // Extra.
+	SecondarySubnetIDs   []string              `json:",omitempty"`
+	SecondaryVolumeSizes []string              `json:",omitempty"`
+	SshPublicKeys        []string              `json:",omitempty"`
+	SubnetId             string                `json:",omitempty"`
+	Tags                 tags.Tags             `json:",omitempty"`
+	UserDataFile         string                `json:",omitempty"`
+}
+
+// vmSpecState records the IP address of a VM created from a specification.
+type vmSpecState struct {
+	IpAddress net.IP
+}
+
+// checkVmSpecStateWritable returns an error if the state file for the
+// specification cannot be created, so that a VM is not created which cannot
+// be recorded.
+func checkVmSpecStateWritable(filename string) error {
+	stateFilename := getVmSpecStateFilename(filename)
+	file, err := os.OpenFile(stateFilename, os.O_WRONLY|os.O_CREATE|os.O_EXCL,
+		fsutil.PublicFilePerms)
+	if err != nil {
+		return fmt.Errorf("cannot record VM: %s", err)
+	}
+	file.Close()
+	return os.Remove(stateFilename)
+}
+
+// convertYamlValue converts the maps decoded from YAML to maps with string
+// keys, so that they may be encoded as JSON.
+func convertYamlValue(value interface{}) (interface{}, error) {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for key, element := range value {
+			keyString, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("non-string key: %v", key)
+			}
+			element, err := convertYamlValue(element)
+			if err != nil {
+				return nil, err
+			}
+			result[keyString] = element
+		}
+		return result, nil
+	case []interface{}:
+		result := make([]interface{}, 0, len(value))
+		for _, element := range value {
+			element, err := convertYamlValue(element)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, element)
+		}
+		return result, nil
+	}
+	return value, nil
+}
+
+// imageNameMatches returns true if the image name of the VM is the image
+// name in the specification or is an image in the stream (directory) named in
+// the specification.
+func imageNameMatches(specImageName, vmImageName string) bool {
+	if specImageName == vmImageName {
+		return true
+	}
+	return strings.HasPrefix(vmImageName,
+		strings.TrimSuffix(specImageName, "/")+"/")
+}
+
+func getVmSpecStateFilename(filename string) string {
+	return filename + ".state"
+}
+
+func isYamlFile(filename string) bool {
+	switch filepath.Ext(filename) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+func loadVmSpec(filename string) (vmSpec, error) {
+	var spec vmSpec
+	if err := readVmSpecFile(filename, &spec); err != nil {
+		return vmSpec{}, err
+	}
+	if spec.ImageName != "" && spec.ImageURL != "" {
+		return vmSpec{}, errors.New("both ImageName and ImageURL specified")
+	}
+	if err := spec.ConsoleType.CheckValid(); err != nil {
+		return vmSpec{}, err
+	}
+	if spec.Firewall != nil {
+		if err := spec.Firewall.CheckValid(); err != nil {
+			return vmSpec{}, err
+		}
+	}
+	if _, err := parseSizes(spec.SecondaryVolumeSizes); err != nil {
+		return vmSpec{}, err
+	}
+	return spec, nil
+}
+
+// applyDefaults returns a copy of the specification with the defaults used
+// by create-vm filled in. Relative filenames are made relative to the
+// directory containing the specification file.
+func (spec vmSpec) applyDefaults(filename string) vmSpec {
+	if spec.MemoryInMiB < 1 {
+		spec.MemoryInMiB = 1024
+	}
+	if spec.MilliCPUs < 1 {
+		spec.MilliCPUs = 250
+	}
+	if spec.Hostname != "" && spec.Tags["Name"] == "" {
+		spec.Tags = spec.Tags.Copy()
+		spec.Tags["Name"] = spec.Hostname
+	}
+	if spec.UserDataFile != "" && !filepath.IsAbs(spec.UserDataFile) {
+		spec.UserDataFile = filepath.Join(filepath.Dir(filename),
+			spec.UserDataFile)
+	}
+	return spec
+}
+
+func (spec vmSpec) makeVmInfo() proto.VmInfo {
+	return proto.VmInfo{
+		ConfigDrive:        spec.ConfigDrive,
+		ConsoleType:        spec.ConsoleType,
+		DestroyProtection:  spec.DestroyProtection,
+		Firewall:           spec.Firewall,
+		Hostname:           spec.Hostname,
+		ImageName:          spec.ImageName,
+		ImageURL:           spec.ImageURL,
+		Limits:             spec.Limits,
+		MemoryInMiB:        spec.MemoryInMiB,
+		MilliCPUs:          spec.MilliCPUs,
+		OwnerGroups:        spec.OwnerGroups,
+		OwnerUsers:         spec.OwnerUsers,
+		SecondarySubnetIDs: spec.SecondarySubnetIDs,
+		SshPublicKeys:      spec.SshPublicKeys,
+		SubnetId:           spec.SubnetId,
+		Tags:               spec.Tags,
+	}
+}
+
+func limitsEqual(left, right *proto.VmLimits) bool {
+	if left == nil {
+		left = &proto.VmLimits{}
+	}
+	if right == nil {
+		right = &proto.VmLimits{}
+	}
+	return *left == *right
+}
+
+func stringSlicesEqual(left, right []string) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for index, leftString := range left {
+		if leftString != right[index] {
+			return false
+		}
+	}
+	return true
+}
+
+// planVmChanges returns the changes needed to make the VM match the
+// specification, using the same RPCs as the corresponding subcommands. An
+// error is returned if the VM differs in ways which cannot be changed without
+// re-creating it.
+func planVmChanges(spec vmSpec, vmInfo proto.VmInfo,
+	logger log.DebugLogger) ([]vmChange, error) {
+	var changes []vmChange
+	var problems []string
+	if spec.ConfigDrive != vmInfo.ConfigDrive {
+		problems = append(problems, "ConfigDrive")
+	}
+	if spec.Hostname != "" && spec.Hostname != vmInfo.Hostname {
+		problems = append(problems, "Hostname")
+	}
+	if !stringSlicesEqual(spec.OwnerGroups, vmInfo.OwnerGroups) {
+		problems = append(problems, "OwnerGroups")
+	}
+	if !stringSlicesEqual(spec.SecondarySubnetIDs,
+		vmInfo.SecondarySubnetIDs) {
+		problems = append(problems, "SecondarySubnetIDs")
+	}
+	if !stringSlicesEqual(spec.SshPublicKeys, vmInfo.SshPublicKeys) {
+		problems = append(problems, "SshPublicKeys")
+	}
+	if spec.SubnetId != "" && spec.SubnetId != vmInfo.SubnetId {
+		problems = append(problems, "SubnetId")
+	}
+	if (spec.ImageName != "" &&
+		!imageNameMatches(spec.ImageName, vmInfo.ImageName)) ||
+		(spec.ImageURL != "" && spec.ImageURL != vmInfo.ImageURL) {
+		request := proto.ReplaceVmImageRequest{
+			DhcpTimeout:      *dhcpTimeout,
+			ImageName:        spec.ImageName,
+			ImageURL:         spec.ImageURL,
+			MinimumFreeBytes: uint64(minFreeBytes),
+			RoundupPower:     *roundupPower,
+		}
+		description := fmt.Sprintf("replace image: %s -> %s",
+			vmInfo.ImageName, spec.ImageName)
+		if spec.ImageName != "" {
+			request.ImageTimeout = *imageTimeout
+			request.SkipBootloader = *skipBootloader
+		} else {
+			description = fmt.Sprintf("replace image: %s -> %s",
+				vmInfo.ImageURL, spec.ImageURL)
+		}
+		changes = append(changes, vmChange{
+			apply: func(client *srpc.Client, ipAddr net.IP) error {
+				request.IpAddress = ipAddr
+				var reply proto.ReplaceVmImageResponse
+				return callReplaceVmImage(client, request, &reply, nil, logger)
+			},
+			description: description,
+			needsStop:   true,
+		})
+	}
+	if spec.MemoryInMiB != vmInfo.MemoryInMiB ||
+		spec.MilliCPUs != vmInfo.MilliCPUs {
+		changes = append(changes, vmChange{
+			apply: func(client *srpc.Client, ipAddr net.IP) error {
+				return hyperclient.ChangeVmSize(client, ipAddr, nil,
+					spec.MemoryInMiB, spec.MilliCPUs)
+			},
+			description: fmt.Sprintf(
+				"change size: %d MiB, %d milliCPUs -> %d MiB, %d milliCPUs",
+				vmInfo.MemoryInMiB, vmInfo.MilliCPUs, spec.MemoryInMiB,
+				spec.MilliCPUs),
+			needsStop: true,
+		})
+	}
+	if spec.ConsoleType != vmInfo.ConsoleType {
+		request := proto.ChangeVmConsoleTypeRequest{
+			ConsoleType: spec.ConsoleType,
+		}
+		changes = append(changes, vmChange{
+			apply: func(client *srpc.Client, ipAddr net.IP) error {
+				request.IpAddress = ipAddr
+				var reply proto.ChangeVmConsoleTypeResponse
+				err := client.RequestReply("Hypervisor.ChangeVmConsoleType",
+					request, &reply)
+				if err != nil {
+					return err
+				}
+				return errors.New(reply.Error)
+			},
+			description: fmt.Sprintf("change console type: %s -> %s",
+				vmInfo.ConsoleType, spec.ConsoleType),
+			needsStop: true,
+		})
+	}
+	specVolumes, err := parseSizes(spec.SecondaryVolumeSizes)
+	if err != nil {
+		return nil, err
+	}
+	for index, volume := range specVolumes {
+		volumeIndex := uint(index + 1)
+		size := volume.Size
+		if int(volumeIndex) >= len(vmInfo.Volumes) {
+			changes = append(changes, vmChange{
+				apply: func(client *srpc.Client, ipAddr net.IP) error {
+					return hyperclient.AddVmVolume(client, ipAddr, nil, size)
+				},
+				description: "add volume: " + format.FormatBytes(size),
+				needsStop:   true,
+			})
+			continue
+		}
+		currentSize := vmInfo.Volumes[volumeIndex].Size
+		if size < currentSize {
+			problems = append(problems,
+				fmt.Sprintf("volume %d size (cannot shrink)", volumeIndex))
+		} else if size > currentSize {
+			changes = append(changes, vmChange{
+				apply: func(client *srpc.Client, ipAddr net.IP) error {
+					return hyperclient.GrowVmVolume(client, ipAddr, nil,
+						volumeIndex, size)
+				},
+				description: fmt.Sprintf("grow volume %d: %s -> %s",
+					volumeIndex, format.FormatBytes(currentSize),
+					format.FormatBytes(size)),
+			})
+		}
+	}
+	for index := len(specVolumes) + 1; index < len(vmInfo.Volumes); index++ {
+		problems = append(problems, fmt.Sprintf(
+			"volume %d (not in specification, use delete-vm-volume)", index))
+	}
+	if spec.DestroyProtection != vmInfo.DestroyProtection {
+		request := proto.ChangeVmDestroyProtectionRequest{
+			DestroyProtection: spec.DestroyProtection,
+		}
+		changes = append(changes, vmChange{
+			apply: func(client *srpc.Client, ipAddr net.IP) error {
+				request.IpAddress = ipAddr
+				var reply proto.ChangeVmDestroyProtectionResponse
+				err := client.RequestReply(
+					"Hypervisor.ChangeVmDestroyProtection", request, &reply)
+				if err != nil {
+					return err
+				}
+				return errors.New(reply.Error)
+			},
+			description: fmt.Sprintf("change destroy protection: %t -> %t",
+				vmInfo.DestroyProtection, spec.DestroyProtection),
+		})
+	}
+	if !spec.Firewall.Equal(vmInfo.Firewall) {
+		request := proto.ChangeVmFirewallRequest{Firewall: spec.Firewall}
+		changes = append(changes, vmChange{
+			apply: func(client *srpc.Client, ipAddr net.IP) error {
+				request.IpAddress = ipAddr
+				var reply proto.ChangeVmFirewallResponse
+				err := client.RequestReply("Hypervisor.ChangeVmFirewall",
+					request, &reply)
+				if err != nil {
+					return err
+				}
+				return errors.New(reply.Error)
+			},
+			description: "change firewall policy",
+		})
+	}
+	if !limitsEqual(spec.Limits, vmInfo.Limits) {
+		request := proto.ChangeVmLimitsRequest{Limits: spec.Limits}
+		changes = append(changes, vmChange{
+			apply: func(client *srpc.Client, ipAddr net.IP) error {
+				request.IpAddress = ipAddr
+				var reply proto.ChangeVmLimitsResponse
+				err := client.RequestReply("Hypervisor.ChangeVmLimits",
+					request, &reply)
+				if err != nil {
+					return err
+				}
+				return errors.New(reply.Error)
+			},
+			description: "change limits",
+		})
+	}
+	var extraOwnerUsers []string
+	if len(vmInfo.OwnerUsers) > 1 {
+		extraOwnerUsers = vmInfo.OwnerUsers[1:]
+	}
+	if !stringSlicesEqual(spec.OwnerUsers, extraOwnerUsers) {
+		request := proto.ChangeVmOwnerUsersRequest{OwnerUsers: spec.OwnerUsers}
+		changes = append(changes, vmChange{
+			apply: func(client *srpc.Client, ipAddr net.IP) error {
+				request.IpAddress = ipAddr
+				var reply proto.ChangeVmOwnerUsersResponse
+				err := client.RequestReply("Hypervisor.ChangeVmOwnerUsers",
+					request, &reply)
+				if err != nil {
+					return err
+				}
+				return errors.New(reply.Error)
+			},
+			description: fmt.Sprintf("change owner users: %v -> %v",
+				extraOwnerUsers, spec.OwnerUsers),
+		})
+	}
+	if !spec.Tags.Equal(vmInfo.Tags) {
+		changes = append(changes, vmChange{
+			apply: func(client *srpc.Client, ipAddr net.IP) error {
+				return setVmTagsOnHypervisor(client, ipAddr, spec.Tags, logger)
+			},
+			description: fmt.Sprintf("change tags: %v -> %v",
+				vmInfo.Tags, spec.Tags),
+		})
+	}
+	if len(problems) > 0 {
+		return nil, fmt.Errorf("cannot change without re-creating VM: %s",
+			strings.Join(problems, ", "))
+	}
+	return changes, nil
+}
+
+// readVmSpecFile reads a JSON or YAML (if the filename ends with .yaml or
+// .yml) specification. YAML uses the same field names as JSON.
+func readVmSpecFile(filename string, spec *vmSpec) error {
+	if !isYamlFile(filename) {
+		return libjson.ReadFromFile(filename, spec)
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	if value, err = convertYamlValue(value); err != nil {
+		return err
+	}
+	if data, err = json.Marshal(value); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, spec)
+}
+
+// readVmSpecState returns the IP address recorded for the VM created from
+// the specification, or nil if none has been recorded.
+func readVmSpecState(filename string) (net.IP, error) {
+	var state vmSpecState
+	err := libjson.ReadFromFile(getVmSpecStateFilename(filename), &state)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return state.IpAddress, nil
+}
+
+func writeVmSpecState(filename string, ipAddr net.IP) error {
+	return libjson.WriteToFile(getVmSpecStateFilename(filename),
+		fsutil.PublicFilePerms, "    ", vmSpecState{IpAddress: ipAddr})
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+	"github.com/Symantec/Dominator/lib/tags"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func TestPlanVmChanges(t *testing.T) {
+	logger := testlogger.New(t)
+	tests := []struct {
+		name     string
+		modify   func(spec *vmSpec, vmInfo *proto.VmInfo)
+		expected []string // Prefixes of descriptions, "!" if needs stop.
+		problem  string
+	}{
+		{"unchanged", func(spec *vmSpec, vmInfo *proto.VmInfo) {}, nil, ""},
+		{"same image", func(spec *vmSpec, vmInfo *proto.VmInfo) {
+			spec.ImageName = vmInfo.ImageName
+		}, nil, ""},
+		{"other stream", func(spec *vmSpec, vmInfo *proto.VmInfo) {
+			spec.ImageName = "base/ubuntu-minimal"
+		}, []string{"!replace image"}, ""},
+		{"size", func(spec *vmSpec, vmInfo *proto.VmInfo) {
+			spec.MemoryInMiB = 2048
+		}, []string{"!change size"}, ""},
+		{"grow and add volumes", func(spec *vmSpec, vmInfo *proto.VmInfo) {
+			spec.SecondaryVolumeSizes = []string{"3G", "512M"}
+		}, []string{"grow volume 1", "!add volume"}, ""},
+		{"owners and tags", func(spec *vmSpec, vmInfo *proto.VmInfo) {
+			spec.OwnerUsers = nil
+			spec.Tags = tags.Tags{"Name": "db"}
+		}, []string{"change owner users", "change tags"}, ""},
+		{"destroy protection", func(spec *vmSpec, vmInfo *proto.VmInfo) {
+			spec.DestroyProtection = true
+		}, []string{"change destroy protection"}, ""},
+		{"hostname", func(spec *vmSpec, vmInfo *proto.VmInfo) {
+			spec.Hostname = "other"
+		}, nil, "Hostname"},
+		{"shrink volume", func(spec *vmSpec, vmInfo *proto.VmInfo) {
+			spec.SecondaryVolumeSizes = []string{"1G"}
+		}, nil, "cannot shrink"},
+		{"extra volume", func(spec *vmSpec, vmInfo *proto.VmInfo) {
+			spec.SecondaryVolumeSizes = nil
+		}, nil, "delete-vm-volume"},
+	}
+	for _, test := range tests {
+		spec := vmSpec{
+			ImageName:            "base/ubuntu",
+			MemoryInMiB:          1024,
+			MilliCPUs:            500,
+			OwnerUsers:           []string{"bob"},
+			SecondaryVolumeSizes: []string{"2G"},
+			Tags:                 tags.Tags{"Name": "web"},
+		}
+		vmInfo := proto.VmInfo{
+			ImageName:   "base/ubuntu/2019-01-02:03:04:05",
+			MemoryInMiB: 1024,
+			MilliCPUs:   500,
+			OwnerUsers:  []string{"alice", "bob"},
+			State:       proto.StateRunning,
+			Tags:        tags.Tags{"Name": "web"},
+			Volumes:     []proto.Volume{{Size: 4 << 30}, {Size: 2 << 30}},
+		}
+		test.modify(&spec, &vmInfo)
+		changes, err := planVmChanges(spec, vmInfo, logger)
+		if test.problem != "" {
+			if err == nil || !strings.Contains(err.Error(), test.problem) {
+				t.Errorf("%s: error: %v, expected: %s",
+					test.name, err, test.problem)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		var descriptions []string
+		for index, change := range changes {
+			description := change.description
+			if change.needsStop {
+				description = "!" + description
+			}
+			if index < len(test.expected) &&
+				strings.HasPrefix(description, test.expected[index]) {
+				description = test.expected[index]
+			}
+			descriptions = append(descriptions, description)
+		}
+		if !reflect.DeepEqual(descriptions, test.expected) {
+			t.Errorf("%s: changes: %q, expected: %q",
+				test.name, descriptions, test.expected)
+		}
+	}
+}
+
+func TestYamlVmSpec(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "vm-control.test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirname)
+	filename := filepath.Join(dirname, "web.yaml")
+	err = ioutil.WriteFile(filename, []byte(`# Web server.
+ImageName: base/ubuntu
+MemoryInMiB: 2048
+Limits:
+  DiskOpsPerSecond: 100
+SecondaryVolumeSizes:
+  - 2G
+Tags:
+  Name: web
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec, err := loadVmSpec(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := vmSpec{
+		ImageName:            "base/ubuntu",
+		Limits:               &proto.VmLimits{DiskOpsPerSecond: 100},
+		MemoryInMiB:          2048,
+		SecondaryVolumeSizes: []string{"2G"},
+		Tags:                 tags.Tags{"Name": "web"},
+	}
+	if !reflect.DeepEqual(spec, expected) {
+		t.Fatalf("spec: %+v, expected: %+v", spec, expected)
+	}
+}
+
+func TestVmSpecState(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "vm-control.test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirname)
+	filename := filepath.Join(dirname, "web.yaml")
+	if ipAddr, err := readVmSpecState(filename); err != nil {
+		t.Fatal(err)
+	} else if ipAddr != nil {
+		t.Errorf("IpAddress: %s without state file", ipAddr)
+	}
+	if err := checkVmSpecStateWritable(filename); err != nil {
+		t.Fatal(err)
+	}
+	_, err = os.Stat(getVmSpecStateFilename(filename))
+	if !os.IsNotExist(err) {
+		t.Errorf("state file left after check: %v", err)
+	}
+	expected := net.ParseIP("10.0.0.2")
+	if err := writeVmSpecState(filename, expected); err != nil {
+		t.Fatal(err)
+	}
+	if ipAddr, err := readVmSpecState(filename); err != nil {
+		t.Fatal(err)
+	} else if !ipAddr.Equal(expected) {
+		t.Errorf("IpAddress: %s, expected: %s", ipAddr, expected)
+	}
+	if err := checkVmSpecStateWritable(filename); err == nil {
+		t.Error("no error for existing state file")
+	}
+	filename = filepath.Join(dirname, "missing", "web.yaml")
+	if err := checkVmSpecStateWritable(filename); err == nil {
+		t.Error("no error for missing directory")
+	}
+}
@@ -6,16 +6,19 @@ import (
 	"time"
 
 	"github.com/Symantec/Dominator/dom/images"
+	"github.com/Symantec/Dominator/dom/maintenance"
 	"github.com/Symantec/Dominator/lib/cpusharer"
 	filegenclient "github.com/Symantec/Dominator/lib/filegen/client"
 	"github.com/Symantec/Dominator/lib/filesystem"
 	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/image/trust"
 	"github.com/Symantec/Dominator/lib/log"
 	"github.com/Symantec/Dominator/lib/mdb"
 	"github.com/Symantec/Dominator/lib/net"
 	"github.com/Symantec/Dominator/lib/objectcache"
 	"github.com/Symantec/Dominator/lib/objectserver"
 	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/dominator"
 	filegenproto "github.com/Symantec/Dominator/proto/filegenerator"
 	subproto "github.com/Symantec/Dominator/proto/sub"
 	"github.com/Symantec/tricorder/go/tricorder"
@@ -57,6 +60,8 @@ const (
 	statusMissingComputedFile
 	statusUpdatesDisabled
 	statusUnsafeUpdate
+	statusWaitingForMaintenanceWindow
+	statusWaitingAfterRollback
 	statusUpdating
 	statusUpdateDenied
 	statusFailedToUpdate
@@ -112,6 +117,15 @@ type Sub struct {
 	lastUpdateTime               time.Time
 	lastSyncTime                 time.Time
 	lastSuccessfulImageName      string
+	lastUpdateHadTriggerFailures bool
+	lastUpdateRolledBack         bool
+	lastUpdateTriggerResults     []subproto.TriggerResult
+	maintenancePolicy            *maintenance.Policy // Policy last checked.
+	maintenanceReason            string
+	maintenanceRetryTime         time.Time
+	rolledBackImageName          string
+	numRollbacks                 uint
+	rollbackRetryTime            time.Time
 }
 
 func (sub *Sub) String() string {
@@ -140,11 +154,22 @@ type Herd struct {
 	dialer                net.Dialer
 	currentScanStartTime  time.Time
 	previousScanDuration  time.Duration
+	stateDir              string
+	rolloutMutex          sync.Mutex   // Protect rollout.
+	rollout               *rolloutType // Protected by rolloutMutex.
+	maintenanceMutex      sync.RWMutex // Protect maintenancePolicy.
+	maintenancePolicy     *maintenance.Policy
 }
 
 func NewHerd(imageServerAddress string, objectServer objectserver.ObjectServer,
+	stateDir string, imageTrustPolicy *trust.Policy,
 	metricsDir *tricorder.DirectorySpec, logger log.DebugLogger) *Herd {
-	return newHerd(imageServerAddress, objectServer, metricsDir, logger)
+	return newHerd(imageServerAddress, objectServer, stateDir,
+		imageTrustPolicy, metricsDir, logger)
+}
+
+func (herd *Herd) AbortRollout() error {
+	return herd.abortRollout()
 }
 
 func (herd *Herd) AddHtmlWriter(htmlWriter HtmlWriter) {
@@ -171,6 +196,10 @@ func (herd *Herd) GetDefaultImage() string {
 	return herd.defaultImageName
 }
 
+func (herd *Herd) GetRolloutStatus() *dominator.RolloutStatus {
+	return herd.getRolloutStatus()
+}
+
 func (herd *Herd) GetSubsConfiguration() subproto.Configuration {
 	return herd.getSubsConfiguration()
 }
@@ -183,6 +212,15 @@ func (herd *Herd) MdbUpdate(mdb *mdb.Mdb) {
 	herd.mdbUpdate(mdb)
 }
 
+func (herd *Herd) PauseRollout(resume bool) error {
+	return herd.pauseRollout(resume)
+}
+
+func (herd *Herd) PlanUpdate(request dominator.PlanUpdateRequest) (
+	dominator.PlanUpdateResponse, error) {
+	return herd.planUpdate(request)
+}
+
 func (herd *Herd) PollNextSub() bool {
 	return herd.pollNextSub()
 }
@@ -195,6 +233,15 @@ func (herd *Herd) SetDefaultImage(imageName string) error {
 	return herd.setDefaultImage(imageName)
 }
 
+func (herd *Herd) SetMaintenancePolicy(policy *maintenance.Policy) {
+	herd.setMaintenancePolicy(policy)
+}
+
+func (herd *Herd) StartRollout(spec dominator.RolloutSpec,
+	username string) error {
+	return herd.startRollout(spec, username)
+}
+
 func (herd *Herd) StartServer(portNum uint, daemon bool) error {
 	return herd.startServer(portNum, daemon)
 }
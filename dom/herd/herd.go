@@ -12,6 +12,7 @@ import (
 	"github.com/Symantec/Dominator/lib/constants"
 	"github.com/Symantec/Dominator/lib/cpusharer"
 	filegenclient "github.com/Symantec/Dominator/lib/filegen/client"
+	"github.com/Symantec/Dominator/lib/image/trust"
 	"github.com/Symantec/Dominator/lib/log"
 	libnet "github.com/Symantec/Dominator/lib/net"
 	"github.com/Symantec/Dominator/lib/net/reverseconnection"
@@ -31,10 +32,13 @@ var (
 )
 
 func newHerd(imageServerAddress string, objectServer objectserver.ObjectServer,
+	stateDir string, imageTrustPolicy *trust.Policy,
 	metricsDir *tricorder.DirectorySpec, logger log.DebugLogger) *Herd {
 	var herd Herd
-	herd.imageManager = images.New(imageServerAddress, logger)
+	herd.imageManager = images.New(imageServerAddress, imageTrustPolicy,
+		logger)
 	herd.objectServer = objectServer
+	herd.stateDir = stateDir
 	herd.computedFilesManager = filegenclient.New(objectServer, logger)
 	herd.logger = logger
 	if *disableUpdatesAtStartup {
@@ -54,6 +58,9 @@ func newHerd(imageServerAddress string, objectServer objectserver.ObjectServer,
 		herd.cpuSharer)
 	herd.currentScanStartTime = time.Now()
 	herd.setupMetrics(metricsDir)
+	if err := herd.loadRollout(); err != nil {
+		logger.Printf("Error loading rollout: %s\n", err)
+	}
 	return &herd
 }
 
@@ -103,6 +110,7 @@ func (herd *Herd) pollNextSub() bool {
 	if herd.nextSubToPoll >= uint(len(herd.subsByIndex)) {
 		herd.nextSubToPoll = 0
 		herd.previousScanDuration = time.Since(herd.currentScanStartTime)
+		herd.checkRollout()
 		return true
 	}
 	if herd.nextSubToPoll == 0 {
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/Symantec/Dominator/lib/format"
+	proto "github.com/Symantec/Dominator/proto/dominator"
 )
 
 var timeFormat string = "02 Jan 2006 15:04:05.99 MST"
@@ -43,6 +44,7 @@ func (herd *Herd) writeHtml(writer io.Writer) {
 			"Default image: <a href=\"http://%s/showImage?%s\">%s</a><br>\n",
 			herd.imageManager, herd.defaultImageName, herd.defaultImageName)
 	}
+	herd.writeRolloutHtml(writer)
 	fmt.Fprintf(writer,
 		"Number of <a href=\"listSubs\">subs</a>: <a href=\"showAllSubs\">%d</a><br>\n",
 		numSubs)
@@ -103,6 +105,30 @@ func (herd *Herd) writeDisableStatus(writer io.Writer) {
 	}
 }
 
+func (herd *Herd) writeRolloutHtml(writer io.Writer) {
+	status := herd.getRolloutStatus()
+	if status == nil {
+		return
+	}
+	fmt.Fprintf(writer,
+		"Rollout of image: <a href=\"http://%s/showImage?%s\">%s</a> replacing %s: ",
+		herd.imageManager, status.Spec.ImageName, status.Spec.ImageName,
+		status.Spec.PreviousImageName)
+	switch status.State {
+	case proto.RolloutStateHalted, proto.RolloutStateRolledBack:
+		fmt.Fprintf(writer, "<font color=\"red\">%s</font>", status.State)
+	default:
+		fmt.Fprint(writer, status.State)
+	}
+	if status.Message != "" {
+		fmt.Fprintf(writer, " (%s)", status.Message)
+	}
+	fmt.Fprintf(writer,
+		", wave %d of %d, <a href=\"showRolloutSubs\">%d</a> of %d subs admitted, %d synced, %d failed<br>\n",
+		status.CurrentWave+1, len(status.Spec.Waves), status.NumAdmitted,
+		status.NumEligible, status.NumSynced, status.NumFailed)
+}
+
 func (herd *Herd) writeReachableSubsLink(writer io.Writer,
 	duration time.Duration, durationString string, query string,
 	moreToCome bool) {
@@ -148,6 +174,10 @@ func selectDeviantSub(sub *Sub) bool {
 		return true
 	case statusUpdatesDisabled:
 		return true
+	case statusWaitingForMaintenanceWindow:
+		return true
+	case statusWaitingAfterRollback:
+		return true
 	case statusUpdating:
 		return true
 	case statusUpdateDenied:
@@ -24,6 +24,8 @@ func (herd *Herd) startServer(portNum uint, daemon bool) error {
 		html.BenchmarkedHandler(herd.showCompliantSubsHandler))
 	html.HandleFunc("/showDeviantSubs",
 		html.BenchmarkedHandler(herd.showDeviantSubsHandler))
+	html.HandleFunc("/showRolloutSubs",
+		html.BenchmarkedHandler(herd.showRolloutSubsHandler))
 	html.HandleFunc("/showReachableSubs",
 		html.BenchmarkedHandler(herd.showReachableSubsHandler))
 	html.HandleFunc("/showSub", html.BenchmarkedHandler(herd.showSubHandler))
@@ -0,0 +1,103 @@
+package herd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Symantec/Dominator/dom/maintenance"
+	"github.com/Symantec/Dominator/lib/format"
+	subproto "github.com/Symantec/Dominator/proto/sub"
+)
+
+// noWindowRetryInterval is how long to wait before checking again if there is
+// no upcoming maintenance window.
+const noWindowRetryInterval = time.Hour
+
+func (herd *Herd) getMaintenancePolicy() *maintenance.Policy {
+	herd.maintenanceMutex.RLock()
+	defer herd.maintenanceMutex.RUnlock()
+	return herd.maintenancePolicy
+}
+
+func (herd *Herd) setMaintenancePolicy(policy *maintenance.Policy) {
+	herd.maintenanceMutex.Lock()
+	defer herd.maintenanceMutex.Unlock()
+	herd.maintenancePolicy = policy
+}
+
+// checkMaintenance returns true if the update may be sent now. If not, the
+// time to check again and the reason are recorded. The sub file-system must be
+// present.
+func (sub *Sub) checkMaintenance(request subproto.UpdateRequest) bool {
+	policy := sub.herd.getMaintenancePolicy()
+	sub.maintenancePolicy = policy
+	sub.maintenanceReason = ""
+	if policy == nil {
+		return true
+	}
+	impact := maintenance.ImpactNone
+	trig := matchUpdateTriggers(request, sub.fileSystem, request.Triggers)
+	for _, trigger := range trig.GetMatchedTriggers() {
+		if trigger.DoReboot || trigger.HighImpact {
+			impact = maintenance.ImpactHighImpact
+			break
+		}
+		impact = maintenance.ImpactTriggers
+	}
+	timeNow := time.Now()
+	decision := policy.Check(sub.mdb, impact, timeNow)
+	if decision.Allowed {
+		return true
+	}
+	sub.maintenanceReason = decision.Reason
+	sub.maintenanceRetryTime = decision.RetryTime
+	if sub.maintenanceRetryTime.IsZero() {
+		sub.maintenanceRetryTime = timeNow.Add(noWindowRetryInterval)
+	}
+	return false
+}
+
+func showOccurrence(writer io.Writer, occurrence *maintenance.Occurrence,
+	timeNow time.Time) {
+	if occurrence == nil {
+		fmt.Fprintln(writer, "    <td>none</td>")
+		return
+	}
+	if occurrence.StartTime.After(timeNow) {
+		fmt.Fprintf(writer, "    <td>%s: starts in %s (%s), lasts %s</td>\n",
+			occurrence.Name,
+			format.Duration(occurrence.StartTime.Sub(timeNow)),
+			occurrence.StartTime.Format(format.TimeFormatSeconds),
+			format.Duration(occurrence.EndTime.Sub(occurrence.StartTime)))
+	} else {
+		fmt.Fprintf(writer, "    <td>%s: active, ends in %s (%s)</td>\n",
+			occurrence.Name, format.Duration(occurrence.EndTime.Sub(timeNow)),
+			occurrence.EndTime.Format(format.TimeFormatSeconds))
+	}
+}
+
+// maintenanceWaitOver returns true if the sub was waiting for a maintenance
+// window and should check again.
+func (sub *Sub) maintenanceWaitOver() bool {
+	if sub.herd.getMaintenancePolicy() != sub.maintenancePolicy {
+		return true
+	}
+	return !time.Now().Before(sub.maintenanceRetryTime)
+}
+
+// showMaintenance writes the maintenance rows for the sub status page.
+func (sub *Sub) showMaintenance(writer io.Writer, timeNow time.Time) {
+	policy := sub.herd.getMaintenancePolicy()
+	if policy == nil {
+		return
+	}
+	newRow(writer, "Maintenance window", false)
+	showOccurrence(writer, policy.NextWindow(sub.mdb, timeNow), timeNow)
+	newRow(writer, "Update blackout", false)
+	showOccurrence(writer, policy.ActiveBlackout(sub.mdb, timeNow), timeNow)
+	if sub.publishedStatus == statusWaitingForMaintenanceWindow {
+		newRow(writer, "Update deferred", false)
+		fmt.Fprintf(writer, "    <td>%s</td>\n", sub.maintenanceReason)
+	}
+}
@@ -15,6 +15,9 @@ func (herd *Herd) mdbUpdate(mdb *mdb.Mdb) {
 	for _, clientResource := range clientResourcesToDelete {
 		clientResource.ScheduleClose()
 	}
+	for _, imageName := range herd.getRolloutImageNames() {
+		wantedImages[imageName] = struct{}{}
+	}
 	// Clean up unreferenced images.
 	herd.imageManager.SetImageInterestList(wantedImages, true)
 	pluralNew := "s"
@@ -0,0 +1,319 @@
+package herd
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Symantec/Dominator/dom/lib"
+	filegenclient "github.com/Symantec/Dominator/lib/filegen/client"
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/lib/triggers"
+	proto "github.com/Symantec/Dominator/proto/dominator"
+	subproto "github.com/Symantec/Dominator/proto/sub"
+	"github.com/Symantec/Dominator/sub/client"
+)
+
+const (
+	planBusyTimeout     = time.Minute
+	planGenerateTimeout = 30 * time.Second
+)
+
+// copyTriggers returns a private copy of the triggers, since matching state is
+// kept in the triggers and they are shared between subs.
+func copyTriggers(oldTriggers *triggers.Triggers) *triggers.Triggers {
+	newTriggers := triggers.New()
+	if oldTriggers == nil {
+		return newTriggers
+	}
+	for _, trigger := range oldTriggers.Triggers {
+		newTriggers.Triggers = append(newTriggers.Triggers, &triggers.Trigger{
+			MatchLines:  trigger.MatchLines,
+			Service:     trigger.Service,
+			DoReboot:    trigger.DoReboot,
+			HighImpact:  trigger.HighImpact,
+			After:       trigger.After,
+			HealthCheck: trigger.HealthCheck,
+		})
+	}
+	return newTriggers
+}
+
+// checkNonMtimeChange returns true if the new inode differs from the existing
+// inode other than in the modification time. This mirrors the check the sub
+// makes before matching triggers for changed inodes.
+func checkNonMtimeChange(oldInode, newInode filesystem.GenericInode) bool {
+	switch newInode := newInode.(type) {
+	case *filesystem.RegularInode:
+		if oldInode, ok := oldInode.(*filesystem.RegularInode); ok {
+			inode := *oldInode
+			inode.Hash = newInode.Hash
+			inode.MtimeNanoSeconds = newInode.MtimeNanoSeconds
+			inode.MtimeSeconds = newInode.MtimeSeconds
+			return inode != *newInode
+		}
+	case *filesystem.SpecialInode:
+		if oldInode, ok := oldInode.(*filesystem.SpecialInode); ok {
+			inode := *oldInode
+			inode.MtimeNanoSeconds = newInode.MtimeNanoSeconds
+			inode.MtimeSeconds = newInode.MtimeSeconds
+			return inode != *newInode
+		}
+	}
+	return true
+}
+
+// matchUpdateTriggers returns a private copy of the triggers which has been
+// matched against the changes in the update request.
+func matchUpdateTriggers(request subproto.UpdateRequest,
+	fs *filesystem.FileSystem,
+	imageTriggers *triggers.Triggers) *triggers.Triggers {
+	trig := copyTriggers(imageTriggers)
+	for _, inode := range request.DirectoriesToMake {
+		trig.Match(inode.Name)
+	}
+	for _, inode := range request.InodesToMake {
+		trig.Match(inode.Name)
+	}
+	for _, hardlink := range request.HardlinksToMake {
+		trig.Match(hardlink.NewLink)
+	}
+	for _, pathname := range request.PathsToDelete {
+		trig.Match(pathname)
+	}
+	filenameToInodeTable := fs.FilenameToInodeTable()
+	for _, inode := range request.InodesToChange {
+		if inum, ok := filenameToInodeTable[inode.Name]; !ok {
+			trig.Match(inode.Name)
+		} else if checkNonMtimeChange(fs.InodeTable[inum],
+			inode.GenericInode) {
+			trig.Match(inode.Name)
+		}
+	}
+	return trig
+}
+
+func (herd *Herd) planUpdate(request proto.PlanUpdateRequest) (
+	proto.PlanUpdateResponse, error) {
+	var response proto.PlanUpdateResponse
+	var img *image.Image
+	if request.ImageName != "" {
+		var err error
+		img, err = herd.imageManager.Get(request.ImageName, true)
+		if err != nil {
+			return response, err
+		}
+		if img == nil {
+			return response, errors.New("unknown image: " + request.ImageName)
+		}
+	}
+	var selectFunc func(*Sub) bool
+	if len(request.Hostnames) > 0 {
+		hostnames := make(map[string]struct{}, len(request.Hostnames))
+		for _, hostname := range request.Hostnames {
+			hostnames[hostname] = struct{}{}
+		}
+		selectFunc = func(sub *Sub) bool {
+			_, ok := hostnames[sub.mdb.Hostname]
+			return ok
+		}
+	}
+	subs := herd.getSelectedSubs(selectFunc)
+	if request.SubsRequiringImage != "" {
+		selectedSubs := make([]*Sub, 0, len(subs))
+		for _, sub := range subs {
+			if sub.getRequiredImageName() == request.SubsRequiringImage {
+				selectedSubs = append(selectedSubs, sub)
+			}
+		}
+		subs = selectedSubs
+	}
+	plans := make([]proto.SubUpdatePlan, len(subs))
+	var waitGroup sync.WaitGroup
+	for index, sub := range subs {
+		waitGroup.Add(1)
+		herd.cpuSharer.GoWhenAvailable(func(index int, sub *Sub) func() {
+			return func() {
+				defer waitGroup.Done()
+				plans[index] = sub.planUpdate(request.ImageName, img)
+			}
+		}(index, sub))
+	}
+	waitGroup.Wait()
+	report := &response.Report
+	report.NumSubs = uint(len(plans))
+	report.FailedSubs = make(map[string]string)
+	report.PathCounts = make(map[string]uint)
+	report.ServiceCounts = make(map[string]uint)
+	for _, plan := range plans {
+		if plan.Error != "" {
+			report.FailedSubs[plan.Hostname] = plan.Error
+			continue
+		}
+		pathnames := make(map[string]struct{})
+		for _, list := range [][]string{plan.DirectoriesToMake,
+			plan.HardlinksToMake, plan.InodesToChange, plan.InodesToMake,
+			plan.PathsToDelete} {
+			for _, pathname := range list {
+				pathnames[pathname] = struct{}{}
+			}
+		}
+		if len(pathnames) < 1 && len(plan.ServicesToRestart) < 1 {
+			continue
+		}
+		report.NumSubsToUpdate++
+		for pathname := range pathnames {
+			report.PathCounts[pathname]++
+		}
+		for _, service := range plan.ServicesToRestart {
+			report.ServiceCounts[service]++
+		}
+		if plan.Reboot {
+			report.SubsToReboot = append(report.SubsToReboot, plan.Hostname)
+		}
+	}
+	sort.Strings(report.SubsToReboot)
+	if request.IncludeSubPlans {
+		response.SubPlans = plans
+	}
+	return response, nil
+}
+
+// getRequiredImageName returns the name of the image the sub should be running,
+// taking the default image and any rollout into account.
+func (sub *Sub) getRequiredImageName() string {
+	imageName := sub.mdb.RequiredImage
+	if imageName == "" {
+		imageName = sub.herd.defaultImageName
+	}
+	return sub.herd.getRolloutImageName(sub.mdb.Hostname, imageName)
+}
+
+// planUpdate computes the update that would be sent to the sub, without sending
+// it. If img is nil, the required image for the sub is used. The CPU must be
+// held by the caller.
+func (sub *Sub) planUpdate(imageName string,
+	img *image.Image) proto.SubUpdatePlan {
+	plan := proto.SubUpdatePlan{Hostname: sub.mdb.Hostname, ImageName: imageName}
+	if err := sub.planUpdateWithError(img, &plan); err != nil {
+		plan.Error = err.Error()
+	}
+	return plan
+}
+
+// computeInodes returns the computed inodes for the sub for the specified
+// image. The inodes maintained for the required image of the sub are used if
+// that is the image, else the file generators are queried directly. The sub
+// must be busy.
+func (sub *Sub) computeInodes(imageName string, img *image.Image) (
+	map[string]*filesystem.RegularInode, error) {
+	if imageName == sub.requiredImageName && sub.computedInodes != nil {
+		return sub.computedInodes, nil
+	}
+	computedFiles := sub.getComputedFiles(img)
+	if len(computedFiles) < 1 {
+		return nil, nil
+	}
+	sub.herd.cpuSharer.ReleaseCpu()
+	fileInfos, err := filegenclient.Generate(
+		filegenclient.Machine{sub.mdb, computedFiles}, planGenerateTimeout)
+	sub.herd.cpuSharer.GrabCpu()
+	if err != nil {
+		return nil, err
+	}
+	computedInodes := make(map[string]*filesystem.RegularInode,
+		len(fileInfos))
+	addComputedInodes(computedInodes, img.FileSystem, fileInfos)
+	return computedInodes, nil
+}
+
+func (sub *Sub) planUpdateWithError(img *image.Image,
+	plan *proto.SubUpdatePlan) error {
+	// Wait for the sub goroutine to finish so that sub state may be used.
+	stopTime := time.Now().Add(planBusyTimeout)
+	for !sub.tryMakeBusy() {
+		if time.Now().After(stopTime) {
+			return errors.New("timed out waiting for busy sub")
+		}
+		sub.herd.cpuSharer.Sleep(100 * time.Millisecond)
+	}
+	defer sub.makeUnbusy()
+	if img == nil {
+		plan.ImageName = sub.getRequiredImageName()
+		img = sub.herd.imageManager.GetNoError(plan.ImageName)
+		if img == nil {
+			if plan.ImageName == "" {
+				return errors.New("image undefined")
+			}
+			return errors.New("image not ready")
+		}
+	}
+	sub.deletingFlagMutex.Lock()
+	if sub.deleting {
+		sub.deletingFlagMutex.Unlock()
+		return errors.New("sub deleted")
+	}
+	if sub.clientResource == nil {
+		sub.clientResource = srpc.NewClientResource("tcp", sub.address())
+	}
+	sub.deletingFlagMutex.Unlock()
+	srpcClient, err := sub.clientResource.GetHTTPWithDialer(nil,
+		sub.herd.dialer)
+	if err != nil {
+		return err
+	}
+	defer srpcClient.Put()
+	sub.herd.cpuSharer.GrabSemaphore(sub.herd.pollSemaphore)
+	defer func() { <-sub.herd.pollSemaphore }()
+	var pollRequest subproto.PollRequest
+	var pollReply subproto.PollResponse
+	if err := client.CallPoll(srpcClient, pollRequest, &pollReply); err != nil {
+		srpcClient.Close()
+		return err
+	}
+	fs := pollReply.FileSystem
+	if fs == nil {
+		return errors.New("sub not ready")
+	}
+	if err := fs.RebuildInodePointers(); err != nil {
+		return err
+	}
+	fs.BuildEntryMap()
+	computedInodes, err := sub.computeInodes(plan.ImageName, img)
+	if err != nil {
+		return err
+	}
+	subObj := lib.Sub{
+		Hostname:       sub.mdb.Hostname,
+		FileSystem:     fs,
+		ComputedInodes: computedInodes,
+		ObjectCache:    pollReply.ObjectCache,
+	}
+	var request subproto.UpdateRequest
+	lib.BuildUpdateRequest(subObj, img, &request, false, true, sub.herd.logger)
+	for _, inode := range request.DirectoriesToMake {
+		plan.DirectoriesToMake = append(plan.DirectoriesToMake, inode.Name)
+	}
+	for _, inode := range request.InodesToMake {
+		plan.InodesToMake = append(plan.InodesToMake, inode.Name)
+	}
+	for _, hardlink := range request.HardlinksToMake {
+		plan.HardlinksToMake = append(plan.HardlinksToMake, hardlink.NewLink)
+	}
+	plan.PathsToDelete = append(plan.PathsToDelete, request.PathsToDelete...)
+	for _, inode := range request.InodesToChange {
+		plan.InodesToChange = append(plan.InodesToChange, inode.Name)
+	}
+	trig := matchUpdateTriggers(request, fs, img.Triggers)
+	for _, trigger := range trig.GetMatchedTriggers() {
+		plan.ServicesToRestart = append(plan.ServicesToRestart,
+			trigger.Service)
+		if trigger.DoReboot {
+			plan.Reboot = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package herd
+
+import (
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/cpusharer"
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/mdb"
+	"github.com/Symantec/Dominator/lib/triggers"
+	filegenproto "github.com/Symantec/Dominator/proto/filegenerator"
+	subproto "github.com/Symantec/Dominator/proto/sub"
+)
+
+func makeComputedFileSystem(source string) *filesystem.FileSystem {
+	fs := &filesystem.FileSystem{
+		InodeTable: filesystem.InodeTable{
+			1: &filesystem.ComputedRegularInode{Mode: 0644, Source: source},
+			2: &filesystem.RegularInode{Mode: 0644, Size: 1},
+		},
+		DirectoryInode: filesystem.DirectoryInode{
+			EntryList: []*filesystem.DirectoryEntry{
+				{Name: "computed", InodeNumber: 1},
+				{Name: "plain", InodeNumber: 2},
+			},
+		},
+	}
+	if err := fs.RebuildInodePointers(); err != nil {
+		panic(err)
+	}
+	return fs
+}
+
+func TestCheckNonMtimeChange(t *testing.T) {
+	oldInode := &filesystem.RegularInode{Mode: 0644, Size: 1, MtimeSeconds: 1}
+	newInode := *oldInode
+	newInode.MtimeSeconds = 2
+	newInode.Hash = hash.Hash{1}
+	if checkNonMtimeChange(oldInode, &newInode) {
+		t.Error("mtime and hash change reported as a non-mtime change")
+	}
+	newInode.Mode = 0600
+	if !checkNonMtimeChange(oldInode, &newInode) {
+		t.Error("mode change not detected")
+	}
+	if !checkNonMtimeChange(&filesystem.SymlinkInode{}, &newInode) {
+		t.Error("type change not detected")
+	}
+}
+
+func TestMatchUpdateTriggers(t *testing.T) {
+	imageTriggers := triggers.New()
+	imageTriggers.Triggers = []*triggers.Trigger{
+		{MatchLines: []string{"/etc/ssh/.*"}, Service: "sshd"},
+		{MatchLines: []string{"/boot/.*"}, Service: "kernel", DoReboot: true},
+		{MatchLines: []string{"/plain"}, Service: "plain"},
+	}
+	fs := makeComputedFileSystem("")
+	request := subproto.UpdateRequest{
+		InodesToChange: []subproto.Inode{
+			{ // Only the mtime has changed: must not match.
+				Name: "/plain",
+				GenericInode: &filesystem.RegularInode{
+					Mode:         0644,
+					Size:         1,
+					MtimeSeconds: 10,
+				},
+			},
+		},
+		PathsToDelete: []string{"/etc/ssh/sshd_config"},
+	}
+	matched := matchUpdateTriggers(request, fs, imageTriggers)
+	services := matched.GetMatchedTriggers()
+	if len(services) != 1 || services[0].Service != "sshd" {
+		t.Fatalf("expected sshd only, got: %v", services)
+	}
+	// The image triggers must not be modified.
+	if nMatched, _ := imageTriggers.GetMatchStatistics(); nMatched != 0 {
+		t.Errorf("image triggers were matched: %d", nMatched)
+	}
+}
+
+func TestAddComputedInodes(t *testing.T) {
+	fs := makeComputedFileSystem("filegen:1234")
+	computedInodes := make(map[string]*filesystem.RegularInode)
+	added := addComputedInodes(computedInodes, fs, []filegenproto.FileInfo{
+		{Pathname: "/computed", Hash: hash.Hash{2}, Length: 10},
+		{Pathname: "/plain", Hash: hash.Hash{3}, Length: 10},
+		{Pathname: "/missing", Hash: hash.Hash{4}, Length: 10},
+	})
+	if !added {
+		t.Error("no inodes added")
+	}
+	if len(computedInodes) != 1 {
+		t.Fatalf("expected 1 computed inode, got: %d", len(computedInodes))
+	}
+	inode := computedInodes["/computed"]
+	if inode == nil || inode.Hash != (hash.Hash{2}) || inode.Size != 10 ||
+		inode.Mode != 0644 {
+		t.Errorf("bad computed inode: %v", inode)
+	}
+	if addComputedInodes(computedInodes, fs, []filegenproto.FileInfo{
+		{Pathname: "/computed"}}) {
+		t.Error("file without an object was added")
+	}
+}
+
+func TestComputeInodes(t *testing.T) {
+	herd := &Herd{cpuSharer: cpusharer.NewFifoCpuSharer()}
+	herd.cpuSharer.GrabCpu()
+	defer herd.cpuSharer.ReleaseCpu()
+	requiredInodes := map[string]*filesystem.RegularInode{
+		"/computed": {Hash: hash.Hash{1}},
+	}
+	sub := &Sub{
+		herd:              herd,
+		mdb:               mdb.Machine{Hostname: "sub"},
+		requiredImageName: "required",
+		computedInodes:    requiredInodes,
+	}
+	img := &image.Image{FileSystem: makeComputedFileSystem("localhost:1")}
+	// The inodes for the required image are maintained by the sub.
+	computedInodes, err := sub.computeInodes("required", img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if computedInodes["/computed"] != requiredInodes["/computed"] {
+		t.Error("inodes for required image not used")
+	}
+	// Inodes for other images must be generated, not reused.
+	computedInodes, err = sub.computeInodes("other", img)
+	if err == nil {
+		t.Errorf("no error generating from bad source, got: %v",
+			computedInodes)
+	}
+	// Images without computed files need no generation.
+	img = &image.Image{FileSystem: makeComputedFileSystem("")}
+	delete(img.FileSystem.InodeTable, 1)
+	img.FileSystem.EntryList = img.FileSystem.EntryList[1:]
+	computedInodes, err = sub.computeInodes("other", img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(computedInodes) != 0 {
+		t.Errorf("unexpected computed inodes: %v", computedInodes)
+	}
+}
@@ -0,0 +1,65 @@
+package herd
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/format"
+)
+
+// Updates to an image which the sub rolled back are retried with exponential
+// backoff between these limits.
+const (
+	rollbackMinBackoff = 5 * time.Minute
+	rollbackMaxBackoff = 24 * time.Hour
+)
+
+// recordRollback records whether the last completed update to the required
+// image was rolled back and computes when it may be retried. Updates which
+// succeed clear the record.
+func (sub *Sub) recordRollback(rolledBack bool, timeNow time.Time) {
+	if !rolledBack {
+		sub.rolledBackImageName = ""
+		sub.numRollbacks = 0
+		return
+	}
+	if sub.rolledBackImageName != sub.requiredImageName {
+		sub.rolledBackImageName = sub.requiredImageName
+		sub.numRollbacks = 0
+	}
+	sub.numRollbacks++
+	backoff := rollbackMaxBackoff
+	if sub.numRollbacks < 16 {
+		backoff = rollbackMinBackoff << (sub.numRollbacks - 1)
+		if backoff > rollbackMaxBackoff {
+			backoff = rollbackMaxBackoff
+		}
+	}
+	sub.rollbackRetryTime = timeNow.Add(backoff)
+}
+
+// rollbackBackoffActive returns true if updates to the required image must be
+// deferred because earlier updates to it were rolled back.
+func (sub *Sub) rollbackBackoffActive(timeNow time.Time) bool {
+	if sub.rolledBackImageName == "" ||
+		sub.rolledBackImageName != sub.requiredImageName {
+		return false
+	}
+	return timeNow.Before(sub.rollbackRetryTime)
+}
+
+// showRollback writes the rollback row for the sub status page.
+func (sub *Sub) showRollback(writer io.Writer, timeNow time.Time) {
+	if sub.rolledBackImageName == "" {
+		return
+	}
+	newRow(writer, "Rolled back image", false)
+	fmt.Fprintf(writer, "    <td>%s: %d times",
+		sub.rolledBackImageName, sub.numRollbacks)
+	if timeNow.Before(sub.rollbackRetryTime) {
+		fmt.Fprintf(writer, ", retry in %s",
+			format.Duration(sub.rollbackRetryTime.Sub(timeNow)))
+	}
+	fmt.Fprintln(writer, "</td>")
+}
@@ -0,0 +1,57 @@
+package herd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRollbackBackoff(t *testing.T) {
+	sub := &Sub{requiredImageName: "bad"}
+	timeNow := time.Now()
+	if sub.rollbackBackoffActive(timeNow) {
+		t.Fatal("backoff active without a rollback")
+	}
+	expectedBackoff := rollbackMinBackoff
+	for count := 0; count < 12; count++ {
+		sub.recordRollback(true, timeNow)
+		if backoff := sub.rollbackRetryTime.Sub(timeNow); backoff !=
+			expectedBackoff {
+			t.Fatalf("rollback: %d: backoff: %s, expected: %s",
+				count, backoff, expectedBackoff)
+		}
+		if !sub.rollbackBackoffActive(timeNow) {
+			t.Fatalf("rollback: %d: backoff not active", count)
+		}
+		if sub.rollbackBackoffActive(sub.rollbackRetryTime) {
+			t.Fatalf("rollback: %d: backoff active after retry time", count)
+		}
+		expectedBackoff *= 2
+		if expectedBackoff > rollbackMaxBackoff {
+			expectedBackoff = rollbackMaxBackoff
+		}
+	}
+	// Many rollbacks must not overflow the backoff.
+	for count := 0; count < 100; count++ {
+		sub.recordRollback(true, timeNow)
+	}
+	if backoff := sub.rollbackRetryTime.Sub(timeNow); backoff !=
+		rollbackMaxBackoff {
+		t.Errorf("backoff: %s, expected: %s", backoff, rollbackMaxBackoff)
+	}
+	// A different image must not be deferred.
+	sub.requiredImageName = "good"
+	if sub.rollbackBackoffActive(timeNow) {
+		t.Error("backoff active for a different image")
+	}
+	// The count starts again for a new image.
+	sub.recordRollback(true, timeNow)
+	if sub.numRollbacks != 1 || sub.rolledBackImageName != "good" {
+		t.Errorf("rollback for new image: %s: %d",
+			sub.rolledBackImageName, sub.numRollbacks)
+	}
+	// A successful update clears the backoff.
+	sub.recordRollback(false, timeNow)
+	if sub.rollbackBackoffActive(timeNow) {
+		t.Error("backoff active after successful update")
+	}
+}
@@ -0,0 +1,470 @@
+package herd
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"path"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/json"
+	proto "github.com/Symantec/Dominator/proto/dominator"
+)
+
+const (
+	privateFilePerms = syscall.S_IRUSR | syscall.S_IWUSR
+	rolloutFilename  = "rollout.json"
+)
+
+type rolloutType struct {
+	Status         proto.RolloutStatus
+	AdmittedSubs   map[string]struct{} // Key: hostname.
+	WaveSyncedTime time.Time           `json:",omitempty"`
+}
+
+type rolloutCounts struct {
+	numAdmitted uint
+	numSynced   uint
+	failedSubs  []string
+}
+
+type subRolloutInfo struct {
+	hostname string
+	order    uint32
+	tags     map[string]string
+}
+
+func hashHostname(hostname string) uint32 {
+	hasher := fnv.New32a()
+	hasher.Write([]byte(hostname))
+	return hasher.Sum32()
+}
+
+func checkRolloutSpec(spec *proto.RolloutSpec) error {
+	if spec.ImageName == "" {
+		return errors.New("no image specified")
+	}
+	if spec.PreviousImageName == "" {
+		return errors.New("no previous image specified")
+	}
+	if spec.ImageName == spec.PreviousImageName {
+		return errors.New("image and previous image are the same")
+	}
+	if spec.FailureThreshold < 0 || spec.FailureThreshold > 1 {
+		return errors.New("failure threshold must be between 0 and 1")
+	}
+	if len(spec.Waves) < 1 {
+		spec.Waves = []proto.RolloutWave{{Percent: 100}}
+	}
+	for _, wave := range spec.Waves {
+		if wave.Percent > 100 {
+			return fmt.Errorf("bad wave percentage: %d", wave.Percent)
+		}
+	}
+	return nil
+}
+
+// rolloutApplies returns true if the rollout is overriding the required image
+// for admitted subs.
+func (rollout *rolloutType) rolloutApplies() bool {
+	switch rollout.Status.State {
+	case proto.RolloutStateRolledBack, proto.RolloutStateAborted:
+		return false
+	}
+	return true
+}
+
+// getEligibleSubs returns the subs which require the previous image, ordered by
+// a hash of their hostname so that waves are spread across the herd.
+func (herd *Herd) getEligibleSubs(previousImageName string) []subRolloutInfo {
+	herd.RLock()
+	defer herd.RUnlock()
+	subs := make([]subRolloutInfo, 0)
+	for _, sub := range herd.subsByIndex {
+		imageName := sub.mdb.RequiredImage
+		if imageName == "" {
+			imageName = herd.defaultImageName
+		}
+		if imageName != previousImageName {
+			continue
+		}
+		subs = append(subs, subRolloutInfo{
+			hostname: sub.mdb.Hostname,
+			order:    hashHostname(sub.mdb.Hostname),
+			tags:     sub.mdb.Tags,
+		})
+	}
+	sort.SliceStable(subs, func(left, right int) bool {
+		return subs[left].order < subs[right].order
+	})
+	return subs
+}
+
+func (herd *Herd) loadRollout() error {
+	if herd.stateDir == "" {
+		return nil
+	}
+	var rollout rolloutType
+	err := json.ReadFromFile(path.Join(herd.stateDir, rolloutFilename),
+		&rollout)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if rollout.AdmittedSubs == nil {
+		rollout.AdmittedSubs = make(map[string]struct{})
+	}
+	herd.rollout = &rollout
+	return nil
+}
+
+func (herd *Herd) writeRollout() error {
+	if herd.stateDir == "" {
+		return nil
+	}
+	return json.WriteToFile(path.Join(herd.stateDir, rolloutFilename),
+		privateFilePerms, "    ", herd.rollout)
+}
+
+// admitWave adds subs to the rollout until the target for the current wave is
+// reached. The rolloutMutex must be held if the rollout is shared. The
+// hostnames of newly admitted subs are returned.
+func (rollout *rolloutType) admitWave(eligibleSubs []subRolloutInfo) []string {
+	wave := rollout.Status.Spec.Waves[rollout.Status.CurrentWave]
+	matchingSubs := make([]subRolloutInfo, 0, len(eligibleSubs))
+	for _, sub := range eligibleSubs {
+		if matchTags(sub.tags, wave.RequiredTags) {
+			matchingSubs = append(matchingSubs, sub)
+		}
+	}
+	percent := wave.Percent
+	if percent < 1 {
+		percent = 100
+	}
+	target := (uint(len(matchingSubs))*percent + 99) / 100
+	var numAdmitted uint
+	for _, sub := range matchingSubs {
+		if _, ok := rollout.AdmittedSubs[sub.hostname]; ok {
+			numAdmitted++
+		}
+	}
+	var newSubs []string
+	for _, sub := range matchingSubs {
+		if numAdmitted >= target {
+			break
+		}
+		if _, ok := rollout.AdmittedSubs[sub.hostname]; ok {
+			continue
+		}
+		rollout.AdmittedSubs[sub.hostname] = struct{}{}
+		newSubs = append(newSubs, sub.hostname)
+		numAdmitted++
+	}
+	return newSubs
+}
+
+func matchTags(tags, requiredTags map[string]string) bool {
+	for key, value := range requiredTags {
+		if tags[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func (herd *Herd) startRollout(spec proto.RolloutSpec, username string) error {
+	if err := checkRolloutSpec(&spec); err != nil {
+		return err
+	}
+	img, err := herd.imageManager.Get(spec.ImageName, true)
+	if err != nil {
+		return err
+	}
+	if img == nil {
+		return errors.New("unknown image: " + spec.ImageName)
+	}
+	if oldStatus := herd.getRolloutStatus(); oldStatus != nil {
+		switch oldStatus.State {
+		case proto.RolloutStateRunning, proto.RolloutStatePaused,
+			proto.RolloutStateHalted:
+			return fmt.Errorf("rollout of: %s is %s, abort it first",
+				oldStatus.Spec.ImageName, oldStatus.State)
+		case proto.RolloutStateCompleted:
+			numEligible := len(herd.getEligibleSubs(
+				oldStatus.Spec.PreviousImageName))
+			if numEligible > 0 {
+				return fmt.Errorf(
+					"completed rollout of: %s still overrides: %d subs, update the MDB or abort it",
+					oldStatus.Spec.ImageName, numEligible)
+			}
+		}
+	}
+	eligibleSubs := herd.getEligibleSubs(spec.PreviousImageName)
+	timeNow := time.Now()
+	rollout := &rolloutType{
+		Status: proto.RolloutStatus{
+			Spec:            spec,
+			State:           proto.RolloutStateRunning,
+			StartedBy:       username,
+			StartTime:       timeNow,
+			StateChangeTime: timeNow,
+			NumEligible:     uint(len(eligibleSubs)),
+		},
+		AdmittedSubs: make(map[string]struct{}),
+	}
+	newSubs := rollout.admitWave(eligibleSubs)
+	rollout.Status.NumAdmitted = uint(len(newSubs))
+	herd.rolloutMutex.Lock()
+	oldRollout := herd.rollout
+	if oldRollout != nil && oldRollout.rolloutApplies() &&
+		oldRollout.Status.State != proto.RolloutStateCompleted {
+		herd.rolloutMutex.Unlock()
+		return errors.New("rollout started concurrently")
+	}
+	herd.rollout = rollout
+	if err := herd.writeRollout(); err != nil {
+		herd.rollout = oldRollout
+		herd.rolloutMutex.Unlock()
+		return err
+	}
+	herd.rolloutMutex.Unlock()
+	herd.logger.Printf("Started rollout of: %s, admitted: %d subs\n",
+		spec.ImageName, len(newSubs))
+	herd.wakeSubs(newSubs)
+	return nil
+}
+
+func (herd *Herd) pauseRollout(resume bool) error {
+	herd.rolloutMutex.Lock()
+	defer herd.rolloutMutex.Unlock()
+	rollout := herd.rollout
+	if rollout == nil {
+		return errors.New("no rollout")
+	}
+	if resume {
+		if rollout.Status.State != proto.RolloutStatePaused {
+			return errors.New("rollout is not paused")
+		}
+		rollout.setState(proto.RolloutStateRunning, "")
+	} else {
+		if rollout.Status.State != proto.RolloutStateRunning {
+			return errors.New("rollout is not running")
+		}
+		rollout.setState(proto.RolloutStatePaused, "")
+	}
+	return herd.writeRollout()
+}
+
+func (herd *Herd) abortRollout() error {
+	herd.rolloutMutex.Lock()
+	rollout := herd.rollout
+	if rollout == nil {
+		herd.rolloutMutex.Unlock()
+		return errors.New("no rollout")
+	}
+	if !rollout.rolloutApplies() {
+		herd.rolloutMutex.Unlock()
+		return fmt.Errorf("rollout already %s", rollout.Status.State)
+	}
+	rollout.setState(proto.RolloutStateAborted, "")
+	err := herd.writeRollout()
+	hostnames := rollout.hostnames()
+	herd.rolloutMutex.Unlock()
+	herd.logger.Printf("Aborted rollout of: %s\n", rollout.Status.Spec.ImageName)
+	herd.wakeSubs(hostnames)
+	return err
+}
+
+func (herd *Herd) getRolloutStatus() *proto.RolloutStatus {
+	herd.rolloutMutex.Lock()
+	defer herd.rolloutMutex.Unlock()
+	if herd.rollout == nil {
+		return nil
+	}
+	status := herd.rollout.Status
+	status.FailedSubs = append([]string(nil), status.FailedSubs...)
+	return &status
+}
+
+// getRolloutImageName returns the name of the image that the sub should be
+// running, given the image that it would otherwise require.
+func (herd *Herd) getRolloutImageName(hostname, imageName string) string {
+	herd.rolloutMutex.Lock()
+	defer herd.rolloutMutex.Unlock()
+	rollout := herd.rollout
+	if rollout == nil || !rollout.rolloutApplies() {
+		return imageName
+	}
+	if imageName != rollout.Status.Spec.PreviousImageName {
+		return imageName
+	}
+	if _, ok := rollout.AdmittedSubs[hostname]; !ok {
+		return imageName
+	}
+	return rollout.Status.Spec.ImageName
+}
+
+func (herd *Herd) getRolloutImageNames() []string {
+	herd.rolloutMutex.Lock()
+	defer herd.rolloutMutex.Unlock()
+	if herd.rollout == nil || !herd.rollout.rolloutApplies() {
+		return nil
+	}
+	return []string{herd.rollout.Status.Spec.ImageName}
+}
+
+func (herd *Herd) selectRolloutSub(sub *Sub) bool {
+	herd.rolloutMutex.Lock()
+	defer herd.rolloutMutex.Unlock()
+	if herd.rollout == nil {
+		return false
+	}
+	_, ok := herd.rollout.AdmittedSubs[sub.mdb.Hostname]
+	return ok
+}
+
+func (rollout *rolloutType) hostnames() []string {
+	hostnames := make([]string, 0, len(rollout.AdmittedSubs))
+	for hostname := range rollout.AdmittedSubs {
+		hostnames = append(hostnames, hostname)
+	}
+	return hostnames
+}
+
+func (rollout *rolloutType) setState(state proto.RolloutState,
+	message string) {
+	rollout.Status.State = state
+	rollout.Status.StateChangeTime = time.Now()
+	rollout.Status.Message = message
+}
+
+// checkRollout updates the rollout statistics and advances, halts or rolls back
+// the rollout as needed. It is called at the end of each scan cycle.
+func (herd *Herd) checkRollout() {
+	herd.rolloutMutex.Lock()
+	rollout := herd.rollout
+	if rollout == nil || !rollout.Status.State.IsActive() {
+		herd.rolloutMutex.Unlock()
+		return
+	}
+	spec := rollout.Status.Spec
+	herd.rolloutMutex.Unlock()
+	eligibleSubs := herd.getEligibleSubs(spec.PreviousImageName)
+	eligibleHostnames := make(map[string]struct{}, len(eligibleSubs))
+	for _, sub := range eligibleSubs {
+		eligibleHostnames[sub.hostname] = struct{}{}
+	}
+	var counts rolloutCounts
+	for _, sub := range herd.getSelectedSubs(herd.selectRolloutSub) {
+		if _, ok := eligibleHostnames[sub.mdb.Hostname]; !ok {
+			continue // MDB has moved on.
+		}
+		counts.numAdmitted++
+		if sub.requiredImageName != spec.ImageName {
+			continue
+		}
+		if sub.lastUpdateHadTriggerFailures {
+			counts.failedSubs = append(counts.failedSubs, sub.mdb.Hostname)
+			continue
+		}
+		switch sub.publishedStatus {
+		case statusSynced:
+			counts.numSynced++
+		case statusFailedToUpdate, statusUnsafeUpdate, statusFailedToFetch,
+			statusMissingComputedFile, statusWaitingAfterRollback:
+			counts.failedSubs = append(counts.failedSubs, sub.mdb.Hostname)
+		}
+	}
+	herd.rolloutMutex.Lock()
+	hostnamesToWake := herd.updateRollout(rollout, eligibleSubs, counts)
+	herd.rolloutMutex.Unlock()
+	herd.wakeSubs(hostnamesToWake)
+}
+
+// updateRollout records the rollout statistics and decides whether to advance,
+// halt or roll back. The rolloutMutex must be held. The hostnames of subs which
+// need to be woken up are returned.
+func (herd *Herd) updateRollout(rollout *rolloutType,
+	eligibleSubs []subRolloutInfo, counts rolloutCounts) []string {
+	if rollout != herd.rollout || !rollout.Status.State.IsActive() {
+		return nil // Changed while unlocked.
+	}
+	spec := rollout.Status.Spec
+	status := &rollout.Status
+	status.NumEligible = uint(len(eligibleSubs))
+	status.NumAdmitted = counts.numAdmitted
+	status.NumSynced = counts.numSynced
+	status.NumFailed = uint(len(counts.failedSubs))
+	status.FailedSubs = counts.failedSubs
+	numFailed := status.NumFailed
+	var hostnamesToWake []string
+	if numFailed > 0 && float64(numFailed) >
+		spec.FailureThreshold*float64(status.NumAdmitted) {
+		message := fmt.Sprintf("%d of %d subs failed", numFailed,
+			status.NumAdmitted)
+		if spec.AutoRollback {
+			rollout.setState(proto.RolloutStateRolledBack, message)
+			herd.logger.Printf("Rolling back rollout of: %s: %s\n",
+				spec.ImageName, message)
+			hostnamesToWake = rollout.hostnames()
+		} else {
+			rollout.setState(proto.RolloutStateHalted, message)
+			herd.logger.Printf("Halted rollout of: %s: %s\n",
+				spec.ImageName, message)
+		}
+		if err := herd.writeRollout(); err != nil {
+			herd.logger.Println(err)
+		}
+		return hostnamesToWake
+	}
+	if status.State != proto.RolloutStateRunning {
+		return nil
+	}
+	if status.NumSynced < status.NumAdmitted {
+		rollout.WaveSyncedTime = time.Time{}
+		return nil
+	}
+	if rollout.WaveSyncedTime.IsZero() {
+		rollout.WaveSyncedTime = time.Now()
+	}
+	if time.Since(rollout.WaveSyncedTime) < spec.WaveSoakTime {
+		return nil
+	}
+	rollout.WaveSyncedTime = time.Time{}
+	if status.CurrentWave+1 >= uint(len(spec.Waves)) {
+		rollout.setState(proto.RolloutStateCompleted, "")
+		herd.logger.Printf("Completed rollout of: %s\n", spec.ImageName)
+	} else {
+		status.CurrentWave++
+		hostnamesToWake = rollout.admitWave(eligibleSubs)
+		status.NumAdmitted += uint(len(hostnamesToWake))
+		herd.logger.Printf(
+			"Rollout of: %s advanced to wave: %d, admitted: %d subs\n",
+			spec.ImageName, status.CurrentWave, len(hostnamesToWake))
+	}
+	if err := herd.writeRollout(); err != nil {
+		herd.logger.Println(err)
+	}
+	return hostnamesToWake
+}
+
+// wakeSubs cancels any blocking operations for the specified subs. The sub
+// goroutine re-evaluates the required image when it next loads its
+// configuration and forces a full poll if it has changed.
+func (herd *Herd) wakeSubs(hostnames []string) {
+	if len(hostnames) < 1 {
+		return
+	}
+	herd.RLock()
+	defer herd.RUnlock()
+	for _, hostname := range hostnames {
+		if sub := herd.subsByName[hostname]; sub != nil {
+			sub.sendCancel()
+		}
+	}
+}
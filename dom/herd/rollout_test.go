@@ -0,0 +1,254 @@
+package herd
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+	"github.com/Symantec/Dominator/lib/tags"
+	proto "github.com/Symantec/Dominator/proto/dominator"
+)
+
+func makeEligibleSubs(count int) []subRolloutInfo {
+	subs := make([]subRolloutInfo, 0, count)
+	for index := 0; index < count; index++ {
+		hostname := fmt.Sprintf("sub%03d", index)
+		location := "east"
+		if index%2 == 1 {
+			location = "west"
+		}
+		subs = append(subs, subRolloutInfo{
+			hostname: hostname,
+			order:    hashHostname(hostname),
+			tags:     map[string]string{"Location": location},
+		})
+	}
+	return subs
+}
+
+func TestCheckRolloutSpec(t *testing.T) {
+	badSpecs := []proto.RolloutSpec{
+		{PreviousImageName: "old"},
+		{ImageName: "new"},
+		{ImageName: "same", PreviousImageName: "same"},
+		{ImageName: "new", PreviousImageName: "old", FailureThreshold: 2},
+		{
+			ImageName:         "new",
+			PreviousImageName: "old",
+			Waves:             []proto.RolloutWave{{Percent: 101}},
+		},
+	}
+	for _, spec := range badSpecs {
+		if err := checkRolloutSpec(&spec); err == nil {
+			t.Errorf("no error for bad spec: %v", spec)
+		}
+	}
+	spec := proto.RolloutSpec{ImageName: "new", PreviousImageName: "old"}
+	if err := checkRolloutSpec(&spec); err != nil {
+		t.Fatal(err)
+	}
+	if len(spec.Waves) != 1 || spec.Waves[0].Percent != 100 {
+		t.Errorf("expected single 100%% wave, got: %v", spec.Waves)
+	}
+}
+
+func TestAdmitWave(t *testing.T) {
+	eligibleSubs := makeEligibleSubs(100)
+	rollout := &rolloutType{
+		Status: proto.RolloutStatus{
+			Spec: proto.RolloutSpec{
+				Waves: []proto.RolloutWave{
+					{Percent: 1},
+					{Percent: 10},
+					{Percent: 50, RequiredTags: tags.Tags{"Location": "east"}},
+					{},
+				},
+			},
+		},
+		AdmittedSubs: make(map[string]struct{}),
+	}
+	expectedTotals := []int{1, 10, -1, 100}
+	for wave, expectedTotal := range expectedTotals {
+		rollout.Status.CurrentWave = uint(wave)
+		newSubs := rollout.admitWave(eligibleSubs)
+		if expectedTotal >= 0 &&
+			len(rollout.AdmittedSubs) != expectedTotal {
+			t.Errorf("wave: %d: expected %d admitted, got: %d",
+				wave, expectedTotal, len(rollout.AdmittedSubs))
+		}
+		if wave != 2 {
+			continue
+		}
+		// Half of the matching subs must be admitted, and only matching
+		// subs are newly admitted.
+		var numEast int
+		for _, sub := range eligibleSubs {
+			if _, ok := rollout.AdmittedSubs[sub.hostname]; !ok {
+				continue
+			}
+			if sub.tags["Location"] == "east" {
+				numEast++
+			}
+		}
+		if numEast != 25 {
+			t.Errorf("expected 25 east subs admitted, got: %d", numEast)
+		}
+		for _, hostname := range newSubs {
+			for _, sub := range eligibleSubs {
+				if sub.hostname == hostname &&
+					sub.tags["Location"] != "east" {
+					t.Errorf("admitted sub: %s not in east", hostname)
+				}
+			}
+		}
+	}
+	// Admitting the same wave again should be a no-op.
+	if newSubs := rollout.admitWave(eligibleSubs); len(newSubs) != 0 {
+		t.Errorf("re-admitted %d subs", len(newSubs))
+	}
+}
+
+func TestUpdateRolloutProgression(t *testing.T) {
+	herd := &Herd{logger: testlogger.New(t)}
+	eligibleSubs := makeEligibleSubs(20)
+	rollout := &rolloutType{
+		Status: proto.RolloutStatus{
+			Spec: proto.RolloutSpec{
+				ImageName:         "new",
+				PreviousImageName: "old",
+				FailureThreshold:  0.2,
+				Waves: []proto.RolloutWave{
+					{Percent: 10},
+					{Percent: 50},
+					{Percent: 100},
+				},
+			},
+			State: proto.RolloutStateRunning,
+		},
+		AdmittedSubs: make(map[string]struct{}),
+	}
+	herd.rollout = rollout
+	rollout.admitWave(eligibleSubs)
+	numAdmitted := uint(len(rollout.AdmittedSubs))
+	// Not yet synced: no progress.
+	woken := herd.updateRollout(rollout, eligibleSubs,
+		rolloutCounts{numAdmitted: numAdmitted})
+	if len(woken) != 0 || rollout.Status.CurrentWave != 0 {
+		t.Fatalf("advanced before wave synced: wave: %d",
+			rollout.Status.CurrentWave)
+	}
+	for _, expectedAdmitted := range []uint{10, 20} {
+		woken = herd.updateRollout(rollout, eligibleSubs, rolloutCounts{
+			numAdmitted: numAdmitted,
+			numSynced:   numAdmitted,
+		})
+		numAdmitted += uint(len(woken))
+		if numAdmitted != expectedAdmitted {
+			t.Fatalf("expected %d admitted, got: %d",
+				expectedAdmitted, numAdmitted)
+		}
+		if rollout.Status.NumAdmitted != numAdmitted {
+			t.Errorf("status shows %d admitted, expected: %d",
+				rollout.Status.NumAdmitted, numAdmitted)
+		}
+	}
+	herd.updateRollout(rollout, eligibleSubs, rolloutCounts{
+		numAdmitted: numAdmitted,
+		numSynced:   numAdmitted,
+	})
+	if rollout.Status.State != proto.RolloutStateCompleted {
+		t.Errorf("expected completed, got: %s", rollout.Status.State)
+	}
+	if !rollout.rolloutApplies() {
+		t.Error("completed rollout should still apply")
+	}
+}
+
+func TestUpdateRolloutPaused(t *testing.T) {
+	herd := &Herd{logger: testlogger.New(t)}
+	eligibleSubs := makeEligibleSubs(10)
+	rollout := &rolloutType{
+		Status: proto.RolloutStatus{
+			Spec: proto.RolloutSpec{
+				ImageName:         "new",
+				PreviousImageName: "old",
+				Waves:             []proto.RolloutWave{{Percent: 10}, {}},
+			},
+			State: proto.RolloutStateRunning,
+		},
+		AdmittedSubs: make(map[string]struct{}),
+	}
+	herd.rollout = rollout
+	rollout.admitWave(eligibleSubs)
+	rollout.setState(proto.RolloutStatePaused, "")
+	woken := herd.updateRollout(rollout, eligibleSubs,
+		rolloutCounts{numAdmitted: 1, numSynced: 1})
+	if len(woken) != 0 || rollout.Status.CurrentWave != 0 {
+		t.Errorf("paused rollout advanced to wave: %d",
+			rollout.Status.CurrentWave)
+	}
+}
+
+func TestUpdateRolloutHalt(t *testing.T) {
+	for _, autoRollback := range []bool{false, true} {
+		herd := &Herd{logger: testlogger.New(t)}
+		eligibleSubs := makeEligibleSubs(20)
+		rollout := &rolloutType{
+			Status: proto.RolloutStatus{
+				Spec: proto.RolloutSpec{
+					ImageName:         "new",
+					PreviousImageName: "old",
+					FailureThreshold:  0.25,
+					AutoRollback:      autoRollback,
+					Waves:             []proto.RolloutWave{{Percent: 20}, {}},
+				},
+				State: proto.RolloutStateRunning,
+			},
+			AdmittedSubs: make(map[string]struct{}),
+		}
+		herd.rollout = rollout
+		rollout.admitWave(eligibleSubs)
+		// A failure ratio at the threshold is tolerated.
+		herd.updateRollout(rollout, eligibleSubs, rolloutCounts{
+			numAdmitted: 4,
+			numSynced:   3,
+			failedSubs:  []string{"sub000"},
+		})
+		if rollout.Status.State != proto.RolloutStateRunning {
+			t.Fatalf("stopped at threshold: %s", rollout.Status.State)
+		}
+		woken := herd.updateRollout(rollout, eligibleSubs, rolloutCounts{
+			numAdmitted: 4,
+			numSynced:   2,
+			failedSubs:  []string{"sub000", "sub001"},
+		})
+		if autoRollback {
+			if rollout.Status.State != proto.RolloutStateRolledBack {
+				t.Fatalf("expected rolled back, got: %s",
+					rollout.Status.State)
+			}
+			if len(woken) != 4 {
+				t.Errorf("expected 4 subs woken, got: %d", len(woken))
+			}
+			if rollout.rolloutApplies() {
+				t.Error("rolled back rollout should not apply")
+			}
+		} else {
+			if rollout.Status.State != proto.RolloutStateHalted {
+				t.Fatalf("expected halted, got: %s", rollout.Status.State)
+			}
+			if len(woken) != 0 {
+				t.Errorf("halted rollout woke %d subs", len(woken))
+			}
+		}
+		if rollout.Status.NumFailed != 2 {
+			t.Errorf("expected 2 failed, got: %d", rollout.Status.NumFailed)
+		}
+		// A stopped rollout must not advance.
+		herd.updateRollout(rollout, eligibleSubs,
+			rolloutCounts{numAdmitted: 4, numSynced: 4})
+		if rollout.Status.CurrentWave != 0 {
+			t.Errorf("stopped rollout advanced")
+		}
+	}
+}
@@ -2,6 +2,7 @@ package herd
 
 import (
 	"fmt"
+	"html"
 	"io"
 	"net/http"
 	"strings"
@@ -39,6 +40,10 @@ func (herd *Herd) showReachableSubsHandler(w io.Writer, req *http.Request) {
 	herd.showSubs(w, "reachable ", selector)
 }
 
+func (herd *Herd) showRolloutSubsHandler(w io.Writer, req *http.Request) {
+	herd.showSubs(w, "rollout ", herd.selectRolloutSub)
+}
+
 func (herd *Herd) showSubs(writer io.Writer, subType string,
 	selectFunc func(*Sub) bool) {
 	fmt.Fprintf(writer, "<title>Dominator %s subs</title>", subType)
@@ -167,6 +172,8 @@ func (herd *Herd) showSubHandler(w io.Writer, req *http.Request) {
 	sub.showBusy(w)
 	newRow(w, "Status", false)
 	fmt.Fprintf(w, "    <td>%s</td>\n", sub.publishedStatus.html())
+	sub.showMaintenance(w, timeNow)
+	sub.showRollback(w, timeNow)
 	newRow(w, "Uptime", false)
 	showSince(w, sub.pollTime, sub.startTime)
 	newRow(w, "Last scan duration", false)
@@ -175,6 +182,10 @@ func (herd *Herd) showSubHandler(w io.Writer, req *http.Request) {
 	showSince(w, timeNow, sub.lastPollSucceededTime)
 	newRow(w, "Time since last update", false)
 	showSince(w, timeNow, sub.lastUpdateTime)
+	if len(sub.lastUpdateTriggerResults) > 0 {
+		newRow(w, "Last update triggers", false)
+		sub.showTriggerResults(w)
+	}
 	newRow(w, "Time since last sync", false)
 	showSince(w, timeNow, sub.lastSyncTime)
 	newRow(w, "Last connection duration", false)
@@ -201,6 +212,30 @@ func newRow(w io.Writer, row string, first bool) {
 	fmt.Fprintf(w, "    <td>%s:</td>\n", row)
 }
 
+func (sub *Sub) showTriggerResults(writer io.Writer) {
+	fmt.Fprint(writer, "    <td>")
+	if sub.lastUpdateRolledBack {
+		fmt.Fprint(writer, `<font color="red">rolled back</font><br>`)
+	}
+	for _, result := range sub.lastUpdateTriggerResults {
+		if result.Rollback {
+			fmt.Fprint(writer, "rollback: ")
+		}
+		fmt.Fprintf(writer, "%s %s: ", result.Action, result.Service)
+		if result.Error != "" {
+			fmt.Fprintf(writer, `<font color="red">%s</font><br>`,
+				html.EscapeString(result.Error))
+		} else if result.HealthCheckError != "" {
+			fmt.Fprintf(writer,
+				`<font color="red">health check failed: %s</font><br>`,
+				html.EscapeString(result.HealthCheckError))
+		} else {
+			fmt.Fprint(writer, "OK<br>")
+		}
+	}
+	fmt.Fprintln(writer, "</td>")
+}
+
 func (sub *Sub) showBusy(writer io.Writer) {
 	if sub.busy {
 		if sub.busyStartTime.IsZero() {
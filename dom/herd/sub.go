@@ -13,11 +13,13 @@ import (
 	"github.com/Symantec/Dominator/lib/constants"
 	filegenclient "github.com/Symantec/Dominator/lib/filegen/client"
 	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/format"
 	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/image"
 	"github.com/Symantec/Dominator/lib/objectcache"
 	"github.com/Symantec/Dominator/lib/resourcepool"
 	"github.com/Symantec/Dominator/lib/srpc"
+	filegenproto "github.com/Symantec/Dominator/proto/filegenerator"
 	subproto "github.com/Symantec/Dominator/proto/sub"
 	"github.com/Symantec/Dominator/sub/client"
 )
@@ -27,6 +29,8 @@ var (
 		true, "If true, update the configurations for all subs")
 	logUnknownSubConnectErrors = flag.Bool("logUnknownSubConnectErrors", false,
 		"If true, log unknown sub connection errors")
+	minimumDeltaFetchSize = flag.Uint64("minimumDeltaFetchSize", 1<<20,
+		"Minimum object size for subs to fetch changed chunks only (0: never)")
 	showIP = flag.Bool("showIP", false,
 		"If true, prefer to show IP address from MDB if available")
 	useIP = flag.Bool("useIP", true,
@@ -194,8 +198,17 @@ func (sub *Sub) loadConfiguration() {
 	if newRequiredImageName == "" {
 		newRequiredImageName = sub.herd.defaultImageName
 	}
+	newRequiredImageName = sub.herd.getRolloutImageName(sub.mdb.Hostname,
+		newRequiredImageName)
 	if newRequiredImageName != sub.requiredImageName {
 		sub.computedInodes = nil
+		if sub.requiredImageName != "" {
+			// Force a full poll: any sync was to the previous image.
+			sub.generationCount = 0
+			if sub.status == statusSynced {
+				sub.status = statusWaitingToPoll
+			}
+		}
 	}
 	sub.herd.cpuSharer.ReleaseCpu()
 	defer sub.herd.cpuSharer.GrabCpu()
@@ -205,6 +218,41 @@ func (sub *Sub) loadConfiguration() {
 	sub.plannedImage = sub.herd.imageManager.GetNoError(sub.plannedImageName)
 }
 
+// addComputedInodes adds the computed inodes for the generated files in
+// fileInfos which are computed files in fs. It returns true if any were added.
+func addComputedInodes(computedInodes map[string]*filesystem.RegularInode,
+	fs *filesystem.FileSystem, fileInfos []filegenproto.FileInfo) bool {
+	added := false
+	filenameToInodeTable := fs.FilenameToInodeTable()
+	for _, fileInfo := range fileInfos {
+		if fileInfo.Hash == zeroHash {
+			continue // No object.
+		}
+		inum, ok := filenameToInodeTable[fileInfo.Pathname]
+		if !ok {
+			continue
+		}
+		genericInode, ok := fs.InodeTable[inum]
+		if !ok {
+			continue
+		}
+		cInode, ok := genericInode.(*filesystem.ComputedRegularInode)
+		if !ok {
+			continue
+		}
+		computedInodes[fileInfo.Pathname] = &filesystem.RegularInode{
+			Mode:         cInode.Mode,
+			Uid:          cInode.Uid,
+			Gid:          cInode.Gid,
+			MtimeSeconds: -1, // The time is set during the compute.
+			Size:         fileInfo.Length,
+			Hash:         fileInfo.Hash,
+		}
+		added = true
+	}
+	return added
+}
+
 func (sub *Sub) processFileUpdates() bool {
 	haveUpdates := false
 	for {
@@ -228,32 +276,8 @@ func (sub *Sub) processFileUpdates() bool {
 			if image == nil {
 				continue
 			}
-			filenameToInodeTable := image.FileSystem.FilenameToInodeTable()
-			for _, fileInfo := range fileInfos {
-				if fileInfo.Hash == zeroHash {
-					continue // No object.
-				}
-				inum, ok := filenameToInodeTable[fileInfo.Pathname]
-				if !ok {
-					continue
-				}
-				genericInode, ok := image.FileSystem.InodeTable[inum]
-				if !ok {
-					continue
-				}
-				cInode, ok := genericInode.(*filesystem.ComputedRegularInode)
-				if !ok {
-					continue
-				}
-				rInode := &filesystem.RegularInode{
-					Mode:         cInode.Mode,
-					Uid:          cInode.Uid,
-					Gid:          cInode.Gid,
-					MtimeSeconds: -1, // The time is set during the compute.
-					Size:         fileInfo.Length,
-					Hash:         fileInfo.Hash,
-				}
-				sub.computedInodes[fileInfo.Pathname] = rInode
+			if addComputedInodes(sub.computedInodes, image.FileSystem,
+				fileInfos) {
 				haveUpdates = true
 			}
 		default:
@@ -286,6 +310,18 @@ func (sub *Sub) poll(srpcClient *srpc.Client, previousStatus subStatus) {
 	if previousStatus == statusUnsafeUpdate && sub.pendingSafetyClear {
 		sub.generationCount = 0 // Force a full poll.
 	}
+	// If the last update was waiting for a maintenance window which may now be
+	// open or the maintenance policy has changed, force a full poll.
+	if previousStatus == statusWaitingForMaintenanceWindow &&
+		sub.maintenanceWaitOver() {
+		sub.generationCount = 0 // Force a full poll.
+	}
+	// If the last update was deferred because the image was rolled back and
+	// the backoff has expired, force a full poll.
+	if previousStatus == statusWaitingAfterRollback &&
+		!sub.rollbackBackoffActive(time.Now()) {
+		sub.generationCount = 0 // Force a full poll.
+	}
 	var request subproto.PollRequest
 	request.HaveGeneration = sub.generationCount
 	var reply subproto.PollResponse
@@ -377,6 +413,16 @@ func (sub *Sub) poll(srpcClient *srpc.Client, previousStatus subStatus) {
 	}
 	if previousStatus == statusUpdating {
 		// Transition from updating to update ended (may be partial/failed).
+		sub.lastUpdateHadTriggerFailures = reply.LastUpdateHadTriggerFailures
+		sub.lastUpdateRolledBack = reply.LastUpdateRolledBack
+		sub.lastUpdateTriggerResults = reply.LastUpdateTriggerResults
+		if reply.LastUpdateRolledBack || reply.LastUpdateError == "" {
+			sub.recordRollback(reply.LastUpdateRolledBack, time.Now())
+		}
+		if reply.LastUpdateRolledBack {
+			logger.Printf("Update rolled back for: %s, retry in: %s\n",
+				sub, format.Duration(time.Until(sub.rollbackRetryTime)))
+		}
 		if reply.LastUpdateError != "" {
 			logger.Printf("Update failure for: %s: %s\n",
 				sub, reply.LastUpdateError)
@@ -540,8 +586,15 @@ func (sub *Sub) fetchMissingObjects(srpcClient *srpc.Client, image *image.Image,
 		}
 		logger.Printf("Calling %s:Subd.Fetch() for: %d objects\n",
 			sub, len(objectsToFetch))
-		err := client.Fetch(srpcClient, sub.herd.imageManager.String(),
-			objectcache.ObjectMapToCache(objectsToFetch))
+		request := subproto.FetchRequest{
+			ServerAddress: sub.herd.imageManager.String(),
+			Hashes:        objectcache.ObjectMapToCache(objectsToFetch),
+		}
+		if *minimumDeltaFetchSize > 0 {
+			request.Deltas = lib.BuildFetchDeltas(subObj, image,
+				objectsToFetch, *minimumDeltaFetchSize)
+		}
+		err := client.CallFetch(srpcClient, request)
 		if err != nil {
 			srpcClient.Close()
 			logger.Printf("Error calling %s:Subd.Fetch(): %s\n", sub, err)
@@ -598,8 +651,17 @@ func (sub *Sub) sendUpdate(srpcClient *srpc.Client) (bool, subStatus) {
 			return false, statusUnsafeUpdate
 		}
 	}
+	if sub.rollbackBackoffActive(time.Now()) {
+		return false, statusWaitingAfterRollback
+	}
+	if !sub.checkMaintenance(request) {
+		return false, statusWaitingForMaintenanceWindow
+	}
 	sub.status = statusSendingUpdate
 	sub.lastUpdateTime = time.Now()
+	sub.lastUpdateHadTriggerFailures = false
+	sub.lastUpdateRolledBack = false
+	sub.lastUpdateTriggerResults = nil
 	logger.Printf("Calling %s:Subd.Update() for image: %s\n",
 		sub, sub.requiredImageName)
 	if err := client.CallUpdate(srpcClient, request, &reply); err != nil {
@@ -64,6 +64,10 @@ func (status subStatus) string() string {
 		return "updates disabled"
 	case statusUnsafeUpdate:
 		return "unsafe update"
+	case statusWaitingForMaintenanceWindow:
+		return "waiting for maintenance window"
+	case statusWaitingAfterRollback:
+		return "waiting after rollback"
 	case statusUpdating:
 		return "updating"
 	case statusUpdateDenied:
@@ -81,7 +85,7 @@ func (status subStatus) string() string {
 
 func (status subStatus) html() string {
 	switch status {
-	case statusUnsafeUpdate:
+	case statusUnsafeUpdate, statusWaitingAfterRollback:
 		return `<font color="red">` + status.String() + "</font>"
 	default:
 		return status.String()
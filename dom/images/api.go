@@ -4,6 +4,7 @@ import (
 	"sync"
 
 	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/image/trust"
 	"github.com/Symantec/Dominator/lib/log"
 	"github.com/Symantec/Dominator/lib/stringutil"
 )
@@ -12,6 +13,8 @@ type Manager struct {
 	imageServerAddress string
 	logger             log.Logger
 	loggedDialFailure  bool
+	trustPolicy        *trust.Policy
+	untrustedImages    map[string]struct{} // Not retried until uninterested.
 	sync.RWMutex
 	deduper *stringutil.StringDeduplicator
 	// Protected by lock.
@@ -22,8 +25,9 @@ type Manager struct {
 	missingImages        map[string]error
 }
 
-func New(imageServerAddress string, logger log.Logger) *Manager {
-	return newManager(imageServerAddress, logger)
+func New(imageServerAddress string, trustPolicy *trust.Policy,
+	logger log.Logger) *Manager {
+	return newManager(imageServerAddress, trustPolicy, logger)
 }
 
 func (m *Manager) Get(name string, wait bool) (*image.Image, error) {
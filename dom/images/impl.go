@@ -5,18 +5,22 @@ import (
 
 	"github.com/Symantec/Dominator/imageserver/client"
 	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/image/trust"
 	"github.com/Symantec/Dominator/lib/log"
 	"github.com/Symantec/Dominator/lib/srpc"
 	"github.com/Symantec/Dominator/lib/stringutil"
 )
 
-func newManager(imageServerAddress string, logger log.Logger) *Manager {
+func newManager(imageServerAddress string, trustPolicy *trust.Policy,
+	logger log.Logger) *Manager {
 	imageInterestChannel := make(chan map[string]struct{})
 	imageRequestChannel := make(chan string)
 	imageExpireChannel := make(chan string, 16)
 	m := &Manager{
 		imageServerAddress:   imageServerAddress,
 		logger:               logger,
+		trustPolicy:          trustPolicy,
+		untrustedImages:      make(map[string]struct{}),
 		deduper:              stringutil.NewStringDeduplicator(false),
 		imageInterestChannel: imageInterestChannel,
 		imageRequestChannel:  imageRequestChannel,
@@ -83,13 +87,15 @@ func (m *Manager) manager(imageInterestChannel <-chan map[string]struct{},
 			// Loop over missing (pending) images. First obtain a copy.
 			missingImages := make(map[string]struct{})
 			for name := range m.missingImages {
-				missingImages[name] = struct{}{}
+				if _, ok := m.untrustedImages[name]; !ok {
+					missingImages[name] = struct{}{}
+				}
 			}
 			for name := range missingImages {
 				imageClient = m.requestImage(imageClient, name)
 			}
 		}
-		if len(m.missingImages) > 0 {
+		if len(m.missingImages) > len(m.untrustedImages) {
 			timer.Reset(time.Second)
 		}
 	}
@@ -117,6 +123,11 @@ func (m *Manager) setInterest(imageClient *srpc.Client,
 			m.Unlock()
 		}
 	}
+	for name := range m.untrustedImages {
+		if _, ok := imageList[name]; !ok {
+			delete(m.untrustedImages, name)
+		}
+	}
 	if deletedSome {
 		m.rebuildDeDuper()
 	}
@@ -166,6 +177,11 @@ func (m *Manager) loadImage(imageClient *srpc.Client, name string) (
 	if img == nil || m.scheduleExpiration(img, name) {
 		return imageClient, nil, nil
 	}
+	if err := m.trustPolicy.Verify(img); err != nil {
+		m.logger.Printf("Ignoring untrusted image: %s: %s\n", name, err)
+		m.untrustedImages[name] = struct{}{}
+		return imageClient, nil, err
+	}
 	if err := img.FileSystem.RebuildInodePointers(); err != nil {
 		m.logger.Printf("Error building inode pointers for image: %s %s",
 			name, err)
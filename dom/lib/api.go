@@ -62,6 +62,16 @@ func BuildMissingLists(sub Sub, image *image.Image, pushComputedFiles bool,
 		ignoreMissingComputedFiles, logger)
 }
 
+// BuildFetchDeltas will construct a list of delta transfer suggestions for
+// objects to be fetched by the sub. For each object of at least minimumSize
+// bytes, if the sub has a different version of a file which uses the object in
+// the image, that file is suggested as the base for a delta transfer.
+func BuildFetchDeltas(sub Sub, image *image.Image,
+	objectsToFetch map[hash.Hash]uint64,
+	minimumSize uint64) []subproto.FetchDelta {
+	return sub.buildFetchDeltas(image, objectsToFetch, minimumSize)
+}
+
 // BuildUpdateRequest will build an update request which can be sent to the sub.
 // If deleteMissingComputedFiles is true then missing computed files are deleted
 // on the sub, else missing computed files lead to the function failing.
@@ -0,0 +1,55 @@
+package lib
+
+import (
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/image"
+	subproto "github.com/Symantec/Dominator/proto/sub"
+)
+
+func (sub *Sub) buildFetchDeltas(image *image.Image,
+	objectsToFetch map[hash.Hash]uint64,
+	minimumSize uint64) []subproto.FetchDelta {
+	if sub.FileSystem == nil || image.FileSystem == nil {
+		return nil
+	}
+	wanted := make(map[hash.Hash]struct{})
+	for hashVal, size := range objectsToFetch {
+		if size >= minimumSize {
+			wanted[hashVal] = struct{}{}
+		}
+	}
+	if len(wanted) < 1 {
+		return nil
+	}
+	subFilenameToInode := sub.FileSystem.FilenameToInodeTable()
+	var deltas []subproto.FetchDelta
+	for inum, filenames := range image.FileSystem.InodeToFilenamesTable() {
+		inode, ok := image.FileSystem.InodeTable[inum].(*filesystem.RegularInode)
+		if !ok {
+			continue
+		}
+		if _, ok := wanted[inode.Hash]; !ok {
+			continue
+		}
+		for _, filename := range filenames {
+			subInum, ok := subFilenameToInode[filename]
+			if !ok {
+				continue
+			}
+			subInode, ok :=
+				sub.FileSystem.InodeTable[subInum].(*filesystem.RegularInode)
+			if !ok || subInode.Size < 1 || subInode.Hash == inode.Hash {
+				continue
+			}
+			deltas = append(deltas, subproto.FetchDelta{
+				Hash:         inode.Hash,
+				BaseHash:     subInode.Hash,
+				BasePathname: filename,
+			})
+			delete(wanted, inode.Hash)
+			break
+		}
+	}
+	return deltas
+}
@@ -0,0 +1,113 @@
+/*
+	Package maintenance implements scheduled maintenance windows and update
+	blackout periods for subs.
+
+	A policy contains a list of maintenance windows and a list of blackout
+	periods. Each period starts according to a cron-like schedule (see the
+	lib/cron package), evaluated in a timezone, and lasts for a fixed duration.
+	A period may be restricted to machines in a set of owner groups and/or to
+	machines with a set of tags. Disruptive updates (updates which match
+	triggers) may only be pushed during a maintenance window, if any windows
+	apply to the machine, and never during a blackout period. Non-disruptive
+	updates are always permitted.
+*/
+package maintenance
+
+import (
+	"io"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/cron"
+	"github.com/Symantec/Dominator/lib/mdb"
+	"github.com/Symantec/Dominator/lib/tags"
+)
+
+const (
+	ImpactNone       Impact = iota // No triggers matched.
+	ImpactTriggers                 // Services will be restarted.
+	ImpactHighImpact               // High impact triggers or a reboot.
+)
+
+type Impact uint
+
+// Period describes a recurring maintenance window or blackout period.
+type Period struct {
+	Name           string
+	Schedule       string    // Cron expression for the start of the period.
+	Duration       string    // For example: "4h".
+	Timezone       string    `json:",omitempty"` // Default: UTC.
+	OwnerGroups    []string  `json:",omitempty"` // Default: all.
+	Tags           tags.Tags `json:",omitempty"` // All must match.
+	HighImpactOnly bool      `json:",omitempty"` // Only restrict high impact.
+	schedule       *cron.Schedule
+	duration       time.Duration
+	location       *time.Location
+}
+
+// Config is the JSON representation of a Policy.
+type Config struct {
+	Windows   []Period `json:",omitempty"`
+	Blackouts []Period `json:",omitempty"`
+}
+
+// Decision is the result of checking whether an update may be sent.
+type Decision struct {
+	Allowed   bool
+	Reason    string    // Why the update is not allowed.
+	RetryTime time.Time // When the update may be allowed.
+}
+
+// Occurrence is a single occurrence of a Period.
+type Occurrence struct {
+	Name      string
+	StartTime time.Time
+	EndTime   time.Time
+}
+
+type Policy struct {
+	windows    []*Period
+	blackouts  []*Period
+	denyReason string
+}
+
+// Decode will decode a JSON-encoded Config from reader and will return a
+// Policy.
+func Decode(reader io.Reader) (*Policy, error) {
+	return decode(reader)
+}
+
+// Deny returns a Policy which refuses all disruptive updates, giving reason.
+// It should be used when a policy is required but could not be loaded.
+func Deny(reason string) *Policy {
+	return &Policy{denyReason: reason}
+}
+
+// Load will read a JSON-encoded Config from the specified file and will return
+// a Policy.
+func Load(filename string) (*Policy, error) {
+	return load(filename)
+}
+
+// ActiveBlackout returns the blackout period which applies to the machine at
+// time t, or nil if there is none. A nil Policy has no blackouts.
+func (p *Policy) ActiveBlackout(machine mdb.Machine,
+	t time.Time) *Occurrence {
+	return p.activeBlackout(machine, t)
+}
+
+// Check determines whether an update with the specified impact may be sent to
+// the machine at time t. A nil Policy allows all updates.
+func (p *Policy) Check(machine mdb.Machine, impact Impact,
+	t time.Time) Decision {
+	return p.check(machine, impact, t)
+}
+
+// NextWindow returns the current or next maintenance window which applies to
+// the machine at time t, or nil if no windows apply.
+func (p *Policy) NextWindow(machine mdb.Machine, t time.Time) *Occurrence {
+	return p.nextWindow(machine, t)
+}
+
+func (impact Impact) String() string {
+	return impact.string()
+}
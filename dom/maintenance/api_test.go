@@ -0,0 +1,132 @@
+package maintenance
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/mdb"
+	"github.com/Symantec/Dominator/lib/tags"
+)
+
+const testPolicy = `{
+  "Windows": [
+    {
+      "Name": "weekend",
+      "Schedule": "0 2 * * SAT,SUN",
+      "Duration": "4h",
+      "Timezone": "America/Los_Angeles",
+      "OwnerGroups": ["web"]
+    },
+    {
+      "Name": "db-nightly",
+      "Schedule": "30 1 * * *",
+      "Duration": "1h",
+      "Tags": {"Role": "db"},
+      "HighImpactOnly": true
+    }
+  ],
+  "Blackouts": [
+    {
+      "Name": "freeze",
+      "Schedule": "0 0 20 DEC *",
+      "Duration": "336h"
+    }
+  ]
+}`
+
+func TestCheck(t *testing.T) {
+	policy, err := Decode(strings.NewReader(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	web := mdb.Machine{Hostname: "web1", OwnerGroup: "web"}
+	db := mdb.Machine{Hostname: "db1", Tags: tags.Tags{"Role": "db"}}
+	other := mdb.Machine{Hostname: "other1"}
+	// Saturday 2018-03-17 03:00 PDT is 10:00 UTC.
+	inWeekend := time.Date(2018, time.March, 17, 10, 0, 0, 0, time.UTC)
+	weekday := time.Date(2018, time.March, 15, 10, 0, 0, 0, time.UTC)
+	inDbNightly := time.Date(2018, time.March, 15, 1, 45, 0, 0, time.UTC)
+	inFreeze := time.Date(2018, time.December, 25, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		machine mdb.Machine
+		impact  Impact
+		t       time.Time
+		allowed bool
+		retry   time.Time
+	}{
+		{"web none", web, ImpactNone, weekday, true, time.Time{}},
+		{"web weekday", web, ImpactTriggers, weekday, false,
+			time.Date(2018, time.March, 17, 9, 0, 0, 0, time.UTC)},
+		{"web weekend", web, ImpactHighImpact, inWeekend, true, time.Time{}},
+		{"web freeze", web, ImpactTriggers, inFreeze, false,
+			time.Date(2019, time.January, 3, 0, 0, 0, 0, time.UTC)},
+		{"web freeze none", web, ImpactNone, inFreeze, true, time.Time{}},
+		{"db triggers", db, ImpactTriggers, weekday, true, time.Time{}},
+		{"db high weekday", db, ImpactHighImpact, weekday, false,
+			time.Date(2018, time.March, 16, 1, 30, 0, 0, time.UTC)},
+		{"db high nightly", db, ImpactHighImpact, inDbNightly, true,
+			time.Time{}},
+		{"other weekday", other, ImpactHighImpact, weekday, true, time.Time{}},
+		{"other freeze", other, ImpactHighImpact, inFreeze, false,
+			time.Date(2019, time.January, 3, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		decision := policy.Check(test.machine, test.impact, test.t)
+		if decision.Allowed != test.allowed {
+			t.Errorf("%s: expected allowed=%t, got: %t (%s)",
+				test.name, test.allowed, decision.Allowed, decision.Reason)
+		}
+		if !decision.RetryTime.Equal(test.retry) {
+			t.Errorf("%s: expected retry time: %s, got: %s",
+				test.name, test.retry, decision.RetryTime)
+		}
+	}
+	var nilPolicy *Policy
+	if !nilPolicy.Check(web, ImpactHighImpact, weekday).Allowed {
+		t.Error("nil policy did not allow update")
+	}
+}
+
+func TestDeny(t *testing.T) {
+	if _, err := Decode(strings.NewReader(`{"Windows": [{}]}`)); err == nil {
+		t.Fatal("no error decoding invalid policy")
+	}
+	policy := Deny("bad policy")
+	machine := mdb.Machine{Hostname: "web1"}
+	timeNow := time.Now()
+	if !policy.Check(machine, ImpactNone, timeNow).Allowed {
+		t.Error("non-disruptive update refused")
+	}
+	for _, impact := range []Impact{ImpactTriggers, ImpactHighImpact} {
+		decision := policy.Check(machine, impact, timeNow)
+		if decision.Allowed {
+			t.Errorf("%s update allowed", impact)
+		}
+		if !strings.Contains(decision.Reason, "bad policy") {
+			t.Errorf("reason: %s does not explain refusal", decision.Reason)
+		}
+	}
+}
+
+func TestNextWindow(t *testing.T) {
+	policy, err := Decode(strings.NewReader(testPolicy))
+	if err != nil {
+		t.Fatal(err)
+	}
+	web := mdb.Machine{Hostname: "web1", OwnerGroup: "web"}
+	weekday := time.Date(2018, time.March, 15, 10, 0, 0, 0, time.UTC)
+	window := policy.NextWindow(web, weekday)
+	if window == nil {
+		t.Fatal("no window found")
+	}
+	expected := time.Date(2018, time.March, 17, 9, 0, 0, 0, time.UTC)
+	if window.Name != "weekend" || !window.StartTime.Equal(expected) {
+		t.Errorf("expected weekend at %s, got: %s at %s",
+			expected, window.Name, window.StartTime)
+	}
+	if window := policy.NextWindow(mdb.Machine{}, weekday); window != nil {
+		t.Errorf("unexpected window: %s", window.Name)
+	}
+}
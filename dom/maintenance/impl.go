@@ -0,0 +1,250 @@
+package maintenance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/cron"
+	"github.com/Symantec/Dominator/lib/mdb"
+)
+
+func decode(reader io.Reader) (*Policy, error) {
+	var config Config
+	decoder := json.NewDecoder(reader)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return nil, errors.New("error decoding maintenance policy: " +
+			err.Error())
+	}
+	policy := &Policy{}
+	for index := range config.Windows {
+		period := &config.Windows[index]
+		if err := period.compile(); err != nil {
+			return nil, fmt.Errorf("window: %s: %s", period.Name, err)
+		}
+		policy.windows = append(policy.windows, period)
+	}
+	for index := range config.Blackouts {
+		period := &config.Blackouts[index]
+		if err := period.compile(); err != nil {
+			return nil, fmt.Errorf("blackout: %s: %s", period.Name, err)
+		}
+		policy.blackouts = append(policy.blackouts, period)
+	}
+	return policy, nil
+}
+
+func load(filename string) (*Policy, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return decode(file)
+}
+
+func (period *Period) compile() error {
+	schedule, err := cron.Parse(period.Schedule)
+	if err != nil {
+		return err
+	}
+	duration, err := time.ParseDuration(period.Duration)
+	if err != nil {
+		return err
+	}
+	if duration <= 0 {
+		return errors.New("duration must be positive")
+	}
+	location := time.UTC
+	if period.Timezone != "" {
+		if location, err = time.LoadLocation(period.Timezone); err != nil {
+			return err
+		}
+	}
+	period.schedule = schedule
+	period.duration = duration
+	period.location = location
+	return nil
+}
+
+// applies returns true if the period applies to the machine for updates with
+// the specified impact.
+func (period *Period) applies(machine mdb.Machine, impact Impact) bool {
+	if period.HighImpactOnly && impact < ImpactHighImpact {
+		return false
+	}
+	if len(period.OwnerGroups) > 0 {
+		found := false
+		for _, ownerGroup := range period.OwnerGroups {
+			if ownerGroup == machine.OwnerGroup {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	for key, value := range period.Tags {
+		if machine.Tags[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// active returns the occurrence of the period which includes time t, or nil.
+func (period *Period) active(t time.Time) *Occurrence {
+	startTime := period.schedule.Next(
+		t.In(period.location).Add(-period.duration))
+	if startTime.IsZero() || startTime.After(t) {
+		return nil
+	}
+	return &Occurrence{
+		Name:      period.Name,
+		StartTime: startTime,
+		EndTime:   startTime.Add(period.duration),
+	}
+}
+
+// next returns the current occurrence of the period or the next one, or nil.
+func (period *Period) next(t time.Time) *Occurrence {
+	if occurrence := period.active(t); occurrence != nil {
+		return occurrence
+	}
+	startTime := period.schedule.Next(t.In(period.location))
+	if startTime.IsZero() {
+		return nil
+	}
+	return &Occurrence{
+		Name:      period.Name,
+		StartTime: startTime,
+		EndTime:   startTime.Add(period.duration),
+	}
+}
+
+func (p *Policy) activeBlackout(machine mdb.Machine,
+	t time.Time) *Occurrence {
+	if p == nil {
+		return nil
+	}
+	return p.findBlackout(machine, ImpactHighImpact, t)
+}
+
+func (p *Policy) check(machine mdb.Machine, impact Impact,
+	t time.Time) Decision {
+	if p == nil || impact == ImpactNone {
+		return Decision{Allowed: true}
+	}
+	if p.denyReason != "" {
+		return Decision{
+			Reason: fmt.Sprintf("%s update refused: %s", impact, p.denyReason),
+		}
+	}
+	if blackout := p.findBlackout(machine, impact, t); blackout != nil {
+		return Decision{
+			Reason: fmt.Sprintf("%s update in blackout: %s until %s",
+				impact, blackout.Name, blackout.EndTime.Format(time.RFC3339)),
+			RetryTime: blackout.EndTime,
+		}
+	}
+	var nextWindow *Occurrence
+	for _, period := range p.windows {
+		if !period.applies(machine, impact) {
+			continue
+		}
+		occurrence := period.next(t)
+		if occurrence == nil {
+			continue
+		}
+		if !occurrence.StartTime.After(t) {
+			return Decision{Allowed: true}
+		}
+		if nextWindow == nil ||
+			occurrence.StartTime.Before(nextWindow.StartTime) {
+			nextWindow = occurrence
+		}
+	}
+	if nextWindow == nil {
+		if p.hasWindows(machine, impact) {
+			return Decision{
+				Reason: fmt.Sprintf("%s update: no upcoming maintenance window",
+					impact),
+			}
+		}
+		return Decision{Allowed: true}
+	}
+	return Decision{
+		Reason: fmt.Sprintf("%s update waiting for maintenance window: %s at %s",
+			impact, nextWindow.Name, nextWindow.StartTime.Format(time.RFC3339)),
+		RetryTime: nextWindow.StartTime,
+	}
+}
+
+// findBlackout returns the active blackout period for the machine which ends
+// last, or nil.
+func (p *Policy) findBlackout(machine mdb.Machine, impact Impact,
+	t time.Time) *Occurrence {
+	var latest *Occurrence
+	for _, period := range p.blackouts {
+		if !period.applies(machine, impact) {
+			continue
+		}
+		occurrence := period.active(t)
+		if occurrence == nil {
+			continue
+		}
+		if latest == nil || occurrence.EndTime.After(latest.EndTime) {
+			latest = occurrence
+		}
+	}
+	return latest
+}
+
+// hasWindows returns true if any maintenance windows apply to the machine.
+func (p *Policy) hasWindows(machine mdb.Machine, impact Impact) bool {
+	for _, period := range p.windows {
+		if period.applies(machine, impact) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Policy) nextWindow(machine mdb.Machine, t time.Time) *Occurrence {
+	if p == nil {
+		return nil
+	}
+	var nextWindow *Occurrence
+	for _, period := range p.windows {
+		if !period.applies(machine, ImpactHighImpact) {
+			continue
+		}
+		occurrence := period.next(t)
+		if occurrence == nil {
+			continue
+		}
+		if nextWindow == nil ||
+			occurrence.StartTime.Before(nextWindow.StartTime) {
+			nextWindow = occurrence
+		}
+	}
+	return nextWindow
+}
+
+func (impact Impact) string() string {
+	switch impact {
+	case ImpactNone:
+		return "non-disruptive"
+	case ImpactTriggers:
+		return "triggered"
+	case ImpactHighImpact:
+		return "high impact"
+	default:
+		return "unknown impact"
+	}
+}
@@ -0,0 +1,18 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/dominator"
+)
+
+func (t *rpcType) PauseRollout(conn *srpc.Conn,
+	request dominator.PauseRolloutRequest,
+	reply *dominator.PauseRolloutResponse) error {
+	if conn.Username() == "" {
+		t.logger.Printf("PauseRollout(resume=%t)\n", request.Resume)
+	} else {
+		t.logger.Printf("PauseRollout(resume=%t): by %s\n",
+			request.Resume, conn.Username())
+	}
+	return t.herd.PauseRollout(request.Resume)
+}
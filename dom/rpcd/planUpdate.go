@@ -0,0 +1,23 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/dominator"
+)
+
+func (t *rpcType) PlanUpdate(conn *srpc.Conn,
+	request dominator.PlanUpdateRequest,
+	reply *dominator.PlanUpdateResponse) error {
+	if conn.Username() == "" {
+		t.logger.Printf("PlanUpdate(%s)\n", request.ImageName)
+	} else {
+		t.logger.Printf("PlanUpdate(%s): by %s\n",
+			request.ImageName, conn.Username())
+	}
+	response, err := t.herd.PlanUpdate(request)
+	if err != nil {
+		return err
+	}
+	*reply = response
+	return nil
+}
@@ -0,0 +1,20 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/dominator"
+)
+
+func (t *rpcType) StartRollout(conn *srpc.Conn,
+	request dominator.StartRolloutRequest,
+	reply *dominator.StartRolloutResponse) error {
+	if conn.Username() == "" {
+		t.logger.Printf("StartRollout(%s, %s)\n",
+			request.ImageName, request.PreviousImageName)
+	} else {
+		t.logger.Printf("StartRollout(%s, %s): by %s\n",
+			request.ImageName, request.PreviousImageName, conn.Username())
+	}
+	return t.herd.StartRollout(dominator.RolloutSpec(request),
+		conn.Username())
+}
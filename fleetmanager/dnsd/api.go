@@ -0,0 +1,48 @@
+package dnsd
+
+import (
+	"net"
+	"sync"
+
+	"github.com/Symantec/Dominator/fleetmanager/hypervisors"
+	"github.com/Symantec/Dominator/fleetmanager/topology"
+	"github.com/Symantec/Dominator/lib/log"
+	fm_proto "github.com/Symantec/Dominator/proto/fleetmanager"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+type recordsType struct {
+	addresses map[string][]net.IP // Key: FQDN.
+	names     map[string][]string // Key: reverse name, value: FQDNs.
+	serial    uint32
+	zones     map[string]struct{} // Key: forward or reverse zone name.
+}
+
+type Server struct {
+	hostname string
+	logger   log.DebugLogger
+	mutex    sync.RWMutex                 // Protect everything below.
+	machines map[string]*fm_proto.Machine // Key: hostname.
+	records  *recordsType
+	subnets  []*hyper_proto.Subnet
+	vms      map[string]*vmType // Key: VM IP address.
+}
+
+type vmType struct {
+	addresses []hyper_proto.Address // Primary address first.
+	hostname  string
+	subnetId  string
+}
+
+// StartServer starts an authoritative DNS server on the UDP and TCP port
+// specified by portNum. It serves forward and reverse records for the
+// Hypervisors and VMs managed by hypervisorsManager in the domains of the
+// subnets in the topology.
+func StartServer(portNum uint, hypervisorsManager *hypervisors.Manager,
+	logger log.DebugLogger) (*Server, error) {
+	return startServer(portNum, hypervisorsManager, logger)
+}
+
+func (s *Server) UpdateTopology(t *topology.Topology) {
+	s.updateTopology(t)
+}
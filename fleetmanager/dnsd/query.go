@@ -0,0 +1,195 @@
+package dnsd
+
+import (
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	maxUdpMessageLength = 512
+	ttl                 = 60
+)
+
+func makeResourceHeader(name dnsmessage.Name,
+	rrType dnsmessage.Type) dnsmessage.ResourceHeader {
+	return dnsmessage.ResourceHeader{
+		Name:  name,
+		Type:  rrType,
+		Class: dnsmessage.ClassINET,
+		TTL:   ttl,
+	}
+}
+
+func testType(question dnsmessage.Question, rrType dnsmessage.Type) bool {
+	return question.Type == rrType || question.Type == dnsmessage.TypeALL
+}
+
+// findZone returns the closest enclosing zone for the name, or "" if the name
+// is not in any zone which is served.
+func (records *recordsType) findZone(name string) string {
+	for {
+		if _, ok := records.zones[name]; ok {
+			return name
+		}
+		index := strings.IndexByte(name, '.')
+		if index < 0 || index+1 >= len(name) {
+			return ""
+		}
+		name = name[index+1:]
+	}
+}
+
+func (records *recordsType) makeSoa(zone, hostname string) (
+	dnsmessage.Resource, error) {
+	zoneName, err := dnsmessage.NewName(zone)
+	if err != nil {
+		return dnsmessage.Resource{}, err
+	}
+	nsName, err := dnsmessage.NewName(hostname)
+	if err != nil {
+		return dnsmessage.Resource{}, err
+	}
+	mboxName, err := dnsmessage.NewName("hostmaster." + zone)
+	if err != nil {
+		return dnsmessage.Resource{}, err
+	}
+	return dnsmessage.Resource{
+		Header: makeResourceHeader(zoneName, dnsmessage.TypeSOA),
+		Body: &dnsmessage.SOAResource{
+			NS:      nsName,
+			MBox:    mboxName,
+			Serial:  records.serial,
+			Refresh: 3600,
+			Retry:   600,
+			Expire:  86400,
+			MinTTL:  ttl,
+		},
+	}, nil
+}
+
+// answer fills in the answer and authority sections of the response for the
+// question, or sets an error response code.
+func (records *recordsType) answer(response *dnsmessage.Message,
+	question dnsmessage.Question, hostname string) error {
+	if question.Class != dnsmessage.ClassINET &&
+		question.Class != dnsmessage.ClassANY {
+		response.RCode = dnsmessage.RCodeRefused
+		return nil
+	}
+	name := strings.ToLower(question.Name.String())
+	zone := records.findZone(name)
+	if zone == "" {
+		response.RCode = dnsmessage.RCodeRefused
+		return nil
+	}
+	response.Authoritative = true
+	addresses, haveAddresses := records.addresses[name]
+	for _, address := range addresses {
+		if ip4 := address.To4(); ip4 != nil {
+			if testType(question, dnsmessage.TypeA) {
+				var body dnsmessage.AResource
+				copy(body.A[:], ip4)
+				response.Answers = append(response.Answers, dnsmessage.Resource{
+					Header: makeResourceHeader(question.Name, dnsmessage.TypeA),
+					Body:   &body,
+				})
+			}
+		} else if testType(question, dnsmessage.TypeAAAA) {
+			var body dnsmessage.AAAAResource
+			copy(body.AAAA[:], address.To16())
+			response.Answers = append(response.Answers, dnsmessage.Resource{
+				Header: makeResourceHeader(question.Name, dnsmessage.TypeAAAA),
+				Body:   &body,
+			})
+		}
+	}
+	names, haveNames := records.names[name]
+	if testType(question, dnsmessage.TypePTR) {
+		for _, ptrName := range names {
+			ptr, err := dnsmessage.NewName(ptrName)
+			if err != nil {
+				return err
+			}
+			response.Answers = append(response.Answers, dnsmessage.Resource{
+				Header: makeResourceHeader(question.Name, dnsmessage.TypePTR),
+				Body:   &dnsmessage.PTRResource{PTR: ptr},
+			})
+		}
+	}
+	soa, err := records.makeSoa(zone, hostname)
+	if err != nil {
+		return err
+	}
+	if name == zone {
+		if testType(question, dnsmessage.TypeSOA) {
+			response.Answers = append(response.Answers, soa)
+		}
+		if testType(question, dnsmessage.TypeNS) {
+			response.Answers = append(response.Answers, dnsmessage.Resource{
+				Header: makeResourceHeader(question.Name, dnsmessage.TypeNS),
+				Body: &dnsmessage.NSResource{
+					NS: soa.Body.(*dnsmessage.SOAResource).NS,
+				},
+			})
+		}
+	} else if !haveAddresses && !haveNames {
+		response.RCode = dnsmessage.RCodeNameError
+	}
+	if len(response.Answers) < 1 {
+		response.Authorities = append(response.Authorities, soa)
+	}
+	return nil
+}
+
+// handleQuery returns the response to the query message, or nil if no
+// response should be sent.
+func (s *Server) handleQuery(query []byte, maxLength int) []byte {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(query)
+	if err != nil || header.Response {
+		return nil
+	}
+	response := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:               header.ID,
+			Response:         true,
+			OpCode:           header.OpCode,
+			RecursionDesired: header.RecursionDesired,
+		},
+	}
+	if header.OpCode != 0 {
+		response.RCode = dnsmessage.RCodeNotImplemented
+	} else if question, err := parser.Question(); err != nil {
+		response.RCode = dnsmessage.RCodeFormatError
+	} else {
+		response.Questions = []dnsmessage.Question{question}
+		s.mutex.RLock()
+		records := s.records
+		s.mutex.RUnlock()
+		err := records.answer(&response, question, s.hostname)
+		if err != nil {
+			s.logger.Printf("error answering DNS query for: %s: %s\n",
+				question.Name, err)
+			response.Answers = nil
+			response.Authorities = nil
+			response.RCode = dnsmessage.RCodeServerFailure
+		}
+	}
+	responseMessage, err := response.Pack()
+	if err != nil {
+		s.logger.Printf("error packing DNS response: %s\n", err)
+		return nil
+	}
+	if len(responseMessage) <= maxLength {
+		return responseMessage
+	}
+	response.Truncated = true
+	response.Answers = nil
+	response.Authorities = nil
+	if responseMessage, err = response.Pack(); err != nil {
+		s.logger.Printf("error packing DNS response: %s\n", err)
+		return nil
+	}
+	return responseMessage
+}
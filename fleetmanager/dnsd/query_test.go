@@ -0,0 +1,226 @@
+package dnsd
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+	fm_proto "github.com/Symantec/Dominator/proto/fleetmanager"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func query(t *testing.T, server *Server, name string,
+	rrType dnsmessage.Type) dnsmessage.Message {
+	queryMessage := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: 1234},
+		Questions: []dnsmessage.Question{{
+			Name:  dnsmessage.MustNewName(name),
+			Type:  rrType,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	packedQuery, err := queryMessage.Pack()
+	if err != nil {
+		t.Fatal(err)
+	}
+	packedResponse := server.handleQuery(packedQuery, maxUdpMessageLength)
+	if packedResponse == nil {
+		t.Fatalf("%s: no response", name)
+	}
+	var response dnsmessage.Message
+	if err := response.Unpack(packedResponse); err != nil {
+		t.Fatal(err)
+	}
+	if response.ID != queryMessage.ID || !response.Response {
+		t.Fatalf("%s: bad response header: %v", name, response.Header)
+	}
+	return response
+}
+
+func TestQuery(t *testing.T) {
+	subnets := []*hyper_proto.Subnet{
+		{
+			Id:          "Production",
+			IpGateway:   net.ParseIP("10.20.0.1"),
+			IpMask:      net.ParseIP("255.255.252.0"),
+			Ipv6Gateway: net.ParseIP("2001:db8:20::1"),
+			Ipv6Mask:    net.ParseIP("ffff:ffff:ffff:ffff::"),
+			DomainName:  "syd.prod.company.com",
+		},
+	}
+	// The second machine is outside the subnets of the VMs.
+	machines := map[string]*fm_proto.Machine{
+		"row00-rack0.syd.prod.company.com": {
+			NetworkEntry: fm_proto.NetworkEntry{
+				Hostname:      "row00-rack0.syd.prod.company.com",
+				HostIpAddress: net.ParseIP("10.20.0.2"),
+			},
+		},
+		"hyper1.mgmt.company.com": {
+			NetworkEntry: fm_proto.NetworkEntry{
+				Hostname:      "hyper1.mgmt.company.com",
+				HostIpAddress: net.ParseIP("192.168.5.10"),
+			},
+		},
+	}
+	vms := map[string]*vmType{
+		"10.20.1.5": {
+			addresses: []hyper_proto.Address{{
+				IpAddress:   net.ParseIP("10.20.1.5"),
+				Ipv6Address: net.ParseIP("2001:db8:20::5054:aff:fe14:105"),
+			}},
+			hostname: "Web",
+		},
+	}
+	server := &Server{
+		hostname: "fleet-manager.company.com.",
+		logger:   testlogger.New(t),
+		records:  makeRecords(subnets, machines, vms, 1),
+	}
+	response := query(t, server, "web.SYD.prod.company.com.", dnsmessage.TypeA)
+	if response.RCode != dnsmessage.RCodeSuccess || !response.Authoritative {
+		t.Errorf("bad response: %v", response.Header)
+	} else if len(response.Answers) != 1 {
+		t.Errorf("expected 1 answer, got: %d", len(response.Answers))
+	} else {
+		body := response.Answers[0].Body.(*dnsmessage.AResource)
+		if ipAddr := net.IP(body.A[:]); !ipAddr.Equal(
+			net.ParseIP("10.20.1.5")) {
+			t.Errorf("bad address: %s", ipAddr)
+		}
+	}
+	response = query(t, server, "web.syd.prod.company.com.",
+		dnsmessage.TypeAAAA)
+	if len(response.Answers) != 1 {
+		t.Errorf("expected 1 AAAA answer, got: %d", len(response.Answers))
+	}
+	response = query(t, server, "5.1.20.10.in-addr.arpa.", dnsmessage.TypePTR)
+	if len(response.Answers) != 1 {
+		t.Errorf("expected 1 PTR answer, got: %d", len(response.Answers))
+	} else {
+		body := response.Answers[0].Body.(*dnsmessage.PTRResource)
+		if name := body.PTR.String(); name != "web.syd.prod.company.com." {
+			t.Errorf("bad PTR: %s", name)
+		}
+	}
+	response = query(t, server,
+		"5.0.1.0.4.1.e.f.f.f.a.0.4.5.0.5.0.0.0.0.0.2.0.0.8.b.d.0.1.0.0.2."+
+			"ip6.arpa.", dnsmessage.TypePTR)
+	if len(response.Answers) != 1 {
+		t.Errorf("expected 1 IPv6 PTR answer, got: %d",
+			len(response.Answers))
+	}
+	response = query(t, server, "row00-rack0.syd.prod.company.com.",
+		dnsmessage.TypeAAAA)
+	if response.RCode != dnsmessage.RCodeSuccess ||
+		len(response.Answers) != 0 || len(response.Authorities) != 1 {
+		t.Errorf("expected NODATA response, got: %v", response)
+	}
+	response = query(t, server, "missing.syd.prod.company.com.",
+		dnsmessage.TypeA)
+	if response.RCode != dnsmessage.RCodeNameError {
+		t.Errorf("expected NXDOMAIN, got: %s", response.RCode)
+	}
+	response = query(t, server, "9.2.20.10.in-addr.arpa.", dnsmessage.TypePTR)
+	if response.RCode != dnsmessage.RCodeNameError {
+		t.Errorf("expected NXDOMAIN for reverse, got: %s", response.RCode)
+	}
+	response = query(t, server, "www.example.com.", dnsmessage.TypeA)
+	if response.RCode != dnsmessage.RCodeRefused {
+		t.Errorf("expected REFUSED, got: %s", response.RCode)
+	}
+	response = query(t, server, "syd.prod.company.com.", dnsmessage.TypeSOA)
+	if len(response.Answers) != 1 {
+		t.Errorf("expected 1 SOA answer, got: %d", len(response.Answers))
+	}
+	response = query(t, server, "hyper1.mgmt.company.com.", dnsmessage.TypeA)
+	if response.RCode != dnsmessage.RCodeSuccess || len(response.Answers) != 1 {
+		t.Errorf("expected machine address, got: %v", response)
+	}
+	response = query(t, server, "10.5.168.192.in-addr.arpa.",
+		dnsmessage.TypePTR)
+	if len(response.Answers) != 1 {
+		t.Errorf("expected 1 machine PTR answer, got: %v", response)
+	} else {
+		body := response.Answers[0].Body.(*dnsmessage.PTRResource)
+		if name := body.PTR.String(); name != "hyper1.mgmt.company.com." {
+			t.Errorf("bad machine PTR: %s", name)
+		}
+	}
+	response = query(t, server, "missing.mgmt.company.com.", dnsmessage.TypeA)
+	if response.RCode != dnsmessage.RCodeNameError {
+		t.Errorf("expected NXDOMAIN for machine domain, got: %s",
+			response.RCode)
+	}
+	response = query(t, server, "company.com.", dnsmessage.TypeSOA)
+	if response.RCode != dnsmessage.RCodeRefused {
+		t.Errorf("expected REFUSED for parent domain, got: %s",
+			response.RCode)
+	}
+}
+
+func TestHostileVmHostnames(t *testing.T) {
+	subnets := []*hyper_proto.Subnet{
+		{
+			Id:         "Production",
+			IpGateway:  net.ParseIP("10.20.0.1"),
+			IpMask:     net.ParseIP("255.255.252.0"),
+			DomainName: "syd.prod.company.com",
+		},
+		{
+			Id:         "Test",
+			IpGateway:  net.ParseIP("10.30.0.1"),
+			IpMask:     net.ParseIP("255.255.252.0"),
+			DomainName: "syd.test.company.com",
+		},
+	}
+	machines := map[string]*fm_proto.Machine{
+		"row00-rack0.syd.prod.company.com": {
+			NetworkEntry: fm_proto.NetworkEntry{
+				Hostname:      "row00-rack0.syd.prod.company.com",
+				HostIpAddress: net.ParseIP("10.20.0.2"),
+			},
+		},
+	}
+	makeVm := func(ipAddr, hostname, subnetId string) *vmType {
+		return &vmType{
+			addresses: []hyper_proto.Address{
+				{IpAddress: net.ParseIP(ipAddr)}},
+			hostname: hostname,
+			subnetId: subnetId,
+		}
+	}
+	vms := map[string]*vmType{
+		"10.20.1.1": makeVm("10.20.1.1", "row00-rack0.syd.prod.company.com",
+			""),
+		"10.20.1.2": makeVm("10.20.1.2", "row00-rack0", "Production"),
+		"10.20.1.3": makeVm("10.20.1.3", "www.example.com", ""),
+		"10.20.1.4": makeVm("10.20.1.4", "bad_name", ""),
+		"10.20.1.5": makeVm("10.20.1.5", "dup", ""),
+		"10.20.1.6": makeVm("10.20.1.6", "dup", ""),
+		"10.20.1.7": makeVm("10.20.1.7", "-web", ""),
+		"10.30.1.8": makeVm("10.30.1.8", "Web", "Test"),
+	}
+	records := makeRecords(subnets, machines, vms, 1)
+	expected := map[string]string{
+		"row00-rack0.syd.prod.company.com.": "10.20.0.2",
+		"web.syd.test.company.com.":         "10.30.1.8",
+	}
+	if len(records.addresses) != len(expected) {
+		t.Errorf("unexpected names: %v", records.addresses)
+	}
+	for name, ipAddr := range expected {
+		addresses := records.addresses[name]
+		if len(addresses) != 1 || !addresses[0].Equal(net.ParseIP(ipAddr)) {
+			t.Errorf("%s: addresses: %v, expected: %s",
+				name, addresses, ipAddr)
+		}
+	}
+	for _, ipAddr := range []string{"10.20.1.1", "10.20.1.2", "10.20.1.5"} {
+		reverseName := makeReverseName(net.ParseIP(ipAddr), 128)
+		if names := records.names[reverseName]; len(names) > 0 {
+			t.Errorf("%s: unexpected reverse names: %v", ipAddr, names)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+package dnsd
+
+import (
+	"bytes"
+	"net"
+	"strconv"
+	"strings"
+
+	fm_proto "github.com/Symantec/Dominator/proto/fleetmanager"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const hexDigits = "0123456789abcdef"
+
+// canonicaliseName returns the name in lower case with a trailing dot.
+func canonicaliseName(name string) string {
+	name = strings.ToLower(name)
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	return name
+}
+
+// getMachineNetwork returns the network assumed for a machine address which is
+// not in any subnet: a /24 for IPv4 or a /64 for IPv6.
+func getMachineNetwork(ipAddr net.IP) net.IPNet {
+	if ip4 := ipAddr.To4(); ip4 != nil {
+		mask := net.CIDRMask(24, 32)
+		return net.IPNet{IP: ip4.Mask(mask), Mask: mask}
+	}
+	mask := net.CIDRMask(64, 128)
+	return net.IPNet{IP: ipAddr.To16().Mask(mask), Mask: mask}
+}
+
+func getSubnetNetworks(subnet *hyper_proto.Subnet) []net.IPNet {
+	var networks []net.IPNet
+	if ip4 := subnet.IpGateway.To4(); ip4 != nil {
+		if mask := net.IPMask(subnet.IpMask.To4()); mask != nil {
+			networks = append(networks,
+				net.IPNet{IP: ip4.Mask(mask), Mask: mask})
+		}
+	}
+	if subnet.HaveIpv6() {
+		mask := net.IPMask(subnet.Ipv6Mask.To16())
+		networks = append(networks,
+			net.IPNet{IP: subnet.Ipv6Gateway.To16().Mask(mask), Mask: mask})
+	}
+	return networks
+}
+
+// getReverseZones returns the names of the reverse zones which cover the
+// network. Zones are delegated on octet (IPv4) or nibble (IPv6) boundaries, so
+// a network with an unaligned prefix is covered by several smaller zones.
+func getReverseZones(network net.IPNet) []string {
+	ones, bits := network.Mask.Size()
+	step := 8
+	if bits == 128 {
+		step = 4
+	}
+	aligned := (ones + step - 1) / step * step
+	if aligned < step {
+		return nil
+	}
+	zones := make([]string, 0, 1<<uint(aligned-ones))
+	for index := 0; index < 1<<uint(aligned-ones); index++ {
+		ip := make(net.IP, len(network.IP))
+		copy(ip, network.IP)
+		ip[(aligned-1)/8] |= byte(index) << uint(7-(aligned-1)%8)
+		zones = append(zones, makeReverseName(ip, aligned))
+	}
+	return zones
+}
+
+// makeReverseName returns the in-addr.arpa or ip6.arpa name for the first
+// prefixLength bits of the IP address. Use a prefixLength of 128 for the name
+// of a complete address.
+func makeReverseName(ipAddr net.IP, prefixLength int) string {
+	var labels []string
+	suffix := "ip6.arpa."
+	if ip4 := ipAddr.To4(); ip4 != nil {
+		for index := 0; index < 4 && index*8 < prefixLength; index++ {
+			labels = append(labels, strconv.Itoa(int(ip4[index])))
+		}
+		suffix = "in-addr.arpa."
+	} else {
+		ip6 := ipAddr.To16()
+		for index := 0; index < 32 && index*4 < prefixLength; index++ {
+			nibble := ip6[index/2] >> 4
+			if index%2 == 1 {
+				nibble = ip6[index/2] & 0x0f
+			}
+			labels = append(labels, hexDigits[nibble:nibble+1])
+		}
+	}
+	buffer := &bytes.Buffer{}
+	for index := len(labels) - 1; index >= 0; index-- {
+		buffer.WriteString(labels[index])
+		buffer.WriteByte('.')
+	}
+	buffer.WriteString(suffix)
+	return buffer.String()
+}
+
+// isValidLabel returns true if the name is a single DNS label: letters,
+// digits and hyphens, not starting or ending with a hyphen.
+func isValidLabel(name string) bool {
+	if len(name) < 1 || len(name) > 63 {
+		return false
+	}
+	if name[0] == '-' || name[len(name)-1] == '-' {
+		return false
+	}
+	for _, ch := range name {
+		switch {
+		case ch >= 'a' && ch <= 'z':
+		case ch >= 'A' && ch <= 'Z':
+		case ch >= '0' && ch <= '9':
+		case ch == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// makeRecords builds the lookup tables for the machines and VMs. Machine names
+// which are not fully qualified are qualified with the domain name of the
+// subnet containing the address. Machines outside the zones of the subnets
+// add zones for the parent domain of the name and the reverse zones of the
+// assumed network of the address. VM names must be a single label, which is
+// qualified with the domain name of the subnet of the VM. VMs are never
+// published under names used by machines, and names claimed by several VMs
+// are not published.
+func makeRecords(subnets []*hyper_proto.Subnet,
+	machines map[string]*fm_proto.Machine, vms map[string]*vmType,
+	serial uint32) *recordsType {
+	records := &recordsType{
+		addresses: make(map[string][]net.IP),
+		names:     make(map[string][]string),
+		serial:    serial,
+		zones:     make(map[string]struct{}),
+	}
+	var networks []net.IPNet
+	var networkDomains []string
+	subnetDomains := make(map[string]string, len(subnets)) // Key: subnet ID.
+	for _, subnet := range subnets {
+		if subnet.DomainName != "" {
+			records.zones[canonicaliseName(subnet.DomainName)] = struct{}{}
+		}
+		subnetDomains[subnet.Id] = subnet.DomainName
+		for _, network := range getSubnetNetworks(subnet) {
+			networks = append(networks, network)
+			networkDomains = append(networkDomains, subnet.DomainName)
+			for _, zone := range getReverseZones(network) {
+				records.zones[zone] = struct{}{}
+			}
+		}
+	}
+	getNetworkDomain := func(ipAddr net.IP) string {
+		for index, network := range networks {
+			if network.Contains(ipAddr) {
+				return networkDomains[index]
+			}
+		}
+		return ""
+	}
+	qualify := func(name string, ipAddr net.IP) string {
+		if name == "" || len(ipAddr) < 1 {
+			return ""
+		}
+		if strings.Contains(strings.TrimSuffix(name, "."), ".") {
+			return canonicaliseName(name)
+		}
+		if domain := getNetworkDomain(ipAddr); domain != "" {
+			return canonicaliseName(name + "." + domain)
+		}
+		return ""
+	}
+	// VMs without a subnet ID are on the default subnet of their Hypervisor,
+	// which is found from the address.
+	qualifyVm := func(vm *vmType) string {
+		if !isValidLabel(vm.hostname) {
+			return ""
+		}
+		var domain string
+		if vm.subnetId == "" {
+			domain = getNetworkDomain(vm.addresses[0].IpAddress)
+		} else {
+			domain = subnetDomains[vm.subnetId]
+		}
+		if domain == "" {
+			return ""
+		}
+		return canonicaliseName(vm.hostname + "." + domain)
+	}
+	addReverse := func(ipAddr net.IP, name string) {
+		if name == "" || len(ipAddr) < 1 {
+			return
+		}
+		reverseName := makeReverseName(ipAddr, 128)
+		records.names[reverseName] = append(records.names[reverseName], name)
+	}
+	add := func(name string, ipAddr net.IP) {
+		if name == "" || len(ipAddr) < 1 {
+			return
+		}
+		if ip4 := ipAddr.To4(); ip4 != nil {
+			ipAddr = ip4
+		}
+		records.addresses[name] = append(records.addresses[name], ipAddr)
+		addReverse(ipAddr, name)
+	}
+	// Machine zones are only added if not covered by subnet zones, so they are
+	// collected separately to be independent of the order of the machines.
+	machineZones := make(map[string]struct{})
+	addMachineZones := func(name string, ipAddr net.IP) {
+		if name == "" || len(ipAddr) < 1 {
+			return
+		}
+		if records.findZone(name) == "" {
+			index := strings.IndexByte(name, '.')
+			if domain := name[index+1:]; strings.Count(domain, ".") > 1 {
+				machineZones[domain] = struct{}{}
+			}
+		}
+		if records.findZone(makeReverseName(ipAddr, 128)) == "" {
+			for _, zone := range getReverseZones(getMachineNetwork(ipAddr)) {
+				machineZones[zone] = struct{}{}
+			}
+		}
+	}
+	for _, machine := range machines {
+		entries := append([]fm_proto.NetworkEntry{machine.NetworkEntry,
+			machine.IPMI}, machine.SecondaryNetworkEntries...)
+		for _, entry := range entries {
+			name := qualify(entry.Hostname, entry.HostIpAddress)
+			addMachineZones(name, entry.HostIpAddress)
+			add(name, entry.HostIpAddress)
+		}
+	}
+	for zone := range machineZones {
+		records.zones[zone] = struct{}{}
+	}
+	vmNames := make(map[*vmType]string, len(vms))
+	vmNameCounts := make(map[string]uint)
+	for _, vm := range vms {
+		if len(vm.addresses) < 1 {
+			continue
+		}
+		if name := qualifyVm(vm); name != "" {
+			vmNames[vm] = name
+			vmNameCounts[name]++
+		}
+	}
+	// Only the primary address of a VM is published under its name. The
+	// secondary addresses map back to that name.
+	for vm, name := range vmNames {
+		if _, ok := records.addresses[name]; ok || vmNameCounts[name] > 1 {
+			continue
+		}
+		primary := vm.addresses[0]
+		add(name, primary.IpAddress)
+		add(name, primary.Ipv6Address)
+		for _, address := range vm.addresses[1:] {
+			addReverse(address.IpAddress, name)
+			addReverse(address.Ipv6Address, name)
+		}
+	}
+	return records
+}
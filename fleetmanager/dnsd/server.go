@@ -0,0 +1,112 @@
+package dnsd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/Symantec/Dominator/fleetmanager/hypervisors"
+	"github.com/Symantec/Dominator/lib/log"
+	fm_proto "github.com/Symantec/Dominator/proto/fleetmanager"
+)
+
+const (
+	tcpIdleTimeout      = time.Second * 10
+	updateRetryInterval = time.Minute
+)
+
+func startServer(portNum uint, hypervisorsManager *hypervisors.Manager,
+	logger log.DebugLogger) (*Server, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	address := fmt.Sprintf(":%d", portNum)
+	udpConn, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	tcpListener, err := net.Listen("tcp", address)
+	if err != nil {
+		udpConn.Close()
+		return nil, err
+	}
+	s := &Server{
+		hostname: canonicaliseName(hostname),
+		logger:   logger,
+		machines: make(map[string]*fm_proto.Machine),
+		vms:      make(map[string]*vmType),
+	}
+	s.rebuildRecords()
+	go s.serveUdp(udpConn)
+	go s.serveTcp(tcpListener)
+	go s.updateLoop(hypervisorsManager)
+	return s, nil
+}
+
+func (s *Server) handleTcpConnection(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		conn.SetDeadline(time.Now().Add(tcpIdleTimeout))
+		var length uint16
+		if err := binary.Read(reader, binary.BigEndian, &length); err != nil {
+			if err != io.EOF {
+				s.logger.Debugf(0, "error reading DNS query from: %s: %s\n",
+					conn.RemoteAddr(), err)
+			}
+			return
+		}
+		query := make([]byte, length)
+		if _, err := io.ReadFull(reader, query); err != nil {
+			s.logger.Debugf(0, "error reading DNS query from: %s: %s\n",
+				conn.RemoteAddr(), err)
+			return
+		}
+		response := s.handleQuery(query, 65535)
+		if response == nil {
+			return
+		}
+		buffer := make([]byte, 2, len(response)+2)
+		binary.BigEndian.PutUint16(buffer, uint16(len(response)))
+		if _, err := conn.Write(append(buffer, response...)); err != nil {
+			s.logger.Debugf(0, "error writing DNS response to: %s: %s\n",
+				conn.RemoteAddr(), err)
+			return
+		}
+	}
+}
+
+func (s *Server) serveTcp(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			s.logger.Println(err)
+			continue
+		}
+		go s.handleTcpConnection(conn)
+	}
+}
+
+func (s *Server) serveUdp(conn net.PacketConn) {
+	buffer := make([]byte, 65535)
+	for {
+		length, address, err := conn.ReadFrom(buffer)
+		if err != nil {
+			s.logger.Println(err)
+			continue
+		}
+		response := s.handleQuery(buffer[:length], maxUdpMessageLength)
+		if response == nil {
+			continue
+		}
+		if _, err := conn.WriteTo(response, address); err != nil {
+			s.logger.Debugf(0, "error writing DNS response to: %s: %s\n",
+				address, err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package dnsd
+
+import (
+	"net"
+	"time"
+
+	"github.com/Symantec/Dominator/fleetmanager/hypervisors"
+	"github.com/Symantec/Dominator/fleetmanager/topology"
+	fm_proto "github.com/Symantec/Dominator/proto/fleetmanager"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (s *Server) processUpdate(update fm_proto.Update) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, machine := range update.ChangedMachines {
+		s.machines[machine.Hostname] = machine
+	}
+	for _, hostname := range update.DeletedMachines {
+		delete(s.machines, hostname)
+	}
+	for ipAddr, vmInfo := range update.ChangedVMs {
+		primaryAddress := vmInfo.Address
+		primaryAddress.IpAddress = net.ParseIP(ipAddr)
+		if primaryAddress.IpAddress == nil {
+			continue
+		}
+		s.vms[ipAddr] = &vmType{
+			addresses: append([]hyper_proto.Address{primaryAddress},
+				vmInfo.SecondaryAddresses...),
+			hostname: vmInfo.GetLocalHostname(primaryAddress.IpAddress),
+			subnetId: vmInfo.SubnetId,
+		}
+	}
+	for _, ipAddr := range update.DeletedVMs {
+		delete(s.vms, ipAddr)
+	}
+	s.rebuildRecords()
+}
+
+// rebuildRecords replaces the lookup tables. The lock must be held.
+func (s *Server) rebuildRecords() {
+	serial := uint32(time.Now().Unix())
+	if s.records != nil && serial <= s.records.serial {
+		serial = s.records.serial + 1
+	}
+	s.records = makeRecords(s.subnets, s.machines, s.vms, serial)
+}
+
+// clearRecords removes the records for the machines and VMs.
+func (s *Server) clearRecords() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.machines = make(map[string]*fm_proto.Machine)
+	s.vms = make(map[string]*vmType)
+	s.rebuildRecords()
+}
+
+// updateLoop processes updates for the machines and VMs. If there is an error
+// the records for the machines and VMs are cleared, since they can no longer
+// be kept current, and new updates are requested after a delay.
+func (s *Server) updateLoop(hypervisorsManager *hypervisors.Manager) {
+	for ; ; time.Sleep(updateRetryInterval) {
+		updateChannel := hypervisorsManager.MakeUpdateChannel("")
+		for update := range updateChannel {
+			if update.Error != "" {
+				s.logger.Printf(
+					"not serving DNS records for machines and VMs: %s\n",
+					update.Error)
+				hypervisorsManager.CloseUpdateChannel(updateChannel)
+				break
+			}
+			s.processUpdate(update)
+		}
+		s.clearRecords()
+	}
+}
+
+func (s *Server) updateTopology(t *topology.Topology) {
+	var subnets []*hyper_proto.Subnet
+	t.Walk(func(directory *topology.Directory) error {
+		for _, subnet := range directory.Subnets {
+			subnets = append(subnets, &subnet.Subnet)
+		}
+		return nil
+	})
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.subnets = subnets
+	s.rebuildRecords()
+}
@@ -0,0 +1,62 @@
+package dnsd
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+	fm_proto "github.com/Symantec/Dominator/proto/fleetmanager"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func TestProcessUpdate(t *testing.T) {
+	server := &Server{
+		hostname: "fleet-manager.company.com.",
+		logger:   testlogger.New(t),
+		machines: make(map[string]*fm_proto.Machine),
+		subnets: []*hyper_proto.Subnet{{
+			Id:         "Production",
+			IpGateway:  net.ParseIP("10.20.0.1"),
+			IpMask:     net.ParseIP("255.255.252.0"),
+			DomainName: "syd.prod.company.com",
+		}},
+		vms: make(map[string]*vmType),
+	}
+	server.processUpdate(fm_proto.Update{
+		ChangedMachines: []*fm_proto.Machine{{
+			NetworkEntry: fm_proto.NetworkEntry{
+				Hostname:      "row00-rack0",
+				HostIpAddress: net.ParseIP("10.20.0.2"),
+			},
+		}},
+		ChangedVMs: map[string]*hyper_proto.VmInfo{
+			"10.20.1.5": {Hostname: "web"},
+			"10.20.1.6": {},
+		},
+	})
+	for _, name := range []string{
+		"row00-rack0.syd.prod.company.com.",
+		"web.syd.prod.company.com.",
+		"ip-10-20-1-6.syd.prod.company.com.",
+	} {
+		if _, ok := server.records.addresses[name]; !ok {
+			t.Errorf("%s: not published", name)
+		}
+	}
+	serial := server.records.serial
+	server.processUpdate(fm_proto.Update{DeletedVMs: []string{"10.20.1.5"}})
+	if _, ok := server.records.addresses["web.syd.prod.company.com."]; ok {
+		t.Error("deleted VM still published")
+	}
+	if server.records.serial <= serial {
+		t.Errorf("serial: %d not increased from: %d",
+			server.records.serial, serial)
+	}
+	server.clearRecords()
+	if len(server.records.addresses) != 0 {
+		t.Errorf("records not cleared: %v", server.records.addresses)
+	}
+	if _, ok := server.records.zones["syd.prod.company.com."]; !ok {
+		t.Error("subnet zone not served after clearing records")
+	}
+}
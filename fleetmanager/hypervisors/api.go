@@ -25,13 +25,20 @@ const (
 	probeStatusOff
 )
 
+type cordonStorer interface {
+	ReadMachineCordoned(hypervisor net.IP) (bool, error)
+	WriteMachineCordoned(hypervisor net.IP, cordoned bool) error
+}
+
 type hypervisorType struct {
 	logger             log.DebugLogger
 	receiveChannel     chan struct{}
 	mutex              sync.RWMutex
 	cachedSerialNumber string
 	conn               *srpc.Conn
+	cordoned           bool
 	deleteScheduled    bool
+	firewallRequest    *hyper_proto.UpdateFirewallRequest // Last sent.
 	healthStatus       string
 	lastIpmiProbe      time.Time
 	localTags          tags.Tags
@@ -40,9 +47,14 @@ type hypervisorType struct {
 	migratingVms       map[string]*vmInfoType // Key: VM IP address.
 	ownerUsers         map[string]struct{}
 	probeStatus        probeStatus
+	quotasRequest      *hyper_proto.UpdateQuotasRequest // Last sent.
+	reservedVms        map[*hyper_proto.VmInfo]struct{} // Being created.
+	resources          *hyper_proto.Resources
 	serialNumber       string
 	subnets            []hyper_proto.Subnet
-	vms                map[string]*vmInfoType // Key: VM IP address.
+	vms                map[string]*vmInfoType          // Key: VM IP address.
+	vmsReported        bool                            // Manager lock.
+	vmUsage            map[string]*hyper_proto.VmUsage // Key: VM IP address.
 }
 
 type ipStorer interface {
@@ -58,20 +70,27 @@ type locationType struct {
 }
 
 type Manager struct {
-	invertTable      [256]byte
-	ipmiPasswordFile string
-	ipmiUsername     string
-	logger           log.DebugLogger
-	storer           Storer
-	mutex            sync.RWMutex               // Protect everything below.
-	allocatingIPs    map[string]struct{}        // Key: VM IP address.
-	hypervisors      map[string]*hypervisorType // Key: hypervisor machine name.
-	locations        map[string]*locationType   // Key: location.
-	migratingIPs     map[string]struct{}        // Key: VM IP address.
-	notifiers        map[<-chan fm_proto.Update]*locationType
-	topology         *topology.Topology
-	subnets          map[string]*subnetType // Key: Gateway IP.
-	vms              map[string]*vmInfoType // Key: VM IP address.
+	imageServerAddress string
+	invertTable        [256]byte
+	ipmiPasswordFile   string
+	ipmiUsername       string
+	logger             log.DebugLogger
+	startTime          time.Time
+	storer             Storer
+	firewallTrigger    chan<- struct{}
+	quotaTrigger       chan<- struct{}
+	vmGroupDialer      func(address string) (vmGroupHypervisor, error)
+	vmGroupTrigger     chan<- struct{}
+	mutex              sync.RWMutex               // Protect everything below.
+	allocatingIPs      map[string]struct{}        // Key: VM IP address.
+	hypervisors        map[string]*hypervisorType // Key: machine name.
+	locations          map[string]*locationType   // Key: location.
+	migratingIPs       map[string]struct{}        // Key: VM IP address.
+	notifiers          map[<-chan fm_proto.Update]*locationType
+	topology           *topology.Topology
+	subnets            map[string]*subnetType  // Key: Gateway IP.
+	vmGroups           map[string]*vmGroupType // Key: location/name.
+	vms                map[string]*vmInfoType  // Key: VM IP address.
 }
 
 type probeStatus uint
@@ -82,13 +101,15 @@ type serialStorer interface {
 }
 
 type StartOptions struct {
-	IpmiPasswordFile string
-	IpmiUsername     string
-	Logger           log.DebugLogger
-	Storer           Storer
+	ImageServerAddress string // Used to find the latest image for VM groups.
+	IpmiPasswordFile   string
+	IpmiUsername       string
+	Logger             log.DebugLogger
+	Storer             Storer
 }
 
 type Storer interface {
+	cordonStorer
 	ipStorer
 	serialStorer
 	tagsStorer
@@ -133,6 +154,14 @@ func (m *Manager) CloseUpdateChannel(channel <-chan fm_proto.Update) {
 	m.closeUpdateChannel(channel)
 }
 
+func (m *Manager) CreateVm(conn *srpc.Conn) error {
+	return m.createVm(conn)
+}
+
+func (m *Manager) DrainHypervisor(conn *srpc.Conn) error {
+	return m.drainHypervisor(conn)
+}
+
 func (m *Manager) GetHypervisorForVm(ipAddr net.IP) (string, error) {
 	return m.getHypervisorForVm(ipAddr)
 }
@@ -154,6 +183,11 @@ func (m *Manager) ListLocations(dirname string) ([]string, error) {
 	return m.listLocations(dirname)
 }
 
+func (m *Manager) ListQuotas(location string) (
+	[]hyper_proto.OwnerQuota, error) {
+	return m.listQuotas(location)
+}
+
 func (m *Manager) ListVMsInLocation(dirname string) ([]net.IP, error) {
 	return m.listVMsInLocation(dirname)
 }
@@ -166,6 +200,11 @@ func (m *Manager) MoveIpAddresses(hostname string, ipAddresses []net.IP) error {
 	return m.moveIpAddresses(hostname, ipAddresses)
 }
 
+func (m *Manager) SetMachineCordon(hostname string,
+	authInfo *srpc.AuthInformation, cordoned bool) error {
+	return m.setMachineCordon(hostname, authInfo, cordoned)
+}
+
 func (m *Manager) WriteHtml(writer io.Writer) {
 	m.writeHtml(writer)
 }
@@ -0,0 +1,120 @@
+package hypervisors
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/constants"
+	"github.com/Symantec/Dominator/lib/srpc"
+	fm_proto "github.com/Symantec/Dominator/proto/fleetmanager"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func sendCreateVmError(conn *srpc.Conn, err error) error {
+	response := fm_proto.CreateVmResponse{}
+	response.Error = err.Error()
+	return conn.Encode(response)
+}
+
+func (m *Manager) createVm(conn *srpc.Conn) error {
+	var request fm_proto.CreateVmRequest
+	if err := conn.Decode(&request); err != nil {
+		return err
+	}
+	dataSize := int64(request.ImageDataSize + request.UserDataSize)
+	if request.DryRun {
+		dataSize = 0
+	}
+	authInfo := conn.GetAuthInformation()
+	if authInfo.Username == "" {
+		if _, err := io.CopyN(ioutil.Discard, conn, dataSize); err != nil {
+			return err
+		}
+		return sendCreateVmError(conn, errors.New("no authentication data"))
+	}
+	hypervisor, reason, release, err := m.placeVm(request, authInfo)
+	if err == nil {
+		defer release()
+		quota := makePlacementRequest(request, authInfo.Username).makeQuota()
+		err = m.checkQuotas(hypervisor, authInfo.Username, request.OwnerGroups,
+			quota)
+	}
+	if err != nil {
+		if _, err := io.CopyN(ioutil.Discard, conn, dataSize); err != nil {
+			return err
+		}
+		return sendCreateVmError(conn, err)
+	}
+	address := fmt.Sprintf("%s:%d", hypervisor.machine.Hostname,
+		constants.HypervisorPortNumber)
+	m.logger.Debugf(0, "CreateVm(%s): placing on: %s: %s\n",
+		authInfo.Username, address, reason)
+	response := fm_proto.CreateVmResponse{
+		HypervisorAddress: address,
+		PlacementReason:   reason,
+	}
+	if request.DryRun {
+		response.Final = true
+		return conn.Encode(response)
+	}
+	response.ProgressMessage = "creating VM on: " + address
+	if err := conn.Encode(response); err != nil {
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	client, err := srpc.DialHTTP("tcp", address, time.Second*15)
+	if err != nil {
+		if _, err := io.CopyN(ioutil.Discard, conn, dataSize); err != nil {
+			return err
+		}
+		return sendCreateVmError(conn, err)
+	}
+	defer client.Close()
+	hyperConn, err := client.Call("Hypervisor.CreateVm")
+	if err != nil {
+		if _, err := io.CopyN(ioutil.Discard, conn, dataSize); err != nil {
+			return err
+		}
+		return sendCreateVmError(conn, err)
+	}
+	defer hyperConn.Close()
+	hyperRequest := request.CreateVmRequest
+	hyperRequest.OnBehalfOfUser = authInfo.Username
+	for group := range authInfo.GroupList {
+		hyperRequest.OnBehalfOfGroups = append(hyperRequest.OnBehalfOfGroups,
+			group)
+	}
+	if err := hyperConn.Encode(hyperRequest); err != nil {
+		return err
+	}
+	if _, err := io.CopyN(hyperConn, conn, dataSize); err != nil {
+		return err
+	}
+	if err := hyperConn.Flush(); err != nil {
+		return err
+	}
+	for {
+		var hyperResponse hyper_proto.CreateVmResponse
+		if err := hyperConn.Decode(&hyperResponse); err != nil {
+			return sendCreateVmError(conn, err)
+		}
+		response := fm_proto.CreateVmResponse{CreateVmResponse: hyperResponse}
+		if hyperResponse.Final {
+			response.HypervisorAddress = address
+		}
+		if err := conn.Encode(response); err != nil {
+			return err
+		}
+		if hyperResponse.Final || hyperResponse.Error != "" {
+			return nil
+		}
+		if err := conn.Flush(); err != nil {
+			return err
+		}
+	}
+}
@@ -3,8 +3,13 @@ package hypervisors
 import (
 	"fmt"
 	"io"
+	"time"
+
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
 )
 
+const idleVmReportThreshold = time.Hour * 24
+
 func (m *Manager) writeHtml(writer io.Writer) {
 	t, err := m.getTopology()
 	if err != nil {
@@ -19,9 +24,12 @@ func (m *Manager) writeHtml(writer io.Writer) {
 			`<font color="grey">Hypervisors are not being managed by this instance</font><br>`)
 	}
 	numMachines := t.GetNumMachines()
-	var numConnected, numOff, numOK uint
+	var numConnected, numCordoned, numOff, numOK uint
 	m.mutex.RLock()
 	for _, hypervisor := range m.hypervisors {
+		if hypervisor.cordoned {
+			numCordoned++
+		}
 		switch hypervisor.probeStatus {
 		case probeStatusConnected:
 			numConnected++
@@ -35,6 +43,14 @@ func (m *Manager) writeHtml(writer io.Writer) {
 	}
 	numVMs := uint(len(m.vms))
 	m.mutex.RUnlock()
+	var numIdleVMs uint
+	for _, vm := range m.getVMs(false) {
+		if usage := vm.getUsage(); usage != nil &&
+			!usage.IdleSince.IsZero() &&
+			time.Since(usage.IdleSince) >= idleVmReportThreshold {
+			numIdleVMs++
+		}
+	}
 	writeCountLinksHT(writer, "Number of hypervisors known",
 		"listHypervisors?state=", numMachines)
 	writeCountLinksHT(writer, "Number of hypervisors powered off",
@@ -43,9 +59,42 @@ func (m *Manager) writeHtml(writer io.Writer) {
 		"listHypervisors?state=connected", numConnected)
 	writeCountLinksHT(writer, "Number of hypervisors OK",
 		"listHypervisors?state=OK", numOK)
+	writeCountLinksHT(writer, "Number of hypervisors cordoned",
+		"listHypervisors?state=cordoned", numCordoned)
 	writeCountLinksHTJ(writer, "Number of VMs known",
 		"listVMs?", numVMs)
-	fmt.Fprintln(writer, `Hypervisor <a href="listLocations">locations</a><br>`)
+	writeCountLinksHT(writer, "Number of VMs idle for over a day",
+		"listVMs?idleFor=24h", numIdleVMs)
+	fmt.Fprintln(writer,
+		`Hypervisor <a href="listLocations">locations</a> (<a href="listLocationUsage">utilisation</a>)<br>`)
+	if quotas, err := m.listQuotas(""); err == nil && len(quotas) > 0 {
+		var numExhausted uint
+		for _, quota := range quotas {
+			err := quota.Limit.CheckAvailable(quota.Used, hyper_proto.Quota{
+				MemoryInMiB: 1,
+				MilliCPUs:   1,
+				NumVMs:      1,
+				VolumeBytes: 1,
+			})
+			if err != nil {
+				numExhausted++
+			}
+		}
+		fmt.Fprintf(writer,
+			"<a href=\"listQuotas\">Quotas</a>: %d defined, %d exhausted<br>\n",
+			len(quotas), numExhausted)
+	}
+	if groups := m.listVmGroups(""); len(groups) > 0 {
+		var numDegraded uint
+		for _, group := range groups {
+			if group.Available < group.Replicas {
+				numDegraded++
+			}
+		}
+		fmt.Fprintf(writer,
+			"<a href=\"listVmGroups\">VM groups</a>: %d defined, %d degraded<br>\n",
+			len(groups), numDegraded)
+	}
 }
 
 func writeCountLinksHT(writer io.Writer, text, path string, count uint) {
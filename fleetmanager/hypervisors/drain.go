@@ -0,0 +1,293 @@
+package hypervisors
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	hyper_client "github.com/Symantec/Dominator/hypervisor/client"
+	"github.com/Symantec/Dominator/lib/constants"
+	"github.com/Symantec/Dominator/lib/srpc"
+	fm_proto "github.com/Symantec/Dominator/proto/fleetmanager"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const defaultMaxParallelMigrations = 2
+
+type drainMigration struct {
+	destination string // host:port
+	ipAddr      net.IP
+}
+
+func sendDrainError(conn *srpc.Conn, err error) error {
+	return conn.Encode(fm_proto.DrainHypervisorResponse{Error: err.Error()})
+}
+
+// cordonHypervisor will change and persist the cordon state. The Hypervisor
+// lock must be held for writing.
+func (m *Manager) cordonHypervisor(h *hypervisorType, cordoned bool) error {
+	if h.cordoned == cordoned {
+		return nil
+	}
+	err := m.storer.WriteMachineCordoned(h.machine.HostIpAddress, cordoned)
+	if err != nil {
+		return err
+	}
+	h.cordoned = cordoned
+	if cordoned {
+		h.logger.Println("cordoned")
+	} else {
+		h.logger.Println("uncordoned")
+	}
+	return nil
+}
+
+func (m *Manager) drainHypervisor(conn *srpc.Conn) error {
+	var request fm_proto.DrainHypervisorRequest
+	if err := conn.Decode(&request); err != nil {
+		return err
+	}
+	if !*manageHypervisors {
+		return sendDrainError(conn,
+			errors.New("this is a read-only Fleet Manager"))
+	}
+	h, err := m.getLockedHypervisor(request.Hostname, true)
+	if err != nil {
+		return sendDrainError(conn, err)
+	}
+	if err := m.cordonHypervisor(h, true); err != nil {
+		h.mutex.Unlock()
+		return sendDrainError(conn, err)
+	}
+	location := h.location
+	sourceAddress := fmt.Sprintf("%s:%d", h.machine.Hostname,
+		constants.HypervisorPortNumber)
+	vms := make([]hyper_proto.VmInfo, 0, len(h.vms))
+	for _, vm := range h.vms {
+		vms = append(vms, vm.VmInfo)
+	}
+	h.mutex.Unlock()
+	sort.Slice(vms, func(i, j int) bool {
+		return bytes.Compare(vms[i].Address.IpAddress,
+			vms[j].Address.IpAddress) < 0
+	})
+	migrations, responses := m.planDrain(location, vms,
+		conn.GetAuthInformation())
+	numFailed := uint(len(responses))
+	for _, response := range responses {
+		if err := conn.Encode(response); err != nil {
+			return err
+		}
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	maxParallel := request.MaxParallel
+	if maxParallel < 1 {
+		maxParallel = defaultMaxParallelMigrations
+	}
+	migrationChannel := make(chan drainMigration, len(migrations))
+	for _, migration := range migrations {
+		migrationChannel <- migration
+	}
+	close(migrationChannel)
+	responseChannel := make(chan fm_proto.DrainHypervisorResponse, 16)
+	var waitGroup sync.WaitGroup
+	for count := uint(0); count < maxParallel; count++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for migration := range migrationChannel {
+				m.drainVm(sourceAddress, migration, responseChannel)
+			}
+		}()
+	}
+	go func() {
+		waitGroup.Wait()
+		close(responseChannel)
+	}()
+	// Keep consuming responses if the client goes away so that the migrations
+	// are not blocked.
+	var connErr error
+	for response := range responseChannel {
+		if response.VmError != "" {
+			numFailed++
+		}
+		if connErr != nil {
+			continue
+		}
+		if connErr = conn.Encode(response); connErr == nil {
+			connErr = conn.Flush()
+		}
+	}
+	h.logger.Printf("drained: %d of %d VMs failed to migrate\n",
+		numFailed, len(vms))
+	if connErr != nil {
+		return connErr
+	}
+	response := fm_proto.DrainHypervisorResponse{Final: true}
+	if numFailed > 0 {
+		response.Error = fmt.Sprintf("%d of %d VMs failed to migrate",
+			numFailed, len(vms))
+	}
+	return conn.Encode(response)
+}
+
+// drainVm will migrate a VM, sending progress and errors to responses.
+func (m *Manager) drainVm(sourceAddress string, migration drainMigration,
+	responses chan<- fm_proto.DrainHypervisorResponse) {
+	responses <- fm_proto.DrainHypervisorResponse{
+		ProgressMessage: "migrating to: " + migration.destination,
+		VmIpAddress:     migration.ipAddr,
+	}
+	err := m.migrateVm(sourceAddress, migration.destination, migration.ipAddr,
+		func(message string) {
+			responses <- fm_proto.DrainHypervisorResponse{
+				ProgressMessage: message,
+				VmIpAddress:     migration.ipAddr,
+			}
+		})
+	if err != nil {
+		m.logger.Printf("error migrating VM: %s to %s: %s\n",
+			migration.ipAddr, migration.destination, err)
+		responses <- fm_proto.DrainHypervisorResponse{
+			VmError:     err.Error(),
+			VmIpAddress: migration.ipAddr,
+		}
+		return
+	}
+	responses <- fm_proto.DrainHypervisorResponse{
+		ProgressMessage: "migrated to: " + migration.destination,
+		VmIpAddress:     migration.ipAddr,
+	}
+}
+
+// migrateVm will ask the destination Hypervisor to migrate a VM from the
+// source Hypervisor, committing the migration once it is ready.
+func (m *Manager) migrateVm(sourceAddress, destinationAddress string,
+	ipAddr net.IP, progress func(string)) error {
+	sourceClient, err := srpc.DialHTTP("tcp", sourceAddress, time.Second*15)
+	if err != nil {
+		return err
+	}
+	defer sourceClient.Close()
+	accessToken, err := hyper_client.GetVmAccessToken(sourceClient, ipAddr,
+		time.Hour)
+	if err != nil {
+		return err
+	}
+	defer hyper_client.DiscardVmAccessToken(sourceClient, ipAddr, accessToken)
+	destinationClient, err := srpc.DialHTTP("tcp", destinationAddress,
+		time.Second*15)
+	if err != nil {
+		return err
+	}
+	defer destinationClient.Close()
+	conn, err := destinationClient.Call("Hypervisor.MigrateVm")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	request := hyper_proto.MigrateVmRequest{
+		AccessToken:      accessToken,
+		IpAddress:        ipAddr,
+		Live:             true,
+		SourceHypervisor: sourceAddress,
+	}
+	if err := conn.Encode(request); err != nil {
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	for {
+		var reply hyper_proto.MigrateVmResponse
+		if err := conn.Decode(&reply); err != nil {
+			return err
+		}
+		if reply.Error != "" {
+			return errors.New(reply.Error)
+		}
+		if reply.ProgressMessage != "" {
+			progress(reply.ProgressMessage)
+		}
+		if reply.RequestCommit {
+			err := conn.Encode(hyper_proto.MigrateVmResponseResponse{
+				Commit: true,
+			})
+			if err != nil {
+				return err
+			}
+			if err := conn.Flush(); err != nil {
+				return err
+			}
+		}
+		if reply.Final {
+			return nil
+		}
+	}
+}
+
+// planDrain chooses a destination for each VM. VMs which cannot be migrated
+// are returned as error responses.
+func (m *Manager) planDrain(location string, vms []hyper_proto.VmInfo,
+	authInfo *srpc.AuthInformation) (
+	[]drainMigration, []fm_proto.DrainHypervisorResponse) {
+	var migrations []drainMigration
+	var failures []fm_proto.DrainHypervisorResponse
+	pending := make(map[string][]*hyper_proto.VmInfo)
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	for index := range vms {
+		vm := &vms[index]
+		ipAddr := vm.Address.IpAddress
+		if vm.State != hyper_proto.StateRunning &&
+			vm.State != hyper_proto.StateStopped {
+			failures = append(failures, fm_proto.DrainHypervisorResponse{
+				VmError:     "cannot migrate VM in state: " + vm.State.String(),
+				VmIpAddress: ipAddr,
+			})
+			continue
+		}
+		destination, reason, err := m.selectHypervisor(location,
+			makeSubnetIDs(vm.SubnetId, vm.SecondarySubnetIDs), authInfo,
+			makeVmPlacementRequest(*vm), pending)
+		if err != nil {
+			failures = append(failures, fm_proto.DrainHypervisorResponse{
+				VmError:     err.Error(),
+				VmIpAddress: ipAddr,
+			})
+			continue
+		}
+		hostname := destination.machine.Hostname
+		m.logger.Debugf(0, "drain: placing VM: %s on: %s: %s\n",
+			ipAddr, hostname, reason)
+		pending[hostname] = append(pending[hostname], vm)
+		migrations = append(migrations, drainMigration{
+			destination: fmt.Sprintf("%s:%d", hostname,
+				constants.HypervisorPortNumber),
+			ipAddr: ipAddr,
+		})
+	}
+	return migrations, failures
+}
+
+func (m *Manager) setMachineCordon(hostname string,
+	authInfo *srpc.AuthInformation, cordoned bool) error {
+	if !*manageHypervisors {
+		return errors.New("this is a read-only Fleet Manager")
+	}
+	h, err := m.getLockedHypervisor(hostname, true)
+	if err != nil {
+		return err
+	}
+	defer h.mutex.Unlock()
+	if err := h.checkAuth(authInfo); err != nil {
+		return err
+	}
+	return m.cordonHypervisor(h, cordoned)
+}
@@ -0,0 +1,90 @@
+package hypervisors
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/constants"
+	"github.com/Symantec/Dominator/lib/srpc"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func TestCordonHypervisor(t *testing.T) {
+	h := makeTestHypervisor(t, "h0", "10.1.0.1", 16384)
+	m := makeTestManager(t, h)
+	storer := m.storer.(*testStorer)
+	if err := m.cordonHypervisor(h, true); err != nil {
+		t.Fatal(err)
+	}
+	if !h.cordoned || !storer.cordoned["10.1.0.1"] {
+		t.Error("cordon not recorded and persisted")
+	}
+	storer.err = errors.New("write failed")
+	if err := m.cordonHypervisor(h, true); err != nil {
+		t.Errorf("error for unchanged cordon state: %s", err)
+	}
+	if err := m.cordonHypervisor(h, false); err == nil {
+		t.Error("no error for failed write")
+	}
+	if !h.cordoned {
+		t.Error("cordon changed after failed write")
+	}
+	storer.err = nil
+	if err := m.cordonHypervisor(h, false); err != nil {
+		t.Fatal(err)
+	}
+	if h.cordoned || storer.cordoned["10.1.0.1"] {
+		t.Error("uncordon not recorded and persisted")
+	}
+}
+
+func TestPlanDrain(t *testing.T) {
+	source := makeTestHypervisor(t, "h0", "10.1.0.1", 65536)
+	source.cordoned = true
+	small := makeTestHypervisor(t, "h1", "10.1.0.2", 8192)
+	large := makeTestHypervisor(t, "h2", "10.1.0.3", 16384)
+	large.vms["10.2.0.9"] = &vmInfoType{"10.2.0.9",
+		makeTestVmInfo("10.2.0.9", 4096, hyper_proto.StateRunning), large}
+	m := makeTestManager(t, source, small, large)
+	vms := []hyper_proto.VmInfo{
+		makeTestVmInfo("10.2.0.1", 8192, hyper_proto.StateRunning),
+		makeTestVmInfo("10.2.0.2", 8192, hyper_proto.StateStopped),
+		makeTestVmInfo("10.2.0.3", 1024, hyper_proto.StateStarting),
+		makeTestVmInfo("10.2.0.4", 8192, hyper_proto.StateRunning),
+	}
+	migrations, failures := m.planDrain("", vms,
+		&srpc.AuthInformation{HaveMethodAccess: true})
+	// The first two VMs fill the free space on both destinations (the
+	// cordoned source is never chosen), so the last VM does not fit.
+	destinations := make(map[string]string)
+	for _, migration := range migrations {
+		destinations[migration.ipAddr.String()] = migration.destination
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("migrations: %v", migrations)
+	}
+	if destinations["10.2.0.1"] == destinations["10.2.0.2"] {
+		t.Errorf("VMs placed on the same Hypervisor: %v", migrations)
+	}
+	allowed := map[string]struct{}{
+		fmt.Sprintf("h1:%d", constants.HypervisorPortNumber): {},
+		fmt.Sprintf("h2:%d", constants.HypervisorPortNumber): {},
+	}
+	for ipAddr, destination := range destinations {
+		if _, ok := allowed[destination]; !ok {
+			t.Errorf("VM: %s placed on: %s", ipAddr, destination)
+		}
+	}
+	if len(failures) != 2 {
+		t.Fatalf("failures: %v", failures)
+	}
+	if !failures[0].VmIpAddress.Equal(net.ParseIP("10.2.0.3")) {
+		t.Errorf("starting VM not rejected: %v", failures[0])
+	}
+	if !failures[1].VmIpAddress.Equal(net.ParseIP("10.2.0.4")) ||
+		failures[1].VmError == "" {
+		t.Errorf("VM which does not fit not rejected: %v", failures[1])
+	}
+}
@@ -0,0 +1,167 @@
+package hypervisors
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/constants"
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const firewallSettleTime = time.Second * 5
+
+func firewallRequestsEqual(left, right *hyper_proto.UpdateFirewallRequest) bool {
+	if left == nil || right == nil {
+		return false
+	}
+	if len(left.Groups) != len(right.Groups) {
+		return false
+	}
+	for name, leftGroup := range left.Groups {
+		if rightGroup, ok := right.Groups[name]; !ok {
+			return false
+		} else if !leftGroup.Equal(&rightGroup) {
+			return false
+		}
+	}
+	if len(left.Peers) != len(right.Peers) {
+		return false
+	}
+	for index, leftPeer := range left.Peers {
+		rightPeer := right.Peers[index]
+		if !leftPeer.IpAddress.Equal(rightPeer.IpAddress) {
+			return false
+		}
+		if !leftPeer.Tags.Equal(rightPeer.Tags) {
+			return false
+		}
+	}
+	return true
+}
+
+// firewallLoop will push the firewall rule groups and the tagged VMs to the
+// Hypervisors when they change.
+func (m *Manager) firewallLoop(triggerChannel <-chan struct{}) {
+	for range triggerChannel {
+		time.Sleep(firewallSettleTime) // Coalesce bursts of changes.
+		select {
+		case <-triggerChannel:
+		default:
+		}
+		m.updateFirewalls()
+	}
+}
+
+// getFirewallPeers returns the tagged VMs which are not on the Hypervisor,
+// sorted by IP address.
+func (m *Manager) getFirewallPeers(h *hypervisorType) []hyper_proto.FirewallPeer {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	var peers []hyper_proto.FirewallPeer
+	for _, vm := range m.vms {
+		if len(vm.Tags) < 1 || vm.hypervisor == h {
+			continue
+		}
+		addresses := append([]hyper_proto.Address{vm.Address},
+			vm.SecondaryAddresses...)
+		for _, address := range addresses {
+			for _, ipAddr := range []net.IP{address.IpAddress,
+				address.Ipv6Address} {
+				if len(ipAddr) > 0 {
+					peers = append(peers, hyper_proto.FirewallPeer{
+						IpAddress: ipAddr,
+						Tags:      vm.Tags,
+					})
+				}
+			}
+		}
+	}
+	sort.Slice(peers, func(i, j int) bool {
+		return peers[i].IpAddress.String() < peers[j].IpAddress.String()
+	})
+	return peers
+}
+
+// triggerFirewallUpdate will request that the Hypervisor firewalls are
+// updated. It does not block.
+func (m *Manager) triggerFirewallUpdate() {
+	if !*manageHypervisors {
+		return
+	}
+	select {
+	case m.firewallTrigger <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Manager) updateFirewalls() {
+	t, err := m.getTopology()
+	if err != nil {
+		return
+	}
+	var hypervisors []*hypervisorType
+	m.mutex.RLock()
+	for _, h := range m.hypervisors {
+		if h.probeStatus == probeStatusConnected {
+			hypervisors = append(hypervisors, h)
+		}
+	}
+	m.mutex.RUnlock()
+	var waitGroup sync.WaitGroup
+	for _, h := range hypervisors {
+		groups, err := t.GetFirewallGroupsForMachine(h.machine.Hostname)
+		if err != nil {
+			h.logger.Println(err)
+			continue
+		}
+		request := hyper_proto.UpdateFirewallRequest{
+			Groups: groups,
+			Peers:  m.getFirewallPeers(h),
+		}
+		h.mutex.RLock()
+		unchanged := firewallRequestsEqual(&request, h.firewallRequest)
+		h.mutex.RUnlock()
+		if unchanged {
+			continue
+		}
+		waitGroup.Add(1)
+		go func(h *hypervisorType) {
+			defer waitGroup.Done()
+			if err := h.updateFirewall(request); err != nil {
+				h.logger.Printf("error updating firewall: %s\n", err)
+			}
+		}(h)
+	}
+	waitGroup.Wait()
+}
+
+func (h *hypervisorType) updateFirewall(
+	request hyper_proto.UpdateFirewallRequest) error {
+	client, err := srpc.DialHTTP("tcp",
+		fmt.Sprintf("%s:%d",
+			h.machine.Hostname, constants.HypervisorPortNumber),
+		time.Minute)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	var reply hyper_proto.UpdateFirewallResponse
+	err = client.RequestReply("Hypervisor.UpdateFirewall", request, &reply)
+	if err == nil {
+		err = errors.New(reply.Error)
+	}
+	if err != nil {
+		return err
+	}
+	h.mutex.Lock()
+	h.firewallRequest = &request
+	h.mutex.Unlock()
+	h.logger.Debugf(0, "updated firewall: %d groups, %d peers\n",
+		len(request.Groups), len(request.Peers))
+	return nil
+}
@@ -57,6 +57,10 @@ func (s *Storer) ListVMs(hypervisor net.IP) ([]string, error) {
 	return s.listVMs(hypervisor)
 }
 
+func (s *Storer) ReadMachineCordoned(hypervisor net.IP) (bool, error) {
+	return s.readMachineCordoned(hypervisor)
+}
+
 func (s *Storer) ReadMachineSerialNumber(hypervisor net.IP) (string, error) {
 	return s.readMachineSerialNumber(hypervisor)
 }
@@ -79,6 +83,11 @@ func (s *Storer) UnregisterHypervisor(hypervisor net.IP) error {
 	return s.unregisterHypervisor(hypervisor)
 }
 
+func (s *Storer) WriteMachineCordoned(hypervisor net.IP,
+	cordoned bool) error {
+	return s.writeMachineCordoned(hypervisor, cordoned)
+}
+
 func (s *Storer) WriteMachineSerialNumber(hypervisor net.IP,
 	serialNumber string) error {
 	return s.writeMachineSerialNumber(hypervisor, serialNumber)
@@ -0,0 +1,50 @@
+package fsstorer
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+)
+
+func TestMachineCordoned(t *testing.T) {
+	topDir, err := ioutil.TempDir("", "fsstorer.test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(topDir)
+	storer, err := New(topDir, testlogger.New(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hypervisor := net.ParseIP("10.1.0.1")
+	if cordoned, err := storer.ReadMachineCordoned(hypervisor); err != nil {
+		t.Fatal(err)
+	} else if cordoned {
+		t.Error("new machine is cordoned")
+	}
+	if err := storer.WriteMachineCordoned(hypervisor, true); err != nil {
+		t.Fatal(err)
+	}
+	// The cordon state must survive a restart.
+	if storer, err = New(topDir, testlogger.New(t)); err != nil {
+		t.Fatal(err)
+	}
+	if cordoned, err := storer.ReadMachineCordoned(hypervisor); err != nil {
+		t.Fatal(err)
+	} else if !cordoned {
+		t.Error("cordon not persisted")
+	}
+	for count := 0; count < 2; count++ {
+		if err := storer.WriteMachineCordoned(hypervisor, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cordoned, err := storer.ReadMachineCordoned(hypervisor); err != nil {
+		t.Fatal(err)
+	} else if cordoned {
+		t.Error("machine still cordoned")
+	}
+}
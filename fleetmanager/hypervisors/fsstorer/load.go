@@ -85,6 +85,21 @@ func (s *Storer) readDirectory(partialIP []byte, dirname string) error {
 	return nil
 }
 
+func (s *Storer) readMachineCordoned(hypervisor net.IP) (bool, error) {
+	hypervisorIP, err := netIpToIp(hypervisor)
+	if err != nil {
+		return false, err
+	}
+	dirname := s.getHypervisorDirectory(hypervisorIP)
+	if _, err := os.Stat(filepath.Join(dirname, "cordoned")); err != nil {
+		if !os.IsNotExist(err) {
+			return false, err
+		}
+		return false, nil
+	}
+	return true, nil
+}
+
 func (s *Storer) readMachineSerialNumber(hypervisor net.IP) (string, error) {
 	hypervisorIP, err := netIpToIp(hypervisor)
 	if err != nil {
@@ -149,6 +149,29 @@ func (s *Storer) writeIPsForHypervisor(hypervisor IP, ipList []IP,
 	return writeIpList(filepath.Join(dirname, "ip-list.raw"), ipList, flags)
 }
 
+func (s *Storer) writeMachineCordoned(hypervisor net.IP, cordoned bool) error {
+	hypervisorIP, err := netIpToIp(hypervisor)
+	if err != nil {
+		return err
+	}
+	dirname := s.getHypervisorDirectory(hypervisorIP)
+	filename := filepath.Join(dirname, "cordoned")
+	if !cordoned {
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	if err := os.MkdirAll(dirname, dirPerms); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE, filePerms)
+	if err != nil {
+		return err
+	}
+	return file.Close()
+}
+
 func (s *Storer) writeMachineSerialNumber(hypervisor net.IP,
 	serialNumber string) error {
 	hypervisorIP, err := netIpToIp(hypervisor)
@@ -17,6 +17,7 @@ const (
 	showConnected
 	showAll
 	showOff
+	showCordoned
 )
 
 type hypervisorList []*hypervisorType
@@ -72,6 +73,10 @@ func (m *Manager) listHypervisors(topologyDir string, showFilter int,
 			if hypervisor.probeStatus == probeStatusOff {
 				hypervisors = append(hypervisors, hypervisor)
 			}
+		case showCordoned:
+			if hypervisor.cordoned {
+				hypervisors = append(hypervisors, hypervisor)
+			}
 		}
 	}
 	return hypervisors, nil
@@ -91,6 +96,8 @@ func (m *Manager) listHypervisorsHandler(w http.ResponseWriter,
 	switch parsedQuery.Table["state"] {
 	case "connected":
 		showFilter = showConnected
+	case "cordoned":
+		showFilter = showCordoned
 	case "OK":
 		showFilter = showOK
 	case "off":
@@ -138,9 +145,12 @@ func (m *Manager) listHypervisorsHandler(w http.ResponseWriter,
 		fmt.Fprintf(writer,
 			"    <td><a href=\"showHypervisor?%s\">%s</a></td>\n",
 			machine.Hostname, machine.Hostname)
+		status := hypervisor.getHealthStatus()
+		if hypervisor.cordoned {
+			status += " (cordoned)"
+		}
 		fmt.Fprintf(writer, "    <td><a href=\"http://%s:%d/\">%s</a></td>\n",
-			machine.Hostname, constants.HypervisorPortNumber,
-			hypervisor.getHealthStatus())
+			machine.Hostname, constants.HypervisorPortNumber, status)
 		fmt.Fprintf(writer, "    <td>%s</td>\n", machine.HostIpAddress)
 		fmt.Fprintf(writer, "    <td>%s</td>\n", hypervisor.serialNumber)
 		fmt.Fprintf(writer, "    <td>%s</td>\n", hypervisor.location)
@@ -0,0 +1,150 @@
+package hypervisors
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/json"
+	"github.com/Symantec/Dominator/lib/url"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+type locationUsageType struct {
+	Location              string
+	NumHypervisors        uint
+	NumRunningVMs         uint
+	NumIdleVMs            uint
+	MilliCPUs             uint64 // Total of the Hypervisors.
+	MilliCPUsAllocated    uint64 // Total of the running VMs.
+	MilliCPUsUsed         uint64
+	MemoryInMiB           uint64
+	MemoryAllocatedInMiB  uint64
+	MemoryUsedInMiB       uint64
+	DiskBytesPerSecond    uint64
+	NetworkBytesPerSecond uint64
+}
+
+func percent(value, total uint64) uint64 {
+	if total < 1 {
+		return 0
+	}
+	return value * 100 / total
+}
+
+// getLocationUsage returns the resource utilisation of the connected
+// Hypervisors, summarised by location and sorted by location.
+func (m *Manager) getLocationUsage() []*locationUsageType {
+	usageByLocation := make(map[string]*locationUsageType)
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	for _, h := range m.hypervisors {
+		h.mutex.RLock()
+		if h.probeStatus != probeStatusConnected || h.resources == nil {
+			h.mutex.RUnlock()
+			continue
+		}
+		usage := usageByLocation[h.location]
+		if usage == nil {
+			usage = &locationUsageType{Location: h.location}
+			usageByLocation[h.location] = usage
+		}
+		usage.NumHypervisors++
+		usage.MilliCPUs += uint64(h.resources.NumCPUs) * 1000
+		usage.MemoryInMiB += h.resources.MemoryInMiB
+		for ipAddr, vm := range h.vms {
+			if vm.State != hyper_proto.StateRunning {
+				continue
+			}
+			usage.NumRunningVMs++
+			usage.MilliCPUsAllocated += uint64(vm.MilliCPUs)
+			usage.MemoryAllocatedInMiB += vm.MemoryInMiB
+			if vmUsage := h.vmUsage[ipAddr]; vmUsage != nil {
+				if !vmUsage.IdleSince.IsZero() {
+					usage.NumIdleVMs++
+				}
+				usage.MilliCPUsUsed += uint64(vmUsage.MilliCPUsUsed)
+				usage.MemoryUsedInMiB += vmUsage.MemoryUsedInMiB
+				usage.DiskBytesPerSecond += vmUsage.DiskBytesPerSecond
+				usage.NetworkBytesPerSecond += vmUsage.NetworkBytesPerSecond
+			}
+		}
+		h.mutex.RUnlock()
+	}
+	usages := make([]*locationUsageType, 0, len(usageByLocation))
+	for _, usage := range usageByLocation {
+		usages = append(usages, usage)
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		return usages[i].Location < usages[j].Location
+	})
+	return usages
+}
+
+func (m *Manager) listLocationUsageHandler(w http.ResponseWriter,
+	req *http.Request) {
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	usages := m.getLocationUsage()
+	parsedQuery := url.ParseQuery(req.URL)
+	switch parsedQuery.OutputType() {
+	case url.OutputTypeJson:
+		json.WriteWithIndent(writer, "   ", usages)
+		return
+	case url.OutputTypeText:
+		for _, usage := range usages {
+			fmt.Fprintf(writer, "%s %d %d %d %d\n", usage.Location,
+				percent(usage.MilliCPUsAllocated, usage.MilliCPUs),
+				percent(usage.MilliCPUsUsed, usage.MilliCPUs),
+				percent(usage.MemoryAllocatedInMiB, usage.MemoryInMiB),
+				percent(usage.MemoryUsedInMiB, usage.MemoryInMiB))
+		}
+		return
+	}
+	fmt.Fprintf(writer, "<title>Location utilisation</title>\n")
+	writer.WriteString(commonStyleSheet)
+	fmt.Fprintln(writer, "<body>")
+	fmt.Fprintln(writer, `<table border="1" style="width:100%">`)
+	fmt.Fprintln(writer, "  <tr>")
+	fmt.Fprintln(writer, "    <th>Location</th>")
+	fmt.Fprintln(writer, "    <th>Hypervisors</th>")
+	fmt.Fprintln(writer, "    <th>Running VMs</th>")
+	fmt.Fprintln(writer, "    <th>Idle VMs</th>")
+	fmt.Fprintln(writer, "    <th>CPU Allocated</th>")
+	fmt.Fprintln(writer, "    <th>CPU Used</th>")
+	fmt.Fprintln(writer, "    <th>RAM Allocated</th>")
+	fmt.Fprintln(writer, "    <th>RAM Used</th>")
+	fmt.Fprintln(writer, "    <th>Disk I/O</th>")
+	fmt.Fprintln(writer, "    <th>Network I/O</th>")
+	fmt.Fprintln(writer, "  </tr>")
+	for _, usage := range usages {
+		fmt.Fprintln(writer, "  <tr>")
+		fmt.Fprintf(writer, "    <td>%s</td>\n", usage.Location)
+		fmt.Fprintf(writer, "    <td>%d</td>\n", usage.NumHypervisors)
+		fmt.Fprintf(writer, "    <td>%d</td>\n", usage.NumRunningVMs)
+		fmt.Fprintf(writer, "    <td>%d</td>\n", usage.NumIdleVMs)
+		fmt.Fprintf(writer, "    <td>%g/%g (%d%%)</td>\n",
+			float64(usage.MilliCPUsAllocated)*1e-3,
+			float64(usage.MilliCPUs)*1e-3,
+			percent(usage.MilliCPUsAllocated, usage.MilliCPUs))
+		fmt.Fprintf(writer, "    <td>%g (%d%%)</td>\n",
+			float64(usage.MilliCPUsUsed)*1e-3,
+			percent(usage.MilliCPUsUsed, usage.MilliCPUs))
+		fmt.Fprintf(writer, "    <td>%s/%s (%d%%)</td>\n",
+			format.FormatBytes(usage.MemoryAllocatedInMiB<<20),
+			format.FormatBytes(usage.MemoryInMiB<<20),
+			percent(usage.MemoryAllocatedInMiB, usage.MemoryInMiB))
+		fmt.Fprintf(writer, "    <td>%s (%d%%)</td>\n",
+			format.FormatBytes(usage.MemoryUsedInMiB<<20),
+			percent(usage.MemoryUsedInMiB, usage.MemoryInMiB))
+		fmt.Fprintf(writer, "    <td>%s/s</td>\n",
+			format.FormatBytes(usage.DiskBytesPerSecond))
+		fmt.Fprintf(writer, "    <td>%s/s</td>\n",
+			format.FormatBytes(usage.NetworkBytesPerSecond))
+		fmt.Fprintln(writer, "  </tr>")
+	}
+	fmt.Fprintln(writer, "</table>")
+	fmt.Fprintln(writer, "</body>")
+}
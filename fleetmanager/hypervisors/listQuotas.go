@@ -0,0 +1,92 @@
+package hypervisors
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/json"
+	"github.com/Symantec/Dominator/lib/url"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func quotaOwner(quota hyper_proto.OwnerQuota) string {
+	if quota.User != "" {
+		return "user:" + quota.User
+	}
+	return "group:" + quota.Group
+}
+
+func writeQuotaTableEntry(writer io.Writer, used, limit uint64,
+	formatter func(uint64) string) {
+	if limit < 1 {
+		fmt.Fprintf(writer, "    <td>%s</td>\n", formatter(used))
+	} else if used >= limit {
+		fmt.Fprintf(writer, "    <td style=\"color:red\">%s/%s</td>\n",
+			formatter(used), formatter(limit))
+	} else {
+		fmt.Fprintf(writer, "    <td>%s/%s</td>\n",
+			formatter(used), formatter(limit))
+	}
+}
+
+func (m *Manager) listQuotasHandler(w http.ResponseWriter,
+	req *http.Request) {
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	parsedQuery := url.ParseQuery(req.URL)
+	quotas, err := m.listQuotas(parsedQuery.Table["location"])
+	if err != nil {
+		fmt.Fprintln(writer, err)
+		return
+	}
+	switch parsedQuery.OutputType() {
+	case url.OutputTypeJson:
+		json.WriteWithIndent(writer, "   ", quotas)
+		return
+	case url.OutputTypeText:
+		for _, quota := range quotas {
+			fmt.Fprintf(writer, "%s %s\n", quota.Location, quotaOwner(quota))
+		}
+		return
+	}
+	formatCount := func(value uint64) string {
+		return fmt.Sprintf("%d", value)
+	}
+	formatCPUs := func(value uint64) string {
+		return fmt.Sprintf("%g", float64(value)*1e-3)
+	}
+	formatMiB := func(value uint64) string {
+		return format.FormatBytes(value << 20)
+	}
+	fmt.Fprintf(writer, "<title>Quotas</title>\n")
+	writer.WriteString(commonStyleSheet)
+	fmt.Fprintln(writer, "<body>")
+	fmt.Fprintln(writer, `<table border="1" style="width:100%">`)
+	fmt.Fprintln(writer, "  <tr>")
+	fmt.Fprintln(writer, "    <th>Location</th>")
+	fmt.Fprintln(writer, "    <th>Owner</th>")
+	fmt.Fprintln(writer, "    <th>VMs</th>")
+	fmt.Fprintln(writer, "    <th>CPU</th>")
+	fmt.Fprintln(writer, "    <th>RAM</th>")
+	fmt.Fprintln(writer, "    <th>Storage</th>")
+	fmt.Fprintln(writer, "  </tr>")
+	for _, quota := range quotas {
+		fmt.Fprintln(writer, "  <tr>")
+		fmt.Fprintf(writer, "    <td>%s</td>\n", quota.Location)
+		fmt.Fprintf(writer, "    <td>%s</td>\n", quotaOwner(quota))
+		writeQuotaTableEntry(writer, uint64(quota.Used.NumVMs),
+			uint64(quota.Limit.NumVMs), formatCount)
+		writeQuotaTableEntry(writer, quota.Used.MilliCPUs,
+			quota.Limit.MilliCPUs, formatCPUs)
+		writeQuotaTableEntry(writer, quota.Used.MemoryInMiB,
+			quota.Limit.MemoryInMiB, formatMiB)
+		writeQuotaTableEntry(writer, quota.Used.VolumeBytes,
+			quota.Limit.VolumeBytes, format.FormatBytes)
+		fmt.Fprintln(writer, "  </tr>")
+	}
+	fmt.Fprintln(writer, "</table>")
+	fmt.Fprintln(writer, "</body>")
+}
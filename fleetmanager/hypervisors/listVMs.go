@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/Symantec/Dominator/lib/constants"
 	"github.com/Symantec/Dominator/lib/format"
@@ -82,7 +83,17 @@ func (m *Manager) listVMsHandler(w http.ResponseWriter,
 		return
 	}
 	parsedQuery := url.ParseQuery(req.URL)
+	var idleFilter time.Duration
+	if idleFor := parsedQuery.Table["idleFor"]; idleFor != "" {
+		if idleFilter, err = time.ParseDuration(idleFor); err != nil {
+			fmt.Fprintln(writer, err)
+			return
+		}
+	}
 	vms := m.getVMs(true)
+	if idleFilter > 0 {
+		vms = selectIdleVMs(vms, idleFilter)
+	}
 	if parsedQuery.OutputType() == url.OutputTypeJson {
 		json.WriteWithIndent(writer, "   ", vms)
 	}
@@ -98,6 +109,7 @@ func (m *Manager) listVMsHandler(w http.ResponseWriter,
 		fmt.Fprintln(writer, "    <th>State</th>")
 		fmt.Fprintln(writer, "    <th>RAM</th>")
 		fmt.Fprintln(writer, "    <th>CPU</th>")
+		fmt.Fprintln(writer, "    <th>CPU Used</th>")
 		fmt.Fprintln(writer, "    <th>Num Volumes</th>")
 		fmt.Fprintln(writer, "    <th>Storage</th>")
 		fmt.Fprintln(writer, "    <th>Primary Owner</th>")
@@ -108,6 +120,7 @@ func (m *Manager) listVMsHandler(w http.ResponseWriter,
 	lastRowHighlighted := true
 	primaryOwnersMap := make(map[string]struct{})
 	for _, vm := range vms {
+		usage := vm.getUsage()
 		if primaryOwnerFilter != "" {
 			if vm.OwnerUsers[0] != primaryOwnerFilter {
 				primaryOwnersMap[vm.OwnerUsers[0]] = struct{}{}
@@ -164,6 +177,7 @@ func (m *Manager) listVMsHandler(w http.ResponseWriter,
 				format.FormatBytes(vm.MemoryInMiB<<20))
 			fmt.Fprintf(writer, "    <td>%g</td>\n",
 				float64(vm.MilliCPUs)*1e-3)
+			writeCpuUsedTableEntry(writer, usage)
 			vm.writeNumVolumesTableEntry(writer)
 			vm.writeStorageTotalTableEntry(writer)
 			fmt.Fprintf(writer, "    <td>%s</td>\n", vm.OwnerUsers[0])
@@ -193,6 +207,35 @@ func (m *Manager) listVMsHandler(w http.ResponseWriter,
 	}
 }
 
+// selectIdleVMs returns the VMs which have been idle for at least idleFor.
+func selectIdleVMs(vms []vmInfoType, idleFor time.Duration) []vmInfoType {
+	idleVMs := make([]vmInfoType, 0, len(vms))
+	for _, vm := range vms {
+		usage := vm.getUsage()
+		if usage == nil || usage.IdleSince.IsZero() ||
+			time.Since(usage.IdleSince) < idleFor {
+			continue
+		}
+		idleVMs = append(idleVMs, vm)
+	}
+	return idleVMs
+}
+
+func writeCpuUsedTableEntry(writer io.Writer, usage *proto.VmUsage) {
+	if usage == nil {
+		fmt.Fprintln(writer, "    <td></td>")
+		return
+	}
+	var comment string
+	if !usage.IdleSince.IsZero() {
+		comment = fmt.Sprintf(
+			`<font style="color:grey;font-size:12px"> (idle %s)</font>`,
+			format.Duration(time.Since(usage.IdleSince)))
+	}
+	fmt.Fprintf(writer, "    <td>%g%s</td>\n",
+		float64(usage.MilliCPUsUsed)*1e-3, comment)
+}
+
 func (m *Manager) listVMsInLocation(dirname string) ([]net.IP, error) {
 	hypervisors, err := m.listHypervisors(dirname, showAll, "")
 	if err != nil {
@@ -209,6 +252,14 @@ func (m *Manager) listVMsInLocation(dirname string) ([]net.IP, error) {
 	return addresses, nil
 }
 
+// getUsage returns the last usage summary sent by the Hypervisor, or nil if the
+// VM is not running.
+func (vm *vmInfoType) getUsage() *proto.VmUsage {
+	vm.hypervisor.mutex.RLock()
+	defer vm.hypervisor.mutex.RUnlock()
+	return vm.hypervisor.vmUsage[vm.ipAddr]
+}
+
 func (vm *vmInfoType) writeNumVolumesTableEntry(writer io.Writer) {
 	var comment string
 	for _, volume := range vm.Volumes {
@@ -0,0 +1,27 @@
+package hypervisors
+
+import (
+	"testing"
+	"time"
+
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func TestSelectIdleVMs(t *testing.T) {
+	h := makeTestHypervisor(t, "h0", "10.1.0.1", 16384)
+	h.vmUsage = map[string]*hyper_proto.VmUsage{
+		"10.2.0.1": {IdleSince: time.Now().Add(-2 * time.Hour)},
+		"10.2.0.2": {IdleSince: time.Now().Add(-time.Minute)},
+		"10.2.0.3": {},
+	}
+	var vms []vmInfoType
+	for _, ipAddr := range []string{
+		"10.2.0.1", "10.2.0.2", "10.2.0.3", "10.2.0.4"} {
+		vms = append(vms, vmInfoType{ipAddr,
+			makeTestVmInfo(ipAddr, 1024, hyper_proto.StateRunning), h})
+	}
+	idleVMs := selectIdleVMs(vms, time.Hour)
+	if len(idleVMs) != 1 || idleVMs[0].ipAddr != "10.2.0.1" {
+		t.Errorf("idle VMs: %v", idleVMs)
+	}
+}
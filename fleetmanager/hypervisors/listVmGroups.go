@@ -0,0 +1,73 @@
+package hypervisors
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/json"
+	"github.com/Symantec/Dominator/lib/url"
+)
+
+func (m *Manager) listVmGroupsHandler(w http.ResponseWriter,
+	req *http.Request) {
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	parsedQuery := url.ParseQuery(req.URL)
+	groups := m.listVmGroups(parsedQuery.Table["location"])
+	switch parsedQuery.OutputType() {
+	case url.OutputTypeJson:
+		json.WriteWithIndent(writer, "   ", groups)
+		return
+	case url.OutputTypeText:
+		for _, group := range groups {
+			fmt.Fprintln(writer, makeVmGroupId(group.Location, group.Name))
+		}
+		return
+	}
+	fmt.Fprintf(writer, "<title>VM groups</title>\n")
+	writer.WriteString(commonStyleSheet)
+	fmt.Fprintln(writer, "<body>")
+	if !*manageHypervisors {
+		fmt.Fprintln(writer, "<b>This is a read-only Fleet Manager: "+
+			"VM groups are not being maintained</b><p>")
+	}
+	fmt.Fprintln(writer, `<table border="1" style="width:100%">`)
+	fmt.Fprintln(writer, "  <tr>")
+	fmt.Fprintln(writer, "    <th>Location</th>")
+	fmt.Fprintln(writer, "    <th>Name</th>")
+	fmt.Fprintln(writer, "    <th>Image</th>")
+	fmt.Fprintln(writer, "    <th>Available</th>")
+	fmt.Fprintln(writer, "    <th>Members</th>")
+	fmt.Fprintln(writer, "    <th>Outdated</th>")
+	fmt.Fprintln(writer, "    <th>Last Reconcile</th>")
+	fmt.Fprintln(writer, "    <th>Error</th>")
+	fmt.Fprintln(writer, "  </tr>")
+	for _, group := range groups {
+		fmt.Fprintln(writer, "  <tr>")
+		fmt.Fprintf(writer, "    <td>%s</td>\n", group.Location)
+		fmt.Fprintf(writer, "    <td>%s</td>\n", group.Name)
+		fmt.Fprintf(writer, "    <td>%s</td>\n", group.ImageName)
+		if group.Available < group.Replicas {
+			fmt.Fprintf(writer, "    <td style=\"color:red\">%d/%d</td>\n",
+				group.Available, group.Replicas)
+		} else {
+			fmt.Fprintf(writer, "    <td>%d/%d</td>\n",
+				group.Available, group.Replicas)
+		}
+		fmt.Fprintf(writer, "    <td>%d</td>\n", group.Members)
+		fmt.Fprintf(writer, "    <td>%d</td>\n", group.Outdated)
+		if group.LastReconcile.IsZero() {
+			fmt.Fprintln(writer, "    <td>never</td>")
+		} else {
+			fmt.Fprintf(writer, "    <td>%s ago</td>\n",
+				format.Duration(time.Since(group.LastReconcile)))
+		}
+		fmt.Fprintf(writer, "    <td>%s</td>\n", group.Error)
+		fmt.Fprintln(writer, "  </tr>")
+	}
+	fmt.Fprintln(writer, "</table>")
+	fmt.Fprintln(writer, "</body>")
+}
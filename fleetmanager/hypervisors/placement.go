@@ -0,0 +1,382 @@
+package hypervisors
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/lib/tags"
+	fm_proto "github.com/Symantec/Dominator/proto/fleetmanager"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+type placementCandidate struct {
+	name          string
+	hypervisor    *hypervisorType
+	affinity      uint    // Number of VMs with matching affinity tags.
+	conflicts     uint    // Number of VMs to spread away from.
+	freeFraction  float64 // Sum of resource fractions free after placement.
+	freeMemory    uint64  // MiB after placement.
+	freeMilliCPUs uint64
+	freeVolume    uint64
+}
+
+type placementRequest struct {
+	memoryInMiB uint64
+	milliCPUs   uint64
+	owner       string
+	policy      fm_proto.PlacementPolicy
+	tags        tags.Tags
+	volumeBytes uint64
+}
+
+func checkSubnetAccess(subnet hyper_proto.Subnet,
+	authInfo *srpc.AuthInformation) bool {
+	if authInfo.HaveMethodAccess {
+		return true
+	}
+	if len(subnet.AllowedUsers) < 1 && len(subnet.AllowedGroups) < 1 {
+		return true
+	}
+	for _, allowedUser := range subnet.AllowedUsers {
+		if authInfo.Username == allowedUser {
+			return true
+		}
+	}
+	for _, allowedGroup := range subnet.AllowedGroups {
+		if _, ok := authInfo.GroupList[allowedGroup]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluateCandidate computes how well a VM fits on a Hypervisor with the
+// specified resources and VMs. If the VM does not fit, the reason is returned.
+func evaluateCandidate(name string, resources hyper_proto.Resources,
+	vms []*hyper_proto.VmInfo,
+	request placementRequest) (placementCandidate, string) {
+	candidate := placementCandidate{name: name}
+	usedMemory := request.memoryInMiB
+	usedMilliCPUs := request.milliCPUs
+	usedVolume := request.volumeBytes
+	for _, vm := range vms {
+		usedMemory += vm.MemoryInMiB
+		usedMilliCPUs += uint64(vm.MilliCPUs)
+		for _, volume := range vm.Volumes {
+			usedVolume += volume.Size
+		}
+		if request.policy.SpreadByOwner && len(vm.OwnerUsers) > 0 &&
+			vm.OwnerUsers[0] == request.owner {
+			candidate.conflicts++
+		} else if matchTags(vm.Tags, request.tags,
+			request.policy.SpreadTagKeys) {
+			candidate.conflicts++
+		}
+		if matchTags(vm.Tags, request.tags, request.policy.AffinityTagKeys) {
+			candidate.affinity++
+		}
+	}
+	totalMilliCPUs := uint64(resources.NumCPUs) * 1000
+	if usedMemory > resources.MemoryInMiB {
+		return candidate, "insufficient memory"
+	}
+	if usedMilliCPUs > totalMilliCPUs {
+		return candidate, "insufficient CPU"
+	}
+	if usedVolume > resources.TotalVolumeBytes {
+		return candidate, "insufficient volume space"
+	}
+	if request.policy.StrictSpread && candidate.conflicts > 0 {
+		return candidate, "spread conflict"
+	}
+	candidate.freeMemory = resources.MemoryInMiB - usedMemory
+	candidate.freeMilliCPUs = totalMilliCPUs - usedMilliCPUs
+	candidate.freeVolume = resources.TotalVolumeBytes - usedVolume
+	candidate.freeFraction = fraction(candidate.freeMemory,
+		resources.MemoryInMiB) +
+		fraction(candidate.freeMilliCPUs, totalMilliCPUs) +
+		fraction(candidate.freeVolume, resources.TotalVolumeBytes)
+	return candidate, ""
+}
+
+// matchTags returns true if any of the keys has the same (non-empty) value in
+// both sets of tags.
+func matchTags(left, right tags.Tags, keys []string) bool {
+	for _, key := range keys {
+		if value := right[key]; value != "" && left[key] == value {
+			return true
+		}
+	}
+	return false
+}
+
+func fraction(value, total uint64) float64 {
+	if total < 1 {
+		return 0
+	}
+	return float64(value) / float64(total)
+}
+
+// makePlacementRequest returns the request to place a new VM. The root volume
+// is estimated as the Hypervisor allocates it: the image data plus the minimum
+// free space. The size of named images is not known, so only the minimum free
+// space is counted for them.
+func makePlacementRequest(request fm_proto.CreateVmRequest,
+	owner string) placementRequest {
+	volumeBytes := request.ImageDataSize + request.MinimumFreeBytes
+	for _, volume := range request.SecondaryVolumes {
+		volumeBytes += volume.Size
+	}
+	return placementRequest{
+		memoryInMiB: request.MemoryInMiB,
+		milliCPUs:   uint64(request.MilliCPUs),
+		owner:       owner,
+		policy:      request.Placement,
+		tags:        request.Tags,
+		volumeBytes: volumeBytes,
+	}
+}
+
+func makeSubnetIDs(subnetId string, secondarySubnetIDs []string) []string {
+	subnetIDs := make([]string, 0, len(secondarySubnetIDs)+1)
+	if subnetId != "" {
+		subnetIDs = append(subnetIDs, subnetId)
+	}
+	return append(subnetIDs, secondarySubnetIDs...)
+}
+
+// makeVmPlacementRequest returns a request to place an existing VM.
+func makeVmPlacementRequest(vm hyper_proto.VmInfo) placementRequest {
+	request := placementRequest{
+		memoryInMiB: vm.MemoryInMiB,
+		milliCPUs:   uint64(vm.MilliCPUs),
+		tags:        vm.Tags,
+	}
+	if len(vm.OwnerUsers) > 0 {
+		request.owner = vm.OwnerUsers[0]
+	}
+	for _, volume := range vm.Volumes {
+		request.volumeBytes += volume.Size
+	}
+	return request
+}
+
+// selectCandidate returns the best candidate, preferring the fewest spread
+// conflicts, then the most affinity matches and then the tightest fit (to
+// leave large spaces for large VMs).
+func selectCandidate(candidates []placementCandidate) placementCandidate {
+	sort.Slice(candidates, func(i, j int) bool {
+		left := candidates[i]
+		right := candidates[j]
+		if left.conflicts != right.conflicts {
+			return left.conflicts < right.conflicts
+		}
+		if left.affinity != right.affinity {
+			return left.affinity > right.affinity
+		}
+		if left.freeFraction != right.freeFraction {
+			return left.freeFraction < right.freeFraction
+		}
+		return left.name < right.name
+	})
+	return candidates[0]
+}
+
+// makeVmInfo returns a VmInfo which uses the resources requested, suitable
+// for reserving capacity.
+func (request placementRequest) makeVmInfo() *hyper_proto.VmInfo {
+	vmInfo := &hyper_proto.VmInfo{
+		MemoryInMiB: request.memoryInMiB,
+		MilliCPUs:   uint(request.milliCPUs),
+		Tags:        request.tags,
+		Volumes:     []hyper_proto.Volume{{Size: request.volumeBytes}},
+	}
+	if request.owner != "" {
+		vmInfo.OwnerUsers = []string{request.owner}
+	}
+	return vmInfo
+}
+
+// makeQuota returns the quota used by the requested resources. The volume
+// bytes include the (estimated) root volume.
+func (request placementRequest) makeQuota() hyper_proto.Quota {
+	return hyper_proto.Quota{
+		MemoryInMiB: request.memoryInMiB,
+		MilliCPUs:   request.milliCPUs,
+		NumVMs:      1,
+		VolumeBytes: request.volumeBytes,
+	}
+}
+
+func (candidate placementCandidate) String() string {
+	return fmt.Sprintf("free after placement: memory: %s, CPU: %s, volume: %s"+
+		"; affinity matches: %d, spread conflicts: %d",
+		format.FormatBytes(candidate.freeMemory<<20),
+		strconv.FormatFloat(float64(candidate.freeMilliCPUs)/1000, 'f', -1,
+			64),
+		format.FormatBytes(candidate.freeVolume),
+		candidate.affinity, candidate.conflicts)
+}
+
+// placeVm chooses a Hypervisor for a VM and returns the reason for the choice.
+// The capacity for the VM is reserved on the Hypervisor until the returned
+// release function is called, which must be done once the VM has been created
+// (or creation has failed).
+func (m *Manager) placeVm(request fm_proto.CreateVmRequest,
+	authInfo *srpc.AuthInformation) (*hypervisorType, string, func(), error) {
+	if request.MemoryInMiB < 1 {
+		return nil, "", nil, fmt.Errorf("no memory specified")
+	}
+	if request.MilliCPUs < 1 {
+		return nil, "", nil, fmt.Errorf("no CPUs specified")
+	}
+	placementRequest := makePlacementRequest(request, authInfo.Username)
+	subnetIDs := makeSubnetIDs(request.SubnetId, request.SecondarySubnetIDs)
+	// Hold the write lock so that concurrent placements cannot select the
+	// same capacity before it is reserved.
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	hypervisor, reason, err := m.selectHypervisor(request.Location, subnetIDs,
+		authInfo, placementRequest, nil)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return hypervisor, reason,
+		hypervisor.reserveVm(placementRequest.makeVmInfo()), nil
+}
+
+// selectHypervisor chooses the best Hypervisor in a location for a VM and
+// returns the reason for the choice. Migrating VMs, reserved VMs and the VMs
+// in pending (keyed by Hypervisor hostname) are counted as if they were
+// already placed. The Manager lock must be held.
+func (m *Manager) selectHypervisor(location string, subnetIDs []string,
+	authInfo *srpc.AuthInformation, request placementRequest,
+	pending map[string][]*hyper_proto.VmInfo) (*hypervisorType, string, error) {
+	if m.topology == nil {
+		return nil, "", fmt.Errorf("no topology available")
+	}
+	machines, err := m.topology.ListMachines(location)
+	if err != nil {
+		return nil, "", err
+	}
+	var candidates []placementCandidate
+	rejections := make(map[string]uint)
+	for _, machine := range machines {
+		hypervisor := m.hypervisors[machine.Hostname]
+		if hypervisor == nil {
+			continue
+		}
+		if reason := m.checkSubnets(machine.Hostname, subnetIDs,
+			authInfo); reason != "" {
+			rejections[reason]++
+			continue
+		}
+		hypervisor.mutex.RLock()
+		cordoned := hypervisor.cordoned
+		healthy := hypervisor.probeStatus == probeStatusConnected &&
+			(hypervisor.healthStatus == "" ||
+				hypervisor.healthStatus == "healthy")
+		resources := hypervisor.resources
+		vms := make([]*hyper_proto.VmInfo, 0,
+			len(hypervisor.vms)+len(hypervisor.migratingVms)+
+				len(hypervisor.reservedVms)+len(pending[machine.Hostname]))
+		for _, vm := range hypervisor.vms {
+			vms = append(vms, &vm.VmInfo)
+		}
+		for _, vm := range hypervisor.migratingVms {
+			vms = append(vms, &vm.VmInfo)
+		}
+		for vm := range hypervisor.reservedVms {
+			vms = append(vms, vm)
+		}
+		hypervisor.mutex.RUnlock()
+		vms = append(vms, pending[machine.Hostname]...)
+		if cordoned {
+			rejections["cordoned"]++
+			continue
+		}
+		if !healthy {
+			rejections["unhealthy"]++
+			continue
+		}
+		if resources == nil {
+			rejections["resources unknown"]++
+			continue
+		}
+		candidate, reason := evaluateCandidate(machine.Hostname, *resources,
+			vms, request)
+		if reason != "" {
+			rejections[reason]++
+			continue
+		}
+		candidate.hypervisor = hypervisor
+		candidates = append(candidates, candidate)
+	}
+	if len(candidates) < 1 {
+		return nil, "", fmt.Errorf("no Hypervisor available: %s",
+			formatRejections(rejections))
+	}
+	candidate := selectCandidate(candidates)
+	reason := fmt.Sprintf("best of %d candidates: %s", len(candidates),
+		candidate)
+	if len(rejections) > 0 {
+		reason += "; rejected: " + formatRejections(rejections)
+	}
+	return candidate.hypervisor, reason, nil
+}
+
+// reserveVm counts the resources of a VM which is being created against the
+// Hypervisor until the returned function is called.
+func (h *hypervisorType) reserveVm(vmInfo *hyper_proto.VmInfo) func() {
+	h.mutex.Lock()
+	h.reservedVms[vmInfo] = struct{}{}
+	h.mutex.Unlock()
+	return func() {
+		h.mutex.Lock()
+		delete(h.reservedVms, vmInfo)
+		h.mutex.Unlock()
+	}
+}
+
+// checkSubnets checks if a machine has the subnets and if the user may use
+// them. The reason the machine is not usable is returned. The Manager lock
+// must be held.
+func (m *Manager) checkSubnets(hostname string, subnetIDs []string,
+	authInfo *srpc.AuthInformation) string {
+	if len(subnetIDs) < 1 {
+		return ""
+	}
+	subnets, err := m.topology.GetSubnetsForMachine(hostname)
+	if err != nil {
+		return "subnets unknown"
+	}
+	for _, subnetId := range subnetIDs {
+		found := false
+		for _, subnet := range subnets {
+			if subnet.Id == subnetId {
+				if !checkSubnetAccess(subnet.Subnet, authInfo) {
+					return "no access to subnet"
+				}
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "missing subnet"
+		}
+	}
+	return ""
+}
+
+func formatRejections(rejections map[string]uint) string {
+	reasons := make([]string, 0, len(rejections))
+	for reason, count := range rejections {
+		reasons = append(reasons, fmt.Sprintf("%d %s", count, reason))
+	}
+	sort.Strings(reasons)
+	return strings.Join(reasons, ", ")
+}
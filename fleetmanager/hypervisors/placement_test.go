@@ -0,0 +1,175 @@
+package hypervisors
+
+import (
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/tags"
+	fm_proto "github.com/Symantec/Dominator/proto/fleetmanager"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+var testResources = hyper_proto.Resources{
+	MemoryInMiB:      16384,
+	NumCPUs:          4,
+	TotalVolumeBytes: 100 << 30,
+}
+
+func TestEvaluateCandidate(t *testing.T) {
+	request := placementRequest{
+		memoryInMiB: 4096,
+		milliCPUs:   1000,
+		owner:       "alice",
+		tags:        tags.Tags{"Service": "web"},
+		volumeBytes: 10 << 30,
+	}
+	vms := []*hyper_proto.VmInfo{
+		{
+			MemoryInMiB: 8192,
+			MilliCPUs:   1000,
+			OwnerUsers:  []string{"alice"},
+			Tags:        tags.Tags{"Service": "web"},
+			Volumes:     []hyper_proto.Volume{{Size: 10 << 30}},
+		},
+		{
+			MemoryInMiB: 2048,
+			MilliCPUs:   1000,
+			OwnerUsers:  []string{"bob"},
+			Tags:        tags.Tags{"Service": "db"},
+			Volumes:     []hyper_proto.Volume{{Size: 10 << 30}},
+		},
+	}
+	tests := []struct {
+		name      string
+		policy    fm_proto.PlacementPolicy
+		memory    uint64
+		reason    string
+		affinity  uint
+		conflicts uint
+	}{
+		{name: "fits", memory: 4096},
+		{name: "too big", memory: 8192, reason: "insufficient memory"},
+		{name: "spread by tag", memory: 4096, conflicts: 1,
+			policy: fm_proto.PlacementPolicy{SpreadTagKeys: []string{"Service"}}},
+		{name: "spread by owner", memory: 4096, conflicts: 1,
+			policy: fm_proto.PlacementPolicy{SpreadByOwner: true}},
+		{name: "strict spread", memory: 4096, reason: "spread conflict",
+			policy: fm_proto.PlacementPolicy{
+				SpreadTagKeys: []string{"Service"},
+				StrictSpread:  true,
+			}},
+		{name: "affinity", memory: 4096, affinity: 1,
+			policy: fm_proto.PlacementPolicy{
+				AffinityTagKeys: []string{"Service"},
+			}},
+		{name: "no match on missing tag", memory: 4096,
+			policy: fm_proto.PlacementPolicy{
+				SpreadTagKeys: []string{"Team"},
+			}},
+	}
+	for _, test := range tests {
+		request.memoryInMiB = test.memory
+		request.policy = test.policy
+		candidate, reason := evaluateCandidate("h", testResources, vms,
+			request)
+		if reason != test.reason {
+			t.Errorf("%s: reason: \"%s\", expected: \"%s\"",
+				test.name, reason, test.reason)
+		}
+		if reason != "" {
+			continue
+		}
+		if candidate.affinity != test.affinity {
+			t.Errorf("%s: affinity: %d, expected: %d",
+				test.name, candidate.affinity, test.affinity)
+		}
+		if candidate.conflicts != test.conflicts {
+			t.Errorf("%s: conflicts: %d, expected: %d",
+				test.name, candidate.conflicts, test.conflicts)
+		}
+	}
+}
+
+func TestSelectCandidate(t *testing.T) {
+	request := placementRequest{memoryInMiB: 1024, milliCPUs: 500}
+	empty, _ := evaluateCandidate("empty", testResources, nil, request)
+	busy, _ := evaluateCandidate("busy", testResources,
+		[]*hyper_proto.VmInfo{{
+			MemoryInMiB: 8192,
+			MilliCPUs:   1000,
+			OwnerUsers:  []string{"bob"},
+			Volumes:     []hyper_proto.Volume{{Size: 10 << 30}},
+		}}, request)
+	tests := []struct {
+		name      string
+		setup     func()
+		candidate string
+	}{
+		{"tightest fit", func() {}, "busy"},
+		{"spread", func() { busy.conflicts = 1 }, "empty"},
+		{"affinity", func() { empty.conflicts = 1; empty.affinity = 1 },
+			"empty"},
+	}
+	for _, test := range tests {
+		test.setup()
+		candidate := selectCandidate([]placementCandidate{busy, empty})
+		if candidate.name != test.candidate {
+			t.Errorf("%s: got: %s, expected: %s",
+				test.name, candidate.name, test.candidate)
+		}
+	}
+}
+
+func TestReserveVm(t *testing.T) {
+	h := &hypervisorType{
+		reservedVms: make(map[*hyper_proto.VmInfo]struct{}),
+	}
+	request := placementRequest{
+		memoryInMiB: 10240,
+		milliCPUs:   2000,
+		owner:       "alice",
+		volumeBytes: 60 << 30,
+	}
+	release := h.reserveVm(request.makeVmInfo())
+	var vms []*hyper_proto.VmInfo
+	for vm := range h.reservedVms {
+		vms = append(vms, vm)
+	}
+	if _, reason := evaluateCandidate("h", testResources, vms,
+		request); reason != "insufficient memory" {
+		t.Errorf("reason: \"%s\", expected: \"insufficient memory\"", reason)
+	}
+	request.memoryInMiB = 1024
+	if _, reason := evaluateCandidate("h", testResources, vms,
+		request); reason != "insufficient volume space" {
+		t.Errorf("reason: \"%s\", expected: \"insufficient volume space\"",
+			reason)
+	}
+	release()
+	if len(h.reservedVms) != 0 {
+		t.Error("reservation not released")
+	}
+}
+
+func TestMakeQuota(t *testing.T) {
+	request := fm_proto.CreateVmRequest{
+		CreateVmRequest: hyper_proto.CreateVmRequest{
+			ImageDataSize:    2 << 30,
+			MinimumFreeBytes: 4 << 30,
+			SecondaryVolumes: []hyper_proto.Volume{{Size: 10 << 30}},
+			VmInfo: hyper_proto.VmInfo{
+				MemoryInMiB: 1024,
+				MilliCPUs:   500,
+			},
+		},
+	}
+	quota := makePlacementRequest(request, "alice").makeQuota()
+	expected := hyper_proto.Quota{
+		MemoryInMiB: 1024,
+		MilliCPUs:   500,
+		NumVMs:      1,
+		VolumeBytes: 16 << 30,
+	}
+	if quota != expected {
+		t.Errorf("quota: %+v, expected: %+v", quota, expected)
+	}
+}
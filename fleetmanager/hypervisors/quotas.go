@@ -0,0 +1,235 @@
+package hypervisors
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Symantec/Dominator/fleetmanager/topology"
+	"github.com/Symantec/Dominator/lib/constants"
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const quotaSettleTime = time.Second * 5
+
+type hypervisorQuotasType struct {
+	hypervisor *hypervisorType
+	quotas     []hyper_proto.OwnerQuota // Used: usage on the Hypervisor.
+}
+
+type quotaKey struct {
+	group    string
+	location string
+	user     string
+}
+
+func makeQuotaKey(quota *hyper_proto.OwnerQuota) quotaKey {
+	return quotaKey{quota.Group, quota.Location, quota.User}
+}
+
+func quotaRequestsEqual(left, right *hyper_proto.UpdateQuotasRequest) bool {
+	if left == nil || right == nil {
+		return false
+	}
+	if len(left.Quotas) != len(right.Quotas) {
+		return false
+	}
+	for index, leftQuota := range left.Quotas {
+		if leftQuota != right.Quotas[index] {
+			return false
+		}
+	}
+	return true
+}
+
+// subtractQuota returns the resources in left which are not in right.
+func subtractQuota(left, right hyper_proto.Quota) hyper_proto.Quota {
+	return hyper_proto.Quota{
+		MemoryInMiB: left.MemoryInMiB - right.MemoryInMiB,
+		MilliCPUs:   left.MilliCPUs - right.MilliCPUs,
+		NumVMs:      left.NumVMs - right.NumVMs,
+		VolumeBytes: left.VolumeBytes - right.VolumeBytes,
+	}
+}
+
+// checkQuotas returns an error if creating a VM with the specified resources
+// and owners on the Hypervisor would exceed a quota.
+func (m *Manager) checkQuotas(h *hypervisorType, ownerUser string,
+	ownerGroups []string, extra hyper_proto.Quota) error {
+	t, err := m.getTopology()
+	if err != nil {
+		return err
+	}
+	quotas, err := t.GetQuotasForMachine(h.machine.Hostname)
+	if err != nil || len(quotas) < 1 {
+		return err
+	}
+	_, totals := m.computeQuotas(t)
+	for _, quota := range quotas {
+		if !quota.AppliesTo(ownerUser, ownerGroups) {
+			continue
+		}
+		used := totals[makeQuotaKey(&quota)].Used
+		if err := quota.Limit.CheckAvailable(used, extra); err != nil {
+			if quota.User != "" {
+				return fmt.Errorf("quota for user: %s in: %s exceeded: %s",
+					quota.User, quota.Location, err)
+			}
+			return fmt.Errorf("quota for group: %s in: %s exceeded: %s",
+				quota.Group, quota.Location, err)
+		}
+	}
+	return nil
+}
+
+// computeQuotas returns the quotas for each Hypervisor along with the usage on
+// that Hypervisor, and the quotas along with their total usage.
+func (m *Manager) computeQuotas(t *topology.Topology) (
+	[]hypervisorQuotasType, map[quotaKey]*hyper_proto.OwnerQuota) {
+	totals := make(map[quotaKey]*hyper_proto.OwnerQuota)
+	var hypervisorQuotas []hypervisorQuotasType
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	for _, h := range m.hypervisors {
+		quotas, err := t.GetQuotasForMachine(h.machine.Hostname)
+		if err != nil {
+			continue
+		}
+		for index := range quotas {
+			quota := &quotas[index]
+			for _, vm := range h.vms {
+				if quota.AppliesToVm(&vm.VmInfo) {
+					quota.Used.AddVm(&vm.VmInfo)
+				}
+			}
+			key := makeQuotaKey(quota)
+			if total := totals[key]; total == nil {
+				total := *quota
+				totals[key] = &total
+			} else {
+				total.Used.Add(quota.Used)
+			}
+		}
+		hypervisorQuotas = append(hypervisorQuotas,
+			hypervisorQuotasType{h, quotas})
+	}
+	return hypervisorQuotas, totals
+}
+
+// listQuotas returns the quotas defined in and below the location, along with
+// their total usage.
+func (m *Manager) listQuotas(location string) (
+	[]hyper_proto.OwnerQuota, error) {
+	t, err := m.getTopology()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := t.FindDirectory(location); err != nil {
+		return nil, err
+	}
+	_, totals := m.computeQuotas(t)
+	quotas := make([]hyper_proto.OwnerQuota, 0, len(totals))
+	for _, quota := range totals {
+		if testInLocation(quota.Location, location) {
+			quotas = append(quotas, *quota)
+		}
+	}
+	sort.Slice(quotas, func(i, j int) bool {
+		if quotas[i].Location != quotas[j].Location {
+			return quotas[i].Location < quotas[j].Location
+		}
+		if quotas[i].Group != quotas[j].Group {
+			return quotas[i].Group < quotas[j].Group
+		}
+		return quotas[i].User < quotas[j].User
+	})
+	return quotas, nil
+}
+
+// quotaLoop will push the quotas and the usage on other Hypervisors to the
+// Hypervisors when they change.
+func (m *Manager) quotaLoop(triggerChannel <-chan struct{}) {
+	for range triggerChannel {
+		time.Sleep(quotaSettleTime) // Coalesce bursts of changes.
+		select {
+		case <-triggerChannel:
+		default:
+		}
+		m.updateQuotas()
+	}
+}
+
+// triggerQuotaUpdate will request that the Hypervisor quotas are updated. It
+// does not block.
+func (m *Manager) triggerQuotaUpdate() {
+	if !*manageHypervisors {
+		return
+	}
+	select {
+	case m.quotaTrigger <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Manager) updateQuotas() {
+	t, err := m.getTopology()
+	if err != nil {
+		return
+	}
+	hypervisorQuotas, totals := m.computeQuotas(t)
+	var waitGroup sync.WaitGroup
+	for _, entry := range hypervisorQuotas {
+		h := entry.hypervisor
+		request := hyper_proto.UpdateQuotasRequest{
+			Quotas: make([]hyper_proto.OwnerQuota, 0, len(entry.quotas)),
+		}
+		for _, quota := range entry.quotas {
+			total := totals[makeQuotaKey(&quota)]
+			quota.Used = subtractQuota(total.Used, quota.Used)
+			request.Quotas = append(request.Quotas, quota)
+		}
+		h.mutex.RLock()
+		unchanged := h.probeStatus != probeStatusConnected ||
+			quotaRequestsEqual(&request, h.quotasRequest)
+		h.mutex.RUnlock()
+		if unchanged {
+			continue
+		}
+		waitGroup.Add(1)
+		go func(h *hypervisorType) {
+			defer waitGroup.Done()
+			if err := h.updateQuotas(request); err != nil {
+				h.logger.Printf("error updating quotas: %s\n", err)
+			}
+		}(h)
+	}
+	waitGroup.Wait()
+}
+
+func (h *hypervisorType) updateQuotas(
+	request hyper_proto.UpdateQuotasRequest) error {
+	client, err := srpc.DialHTTP("tcp",
+		fmt.Sprintf("%s:%d",
+			h.machine.Hostname, constants.HypervisorPortNumber),
+		time.Minute)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	var reply hyper_proto.UpdateQuotasResponse
+	err = client.RequestReply("Hypervisor.UpdateQuotas", request, &reply)
+	if err == nil {
+		err = errors.New(reply.Error)
+	}
+	if err != nil {
+		return err
+	}
+	h.mutex.Lock()
+	h.quotasRequest = &request
+	h.mutex.Unlock()
+	h.logger.Debugf(0, "updated %d quotas\n", len(request.Quotas))
+	return nil
+}
@@ -79,6 +79,10 @@ func (m *Manager) showHypervisorHandler(w http.ResponseWriter,
 		fmt.Fprintln(writer, "</table>")
 	}
 	fmt.Fprintf(writer, "Status: %s<br>\n", h.getHealthStatus())
+	if h.cordoned {
+		fmt.Fprintln(writer,
+			`<font color="red">Cordoned: no new VMs will be placed</font><br>`)
+	}
 	fmt.Fprintf(writer,
 		"Number of VMs known: <a href=\"http://%s:%d/listVMs\">%d</a>\n",
 		hostname, constants.HypervisorPortNumber, len(h.vms))
@@ -2,6 +2,7 @@ package hypervisors
 
 import (
 	"os"
+	"time"
 
 	"github.com/Symantec/Dominator/lib/html"
 )
@@ -17,22 +18,38 @@ func newManager(startOptions StartOptions) (*Manager, error) {
 		}
 		file.Close()
 	}
+	firewallTrigger := make(chan struct{}, 1)
+	quotaTrigger := make(chan struct{}, 1)
+	vmGroupTrigger := make(chan struct{}, 1)
 	manager := &Manager{
-		ipmiUsername:     startOptions.IpmiUsername,
-		ipmiPasswordFile: startOptions.IpmiPasswordFile,
-		logger:           startOptions.Logger,
-		storer:           startOptions.Storer,
-		allocatingIPs:    make(map[string]struct{}),
-		hypervisors:      make(map[string]*hypervisorType),
-		migratingIPs:     make(map[string]struct{}),
-		subnets:          make(map[string]*subnetType),
-		vms:              make(map[string]*vmInfoType),
+		imageServerAddress: startOptions.ImageServerAddress,
+		ipmiUsername:       startOptions.IpmiUsername,
+		ipmiPasswordFile:   startOptions.IpmiPasswordFile,
+		logger:             startOptions.Logger,
+		startTime:          time.Now(),
+		storer:             startOptions.Storer,
+		firewallTrigger:    firewallTrigger,
+		quotaTrigger:       quotaTrigger,
+		vmGroupDialer:      dialVmGroupHypervisor,
+		vmGroupTrigger:     vmGroupTrigger,
+		allocatingIPs:      make(map[string]struct{}),
+		hypervisors:        make(map[string]*hypervisorType),
+		migratingIPs:       make(map[string]struct{}),
+		subnets:            make(map[string]*subnetType),
+		vmGroups:           make(map[string]*vmGroupType),
+		vms:                make(map[string]*vmInfoType),
 	}
 	manager.initInvertTable()
 	html.HandleFunc("/listHypervisors", manager.listHypervisorsHandler)
+	html.HandleFunc("/listLocationUsage", manager.listLocationUsageHandler)
 	html.HandleFunc("/listLocations", manager.listLocationsHandler)
+	html.HandleFunc("/listQuotas", manager.listQuotasHandler)
 	html.HandleFunc("/listVMs", manager.listVMsHandler)
+	html.HandleFunc("/listVmGroups", manager.listVmGroupsHandler)
 	html.HandleFunc("/showHypervisor", manager.showHypervisorHandler)
+	go manager.firewallLoop(firewallTrigger)
 	go manager.notifierLoop()
+	go manager.quotaLoop(quotaTrigger)
+	go manager.vmGroupLoop(vmGroupTrigger)
 	return manager, nil
 }
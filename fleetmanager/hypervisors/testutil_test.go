@@ -0,0 +1,90 @@
+package hypervisors
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Symantec/Dominator/fleetmanager/topology"
+	"github.com/Symantec/Dominator/lib/json"
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+	fm_proto "github.com/Symantec/Dominator/proto/fleetmanager"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+type testStorer struct {
+	Storer
+	cordoned map[string]bool // Key: Hypervisor IP address.
+	err      error
+}
+
+func (s *testStorer) WriteMachineCordoned(hypervisor net.IP,
+	cordoned bool) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.cordoned[hypervisor.String()] = cordoned
+	return nil
+}
+
+func makeTestHypervisor(t *testing.T, hostname, ipAddr string,
+	memoryInMiB uint64) *hypervisorType {
+	return &hypervisorType{
+		logger: testlogger.New(t),
+		machine: &fm_proto.Machine{NetworkEntry: fm_proto.NetworkEntry{
+			Hostname:      hostname,
+			HostIpAddress: net.ParseIP(ipAddr).To4(),
+		}},
+		migratingVms: make(map[string]*vmInfoType),
+		probeStatus:  probeStatusConnected,
+		reservedVms:  make(map[*hyper_proto.VmInfo]struct{}),
+		resources: &hyper_proto.Resources{
+			MemoryInMiB:      memoryInMiB,
+			NumCPUs:          4,
+			TotalVolumeBytes: 100 << 30,
+		},
+		vms: make(map[string]*vmInfoType),
+	}
+}
+
+// makeTestManager returns a Manager with the Hypervisors in a topology.
+func makeTestManager(t *testing.T, hypervisors ...*hypervisorType) *Manager {
+	topologyDir, err := ioutil.TempDir("", "fleetmanager.hypervisors.test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(topologyDir)
+	m := &Manager{
+		hypervisors: make(map[string]*hypervisorType),
+		logger:      testlogger.New(t),
+		storer:      &testStorer{cordoned: make(map[string]bool)},
+	}
+	var machines []*fm_proto.Machine
+	for _, h := range hypervisors {
+		m.hypervisors[h.machine.Hostname] = h
+		machines = append(machines, h.machine)
+	}
+	err = json.WriteToFile(filepath.Join(topologyDir, "machines.json"), 0644,
+		"    ", machines)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.topology, err = topology.Load(topologyDir); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// makeTestVmInfo returns a VM which uses the specified memory and state.
+func makeTestVmInfo(ipAddr string, memoryInMiB uint64,
+	state hyper_proto.State) hyper_proto.VmInfo {
+	return hyper_proto.VmInfo{
+		Address:     hyper_proto.Address{IpAddress: net.ParseIP(ipAddr)},
+		MemoryInMiB: memoryInMiB,
+		MilliCPUs:   500,
+		State:       state,
+		Volumes:     []hyper_proto.Volume{{Size: 1 << 30}},
+	}
+}
@@ -269,6 +269,9 @@ func (m *Manager) updateTopology(t *topology.Topology) {
 		m.storer.UnregisterHypervisor(hypervisor.machine.HostIpAddress)
 		hypervisor.delete()
 	}
+	m.triggerFirewallUpdate()
+	m.triggerQuotaUpdate()
+	m.triggerVmGroupUpdate()
 }
 
 func (m *Manager) updateTopologyLocked(t *topology.Topology,
@@ -296,6 +299,7 @@ func (m *Manager) updateTopologyLocked(t *topology.Topology,
 				machine:      machine,
 				migratingVms: make(map[string]*vmInfoType),
 				ownerUsers:   stringSliceToSet(machine.OwnerUsers),
+				reservedVms:  make(map[*hyper_proto.VmInfo]struct{}),
 				vms:          make(map[string]*vmInfoType),
 			}
 			m.hypervisors[machine.Hostname] = hypervisor
@@ -370,6 +374,12 @@ func (m *Manager) manageHypervisorLoop(h *hypervisorType, hostname string) {
 		return
 	}
 	h.serialNumber = h.cachedSerialNumber
+	h.cordoned, err = m.storer.ReadMachineCordoned(h.machine.HostIpAddress)
+	if err != nil {
+		h.logger.Printf(
+			"error reading cordon state, not managing hypervisor: %s", err)
+		return
+	}
 	h.localTags, err = m.storer.ReadMachineTags(h.machine.HostIpAddress)
 	if err != nil {
 		h.logger.Printf("error reading tags, not managing hypervisor: %s", err)
@@ -525,6 +535,10 @@ func (m *Manager) processAddressPoolUpdates(h *hypervisorType,
 		if !tSubnet.Manage {
 			continue
 		}
+		if err := tSubnet.CheckValid(); err != nil {
+			h.logger.Println(err)
+			continue
+		}
 		if numFreeAddresses < addressPoolOptions.minimumSize {
 			m.mutex.Lock()
 			freeIPs, err := m.findFreeIPs(tSubnet,
@@ -539,11 +553,18 @@ func (m *Manager) processAddressPoolUpdates(h *hypervisorType,
 				continue
 			}
 			for _, ip := range freeIPs {
+				macAddress := fmt.Sprintf("52:54:%02x:%02x:%02x:%02x",
+					ip[0], ip[1], ip[2], ip[3])
+				ipv6Address, err := tSubnet.MakeIpv6Address(macAddress)
+				if err != nil {
+					h.logger.Println(err)
+					continue
+				}
 				ipsToAdd = append(ipsToAdd, ip)
 				addressesToAdd = append(addressesToAdd, hyper_proto.Address{
-					IpAddress: ip,
-					MacAddress: fmt.Sprintf("52:54:%02x:%02x:%02x:%02x",
-						ip[0], ip[1], ip[2], ip[3]),
+					IpAddress:   ip,
+					Ipv6Address: ipv6Address,
+					MacAddress:  macAddress,
 				})
 			}
 			h.logger.Debugf(0, "Adding %d addresses to subnet: %s\n",
@@ -600,6 +621,12 @@ func (m *Manager) processHypervisorUpdate(h *hypervisorType,
 	if update.HaveSerialNumber && update.SerialNumber != "" {
 		h.serialNumber = update.SerialNumber
 	}
+	if update.Resources != nil {
+		h.resources = update.Resources
+	}
+	if update.HaveVmUsage {
+		h.vmUsage = update.VmUsage
+	}
 	h.mutex.Unlock()
 	if !firstUpdate && update.HealthStatus != oldHealthStatus {
 		h.logger.Printf("health status changed from: \"%s\" to: \"%s\"\n",
@@ -613,6 +640,14 @@ func (m *Manager) processHypervisorUpdate(h *hypervisorType,
 			m.processSubnetsUpdates(h, update.Subnets)
 		}
 		m.processAddressPoolUpdates(h, update)
+		if firstUpdate {
+			h.mutex.Lock()
+			h.firewallRequest = nil
+			h.quotasRequest = nil
+			h.mutex.Unlock()
+			m.triggerFirewallUpdate()
+			m.triggerQuotaUpdate()
+		}
 	}
 	if update.HaveSerialNumber && update.SerialNumber != "" &&
 		update.SerialNumber != oldSerialNumber {
@@ -650,6 +685,7 @@ func (m *Manager) processInitialVMs(h *hypervisorType,
 		}
 	}
 	m.processVmUpdatesWithLock(h, vms)
+	h.vmsReported = true
 }
 
 func (m *Manager) processSubnetsUpdates(h *hypervisorType,
@@ -789,6 +825,8 @@ func (m *Manager) processVmUpdatesWithLock(h *hypervisorType,
 		update.DeletedVMs = append(update.DeletedVMs, ipAddr)
 	}
 	m.sendUpdate(h.location, &update)
+	m.triggerFirewallUpdate()
+	m.triggerQuotaUpdate()
 }
 
 func (m *Manager) splitChanges(hypersToChange []*hypervisorType,
@@ -0,0 +1,931 @@
+package hypervisors
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Symantec/Dominator/fleetmanager/topology"
+	hyper_client "github.com/Symantec/Dominator/hypervisor/client"
+	imclient "github.com/Symantec/Dominator/imageserver/client"
+	"github.com/Symantec/Dominator/lib/constants"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/log/prefixlogger"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/lib/tags"
+	fm_proto "github.com/Symantec/Dominator/proto/fleetmanager"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const (
+	vmGroupCheckInterval = time.Minute
+	vmGroupCreateTimeout = time.Minute * 10 // Wait for new VM to be reported.
+	vmGroupReplaceDelay  = time.Minute * 5
+	vmGroupStartupDelay  = time.Minute * 10 // Wait for Hypervisors to report.
+	vmGroupTagKey        = "VmGroup"
+)
+
+type createdVmType struct {
+	createdAt time.Time
+	hostname  string
+	location  string
+	vmInfo    *hyper_proto.VmInfo
+}
+
+// vmGroupHypervisor is a connection to a Hypervisor, used to maintain the VMs
+// in VM groups.
+type vmGroupHypervisor interface {
+	AcknowledgeVm(ipAddr net.IP) error
+	ChangeVmSize(ipAddr net.IP, memoryInMiB uint64, milliCPUs uint) error
+	ChangeVmTags(ipAddr net.IP, vmTags tags.Tags) error
+	Close() error
+	CreateVm(request hyper_proto.CreateVmRequest,
+		reply *hyper_proto.CreateVmResponse, logger log.DebugLogger) error
+	DestroyVm(ipAddr net.IP) error
+	ReplaceVmImage(request hyper_proto.ReplaceVmImageRequest,
+		reply *hyper_proto.ReplaceVmImageResponse, logger log.DebugLogger) error
+	StartVm(ipAddr net.IP) error
+	StopVm(ipAddr net.IP) error
+}
+
+type srpcVmGroupHypervisor struct {
+	client *srpc.Client
+}
+
+type vmGroupMemberType struct {
+	address      string // Hypervisor host:port.
+	available    bool   // Starting or running on a healthy Hypervisor.
+	current      bool   // Running the target image with the target size.
+	imageCurrent bool   // Running the target image.
+	ipAddr       net.IP
+	location     string
+	migrating    bool
+	reachable    bool // Hypervisor is connected.
+	sizeCurrent  bool // Has the memory and CPUs of the group.
+	state        hyper_proto.State
+	tagsCurrent  bool
+}
+
+type vmGroupStatus struct {
+	Available     uint
+	Error         string `json:",omitempty"`
+	ImageName     string // Target image.
+	LastReconcile time.Time
+	Location      string
+	Members       uint
+	Name          string
+	Outdated      uint
+	Replicas      uint
+}
+
+// vmGroupType holds the definition and reconciliation state for a VM group.
+// The fields above the mutex are only used by the reconciliation loop.
+type vmGroupType struct {
+	id               string // location/name.
+	location         string
+	logger           log.DebugLogger
+	name             string
+	created          map[string]createdVmType // Key: VM IP address.
+	definition       fm_proto.VmGroup
+	imageName        string               // Image which imageUsage is for.
+	imageUsage       uint64               // Estimated root volume usage.
+	replaced         map[string]struct{}  // Key: VM IP address.
+	unavailableSince map[string]time.Time // Key: VM IP address.
+	mutex            sync.Mutex           // Protect everything below.
+	status           vmGroupStatus
+}
+
+func dialVmGroupHypervisor(address string) (vmGroupHypervisor, error) {
+	client, err := srpc.DialHTTP("tcp", address, time.Second*15)
+	if err != nil {
+		return nil, err
+	}
+	return &srpcVmGroupHypervisor{client}, nil
+}
+
+func makeVmGroupId(location, name string) string {
+	if location == "" {
+		return name
+	}
+	return location + "/" + name
+}
+
+// imageIsCurrent returns true if imageName is the target image. If the target
+// is not exact (the latest image in a stream is not known), any image in the
+// stream is considered current.
+func imageIsCurrent(imageName, targetImage string, exact bool) bool {
+	if imageName == targetImage {
+		return true
+	}
+	return !exact && strings.HasPrefix(imageName, targetImage+"/")
+}
+
+// makeVmGroupTags returns the tags for the members of a VM group.
+func makeVmGroupTags(group *vmGroupType) tags.Tags {
+	vmTags := group.definition.Tags.Copy()
+	if vmTags == nil {
+		vmTags = make(tags.Tags)
+	}
+	vmTags[vmGroupTagKey] = group.id
+	if vmTags["Name"] == "" {
+		vmTags["Name"] = group.name
+	}
+	return vmTags
+}
+
+// orderVmGroupLocations returns the locations ordered by the number of members
+// in each, fewest first.
+func orderVmGroupLocations(locations []string,
+	members []*vmGroupMemberType) []string {
+	counts := make(map[string]uint, len(locations))
+	for _, member := range members {
+		for _, location := range locations {
+			if testInLocation(member.location, location) {
+				counts[location]++
+				break
+			}
+		}
+	}
+	ordered := make([]string, len(locations))
+	copy(ordered, locations)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return counts[ordered[i]] < counts[ordered[j]]
+	})
+	return ordered
+}
+
+// resolveVmGroupImage returns the image which VM group members should run. If
+// imageName is an image stream and the image server is known, the latest image
+// in the stream is returned and exact is true.
+func resolveVmGroupImage(client *srpc.Client, imageName string) (
+	string, bool, error) {
+	if client == nil {
+		return imageName, false, nil
+	}
+	if isDir, err := imclient.CheckDirectory(client, imageName); err != nil {
+		return "", false, err
+	} else if !isDir {
+		return imageName, true, nil
+	}
+	latestImage, err := imclient.FindLatestImage(client, imageName, false)
+	if err != nil {
+		return "", false, err
+	}
+	if latestImage == "" {
+		return "", false, errors.New("no images in stream: " + imageName)
+	}
+	return latestImage, true, nil
+}
+
+// getVmGroupImageUsage returns the estimated root volume usage of an image,
+// which is the space the Hypervisor allocates in addition to the minimum free
+// space.
+func getVmGroupImageUsage(client *srpc.Client, imageName string) (
+	uint64, error) {
+	img, err := imclient.GetImage(client, imageName)
+	if err != nil {
+		return 0, err
+	}
+	if img == nil {
+		return 0, errors.New("image not found: " + imageName)
+	}
+	usage := img.FileSystem.EstimateUsage(0)
+	return usage + usage>>3, nil // Hypervisors add 12% extra.
+}
+
+// selectVmGroupExcess returns the members to destroy when there are too many
+// available members. Outdated members are chosen first, then members in the
+// most crowded locations.
+func selectVmGroupExcess(members []*vmGroupMemberType,
+	numExcess uint) []*vmGroupMemberType {
+	locationCounts := make(map[string]uint)
+	var candidates []*vmGroupMemberType
+	for _, member := range members {
+		if member.available {
+			locationCounts[member.location]++
+			if member.reachable && !member.migrating {
+				candidates = append(candidates, member)
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		left := candidates[i]
+		right := candidates[j]
+		if left.current != right.current {
+			return !left.current
+		}
+		leftCount := locationCounts[left.location]
+		rightCount := locationCounts[right.location]
+		if leftCount != rightCount {
+			return leftCount > rightCount
+		}
+		return bytes.Compare(left.ipAddr, right.ipAddr) > 0
+	})
+	if uint(len(candidates)) > numExcess {
+		candidates = candidates[:numExcess]
+	}
+	return candidates
+}
+
+func (m *Manager) listVmGroups(location string) []vmGroupStatus {
+	m.mutex.RLock()
+	groups := make([]*vmGroupType, 0, len(m.vmGroups))
+	for _, group := range m.vmGroups {
+		if testInLocation(group.location, location) {
+			groups = append(groups, group)
+		}
+	}
+	m.mutex.RUnlock()
+	statuses := make([]vmGroupStatus, 0, len(groups))
+	for _, group := range groups {
+		group.mutex.Lock()
+		statuses = append(statuses, group.status)
+		group.mutex.Unlock()
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Location != statuses[j].Location {
+			return statuses[i].Location < statuses[j].Location
+		}
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// triggerVmGroupUpdate will request that the VM groups are reconciled. It does
+// not block.
+func (m *Manager) triggerVmGroupUpdate() {
+	select {
+	case m.vmGroupTrigger <- struct{}{}:
+	default:
+	}
+}
+
+// vmGroupLoop will reconcile the VM groups periodically and when triggered.
+func (m *Manager) vmGroupLoop(triggerChannel <-chan struct{}) {
+	for {
+		select {
+		case <-triggerChannel:
+		case <-time.After(vmGroupCheckInterval):
+		}
+		m.reconcileVmGroups()
+	}
+}
+
+func (m *Manager) reconcileVmGroups() {
+	m.mutex.RLock()
+	t := m.topology
+	m.mutex.RUnlock()
+	if t == nil {
+		return
+	}
+	groups := m.updateVmGroups(t)
+	if len(groups) < 1 {
+		return
+	}
+	// Until every Hypervisor has reported its VMs, members may be missed and
+	// needlessly replaced.
+	if numUnreported := m.countUnreportedHypervisors(); numUnreported > 0 &&
+		time.Since(m.startTime) < vmGroupStartupDelay {
+		err := fmt.Sprintf("waiting for %d Hypervisors to report",
+			numUnreported)
+		for _, group := range groups {
+			group.mutex.Lock()
+			group.status.Error = err
+			group.mutex.Unlock()
+		}
+		return
+	}
+	var imageClient *srpc.Client
+	if m.imageServerAddress != "" {
+		var err error
+		imageClient, err = srpc.DialHTTP("tcp", m.imageServerAddress,
+			time.Second*15)
+		if err != nil {
+			m.logger.Printf("error connecting to image server: %s\n", err)
+		} else {
+			defer imageClient.Close()
+		}
+	}
+	var waitGroup sync.WaitGroup
+	for _, group := range groups {
+		targetImage, exact, err := resolveVmGroupImage(imageClient,
+			group.definition.ImageName)
+		if err == nil && exact && targetImage != group.imageName {
+			usage, err := getVmGroupImageUsage(imageClient, targetImage)
+			if err != nil {
+				group.logger.Printf("error getting image: %s: %s\n",
+					targetImage, err)
+			} else {
+				group.imageName = targetImage
+				group.imageUsage = usage
+			}
+		}
+		waitGroup.Add(1)
+		go func(group *vmGroupType, targetImage string, exact bool,
+			err error) {
+			defer waitGroup.Done()
+			m.reconcileVmGroup(group, targetImage, exact, err)
+		}(group, targetImage, exact, err)
+	}
+	waitGroup.Wait()
+}
+
+// countUnreportedHypervisors returns the number of Hypervisors which have not
+// yet reported their VMs.
+func (m *Manager) countUnreportedHypervisors() uint {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	var count uint
+	for _, h := range m.hypervisors {
+		if !h.vmsReported {
+			count++
+		}
+	}
+	return count
+}
+
+// updateVmGroups updates the VM groups from the topology, keeping the state
+// for existing groups, and returns the groups.
+func (m *Manager) updateVmGroups(t *topology.Topology) []*vmGroupType {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	vmGroups := make(map[string]*vmGroupType)
+	t.Walk(func(directory *topology.Directory) error {
+		location := directory.GetPath()
+		for name, definition := range directory.VmGroups {
+			id := makeVmGroupId(location, name)
+			group := m.vmGroups[id]
+			if group == nil {
+				group = &vmGroupType{
+					id:       id,
+					location: location,
+					logger: prefixlogger.New("VmGroup("+id+"): ",
+						m.logger),
+					name:             name,
+					created:          make(map[string]createdVmType),
+					replaced:         make(map[string]struct{}),
+					unavailableSince: make(map[string]time.Time),
+					status: vmGroupStatus{
+						Location: location,
+						Name:     name,
+					},
+				}
+			}
+			group.definition = definition
+			vmGroups[id] = group
+		}
+		return nil
+	})
+	m.vmGroups = vmGroups
+	groups := make([]*vmGroupType, 0, len(vmGroups))
+	for _, group := range vmGroups {
+		groups = append(groups, group)
+	}
+	return groups
+}
+
+// getVmGroupMembers returns the VMs which are tagged as members of the group
+// and which are owned by the primary owner of the group.
+func (m *Manager) getVmGroupMembers(group *vmGroupType, targetImage string,
+	exact bool) []*vmGroupMemberType {
+	definition := group.definition
+	owner := definition.OwnerUsers[0]
+	vmTags := makeVmGroupTags(group)
+	isMember := func(vm *vmInfoType) bool {
+		return vm.Tags[vmGroupTagKey] == group.id &&
+			len(vm.OwnerUsers) > 0 && vm.OwnerUsers[0] == owner
+	}
+	makeMember := func(vm *vmInfoType) *vmGroupMemberType {
+		imageCurrent := imageIsCurrent(vm.ImageName, targetImage, exact)
+		sizeCurrent := vm.MemoryInMiB == definition.MemoryInMiB &&
+			vm.MilliCPUs == definition.MilliCPUs
+		return &vmGroupMemberType{
+			current:      imageCurrent && sizeCurrent,
+			imageCurrent: imageCurrent,
+			ipAddr:       vm.Address.IpAddress,
+			sizeCurrent:  sizeCurrent,
+			state:        vm.State,
+			tagsCurrent:  vm.Tags.Equal(vmTags),
+		}
+	}
+	var members []*vmGroupMemberType
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	for _, h := range m.hypervisors {
+		h.mutex.RLock()
+		reachable := h.probeStatus == probeStatusConnected
+		healthy := reachable &&
+			(h.healthStatus == "" || h.healthStatus == "healthy")
+		address := fmt.Sprintf("%s:%d", h.machine.Hostname,
+			constants.HypervisorPortNumber)
+		for _, vm := range h.vms {
+			if !isMember(vm) {
+				continue
+			}
+			member := makeMember(vm)
+			member.address = address
+			member.available = healthy &&
+				(vm.State == hyper_proto.StateStarting ||
+					vm.State == hyper_proto.StateRunning)
+			member.location = h.location
+			member.reachable = reachable
+			members = append(members, member)
+		}
+		for _, vm := range h.migratingVms {
+			if !isMember(vm) {
+				continue
+			}
+			member := makeMember(vm)
+			member.address = address
+			member.available = true
+			member.location = h.location
+			member.migrating = true
+			member.reachable = reachable
+			members = append(members, member)
+		}
+		h.mutex.RUnlock()
+	}
+	sort.Slice(members, func(i, j int) bool {
+		return bytes.Compare(members[i].ipAddr, members[j].ipAddr) < 0
+	})
+	return members
+}
+
+// reconcileVmGroup will create, destroy and update the VMs in the group so that
+// the requested number of replicas are available and running the target image
+// with the size and tags of the group.
+func (m *Manager) reconcileVmGroup(group *vmGroupType, targetImage string,
+	exact bool, imageErr error) {
+	definition := group.definition
+	if imageErr != nil {
+		targetImage = definition.ImageName
+		exact = false
+	}
+	members := m.getVmGroupMembers(group, targetImage, exact)
+	now := time.Now()
+	memberIPs := make(map[string]struct{}, len(members))
+	for _, member := range members {
+		memberIPs[member.ipAddr.String()] = struct{}{}
+	}
+	for ipAddr, created := range group.created {
+		if _, ok := memberIPs[ipAddr]; ok {
+			delete(group.created, ipAddr)
+		} else if now.Sub(created.createdAt) > vmGroupCreateTimeout {
+			group.logger.Printf("created VM: %s was never reported\n", ipAddr)
+			delete(group.created, ipAddr)
+		}
+	}
+	// Members which are unavailable for a short time are still counted, so
+	// that they are not replaced while a Hypervisor restarts.
+	numAvailable := uint(len(group.created))
+	var counted, expired []*vmGroupMemberType
+	unavailableSince := make(map[string]time.Time)
+	for _, member := range members {
+		if member.available {
+			numAvailable++
+			counted = append(counted, member)
+			continue
+		}
+		ipAddr := member.ipAddr.String()
+		since, ok := group.unavailableSince[ipAddr]
+		if !ok {
+			since = now
+		}
+		unavailableSince[ipAddr] = since
+		if now.Sub(since) < vmGroupReplaceDelay {
+			counted = append(counted, member)
+		} else {
+			expired = append(expired, member)
+		}
+	}
+	group.unavailableSince = unavailableSince
+	numCounted := uint(len(counted) + len(group.created))
+	var numOutdated uint
+	for _, member := range members {
+		if !member.current {
+			numOutdated++
+		}
+	}
+	status := vmGroupStatus{
+		Available:     numAvailable,
+		ImageName:     targetImage,
+		LastReconcile: now,
+		Location:      group.location,
+		Members:       uint(len(members) + len(group.created)),
+		Name:          group.name,
+		Outdated:      numOutdated,
+		Replicas:      definition.Replicas,
+	}
+	if imageErr != nil {
+		status.Error = imageErr.Error()
+	}
+	defer func() {
+		group.mutex.Lock()
+		group.status = status
+		group.mutex.Unlock()
+	}()
+	if !*manageHypervisors {
+		return
+	}
+	if numCounted < definition.Replicas {
+		numCreated, err := m.createVmGroupMembers(group, targetImage,
+			counted, definition.Replicas-numCounted)
+		numAvailable += numCreated
+		status.Available = numAvailable
+		status.Members += numCreated
+		if err != nil {
+			status.Error = err.Error()
+			return
+		}
+	}
+	if numAvailable < definition.Replicas {
+		return
+	}
+	destroyed := make(map[*vmGroupMemberType]struct{})
+	for _, member := range expired {
+		if !member.reachable {
+			continue
+		}
+		if err := m.destroyVmGroupMember(group, member); err != nil {
+			status.Error = err.Error()
+		} else {
+			destroyed[member] = struct{}{}
+		}
+	}
+	if numAvailable > definition.Replicas {
+		for _, member := range selectVmGroupExcess(members,
+			numAvailable-definition.Replicas) {
+			if err := m.destroyVmGroupMember(group, member); err != nil {
+				status.Error = err.Error()
+			} else {
+				destroyed[member] = struct{}{}
+				numAvailable--
+			}
+		}
+	}
+	status.Available = numAvailable
+	for _, member := range members {
+		if _, ok := destroyed[member]; ok {
+			continue
+		}
+		if member.reachable && !member.migrating && !member.tagsCurrent {
+			if err := m.changeVmGroupMemberTags(group, member); err != nil {
+				status.Error = err.Error()
+			}
+		}
+	}
+	if imageErr != nil || status.Error != "" {
+		return
+	}
+	var outdated []*vmGroupMemberType
+	for _, member := range members {
+		if _, ok := destroyed[member]; ok {
+			continue
+		}
+		if member.available && member.reachable && !member.migrating &&
+			!member.current && member.state == hyper_proto.StateRunning {
+			outdated = append(outdated, member)
+		}
+	}
+	err := m.rollVmGroupImage(group, targetImage, members, outdated)
+	if err != nil {
+		status.Error = err.Error()
+	}
+}
+
+// createVmGroupMembers will create up to count members, spreading them across
+// the locations of the group, and returns the number created. The counted
+// members are those which are expected to remain.
+func (m *Manager) createVmGroupMembers(group *vmGroupType, imageName string,
+	counted []*vmGroupMemberType, count uint) (uint, error) {
+	locations := []string{group.location}
+	if len(group.definition.Locations) > 0 {
+		locations = make([]string, 0, len(group.definition.Locations))
+		for _, location := range group.definition.Locations {
+			locations = append(locations, path.Join(group.location, location))
+		}
+	}
+	pending := make(map[string][]*hyper_proto.VmInfo)
+	for _, created := range group.created {
+		pending[created.hostname] = append(pending[created.hostname],
+			created.vmInfo)
+		counted = append(counted,
+			&vmGroupMemberType{location: created.location})
+	}
+	var numCreated uint
+	for ; numCreated < count; numCreated++ {
+		ipAddr, created, err := m.createVmGroupMember(group, imageName,
+			orderVmGroupLocations(locations, counted), pending)
+		if err != nil {
+			return numCreated, err
+		}
+		group.created[ipAddr.String()] = created
+		pending[created.hostname] = append(pending[created.hostname],
+			created.vmInfo)
+		counted = append(counted,
+			&vmGroupMemberType{location: created.location})
+	}
+	return numCreated, nil
+}
+
+// createVmGroupMember will create a VM in the first location where it can be
+// placed and returns the IP address of the new VM.
+func (m *Manager) createVmGroupMember(group *vmGroupType, imageName string,
+	locations []string, pending map[string][]*hyper_proto.VmInfo) (
+	net.IP, createdVmType, error) {
+	definition := group.definition
+	owner := definition.OwnerUsers[0]
+	vmTags := makeVmGroupTags(group)
+	policy := definition.Placement
+	policy.SpreadTagKeys = append([]string{vmGroupTagKey},
+		policy.SpreadTagKeys...)
+	request := placementRequest{
+		memoryInMiB: definition.MemoryInMiB,
+		milliCPUs:   uint64(definition.MilliCPUs),
+		owner:       owner,
+		policy:      policy,
+		tags:        vmTags,
+		volumeBytes: definition.MinimumFreeBytes,
+	}
+	// The size of the image is only known once it has been resolved.
+	if imageName == group.imageName {
+		request.volumeBytes += group.imageUsage
+	}
+	for _, volume := range definition.SecondaryVolumes {
+		request.volumeBytes += volume.Size
+	}
+	authInfo := &srpc.AuthInformation{
+		GroupList: stringSliceToSet(definition.OwnerGroups),
+		Username:  owner,
+	}
+	subnetIDs := makeSubnetIDs(definition.SubnetId,
+		definition.SecondarySubnetIDs)
+	var h *hypervisorType
+	var reason string
+	var err error
+	m.mutex.Lock()
+	for _, location := range locations {
+		h, reason, err = m.selectHypervisor(location, subnetIDs, authInfo,
+			request, pending)
+		if err == nil {
+			break
+		}
+	}
+	if err != nil {
+		m.mutex.Unlock()
+		return nil, createdVmType{}, err
+	}
+	location := h.location
+	defer h.reserveVm(request.makeVmInfo())()
+	m.mutex.Unlock()
+	if err := m.checkQuotas(h, owner, definition.OwnerGroups,
+		request.makeQuota()); err != nil {
+		return nil, createdVmType{}, err
+	}
+	address := fmt.Sprintf("%s:%d", h.machine.Hostname,
+		constants.HypervisorPortNumber)
+	group.logger.Printf("creating VM on: %s: %s\n", address, reason)
+	client, err := m.vmGroupDialer(address)
+	if err != nil {
+		return nil, createdVmType{}, err
+	}
+	defer client.Close()
+	createRequest := hyper_proto.CreateVmRequest{
+		MinimumFreeBytes: definition.MinimumFreeBytes,
+		OnBehalfOfGroups: definition.OwnerGroups,
+		OnBehalfOfUser:   owner,
+		SecondaryVolumes: definition.SecondaryVolumes,
+		VmInfo: hyper_proto.VmInfo{
+			ImageName:          imageName,
+			MemoryInMiB:        definition.MemoryInMiB,
+			MilliCPUs:          definition.MilliCPUs,
+			OwnerGroups:        definition.OwnerGroups,
+			OwnerUsers:         definition.OwnerUsers[1:],
+			SecondarySubnetIDs: definition.SecondarySubnetIDs,
+			SubnetId:           definition.SubnetId,
+			Tags:               vmTags,
+		},
+	}
+	var reply hyper_proto.CreateVmResponse
+	err = client.CreateVm(createRequest, &reply, group.logger)
+	if err != nil {
+		return nil, createdVmType{},
+			fmt.Errorf("error creating VM on: %s: %s", address, err)
+	}
+	if err := client.AcknowledgeVm(reply.IpAddress); err != nil {
+		return nil, createdVmType{},
+			fmt.Errorf("error acknowledging VM: %s: %s", reply.IpAddress, err)
+	}
+	group.logger.Printf("created VM: %s on: %s\n", reply.IpAddress, address)
+	vmInfo := createRequest.VmInfo
+	vmInfo.OwnerUsers = definition.OwnerUsers
+	return reply.IpAddress, createdVmType{
+		createdAt: time.Now(),
+		hostname:  h.machine.Hostname,
+		location:  location,
+		vmInfo:    &vmInfo,
+	}, nil
+}
+
+// changeVmGroupMemberTags will change the tags of the VM to the tags of the
+// group.
+func (m *Manager) changeVmGroupMemberTags(group *vmGroupType,
+	member *vmGroupMemberType) error {
+	client, err := m.vmGroupDialer(member.address)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := client.ChangeVmTags(member.ipAddr,
+		makeVmGroupTags(group)); err != nil {
+		return fmt.Errorf("error changing tags of VM: %s: %s",
+			member.ipAddr, err)
+	}
+	group.logger.Printf("changed tags of VM: %s\n", member.ipAddr)
+	return nil
+}
+
+func (m *Manager) destroyVmGroupMember(group *vmGroupType,
+	member *vmGroupMemberType) error {
+	client, err := m.vmGroupDialer(member.address)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := client.DestroyVm(member.ipAddr); err != nil {
+		return fmt.Errorf("error destroying VM: %s: %s", member.ipAddr, err)
+	}
+	group.logger.Printf("destroyed VM: %s on: %s\n",
+		member.ipAddr, member.address)
+	return nil
+}
+
+// updateVmGroupMember will stop the VM, change its size and replace its image
+// as needed and start it.
+func (m *Manager) updateVmGroupMember(group *vmGroupType, imageName string,
+	member *vmGroupMemberType) error {
+	client, err := m.vmGroupDialer(member.address)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	definition := group.definition
+	group.logger.Printf("updating VM: %s\n", member.ipAddr)
+	if err := client.StopVm(member.ipAddr); err != nil {
+		return fmt.Errorf("error stopping VM: %s: %s", member.ipAddr, err)
+	}
+	if !member.imageCurrent {
+		group.logger.Printf("replacing image of VM: %s with: %s\n",
+			member.ipAddr, imageName)
+		request := hyper_proto.ReplaceVmImageRequest{
+			ImageName:        imageName,
+			IpAddress:        member.ipAddr,
+			MinimumFreeBytes: definition.MinimumFreeBytes,
+		}
+		var reply hyper_proto.ReplaceVmImageResponse
+		err = client.ReplaceVmImage(request, &reply, group.logger)
+		if err != nil {
+			err = fmt.Errorf("error replacing image of VM: %s: %s",
+				member.ipAddr, err)
+		}
+	}
+	if err == nil && !member.sizeCurrent {
+		err = client.ChangeVmSize(member.ipAddr, definition.MemoryInMiB,
+			definition.MilliCPUs)
+		if err != nil {
+			err = fmt.Errorf("error changing size of VM: %s: %s",
+				member.ipAddr, err)
+		}
+	}
+	if startErr := client.StartVm(member.ipAddr); startErr != nil &&
+		err == nil {
+		err = fmt.Errorf("error starting VM: %s: %s", member.ipAddr, startErr)
+	}
+	return err
+}
+
+// checkReplacedVmGroupMembers returns the addresses of the members whose image
+// was replaced and which are not yet running the target image on a healthy
+// Hypervisor. Healthy members and members which no longer exist are forgotten.
+func checkReplacedVmGroupMembers(replaced map[string]struct{},
+	members []*vmGroupMemberType) []string {
+	healthy := make(map[string]bool, len(members))
+	for _, member := range members {
+		healthy[member.ipAddr.String()] = member.available &&
+			member.current && member.state == hyper_proto.StateRunning
+	}
+	var pending []string
+	for ipAddr := range replaced {
+		if isHealthy, ok := healthy[ipAddr]; !ok || isHealthy {
+			delete(replaced, ipAddr)
+		} else {
+			pending = append(pending, ipAddr)
+		}
+	}
+	sort.Strings(pending)
+	return pending
+}
+
+// rollVmGroupImage will update (replace the image and change the size of) a
+// batch of at most MaxUnavailable outdated members. A batch is only started
+// once the members replaced in the previous batch are running the target image
+// on healthy Hypervisors.
+func (m *Manager) rollVmGroupImage(group *vmGroupType, imageName string,
+	members, outdated []*vmGroupMemberType) error {
+	pending := checkReplacedVmGroupMembers(group.replaced, members)
+	if len(pending) > 0 {
+		return fmt.Errorf("waiting for replaced VMs to become healthy: %s",
+			strings.Join(pending, ", "))
+	}
+	batchSize := int(group.definition.MaxUnavailable)
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	batch := outdated
+	if len(batch) > batchSize {
+		batch = batch[:batchSize]
+	}
+	type resultType struct {
+		ipAddr string
+		err    error
+	}
+	resultChannel := make(chan resultType, len(batch))
+	for _, member := range batch {
+		ipAddr := member.ipAddr.String()
+		group.replaced[ipAddr] = struct{}{}
+		go func(member *vmGroupMemberType) {
+			resultChannel <- resultType{ipAddr,
+				m.updateVmGroupMember(group, imageName, member)}
+		}(member)
+	}
+	var firstError error
+	for range batch {
+		if result := <-resultChannel; result.err != nil {
+			group.logger.Println(result.err)
+			// Failed members are retried rather than waited for.
+			delete(group.replaced, result.ipAddr)
+			if firstError == nil {
+				firstError = result.err
+			}
+		}
+	}
+	return firstError
+}
+
+func (h *srpcVmGroupHypervisor) AcknowledgeVm(ipAddr net.IP) error {
+	return hyper_client.AcknowledgeVm(h.client, ipAddr)
+}
+
+func (h *srpcVmGroupHypervisor) ChangeVmSize(ipAddr net.IP,
+	memoryInMiB uint64, milliCPUs uint) error {
+	return hyper_client.ChangeVmSize(h.client, ipAddr, nil, memoryInMiB,
+		milliCPUs)
+}
+
+func (h *srpcVmGroupHypervisor) ChangeVmTags(ipAddr net.IP,
+	vmTags tags.Tags) error {
+	request := hyper_proto.ChangeVmTagsRequest{IpAddress: ipAddr, Tags: vmTags}
+	var reply hyper_proto.ChangeVmTagsResponse
+	err := h.client.RequestReply("Hypervisor.ChangeVmTags", request, &reply)
+	if err != nil {
+		return err
+	}
+	if reply.Error != "" {
+		return errors.New(reply.Error)
+	}
+	return nil
+}
+
+func (h *srpcVmGroupHypervisor) Close() error {
+	return h.client.Close()
+}
+
+func (h *srpcVmGroupHypervisor) CreateVm(
+	request hyper_proto.CreateVmRequest, reply *hyper_proto.CreateVmResponse,
+	logger log.DebugLogger) error {
+	return hyper_client.CreateVm(h.client, request, reply, logger)
+}
+
+func (h *srpcVmGroupHypervisor) DestroyVm(ipAddr net.IP) error {
+	return hyper_client.DestroyVm(h.client, ipAddr, nil)
+}
+
+func (h *srpcVmGroupHypervisor) ReplaceVmImage(
+	request hyper_proto.ReplaceVmImageRequest,
+	reply *hyper_proto.ReplaceVmImageResponse, logger log.DebugLogger) error {
+	return hyper_client.ReplaceVmImage(h.client, request, reply, logger)
+}
+
+func (h *srpcVmGroupHypervisor) StartVm(ipAddr net.IP) error {
+	return hyper_client.StartVm(h.client, ipAddr, nil)
+}
+
+func (h *srpcVmGroupHypervisor) StopVm(ipAddr net.IP) error {
+	return hyper_client.StopVm(h.client, ipAddr, nil)
+}
@@ -0,0 +1,407 @@
+package hypervisors
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+	"github.com/Symantec/Dominator/lib/tags"
+	fm_proto "github.com/Symantec/Dominator/proto/fleetmanager"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+// testVmGroupHypervisors records the requests made to Hypervisors.
+type testVmGroupHypervisors struct {
+	createErr error
+	mutex     sync.Mutex
+	requests  []string
+}
+
+type testVmGroupHypervisor struct {
+	*testVmGroupHypervisors
+}
+
+func (hypervisors *testVmGroupHypervisors) dial(address string) (
+	vmGroupHypervisor, error) {
+	return testVmGroupHypervisor{hypervisors}, nil
+}
+
+func (hypervisors *testVmGroupHypervisors) record(format string,
+	args ...interface{}) {
+	hypervisors.mutex.Lock()
+	defer hypervisors.mutex.Unlock()
+	hypervisors.requests = append(hypervisors.requests,
+		fmt.Sprintf(format, args...))
+}
+
+func (h testVmGroupHypervisor) AcknowledgeVm(ipAddr net.IP) error {
+	return nil
+}
+
+func (h testVmGroupHypervisor) ChangeVmSize(ipAddr net.IP,
+	memoryInMiB uint64, milliCPUs uint) error {
+	h.record("resize %s", ipAddr)
+	return nil
+}
+
+func (h testVmGroupHypervisor) ChangeVmTags(ipAddr net.IP,
+	vmTags tags.Tags) error {
+	h.record("tag %s", ipAddr)
+	return nil
+}
+
+func (h testVmGroupHypervisor) Close() error {
+	return nil
+}
+
+func (h testVmGroupHypervisor) CreateVm(request hyper_proto.CreateVmRequest,
+	reply *hyper_proto.CreateVmResponse, logger log.DebugLogger) error {
+	h.record("create")
+	if h.createErr != nil {
+		return h.createErr
+	}
+	reply.IpAddress = net.ParseIP("10.0.0.100")
+	return nil
+}
+
+func (h testVmGroupHypervisor) DestroyVm(ipAddr net.IP) error {
+	h.record("destroy %s", ipAddr)
+	return nil
+}
+
+func (h testVmGroupHypervisor) ReplaceVmImage(
+	request hyper_proto.ReplaceVmImageRequest,
+	reply *hyper_proto.ReplaceVmImageResponse, logger log.DebugLogger) error {
+	h.record("replace %s", request.IpAddress)
+	return nil
+}
+
+func (h testVmGroupHypervisor) StartVm(ipAddr net.IP) error {
+	h.record("start %s", ipAddr)
+	return nil
+}
+
+func (h testVmGroupHypervisor) StopVm(ipAddr net.IP) error {
+	h.record("stop %s", ipAddr)
+	return nil
+}
+
+func TestImageIsCurrent(t *testing.T) {
+	tests := []struct {
+		imageName   string
+		targetImage string
+		exact       bool
+		want        bool
+	}{
+		{"web/2019-01-02", "web/2019-01-02", true, true},
+		{"web/2019-01-01", "web/2019-01-02", true, false},
+		{"web/2019-01-01", "web", false, true},
+		{"webserver/2019-01-01", "web", false, false},
+		{"db/2019-01-01", "web", false, false},
+	}
+	for _, test := range tests {
+		got := imageIsCurrent(test.imageName, test.targetImage, test.exact)
+		if got != test.want {
+			t.Errorf("imageIsCurrent(%s, %s, %v) = %v",
+				test.imageName, test.targetImage, test.exact, got)
+		}
+	}
+}
+
+func TestOrderVmGroupLocations(t *testing.T) {
+	members := []*vmGroupMemberType{
+		{location: "SYD/rack0"},
+		{location: "SYD/rack0"},
+		{location: "SYD/rack1"},
+	}
+	ordered := orderVmGroupLocations(
+		[]string{"SYD/rack0", "SYD/rack1", "SYD/rack2"}, members)
+	want := []string{"SYD/rack2", "SYD/rack1", "SYD/rack0"}
+	for index, location := range want {
+		if ordered[index] != location {
+			t.Fatalf("ordered: %v, want: %v", ordered, want)
+		}
+	}
+}
+
+func TestSelectVmGroupExcess(t *testing.T) {
+	makeMember := func(ipAddr, location string,
+		current bool) *vmGroupMemberType {
+		return &vmGroupMemberType{
+			available: true,
+			current:   current,
+			ipAddr:    net.ParseIP(ipAddr),
+			location:  location,
+			reachable: true,
+		}
+	}
+	members := []*vmGroupMemberType{
+		makeMember("10.0.0.1", "SYD/rack0", true),
+		makeMember("10.0.0.2", "SYD/rack0", true),
+		makeMember("10.0.0.3", "SYD/rack1", true),
+		makeMember("10.0.0.4", "SYD/rack1", false),
+		{ // Unreachable members count towards their location.
+			available: true,
+			ipAddr:    net.ParseIP("10.0.0.5"),
+			location:  "SYD/rack0",
+		},
+		{ipAddr: net.ParseIP("10.0.0.6"), location: "SYD/rack1"},
+	}
+	selected := selectVmGroupExcess(members, 2)
+	if len(selected) != 2 {
+		t.Fatalf("expected 2 members, got: %d", len(selected))
+	}
+	if ipAddr := selected[0].ipAddr.String(); ipAddr != "10.0.0.4" {
+		t.Errorf("expected outdated member first, got: %s", ipAddr)
+	}
+	if ipAddr := selected[1].ipAddr.String(); ipAddr != "10.0.0.2" {
+		t.Errorf("expected member in crowded location, got: %s", ipAddr)
+	}
+}
+
+func TestCheckReplacedVmGroupMembers(t *testing.T) {
+	members := []*vmGroupMemberType{
+		{ // Healthy.
+			available: true,
+			current:   true,
+			ipAddr:    net.ParseIP("10.0.0.1"),
+			state:     hyper_proto.StateRunning,
+		},
+		{ // Still starting.
+			available: true,
+			current:   true,
+			ipAddr:    net.ParseIP("10.0.0.2"),
+			state:     hyper_proto.StateStarting,
+		},
+		{ // Running the old image.
+			available: true,
+			ipAddr:    net.ParseIP("10.0.0.3"),
+			state:     hyper_proto.StateRunning,
+		},
+		{ // Unhealthy Hypervisor.
+			current: true,
+			ipAddr:  net.ParseIP("10.0.0.4"),
+			state:   hyper_proto.StateRunning,
+		},
+	}
+	replaced := map[string]struct{}{
+		"10.0.0.1": {},
+		"10.0.0.2": {},
+		"10.0.0.3": {},
+		"10.0.0.4": {},
+		"10.0.0.5": {}, // Destroyed.
+	}
+	pending := checkReplacedVmGroupMembers(replaced, members)
+	want := []string{"10.0.0.2", "10.0.0.3", "10.0.0.4"}
+	if len(pending) != len(want) || len(replaced) != len(want) {
+		t.Fatalf("pending: %v, replaced: %v, want: %v",
+			pending, replaced, want)
+	}
+	for index, ipAddr := range want {
+		if pending[index] != ipAddr {
+			t.Errorf("pending: %v, want: %v", pending, want)
+		}
+		if _, ok := replaced[ipAddr]; !ok {
+			t.Errorf("pending member: %s forgotten", ipAddr)
+		}
+	}
+}
+
+func TestRollVmGroupImageWaitsForReplaced(t *testing.T) {
+	member := &vmGroupMemberType{
+		available: true,
+		current:   true,
+		ipAddr:    net.ParseIP("10.0.0.1"),
+		state:     hyper_proto.StateStarting,
+	}
+	outdated := &vmGroupMemberType{
+		available: true,
+		ipAddr:    net.ParseIP("10.0.0.2"),
+		reachable: true,
+		state:     hyper_proto.StateRunning,
+	}
+	group := &vmGroupType{
+		logger:   testlogger.New(t),
+		replaced: map[string]struct{}{"10.0.0.1": {}},
+	}
+	m := &Manager{}
+	// The outdated member must not be touched (it would fail to connect).
+	err := m.rollVmGroupImage(group, "web/2", []*vmGroupMemberType{
+		member, outdated}, []*vmGroupMemberType{outdated})
+	if err == nil {
+		t.Fatal("no error while waiting for replaced member")
+	}
+	if _, ok := group.replaced["10.0.0.2"]; ok {
+		t.Error("next batch started before replaced member is healthy")
+	}
+}
+
+func TestCountUnreportedHypervisors(t *testing.T) {
+	h0 := makeTestHypervisor(t, "h0", "10.1.0.1", 1024)
+	h1 := makeTestHypervisor(t, "h1", "10.1.0.2", 1024)
+	m := makeTestManager(t, h0, h1)
+	h0.vmsReported = true
+	if count := m.countUnreportedHypervisors(); count != 1 {
+		t.Errorf("unreported: %d, expected: 1", count)
+	}
+	m.processInitialVMs(h1, make(map[string]*hyper_proto.VmInfo))
+	if count := m.countUnreportedHypervisors(); count != 0 {
+		t.Errorf("unreported: %d, expected: 0", count)
+	}
+}
+
+func TestReconcileVmGroup(t *testing.T) {
+	savedManageHypervisors := *manageHypervisors
+	*manageHypervisors = true
+	defer func() { *manageHypervisors = savedManageHypervisors }()
+	definition := fm_proto.VmGroup{
+		ImageName:   "web",
+		MemoryInMiB: 1024,
+		MilliCPUs:   500,
+		OwnerUsers:  []string{"alice"},
+		Replicas:    2,
+	}
+	memberTags := tags.Tags{"Name": "web", vmGroupTagKey: "web"}
+	expiredAt := time.Now().Add(-vmGroupReplaceDelay - time.Minute)
+	tests := []struct {
+		name      string
+		setup     func(h0, h1 *hypervisorType, group *vmGroupType)
+		createErr error
+		requests  []string
+		problem   string
+	}{
+		{"complete", func(h0, h1 *hypervisorType, group *vmGroupType) {
+		}, nil, nil, ""},
+		{"shortage", func(h0, h1 *hypervisorType, group *vmGroupType) {
+			delete(h1.vms, "10.0.0.2")
+		}, nil, []string{"create"}, ""},
+		{"image too big", func(h0, h1 *hypervisorType, group *vmGroupType) {
+			delete(h1.vms, "10.0.0.2")
+			group.definition.MinimumFreeBytes = 2 << 30
+			group.imageName = "web/2"
+			group.imageUsage = 99 << 30
+		}, nil, nil, "no Hypervisor"},
+		{"excess", func(h0, h1 *hypervisorType, group *vmGroupType) {
+			group.definition.Replicas = 1
+		}, nil, []string{"destroy 10.0.0.2"}, ""},
+		{"unhealthy", func(h0, h1 *hypervisorType, group *vmGroupType) {
+			h1.healthStatus = "at risk"
+			group.unavailableSince["10.0.0.2"] = time.Now()
+		}, nil, nil, ""},
+		{"replace unhealthy", func(h0, h1 *hypervisorType,
+			group *vmGroupType) {
+			h1.healthStatus = "at risk"
+			group.unavailableSince["10.0.0.2"] = expiredAt
+		}, nil, []string{"create", "destroy 10.0.0.2"}, ""},
+		{"keep expired", func(h0, h1 *hypervisorType, group *vmGroupType) {
+			h1.healthStatus = "at risk"
+			group.unavailableSince["10.0.0.2"] = expiredAt
+		}, errors.New("no space"), []string{"create"}, "no space"},
+		{"outdated image", func(h0, h1 *hypervisorType, group *vmGroupType) {
+			h0.vms["10.0.0.1"].ImageName = "web/1"
+		}, nil, []string{"stop 10.0.0.1", "replace 10.0.0.1",
+			"start 10.0.0.1"}, ""},
+		{"size", func(h0, h1 *hypervisorType, group *vmGroupType) {
+			group.definition.MemoryInMiB = 2048
+			h1.vms["10.0.0.2"].MemoryInMiB = 2048
+		}, nil, []string{"stop 10.0.0.1", "resize 10.0.0.1",
+			"start 10.0.0.1"}, ""},
+		{"tags", func(h0, h1 *hypervisorType, group *vmGroupType) {
+			group.definition.Tags = tags.Tags{"Service": "web"}
+			h1.vms["10.0.0.2"].Tags["Service"] = "web"
+		}, nil, []string{"tag 10.0.0.1"}, ""},
+	}
+	for _, test := range tests {
+		h0 := makeTestHypervisor(t, "h0", "10.1.0.1", 8192)
+		h1 := makeTestHypervisor(t, "h1", "10.1.0.2", 8192)
+		for index, h := range []*hypervisorType{h0, h1} {
+			ipAddr := fmt.Sprintf("10.0.0.%d", index+1)
+			vmInfo := makeTestVmInfo(ipAddr, 1024, hyper_proto.StateRunning)
+			vmInfo.ImageName = "web/2"
+			vmInfo.OwnerUsers = definition.OwnerUsers
+			vmInfo.Tags = memberTags.Copy()
+			h.vms[ipAddr] = &vmInfoType{
+				ipAddr:     ipAddr,
+				VmInfo:     vmInfo,
+				hypervisor: h,
+			}
+		}
+		m := makeTestManager(t, h0, h1)
+		hypervisors := &testVmGroupHypervisors{createErr: test.createErr}
+		m.vmGroupDialer = hypervisors.dial
+		group := &vmGroupType{
+			id:               "web",
+			logger:           testlogger.New(t),
+			name:             "web",
+			created:          make(map[string]createdVmType),
+			definition:       definition,
+			replaced:         make(map[string]struct{}),
+			unavailableSince: make(map[string]time.Time),
+		}
+		test.setup(h0, h1, group)
+		m.reconcileVmGroup(group, "web/2", true, nil)
+		if !reflect.DeepEqual(hypervisors.requests, test.requests) {
+			t.Errorf("%s: requests: %q, expected: %q",
+				test.name, hypervisors.requests, test.requests)
+		}
+		if !strings.Contains(group.status.Error, test.problem) ||
+			(test.problem == "" && group.status.Error != "") {
+			t.Errorf("%s: error: \"%s\", expected: \"%s\"",
+				test.name, group.status.Error, test.problem)
+		}
+	}
+}
+
+func TestReconcileVmGroupsWaitsForHypervisors(t *testing.T) {
+	savedManageHypervisors := *manageHypervisors
+	*manageHypervisors = true
+	defer func() { *manageHypervisors = savedManageHypervisors }()
+	h0 := makeTestHypervisor(t, "h0", "10.1.0.1", 8192)
+	h1 := makeTestHypervisor(t, "h1", "10.1.0.2", 8192)
+	vmInfo := makeTestVmInfo("10.0.0.1", 1024, hyper_proto.StateRunning)
+	vmInfo.ImageName = "web/1"
+	vmInfo.OwnerUsers = []string{"alice"}
+	vmInfo.Tags = tags.Tags{"Name": "web", vmGroupTagKey: "web"}
+	h0.vms["10.0.0.1"] = &vmInfoType{
+		ipAddr:     "10.0.0.1",
+		VmInfo:     vmInfo,
+		hypervisor: h0,
+	}
+	h0.vmsReported = true
+	m := makeTestManager(t, h0, h1)
+	hypervisors := &testVmGroupHypervisors{}
+	m.vmGroupDialer = hypervisors.dial
+	m.startTime = time.Now()
+	m.topology.Root.VmGroups = map[string]fm_proto.VmGroup{
+		"web": {
+			ImageName:   "web",
+			MemoryInMiB: 1024,
+			MilliCPUs:   500,
+			OwnerUsers:  []string{"alice"},
+		},
+	}
+	// The member on h0 is surplus, but h1 may have other members.
+	m.reconcileVmGroups()
+	if len(hypervisors.requests) > 0 {
+		t.Errorf("requests before Hypervisors reported: %q",
+			hypervisors.requests)
+	}
+	statuses := m.listVmGroups("")
+	if len(statuses) != 1 ||
+		!strings.Contains(statuses[0].Error, "waiting for 1 Hypervisors") {
+		t.Errorf("statuses: %+v", statuses)
+	}
+	m.startTime = time.Now().Add(-vmGroupStartupDelay)
+	m.reconcileVmGroups()
+	expected := []string{"destroy 10.0.0.1"}
+	if !reflect.DeepEqual(hypervisors.requests, expected) {
+		t.Errorf("requests: %q, expected: %q", hypervisors.requests, expected)
+	}
+}
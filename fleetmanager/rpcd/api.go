@@ -36,12 +36,15 @@ func Setup(hypervisorsManager *hypervisors.Manager, logger log.DebugLogger) (
 		srpc.ReceiverOptions{
 			PublicMethods: []string{
 				"ChangeMachineTags",
+				"CreateVm",
 				"GetHypervisorForVM",
 				"GetMachineInfo",
 				"GetUpdates",
 				"ListHypervisorLocations",
 				"ListHypervisorsInLocation",
+				"ListQuotas",
 				"ListVMsInLocation",
+				"SetMachineCordon",
 			}})
 	return (*htmlWriter)(srpcObj), nil
 }
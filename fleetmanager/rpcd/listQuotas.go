@@ -0,0 +1,18 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/fleetmanager"
+)
+
+func (t *srpcType) ListQuotas(conn *srpc.Conn,
+	request proto.ListQuotasRequest,
+	reply *proto.ListQuotasResponse) error {
+	quotas, err := t.hypervisorsManager.ListQuotas(request.Location)
+	*reply = proto.ListQuotasResponse{
+		Quotas: quotas,
+		Error:  errors.ErrorToString(err),
+	}
+	return nil
+}
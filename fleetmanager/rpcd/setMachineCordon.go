@@ -0,0 +1,16 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/fleetmanager"
+)
+
+func (t *srpcType) SetMachineCordon(conn *srpc.Conn,
+	request fleetmanager.SetMachineCordonRequest,
+	reply *fleetmanager.SetMachineCordonResponse) error {
+	*reply = fleetmanager.SetMachineCordonResponse{
+		errors.ErrorToString(t.hypervisorsManager.SetMachineCordon(
+			request.Hostname, conn.GetAuthInformation(), request.Cordoned))}
+	return nil
+}
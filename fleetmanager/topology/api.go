@@ -12,11 +12,15 @@ import (
 
 type Directory struct {
 	Name             string
-	Directories      []*Directory          `json:",omitempty"`
-	Machines         []*fm_proto.Machine   `json:",omitempty"`
-	Subnets          []*Subnet             `json:",omitempty"`
-	Tags             tags.Tags             `json:",omitempty"`
-	nameToDirectory  map[string]*Directory // Key: directory name.
+	Directories      []*Directory                             `json:",omitempty"`
+	FirewallGroups   map[string]hyper_proto.FirewallRuleGroup `json:",omitempty"`
+	GroupQuotas      map[string]hyper_proto.Quota             `json:",omitempty"`
+	Machines         []*fm_proto.Machine                      `json:",omitempty"`
+	Subnets          []*Subnet                                `json:",omitempty"`
+	Tags             tags.Tags                                `json:",omitempty"`
+	UserQuotas       map[string]hyper_proto.Quota             `json:",omitempty"`
+	VmGroups         map[string]fm_proto.VmGroup              `json:",omitempty"`
+	nameToDirectory  map[string]*Directory                    // Key: directory name.
 	owners           *ownersType
 	parent           *Directory
 	path             string
@@ -84,6 +88,14 @@ func (t *Topology) FindDirectory(dirname string) (*Directory, error) {
 	return t.findDirectory(dirname)
 }
 
+// GetFirewallGroupsForMachine returns the firewall rule groups for the
+// directory containing the machine and its parents. Groups in nearer
+// directories override those with the same name in further directories.
+func (t *Topology) GetFirewallGroupsForMachine(name string) (
+	map[string]hyper_proto.FirewallRuleGroup, error) {
+	return t.getFirewallGroupsForMachine(name)
+}
+
 func (t *Topology) GetLocationOfMachine(name string) (string, error) {
 	return t.getLocationOfMachine(name)
 }
@@ -92,6 +104,15 @@ func (t *Topology) GetNumMachines() uint {
 	return uint(len(t.machineParents))
 }
 
+// GetQuotasForMachine returns the quotas for the directory containing the
+// machine and its parents. Quotas in nearer directories override those for the
+// same owner in further directories. The Location of each quota is the
+// directory where it is defined.
+func (t *Topology) GetQuotasForMachine(name string) (
+	[]hyper_proto.OwnerQuota, error) {
+	return t.getQuotasForMachine(name)
+}
+
 func (t *Topology) GetSubnetsForMachine(name string) ([]*Subnet, error) {
 	return t.getSubnetsForMachine(name)
 }
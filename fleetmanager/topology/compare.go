@@ -21,6 +21,12 @@ func (left *Directory) equal(right *Directory) bool {
 	if len(left.Directories) != len(right.Directories) {
 		return false
 	}
+	if len(left.FirewallGroups) != len(right.FirewallGroups) {
+		return false
+	}
+	if !quotaMapsEqual(left.GroupQuotas, right.GroupQuotas) {
+		return false
+	}
 	if len(left.Machines) != len(right.Machines) {
 		return false
 	}
@@ -32,6 +38,13 @@ func (left *Directory) equal(right *Directory) bool {
 			return false
 		}
 	}
+	for name, leftGroup := range left.FirewallGroups {
+		if rightGroup, ok := right.FirewallGroups[name]; !ok {
+			return false
+		} else if !leftGroup.Equal(&rightGroup) {
+			return false
+		}
+	}
 	for index, leftMachine := range left.Machines {
 		if !leftMachine.Equal(right.Machines[index]) {
 			return false
@@ -45,6 +58,31 @@ func (left *Directory) equal(right *Directory) bool {
 	if !left.Tags.Equal(right.Tags) {
 		return false
 	}
+	if !quotaMapsEqual(left.UserQuotas, right.UserQuotas) {
+		return false
+	}
+	if len(left.VmGroups) != len(right.VmGroups) {
+		return false
+	}
+	for name, leftGroup := range left.VmGroups {
+		if rightGroup, ok := right.VmGroups[name]; !ok {
+			return false
+		} else if !leftGroup.Equal(&rightGroup) {
+			return false
+		}
+	}
+	return true
+}
+
+func quotaMapsEqual(left, right map[string]hypervisor.Quota) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for name, leftQuota := range left {
+		if rightQuota, ok := right[name]; !ok || leftQuota != rightQuota {
+			return false
+		}
+	}
 	return true
 }
 
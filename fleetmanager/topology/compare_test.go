@@ -31,8 +31,11 @@ func testNonzero(t *testing.T, valueValue reflect.Value, equalTest func(),
 			equalTest()
 			mapValue := reflect.MakeMap(fieldValue.Type())
 			fieldValue.Set(mapValue)
-			mapValue.SetMapIndex(reflect.ValueOf("key"),
-				reflect.ValueOf("value"))
+			elemValue := reflect.Zero(fieldValue.Type().Elem())
+			if elemValue.Kind() == reflect.String {
+				elemValue = reflect.ValueOf("value")
+			}
+			mapValue.SetMapIndex(reflect.ValueOf("key"), elemValue)
 			notEqualTest()
 			fieldValue.Set(reflect.MakeMap(fieldValue.Type()))
 			equalTest()
@@ -2,8 +2,28 @@ package topology
 
 import (
 	"fmt"
+	"sort"
+
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
 )
 
+func (t *Topology) getFirewallGroupsForMachine(name string) (
+	map[string]hyper_proto.FirewallRuleGroup, error) {
+	directory, ok := t.machineParents[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown machine: %s", name)
+	}
+	groups := make(map[string]hyper_proto.FirewallRuleGroup)
+	for ; directory != nil; directory = directory.parent {
+		for name, group := range directory.FirewallGroups {
+			if _, ok := groups[name]; !ok {
+				groups[name] = group
+			}
+		}
+	}
+	return groups, nil
+}
+
 func (t *Topology) getLocationOfMachine(name string) (string, error) {
 	if directory, ok := t.machineParents[name]; !ok {
 		return "", fmt.Errorf("unknown machine: %s", name)
@@ -12,6 +32,46 @@ func (t *Topology) getLocationOfMachine(name string) (string, error) {
 	}
 }
 
+func (t *Topology) getQuotasForMachine(name string) (
+	[]hyper_proto.OwnerQuota, error) {
+	directory, ok := t.machineParents[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown machine: %s", name)
+	}
+	groups := make(map[string]struct{})
+	users := make(map[string]struct{})
+	var quotas []hyper_proto.OwnerQuota
+	for ; directory != nil; directory = directory.parent {
+		for group, limit := range directory.GroupQuotas {
+			if _, ok := groups[group]; !ok {
+				groups[group] = struct{}{}
+				quotas = append(quotas, hyper_proto.OwnerQuota{
+					Group:    group,
+					Limit:    limit,
+					Location: directory.path,
+				})
+			}
+		}
+		for user, limit := range directory.UserQuotas {
+			if _, ok := users[user]; !ok {
+				users[user] = struct{}{}
+				quotas = append(quotas, hyper_proto.OwnerQuota{
+					Limit:    limit,
+					Location: directory.path,
+					User:     user,
+				})
+			}
+		}
+	}
+	sort.Slice(quotas, func(i, j int) bool {
+		if quotas[i].Group != quotas[j].Group {
+			return quotas[i].Group < quotas[j].Group
+		}
+		return quotas[i].User < quotas[j].User
+	})
+	return quotas, nil
+}
+
 func (t *Topology) getSubnetsForMachine(name string) ([]*Subnet, error) {
 	if directory, ok := t.machineParents[name]; !ok {
 		return nil, fmt.Errorf("unknown machine: %s", name)
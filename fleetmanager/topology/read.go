@@ -6,11 +6,13 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 
 	"github.com/Symantec/Dominator/lib/fsutil"
 	"github.com/Symantec/Dominator/lib/json"
 	"github.com/Symantec/Dominator/lib/tags"
 	proto "github.com/Symantec/Dominator/proto/fleetmanager"
+	hyper_proto "github.com/Symantec/Dominator/proto/hypervisor"
 )
 
 type commonStateType struct {
@@ -62,6 +64,24 @@ func load(topologyDir string) (*Topology, error) {
 	return topology, nil
 }
 
+func loadFirewallGroups(filename string) (
+	map[string]hyper_proto.FirewallRuleGroup, error) {
+	var groups map[string]hyper_proto.FirewallRuleGroup
+	if err := json.ReadFromFile(filename, &groups); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading: %s: %s", filename, err)
+	}
+	for name, group := range groups {
+		if err := group.CheckValid(); err != nil {
+			return nil, fmt.Errorf("error in: %s: group: %s: %s",
+				filename, name, err)
+		}
+	}
+	return groups, nil
+}
+
 func loadMachines(filename string) ([]*proto.Machine, error) {
 	var machines []*proto.Machine
 	if err := json.ReadFromFile(filename, &machines); err != nil {
@@ -110,6 +130,9 @@ func loadSubnets(filename string) ([]*Subnet, error) {
 	gatewayIPs := make(map[string]struct{}, len(subnets))
 	for _, subnet := range subnets {
 		subnet.Shrink()
+		if err := subnet.CheckValid(); err != nil {
+			return nil, fmt.Errorf("error reading: %s: %s", filename, err)
+		}
 		gatewayIp := subnet.IpGateway.String()
 		if _, ok := gatewayIPs[gatewayIp]; ok {
 			return nil, fmt.Errorf("duplicate gateway IP: %s", gatewayIp)
@@ -135,6 +158,27 @@ func loadTags(filename string) (tags.Tags, error) {
 	return loadedTags, nil
 }
 
+func loadVmGroups(filename string) (map[string]proto.VmGroup, error) {
+	var groups map[string]proto.VmGroup
+	if err := json.ReadFromFile(filename, &groups); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error reading: %s: %s", filename, err)
+	}
+	for name, group := range groups {
+		if name == "" || strings.Contains(name, "/") {
+			return nil, fmt.Errorf("error in: %s: bad group name: \"%s\"",
+				filename, name)
+		}
+		if err := group.CheckValid(); err != nil {
+			return nil, fmt.Errorf("error in: %s: group: %s: %s",
+				filename, name, err)
+		}
+	}
+	return groups, nil
+}
+
 func (state *commonStateType) addHostname(name string) error {
 	if name == "" {
 		return nil
@@ -238,6 +282,12 @@ func (t *Topology) readDirectory(topDir, dirname string,
 		subnetIdToSubnet: make(map[string]*Subnet),
 	}
 	dirpath := filepath.Join(topDir, dirname)
+	if err := directory.loadFirewallGroups(dirpath); err != nil {
+		return nil, err
+	}
+	if err := directory.loadQuotas(dirpath); err != nil {
+		return nil, err
+	}
 	if err := directory.loadOwners(dirpath, state.owners); err != nil {
 		return nil, err
 	}
@@ -250,6 +300,9 @@ func (t *Topology) readDirectory(topDir, dirname string,
 	if err := t.loadMachines(directory, dirpath, commonState); err != nil {
 		return nil, err
 	}
+	if err := directory.loadVmGroups(dirpath); err != nil {
+		return nil, err
+	}
 	dirnames, err := fsutil.ReadDirnames(dirpath, false)
 	if err != nil {
 		return nil, err
@@ -280,6 +333,30 @@ func (t *Topology) readDirectory(topDir, dirname string,
 	return directory, nil
 }
 
+func (directory *Directory) loadFirewallGroups(dirname string) error {
+	var err error
+	directory.FirewallGroups, err = loadFirewallGroups(
+		filepath.Join(dirname, "firewall-groups.json"))
+	return err
+}
+
+func (directory *Directory) loadQuotas(dirname string) error {
+	filename := filepath.Join(dirname, "quotas.json")
+	var quotas struct {
+		Groups map[string]hyper_proto.Quota
+		Users  map[string]hyper_proto.Quota
+	}
+	if err := json.ReadFromFile(filename, &quotas); err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("error reading: %s: %s", filename, err)
+	}
+	directory.GroupQuotas = quotas.Groups
+	directory.UserQuotas = quotas.Users
+	return nil
+}
+
 func (directory *Directory) loadMachines(dirname string) error {
 	var err error
 	directory.Machines, err = loadMachines(
@@ -348,6 +425,13 @@ func (directory *Directory) loadTags(dirname string,
 	return nil
 }
 
+func (directory *Directory) loadVmGroups(dirname string) error {
+	var err error
+	directory.VmGroups, err = loadVmGroups(
+		filepath.Join(dirname, "vm-groups.json"))
+	return err
+}
+
 func (owners *ownersType) copy() *ownersType {
 	newOwners := ownersType{
 		OwnerGroups: make([]string, 0, len(owners.OwnerGroups)),
@@ -2,6 +2,7 @@ package client
 
 import (
 	"net"
+	"time"
 
 	"github.com/Symantec/Dominator/lib/log"
 	"github.com/Symantec/Dominator/lib/srpc"
@@ -12,6 +13,16 @@ func AcknowledgeVm(client *srpc.Client, ipAddress net.IP) error {
 	return acknowledgeVm(client, ipAddress)
 }
 
+func AddVmVolume(client *srpc.Client, ipAddr net.IP, accessToken []byte,
+	size uint64) error {
+	return addVmVolume(client, ipAddr, accessToken, size)
+}
+
+func ChangeVmSize(client *srpc.Client, ipAddr net.IP, accessToken []byte,
+	memoryInMiB uint64, milliCPUs uint) error {
+	return changeVmSize(client, ipAddr, accessToken, memoryInMiB, milliCPUs)
+}
+
 func CreateVm(client *srpc.Client, request proto.CreateVmRequest,
 	reply *proto.CreateVmResponse, logger log.DebugLogger) error {
 	return createVm(client, request, reply, logger)
@@ -26,20 +37,61 @@ func DestroyVm(client *srpc.Client, ipAddr net.IP, accessToken []byte) error {
 	return destroyVm(client, ipAddr, accessToken)
 }
 
+func DiscardVmAccessToken(client *srpc.Client, ipAddr net.IP,
+	accessToken []byte) error {
+	return discardVmAccessToken(client, ipAddr, accessToken)
+}
+
 func ExportLocalVm(client *srpc.Client, ipAddr net.IP,
 	verificationCookie []byte) (proto.ExportLocalVmInfo, error) {
 	return exportLocalVm(client, ipAddr, verificationCookie)
 }
 
+func GetVmAccessToken(client *srpc.Client, ipAddr net.IP,
+	lifetime time.Duration) ([]byte, error) {
+	return getVmAccessToken(client, ipAddr, lifetime)
+}
+
 func GetVmInfo(client *srpc.Client, ipAddr net.IP) (proto.VmInfo, error) {
 	return getVmInfo(client, ipAddr)
 }
 
+func GrowVmVolume(client *srpc.Client, ipAddr net.IP, accessToken []byte,
+	volumeIndex uint, size uint64) error {
+	return growVmVolume(client, ipAddr, accessToken, volumeIndex, size)
+}
+
+// MigrateVmState will ask the source Hypervisor to mirror the volumes of a
+// running VM to the NBD server at nbdAddress and then send the memory and
+// device state to destinationAddress. Progress messages are passed to
+// progressFunc.
+func MigrateVmState(client *srpc.Client, ipAddr net.IP, accessToken []byte,
+	destinationAddress, nbdAddress string,
+	progressFunc func(message string) error) error {
+	return migrateVmState(client, ipAddr, accessToken, destinationAddress,
+		nbdAddress, progressFunc)
+}
+
 func PrepareVmForMigration(client *srpc.Client, ipAddr net.IP,
 	accessToken []byte, enable bool) error {
 	return prepareVmForMigration(client, ipAddr, accessToken, enable)
 }
 
+func ProbeVmLiveMigration(client *srpc.Client, ipAddr net.IP,
+	accessToken []byte) (string, error) {
+	return probeVmLiveMigration(client, ipAddr, accessToken)
+}
+
+func ReplaceVmImage(client *srpc.Client, request proto.ReplaceVmImageRequest,
+	reply *proto.ReplaceVmImageResponse, logger log.DebugLogger) error {
+	return replaceVmImage(client, request, reply, logger)
+}
+
+func ResumeMigratingVm(client *srpc.Client, ipAddr net.IP,
+	accessToken []byte) error {
+	return resumeMigratingVm(client, ipAddr, accessToken)
+}
+
 func StartVm(client *srpc.Client, ipAddr net.IP, accessToken []byte) error {
 	return startVm(client, ipAddr, accessToken)
 }
@@ -3,6 +3,7 @@ package client
 import (
 	"fmt"
 	"net"
+	"time"
 
 	"github.com/Symantec/Dominator/lib/errors"
 	"github.com/Symantec/Dominator/lib/log"
@@ -16,6 +17,37 @@ func acknowledgeVm(client *srpc.Client, ipAddress net.IP) error {
 	return client.RequestReply("Hypervisor.AcknowledgeVm", request, &reply)
 }
 
+func addVmVolume(client *srpc.Client, ipAddr net.IP, accessToken []byte,
+	size uint64) error {
+	request := proto.AddVmVolumeRequest{
+		AccessToken: accessToken,
+		IpAddress:   ipAddr,
+		Size:        size,
+	}
+	var reply proto.AddVmVolumeResponse
+	err := client.RequestReply("Hypervisor.AddVmVolume", request, &reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
+
+func changeVmSize(client *srpc.Client, ipAddr net.IP, accessToken []byte,
+	memoryInMiB uint64, milliCPUs uint) error {
+	request := proto.ChangeVmSizeRequest{
+		AccessToken: accessToken,
+		IpAddress:   ipAddr,
+		MemoryInMiB: memoryInMiB,
+		MilliCPUs:   milliCPUs,
+	}
+	var reply proto.ChangeVmSizeResponse
+	err := client.RequestReply("Hypervisor.ChangeVmSize", request, &reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
+
 func createVm(client *srpc.Client, request proto.CreateVmRequest,
 	reply *proto.CreateVmResponse, logger log.DebugLogger) error {
 	if conn, err := client.Call("Hypervisor.CreateVm"); err != nil {
@@ -75,6 +107,21 @@ func destroyVm(client *srpc.Client, ipAddr net.IP, accessToken []byte) error {
 	return errors.New(reply.Error)
 }
 
+func discardVmAccessToken(client *srpc.Client, ipAddr net.IP,
+	accessToken []byte) error {
+	request := proto.DiscardVmAccessTokenRequest{
+		AccessToken: accessToken,
+		IpAddress:   ipAddr,
+	}
+	var reply proto.DiscardVmAccessTokenResponse
+	err := client.RequestReply("Hypervisor.DiscardVmAccessToken", request,
+		&reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
+
 func exportLocalVm(client *srpc.Client, ipAddr net.IP,
 	verificationCookie []byte) (proto.ExportLocalVmInfo, error) {
 	request := proto.ExportLocalVmRequest{
@@ -92,6 +139,23 @@ func exportLocalVm(client *srpc.Client, ipAddr net.IP,
 	return reply.VmInfo, nil
 }
 
+func getVmAccessToken(client *srpc.Client, ipAddr net.IP,
+	lifetime time.Duration) ([]byte, error) {
+	request := proto.GetVmAccessTokenRequest{
+		IpAddress: ipAddr,
+		Lifetime:  lifetime,
+	}
+	var reply proto.GetVmAccessTokenResponse
+	err := client.RequestReply("Hypervisor.GetVmAccessToken", request, &reply)
+	if err != nil {
+		return nil, err
+	}
+	if err := errors.New(reply.Error); err != nil {
+		return nil, err
+	}
+	return reply.Token, nil
+}
+
 func getVmInfo(client *srpc.Client, ipAddr net.IP) (proto.VmInfo, error) {
 	request := proto.GetVmInfoRequest{IpAddress: ipAddr}
 	var reply proto.GetVmInfoResponse
@@ -105,6 +169,69 @@ func getVmInfo(client *srpc.Client, ipAddr net.IP) (proto.VmInfo, error) {
 	return reply.VmInfo, nil
 }
 
+func growVmVolume(client *srpc.Client, ipAddr net.IP, accessToken []byte,
+	volumeIndex uint, size uint64) error {
+	request := proto.GrowVmVolumeRequest{
+		AccessToken: accessToken,
+		IpAddress:   ipAddr,
+		Size:        size,
+		VolumeIndex: volumeIndex,
+	}
+	var reply proto.GrowVmVolumeResponse
+	err := client.RequestReply("Hypervisor.GrowVmVolume", request, &reply)
+	if err != nil {
+		return err
+	}
+	return errors.New(reply.Error)
+}
+
+func migrateVmState(client *srpc.Client, ipAddr net.IP, accessToken []byte,
+	destinationAddress, nbdAddress string,
+	progressFunc func(message string) error) error {
+	_, err := migrateVmStateRequest(client, proto.MigrateVmStateRequest{
+		AccessToken:        accessToken,
+		DestinationAddress: destinationAddress,
+		IpAddress:          ipAddr,
+		NbdAddress:         nbdAddress,
+	}, progressFunc)
+	return err
+}
+
+func migrateVmStateRequest(client *srpc.Client,
+	request proto.MigrateVmStateRequest,
+	progressFunc func(message string) error) (
+	proto.MigrateVmStateResponse, error) {
+	conn, err := client.Call("Hypervisor.MigrateVmState")
+	if err != nil {
+		return proto.MigrateVmStateResponse{}, err
+	}
+	defer conn.Close()
+	if err := conn.Encode(request); err != nil {
+		return proto.MigrateVmStateResponse{}, err
+	}
+	if err := conn.Flush(); err != nil {
+		return proto.MigrateVmStateResponse{}, err
+	}
+	for {
+		var response proto.MigrateVmStateResponse
+		if err := conn.Decode(&response); err != nil {
+			return proto.MigrateVmStateResponse{},
+				fmt.Errorf("error decoding: %s", err)
+		}
+		if response.Error != "" {
+			return response, errors.New(response.Error)
+		}
+		if response.ProgressMessage != "" && progressFunc != nil {
+			if err := progressFunc(response.ProgressMessage); err != nil {
+				return response, err
+			}
+		}
+		if response.Final {
+			return response, nil
+		}
+	}
+}
+
 func prepareVmForMigration(client *srpc.Client, ipAddr net.IP,
 	accessToken []byte, enable bool) error {
 	request := proto.PrepareVmForMigrationRequest{
@@ -121,6 +248,57 @@ func prepareVmForMigration(client *srpc.Client, ipAddr net.IP,
 	return errors.New(reply.Error)
 }
 
+func probeVmLiveMigration(client *srpc.Client, ipAddr net.IP,
+	accessToken []byte) (string, error) {
+	reply, err := migrateVmStateRequest(client, proto.MigrateVmStateRequest{
+		AccessToken: accessToken,
+		IpAddress:   ipAddr,
+		Probe:       true,
+	}, nil)
+	return reply.QemuVersion, err
+}
+
+func replaceVmImage(client *srpc.Client, request proto.ReplaceVmImageRequest,
+	reply *proto.ReplaceVmImageResponse, logger log.DebugLogger) error {
+	if conn, err := client.Call("Hypervisor.ReplaceVmImage"); err != nil {
+		return err
+	} else {
+		defer conn.Close()
+		if err := conn.Encode(request); err != nil {
+			return err
+		}
+		if err := conn.Flush(); err != nil {
+			return err
+		}
+		for {
+			var response proto.ReplaceVmImageResponse
+			if err := conn.Decode(&response); err != nil {
+				return fmt.Errorf("error decoding: %s", err)
+			}
+			if response.Error != "" {
+				return errors.New(response.Error)
+			}
+			if response.ProgressMessage != "" {
+				logger.Debugln(0, response.ProgressMessage)
+			}
+			if response.Final {
+				*reply = response
+				return nil
+			}
+		}
+	}
+}
+
+func resumeMigratingVm(client *srpc.Client, ipAddr net.IP,
+	accessToken []byte) error {
+	_, err := migrateVmStateRequest(client, proto.MigrateVmStateRequest{
+		AccessToken: accessToken,
+		IpAddress:   ipAddr,
+		Resume:      true,
+	}, nil)
+	return err
+}
+
 func startVm(client *srpc.Client, ipAddr net.IP, accessToken []byte) error {
 	request := proto.StartVmRequest{
 		AccessToken: accessToken,
@@ -0,0 +1,404 @@
+package dhcpd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	dhcp6ServerPort = 547
+
+	dhcp6MsgSolicit            = 1
+	dhcp6MsgAdvertise          = 2
+	dhcp6MsgRequest            = 3
+	dhcp6MsgConfirm            = 4
+	dhcp6MsgRenew              = 5
+	dhcp6MsgRebind             = 6
+	dhcp6MsgReply              = 7
+	dhcp6MsgRelease            = 8
+	dhcp6MsgDecline            = 9
+	dhcp6MsgInformationRequest = 11
+
+	dhcp6OptionClientId    = 1
+	dhcp6OptionServerId    = 2
+	dhcp6OptionIaNa        = 3
+	dhcp6OptionIaAddr      = 5
+	dhcp6OptionStatusCode  = 13
+	dhcp6OptionRapidCommit = 14
+	dhcp6OptionDnsServers  = 23
+	dhcp6OptionDomainList  = 24
+
+	dhcp6StatusSuccess   = 0
+	dhcp6StatusNotOnLink = 4
+
+	duidTypeLinkLayerPlusTime = 1
+	duidTypeLinkLayer         = 3
+	hardwareTypeEthernet      = 1
+)
+
+var allDhcp6Servers = net.ParseIP("ff02::1:2")
+
+type dhcp6Option struct {
+	code uint16
+	data []byte
+}
+
+type dhcp6Message struct {
+	msgType       byte
+	transactionId [3]byte
+	options       []dhcp6Option
+}
+
+func decodeDhcp6Message(packet []byte) (*dhcp6Message, error) {
+	if len(packet) < 4 {
+		return nil, errors.New("short DHCPv6 message")
+	}
+	message := &dhcp6Message{msgType: packet[0]}
+	copy(message.transactionId[:], packet[1:4])
+	options, err := decodeDhcp6Options(packet[4:])
+	if err != nil {
+		return nil, err
+	}
+	message.options = options
+	return message, nil
+}
+
+func decodeDhcp6Options(data []byte) ([]dhcp6Option, error) {
+	var options []dhcp6Option
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, errors.New("truncated DHCPv6 option header")
+		}
+		code := binary.BigEndian.Uint16(data[0:2])
+		length := int(binary.BigEndian.Uint16(data[2:4]))
+		if len(data) < 4+length {
+			return nil, fmt.Errorf("truncated DHCPv6 option: %d", code)
+		}
+		options = append(options, dhcp6Option{code, data[4 : 4+length]})
+		data = data[4+length:]
+	}
+	return options, nil
+}
+
+func encodeDhcp6Option(buffer *bytes.Buffer, code uint16, data []byte) {
+	binary.Write(buffer, binary.BigEndian, code)
+	binary.Write(buffer, binary.BigEndian, uint16(len(data)))
+	buffer.Write(data)
+}
+
+// encodeDomainName returns the domain name in DNS wire format.
+func encodeDomainName(name string) []byte {
+	buffer := &bytes.Buffer{}
+	for _, label := range strings.Split(strings.Trim(name, "."), ".") {
+		if label == "" || len(label) > 63 {
+			return nil
+		}
+		buffer.WriteByte(byte(len(label)))
+		buffer.WriteString(label)
+	}
+	buffer.WriteByte(0)
+	return buffer.Bytes()
+}
+
+// getClientMacAddress returns the MAC address of the client, using the
+// link-layer address in the client DUID if available, otherwise the EUI-64
+// interface identifier of the link-local source address.
+func getClientMacAddress(clientId []byte, srcAddr net.IP) (string, error) {
+	if len(clientId) >= 4 &&
+		binary.BigEndian.Uint16(clientId[2:4]) == hardwareTypeEthernet {
+		switch binary.BigEndian.Uint16(clientId[0:2]) {
+		case duidTypeLinkLayerPlusTime:
+			if len(clientId) == 14 {
+				return net.HardwareAddr(clientId[8:14]).String(), nil
+			}
+		case duidTypeLinkLayer:
+			if len(clientId) == 10 {
+				return net.HardwareAddr(clientId[4:10]).String(), nil
+			}
+		}
+	}
+	srcAddr = srcAddr.To16()
+	if srcAddr == nil || !srcAddr.IsLinkLocalUnicast() ||
+		srcAddr[11] != 0xff || srcAddr[12] != 0xfe {
+		return "", fmt.Errorf("cannot determine MAC address for: %s",
+			srcAddr)
+	}
+	return net.HardwareAddr{srcAddr[8] ^ 0x02, srcAddr[9], srcAddr[10],
+		srcAddr[13], srcAddr[14], srcAddr[15]}.String(), nil
+}
+
+// makeDhcp6ServerId returns a DUID-LL for the interface, used to identify
+// the server to clients on that interface.
+func makeDhcp6ServerId(iface *net.Interface) ([]byte, error) {
+	if len(iface.HardwareAddr) != 6 {
+		return nil, fmt.Errorf("no MAC address for: %s", iface.Name)
+	}
+	serverId := make([]byte, 4, 10)
+	binary.BigEndian.PutUint16(serverId[0:2], duidTypeLinkLayer)
+	binary.BigEndian.PutUint16(serverId[2:4], hardwareTypeEthernet)
+	return append(serverId, iface.HardwareAddr...), nil
+}
+
+func makeStatusCode(code uint16, message string) []byte {
+	data := make([]byte, 2, 2+len(message))
+	binary.BigEndian.PutUint16(data, code)
+	return append(data, message...)
+}
+
+func (message *dhcp6Message) addOption(code uint16, data []byte) {
+	message.options = append(message.options, dhcp6Option{code, data})
+}
+
+func (message *dhcp6Message) encode() []byte {
+	buffer := &bytes.Buffer{}
+	buffer.WriteByte(message.msgType)
+	buffer.Write(message.transactionId[:])
+	for _, option := range message.options {
+		encodeDhcp6Option(buffer, option.code, option.data)
+	}
+	return buffer.Bytes()
+}
+
+func (message *dhcp6Message) getOption(code uint16) []byte {
+	for _, option := range message.options {
+		if option.code == code {
+			return option.data
+		}
+	}
+	return nil
+}
+
+func (message *dhcp6Message) hasOption(code uint16) bool {
+	for _, option := range message.options {
+		if option.code == code {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *DhcpServer) addIaNaOptions(reply, request *dhcp6Message,
+	lease *leaseType) {
+	lifetime := uint32(leaseTime.Seconds())
+	for _, option := range request.options {
+		if option.code != dhcp6OptionIaNa || len(option.data) < 12 {
+			continue
+		}
+		iaAddr := make([]byte, 24)
+		copy(iaAddr, lease.Ipv6Address.To16())
+		binary.BigEndian.PutUint32(iaAddr[16:20], lifetime)
+		binary.BigEndian.PutUint32(iaAddr[20:24], lifetime)
+		buffer := &bytes.Buffer{}
+		buffer.Write(option.data[0:4]) // IAID.
+		binary.Write(buffer, binary.BigEndian, lifetime/2)
+		binary.Write(buffer, binary.BigEndian, lifetime/5*4)
+		encodeDhcp6Option(buffer, dhcp6OptionIaAddr, iaAddr)
+		reply.addOption(dhcp6OptionIaNa, buffer.Bytes())
+	}
+}
+
+func (s *DhcpServer) addInformationOptions(reply *dhcp6Message,
+	subnet *proto.Subnet) {
+	var dnsServers []byte
+	for _, dnsServer := range subnet.DomainNameServers {
+		if dnsServer.To4() == nil && len(dnsServer) == net.IPv6len {
+			dnsServers = append(dnsServers, dnsServer...)
+		}
+	}
+	if len(dnsServers) > 0 {
+		reply.addOption(dhcp6OptionDnsServers, dnsServers)
+	}
+	if subnet.DomainName != "" {
+		domainName := encodeDomainName(subnet.DomainName)
+		if domainName != nil {
+			reply.addOption(dhcp6OptionDomainList, domainName)
+		}
+	}
+}
+
+// checkIaAddresses returns true if all the addresses in the IA_NA options of
+// the request match the lease.
+func (s *DhcpServer) checkIaAddresses(request *dhcp6Message,
+	lease *leaseType) bool {
+	for _, option := range request.options {
+		if option.code != dhcp6OptionIaNa || len(option.data) < 12 {
+			continue
+		}
+		iaOptions, err := decodeDhcp6Options(option.data[12:])
+		if err != nil {
+			return false
+		}
+		for _, iaOption := range iaOptions {
+			if iaOption.code != dhcp6OptionIaAddr || len(iaOption.data) < 16 {
+				continue
+			}
+			if !net.IP(iaOption.data[:16]).Equal(lease.Ipv6Address) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// handleDhcp6Packet returns the reply to a DHCPv6 message received on the
+// interface identified by serverId, or nil if there should be no reply.
+func (s *DhcpServer) handleDhcp6Packet(packet []byte, srcAddr net.IP,
+	serverId []byte) ([]byte, error) {
+	request, err := decodeDhcp6Message(packet)
+	if err != nil {
+		return nil, err
+	}
+	clientId := request.getOption(dhcp6OptionClientId)
+	if len(clientId) < 1 {
+		return nil, errors.New("no client ID in DHCPv6 message")
+	}
+	if id := request.getOption(dhcp6OptionServerId); id != nil {
+		if !bytes.Equal(id, serverId) {
+			return nil, nil // Message not for this DHCPv6 server.
+		}
+	}
+	macAddr, err := getClientMacAddress(clientId, srcAddr)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.Debugf(1, "DHCPv6 message type: %d from: %s\n",
+		request.msgType, macAddr)
+	lease, subnet := s.findLease(macAddr)
+	if lease == nil {
+		return nil, nil
+	}
+	if subnet == nil {
+		return nil, fmt.Errorf("no subnet found for %s", lease.IpAddress)
+	}
+	if len(lease.Ipv6Address) < 1 &&
+		request.msgType != dhcp6MsgInformationRequest {
+		return nil, nil
+	}
+	reply := &dhcp6Message{
+		msgType:       dhcp6MsgReply,
+		transactionId: request.transactionId,
+	}
+	reply.addOption(dhcp6OptionClientId, clientId)
+	reply.addOption(dhcp6OptionServerId, serverId)
+	switch request.msgType {
+	case dhcp6MsgSolicit:
+		if request.hasOption(dhcp6OptionRapidCommit) {
+			reply.addOption(dhcp6OptionRapidCommit, nil)
+		} else {
+			reply.msgType = dhcp6MsgAdvertise
+		}
+		s.logger.Debugf(0, "DHCPv6 offer: %s for: %s\n",
+			lease.Ipv6Address, macAddr)
+		s.addIaNaOptions(reply, request, lease)
+	case dhcp6MsgRequest, dhcp6MsgRenew, dhcp6MsgRebind:
+		s.logger.Debugf(0, "DHCPv6 reply: %s for: %s\n",
+			lease.Ipv6Address, macAddr)
+		s.addIaNaOptions(reply, request, lease)
+	case dhcp6MsgConfirm:
+		if s.checkIaAddresses(request, lease) {
+			reply.addOption(dhcp6OptionStatusCode,
+				makeStatusCode(dhcp6StatusSuccess, ""))
+		} else {
+			reply.addOption(dhcp6OptionStatusCode,
+				makeStatusCode(dhcp6StatusNotOnLink, "address not on link"))
+			return reply.encode(), nil
+		}
+	case dhcp6MsgRelease, dhcp6MsgDecline:
+		reply.addOption(dhcp6OptionStatusCode,
+			makeStatusCode(dhcp6StatusSuccess, ""))
+		return reply.encode(), nil
+	case dhcp6MsgInformationRequest:
+	default:
+		return nil, fmt.Errorf("unsupported DHCPv6 message type: %d",
+			request.msgType)
+	}
+	s.addInformationOptions(reply, subnet)
+	return reply.encode(), nil
+}
+
+func (s *DhcpServer) serveDhcp6(conn *ipv6.PacketConn,
+	serverIds map[int][]byte) { // Key: interface index.
+	buffer := make([]byte, 1500)
+	for {
+		length, cm, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			s.logger.Println(err)
+			return
+		}
+		if cm == nil {
+			continue
+		}
+		serverId, ok := serverIds[cm.IfIndex]
+		if !ok {
+			continue
+		}
+		udpAddr, ok := addr.(*net.UDPAddr)
+		if !ok {
+			continue
+		}
+		reply, err := s.handleDhcp6Packet(buffer[:length], udpAddr.IP,
+			serverId)
+		if err != nil {
+			s.logger.Debugf(0, "DHCPv6 error: %s\n", err)
+			continue
+		}
+		if reply == nil {
+			continue
+		}
+		_, err = conn.WriteTo(reply, &ipv6.ControlMessage{IfIndex: cm.IfIndex},
+			addr)
+		if err != nil {
+			s.logger.Println(err)
+		}
+	}
+}
+
+// startDhcp6 starts a stateful DHCPv6 server on the specified interfaces,
+// serving the IPv6 addresses of the leases. The routers for the subnets must
+// send Router Advertisements with the Managed flag set.
+func (s *DhcpServer) startDhcp6(ifIndices map[int]struct{}) error {
+	listener, err := net.ListenPacket("udp6",
+		fmt.Sprintf("[::]:%d", dhcp6ServerPort))
+	if err != nil {
+		return err
+	}
+	pktConn := ipv6.NewPacketConn(listener)
+	if err := pktConn.SetControlMessage(ipv6.FlagInterface, true); err != nil {
+		listener.Close()
+		return err
+	}
+	serverIds := make(map[int][]byte, len(ifIndices))
+	for ifIndex := range ifIndices {
+		iface, err := net.InterfaceByIndex(ifIndex)
+		if err != nil {
+			listener.Close()
+			return err
+		}
+		serverId, err := makeDhcp6ServerId(iface)
+		if err != nil {
+			s.logger.Debugf(0, "not serving DHCPv6 on: %s: %s\n",
+				iface.Name, err)
+			continue
+		}
+		err = pktConn.JoinGroup(iface, &net.UDPAddr{IP: allDhcp6Servers})
+		if err != nil {
+			s.logger.Debugf(0, "not serving DHCPv6 on: %s: %s\n",
+				iface.Name, err)
+			continue
+		}
+		serverIds[ifIndex] = serverId
+	}
+	if len(serverIds) < 1 {
+		listener.Close()
+		return errors.New("no interfaces support IPv6")
+	}
+	go s.serveDhcp6(pktConn, serverIds)
+	return nil
+}
@@ -0,0 +1,200 @@
+package dhcpd
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func TestGetClientMacAddress(t *testing.T) {
+	macAddr := "52:54:0a:01:02:03"
+	duidLL := []byte{0, 3, 0, 1, 0x52, 0x54, 0x0a, 1, 2, 3}
+	duidLLT := []byte{0, 1, 0, 1, 0, 0, 0, 0, 0x52, 0x54, 0x0a, 1, 2, 3}
+	duidUUID := []byte{0, 4, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14,
+		15, 16}
+	linkLocalSubnet := proto.Subnet{
+		Id:          "link-local",
+		Ipv6Gateway: net.ParseIP("fe80::"),
+		Ipv6Mask:    net.ParseIP("ffff:ffff:ffff:ffff::"),
+	}
+	linkLocalAddr, err := linkLocalSubnet.MakeIpv6Address(macAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := net.ParseIP("fe80::5054:aff:fe01:203")
+	if !linkLocalAddr.Equal(expected) {
+		t.Errorf("expected: %s, got: %s", expected, linkLocalAddr)
+	}
+	for _, clientId := range [][]byte{duidLL, duidLLT, duidUUID} {
+		got, err := getClientMacAddress(clientId, linkLocalAddr)
+		if err != nil {
+			t.Error(err)
+		} else if got != macAddr {
+			t.Errorf("expected: %s, got: %s", macAddr, got)
+		}
+	}
+	privacyAddr := net.ParseIP("fe80::1234:5678:9abc:def0")
+	if _, err := getClientMacAddress(duidUUID, privacyAddr); err == nil {
+		t.Error("MAC address found for privacy address")
+	}
+}
+
+func TestHandleDhcp6Packet(t *testing.T) {
+	macAddr := "52:54:0a:00:00:02"
+	clientId := []byte{0, 3, 0, 1, 0x52, 0x54, 0x0a, 0, 0, 2}
+	serverId := []byte{0, 3, 0, 1, 0x52, 0x54, 0, 0, 0, 1}
+	otherServerId := []byte{0, 3, 0, 1, 0x52, 0x54, 0, 0, 0, 9}
+	srcAddr := net.ParseIP("fe80::5054:aff:fe00:2")
+	subnet := proto.Subnet{
+		Id:                "test",
+		IpGateway:         net.IP{10, 0, 0, 1},
+		IpMask:            net.IP{255, 255, 255, 0},
+		Ipv6Gateway:       net.ParseIP("fd00::1"),
+		Ipv6Mask:          net.ParseIP("ffff:ffff:ffff:ffff::"),
+		DomainName:        "example.com",
+		DomainNameServers: []net.IP{{10, 0, 0, 1}, net.ParseIP("fd00::53")},
+	}
+	ipv6Address, err := subnet.MakeIpv6Address(macAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := &DhcpServer{
+		logger: testlogger.New(t),
+		leases: map[string]leaseType{macAddr: {
+			Address: proto.Address{
+				IpAddress:   net.IP{10, 0, 0, 2},
+				Ipv6Address: ipv6Address,
+				MacAddress:  macAddr,
+			},
+			subnet: &subnet,
+		}},
+	}
+	// IA_NA with IAID 1, no T1 or T2 and optionally an IA Address.
+	makeIaNa := func(address net.IP) []byte {
+		iaNa := &bytes.Buffer{}
+		iaNa.Write([]byte{0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0})
+		if address != nil {
+			iaAddr := make([]byte, 24) // Zero lifetimes.
+			copy(iaAddr, address.To16())
+			encodeDhcp6Option(iaNa, dhcp6OptionIaAddr, iaAddr)
+		}
+		return iaNa.Bytes()
+	}
+	tests := []struct {
+		name     string
+		msgType  byte
+		options  []dhcp6Option
+		srcAddr  net.IP
+		reply    byte // Zero if no reply is expected.
+		address  bool // If the lease address is expected.
+		status   int  // -1 if no status code is expected.
+		rapid    bool // If Rapid Commit is expected.
+		clientId []byte
+	}{
+		{"solicit", dhcp6MsgSolicit,
+			[]dhcp6Option{{dhcp6OptionIaNa, makeIaNa(nil)}}, srcAddr,
+			dhcp6MsgAdvertise, true, -1, false, clientId},
+		{"solicit rapid commit", dhcp6MsgSolicit,
+			[]dhcp6Option{{dhcp6OptionIaNa, makeIaNa(nil)},
+				{dhcp6OptionRapidCommit, nil}}, srcAddr,
+			dhcp6MsgReply, true, -1, true, clientId},
+		{"request", dhcp6MsgRequest,
+			[]dhcp6Option{{dhcp6OptionServerId, serverId},
+				{dhcp6OptionIaNa, makeIaNa(nil)}}, srcAddr,
+			dhcp6MsgReply, true, -1, false, clientId},
+		{"request for other server", dhcp6MsgRequest,
+			[]dhcp6Option{{dhcp6OptionServerId, otherServerId},
+				{dhcp6OptionIaNa, makeIaNa(nil)}}, srcAddr,
+			0, false, -1, false, clientId},
+		{"renew", dhcp6MsgRenew,
+			[]dhcp6Option{{dhcp6OptionServerId, serverId},
+				{dhcp6OptionIaNa, makeIaNa(ipv6Address)}}, srcAddr,
+			dhcp6MsgReply, true, -1, false, clientId},
+		{"confirm", dhcp6MsgConfirm,
+			[]dhcp6Option{{dhcp6OptionIaNa, makeIaNa(ipv6Address)}},
+			srcAddr, dhcp6MsgReply, false, dhcp6StatusSuccess, false,
+			clientId},
+		{"confirm moved", dhcp6MsgConfirm,
+			[]dhcp6Option{
+				{dhcp6OptionIaNa, makeIaNa(net.ParseIP("fd01::2"))}},
+			srcAddr, dhcp6MsgReply, false, dhcp6StatusNotOnLink, false,
+			clientId},
+		{"unknown client", dhcp6MsgSolicit,
+			[]dhcp6Option{{dhcp6OptionIaNa, makeIaNa(nil)}},
+			net.ParseIP("fe80::5054:aff:fe00:3"), 0, false, -1, false,
+			[]byte{0, 4, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+				16}},
+	}
+	for _, test := range tests {
+		request := &dhcp6Message{
+			msgType:       test.msgType,
+			transactionId: [3]byte{1, 2, 3},
+			options: append([]dhcp6Option{
+				{dhcp6OptionClientId, test.clientId}}, test.options...),
+		}
+		packet, err := server.handleDhcp6Packet(request.encode(),
+			test.srcAddr, serverId)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if test.reply == 0 {
+			if packet != nil {
+				t.Errorf("%s: unexpected reply", test.name)
+			}
+			continue
+		}
+		reply, err := decodeDhcp6Message(packet)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		if reply.msgType != test.reply {
+			t.Errorf("%s: reply type: %d, expected: %d",
+				test.name, reply.msgType, test.reply)
+		}
+		if reply.transactionId != request.transactionId {
+			t.Errorf("%s: transaction ID not copied", test.name)
+		}
+		if !bytes.Equal(reply.getOption(dhcp6OptionServerId), serverId) ||
+			!bytes.Equal(reply.getOption(dhcp6OptionClientId), clientId) {
+			t.Errorf("%s: bad server or client ID", test.name)
+		}
+		if reply.hasOption(dhcp6OptionRapidCommit) != test.rapid {
+			t.Errorf("%s: Rapid Commit: %v, expected: %v", test.name,
+				reply.hasOption(dhcp6OptionRapidCommit), test.rapid)
+		}
+		var address net.IP
+		if iaNa := reply.getOption(dhcp6OptionIaNa); len(iaNa) >= 12 {
+			iaOptions, err := decodeDhcp6Options(iaNa[12:])
+			if err != nil {
+				t.Errorf("%s: %s", test.name, err)
+			} else if len(iaOptions) == 1 && len(iaOptions[0].data) == 24 {
+				address = net.IP(iaOptions[0].data[:16])
+			}
+		}
+		if test.address && !address.Equal(ipv6Address) {
+			t.Errorf("%s: address: %s, expected: %s",
+				test.name, address, ipv6Address)
+		} else if !test.address && address != nil {
+			t.Errorf("%s: unexpected address: %s", test.name, address)
+		}
+		status := -1
+		if data := reply.getOption(dhcp6OptionStatusCode); len(data) >= 2 {
+			status = int(binary.BigEndian.Uint16(data))
+		}
+		if status != test.status {
+			t.Errorf("%s: status: %d, expected: %d",
+				test.name, status, test.status)
+		}
+		if test.status != dhcp6StatusNotOnLink &&
+			!bytes.Equal(reply.getOption(dhcp6OptionDnsServers),
+				net.ParseIP("fd00::53")) {
+			t.Errorf("%s: bad DNS servers", test.name)
+		}
+	}
+}
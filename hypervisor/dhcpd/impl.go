@@ -77,6 +77,9 @@ func newServer(interfaceNames []string, logger log.DebugLogger) (
 			logger.Println(err)
 		}
 	}()
+	if err := dhcpServer.startDhcp6(serveConn.ifIndices); err != nil {
+		logger.Printf("not starting DHCPv6 server: %s\n", err)
+	}
 	return dhcpServer, nil
 }
 
@@ -167,7 +170,9 @@ func (s *DhcpServer) makeOptions(subnet *proto.Subnet,
 	lease *leaseType) dhcp.Options {
 	dnsServers := make([]byte, 0)
 	for _, dnsServer := range subnet.DomainNameServers {
-		dnsServers = append(dnsServers, dnsServer...)
+		if ip4 := dnsServer.To4(); ip4 != nil {
+			dnsServers = append(dnsServers, ip4...)
+		}
 	}
 	leaseOptions := dhcp.Options{
 		dhcp.OptionSubnetMask:       subnet.IpMask,
@@ -267,7 +272,7 @@ func (s *DhcpServer) ServeDHCP(req dhcp.Packet, msgType dhcp.MessageType,
 		}
 		reqIP = util.ShrinkIP(reqIP)
 		macAddr := req.CHAddr().String()
-		s.notifyRequest(proto.Address{reqIP, macAddr})
+		s.notifyRequest(proto.Address{IpAddress: reqIP, MacAddress: macAddr})
 		server, ok := options[dhcp.OptionServerIdentifier]
 		if ok {
 			serverIP := net.IP(server)
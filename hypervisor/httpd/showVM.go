@@ -63,6 +63,10 @@ func (s state) showVMHandler(w http.ResponseWriter, req *http.Request) {
 		} else {
 			writeString(writer, "IP Address", ipAddr)
 		}
+		if len(vm.Address.Ipv6Address) > 0 {
+			writeString(writer, "IPv6 Address",
+				vm.Address.Ipv6Address.String())
+		}
 		if vm.Hostname != "" {
 			writeString(writer, "Hostname", vm.Hostname)
 		}
@@ -81,6 +85,16 @@ func (s state) showVMHandler(w http.ResponseWriter, req *http.Request) {
 		writeFloat(writer, "CPU", float64(vm.MilliCPUs)*1e-3)
 		writeStrings(writer, "Volume sizes", volumeSizes)
 		writeString(writer, "Total storage", format.FormatBytes(storage))
+		if limits := vm.Limits; limits != nil {
+			writeRate(writer, "Disk bandwidth limit", limits.DiskBytesPerSecond)
+			if limits.DiskOpsPerSecond > 0 {
+				writeUint64(writer, "Disk IOPS limit", limits.DiskOpsPerSecond)
+			}
+			writeRate(writer, "Network egress limit",
+				limits.EgressBytesPerSecond)
+			writeRate(writer, "Network ingress limit",
+				limits.IngressBytesPerSecond)
+		}
 		writeStrings(writer, "Owner users", vm.OwnerGroups)
 		writeStrings(writer, "Owner users", vm.OwnerUsers)
 		writeBool(writer, "Spread volumes", vm.SpreadVolumes)
@@ -124,6 +138,12 @@ func writeFloat(writer io.Writer, name string, value float64) {
 	fmt.Fprintf(writer, "  <tr><td>%s</td><td>%g</td></tr>\n", name, value)
 }
 
+func writeRate(writer io.Writer, name string, bytesPerSecond uint64) {
+	if bytesPerSecond > 0 {
+		writeString(writer, name, format.FormatBytes(bytesPerSecond)+"/s")
+	}
+}
+
 func writeString(writer io.Writer, name, value string) {
 	fmt.Fprintf(writer, "  <tr><td>%s</td><td>%s</td></tr>\n", name, value)
 }
@@ -159,6 +159,14 @@ func (m *Manager) getFreeAddress(ipAddr net.IP, subnetId string,
 					fmt.Errorf("address: %s not found in free pool", ipAddr)
 			}
 		}
+		address := m.addressPool.Free[foundPos]
+		if len(address.Ipv6Address) < 1 {
+			ipv6Addr, err := subnet.MakeIpv6Address(address.MacAddress)
+			if err != nil {
+				return proto.Address{}, "", err
+			}
+			address.Ipv6Address = ipv6Addr
+		}
 		addressPool := addressPoolType{
 			Free:       make([]proto.Address, 0, len(m.addressPool.Free)-1),
 			Registered: m.addressPool.Registered,
@@ -172,7 +180,6 @@ func (m *Manager) getFreeAddress(ipAddr net.IP, subnetId string,
 		if err := m.writeAddressPoolWithLock(addressPool, false); err != nil {
 			return proto.Address{}, "", err
 		}
-		address := m.addressPool.Free[foundPos]
 		m.addressPool = addressPool
 		return address, subnet.Id, nil
 	}
@@ -296,7 +303,9 @@ func (m *Manager) unregisterAddress(address proto.Address, lock bool) error {
 	}
 	addresses := make([]proto.Address, 0, len(m.addressPool.Registered)-1)
 	for _, addr := range m.addressPool.Registered {
-		if address.Equal(&addr) {
+		// The IPv6 address may be derived after registration, so ignore it.
+		if address.IpAddress.Equal(addr.IpAddress) &&
+			address.MacAddress == addr.MacAddress {
 			found = true
 		} else {
 			addresses = append(addresses, addr)
@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/Symantec/Dominator/lib/image/trust"
 	"github.com/Symantec/Dominator/lib/log"
 	"github.com/Symantec/Dominator/lib/objectserver/cachingreader"
 	"github.com/Symantec/Dominator/lib/srpc"
@@ -33,29 +34,40 @@ type Manager struct {
 	memTotalInMiB     uint64
 	numCPU            int
 	serialNumber      string
+	totalVolumeBytes  uint64
 	volumeDirectories []string
+	usageMutex        sync.Mutex // Lock vmUsage (key: IP address).
+	vmUsage           map[string]*proto.VmUsage
 	mutex             sync.RWMutex // Lock everything below (those can change).
 	addressPool       addressPoolType
+	firewallGroups    map[string]proto.FirewallRuleGroup // Key: group name.
+	firewallPeers     []proto.FirewallPeer
+	firewallRefresh   chan<- struct{}
 	healthStatus      string
 	notifiers         map[<-chan proto.Update]chan<- proto.Update
 	objectCache       *cachingreader.ObjectServer
 	ownerGroups       map[string]struct{}
 	ownerUsers        map[string]struct{}
+	quotas            []proto.OwnerQuota
 	subnets           map[string]proto.Subnet // Key: Subnet ID.
 	subnetChannels    []chan<- proto.Subnet
 	vms               map[string]*vmInfoType // Key: IP address.
 }
 
 type StartOptions struct {
-	DhcpServer         DhcpServer
-	ImageServerAddress string
-	Logger             log.DebugLogger
-	ObjectCacheBytes   uint64
-	ShowVgaConsole     bool
-	StateDir           string
-	Username           string
-	VlanIdToBridge     map[uint]string // Key: VLAN ID, value: bridge interface.
-	VolumeDirectories  []string
+	BackupServerAddress string // Object server for VM backups.
+	DhcpServer          DhcpServer
+	EnableFirewall      bool
+	ImageServerAddress  string
+	ImageTrustPolicy    *trust.Policy // If nil, all images are trusted.
+	Logger              log.DebugLogger
+	ObjectCacheBytes    uint64
+	ShowVgaConsole      bool
+	SnapshotPolicyRules []proto.SnapshotPolicyRule
+	StateDir            string
+	Username            string
+	VlanIdToBridge      map[uint]string // Key: VLAN ID, value: bridge interface.
+	VolumeDirectories   []string
 }
 
 type vmInfoType struct {
@@ -66,19 +78,33 @@ type vmInfoType struct {
 	destroyTimer               *time.Timer
 	dirname                    string
 	doNotWriteOrSend           bool
+	firewallInfo               *proto.VmInfo // Copy for firewall peers.
+	firewallMutex              sync.Mutex    // Lock firewallInfo.
 	hasHealthAgent             bool
+	incomingMigrationUri       string
 	ipAddress                  string
 	logger                     log.DebugLogger
 	manager                    *Manager
 	metadataChannels           map[chan<- string]struct{}
 	monitorSockname            string
 	ownerUsers                 map[string]struct{}
+	qmpMutex                   sync.Mutex // Lock qmpNextId and qmpWaiters.
+	qmpNextId                  uint64
+	qmpWaiters                 map[uint64]chan<- qmpResponse
+	quotaInfo                  *proto.VmInfo // Copy for quota checks.
+	quotaMutex                 sync.Mutex    // Lock quotaInfo.
 	serialInput                io.Writer
 	serialOutput               chan<- byte
 	stoppedNotifier            chan<- struct{}
 	proto.LocalVmInfo
 }
 
+// GetInstanceId returns the EC2-style instance ID for the VM with the
+// specified IP address.
+func GetInstanceId(ipAddr net.IP) string {
+	return getInstanceId(ipAddr)
+}
+
 func New(startOptions StartOptions) (*Manager, error) {
 	return newManager(startOptions)
 }
@@ -92,6 +118,16 @@ func (m *Manager) AddAddressesToPool(addresses []proto.Address) error {
 	return m.addAddressesToPool(addresses)
 }
 
+func (m *Manager) AddVmVolume(ipAddr net.IP, authInfo *srpc.AuthInformation,
+	accessToken []byte, size uint64) error {
+	return m.addVmVolume(ipAddr, authInfo, accessToken, size)
+}
+
+func (m *Manager) BackupVm(authInfo *srpc.AuthInformation,
+	request proto.BackupVmRequest) (proto.BackupVmResponse, error) {
+	return m.backupVm(authInfo, request)
+}
+
 func (m *Manager) BecomePrimaryVmOwner(ipAddr net.IP,
 	authInfo *srpc.AuthInformation) error {
 	return m.becomePrimaryVmOwner(ipAddr, authInfo)
@@ -111,11 +147,27 @@ func (m *Manager) ChangeVmDestroyProtection(ipAddr net.IP,
 	return m.changeVmDestroyProtection(ipAddr, authInfo, destroyProtection)
 }
 
+func (m *Manager) ChangeVmFirewall(ipAddr net.IP,
+	authInfo *srpc.AuthInformation, policy *proto.FirewallPolicy) error {
+	return m.changeVmFirewall(ipAddr, authInfo, policy)
+}
+
+func (m *Manager) ChangeVmLimits(ipAddr net.IP,
+	authInfo *srpc.AuthInformation, limits *proto.VmLimits) error {
+	return m.changeVmLimits(ipAddr, authInfo, limits)
+}
+
 func (m *Manager) ChangeVmOwnerUsers(ipAddr net.IP,
 	authInfo *srpc.AuthInformation, extraUsers []string) error {
 	return m.changeVmOwnerUsers(ipAddr, authInfo, extraUsers)
 }
 
+func (m *Manager) ChangeVmSize(ipAddr net.IP, authInfo *srpc.AuthInformation,
+	accessToken []byte, memoryInMiB uint64, milliCPUs uint) error {
+	return m.changeVmSize(ipAddr, authInfo, accessToken, memoryInMiB,
+		milliCPUs)
+}
+
 func (m *Manager) ChangeVmTags(ipAddr net.IP, authInfo *srpc.AuthInformation,
 	tgs tags.Tags) error {
 	return m.changeVmTags(ipAddr, authInfo, tgs)
@@ -183,8 +235,8 @@ func (m *Manager) DiscardVmOldUserData(ipAddr net.IP,
 }
 
 func (m *Manager) DiscardVmSnapshot(ipAddr net.IP,
-	authInfo *srpc.AuthInformation) error {
-	return m.discardVmSnapshot(ipAddr, authInfo)
+	authInfo *srpc.AuthInformation, name string) error {
+	return m.discardVmSnapshot(ipAddr, authInfo, name)
 }
 
 func (m *Manager) ExportLocalVm(authInfo *srpc.AuthInformation,
@@ -234,6 +286,11 @@ func (m *Manager) GetVmVolume(conn *srpc.Conn) error {
 	return m.getVmVolume(conn)
 }
 
+func (m *Manager) GrowVmVolume(ipAddr net.IP, authInfo *srpc.AuthInformation,
+	accessToken []byte, volumeIndex uint, size uint64) error {
+	return m.growVmVolume(ipAddr, authInfo, accessToken, volumeIndex, size)
+}
+
 func (m *Manager) ImportLocalVm(authInfo *srpc.AuthInformation,
 	request proto.ImportLocalVmRequest) error {
 	return m.importLocalVm(authInfo, request)
@@ -251,6 +308,11 @@ func (m *Manager) ListVMs(ownerUsers []string, doSort bool) []string {
 	return m.listVMs(ownerUsers, doSort)
 }
 
+func (m *Manager) ListVmSnapshots(ipAddr net.IP,
+	authInfo *srpc.AuthInformation) ([]proto.VmSnapshot, error) {
+	return m.listVmSnapshots(ipAddr, authInfo)
+}
+
 func (m *Manager) ListVolumeDirectories() []string {
 	return m.volumeDirectories
 }
@@ -267,6 +329,10 @@ func (m *Manager) MigrateVm(conn *srpc.Conn) error {
 	return m.migrateVm(conn)
 }
 
+func (m *Manager) MigrateVmState(conn *srpc.Conn) (string, error) {
+	return m.migrateVmState(conn)
+}
+
 func (m *Manager) NotifyVmMetadataRequest(ipAddr net.IP, path string) {
 	m.notifyVmMetadataRequest(ipAddr, path)
 }
@@ -304,9 +370,15 @@ func (m *Manager) ReplaceVmUserData(ipAddr net.IP, reader io.Reader,
 	return m.replaceVmUserData(ipAddr, reader, size, authInfo)
 }
 
+func (m *Manager) RestoreVmFromBackup(conn *srpc.Conn,
+	request proto.RestoreVmFromBackupRequest) error {
+	return m.restoreVmFromBackup(conn, request)
+}
+
 func (m *Manager) RestoreVmFromSnapshot(ipAddr net.IP,
-	authInfo *srpc.AuthInformation, forceIfNotStopped bool) error {
-	return m.restoreVmFromSnapshot(ipAddr, authInfo, forceIfNotStopped)
+	authInfo *srpc.AuthInformation, forceIfNotStopped bool,
+	name string) error {
+	return m.restoreVmFromSnapshot(ipAddr, authInfo, forceIfNotStopped, name)
 }
 
 func (m *Manager) RestoreVmImage(ipAddr net.IP,
@@ -319,13 +391,19 @@ func (m *Manager) RestoreVmUserData(ipAddr net.IP,
 	return m.restoreVmUserData(ipAddr, authInfo)
 }
 
+func (m *Manager) SetVmSnapshotPolicy(ipAddr net.IP,
+	authInfo *srpc.AuthInformation, policy *proto.SnapshotPolicy) error {
+	return m.setVmSnapshotPolicy(ipAddr, authInfo, policy)
+}
+
 func (m *Manager) ShutdownVMsAndExit() {
 	m.shutdownVMsAndExit()
 }
 
 func (m *Manager) SnapshotVm(ipAddr net.IP, authInfo *srpc.AuthInformation,
-	forceIfNotStopped, snapshotRootOnly bool) error {
-	return m.snapshotVm(ipAddr, authInfo, forceIfNotStopped, snapshotRootOnly)
+	forceIfNotStopped, snapshotRootOnly bool, name string) error {
+	return m.snapshotVm(ipAddr, authInfo, forceIfNotStopped, snapshotRootOnly,
+		name)
 }
 
 func (m *Manager) StartVm(ipAddr net.IP, authInfo *srpc.AuthInformation,
@@ -339,6 +417,14 @@ func (m *Manager) StopVm(ipAddr net.IP, authInfo *srpc.AuthInformation,
 	return m.stopVm(ipAddr, authInfo, accessToken)
 }
 
+func (m *Manager) UpdateFirewall(request proto.UpdateFirewallRequest) error {
+	return m.updateFirewall(request)
+}
+
+func (m *Manager) UpdateQuotas(request proto.UpdateQuotasRequest) error {
+	return m.updateQuotas(request)
+}
+
 func (m *Manager) UpdateSubnets(request proto.UpdateSubnetsRequest) error {
 	return m.updateSubnets(request)
 }
@@ -0,0 +1,576 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/fsutil"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/objectserver"
+	objclient "github.com/Symantec/Dominator/lib/objectserver/client"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const (
+	backupBatchSize = 16 // Number of chunks to check for at a time.
+	backupChunkSize = 4 << 20
+)
+
+type backupObjectAdder interface {
+	AddData(data []byte, hashVal hash.Hash) error
+	Close() error
+}
+
+type backupUploader struct {
+	addClient  *srpc.Client // May be nil.
+	checker    objectserver.ObjectsChecker
+	client     *srpc.Client // May be nil.
+	queue      backupObjectAdder
+	batch      []backupChunk
+	added      map[hash.Hash]struct{}
+	newBytes   uint64
+	totalBytes uint64
+}
+
+// backupSnapshot holds copies of the VM state, so that a backup may be
+// uploaded without holding the VM lock.
+type backupSnapshot struct {
+	createdOn time.Time
+	filenames []string // Copies of the volumes, removed after uploading.
+	userData  []byte
+	vmInfo    proto.VmInfo
+}
+
+type backupChunk struct {
+	data    []byte
+	hashVal hash.Hash
+}
+
+func hashData(data []byte) hash.Hash {
+	var hashVal hash.Hash
+	hasher := sha512.New()
+	hasher.Write(data)
+	copy(hashVal[:], hasher.Sum(nil))
+	return hashVal
+}
+
+func isZero(data []byte) bool {
+	for _, value := range data {
+		if value != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeBackupVmInfo returns vmInfo with empty fields filled in from the VM
+// information in the backup. Addresses are not restored.
+func mergeBackupVmInfo(vmInfo, backupVmInfo proto.VmInfo) proto.VmInfo {
+	if vmInfo.ConsoleType == proto.ConsoleNone {
+		vmInfo.ConsoleType = backupVmInfo.ConsoleType
+	}
+	vmInfo.DestroyProtection = vmInfo.DestroyProtection ||
+		backupVmInfo.DestroyProtection
+	vmInfo.DisableVirtIO = vmInfo.DisableVirtIO || backupVmInfo.DisableVirtIO
+	if vmInfo.Hostname == "" {
+		vmInfo.Hostname = backupVmInfo.Hostname
+	}
+	vmInfo.ImageName = backupVmInfo.ImageName
+	vmInfo.ImageURL = backupVmInfo.ImageURL
+	if vmInfo.MemoryInMiB < 1 {
+		vmInfo.MemoryInMiB = backupVmInfo.MemoryInMiB
+	}
+	if vmInfo.MilliCPUs < 1 {
+		vmInfo.MilliCPUs = backupVmInfo.MilliCPUs
+	}
+	if len(vmInfo.OwnerGroups) < 1 {
+		vmInfo.OwnerGroups = backupVmInfo.OwnerGroups
+	}
+	if len(vmInfo.OwnerUsers) < 1 {
+		vmInfo.OwnerUsers = backupVmInfo.OwnerUsers
+	}
+	if len(vmInfo.Tags) < 1 {
+		vmInfo.Tags = backupVmInfo.Tags
+	}
+	if len(vmInfo.SecondarySubnetIDs) < 1 {
+		vmInfo.SecondarySubnetIDs = backupVmInfo.SecondarySubnetIDs
+	}
+	if vmInfo.SubnetId == "" {
+		vmInfo.SubnetId = backupVmInfo.SubnetId
+	}
+	vmInfo.Address = proto.Address{}
+	vmInfo.SecondaryAddresses = nil
+	vmInfo.SpreadVolumes = vmInfo.SpreadVolumes || backupVmInfo.SpreadVolumes
+	vmInfo.State = proto.StateStopped
+	vmInfo.Uncommitted = false
+	vmInfo.Volumes = backupVmInfo.Volumes
+	return vmInfo
+}
+
+func readBackupManifest(objGetter objectserver.ObjectGetter,
+	manifestHash hash.Hash) (*proto.VmBackupManifest, error) {
+	_, reader, err := objGetter.GetObject(manifestHash)
+	if err != nil {
+		return nil, fmt.Errorf("error getting backup manifest: %s", err)
+	}
+	defer reader.Close()
+	var manifest proto.VmBackupManifest
+	if err := gob.NewDecoder(reader).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("error decoding backup manifest: %s", err)
+	}
+	if manifest.ChunkSize < 1 {
+		return nil, errors.New("bad chunk size in backup manifest")
+	}
+	if len(manifest.Volumes) < 1 ||
+		len(manifest.Volumes) != len(manifest.VmInfo.Volumes) {
+		return nil, errors.New("bad volumes in backup manifest")
+	}
+	return &manifest, nil
+}
+
+func restoreBackupVolume(objGetter objectserver.ObjectsGetter, filename string,
+	chunkSize uint64, volume proto.VmBackupVolume) error {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY,
+		privateFilePerms)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := file.Truncate(int64(volume.Size)); err != nil {
+		return err
+	}
+	var hashes []hash.Hash
+	var offsets []uint64
+	for index, hashVal := range volume.Chunks {
+		if hashVal != (hash.Hash{}) {
+			hashes = append(hashes, hashVal)
+			offsets = append(offsets, uint64(index)*chunkSize)
+		}
+	}
+	if len(hashes) < 1 {
+		return nil
+	}
+	objectsReader, err := objGetter.GetObjects(hashes)
+	if err != nil {
+		return err
+	}
+	defer objectsReader.Close()
+	for _, offset := range offsets {
+		length, reader, err := objectsReader.NextObject()
+		if err != nil {
+			return err
+		}
+		if offset+length > volume.Size {
+			reader.Close()
+			return fmt.Errorf("chunk at: %d extends past volume size: %d",
+				offset, volume.Size)
+		}
+		if _, err := file.Seek(int64(offset), io.SeekStart); err != nil {
+			reader.Close()
+			return err
+		}
+		_, err = io.CopyN(file, reader, int64(length))
+		reader.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Manager) backupVm(authInfo *srpc.AuthInformation,
+	request proto.BackupVmRequest) (proto.BackupVmResponse, error) {
+	var response proto.BackupVmResponse
+	address, err := m.getObjectServerAddress(request.ObjectServer)
+	if err != nil {
+		return response, err
+	}
+	vm, err := m.getVmLockAndAuth(request.IpAddress, false, authInfo, nil)
+	if err != nil {
+		return response, err
+	}
+	if vm.State != proto.StateStopped && !request.ForceIfNotStopped {
+		vm.mutex.RUnlock()
+		return response, errors.New("VM is not stopped")
+	}
+	snapshot, err := vm.snapshotForBackup(request.RootOnly)
+	logger := vm.logger
+	vm.mutex.RUnlock()
+	if err != nil {
+		return response, err
+	}
+	defer snapshot.remove()
+	uploader, err := newBackupUploader(address)
+	if err != nil {
+		return response, err
+	}
+	defer uploader.close()
+	manifestHash, err := uploader.uploadSnapshot(snapshot)
+	if err != nil {
+		return response, err
+	}
+	if err := uploader.close(); err != nil {
+		return response, err
+	}
+	logger.Printf("backed up: %s new bytes, manifest: %x\n",
+		format.FormatBytes(uploader.newBytes), manifestHash)
+	response.ManifestHash = manifestHash
+	response.NewBytes = uploader.newBytes
+	response.TotalBytes = uploader.totalBytes
+	return response, nil
+}
+
+// getObjectServerAddress returns the address of the object server to use for
+// backups. The image server is refused, since it garbage collects objects
+// which are not referenced by images.
+func (m *Manager) getObjectServerAddress(address string) (string, error) {
+	if address == "" {
+		address = m.BackupServerAddress
+	}
+	if address == "" {
+		return "", errors.New("no object server specified for backups")
+	}
+	if address == m.ImageServerAddress {
+		return "", errors.New(
+			"cannot use the image server for backups: it deletes the objects")
+	}
+	return address, nil
+}
+
+func newBackupUploader(address string) (*backupUploader, error) {
+	// Separate connections are needed since adding objects keeps a call open.
+	checkClient, err := srpc.DialHTTP("tcp", address, 0)
+	if err != nil {
+		return nil, fmt.Errorf("error connecting to object server: %s: %s",
+			address, err)
+	}
+	addClient, err := srpc.DialHTTP("tcp", address, 0)
+	if err != nil {
+		checkClient.Close()
+		return nil, fmt.Errorf("error connecting to object server: %s: %s",
+			address, err)
+	}
+	queue, err := objclient.NewObjectAdderQueue(addClient)
+	if err != nil {
+		checkClient.Close()
+		addClient.Close()
+		return nil, err
+	}
+	return &backupUploader{
+		addClient: addClient,
+		checker:   objclient.AttachObjectClient(checkClient),
+		client:    checkClient,
+		queue:     queue,
+		added:     make(map[hash.Hash]struct{}),
+	}, nil
+}
+
+func (m *Manager) restoreVmFromBackup(conn *srpc.Conn,
+	request proto.RestoreVmFromBackupRequest) error {
+	m.Logger.Debugf(1, "RestoreVmFromBackup(%s) starting\n", conn.Username())
+	if m.ImageTrustPolicy != nil {
+		return errors.New(
+			"backups cannot be verified: an image trust policy is set")
+	}
+	ownerUsers := make([]string, 1, len(request.OwnerUsers)+1)
+	ownerUsers[0] = conn.Username()
+	if ownerUsers[0] == "" {
+		return errors.New("no authentication data")
+	}
+	ownerUsers = append(ownerUsers, request.OwnerUsers...)
+	address, err := m.getObjectServerAddress(request.ObjectServer)
+	if err != nil {
+		return err
+	}
+	client, err := srpc.DialHTTP("tcp", address, 0)
+	if err != nil {
+		return fmt.Errorf("error connecting to object server: %s: %s",
+			address, err)
+	}
+	defer client.Close()
+	objClient := objclient.AttachObjectClient(client)
+	if err := sendVmBackupMessage(conn, "reading manifest"); err != nil {
+		return err
+	}
+	manifest, err := readBackupManifest(objClient, request.ManifestHash)
+	if err != nil {
+		return err
+	}
+	vmInfo := mergeBackupVmInfo(request.VmInfo, manifest.VmInfo)
+	vm, err := m.allocateVm(proto.CreateVmRequest{VmInfo: vmInfo},
+		conn.GetAuthInformation())
+	if err != nil {
+		return err
+	}
+	defer func() { // Evaluate vm at return time, not defer time.
+		vm.cleanup()
+	}()
+	vm.OwnerUsers = ownerUsers
+	vm.ownerUsers = make(map[string]struct{}, len(ownerUsers))
+	for _, username := range ownerUsers {
+		vm.ownerUsers[username] = struct{}{}
+	}
+	vm.Volumes = vmInfo.Volumes
+	if err := <-tryAllocateMemory(vmInfo.MemoryInMiB); err != nil {
+		return err
+	}
+	err = vm.setupVolumes(vmInfo.Volumes[0].Size, vmInfo.Volumes[1:],
+		vmInfo.SpreadVolumes)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(vm.dirname, dirPerms); err != nil {
+		return err
+	}
+	for index, volume := range manifest.Volumes {
+		err := sendVmBackupMessage(conn,
+			fmt.Sprintf("restoring volume: %d", index))
+		if err != nil {
+			return err
+		}
+		err = restoreBackupVolume(objClient, vm.VolumeLocations[index].Filename,
+			manifest.ChunkSize, volume)
+		if err != nil {
+			return err
+		}
+	}
+	if manifest.UserData != nil {
+		_, reader, err := objClient.GetObject(*manifest.UserData)
+		if err != nil {
+			return err
+		}
+		defer reader.Close()
+		filename := filepath.Join(vm.dirname, "user-data.raw")
+		file, err := os.OpenFile(filename,
+			os.O_CREATE|os.O_TRUNC|os.O_WRONLY, privateFilePerms)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		if _, err := io.Copy(file, reader); err != nil {
+			return err
+		}
+	}
+	vm.setState(proto.StateStopped)
+	vm.destroyTimer = time.AfterFunc(time.Second*15, vm.autoDestroy)
+	response := proto.RestoreVmFromBackupResponse{
+		Final:     true,
+		IpAddress: vm.Address.IpAddress,
+	}
+	if err := conn.Encode(response); err != nil {
+		return err
+	}
+	vm = nil // Cancel cleanup.
+	m.Logger.Debugln(1, "RestoreVmFromBackup() finished")
+	return nil
+}
+
+// remove will remove the copies of the volumes.
+func (snapshot *backupSnapshot) remove() {
+	for _, filename := range snapshot.filenames {
+		os.Remove(filename)
+	}
+	snapshot.filenames = nil
+}
+
+func sendVmBackupMessage(conn *srpc.Conn, message string) error {
+	response := proto.RestoreVmFromBackupResponse{ProgressMessage: message}
+	if err := conn.Encode(response); err != nil {
+		return err
+	}
+	return conn.Flush()
+}
+
+// snapshotForBackup will copy the VM information, user data and volumes. The
+// volume copies are stored alongside the volumes, so there must be sufficient
+// free space in the volume directories. The VM lock must be held.
+func (vm *vmInfoType) snapshotForBackup(rootOnly bool) (
+	*backupSnapshot, error) {
+	snapshot := &backupSnapshot{createdOn: time.Now(), vmInfo: vm.VmInfo}
+	volumes := vm.VolumeLocations
+	if rootOnly && len(volumes) > 1 {
+		volumes = volumes[:1]
+		snapshot.vmInfo.Volumes = snapshot.vmInfo.Volumes[:1]
+	}
+	neededSpace := make(map[string]int64) // Key: directory.
+	for _, volume := range volumes {
+		fi, err := os.Stat(volume.Filename)
+		if err != nil {
+			return nil, err
+		}
+		neededSpace[filepath.Dir(volume.Filename)] += fi.Size()
+	}
+	if err := checkSnapshotSpace(neededSpace); err != nil {
+		return nil, err
+	}
+	for _, volume := range volumes {
+		file, err := ioutil.TempFile(filepath.Dir(volume.Filename),
+			filepath.Base(volume.Filename)+".backup.")
+		if err != nil {
+			snapshot.remove()
+			return nil, err
+		}
+		file.Close()
+		snapshot.filenames = append(snapshot.filenames, file.Name())
+		err = fsutil.CopyFile(file.Name(), volume.Filename, privateFilePerms)
+		if err != nil {
+			snapshot.remove()
+			return nil, err
+		}
+	}
+	userData, err := ioutil.ReadFile(filepath.Join(vm.dirname,
+		"user-data.raw"))
+	if err != nil && !os.IsNotExist(err) {
+		snapshot.remove()
+		return nil, err
+	}
+	snapshot.userData = userData
+	return snapshot, nil
+}
+
+func (uploader *backupUploader) add(data []byte) (hash.Hash, error) {
+	hashVal := hashData(data)
+	uploader.totalBytes += uint64(len(data))
+	uploader.batch = append(uploader.batch,
+		backupChunk{data: data, hashVal: hashVal})
+	if len(uploader.batch) >= backupBatchSize {
+		if err := uploader.flush(); err != nil {
+			return hashVal, err
+		}
+	}
+	return hashVal, nil
+}
+
+func (uploader *backupUploader) close() error {
+	if uploader.queue == nil {
+		return nil
+	}
+	err := uploader.flush()
+	if e := uploader.queue.Close(); err == nil {
+		err = e
+	}
+	uploader.queue = nil
+	if uploader.addClient != nil {
+		uploader.addClient.Close()
+	}
+	if uploader.client != nil {
+		uploader.client.Close()
+	}
+	return err
+}
+
+// flush will add the objects in the batch which are not already present in the
+// object server.
+func (uploader *backupUploader) flush() error {
+	if len(uploader.batch) < 1 {
+		return nil
+	}
+	hashes := make([]hash.Hash, 0, len(uploader.batch))
+	for _, chunk := range uploader.batch {
+		hashes = append(hashes, chunk.hashVal)
+	}
+	sizes, err := uploader.checker.CheckObjects(hashes)
+	if err != nil {
+		return err
+	}
+	for index, chunk := range uploader.batch {
+		if sizes[index] > 0 {
+			continue
+		}
+		if _, ok := uploader.added[chunk.hashVal]; ok {
+			continue
+		}
+		err := uploader.queue.AddData(chunk.data, chunk.hashVal)
+		if err != nil {
+			return err
+		}
+		uploader.added[chunk.hashVal] = struct{}{}
+		uploader.newBytes += uint64(len(chunk.data))
+	}
+	uploader.batch = uploader.batch[:0]
+	return nil
+}
+
+// uploadSnapshot will upload the snapshot and a manifest for it. The hash of
+// the manifest is returned.
+func (uploader *backupUploader) uploadSnapshot(snapshot *backupSnapshot) (
+	hash.Hash, error) {
+	manifest := proto.VmBackupManifest{
+		ChunkSize: backupChunkSize,
+		CreatedOn: snapshot.createdOn,
+		VmInfo:    snapshot.vmInfo,
+	}
+	for _, filename := range snapshot.filenames {
+		backupVolume, err := uploader.uploadFile(filename)
+		if err != nil {
+			return hash.Hash{}, err
+		}
+		manifest.Volumes = append(manifest.Volumes, *backupVolume)
+	}
+	if len(snapshot.userData) > 0 {
+		hashVal, err := uploader.uploadData(snapshot.userData)
+		if err != nil {
+			return hash.Hash{}, err
+		}
+		manifest.UserData = &hashVal
+	}
+	buffer := &bytes.Buffer{}
+	if err := gob.NewEncoder(buffer).Encode(manifest); err != nil {
+		return hash.Hash{}, err
+	}
+	return uploader.uploadData(buffer.Bytes())
+}
+
+func (uploader *backupUploader) uploadData(data []byte) (hash.Hash, error) {
+	hashVal, err := uploader.add(data)
+	if err != nil {
+		return hashVal, err
+	}
+	return hashVal, uploader.flush()
+}
+
+// uploadFile will split the file into chunks and upload the chunks which are
+// not zero-filled.
+func (uploader *backupUploader) uploadFile(filename string) (
+	*proto.VmBackupVolume, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	fi, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	volume := &proto.VmBackupVolume{Size: uint64(fi.Size())}
+	for offset := uint64(0); offset < volume.Size; offset += backupChunkSize {
+		length := volume.Size - offset
+		if length > backupChunkSize {
+			length = backupChunkSize
+		}
+		data := make([]byte, length) // Not re-used: the queue sends later.
+		if _, err := io.ReadFull(file, data); err != nil {
+			return nil, err
+		}
+		if isZero(data) {
+			volume.Chunks = append(volume.Chunks, hash.Hash{})
+			continue
+		}
+		hashVal, err := uploader.add(data)
+		if err != nil {
+			return nil, err
+		}
+		volume.Chunks = append(volume.Chunks, hashVal)
+	}
+	return volume, nil
+}
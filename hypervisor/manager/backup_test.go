@@ -0,0 +1,182 @@
+package manager
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/objectserver/memory"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+type testObjectAdder struct {
+	objSrv *memory.ObjectServer
+}
+
+func (adder *testObjectAdder) AddData(data []byte, hashVal hash.Hash) error {
+	_, _, err := adder.objSrv.AddObject(bytes.NewReader(data),
+		uint64(len(data)), &hashVal)
+	return err
+}
+
+func (adder *testObjectAdder) Close() error {
+	return nil
+}
+
+// writeTestVolume writes a volume with a data chunk, a zero-filled chunk and
+// a partial chunk with the same data, returning the contents.
+func writeTestVolume(t *testing.T, filename string) []byte {
+	data := make([]byte, backupChunkSize*2+backupChunkSize/2)
+	for index := range data[:backupChunkSize] {
+		data[index] = byte(index % 251)
+	}
+	copy(data[backupChunkSize*2:], data[:backupChunkSize/2])
+	if err := ioutil.WriteFile(filename, data, privateFilePerms); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestBackupAndRestore(t *testing.T) {
+	_, vm, topDir := makeTestVm(t)
+	defer os.RemoveAll(topDir)
+	rootData := writeTestVolume(t, vm.VolumeLocations[0].Filename)
+	secondaryFilename := filepath.Join(vm.dirname, "secondary-volume.0")
+	secondaryData := writeTestVolume(t, secondaryFilename)
+	vm.VolumeLocations = append(vm.VolumeLocations, proto.LocalVolume{
+		DirectoryToCleanup: vm.dirname,
+		Filename:           secondaryFilename,
+	})
+	vm.Volumes = []proto.Volume{
+		{Size: uint64(len(rootData))},
+		{Size: uint64(len(secondaryData))},
+	}
+	userData := []byte("#!/bin/sh\n")
+	err := ioutil.WriteFile(filepath.Join(vm.dirname, "user-data.raw"),
+		userData, privateFilePerms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	snapshot, err := vm.snapshotForBackup(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snapshot.remove()
+	// Changes after the snapshot must not be backed up.
+	err = ioutil.WriteFile(vm.VolumeLocations[0].Filename, []byte("changed"),
+		privateFilePerms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	objSrv := memory.NewObjectServer()
+	uploader := &backupUploader{
+		checker: objSrv,
+		queue:   &testObjectAdder{objSrv},
+		added:   make(map[hash.Hash]struct{}),
+	}
+	manifestHash, err := uploader.uploadSnapshot(snapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := uploader.close(); err != nil {
+		t.Fatal(err)
+	}
+	copies := snapshot.filenames
+	snapshot.remove()
+	for _, filename := range copies {
+		if _, err := os.Stat(filename); !os.IsNotExist(err) {
+			t.Errorf("volume copy: %s not removed", filename)
+		}
+	}
+	// The full chunk is shared by both volumes and the partial chunk.
+	if uploader.newBytes >= uploader.totalBytes {
+		t.Errorf("new bytes: %d not less than total bytes: %d",
+			uploader.newBytes, uploader.totalBytes)
+	}
+	manifest, err := readBackupManifest(objSrv, manifestHash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(manifest.Volumes) != 2 || manifest.UserData == nil {
+		t.Fatalf("bad manifest: %v", manifest)
+	}
+	if manifest.Volumes[0].Chunks[1] != (hash.Hash{}) {
+		t.Error("zero-filled chunk stored")
+	}
+	for index, expected := range [][]byte{rootData, secondaryData} {
+		filename := filepath.Join(topDir, "restored")
+		err := restoreBackupVolume(objSrv, filename, manifest.ChunkSize,
+			manifest.Volumes[index])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, err := ioutil.ReadFile(filename); err != nil {
+			t.Fatal(err)
+		} else if !bytes.Equal(data, expected) {
+			t.Errorf("volume: %d not restored", index)
+		}
+	}
+	_, reader, err := objSrv.GetObject(*manifest.UserData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	if data, err := ioutil.ReadAll(reader); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(data, userData) {
+		t.Error("user data not restored")
+	}
+	vmInfo := mergeBackupVmInfo(proto.VmInfo{Hostname: "restored"},
+		manifest.VmInfo)
+	if vmInfo.Hostname != "restored" || vmInfo.MemoryInMiB != 1024 ||
+		vmInfo.State != proto.StateStopped || len(vmInfo.Volumes) != 2 {
+		t.Errorf("bad restored VM information: %v", vmInfo)
+	}
+	// A second backup only needs to upload a new manifest.
+	rootOnly, err := vm.snapshotForBackup(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rootOnly.remove()
+	uploader = &backupUploader{
+		checker: objSrv,
+		queue:   &testObjectAdder{objSrv},
+		added:   make(map[hash.Hash]struct{}),
+	}
+	if _, err := uploader.uploadSnapshot(rootOnly); err != nil {
+		t.Fatal(err)
+	}
+	if len(rootOnly.vmInfo.Volumes) != 1 {
+		t.Errorf("root only backup has: %d volumes",
+			len(rootOnly.vmInfo.Volumes))
+	}
+	if uploader.newBytes >= 4096 {
+		t.Errorf("unchanged data uploaded: %d new bytes", uploader.newBytes)
+	}
+}
+
+func TestGetObjectServerAddress(t *testing.T) {
+	m := &Manager{StartOptions: StartOptions{
+		ImageServerAddress: "imageserver:6971",
+	}}
+	if _, err := m.getObjectServerAddress(""); err == nil {
+		t.Error("no error without a backup server")
+	}
+	if _, err := m.getObjectServerAddress("imageserver:6971"); err == nil {
+		t.Error("no error for image server")
+	}
+	m.BackupServerAddress = "backupserver:6971"
+	if address, err := m.getObjectServerAddress(""); err != nil {
+		t.Error(err)
+	} else if address != "backupserver:6971" {
+		t.Errorf("address: %s, expected: backupserver:6971", address)
+	}
+	if address, err := m.getObjectServerAddress("other:6971"); err != nil {
+		t.Error(err)
+	} else if address != "other:6971" {
+		t.Errorf("address: %s, expected: other:6971", address)
+	}
+}
@@ -0,0 +1,139 @@
+package manager
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/Symantec/Dominator/lib/fsutil"
+	"github.com/Symantec/Dominator/lib/json"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const configDriveFilename = "config-drive.iso"
+
+var isoCommands = []string{"genisoimage", "mkisofs", "xorrisofs"}
+
+type ethernetConfig struct {
+	Dhcp4   bool        `json:"dhcp4"`
+	Dhcp6   bool        `json:"dhcp6,omitempty"`
+	Match   ethernetMac `json:"match"`
+	SetName string      `json:"set-name"`
+}
+
+type ethernetMac struct {
+	MacAddress string `json:"macaddress"`
+}
+
+// networkConfig is a cloud-init network configuration (version 2). Since JSON
+// is valid YAML, it is written as JSON.
+type networkConfig struct {
+	Ethernets map[string]ethernetConfig `json:"ethernets"`
+	Version   uint                      `json:"version"`
+}
+
+type noCloudMetadata struct {
+	InstanceId    string   `json:"instance-id"`
+	LocalHostname string   `json:"local-hostname"`
+	PublicKeys    []string `json:"public-keys,omitempty"`
+}
+
+// findIsoCommand returns the first command available for making ISO images.
+func findIsoCommand() (string, error) {
+	for _, command := range isoCommands {
+		if _, err := exec.LookPath(command); err == nil {
+			return command, nil
+		}
+	}
+	return "", fmt.Errorf("cannot make config drive: none of %s found",
+		strings.Join(isoCommands, ", "))
+}
+
+func getInstanceId(ipAddr net.IP) string {
+	if ip4 := ipAddr.To4(); ip4 != nil {
+		return "i-" + hex.EncodeToString(ip4)
+	}
+	return "i-" + hex.EncodeToString(ipAddr)
+}
+
+func makeNetworkConfig(vmInfo proto.VmInfo) networkConfig {
+	config := networkConfig{
+		Ethernets: make(map[string]ethernetConfig),
+		Version:   2,
+	}
+	addresses := append([]proto.Address{vmInfo.Address},
+		vmInfo.SecondaryAddresses...)
+	for index, address := range addresses {
+		name := fmt.Sprintf("eth%d", index)
+		config.Ethernets[name] = ethernetConfig{
+			Dhcp4:   true,
+			Dhcp6:   len(address.Ipv6Address) > 0,
+			Match:   ethernetMac{address.MacAddress},
+			SetName: name,
+		}
+	}
+	return config
+}
+
+func writeJsonFile(filename string, value interface{}) error {
+	return json.WriteToFile(filename, publicFilePerms, "    ", value)
+}
+
+// writeConfigDrive writes a NoCloud ISO image containing the meta-data,
+// network configuration and user-data for the VM, which is attached to the VM
+// as a CD-ROM drive. It is re-written each time the VM is started so that it
+// reflects the current VM information. The VM lock must be held.
+func (vm *vmInfoType) writeConfigDrive() error {
+	isoCommand, err := findIsoCommand()
+	if err != nil {
+		return err
+	}
+	tmpDir, err := ioutil.TempDir(vm.dirname, "config-drive")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	metadata := noCloudMetadata{
+		InstanceId:    getInstanceId(vm.Address.IpAddress),
+		LocalHostname: vm.GetLocalHostname(vm.Address.IpAddress),
+		PublicKeys:    vm.SshPublicKeys,
+	}
+	err = writeJsonFile(filepath.Join(tmpDir, "meta-data"), metadata)
+	if err != nil {
+		return err
+	}
+	err = writeJsonFile(filepath.Join(tmpDir, "network-config"),
+		makeNetworkConfig(vm.VmInfo))
+	if err != nil {
+		return err
+	}
+	userDataFilename := filepath.Join(vm.dirname, "user-data.raw")
+	if _, err := os.Stat(userDataFilename); err == nil {
+		err := fsutil.CopyFile(filepath.Join(tmpDir, "user-data"),
+			userDataFilename, privateFilePerms)
+		if err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	} else {
+		err := ioutil.WriteFile(filepath.Join(tmpDir, "user-data"), nil,
+			privateFilePerms)
+		if err != nil {
+			return err
+		}
+	}
+	filename := filepath.Join(vm.dirname, configDriveFilename)
+	cmd := exec.Command(isoCommand, "-o", filename, "-V", "cidata", "-J",
+		"-R", "-quiet", tmpDir)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error running %s: %s: %s",
+			isoCommand, err, string(output))
+	}
+	return os.Chmod(filename, privateFilePerms)
+}
@@ -0,0 +1,137 @@
+package manager
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+// fakeIsoCommand copies the files for the ISO image into a directory named
+// after the image, so that they may be checked.
+const fakeIsoCommand = `#!/bin/sh
+for dir; do :; done
+mkdir "$2.files" && cp "$dir"/* "$2.files" && touch "$2"
+`
+
+func TestMakeNetworkConfig(t *testing.T) {
+	tests := []struct {
+		vmInfo   proto.VmInfo
+		expected map[string]ethernetConfig
+	}{
+		{
+			proto.VmInfo{Address: proto.Address{
+				IpAddress:  net.IP{10, 0, 0, 2},
+				MacAddress: "52:54:0a:00:00:02",
+			}},
+			map[string]ethernetConfig{
+				"eth0": {Dhcp4: true, Match: ethernetMac{"52:54:0a:00:00:02"},
+					SetName: "eth0"},
+			},
+		},
+		{
+			proto.VmInfo{
+				Address: proto.Address{
+					IpAddress:   net.IP{10, 0, 0, 2},
+					Ipv6Address: net.ParseIP("fd00::5054:aff:fe00:2"),
+					MacAddress:  "52:54:0a:00:00:02",
+				},
+				SecondaryAddresses: []proto.Address{{
+					IpAddress:  net.IP{10, 0, 1, 2},
+					MacAddress: "52:54:0a:00:01:02",
+				}},
+			},
+			map[string]ethernetConfig{
+				"eth0": {Dhcp4: true, Dhcp6: true,
+					Match: ethernetMac{"52:54:0a:00:00:02"}, SetName: "eth0"},
+				"eth1": {Dhcp4: true, Match: ethernetMac{"52:54:0a:00:01:02"},
+					SetName: "eth1"},
+			},
+		},
+	}
+	for _, test := range tests {
+		config := makeNetworkConfig(test.vmInfo)
+		if config.Version != 2 {
+			t.Errorf("%s: version: %d", test.vmInfo.Address, config.Version)
+		}
+		if !reflect.DeepEqual(config.Ethernets, test.expected) {
+			t.Errorf("%s: expected: %v, got: %v",
+				test.vmInfo.Address, test.expected, config.Ethernets)
+		}
+	}
+}
+
+func TestWriteConfigDrive(t *testing.T) {
+	_, vm, topDir := makeTestVm(t)
+	defer os.RemoveAll(topDir)
+	binDir := filepath.Join(topDir, "bin")
+	if err := os.Mkdir(binDir, dirPerms); err != nil {
+		t.Fatal(err)
+	}
+	err := ioutil.WriteFile(filepath.Join(binDir, isoCommands[0]),
+		[]byte(fakeIsoCommand), 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", binDir+":/bin:/usr/bin")
+	vm.Address = proto.Address{
+		IpAddress:  net.IP{10, 0, 0, 2},
+		MacAddress: "52:54:0a:00:00:02",
+	}
+	vm.SshPublicKeys = []string{"ssh-ed25519 AAAA alice@example"}
+	userData := []byte("#cloud-config\n")
+	err = ioutil.WriteFile(filepath.Join(vm.dirname, "user-data.raw"),
+		userData, privateFilePerms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.writeConfigDrive(); err != nil {
+		t.Fatal(err)
+	}
+	filesDir := filepath.Join(vm.dirname, configDriveFilename+".files")
+	var metadata noCloudMetadata
+	if data, err := ioutil.ReadFile(
+		filepath.Join(filesDir, "meta-data")); err != nil {
+		t.Fatal(err)
+	} else if err := json.Unmarshal(data, &metadata); err != nil {
+		t.Fatal(err)
+	}
+	expected := noCloudMetadata{
+		InstanceId:    "i-0a000002",
+		LocalHostname: "ip-10-0-0-2",
+		PublicKeys:    vm.SshPublicKeys,
+	}
+	if !reflect.DeepEqual(metadata, expected) {
+		t.Errorf("meta-data: expected: %v, got: %v", expected, metadata)
+	}
+	var config networkConfig
+	if data, err := ioutil.ReadFile(
+		filepath.Join(filesDir, "network-config")); err != nil {
+		t.Fatal(err)
+	} else if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(config, makeNetworkConfig(vm.VmInfo)) {
+		t.Errorf("bad network-config: %v", config)
+	}
+	if data, err := ioutil.ReadFile(
+		filepath.Join(filesDir, "user-data")); err != nil {
+		t.Fatal(err)
+	} else if string(data) != string(userData) {
+		t.Errorf("user-data: %q", string(data))
+	}
+	if _, err := os.Stat(
+		filepath.Join(vm.dirname, configDriveFilename)); err != nil {
+		t.Error(err)
+	}
+	os.Setenv("PATH", topDir)
+	if err := vm.writeConfigDrive(); err == nil {
+		t.Error("no error without ISO command")
+	}
+}
@@ -0,0 +1,549 @@
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/json"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/lib/tags"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const (
+	firewallFilename   = "firewall.json"
+	firewallTable      = "bridge hypervisor"
+	metadataIpAddress  = "169.254.169.254"
+	firewallSettleTime = time.Second
+)
+
+type firewallInterface struct {
+	address proto.Address
+	tapName string
+}
+
+type firewallRuleSet struct {
+	egress          []proto.FirewallRule
+	ingress         []proto.FirewallRule
+	restrictEgress  bool
+	restrictIngress bool
+	usesTags        bool
+}
+
+// getFirewallRules expands the groups in the policy. An error is returned if
+// the policy references an unknown group.
+func getFirewallRules(policy *proto.FirewallPolicy,
+	groups map[string]proto.FirewallRuleGroup) (firewallRuleSet, error) {
+	var ruleSet firewallRuleSet
+	if policy == nil {
+		return ruleSet, nil
+	}
+	ruleSet.egress = append(ruleSet.egress, policy.Egress...)
+	ruleSet.ingress = append(ruleSet.ingress, policy.Ingress...)
+	for _, name := range policy.Groups {
+		group, ok := groups[name]
+		if !ok {
+			return firewallRuleSet{}, errors.New("unknown firewall group: " +
+				name)
+		}
+		ruleSet.egress = append(ruleSet.egress, group.Egress...)
+		ruleSet.ingress = append(ruleSet.ingress, group.Ingress...)
+	}
+	ruleSet.restrictEgress = len(ruleSet.egress) > 0
+	ruleSet.restrictIngress = true
+	for _, rules := range [][]proto.FirewallRule{ruleSet.egress,
+		ruleSet.ingress} {
+		for _, rule := range rules {
+			if len(rule.RemoteTags) > 0 {
+				ruleSet.usesTags = true
+			}
+		}
+	}
+	return ruleSet, nil
+}
+
+func makeFirewallRemoveScript(tapNames []string) string {
+	// Scripts are applied atomically and deleting a missing element or chain
+	// is an error, so ensure they exist first.
+	buffer := &bytes.Buffer{}
+	for _, tapName := range tapNames {
+		for _, direction := range []string{"egress", "ingress"} {
+			fmt.Fprintf(buffer, "add chain %s %s_%s\n",
+				firewallTable, direction, tapName)
+			fmt.Fprintf(buffer,
+				"add element %s %s_taps { \"%s\" : jump %s_%s }\n",
+				firewallTable, direction, tapName, direction, tapName)
+			fmt.Fprintf(buffer, "delete element %s %s_taps { \"%s\" }\n",
+				firewallTable, direction, tapName)
+			fmt.Fprintf(buffer, "delete chain %s %s_%s\n",
+				firewallTable, direction, tapName)
+		}
+	}
+	return buffer.String()
+}
+
+// makeFirewallRuleMatches returns the nftables matches for a rule. The remote
+// address is matched with direction ("daddr" or "saddr"). The peer addresses
+// are the addresses of VMs matching the remote tags. If there is nothing to
+// match (remote tags match no VMs), nothing is returned.
+func makeFirewallRuleMatches(rule proto.FirewallRule, direction string,
+	peerAddresses []net.IP) []string {
+	var ip4Addrs, ip6Addrs []string
+	for _, network := range rule.Networks {
+		var ipAddr net.IP
+		if strings.Contains(network, "/") {
+			ipAddr, _, _ = net.ParseCIDR(network)
+		} else {
+			ipAddr = net.ParseIP(network)
+		}
+		if ipAddr == nil {
+			continue
+		}
+		if ipAddr.To4() == nil {
+			ip6Addrs = append(ip6Addrs, network)
+		} else {
+			ip4Addrs = append(ip4Addrs, network)
+		}
+	}
+	for _, ipAddr := range peerAddresses {
+		if ipAddr.To4() == nil {
+			ip6Addrs = append(ip6Addrs, ipAddr.String())
+		} else {
+			ip4Addrs = append(ip4Addrs, ipAddr.String())
+		}
+	}
+	var matches []string
+	if len(rule.Networks) < 1 && len(rule.RemoteTags) < 1 {
+		matches = append(matches, makeFirewallProtocolMatch(rule, ""))
+	}
+	if len(ip4Addrs) > 0 {
+		matches = append(matches, fmt.Sprintf("ip %s { %s }%s", direction,
+			strings.Join(ip4Addrs, ", "),
+			makeFirewallProtocolMatch(rule, "ip")))
+	}
+	if len(ip6Addrs) > 0 {
+		matches = append(matches, fmt.Sprintf("ip6 %s { %s }%s", direction,
+			strings.Join(ip6Addrs, ", "),
+			makeFirewallProtocolMatch(rule, "ip6")))
+	}
+	return matches
+}
+
+// makeFirewallProtocolMatch returns the protocol and port match for a rule,
+// with a leading space. The family may be "ip", "ip6" or empty for both.
+func makeFirewallProtocolMatch(rule proto.FirewallRule, family string) string {
+	switch rule.Protocol {
+	case "":
+		return ""
+	case "icmp":
+		switch family {
+		case "ip":
+			return " meta l4proto icmp"
+		case "ip6":
+			return " meta l4proto icmpv6"
+		default:
+			return " meta l4proto { icmp, icmpv6 }"
+		}
+	}
+	if rule.FirstPort == 0 && rule.LastPort == 0 {
+		return " meta l4proto " + rule.Protocol
+	}
+	if rule.LastPort == 0 || rule.LastPort == rule.FirstPort {
+		return fmt.Sprintf(" %s dport %d", rule.Protocol, rule.FirstPort)
+	}
+	return fmt.Sprintf(" %s dport %d-%d", rule.Protocol, rule.FirstPort,
+		rule.LastPort)
+}
+
+// makeFirewallScript returns the nftables script to program the firewall for
+// the VM interfaces. The getPeerAddresses function is called to get the
+// addresses of the VMs which match the remote tags in a rule.
+func makeFirewallScript(interfaces []firewallInterface,
+	ruleSet firewallRuleSet,
+	getPeerAddresses func(tags.Tags) []net.IP) string {
+	buffer := &bytes.Buffer{}
+	peerAddressesCache := make(map[string][]net.IP)
+	getAddresses := func(remoteTags tags.Tags) []net.IP {
+		if len(remoteTags) < 1 {
+			return nil
+		}
+		key := remoteTags.String()
+		if addresses, ok := peerAddressesCache[key]; ok {
+			return addresses
+		}
+		addresses := getPeerAddresses(remoteTags)
+		peerAddressesCache[key] = addresses
+		return addresses
+	}
+	for _, iface := range interfaces {
+		egress := fmt.Sprintf("%s egress_%s", firewallTable, iface.tapName)
+		ingress := fmt.Sprintf("%s ingress_%s", firewallTable, iface.tapName)
+		fmt.Fprintf(buffer, "add chain %s\n", egress)
+		fmt.Fprintf(buffer, "flush chain %s\n", egress)
+		fmt.Fprintf(buffer, "add chain %s\n", ingress)
+		fmt.Fprintf(buffer, "flush chain %s\n", ingress)
+		// Anti-spoofing.
+		macAddr := iface.address.MacAddress
+		fmt.Fprintf(buffer, "add rule %s ether saddr != %s drop\n",
+			egress, macAddr)
+		fmt.Fprintf(buffer,
+			"add rule %s ether type arp arp saddr ether != %s drop\n",
+			egress, macAddr)
+		if ipAddr := iface.address.IpAddress; len(ipAddr) > 0 {
+			fmt.Fprintf(buffer,
+				"add rule %s ether type arp arp saddr ip != { 0.0.0.0, %s } drop\n",
+				egress, ipAddr)
+			fmt.Fprintf(buffer,
+				"add rule %s ip saddr 0.0.0.0 udp sport 68 udp dport 67 accept\n",
+				egress)
+			fmt.Fprintf(buffer, "add rule %s ether type ip ip saddr != %s drop\n",
+				egress, ipAddr)
+		}
+		if ipAddr := iface.address.Ipv6Address; len(ipAddr) > 0 {
+			fmt.Fprintf(buffer,
+				"add rule %s ether type ip6 ip6 saddr != { ::, fe80::/10, %s } drop\n",
+				egress, ipAddr)
+		} else {
+			fmt.Fprintf(buffer,
+				"add rule %s ether type ip6 ip6 saddr != { ::, fe80::/10 } drop\n",
+				egress)
+		}
+		// Always allowed.
+		fmt.Fprintf(buffer, "add rule %s ether type arp accept\n", egress)
+		fmt.Fprintf(buffer, "add rule %s udp sport 68 udp dport 67 accept\n",
+			egress)
+		fmt.Fprintf(buffer, "add rule %s udp sport 546 udp dport 547 accept\n",
+			egress)
+		fmt.Fprintf(buffer, "add rule %s meta l4proto icmpv6 accept\n", egress)
+		fmt.Fprintf(buffer, "add rule %s ip daddr %s accept\n",
+			egress, metadataIpAddress)
+		fmt.Fprintf(buffer, "add rule %s ct state established,related accept\n",
+			egress)
+		fmt.Fprintf(buffer, "add rule %s ether type arp accept\n", ingress)
+		fmt.Fprintf(buffer, "add rule %s udp sport 67 udp dport 68 accept\n",
+			ingress)
+		fmt.Fprintf(buffer, "add rule %s udp sport 547 udp dport 546 accept\n",
+			ingress)
+		fmt.Fprintf(buffer, "add rule %s meta l4proto icmpv6 accept\n", ingress)
+		fmt.Fprintf(buffer,
+			"add rule %s ct state established,related accept\n", ingress)
+		for _, rule := range ruleSet.egress {
+			for _, match := range makeFirewallRuleMatches(rule, "daddr",
+				getAddresses(rule.RemoteTags)) {
+				fmt.Fprintf(buffer, "add rule %s%s accept\n", egress,
+					prefixSpace(match))
+			}
+		}
+		for _, rule := range ruleSet.ingress {
+			for _, match := range makeFirewallRuleMatches(rule, "saddr",
+				getAddresses(rule.RemoteTags)) {
+				fmt.Fprintf(buffer, "add rule %s%s accept\n", ingress,
+					prefixSpace(match))
+			}
+		}
+		if ruleSet.restrictEgress {
+			fmt.Fprintf(buffer, "add rule %s drop\n", egress)
+		}
+		if ruleSet.restrictIngress {
+			fmt.Fprintf(buffer, "add rule %s drop\n", ingress)
+		}
+		fmt.Fprintf(buffer,
+			"add element %s egress_taps { \"%s\" : jump egress_%s }\n",
+			firewallTable, iface.tapName, iface.tapName)
+		fmt.Fprintf(buffer,
+			"add element %s ingress_taps { \"%s\" : jump ingress_%s }\n",
+			firewallTable, iface.tapName, iface.tapName)
+	}
+	return buffer.String()
+}
+
+func prefixSpace(match string) string {
+	if match == "" || strings.HasPrefix(match, " ") {
+		return match
+	}
+	return " " + match
+}
+
+func runNft(script string) error {
+	cmd := exec.Command("nft", "-f", "-")
+	cmd.Stdin = strings.NewReader(script)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error running nft: %s: %s", err, output)
+	}
+	return nil
+}
+
+func (m *Manager) changeVmFirewall(ipAddr net.IP,
+	authInfo *srpc.AuthInformation, policy *proto.FirewallPolicy) error {
+	if err := m.checkFirewallPolicy(policy); err != nil {
+		return err
+	}
+	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, nil)
+	if err != nil {
+		return err
+	}
+	defer vm.mutex.Unlock()
+	vm.Firewall = policy
+	if vm.commandChannel != nil {
+		if err := vm.programFirewall(false); err != nil {
+			return err
+		}
+	}
+	vm.writeAndSendInfo()
+	return nil
+}
+
+func (m *Manager) checkFirewallPolicy(policy *proto.FirewallPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	if !m.EnableFirewall {
+		return errors.New("firewall not enabled")
+	}
+	if err := policy.CheckValid(); err != nil {
+		return err
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	_, err := getFirewallRules(policy, m.firewallGroups)
+	return err
+}
+
+// firewallLoop will re-program the firewalls which reference other VMs by tag
+// when VMs change.
+func (m *Manager) firewallLoop(refreshChannel <-chan struct{}) {
+	for range refreshChannel {
+		time.Sleep(firewallSettleTime) // Coalesce bursts of changes.
+		select {
+		case <-refreshChannel:
+		default:
+		}
+		m.refreshFirewalls(false)
+	}
+}
+
+// getFirewallPeerAddresses returns the addresses of the local and remote VMs
+// which have all the tags. The copies recorded by updateFirewallInfo are used,
+// so that VM locks are not needed. The manager lock must be held.
+func (m *Manager) getFirewallPeerAddresses(remoteTags tags.Tags) []net.IP {
+	var addresses []net.IP
+	for _, vm := range m.vms {
+		vmInfo := vm.getFirewallInfo()
+		if vmInfo == nil || !matchTags(vmInfo.Tags, remoteTags) {
+			continue
+		}
+		if len(vmInfo.Address.IpAddress) > 0 {
+			addresses = append(addresses, vmInfo.Address.IpAddress)
+		}
+		if len(vmInfo.Address.Ipv6Address) > 0 {
+			addresses = append(addresses, vmInfo.Address.Ipv6Address)
+		}
+		for _, address := range vmInfo.SecondaryAddresses {
+			addresses = append(addresses, address.IpAddress)
+			if len(address.Ipv6Address) > 0 {
+				addresses = append(addresses, address.Ipv6Address)
+			}
+		}
+	}
+	for _, peer := range m.firewallPeers {
+		if matchTags(peer.Tags, remoteTags) {
+			addresses = append(addresses, peer.IpAddress)
+		}
+	}
+	return addresses
+}
+
+// getFirewallInfo returns the copy of the VM tags and addresses recorded by
+// updateFirewallInfo, or nil if none has been recorded. It must not be
+// modified.
+func (vm *vmInfoType) getFirewallInfo() *proto.VmInfo {
+	vm.firewallMutex.Lock()
+	defer vm.firewallMutex.Unlock()
+	return vm.firewallInfo
+}
+
+// updateFirewallInfo will record a copy of the VM tags and addresses for the
+// firewalls of other VMs. The VM lock must be held.
+func (vm *vmInfoType) updateFirewallInfo() {
+	firewallInfo := &proto.VmInfo{
+		Address: vm.Address,
+		SecondaryAddresses: append([]proto.Address(nil),
+			vm.SecondaryAddresses...),
+		Tags: vm.Tags.Copy(),
+	}
+	vm.firewallMutex.Lock()
+	vm.firewallInfo = firewallInfo
+	vm.firewallMutex.Unlock()
+}
+
+// refreshFirewalls will re-program the firewalls for running VMs. If all is
+// false, only those which reference other VMs by tag are re-programmed.
+func (m *Manager) refreshFirewalls(all bool) {
+	for _, vm := range m.getVMs() {
+		vm.mutex.Lock()
+		if vm.commandChannel != nil && len(vm.TapDevices) > 0 {
+			if err := vm.refreshFirewall(all); err != nil {
+				vm.logger.Println(err)
+			}
+		}
+		vm.mutex.Unlock()
+	}
+}
+
+// setupFirewall will (re)create the nftables table which VM traffic is
+// filtered through and will load the rule groups and peers from the Fleet
+// Manager.
+func (m *Manager) setupFirewall() error {
+	if !m.EnableFirewall {
+		return nil
+	}
+	var request proto.UpdateFirewallRequest
+	err := json.ReadFromFile(filepath.Join(m.StateDir, firewallFilename),
+		&request)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	m.firewallGroups = request.Groups
+	m.firewallPeers = request.Peers
+	buffer := &bytes.Buffer{}
+	fmt.Fprintf(buffer, "add table %s\n", firewallTable)
+	fmt.Fprintf(buffer, "delete table %s\n", firewallTable)
+	fmt.Fprintf(buffer, "add table %s\n", firewallTable)
+	for _, name := range []string{"egress_taps", "ingress_taps"} {
+		fmt.Fprintf(buffer,
+			"add map %s %s { type ifname : verdict ; }\n", firewallTable, name)
+	}
+	for _, hook := range []string{"forward", "input", "output"} {
+		fmt.Fprintf(buffer,
+			"add chain %s %s { type filter hook %s priority 0 ; policy accept ; }\n",
+			firewallTable, hook, hook)
+	}
+	fmt.Fprintf(buffer, "add rule %s forward iifname vmap @egress_taps\n",
+		firewallTable)
+	fmt.Fprintf(buffer, "add rule %s forward oifname vmap @ingress_taps\n",
+		firewallTable)
+	fmt.Fprintf(buffer, "add rule %s input iifname vmap @egress_taps\n",
+		firewallTable)
+	fmt.Fprintf(buffer, "add rule %s output oifname vmap @ingress_taps\n",
+		firewallTable)
+	if err := runNft(buffer.String()); err != nil {
+		return err
+	}
+	refreshChannel := make(chan struct{}, 1)
+	m.firewallRefresh = refreshChannel
+	go m.firewallLoop(refreshChannel)
+	return nil
+}
+
+// triggerFirewallRefresh will request that firewalls referencing other VMs by
+// tag are re-programmed. It does not block.
+func (m *Manager) triggerFirewallRefresh() {
+	if m.firewallRefresh == nil {
+		return
+	}
+	select {
+	case m.firewallRefresh <- struct{}{}:
+	default:
+	}
+}
+
+func (m *Manager) updateFirewall(request proto.UpdateFirewallRequest) error {
+	for name, group := range request.Groups {
+		if err := group.CheckValid(); err != nil {
+			return fmt.Errorf("firewall group: %s: %s", name, err)
+		}
+	}
+	for index := range request.Peers {
+		request.Peers[index].IpAddress =
+			proto.ShrinkIP(request.Peers[index].IpAddress)
+	}
+	m.mutex.Lock()
+	m.firewallGroups = request.Groups
+	m.firewallPeers = request.Peers
+	err := json.WriteToFile(filepath.Join(m.StateDir, firewallFilename),
+		publicFilePerms, "    ", request)
+	m.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	if m.EnableFirewall {
+		m.refreshFirewalls(true)
+	}
+	return nil
+}
+
+// programFirewall will program the firewall for the tap devices of the VM.
+// The VM lock must be held.
+func (vm *vmInfoType) programFirewall(haveManagerLock bool) error {
+	if !vm.manager.EnableFirewall || len(vm.TapDevices) < 1 {
+		return nil
+	}
+	if !haveManagerLock {
+		vm.manager.mutex.RLock()
+	}
+	ruleSet, err := getFirewallRules(vm.Firewall, vm.manager.firewallGroups)
+	if err != nil {
+		if !haveManagerLock {
+			vm.manager.mutex.RUnlock()
+		}
+		return err
+	}
+	addresses := make([]proto.Address, 1, len(vm.SecondaryAddresses)+1)
+	addresses[0] = vm.Address
+	addresses = append(addresses, vm.SecondaryAddresses...)
+	interfaces := make([]firewallInterface, 0, len(vm.TapDevices))
+	for index, tapName := range vm.TapDevices {
+		if index < len(addresses) {
+			interfaces = append(interfaces, firewallInterface{
+				address: addresses[index],
+				tapName: tapName,
+			})
+		}
+	}
+	script := makeFirewallScript(interfaces, ruleSet,
+		vm.manager.getFirewallPeerAddresses)
+	if !haveManagerLock {
+		vm.manager.mutex.RUnlock()
+	}
+	return runNft(script)
+}
+
+// refreshFirewall will re-program the firewall for the VM. If all is false it
+// is only re-programmed if it references other VMs by tag. The VM lock must be
+// held.
+func (vm *vmInfoType) refreshFirewall(all bool) error {
+	if !all {
+		vm.manager.mutex.RLock()
+		ruleSet, err := getFirewallRules(vm.Firewall,
+			vm.manager.firewallGroups)
+		vm.manager.mutex.RUnlock()
+		if err != nil {
+			return err
+		}
+		if !ruleSet.usesTags {
+			return nil
+		}
+	}
+	return vm.programFirewall(false)
+}
+
+// removeFirewall will remove the firewall for the tap devices of the VM. The
+// VM lock must be held.
+func (vm *vmInfoType) removeFirewall() {
+	if len(vm.TapDevices) < 1 {
+		return
+	}
+	if vm.manager.EnableFirewall {
+		if err := runNft(makeFirewallRemoveScript(vm.TapDevices)); err != nil {
+			vm.logger.Println(err)
+		}
+	}
+	vm.TapDevices = nil
+}
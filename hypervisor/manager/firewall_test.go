@@ -0,0 +1,190 @@
+package manager
+
+import (
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/tags"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func TestMakeFirewallRuleMatches(t *testing.T) {
+	tests := []struct {
+		rule     proto.FirewallRule
+		peers    []net.IP
+		expected []string
+	}{
+		{proto.FirewallRule{}, nil, []string{""}},
+		{proto.FirewallRule{Protocol: "tcp", FirstPort: 22}, nil,
+			[]string{" tcp dport 22"}},
+		{proto.FirewallRule{Protocol: "udp", FirstPort: 1, LastPort: 9}, nil,
+			[]string{" udp dport 1-9"}},
+		{proto.FirewallRule{Protocol: "icmp"}, nil,
+			[]string{" meta l4proto { icmp, icmpv6 }"}},
+		{proto.FirewallRule{Networks: []string{"10.0.0.0/8", "fd00::/8"},
+			Protocol: "tcp"}, nil,
+			[]string{"ip daddr { 10.0.0.0/8 } meta l4proto tcp",
+				"ip6 daddr { fd00::/8 } meta l4proto tcp"}},
+		{proto.FirewallRule{RemoteTags: tags.Tags{"Role": "db"}},
+			[]net.IP{{10, 1, 2, 3}}, []string{"ip daddr { 10.1.2.3 }"}},
+		{proto.FirewallRule{RemoteTags: tags.Tags{"Role": "db"}}, nil, nil},
+	}
+	for _, test := range tests {
+		matches := makeFirewallRuleMatches(test.rule, "daddr", test.peers)
+		if !reflect.DeepEqual(matches, test.expected) {
+			t.Errorf("rule: %v: expected: %q, got: %q",
+				test.rule, test.expected, matches)
+		}
+	}
+}
+
+func TestGetFirewallRules(t *testing.T) {
+	ruleSet, err := getFirewallRules(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ruleSet.restrictIngress {
+		t.Error("nil policy restricts ingress")
+	}
+	groups := map[string]proto.FirewallRuleGroup{
+		"web": {Ingress: []proto.FirewallRule{{Protocol: "tcp", FirstPort: 80}}},
+		"db": {Egress: []proto.FirewallRule{
+			{RemoteTags: tags.Tags{"Role": "db"}}}},
+	}
+	ruleSet, err = getFirewallRules(
+		&proto.FirewallPolicy{Groups: []string{"web"}}, groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ruleSet.restrictIngress || ruleSet.restrictEgress ||
+		ruleSet.usesTags || len(ruleSet.ingress) != 1 {
+		t.Errorf("web group: unexpected rules: %+v", ruleSet)
+	}
+	ruleSet, err = getFirewallRules(
+		&proto.FirewallPolicy{Groups: []string{"db"}}, groups)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ruleSet.restrictEgress || !ruleSet.usesTags ||
+		len(ruleSet.egress) != 1 {
+		t.Errorf("db group: unexpected rules: %+v", ruleSet)
+	}
+	_, err = getFirewallRules(
+		&proto.FirewallPolicy{Groups: []string{"db", "missing"}}, groups)
+	if err == nil {
+		t.Error("no error for unknown group")
+	}
+}
+
+func TestCheckFirewallPolicy(t *testing.T) {
+	m := &Manager{
+		StartOptions: StartOptions{EnableFirewall: true},
+		firewallGroups: map[string]proto.FirewallRuleGroup{
+			"web": {Ingress: []proto.FirewallRule{
+				{Protocol: "tcp", FirstPort: 80}}},
+		},
+	}
+	err := m.checkFirewallPolicy(&proto.FirewallPolicy{Groups: []string{"web"}})
+	if err != nil {
+		t.Error(err)
+	}
+	err = m.checkFirewallPolicy(
+		&proto.FirewallPolicy{Groups: []string{"web", "missing"}})
+	if err == nil {
+		t.Error("no error for unknown group")
+	}
+}
+
+func TestGetFirewallPeerAddresses(t *testing.T) {
+	db := &vmInfoType{LocalVmInfo: proto.LocalVmInfo{VmInfo: proto.VmInfo{
+		Address: proto.Address{IpAddress: net.IP{10, 0, 0, 2}},
+		SecondaryAddresses: []proto.Address{{
+			IpAddress:   net.IP{10, 0, 1, 2},
+			Ipv6Address: net.ParseIP("fd00::2"),
+		}},
+		Tags: tags.Tags{"Role": "db"},
+	}}}
+	db.updateFirewallInfo()
+	// Changes which have not been recorded must not be seen.
+	db.Tags = tags.Tags{"Role": "web"}
+	unrecorded := &vmInfoType{LocalVmInfo: proto.LocalVmInfo{
+		VmInfo: proto.VmInfo{
+			Address: proto.Address{IpAddress: net.IP{10, 0, 0, 3}},
+			Tags:    tags.Tags{"Role": "db"},
+		}}}
+	m := &Manager{
+		firewallPeers: []proto.FirewallPeer{
+			{IpAddress: net.IP{10, 2, 0, 1}, Tags: tags.Tags{"Role": "db"}},
+			{IpAddress: net.IP{10, 2, 0, 2}, Tags: tags.Tags{"Role": "web"}},
+		},
+		vms: map[string]*vmInfoType{"10.0.0.2": db, "10.0.0.3": unrecorded},
+	}
+	expected := []net.IP{{10, 0, 0, 2}, {10, 0, 1, 2}, net.ParseIP("fd00::2"),
+		{10, 2, 0, 1}}
+	addresses := m.getFirewallPeerAddresses(tags.Tags{"Role": "db"})
+	if !reflect.DeepEqual(addresses, expected) {
+		t.Errorf("expected: %v, got: %v", expected, addresses)
+	}
+}
+
+func TestMakeFirewallScriptIpv6(t *testing.T) {
+	tests := []struct {
+		address  proto.Address
+		expected []string
+		excluded []string
+	}{
+		{
+			proto.Address{
+				IpAddress:   net.IP{10, 0, 0, 2},
+				Ipv6Address: net.ParseIP("fd00::5054:aff:fe00:2"),
+				MacAddress:  "52:54:0a:00:00:02",
+			},
+			[]string{
+				"egress_tap0 ether type ip6 ip6 saddr != " +
+					"{ ::, fe80::/10, fd00::5054:aff:fe00:2 } drop",
+				"egress_tap0 udp sport 546 udp dport 547 accept",
+				"ingress_tap0 udp sport 547 udp dport 546 accept",
+				"egress_tap0 meta l4proto icmpv6 accept",
+				"ingress_tap0 meta l4proto icmpv6 accept",
+			},
+			[]string{"saddr != { ::, fe80::/10 } drop"},
+		},
+		{
+			proto.Address{
+				IpAddress:  net.IP{10, 0, 0, 2},
+				MacAddress: "52:54:0a:00:00:02",
+			},
+			[]string{
+				"egress_tap0 ether type ip6 ip6 saddr != " +
+					"{ ::, fe80::/10 } drop",
+				"egress_tap0 udp sport 546 udp dport 547 accept",
+			},
+			[]string{"fd00::"},
+		},
+	}
+	for _, test := range tests {
+		script := makeFirewallScript(
+			[]firewallInterface{{address: test.address, tapName: "tap0"}},
+			firewallRuleSet{}, func(tags.Tags) []net.IP { return nil })
+		lines := strings.Split(script, "\n")
+		for _, expected := range test.expected {
+			found := false
+			for _, line := range lines {
+				if line == "add rule "+firewallTable+" "+expected {
+					found = true
+					break
+				}
+			}
+			if !found {
+				t.Errorf("%s: missing rule: %s", test.address, expected)
+			}
+		}
+		for _, excluded := range test.excluded {
+			if strings.Contains(script, excluded) {
+				t.Errorf("%s: unexpected rule: %s", test.address, excluded)
+			}
+		}
+	}
+}
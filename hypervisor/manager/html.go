@@ -23,6 +23,7 @@ func (m *Manager) writeHtml(writer io.Writer) {
 	memUnallocated := m.getUnallocatedMemoryInMiBWithLock()
 	numSubnets := len(m.subnets)
 	numAddresses := len(m.addressPool.Free)
+	numFirewallGroups := len(m.firewallGroups)
 	ownerGroups := make([]string, 0, len(m.ownerGroups))
 	for group := range m.ownerGroups {
 		ownerGroups = append(ownerGroups, group)
@@ -44,6 +45,10 @@ func (m *Manager) writeHtml(writer io.Writer) {
 			format.FormatBytes(memInfo.Available),
 			format.FormatBytes(memUnallocated<<20))
 	}
+	if m.EnableFirewall {
+		fmt.Fprintf(writer, "Firewall enabled, %d rule groups<br>\n",
+			numFirewallGroups)
+	}
 	sort.Strings(ownerGroups)
 	sort.Strings(ownerUsers)
 	if len(ownerGroups) > 0 {
@@ -61,6 +66,10 @@ func (m *Manager) writeHtml(writer io.Writer) {
 		"Number of subnets: <a href=\"listSubnets\">%d</a><br>\n", numSubnets)
 	fmt.Fprintf(writer, "Volume directories: %s<br>\n",
 		strings.Join(m.volumeDirectories, " "))
+	if snapshotBytes := m.getSnapshotBytes(); snapshotBytes > 0 {
+		fmt.Fprintf(writer, "Snapshot space used: %s<br>\n",
+			format.FormatBytes(snapshotBytes))
+	}
 	if m.objectCache == nil {
 		fmt.Fprintln(writer, "No object cache<br>")
 	} else {
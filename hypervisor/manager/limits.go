@@ -0,0 +1,147 @@
+package manager
+
+import (
+	"fmt"
+	"net"
+	"os/exec"
+
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const minimumNetworkBurst = 32 << 10
+
+// makeDriveThrottleOptions returns the QEMU -drive options to throttle a
+// volume.
+func makeDriveThrottleOptions(limits *proto.VmLimits) string {
+	if limits == nil {
+		return ""
+	}
+	var options string
+	if limits.DiskBytesPerSecond > 0 {
+		options += fmt.Sprintf(",throttling.bps-total=%d",
+			limits.DiskBytesPerSecond)
+	}
+	if limits.DiskOpsPerSecond > 0 {
+		options += fmt.Sprintf(",throttling.iops-total=%d",
+			limits.DiskOpsPerSecond)
+	}
+	return options
+}
+
+// makeNetworkBurst returns the burst size in bytes for a rate: 100 ms worth of
+// traffic, with a minimum.
+func makeNetworkBurst(bytesPerSecond uint64) uint64 {
+	if burst := bytesPerSecond / 10; burst > minimumNetworkBurst {
+		return burst
+	}
+	return minimumNetworkBurst
+}
+
+func runTc(args ...string) error {
+	cmd := exec.Command("tc", args...)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("error running tc %v: %s: %s", args, err, output)
+	}
+	return nil
+}
+
+// setTapLimits will throttle the traffic on a tap device. Traffic from the VM
+// arrives on (ingresses) the tap device, so it is policed, and traffic to the
+// VM leaves (egresses) the tap device, so it is shaped.
+func setTapLimits(tapName string, limits *proto.VmLimits) error {
+	return setTapLimitsWithRunner(tapName, limits, runTc)
+}
+
+// setTapLimitsWithRunner implements setTapLimits, calling runTc to run each tc
+// command.
+func setTapLimitsWithRunner(tapName string, limits *proto.VmLimits,
+	runTc func(args ...string) error) error {
+	// Remove existing limits. These fail if there are none.
+	runTc("qdisc", "del", "dev", tapName, "root")
+	runTc("qdisc", "del", "dev", tapName, "ingress")
+	if limits == nil {
+		return nil
+	}
+	if rate := limits.IngressBytesPerSecond; rate > 0 {
+		err := runTc("qdisc", "add", "dev", tapName, "root", "tbf",
+			"rate", fmt.Sprintf("%dbps", rate),
+			"burst", fmt.Sprintf("%d", makeNetworkBurst(rate)),
+			"latency", "50ms")
+		if err != nil {
+			return err
+		}
+	}
+	if rate := limits.EgressBytesPerSecond; rate > 0 {
+		err := runTc("qdisc", "add", "dev", tapName, "handle", "ffff:",
+			"ingress")
+		if err != nil {
+			return err
+		}
+		err = runTc("filter", "add", "dev", tapName, "parent", "ffff:",
+			"protocol", "all", "u32", "match", "u32", "0", "0",
+			"police", "rate", fmt.Sprintf("%dbps", rate),
+			"burst", fmt.Sprintf("%d", makeNetworkBurst(rate)),
+			"drop", "flowid", ":1")
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Manager) changeVmLimits(ipAddr net.IP,
+	authInfo *srpc.AuthInformation, limits *proto.VmLimits) error {
+	if limits != nil && *limits == (proto.VmLimits{}) {
+		limits = nil
+	}
+	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, nil)
+	if err != nil {
+		return err
+	}
+	defer vm.mutex.Unlock()
+	vm.Limits = limits
+	vm.writeAndSendInfo()
+	if vm.State != proto.StateRunning {
+		return nil
+	}
+	if err := vm.setDiskLimits(); err != nil {
+		return err
+	}
+	return vm.setNetworkLimits()
+}
+
+// setDiskLimits will tell QEMU to throttle the volumes of a running VM. The VM
+// lock must be held.
+func (vm *vmInfoType) setDiskLimits() error {
+	var limits proto.VmLimits
+	if vm.Limits != nil {
+		limits = *vm.Limits
+	}
+	for index := range vm.VolumeLocations {
+		err := vm.qmpCommand("block_set_io_throttle", map[string]interface{}{
+			"device":  vm.getVolumeDeviceName(uint(index)),
+			"bps":     limits.DiskBytesPerSecond,
+			"bps_rd":  0,
+			"bps_wr":  0,
+			"iops":    limits.DiskOpsPerSecond,
+			"iops_rd": 0,
+			"iops_wr": 0,
+		}, nil)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setNetworkLimits will throttle the tap devices of a running VM. The VM lock
+// must be held.
+func (vm *vmInfoType) setNetworkLimits() error {
+	for _, tapName := range vm.TapDevices {
+		if err := setTapLimits(tapName, vm.Limits); err != nil {
+			return err
+		}
+	}
+	return nil
+}
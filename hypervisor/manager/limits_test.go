@@ -0,0 +1,112 @@
+package manager
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func TestMakeDriveThrottleOptions(t *testing.T) {
+	tests := []struct {
+		limits   *proto.VmLimits
+		expected string
+	}{
+		{nil, ""},
+		{&proto.VmLimits{}, ""},
+		{&proto.VmLimits{EgressBytesPerSecond: 1000}, ""},
+		{&proto.VmLimits{DiskBytesPerSecond: 1 << 20},
+			",throttling.bps-total=1048576"},
+		{&proto.VmLimits{DiskOpsPerSecond: 500}, ",throttling.iops-total=500"},
+		{&proto.VmLimits{DiskBytesPerSecond: 1 << 20, DiskOpsPerSecond: 500},
+			",throttling.bps-total=1048576,throttling.iops-total=500"},
+	}
+	for _, test := range tests {
+		options := makeDriveThrottleOptions(test.limits)
+		if options != test.expected {
+			t.Errorf("limits: %+v: expected: %q, got: %q",
+				test.limits, test.expected, options)
+		}
+	}
+}
+
+func TestMakeNetworkBurst(t *testing.T) {
+	tests := []struct {
+		bytesPerSecond uint64
+		expected       uint64
+	}{
+		{0, minimumNetworkBurst},
+		{1000, minimumNetworkBurst},
+		{minimumNetworkBurst * 10, minimumNetworkBurst},
+		{minimumNetworkBurst*10 + 10, minimumNetworkBurst + 1},
+		{100 << 20, 10 << 20},
+	}
+	for _, test := range tests {
+		burst := makeNetworkBurst(test.bytesPerSecond)
+		if burst != test.expected {
+			t.Errorf("rate: %d: expected: %d, got: %d",
+				test.bytesPerSecond, test.expected, burst)
+		}
+	}
+}
+
+func TestSetTapLimits(t *testing.T) {
+	remove := []string{
+		"qdisc del dev tap0 root",
+		"qdisc del dev tap0 ingress",
+	}
+	shape := "qdisc add dev tap0 root tbf rate 1048576bps burst 104857" +
+		" latency 50ms"
+	police := []string{
+		"qdisc add dev tap0 handle ffff: ingress",
+		"filter add dev tap0 parent ffff: protocol all u32 match u32 0 0" +
+			" police rate 1000bps burst 32768 drop flowid :1",
+	}
+	tests := []struct {
+		limits   *proto.VmLimits
+		failOn   string
+		expected []string
+		fail     bool
+	}{
+		{nil, "", remove, false},
+		{&proto.VmLimits{DiskOpsPerSecond: 100}, "", remove, false},
+		{&proto.VmLimits{IngressBytesPerSecond: 1 << 20}, "",
+			append(remove[:2:2], shape), false},
+		{&proto.VmLimits{EgressBytesPerSecond: 1000}, "",
+			append(remove[:2:2], police...), false},
+		{&proto.VmLimits{IngressBytesPerSecond: 1 << 20,
+			EgressBytesPerSecond: 1000}, "",
+			append(append(remove[:2:2], shape), police...), false},
+		// Failing to remove limits which do not exist is not an error.
+		{nil, "qdisc del", remove, false},
+		{&proto.VmLimits{IngressBytesPerSecond: 1 << 20,
+			EgressBytesPerSecond: 1000}, "qdisc add dev tap0 root",
+			append(remove[:2:2], shape), true},
+		{&proto.VmLimits{EgressBytesPerSecond: 1000}, "filter add",
+			append(remove[:2:2], police...), true},
+	}
+	for _, test := range tests {
+		var commands []string
+		err := setTapLimitsWithRunner("tap0", test.limits,
+			func(args ...string) error {
+				command := strings.Join(args, " ")
+				commands = append(commands, command)
+				if test.failOn != "" && strings.HasPrefix(command,
+					test.failOn) {
+					return errors.New("tc failed")
+				}
+				return nil
+			})
+		if test.fail && err == nil {
+			t.Errorf("limits: %+v: no error", test.limits)
+		} else if !test.fail && err != nil {
+			t.Errorf("limits: %+v: %s", test.limits, err)
+		}
+		if !reflect.DeepEqual(commands, test.expected) {
+			t.Errorf("limits: %+v: expected: %q, got: %q",
+				test.limits, test.expected, commands)
+		}
+	}
+}
@@ -0,0 +1,501 @@
+package manager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/format"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const (
+	migrationProgressInterval = 5 * time.Second
+	qmpTimeout                = time.Minute
+)
+
+type qmpBlockInfo struct {
+	Device   string `json:"device"`
+	Inserted *struct {
+		File string `json:"file"`
+	} `json:"inserted"`
+}
+
+type qmpError struct {
+	Class       string `json:"class"`
+	Description string `json:"desc"`
+}
+
+type qmpJobInfo struct {
+	CurrentProgress uint64 `json:"current-progress"`
+	Error           string `json:"error"`
+	Id              string `json:"id"`
+	Status          string `json:"status"`
+	TotalProgress   uint64 `json:"total-progress"`
+}
+
+type qmpMigrationInfo struct {
+	ErrorDescription string `json:"error-desc"`
+	Ram              *struct {
+		Remaining   uint64 `json:"remaining"`
+		Total       uint64 `json:"total"`
+		Transferred uint64 `json:"transferred"`
+	} `json:"ram"`
+	Status string `json:"status"`
+}
+
+type qmpRequest struct {
+	Arguments interface{} `json:"arguments,omitempty"`
+	Execute   string      `json:"execute"`
+	Id        uint64      `json:"id"`
+}
+
+type qmpResponse struct {
+	Error  *qmpError       `json:"error"`
+	Id     *uint64         `json:"id"`
+	Return json.RawMessage `json:"return"`
+}
+
+type qmpVersionInfo struct {
+	Qemu struct {
+		Major uint `json:"major"`
+		Minor uint `json:"minor"`
+		Micro uint `json:"micro"`
+	} `json:"qemu"`
+}
+
+// getQemuVersion returns the version of the locally installed QEMU.
+func getQemuVersion() (string, error) {
+	output, err := exec.Command("qemu-system-x86_64", "-version").Output()
+	if err != nil {
+		return "", fmt.Errorf("error getting QEMU version: %s", err)
+	}
+	fields := strings.Fields(string(output))
+	for index, field := range fields {
+		if field == "version" && index+1 < len(fields) {
+			return fields[index+1], nil
+		}
+	}
+	return "", fmt.Errorf("unable to parse QEMU version: %s", output)
+}
+
+// checkMigrationAddress checks that address is an IP address and port and
+// returns it in canonical form.
+func checkMigrationAddress(address string) (string, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return "", fmt.Errorf("bad IP address in: %s", address)
+	}
+	portNum, err := strconv.ParseUint(port, 10, 16)
+	if err != nil || portNum < 1 {
+		return "", fmt.Errorf("bad port number in: %s", address)
+	}
+	return net.JoinHostPort(ip.String(), strconv.FormatUint(portNum, 10)), nil
+}
+
+// makeMigrationUri returns the QEMU URI to send the VM state to the incoming
+// VM listening on address.
+func makeMigrationUri(address string) (string, error) {
+	address, err := checkMigrationAddress(address)
+	if err != nil {
+		return "", err
+	}
+	return "tcp:" + address, nil
+}
+
+func makeMirrorJobId(volumeIndex int) string {
+	return fmt.Sprintf("migrate-volume%d", volumeIndex)
+}
+
+func makeNbdExportName(volumeIndex int) string {
+	return fmt.Sprintf("volume%d", volumeIndex)
+}
+
+// makeNbdUri returns the QEMU URI for a volume exported by the NBD server on
+// address.
+func makeNbdUri(address string, volumeIndex int) (string, error) {
+	address, err := checkMigrationAddress(address)
+	if err != nil {
+		return "", err
+	}
+	return "nbd://" + address + "/" + makeNbdExportName(volumeIndex), nil
+}
+
+// matchVolumeDevices returns the name of the block device for each volume.
+func matchVolumeDevices(volumes []proto.LocalVolume,
+	blocks []qmpBlockInfo) ([]string, error) {
+	devices := make([]string, 0, len(volumes))
+	for _, volume := range volumes {
+		var device string
+		for _, block := range blocks {
+			if block.Inserted != nil && block.Inserted.File == volume.Filename {
+				device = block.Device
+				break
+			}
+		}
+		if device == "" {
+			return nil, fmt.Errorf("no block device for: %s", volume.Filename)
+		}
+		devices = append(devices, device)
+	}
+	return devices, nil
+}
+
+// makeMirrorProgress returns a progress message for the volume mirror jobs.
+func makeMirrorProgress(jobs []qmpJobInfo) string {
+	var current, total uint64
+	var numReady int
+	for _, job := range jobs {
+		current += job.CurrentProgress
+		total += job.TotalProgress
+		if job.Status == "ready" {
+			numReady++
+		}
+	}
+	return fmt.Sprintf("mirrored %s of %s, %d of %d volume(s) synchronised",
+		format.FormatBytes(current), format.FormatBytes(total), numReady,
+		len(jobs))
+}
+
+func makeMigrationCapabilities(names ...string) interface{} {
+	type capability struct {
+		Capability string `json:"capability"`
+		State      bool   `json:"state"`
+	}
+	capabilities := make([]capability, 0, len(names))
+	for _, name := range names {
+		capabilities = append(capabilities,
+			capability{Capability: name, State: true})
+	}
+	return map[string]interface{}{"capabilities": capabilities}
+}
+
+func (version qmpVersionInfo) String() string {
+	return fmt.Sprintf("%d.%d.%d",
+		version.Qemu.Major, version.Qemu.Minor, version.Qemu.Micro)
+}
+
+// qmpCommand will send a command to the QEMU monitor and wait for the reply,
+// which is decoded into reply if it is not nil. The VM lock must be held (read
+// or write) so that the monitor connection is not closed while sending.
+func (vm *vmInfoType) qmpCommand(command string, arguments interface{},
+	reply interface{}) error {
+	if vm.commandChannel == nil {
+		return errors.New("no connection to QEMU monitor")
+	}
+	responseChannel := make(chan qmpResponse, 1)
+	vm.qmpMutex.Lock()
+	vm.qmpNextId++
+	id := vm.qmpNextId
+	if vm.qmpWaiters == nil {
+		vm.qmpWaiters = make(map[uint64]chan<- qmpResponse)
+	}
+	vm.qmpWaiters[id] = responseChannel
+	vm.qmpMutex.Unlock()
+	defer func() {
+		vm.qmpMutex.Lock()
+		delete(vm.qmpWaiters, id)
+		vm.qmpMutex.Unlock()
+	}()
+	data, err := json.Marshal(qmpRequest{
+		Arguments: arguments,
+		Execute:   command,
+		Id:        id,
+	})
+	if err != nil {
+		return err
+	}
+	vm.commandChannel <- string(data)
+	timer := time.NewTimer(qmpTimeout)
+	select {
+	case response, ok := <-responseChannel:
+		timer.Stop()
+		if !ok {
+			return errors.New("QEMU monitor connection closed")
+		}
+		if response.Error != nil {
+			return fmt.Errorf("%s: %s", command, response.Error.Description)
+		}
+		if reply == nil {
+			return nil
+		}
+		return json.Unmarshal(response.Return, reply)
+	case <-timer.C:
+		return fmt.Errorf("timed out waiting for reply to: %s", command)
+	}
+}
+
+// readMonitorResponses will read responses from the QEMU monitor until it is
+// closed, passing replies to commands sent with qmpCommand to the waiters.
+func (vm *vmInfoType) readMonitorResponses(reader io.Reader) {
+	decoder := json.NewDecoder(reader)
+	for {
+		var response qmpResponse
+		if err := decoder.Decode(&response); err != nil {
+			if err != io.EOF {
+				vm.logger.Debugf(0, "error decoding monitor response: %s\n",
+					err)
+			}
+			break
+		}
+		if response.Id == nil {
+			continue
+		}
+		vm.qmpMutex.Lock()
+		if responseChannel, ok := vm.qmpWaiters[*response.Id]; ok {
+			responseChannel <- response
+			delete(vm.qmpWaiters, *response.Id)
+		}
+		vm.qmpMutex.Unlock()
+	}
+	io.Copy(ioutil.Discard, reader) // Read all and drop.
+	vm.qmpMutex.Lock()
+	for id, responseChannel := range vm.qmpWaiters {
+		close(responseChannel)
+		delete(vm.qmpWaiters, id)
+	}
+	vm.qmpMutex.Unlock()
+}
+
+// getVolumeDevices returns the name of the block device for each volume. The
+// VM lock must be held.
+func (vm *vmInfoType) getVolumeDevices() ([]string, error) {
+	var blocks []qmpBlockInfo
+	if err := vm.qmpCommand("query-block", nil, &blocks); err != nil {
+		return nil, err
+	}
+	return matchVolumeDevices(vm.VolumeLocations, blocks)
+}
+
+// getMirrorJobs returns the volume mirror jobs. An error is returned if a job
+// is missing or has failed. The VM lock must not be held.
+func (vm *vmInfoType) getMirrorJobs(numVolumes int) ([]qmpJobInfo, error) {
+	var jobs []qmpJobInfo
+	vm.mutex.RLock()
+	err := vm.qmpCommand("query-jobs", nil, &jobs)
+	vm.mutex.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	jobsById := make(map[string]qmpJobInfo, len(jobs))
+	for _, job := range jobs {
+		jobsById[job.Id] = job
+	}
+	mirrorJobs := make([]qmpJobInfo, 0, numVolumes)
+	for index := 0; index < numVolumes; index++ {
+		job, ok := jobsById[makeMirrorJobId(index)]
+		if !ok {
+			return nil, fmt.Errorf("mirror of volume: %d missing", index)
+		}
+		if job.Error != "" {
+			return nil, fmt.Errorf("mirror of volume: %d failed: %s",
+				index, job.Error)
+		}
+		mirrorJobs = append(mirrorJobs, job)
+	}
+	return mirrorJobs, nil
+}
+
+// startNbdServer will start an NBD server on address which exports the
+// volumes for writing. The VM lock must be held.
+func (vm *vmInfoType) startNbdServer(address string) error {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	devices, err := vm.getVolumeDevices()
+	if err != nil {
+		return err
+	}
+	err = vm.qmpCommand("nbd-server-start", map[string]interface{}{
+		"addr": map[string]interface{}{
+			"type": "inet",
+			"data": map[string]string{"host": host, "port": port},
+		},
+	}, nil)
+	if err != nil {
+		return err
+	}
+	for index, device := range devices {
+		err := vm.qmpCommand("nbd-server-add", map[string]interface{}{
+			"device":   device,
+			"name":     makeNbdExportName(index),
+			"writable": true,
+		}, nil)
+		if err != nil {
+			vm.stopNbdServer()
+			return err
+		}
+	}
+	return nil
+}
+
+// stopNbdServer will stop the NBD server. The VM lock must be held.
+func (vm *vmInfoType) stopNbdServer() {
+	if err := vm.qmpCommand("nbd-server-stop", nil, nil); err != nil {
+		vm.logger.Println(err)
+	}
+}
+
+// startVolumeMirrors will start jobs which mirror the volumes to the NBD
+// server on nbdAddress. The VM lock must be held.
+func (vm *vmInfoType) startVolumeMirrors(nbdAddress string) error {
+	devices, err := vm.getVolumeDevices()
+	if err != nil {
+		return err
+	}
+	for index, device := range devices {
+		target, err := makeNbdUri(nbdAddress, index)
+		if err != nil {
+			return err
+		}
+		err = vm.qmpCommand("drive-mirror", map[string]interface{}{
+			"auto-dismiss": false, // Keep the job so that errors are seen.
+			"device":       device,
+			"format":       "raw",
+			"job-id":       makeMirrorJobId(index),
+			"mode":         "existing",
+			"sync":         "full",
+			"target":       target,
+		}, nil)
+		if err != nil {
+			vm.cancelVolumeMirrors(index)
+			return err
+		}
+	}
+	return nil
+}
+
+// cancelVolumeMirrors will cancel the first numVolumes volume mirror jobs and
+// dismiss them once they have stopped. The VM lock must be held.
+func (vm *vmInfoType) cancelVolumeMirrors(numVolumes int) {
+	for index := 0; index < numVolumes; index++ {
+		jobId := makeMirrorJobId(index)
+		vm.qmpCommand("job-cancel", map[string]string{"id": jobId}, nil)
+		vm.qmpCommand("job-dismiss", map[string]string{"id": jobId}, nil)
+	}
+}
+
+// waitForVolumeMirrors will wait until the volume mirror jobs are ready (the
+// volumes are synchronised), sending progress messages to progressFunc. The
+// VM lock must not be held.
+func (vm *vmInfoType) waitForVolumeMirrors(numVolumes int,
+	progressFunc func(message string) error) error {
+	lastProgressTime := time.Now()
+	for ; ; time.Sleep(time.Second) {
+		jobs, err := vm.getMirrorJobs(numVolumes)
+		if err != nil {
+			return err
+		}
+		numReady := 0
+		for index, job := range jobs {
+			switch job.Status {
+			case "ready":
+				numReady++
+			case "aborting", "concluded", "null":
+				return fmt.Errorf("mirror of volume: %d stopped", index)
+			}
+		}
+		if numReady == numVolumes {
+			return nil
+		}
+		if time.Since(lastProgressTime) >= migrationProgressInterval {
+			if err := progressFunc(makeMirrorProgress(jobs)); err != nil {
+				return err
+			}
+			lastProgressTime = time.Now()
+		}
+	}
+}
+
+// completeVolumeMirrors will stop the ready volume mirror jobs, leaving the
+// NBD exports with a copy of the volumes, and dismiss them. The VM must be
+// paused so that the volumes are not changing. The VM lock must not be held.
+func (vm *vmInfoType) completeVolumeMirrors(numVolumes int) error {
+	err := vm.finishVolumeMirrors(numVolumes)
+	vm.mutex.RLock()
+	vm.cancelVolumeMirrors(numVolumes) // Dismiss the jobs.
+	vm.mutex.RUnlock()
+	return err
+}
+
+func (vm *vmInfoType) finishVolumeMirrors(numVolumes int) error {
+	// Cancelling a ready mirror job completes it without switching to the
+	// target.
+	vm.mutex.RLock()
+	for index := 0; index < numVolumes; index++ {
+		err := vm.qmpCommand("block-job-cancel",
+			map[string]string{"device": makeMirrorJobId(index)}, nil)
+		if err != nil {
+			vm.mutex.RUnlock()
+			return err
+		}
+	}
+	vm.mutex.RUnlock()
+	stopTime := time.Now().Add(qmpTimeout)
+	for ; ; time.Sleep(100 * time.Millisecond) {
+		jobs, err := vm.getMirrorJobs(numVolumes)
+		if err != nil {
+			return err
+		}
+		numConcluded := 0
+		for _, job := range jobs {
+			if job.Status == "concluded" {
+				numConcluded++
+			}
+		}
+		if numConcluded == numVolumes {
+			return nil
+		}
+		if time.Now().After(stopTime) {
+			return errors.New("timed out completing volume mirrors")
+		}
+	}
+}
+
+// waitForMigration will wait until an outgoing migration completes or fails,
+// sending progress messages to progressFunc. The VM lock must not be held.
+func (vm *vmInfoType) waitForMigration(
+	progressFunc func(message string) error) error {
+	lastProgressTime := time.Now()
+	for ; ; time.Sleep(time.Second) {
+		var info qmpMigrationInfo
+		vm.mutex.RLock()
+		err := vm.qmpCommand("query-migrate", nil, &info)
+		vm.mutex.RUnlock()
+		if err != nil {
+			return err
+		}
+		switch info.Status {
+		case "completed":
+			return nil
+		case "failed", "cancelled":
+			return fmt.Errorf("migration %s: %s",
+				info.Status, info.ErrorDescription)
+		}
+		if info.Ram == nil ||
+			time.Since(lastProgressTime) < migrationProgressInterval {
+			continue
+		}
+		err = progressFunc(fmt.Sprintf(
+			"migration %s: copied %s of memory, %s of %s remaining",
+			info.Status, format.FormatBytes(info.Ram.Transferred),
+			format.FormatBytes(info.Ram.Remaining),
+			format.FormatBytes(info.Ram.Total)))
+		if err != nil {
+			return err
+		}
+		lastProgressTime = time.Now()
+	}
+}
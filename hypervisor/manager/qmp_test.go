@@ -0,0 +1,394 @@
+package manager
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+type fakeQemuHandler func(command string,
+	arguments map[string]interface{}) (interface{}, error)
+
+type fakeQemuJob struct {
+	numQueries int
+	status     string
+}
+
+// fakeMirrorQemu simulates the block devices and jobs of a QEMU process.
+type fakeMirrorQemu struct {
+	mutex          sync.Mutex
+	failDevice     string
+	jobs           map[string]*fakeQemuJob
+	mirrorTargets  map[string]string // Key: device, value: target.
+	nbdExports     map[string]string // Key: export name, value: device.
+	nbdServerState string
+}
+
+// startFakeQemu will connect the VM to a fake QEMU monitor which passes
+// commands to handler. The returned function disconnects the monitor.
+func startFakeQemu(vm *vmInfoType, handler fakeQemuHandler) func() {
+	reader, writer := io.Pipe()
+	commandChannel := make(chan string, 1)
+	vm.commandChannel = commandChannel
+	go vm.readMonitorResponses(reader)
+	go func() {
+		encoder := json.NewEncoder(writer)
+		for command := range commandChannel {
+			var request struct {
+				Arguments map[string]interface{} `json:"arguments"`
+				Execute   string                 `json:"execute"`
+				Id        uint64                 `json:"id"`
+			}
+			if err := json.Unmarshal([]byte(command), &request); err != nil {
+				continue
+			}
+			response := map[string]interface{}{"id": request.Id}
+			if reply, err := handler(request.Execute,
+				request.Arguments); err != nil {
+				response["error"] = qmpError{
+					Class:       "GenericError",
+					Description: err.Error(),
+				}
+			} else if reply == nil {
+				response["return"] = struct{}{}
+			} else {
+				response["return"] = reply
+			}
+			encoder.Encode(response)
+		}
+		writer.Close()
+	}()
+	return func() { close(commandChannel) }
+}
+
+func (qemu *fakeMirrorQemu) handle(command string,
+	arguments map[string]interface{}) (interface{}, error) {
+	qemu.mutex.Lock()
+	defer qemu.mutex.Unlock()
+	switch command {
+	case "query-block":
+		return []map[string]interface{}{
+			{"device": "ide1-cd0"},
+			{
+				"device": "virtio1",
+				"inserted": map[string]string{
+					"file": "/volumes/10.0.0.2/secondary-volume.0",
+				},
+			},
+			{
+				"device":   "virtio0",
+				"inserted": map[string]string{"file": "/volumes/10.0.0.2/root"},
+			},
+		}, nil
+	case "drive-mirror":
+		device := arguments["device"].(string)
+		if device == qemu.failDevice {
+			return nil, errors.New("mirror failed")
+		}
+		if arguments["sync"] != "full" || arguments["mode"] != "existing" ||
+			arguments["auto-dismiss"] != false {
+			return nil, errors.New("bad mirror arguments")
+		}
+		qemu.mirrorTargets[device] = arguments["target"].(string)
+		qemu.jobs[arguments["job-id"].(string)] = &fakeQemuJob{
+			status: "running"}
+	case "query-jobs":
+		var jobs []qmpJobInfo
+		for jobId, job := range qemu.jobs {
+			job.numQueries++
+			if job.status == "running" && job.numQueries > 1 {
+				job.status = "ready"
+			}
+			jobs = append(jobs, qmpJobInfo{
+				CurrentProgress: uint64(job.numQueries),
+				Id:              jobId,
+				Status:          job.status,
+				TotalProgress:   2,
+			})
+		}
+		return jobs, nil
+	case "block-job-cancel":
+		job, ok := qemu.jobs[arguments["device"].(string)]
+		if !ok {
+			return nil, errors.New("no such job")
+		}
+		if job.status == "ready" {
+			job.status = "concluded"
+		} else {
+			job.status = "aborting"
+		}
+	case "job-cancel":
+		job, ok := qemu.jobs[arguments["id"].(string)]
+		if !ok {
+			return nil, errors.New("no such job")
+		}
+		job.status = "concluded"
+	case "job-dismiss":
+		jobId := arguments["id"].(string)
+		if job, ok := qemu.jobs[jobId]; !ok || job.status != "concluded" {
+			return nil, errors.New("job not concluded")
+		}
+		delete(qemu.jobs, jobId)
+	case "nbd-server-start":
+		qemu.nbdServerState = "started"
+	case "nbd-server-add":
+		if arguments["writable"] != true {
+			return nil, errors.New("export not writable")
+		}
+		qemu.nbdExports[arguments["name"].(string)] =
+			arguments["device"].(string)
+	case "nbd-server-stop":
+		qemu.nbdServerState = "stopped"
+	default:
+		return nil, errors.New("unknown command: " + command)
+	}
+	return nil, nil
+}
+
+func newFakeMirrorQemu() *fakeMirrorQemu {
+	return &fakeMirrorQemu{
+		jobs:          make(map[string]*fakeQemuJob),
+		mirrorTargets: make(map[string]string),
+		nbdExports:    make(map[string]string),
+	}
+}
+
+func TestMakeMigrationUri(t *testing.T) {
+	goodAddresses := map[string]string{
+		"10.0.0.1:4444": "tcp:10.0.0.1:4444",
+		"[::1]:80":      "tcp:[::1]:80",
+		"10.0.0.1:0080": "tcp:10.0.0.1:80",
+	}
+	for address, expected := range goodAddresses {
+		if uri, err := makeMigrationUri(address); err != nil {
+			t.Errorf("%s: %s", address, err)
+		} else if uri != expected {
+			t.Errorf("%s: got: %s, expected: %s", address, uri, expected)
+		}
+	}
+	badAddresses := []string{
+		"",
+		"exec:rm -rf /",
+		"tcp:10.0.0.1:4444",
+		"unix:/tmp/sock",
+		"10.0.0.1",
+		"hostname:4444",
+		"10.0.0.1:0",
+		"10.0.0.1:65536",
+		"10.0.0.1:4444,server",
+		"10.0.0.1:4444/x",
+	}
+	for _, address := range badAddresses {
+		if uri, err := makeMigrationUri(address); err == nil {
+			t.Errorf("%s: no error, got: %s", address, uri)
+		}
+		if uri, err := makeNbdUri(address, 0); err == nil {
+			t.Errorf("%s: no error, got: %s", address, uri)
+		}
+	}
+	if uri, err := makeNbdUri("[fe80::1]:10809", 1); err != nil {
+		t.Error(err)
+	} else if uri != "nbd://[fe80::1]:10809/volume1" {
+		t.Errorf("bad NBD URI: %s", uri)
+	}
+}
+
+func TestMatchVolumeDevices(t *testing.T) {
+	vm := &vmInfoType{
+		logger: testlogger.New(t),
+		LocalVmInfo: proto.LocalVmInfo{
+			VolumeLocations: []proto.LocalVolume{
+				{Filename: "/volumes/10.0.0.2/root"},
+				{Filename: "/volumes/10.0.0.2/secondary-volume.0"},
+			},
+		},
+	}
+	qemu := newFakeMirrorQemu()
+	stop := startFakeQemu(vm, qemu.handle)
+	defer stop()
+	devices, err := vm.getVolumeDevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 2 || devices[0] != "virtio0" ||
+		devices[1] != "virtio1" {
+		t.Errorf("bad devices: %v", devices)
+	}
+	vm.VolumeLocations = append(vm.VolumeLocations,
+		proto.LocalVolume{Filename: "/volumes/10.0.0.2/missing"})
+	if _, err := vm.getVolumeDevices(); err == nil {
+		t.Error("no error for missing volume")
+	}
+}
+
+func TestCheckLiveMigrationVolumes(t *testing.T) {
+	volumes := []proto.Volume{{Size: 1 << 30}, {Size: 1 << 20}}
+	if err := checkLiveMigrationVolumes(volumes); err != nil {
+		t.Fatal(err)
+	}
+	volumes[1].Format = proto.VolumeFormatQCOW2
+	if err := checkLiveMigrationVolumes(volumes); err == nil {
+		t.Error("no error for QCOW2 volume")
+	}
+}
+
+func TestVolumeMirrors(t *testing.T) {
+	vm := &vmInfoType{
+		logger: testlogger.New(t),
+		LocalVmInfo: proto.LocalVmInfo{
+			VolumeLocations: []proto.LocalVolume{
+				{Filename: "/volumes/10.0.0.2/root"},
+				{Filename: "/volumes/10.0.0.2/secondary-volume.0"},
+			},
+		},
+	}
+	qemu := newFakeMirrorQemu()
+	stop := startFakeQemu(vm, qemu.handle)
+	defer stop()
+	if err := vm.startVolumeMirrors("10.0.0.1:10809"); err != nil {
+		t.Fatal(err)
+	}
+	expectedTargets := map[string]string{
+		"virtio0": "nbd://10.0.0.1:10809/volume0",
+		"virtio1": "nbd://10.0.0.1:10809/volume1",
+	}
+	for device, target := range expectedTargets {
+		if qemu.mirrorTargets[device] != target {
+			t.Errorf("%s: target: %s, expected: %s",
+				device, qemu.mirrorTargets[device], target)
+		}
+	}
+	err := vm.waitForVolumeMirrors(2, func(message string) error {
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.completeVolumeMirrors(2); err != nil {
+		t.Fatal(err)
+	}
+	if len(qemu.jobs) != 0 {
+		t.Errorf("jobs not dismissed: %v", qemu.jobs)
+	}
+}
+
+func TestVolumeMirrorFailures(t *testing.T) {
+	vm := &vmInfoType{
+		logger: testlogger.New(t),
+		LocalVmInfo: proto.LocalVmInfo{
+			VolumeLocations: []proto.LocalVolume{
+				{Filename: "/volumes/10.0.0.2/root"},
+				{Filename: "/volumes/10.0.0.2/secondary-volume.0"},
+			},
+		},
+	}
+	qemu := newFakeMirrorQemu()
+	qemu.failDevice = "virtio1"
+	stop := startFakeQemu(vm, qemu.handle)
+	defer stop()
+	if err := vm.startVolumeMirrors("10.0.0.1:10809"); err == nil {
+		t.Fatal("no error for failed mirror")
+	}
+	if len(qemu.jobs) != 0 {
+		t.Errorf("jobs not cancelled: %v", qemu.jobs)
+	}
+	if err := vm.startVolumeMirrors("host:10809"); err == nil {
+		t.Error("no error for bad NBD address")
+	}
+	// A job which stops before it is ready is a failure.
+	qemu.failDevice = ""
+	if err := vm.startVolumeMirrors("10.0.0.1:10809"); err != nil {
+		t.Fatal(err)
+	}
+	qemu.jobs[makeMirrorJobId(1)].status = "concluded"
+	qemu.jobs[makeMirrorJobId(1)].numQueries = -10
+	err := vm.waitForVolumeMirrors(2, func(message string) error {
+		return nil
+	})
+	if err == nil {
+		t.Error("no error for stopped mirror")
+	}
+	delete(qemu.jobs, makeMirrorJobId(1))
+	if err := vm.completeVolumeMirrors(2); err == nil {
+		t.Error("no error for missing mirror")
+	}
+}
+
+func TestStartNbdServer(t *testing.T) {
+	vm := &vmInfoType{
+		logger: testlogger.New(t),
+		LocalVmInfo: proto.LocalVmInfo{
+			VolumeLocations: []proto.LocalVolume{
+				{Filename: "/volumes/10.0.0.2/root"},
+				{Filename: "/volumes/10.0.0.2/secondary-volume.0"},
+			},
+		},
+	}
+	qemu := newFakeMirrorQemu()
+	stop := startFakeQemu(vm, qemu.handle)
+	defer stop()
+	if err := vm.startNbdServer("10.0.0.1:10809"); err != nil {
+		t.Fatal(err)
+	}
+	if qemu.nbdServerState != "started" {
+		t.Fatal("NBD server not started")
+	}
+	if qemu.nbdExports["volume0"] != "virtio0" ||
+		qemu.nbdExports["volume1"] != "virtio1" {
+		t.Errorf("bad exports: %v", qemu.nbdExports)
+	}
+	vm.stopNbdServer()
+	if qemu.nbdServerState != "stopped" {
+		t.Error("NBD server not stopped")
+	}
+}
+
+func TestMakeMirrorProgress(t *testing.T) {
+	message := makeMirrorProgress([]qmpJobInfo{
+		{CurrentProgress: 1 << 30, TotalProgress: 1 << 30, Status: "ready"},
+		{CurrentProgress: 1 << 29, TotalProgress: 1 << 31, Status: "running"},
+	})
+	if !strings.Contains(message, "1 of 2 volume(s)") {
+		t.Errorf("bad progress message: %s", message)
+	}
+}
+
+func TestRequestVmMigrationCommit(t *testing.T) {
+	for _, commit := range []bool{false, true} {
+		var input, output bytes.Buffer
+		err := gob.NewEncoder(&input).Encode(
+			proto.MigrateVmResponseResponse{Commit: commit})
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn := &srpc.Conn{
+			Decoder: gob.NewDecoder(&input),
+			Encoder: gob.NewEncoder(&output),
+			ReadWriter: bufio.NewReadWriter(bufio.NewReader(&input),
+				bufio.NewWriter(&output)),
+		}
+		err = requestVmMigrationCommit(conn)
+		if commit && err != nil {
+			t.Error(err)
+		} else if !commit && err == nil {
+			t.Error("no error for abandoned migration")
+		}
+		var response proto.MigrateVmResponse
+		if err := gob.NewDecoder(&output).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+		if !response.RequestCommit {
+			t.Errorf("commit not requested: %v", response)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Symantec/Dominator/lib/json"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const quotasFilename = "quotas.json"
+
+// checkQuotasWithLock returns an error if adding extra resources for VMs with
+// the specified owners would exceed a quota. The exclude VM (if not nil) is
+// not counted. The copies recorded by updateQuotaInfo are used, so that VM
+// locks need not be taken. The manager lock must be held.
+func (m *Manager) checkQuotasWithLock(ownerUser string, ownerGroups []string,
+	exclude *vmInfoType, extra proto.Quota) error {
+	for _, quota := range m.quotas {
+		if !quota.AppliesTo(ownerUser, ownerGroups) {
+			continue
+		}
+		used := quota.Used
+		for _, vm := range m.vms {
+			if vm == exclude {
+				continue
+			}
+			if vmInfo := vm.getQuotaInfo(); vmInfo != nil &&
+				quota.AppliesToVm(vmInfo) {
+				used.AddVm(vmInfo)
+			}
+		}
+		if err := quota.Limit.CheckAvailable(used, extra); err != nil {
+			if quota.User != "" {
+				return fmt.Errorf("quota for user: %s in: %s exceeded: %s",
+					quota.User, quota.Location, err)
+			}
+			return fmt.Errorf("quota for group: %s in: %s exceeded: %s",
+				quota.Group, quota.Location, err)
+		}
+	}
+	return nil
+}
+
+// checkVmQuotas returns an error if the resources allocated to the VM exceed
+// a quota of its owners.
+func (m *Manager) checkVmQuotas(vm *vmInfoType) error {
+	var extra proto.Quota
+	extra.AddVm(&vm.VmInfo)
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	var ownerUser string
+	if len(vm.OwnerUsers) > 0 {
+		ownerUser = vm.OwnerUsers[0]
+	}
+	return m.checkQuotasWithLock(ownerUser, vm.OwnerGroups, vm, extra)
+}
+
+func (m *Manager) loadQuotas() error {
+	var request proto.UpdateQuotasRequest
+	err := json.ReadFromFile(filepath.Join(m.StateDir, quotasFilename),
+		&request)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	m.quotas = request.Quotas
+	return nil
+}
+
+func (m *Manager) updateQuotas(request proto.UpdateQuotasRequest) error {
+	for _, quota := range request.Quotas {
+		if (quota.Group == "") == (quota.User == "") {
+			return fmt.Errorf("quota in: %s must have one of Group or User",
+				quota.Location)
+		}
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.quotas = request.Quotas
+	return json.WriteToFile(filepath.Join(m.StateDir, quotasFilename),
+		publicFilePerms, "    ", request)
+}
+
+// getQuotaInfo returns the copy of the VM resources and owners recorded by
+// updateQuotaInfo, or nil if none has been recorded. It must not be modified.
+func (vm *vmInfoType) getQuotaInfo() *proto.VmInfo {
+	vm.quotaMutex.Lock()
+	defer vm.quotaMutex.Unlock()
+	return vm.quotaInfo
+}
+
+// updateQuotaInfo will record a copy of the VM resources and owners for quota
+// checks. The VM lock must be held.
+func (vm *vmInfoType) updateQuotaInfo() {
+	quotaInfo := &proto.VmInfo{
+		MemoryInMiB: vm.MemoryInMiB,
+		MilliCPUs:   vm.MilliCPUs,
+		OwnerGroups: append([]string(nil), vm.OwnerGroups...),
+		OwnerUsers:  append([]string(nil), vm.OwnerUsers...),
+		Volumes:     append([]proto.Volume(nil), vm.Volumes...),
+	}
+	vm.quotaMutex.Lock()
+	vm.quotaInfo = quotaInfo
+	vm.quotaMutex.Unlock()
+}
@@ -0,0 +1,94 @@
+package manager
+
+import (
+	"testing"
+
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func TestCheckQuotas(t *testing.T) {
+	vm := &vmInfoType{
+		LocalVmInfo: proto.LocalVmInfo{
+			VmInfo: proto.VmInfo{
+				MemoryInMiB: 1024,
+				MilliCPUs:   1000,
+				OwnerGroups: []string{"team"},
+				OwnerUsers:  []string{"alice"},
+				Volumes:     []proto.Volume{{Size: 1 << 30}},
+			},
+		},
+	}
+	m := &Manager{
+		quotas: []proto.OwnerQuota{
+			{
+				Group:    "team",
+				Limit:    proto.Quota{MemoryInMiB: 4096, NumVMs: 3},
+				Location: "SJC",
+				Used:     proto.Quota{MemoryInMiB: 2048, NumVMs: 1},
+			},
+			{
+				Limit:    proto.Quota{MilliCPUs: 2000},
+				Location: "SJC",
+				User:     "alice",
+			},
+		},
+		vms: map[string]*vmInfoType{"10.0.0.1": vm},
+	}
+	vm.updateQuotaInfo()
+	// Changes are not seen until the copy is updated.
+	vm.MemoryInMiB = 4096
+	tests := []struct {
+		user     string
+		groups   []string
+		exclude  *vmInfoType
+		extra    proto.Quota
+		expectOK bool
+	}{
+		{"bob", []string{"team"}, nil, proto.Quota{MemoryInMiB: 1024}, true},
+		{"bob", []string{"team"}, nil, proto.Quota{MemoryInMiB: 1025}, false},
+		{"bob", []string{"team"}, vm, proto.Quota{MemoryInMiB: 2048}, true},
+		{"bob", []string{"team"}, nil, proto.Quota{NumVMs: 1}, true},
+		{"bob", []string{"team"}, nil, proto.Quota{NumVMs: 2}, false},
+		{"bob", nil, nil, proto.Quota{MemoryInMiB: 1 << 20}, true},
+		{"alice", nil, nil, proto.Quota{MilliCPUs: 1000}, true},
+		{"alice", nil, nil, proto.Quota{MilliCPUs: 1001}, false},
+		{"alice", nil, nil, proto.Quota{VolumeBytes: 1 << 40}, true},
+	}
+	for _, test := range tests {
+		err := m.checkQuotasWithLock(test.user, test.groups, test.exclude,
+			test.extra)
+		if test.expectOK && err != nil {
+			t.Errorf("%s %v %+v: unexpected error: %s",
+				test.user, test.groups, test.extra, err)
+		} else if !test.expectOK && err == nil {
+			t.Errorf("%s %v %+v: quota not enforced",
+				test.user, test.groups, test.extra)
+		}
+	}
+}
+
+func TestUpdateQuotaInfo(t *testing.T) {
+	vm := &vmInfoType{
+		LocalVmInfo: proto.LocalVmInfo{
+			VmInfo: proto.VmInfo{
+				MemoryInMiB: 1024,
+				OwnerUsers:  []string{"alice"},
+				Volumes:     []proto.Volume{{Size: 1 << 30}},
+			},
+		},
+	}
+	if vm.getQuotaInfo() != nil {
+		t.Error("quota information before update")
+	}
+	vm.updateQuotaInfo()
+	vm.OwnerUsers[0] = "bob"
+	vm.Volumes[0].Size = 2 << 30
+	quotaInfo := vm.getQuotaInfo()
+	if quotaInfo == nil {
+		t.Fatal("no quota information after update")
+	}
+	if quotaInfo.MemoryInMiB != 1024 || quotaInfo.OwnerUsers[0] != "alice" ||
+		quotaInfo.Volumes[0].Size != 1<<30 {
+		t.Errorf("quota information not copied: %+v", quotaInfo)
+	}
+}
@@ -0,0 +1,156 @@
+package manager
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+var testResizeAuth = &srpc.AuthInformation{HaveMethodAccess: true}
+
+func TestChangeVmSize(t *testing.T) {
+	m, vm, topDir := makeTestVm(t)
+	defer os.RemoveAll(topDir)
+	ipAddr := net.ParseIP(vm.ipAddress)
+	tests := []struct {
+		name        string
+		state       proto.State
+		memoryInMiB uint64
+		milliCPUs   uint
+		err         error
+	}{
+		{"hot-plug", proto.StateRunning, 2048, 0, errorVmSizeHotPlug},
+		// An unchanged size is not a hot-plug.
+		{"unchanged", proto.StateRunning, 1024, 1000, nil},
+		{"too many CPUs", proto.StateStopped, 0, 3000,
+			errorInsufficientUnallocatedCPU},
+		{"too much memory", proto.StateStopped, 8192, 0,
+			errorInsufficientUnallocatedMemory},
+		{"resize", proto.StateStopped, 512, 2000, nil},
+	}
+	for _, test := range tests {
+		vm.State = test.state
+		err := m.changeVmSize(ipAddr, testResizeAuth, nil, test.memoryInMiB,
+			test.milliCPUs)
+		if err != test.err {
+			t.Errorf("%s: error: %v, expected: %v", test.name, err, test.err)
+		}
+	}
+	if vm.MemoryInMiB != 512 || vm.MilliCPUs != 2000 {
+		t.Errorf("size: %d MiB, %d milliCPUs, expected: 512 MiB, 2000",
+			vm.MemoryInMiB, vm.MilliCPUs)
+	}
+	if _, err := os.Stat(filepath.Join(vm.dirname, "info.json")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGrowVmVolume(t *testing.T) {
+	m, vm, topDir := makeTestVm(t)
+	defer os.RemoveAll(topDir)
+	ipAddr := net.ParseIP(vm.ipAddress)
+	tests := []struct {
+		name      string
+		format    proto.VolumeFormat
+		state     proto.State
+		index     uint
+		size      uint64
+		expectErr bool
+	}{
+		{"bad index", proto.VolumeFormatRaw, proto.StateStopped, 1, 2 << 20,
+			true},
+		{"unchanged size", proto.VolumeFormatRaw, proto.StateStopped, 0,
+			1 << 20, true},
+		{"QCOW2", proto.VolumeFormatQCOW2, proto.StateStopped, 0, 2 << 20,
+			true},
+		{"starting VM", proto.VolumeFormatRaw, proto.StateStarting, 0,
+			2 << 20, true},
+		{"grow", proto.VolumeFormatRaw, proto.StateStopped, 0, 2 << 20,
+			false},
+	}
+	for _, test := range tests {
+		vm.Volumes[0].Format = test.format
+		vm.State = test.state
+		err := m.growVmVolume(ipAddr, testResizeAuth, nil, test.index,
+			test.size)
+		if test.expectErr && err == nil {
+			t.Errorf("%s: no error", test.name)
+		} else if !test.expectErr && err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+	}
+	if vm.Volumes[0].Size != 2<<20 {
+		t.Errorf("volume size: %d, expected: %d", vm.Volumes[0].Size, 2<<20)
+	}
+	fi, err := os.Stat(vm.VolumeLocations[0].Filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fi.Size() != 2<<20 {
+		t.Errorf("file size: %d, expected: %d", fi.Size(), 2<<20)
+	}
+}
+
+func TestGrowRunningVmVolume(t *testing.T) {
+	m, vm, topDir := makeTestVm(t)
+	defer os.RemoveAll(topDir)
+	vm.State = proto.StateRunning
+	var resizedDevice string
+	var resizedSize float64
+	stop := startFakeQemu(vm, func(command string,
+		arguments map[string]interface{}) (interface{}, error) {
+		if command == "block_resize" {
+			resizedDevice = arguments["device"].(string)
+			resizedSize = arguments["size"].(float64)
+		}
+		return nil, nil
+	})
+	defer stop()
+	if err := m.growVmVolume(net.ParseIP(vm.ipAddress), testResizeAuth, nil,
+		0, 2<<20); err != nil {
+		t.Fatal(err)
+	}
+	if resizedDevice != "virtio0" || resizedSize != 2<<20 {
+		t.Errorf("block_resize: %s to %.0f", resizedDevice, resizedSize)
+	}
+}
+
+func TestAddVmVolume(t *testing.T) {
+	m, vm, topDir := makeTestVm(t)
+	defer os.RemoveAll(topDir)
+	ipAddr := net.ParseIP(vm.ipAddress)
+	if err := m.addVmVolume(ipAddr, testResizeAuth, nil, 0); err == nil {
+		t.Error("no error for empty volume")
+	}
+	vm.State = proto.StateRunning
+	if err := m.addVmVolume(ipAddr, testResizeAuth, nil, 1<<20); err == nil {
+		t.Error("no error for running VM")
+	}
+	vm.State = proto.StateStopped
+	for count := 0; count < 2; count++ {
+		if err := m.addVmVolume(ipAddr, testResizeAuth, nil,
+			1<<20); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(vm.Volumes) != 3 || len(vm.VolumeLocations) != 3 {
+		t.Fatalf("volumes: %d, locations: %d, expected: 3",
+			len(vm.Volumes), len(vm.VolumeLocations))
+	}
+	for index, expected := range []string{
+		"secondary-volume.0", "secondary-volume.1"} {
+		location := vm.VolumeLocations[index+1]
+		if filepath.Base(location.Filename) != expected {
+			t.Errorf("volume: %d: filename: %s, expected: %s",
+				index+1, location.Filename, expected)
+		}
+		if location.DirectoryToCleanup != vm.dirname {
+			t.Errorf("volume: %d: directory: %s, expected: %s",
+				index+1, location.DirectoryToCleanup, vm.dirname)
+		}
+	}
+}
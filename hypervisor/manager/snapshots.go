@@ -0,0 +1,388 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/fsutil"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/lib/tags"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const (
+	minimumSnapshotInterval = time.Minute
+	snapshotCheckInterval   = time.Minute
+)
+
+func checkSnapshotPolicy(policy *proto.SnapshotPolicy) error {
+	if policy == nil {
+		return nil
+	}
+	for _, schedule := range policy.Schedules {
+		if schedule.Interval < minimumSnapshotInterval {
+			return fmt.Errorf("snapshot interval: %s less than: %s",
+				schedule.Interval, minimumSnapshotInterval)
+		}
+		if schedule.Retain < schedule.Interval {
+			return fmt.Errorf("snapshot retention: %s less than interval: %s",
+				schedule.Retain, schedule.Interval)
+		}
+	}
+	return nil
+}
+
+// checkSnapshotSpace returns an error if any directory has insufficient free
+// space for the bytes needed in it.
+func checkSnapshotSpace(neededSpace map[string]int64) error {
+	freeSpaceTable := make(map[string]uint64, len(neededSpace))
+	for dirname, needed := range neededSpace {
+		if needed <= 0 {
+			continue
+		}
+		freeSpace, err := getFreeSpace(dirname, freeSpaceTable)
+		if err != nil {
+			return err
+		}
+		if uint64(needed) >= freeSpace {
+			return fmt.Errorf(
+				"insufficient space for snapshot in: %s, need: %s, free: %s",
+				dirname, format.FormatBytes(uint64(needed)),
+				format.FormatBytes(freeSpace))
+		}
+	}
+	return nil
+}
+
+func checkSnapshotName(name string) error {
+	for _, ch := range name {
+		if ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' ||
+			ch >= '0' && ch <= '9' || ch == '-' || ch == '_' || ch == '.' {
+			continue
+		}
+		return fmt.Errorf("invalid character: '%c' in snapshot name", ch)
+	}
+	return nil
+}
+
+// getFileUsage returns the number of bytes allocated to a file, which is less
+// than its size if the file is sparse.
+func getFileUsage(filename string) (uint64, error) {
+	var statbuf syscall.Stat_t
+	if err := syscall.Stat(filename, &statbuf); err != nil {
+		return 0, err
+	}
+	return uint64(statbuf.Blocks) * 512, nil
+}
+
+func getSnapshotFilename(volumeFilename, name string) string {
+	if name == "" {
+		return volumeFilename + ".snapshot"
+	}
+	return volumeFilename + ".snapshot." + name
+}
+
+func makeScheduledSnapshotName(interval time.Duration, now time.Time) string {
+	return fmt.Sprintf("auto-%s-%s",
+		interval, now.UTC().Format("20060102-150405"))
+}
+
+// matchTags returns true if all the match tags are present in tgs.
+func matchTags(tgs, match tags.Tags) bool {
+	for key, value := range match {
+		if tgs[key] != value {
+			return false
+		}
+	}
+	return true
+}
+
+// planSnapshots returns the schedules for which a snapshot is due and the
+// names of the snapshots which have expired. Snapshots are expired by the
+// schedule which took them, or immediately if that schedule was removed from
+// the policy. Manual snapshots never expire.
+func planSnapshots(policy *proto.SnapshotPolicy, snapshots []proto.VmSnapshot,
+	now time.Time) ([]proto.SnapshotSchedule, []string) {
+	var due []proto.SnapshotSchedule
+	var expired []string
+	intervals := make(map[time.Duration]struct{}, len(policy.Schedules))
+	for _, schedule := range policy.Schedules {
+		intervals[schedule.Interval] = struct{}{}
+	}
+	for _, snapshot := range snapshots {
+		if snapshot.Interval == 0 {
+			continue
+		}
+		if _, ok := intervals[snapshot.Interval]; !ok {
+			expired = append(expired, snapshot.Name)
+		}
+	}
+	for _, schedule := range policy.Schedules {
+		var latest time.Time
+		for _, snapshot := range snapshots {
+			if snapshot.Interval != schedule.Interval {
+				continue
+			}
+			if now.Sub(snapshot.CreatedOn) > schedule.Retain {
+				expired = append(expired, snapshot.Name)
+				continue
+			}
+			if snapshot.CreatedOn.After(latest) {
+				latest = snapshot.CreatedOn
+			}
+		}
+		if now.Sub(latest) >= schedule.Interval {
+			due = append(due, schedule)
+		}
+	}
+	return due, expired
+}
+
+func (m *Manager) getSnapshotBytes() uint64 {
+	var numBytes uint64
+	for _, vm := range m.getVMs() {
+		vm.mutex.RLock()
+		for _, snapshot := range vm.Snapshots {
+			numBytes += snapshot.Size
+		}
+		vm.mutex.RUnlock()
+	}
+	return numBytes
+}
+
+// getSnapshotPolicy returns the policy for the VM, or the policy of the first
+// rule matching the VM tags. The VM lock must be held.
+func (m *Manager) getSnapshotPolicy(vm *vmInfoType) *proto.SnapshotPolicy {
+	if vm.SnapshotPolicy != nil {
+		return vm.SnapshotPolicy
+	}
+	for index, rule := range m.SnapshotPolicyRules {
+		if matchTags(vm.Tags, rule.MatchTags) {
+			return &m.SnapshotPolicyRules[index].Policy
+		}
+	}
+	return nil
+}
+
+// getVMs returns a copy of the VM list, so that VMs may be locked without
+// holding the manager lock.
+func (m *Manager) getVMs() []*vmInfoType {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	vms := make([]*vmInfoType, 0, len(m.vms))
+	for _, vm := range m.vms {
+		vms = append(vms, vm)
+	}
+	return vms
+}
+
+func (m *Manager) listVmSnapshots(ipAddr net.IP,
+	authInfo *srpc.AuthInformation) ([]proto.VmSnapshot, error) {
+	vm, err := m.getVmLockAndAuth(ipAddr, false, authInfo, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer vm.mutex.RUnlock()
+	snapshots := make([]proto.VmSnapshot, len(vm.Snapshots))
+	copy(snapshots, vm.Snapshots)
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].CreatedOn.Before(snapshots[j].CreatedOn)
+	})
+	return snapshots, nil
+}
+
+func (m *Manager) setVmSnapshotPolicy(ipAddr net.IP,
+	authInfo *srpc.AuthInformation, policy *proto.SnapshotPolicy) error {
+	if err := checkSnapshotPolicy(policy); err != nil {
+		return err
+	}
+	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, nil)
+	if err != nil {
+		return err
+	}
+	defer vm.mutex.Unlock()
+	vm.SnapshotPolicy = policy
+	return vm.writeInfo()
+}
+
+func (m *Manager) snapshotLoop() {
+	for ; ; time.Sleep(snapshotCheckInterval) {
+		for _, vm := range m.getVMs() {
+			vm.applySnapshotPolicy(time.Now())
+		}
+	}
+}
+
+func (vm *vmInfoType) applySnapshotPolicy(now time.Time) {
+	vm.mutex.Lock()
+	defer vm.mutex.Unlock()
+	policy := vm.manager.getSnapshotPolicy(vm)
+	if policy == nil {
+		return
+	}
+	switch vm.State {
+	case proto.StateRunning:
+	case proto.StateStopped:
+	default:
+		return
+	}
+	due, expired := planSnapshots(policy, vm.Snapshots, now)
+	for _, name := range expired {
+		if err := vm.removeSnapshot(name); err != nil {
+			vm.logger.Printf("error expiring snapshot: %s: %s\n", name, err)
+		} else {
+			vm.logger.Debugf(0, "expired snapshot: %s\n", name)
+		}
+	}
+	if vm.State != proto.StateStopped && !policy.ForceIfNotStopped {
+		return
+	}
+	for _, schedule := range due {
+		name := makeScheduledSnapshotName(schedule.Interval, now)
+		err := vm.takeSnapshot(name, policy.RootOnly, schedule.Interval, now)
+		if err != nil {
+			vm.logger.Printf("error taking snapshot: %s: %s\n", name, err)
+		} else {
+			vm.logger.Debugf(0, "took snapshot: %s\n", name)
+		}
+	}
+}
+
+// removeSnapshot will remove the snapshot files and record. The VM lock must be
+// held.
+func (vm *vmInfoType) removeSnapshot(name string) error {
+	if err := vm.removeSnapshotFiles(name); err != nil {
+		return err
+	}
+	return vm.removeSnapshotRecord(name)
+}
+
+func (vm *vmInfoType) removeSnapshotFiles(name string) error {
+	for _, volume := range vm.VolumeLocations {
+		err := os.Remove(getSnapshotFilename(volume.Filename, name))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func (vm *vmInfoType) removeSnapshotRecord(name string) error {
+	snapshots := make([]proto.VmSnapshot, 0, len(vm.Snapshots))
+	for _, snapshot := range vm.Snapshots {
+		if snapshot.Name != name {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	if len(snapshots) == len(vm.Snapshots) {
+		return nil
+	}
+	if len(snapshots) < 1 {
+		snapshots = nil
+	}
+	vm.Snapshots = snapshots
+	return vm.writeInfo()
+}
+
+// restoreSnapshot will replace the volumes with the snapshot, consuming it.
+// The VM lock must be held.
+func (vm *vmInfoType) restoreSnapshot(name string) error {
+	if name != "" {
+		found := false
+		for _, snapshot := range vm.Snapshots {
+			if snapshot.Name == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("snapshot: %s not found", name)
+		}
+	}
+	volumes := make([]proto.Volume, len(vm.Volumes))
+	copy(volumes, vm.Volumes)
+	for index, volume := range vm.VolumeLocations {
+		snapshotFilename := getSnapshotFilename(volume.Filename, name)
+		if err := os.Rename(snapshotFilename, volume.Filename); err != nil {
+			if !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		if fi, err := os.Stat(volume.Filename); err == nil &&
+			index < len(volumes) {
+			volumes[index].Size = uint64(fi.Size())
+		}
+	}
+	vm.Volumes = volumes
+	vm.writeAndSendInfo()
+	return vm.removeSnapshotRecord(name)
+}
+
+// takeSnapshot will copy the volumes to a snapshot, replacing any existing
+// snapshot with the same name. Snapshots are stored alongside the volumes, so
+// there must be sufficient free space in the volume directories. The VM lock
+// must be held.
+func (vm *vmInfoType) takeSnapshot(name string, rootOnly bool,
+	interval time.Duration, now time.Time) error {
+	neededSpace := make(map[string]int64) // Key: directory.
+	var volumes []proto.LocalVolume
+	for index, volume := range vm.VolumeLocations {
+		if index > 0 && rootOnly {
+			break
+		}
+		fi, err := os.Stat(volume.Filename)
+		if err != nil {
+			return err
+		}
+		dirname := filepath.Dir(volume.Filename)
+		neededSpace[dirname] += fi.Size()
+		volumes = append(volumes, volume)
+	}
+	for _, volume := range vm.VolumeLocations { // Will be replaced.
+		fi, err := os.Stat(getSnapshotFilename(volume.Filename, name))
+		if err == nil {
+			neededSpace[filepath.Dir(volume.Filename)] -= fi.Size()
+		}
+	}
+	if err := checkSnapshotSpace(neededSpace); err != nil {
+		return err
+	}
+	if err := vm.removeSnapshot(name); err != nil {
+		return err
+	}
+	var totalSize uint64
+	for _, volume := range volumes {
+		snapshotFilename := getSnapshotFilename(volume.Filename, name)
+		err := fsutil.CopyFile(snapshotFilename, volume.Filename,
+			privateFilePerms)
+		if err != nil {
+			vm.removeSnapshotFiles(name)
+			return err
+		}
+		usage, err := getFileUsage(snapshotFilename)
+		if err != nil {
+			vm.removeSnapshotFiles(name)
+			return err
+		}
+		totalSize += usage
+	}
+	vm.Snapshots = append(vm.Snapshots, proto.VmSnapshot{
+		CreatedOn: now,
+		Interval:  interval,
+		Name:      name,
+		RootOnly:  rootOnly,
+		Size:      totalSize,
+	})
+	if err := vm.writeInfo(); err != nil {
+		return errors.New("error recording snapshot: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package manager
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func TestPlanSnapshots(t *testing.T) {
+	now := time.Date(2020, 1, 10, 0, 0, 0, 0, time.UTC)
+	policy := &proto.SnapshotPolicy{
+		Schedules: []proto.SnapshotSchedule{
+			{Interval: time.Hour, Retain: time.Hour * 3},
+			{Interval: time.Hour * 24, Retain: time.Hour * 24 * 7},
+		},
+	}
+	due, expired := planSnapshots(policy, nil, now)
+	if len(due) != 2 || len(expired) != 0 {
+		t.Fatalf("no snapshots: due: %v, expired: %v", due, expired)
+	}
+	snapshots := []proto.VmSnapshot{
+		{CreatedOn: now.Add(-time.Hour * 4), Interval: time.Hour, Name: "h4"},
+		{CreatedOn: now.Add(-time.Minute * 30), Interval: time.Hour,
+			Name: "h0"},
+		{CreatedOn: now.Add(-time.Hour * 25), Interval: time.Hour * 24,
+			Name: "d1"},
+		{CreatedOn: now.Add(-time.Hour * 24 * 30), Name: "manual"},
+		{CreatedOn: now.Add(-time.Minute), Interval: time.Minute * 30,
+			Name: "orphan"},
+	}
+	due, expired = planSnapshots(policy, snapshots, now)
+	if len(due) != 1 || due[0].Interval != time.Hour*24 {
+		t.Errorf("expected daily snapshot due, got: %v", due)
+	}
+	if len(expired) != 2 || expired[0] != "orphan" || expired[1] != "h4" {
+		t.Errorf("expected orphan and h4 to expire, got: %v", expired)
+	}
+}
+
+func TestGetFileUsage(t *testing.T) {
+	dirname, err := ioutil.TempDir("", "hypervisor.manager.test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dirname)
+	filename := filepath.Join(dirname, "sparse")
+	if err := ioutil.WriteFile(filename, nil, privateFilePerms); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Truncate(filename, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	usage, err := getFileUsage(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage >= 1<<20 {
+		t.Errorf("sparse file usage: %d not less than size", usage)
+	}
+}
+
+func TestTakeSnapshot(t *testing.T) {
+	_, vm, topDir := makeTestVm(t)
+	defer os.RemoveAll(topDir)
+	rootFilename := vm.VolumeLocations[0].Filename
+	snapshotFilename := getSnapshotFilename(rootFilename, "s1")
+	now := time.Now()
+	for count := 0; count < 2; count++ { // The second replaces the first.
+		if err := vm.takeSnapshot("s1", false, 0, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(vm.Snapshots) != 1 || vm.Snapshots[0].Name != "s1" {
+		t.Fatalf("expected one snapshot: s1, got: %v", vm.Snapshots)
+	}
+	usage, err := getFileUsage(snapshotFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vm.Snapshots[0].Size != usage {
+		t.Errorf("snapshot size: %d != usage: %d",
+			vm.Snapshots[0].Size, usage)
+	}
+	if _, err := os.Stat(filepath.Join(vm.dirname, "info.json")); err != nil {
+		t.Errorf("snapshot not recorded: %s", err)
+	}
+}
+
+func TestRestoreSnapshot(t *testing.T) {
+	_, vm, topDir := makeTestVm(t)
+	defer os.RemoveAll(topDir)
+	rootFilename := vm.VolumeLocations[0].Filename
+	original := bytes.Repeat([]byte{'a'}, 4096)
+	err := ioutil.WriteFile(rootFilename, original, privateFilePerms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.takeSnapshot("s1", false, 0, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	err = ioutil.WriteFile(rootFilename, []byte("changed"), privateFilePerms)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vm.restoreSnapshot("missing"); err == nil {
+		t.Error("restored missing snapshot")
+	}
+	if err := vm.restoreSnapshot("s1"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := ioutil.ReadFile(rootFilename); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(data, original) {
+		t.Error("root volume not restored")
+	}
+	if vm.Volumes[0].Size != uint64(len(original)) {
+		t.Errorf("volume size: %d != %d", vm.Volumes[0].Size, len(original))
+	}
+	if len(vm.Snapshots) != 0 {
+		t.Errorf("restored snapshot not consumed: %v", vm.Snapshots)
+	}
+	snapshotFilename := getSnapshotFilename(rootFilename, "s1")
+	if _, err := os.Stat(snapshotFilename); !os.IsNotExist(err) {
+		t.Errorf("snapshot file: %s not consumed", snapshotFilename)
+	}
+}
+
+func TestRemoveSnapshot(t *testing.T) {
+	_, vm, topDir := makeTestVm(t)
+	defer os.RemoveAll(topDir)
+	rootFilename := vm.VolumeLocations[0].Filename
+	now := time.Now()
+	for _, name := range []string{"s1", "s2"} {
+		if err := vm.takeSnapshot(name, true, 0, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for count := 0; count < 2; count++ { // Removing again is not an error.
+		if err := vm.removeSnapshot("s1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(vm.Snapshots) != 1 || vm.Snapshots[0].Name != "s2" {
+		t.Errorf("expected one snapshot: s2, got: %v", vm.Snapshots)
+	}
+	if _, err := os.Stat(getSnapshotFilename(rootFilename, "s1")); err == nil {
+		t.Error("s1 snapshot file not removed")
+	}
+	if _, err := os.Stat(getSnapshotFilename(rootFilename, "s2")); err != nil {
+		t.Errorf("s2 snapshot file removed: %s", err)
+	}
+}
@@ -31,6 +31,11 @@ const (
 )
 
 func newManager(startOptions StartOptions) (*Manager, error) {
+	for _, rule := range startOptions.SnapshotPolicyRules {
+		if err := checkSnapshotPolicy(&rule.Policy); err != nil {
+			return nil, err
+		}
+	}
 	memInfo, err := meminfo.GetMemInfo()
 	if err != nil {
 		return nil, err
@@ -51,6 +56,7 @@ func newManager(startOptions StartOptions) (*Manager, error) {
 		notifiers:         make(map[<-chan proto.Update]chan<- proto.Update),
 		numCPU:            runtime.NumCPU(),
 		serialNumber:      readProductSerial(),
+		totalVolumeBytes:  getTotalSpace(startOptions.VolumeDirectories),
 		vms:               make(map[string]*vmInfoType),
 		volumeDirectories: startOptions.VolumeDirectories,
 	}
@@ -60,6 +66,12 @@ func newManager(startOptions StartOptions) (*Manager, error) {
 	if err := manager.loadAddressPool(); err != nil {
 		return nil, err
 	}
+	if err := manager.setupFirewall(); err != nil {
+		return nil, err
+	}
+	if err := manager.loadQuotas(); err != nil {
+		return nil, err
+	}
 	dirname := filepath.Join(manager.StateDir, "VMs")
 	dir, err := os.Open(dirname)
 	if err != nil {
@@ -106,6 +118,9 @@ func newManager(startOptions StartOptions) (*Manager, error) {
 			}
 		}
 	}
+	if manager.EnableFirewall {
+		manager.refreshFirewalls(true) // Program VMs which kept running.
+	}
 	// Check address pool for used addresses with no VM.
 	freeIPs := make(map[string]struct{}, len(manager.addressPool.Free))
 	for _, addr := range manager.addressPool.Free {
@@ -160,6 +175,8 @@ func newManager(startOptions StartOptions) (*Manager, error) {
 		manager.objectCache = objSrv
 	}
 	go manager.loopCheckHealthStatus()
+	go manager.snapshotLoop()
+	go manager.usageLoop()
 	return manager, nil
 }
 
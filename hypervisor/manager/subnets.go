@@ -196,12 +196,18 @@ func (m *Manager) updateSubnets(request proto.UpdateSubnetsRequest) error {
 		if subnet.Id == "hypervisor" {
 			return fmt.Errorf("cannot add hypervisor subnet")
 		}
+		if err := subnet.CheckValid(); err != nil {
+			return err
+		}
 		request.Add[index].Shrink()
 	}
 	for index, subnet := range request.Change {
 		if subnet.Id == "hypervisor" {
 			return fmt.Errorf("cannot change hypervisor subnet")
 		}
+		if err := subnet.CheckValid(); err != nil {
+			return err
+		}
 		request.Change[index].Shrink()
 	}
 	for _, subnetId := range request.Delete {
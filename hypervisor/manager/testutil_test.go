@@ -0,0 +1,61 @@
+package manager
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+// makeTestVm returns a Manager with a stopped VM which has a root
+// volume in a temporary directory. The directory is returned for cleanup.
+func makeTestVm(t *testing.T) (*Manager, *vmInfoType, string) {
+	topDir, err := ioutil.TempDir("", "hypervisor.manager.test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	volumeDirectory := filepath.Join(topDir, "10.0.0.2")
+	if err := os.Mkdir(volumeDirectory, dirPerms); err != nil {
+		os.RemoveAll(topDir)
+		t.Fatal(err)
+	}
+	rootFilename := filepath.Join(volumeDirectory, "root")
+	err = ioutil.WriteFile(rootFilename, nil, privateFilePerms)
+	if err == nil {
+		err = setVolumeSize(rootFilename, 1<<20)
+	}
+	if err != nil {
+		os.RemoveAll(topDir)
+		t.Fatal(err)
+	}
+	m := &Manager{
+		StartOptions:      StartOptions{Logger: testlogger.New(t)},
+		memTotalInMiB:     4096,
+		numCPU:            2,
+		volumeDirectories: []string{topDir},
+	}
+	vm := &vmInfoType{
+		dirname:   volumeDirectory,
+		ipAddress: "10.0.0.2",
+		logger:    testlogger.New(t),
+		manager:   m,
+		LocalVmInfo: proto.LocalVmInfo{
+			VmInfo: proto.VmInfo{
+				MemoryInMiB: 1024,
+				MilliCPUs:   1000,
+				OwnerUsers:  []string{"alice"},
+				State:       proto.StateStopped,
+				Volumes:     []proto.Volume{{Size: 1 << 20}},
+			},
+			VolumeLocations: []proto.LocalVolume{{
+				DirectoryToCleanup: volumeDirectory,
+				Filename:           rootFilename,
+			}},
+		},
+	}
+	m.vms = map[string]*vmInfoType{vm.ipAddress: vm}
+	return m, vm, topDir
+}
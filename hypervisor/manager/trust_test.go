@@ -0,0 +1,57 @@
+package manager
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/image/trust"
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func TestCheckImageSourceTrusted(t *testing.T) {
+	tests := []struct {
+		name          string
+		imageName     string
+		imageDataSize uint64
+		imageURL      string
+		unverifiable  bool
+	}{
+		{"data", "", 1 << 20, "", true},
+		{"URL", "", 0, "http://host/image.raw", true},
+		{"name", "web/2019-01-01", 0, "", false},
+		{"name and data", "web", 1 << 20, "", false},
+		{"nothing", "", 0, "", false},
+	}
+	trusting := &Manager{}
+	enforcing := &Manager{StartOptions: StartOptions{
+		ImageTrustPolicy: &trust.Policy{}}}
+	for _, test := range tests {
+		err := trusting.checkImageSourceTrusted(test.imageName,
+			test.imageDataSize, test.imageURL)
+		if err != nil {
+			t.Errorf("%s: refused without a policy: %s", test.name, err)
+		}
+		err = enforcing.checkImageSourceTrusted(test.imageName,
+			test.imageDataSize, test.imageURL)
+		if test.unverifiable && err == nil {
+			t.Errorf("%s: unsigned image accepted with a policy", test.name)
+		} else if !test.unverifiable && err != nil {
+			// Named images are verified when they are fetched.
+			t.Errorf("%s: named image refused: %s", test.name, err)
+		}
+	}
+}
+
+func TestRestoreVmFromBackupTrusted(t *testing.T) {
+	m := &Manager{StartOptions: StartOptions{
+		ImageTrustPolicy: &trust.Policy{},
+		Logger:           testlogger.New(t),
+	}}
+	err := m.restoreVmFromBackup(&srpc.Conn{},
+		proto.RestoreVmFromBackupRequest{})
+	if err == nil || !strings.Contains(err.Error(), "trust policy") {
+		t.Errorf("backup restore not refused with a policy: %v", err)
+	}
+}
@@ -41,12 +41,19 @@ func (m *Manager) makeUpdateChannel() <-chan proto.Update {
 		AddressPool:      m.addressPool.Registered,
 		NumFreeAddresses: numFreeAddresses,
 		HealthStatus:     m.healthStatus,
+		Resources: &proto.Resources{
+			MemoryInMiB:      m.memTotalInMiB,
+			NumCPUs:          uint(m.numCPU),
+			TotalVolumeBytes: m.totalVolumeBytes,
+		},
 		HaveSerialNumber: true,
 		SerialNumber:     m.serialNumber,
 		HaveSubnets:      true,
 		Subnets:          subnets,
 		HaveVMs:          true,
 		VMs:              vms,
+		HaveVmUsage:      true,
+		VmUsage:          m.getAllVmUsage(),
 	}
 	return channel
 }
@@ -0,0 +1,308 @@
+package manager
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+	"github.com/Symantec/tricorder/go/tricorder"
+	"github.com/Symantec/tricorder/go/tricorder/units"
+)
+
+const (
+	clockTicksPerSecond       = 100
+	idleCpuPercent            = 5
+	idleNetworkBytesPerSecond = 1 << 10
+	usageSampleInterval       = time.Minute
+)
+
+type usageMetric struct {
+	name        string
+	unit        units.Unit
+	description string
+	getter      func(usage proto.VmUsage) uint64
+}
+
+var usageMetrics = []usageMetric{
+	{"disk-bytes-per-second", units.BytePerSecond, "volume I/O rate",
+		func(u proto.VmUsage) uint64 { return u.DiskBytesPerSecond }},
+	{"disk-read-bytes", units.Byte, "bytes read from volumes",
+		func(u proto.VmUsage) uint64 { return u.DiskReadBytes }},
+	{"disk-read-ops", units.None, "read operations on volumes",
+		func(u proto.VmUsage) uint64 { return u.DiskReadOps }},
+	{"disk-write-bytes", units.Byte, "bytes written to volumes",
+		func(u proto.VmUsage) uint64 { return u.DiskWriteBytes }},
+	{"disk-write-ops", units.None, "write operations on volumes",
+		func(u proto.VmUsage) uint64 { return u.DiskWriteOps }},
+	{"memory-used", units.Byte, "resident memory of the QEMU process",
+		func(u proto.VmUsage) uint64 { return u.MemoryUsedInMiB << 20 }},
+	{"milli-cpus-used", units.None, "CPU used over the last sample interval",
+		func(u proto.VmUsage) uint64 { return uint64(u.MilliCPUsUsed) }},
+	{"network-bytes-per-second", units.BytePerSecond, "network I/O rate",
+		func(u proto.VmUsage) uint64 { return u.NetworkBytesPerSecond }},
+	{"network-rx-bytes", units.Byte, "bytes received by the VM",
+		func(u proto.VmUsage) uint64 { return u.NetworkRxBytes }},
+	{"network-tx-bytes", units.Byte, "bytes transmitted by the VM",
+		func(u proto.VmUsage) uint64 { return u.NetworkTxBytes }},
+}
+
+// computeUsageRates fills in the rates and idle time of usage from the
+// previous sample. Counters which went backwards (the VM was restarted) yield
+// no rates.
+func computeUsageRates(usage, previous *proto.VmUsage, milliCPUs uint) {
+	if previous == nil {
+		return
+	}
+	interval := usage.SampleTime.Sub(previous.SampleTime)
+	if interval <= 0 || usage.CpuTime < previous.CpuTime ||
+		usage.DiskReadBytes < previous.DiskReadBytes ||
+		usage.DiskWriteBytes < previous.DiskWriteBytes ||
+		usage.NetworkRxBytes < previous.NetworkRxBytes ||
+		usage.NetworkTxBytes < previous.NetworkTxBytes {
+		return
+	}
+	usage.MilliCPUsUsed = uint((usage.CpuTime - previous.CpuTime) *
+		1000 / interval)
+	usage.DiskBytesPerSecond = uint64(float64(
+		usage.DiskReadBytes-previous.DiskReadBytes+
+			usage.DiskWriteBytes-previous.DiskWriteBytes) /
+		interval.Seconds())
+	usage.NetworkBytesPerSecond = uint64(float64(
+		usage.NetworkRxBytes-previous.NetworkRxBytes+
+			usage.NetworkTxBytes-previous.NetworkTxBytes) /
+		interval.Seconds())
+	if usage.MilliCPUsUsed*100 >= milliCPUs*idleCpuPercent ||
+		usage.NetworkBytesPerSecond >= idleNetworkBytesPerSecond {
+		return
+	}
+	if previous.IdleSince.IsZero() {
+		usage.IdleSince = previous.SampleTime
+	} else {
+		usage.IdleSince = previous.IdleSince
+	}
+}
+
+func readProcessCpuTime(pid int) (time.Duration, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	// The command name may contain spaces, so skip past it.
+	index := bytes.LastIndexByte(data, ')')
+	if index < 0 {
+		return 0, fmt.Errorf("malformed stat for process: %d", pid)
+	}
+	fields := strings.Fields(string(data[index+1:]))
+	if len(fields) < 13 {
+		return 0, fmt.Errorf("short stat for process: %d", pid)
+	}
+	var ticks uint64
+	for _, field := range fields[11:13] { // utime, stime.
+		value, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		ticks += value
+	}
+	return time.Duration(ticks) * time.Second / clockTicksPerSecond, nil
+}
+
+func readProcessMemoryInMiB(pid int) (uint64, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/statm", pid))
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("short statm for process: %d", pid)
+	}
+	residentPages, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return residentPages * uint64(os.Getpagesize()) >> 20, nil
+}
+
+// readThreadGroupId returns the process ID of a thread.
+func readThreadGroupId(threadId int) (int, error) {
+	data, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/status", threadId))
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 &&
+			fields[0] == "Tgid:" {
+			return strconv.Atoi(fields[1])
+		}
+	}
+	return 0, fmt.Errorf("no Tgid for thread: %d", threadId)
+}
+
+func readTapCounter(tapName, counter string) (uint64, error) {
+	data, err := ioutil.ReadFile(filepath.Join("/sys/class/net", tapName,
+		"statistics", counter))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
+
+func (m *Manager) getAllVmUsage() map[string]*proto.VmUsage {
+	m.usageMutex.Lock()
+	defer m.usageMutex.Unlock()
+	return m.vmUsage
+}
+
+func (m *Manager) getVmUsage(ipAddr string) proto.VmUsage {
+	m.usageMutex.Lock()
+	defer m.usageMutex.Unlock()
+	if usage := m.vmUsage[ipAddr]; usage != nil {
+		return *usage
+	}
+	return proto.VmUsage{}
+}
+
+func (m *Manager) registerUsageMetrics(ipAddr string) error {
+	dir, err := tricorder.RegisterDirectory("/vms/" + ipAddr)
+	if err != nil {
+		return err
+	}
+	err = dir.RegisterMetric("cpu-time",
+		func() time.Duration { return m.getVmUsage(ipAddr).CpuTime },
+		units.Second, "CPU time used by the QEMU process")
+	if err != nil {
+		return err
+	}
+	for _, metric := range usageMetrics {
+		getter := metric.getter
+		err := dir.RegisterMetric(metric.name,
+			func() uint64 { return getter(m.getVmUsage(ipAddr)) },
+			metric.unit, metric.description)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sampleUsage will sample the usage of all running VMs, update the metrics
+// and send the summaries to the update channels.
+func (m *Manager) sampleUsage() {
+	vms := m.getVMs()
+	m.usageMutex.Lock()
+	previousUsage := m.vmUsage
+	m.usageMutex.Unlock()
+	usage := make(map[string]*proto.VmUsage, len(vms))
+	for _, vm := range vms {
+		vmUsage, err := vm.sampleUsage(previousUsage[vm.ipAddress])
+		if err != nil {
+			vm.logger.Debugf(1, "error sampling usage: %s\n", err)
+		} else if vmUsage != nil {
+			usage[vm.ipAddress] = vmUsage
+		}
+	}
+	m.usageMutex.Lock()
+	m.vmUsage = usage
+	m.usageMutex.Unlock()
+	for ipAddr := range previousUsage {
+		if _, ok := usage[ipAddr]; !ok {
+			tricorder.UnregisterPath("/vms/" + ipAddr)
+		}
+	}
+	for ipAddr := range usage {
+		if _, ok := previousUsage[ipAddr]; !ok {
+			if err := m.registerUsageMetrics(ipAddr); err != nil {
+				m.Logger.Printf("error registering metrics for: %s: %s\n",
+					ipAddr, err)
+			}
+		}
+	}
+	m.sendUpdate(proto.Update{HaveVmUsage: true, VmUsage: usage})
+}
+
+func (m *Manager) usageLoop() {
+	for ; ; time.Sleep(usageSampleInterval) {
+		m.sampleUsage()
+	}
+}
+
+// getQemuPid asks QEMU for the ID of a vCPU thread and returns the process ID
+// it belongs to. The VM lock must be held.
+func (vm *vmInfoType) getQemuPid() (int, error) {
+	var cpus []struct {
+		ThreadId    int `json:"thread-id"`
+		OldThreadId int `json:"thread_id"` // From query-cpus.
+	}
+	if err := vm.qmpCommand("query-cpus-fast", nil, &cpus); err != nil {
+		if err := vm.qmpCommand("query-cpus", nil, &cpus); err != nil {
+			return 0, err
+		}
+	}
+	if len(cpus) < 1 {
+		return 0, errors.New("no vCPU threads")
+	}
+	if cpus[0].ThreadId < 1 {
+		return readThreadGroupId(cpus[0].OldThreadId)
+	}
+	return readThreadGroupId(cpus[0].ThreadId)
+}
+
+// sampleUsage returns the usage of the VM, or nil if it is not running.
+func (vm *vmInfoType) sampleUsage(previous *proto.VmUsage) (
+	*proto.VmUsage, error) {
+	vm.mutex.RLock()
+	defer vm.mutex.RUnlock()
+	if vm.State != proto.StateRunning {
+		return nil, nil
+	}
+	pid, err := vm.getQemuPid()
+	if err != nil {
+		return nil, err
+	}
+	usage := &proto.VmUsage{SampleTime: time.Now()}
+	if usage.CpuTime, err = readProcessCpuTime(pid); err != nil {
+		return nil, err
+	}
+	if usage.MemoryUsedInMiB, err = readProcessMemoryInMiB(pid); err != nil {
+		return nil, err
+	}
+	var blockStats []struct {
+		Stats struct {
+			ReadBytes  uint64 `json:"rd_bytes"`
+			ReadOps    uint64 `json:"rd_operations"`
+			WriteBytes uint64 `json:"wr_bytes"`
+			WriteOps   uint64 `json:"wr_operations"`
+		} `json:"stats"`
+	}
+	if err := vm.qmpCommand("query-blockstats", nil, &blockStats); err != nil {
+		return nil, err
+	}
+	for _, device := range blockStats {
+		usage.DiskReadBytes += device.Stats.ReadBytes
+		usage.DiskReadOps += device.Stats.ReadOps
+		usage.DiskWriteBytes += device.Stats.WriteBytes
+		usage.DiskWriteOps += device.Stats.WriteOps
+	}
+	// Traffic received on a tap device was transmitted by the VM.
+	for _, tapName := range vm.TapDevices {
+		if count, err := readTapCounter(tapName, "tx_bytes"); err != nil {
+			return nil, err
+		} else {
+			usage.NetworkRxBytes += count
+		}
+		if count, err := readTapCounter(tapName, "rx_bytes"); err != nil {
+			return nil, err
+		} else {
+			usage.NetworkTxBytes += count
+		}
+	}
+	computeUsageRates(usage, previous, vm.MilliCPUs)
+	return usage, nil
+}
@@ -0,0 +1,62 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func TestComputeUsageRates(t *testing.T) {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	previous := &proto.VmUsage{
+		CpuTime:        time.Second,
+		DiskReadBytes:  1000,
+		NetworkRxBytes: 1000,
+		SampleTime:     start,
+	}
+	usage := &proto.VmUsage{
+		CpuTime:        time.Second * 31,
+		DiskReadBytes:  7000,
+		DiskWriteBytes: 6000,
+		NetworkRxBytes: 7000,
+		NetworkTxBytes: 6000,
+		SampleTime:     start.Add(time.Minute),
+	}
+	computeUsageRates(usage, previous, 2000)
+	if usage.MilliCPUsUsed != 500 {
+		t.Errorf("expected 500 MilliCPUsUsed, got: %d", usage.MilliCPUsUsed)
+	}
+	if usage.DiskBytesPerSecond != 200 {
+		t.Errorf("expected 200 DiskBytesPerSecond, got: %d",
+			usage.DiskBytesPerSecond)
+	}
+	if usage.NetworkBytesPerSecond != 200 {
+		t.Errorf("expected 200 NetworkBytesPerSecond, got: %d",
+			usage.NetworkBytesPerSecond)
+	}
+	if !usage.IdleSince.IsZero() {
+		t.Error("busy VM is idle")
+	}
+	idleUsage := &proto.VmUsage{
+		CpuTime:        time.Second * 32,
+		DiskReadBytes:  7000,
+		DiskWriteBytes: 6000,
+		NetworkRxBytes: 7000,
+		NetworkTxBytes: 6000,
+		SampleTime:     start.Add(time.Minute * 2),
+	}
+	computeUsageRates(idleUsage, usage, 2000)
+	if idleUsage.IdleSince != usage.SampleTime {
+		t.Errorf("expected IdleSince: %s, got: %s",
+			usage.SampleTime, idleUsage.IdleSince)
+	}
+	restartedUsage := &proto.VmUsage{
+		CpuTime:    time.Second,
+		SampleTime: start.Add(time.Minute * 3),
+	}
+	computeUsageRates(restartedUsage, idleUsage, 2000)
+	if restartedUsage.MilliCPUsUsed != 0 || !restartedUsage.IdleSince.IsZero() {
+		t.Errorf("restarted VM has rates: %+v", restartedUsage)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
@@ -45,12 +46,15 @@ import (
 )
 
 const (
-	bootlogFilename    = "bootlog"
-	serialSockFilename = "serial0.sock"
+	bootlogFilename                 = "bootlog"
+	minimumLiveMigrationQemuVersion = "3.0.0" // For query-jobs.
+	serialSockFilename              = "serial0.sock"
 )
 
 var (
 	errorNoAccessToResource = errors.New("no access to resource")
+	errorVmSizeHotPlug      = errors.New(
+		"CPU and memory hot-plug is not supported: stop the VM to resize it")
 )
 
 func computeSize(minimumFreeBytes, roundupPower, size uint64) uint64 {
@@ -82,10 +86,10 @@ func copyData(filename string, reader io.Reader, length uint64,
 	return err
 }
 
-func createTapDevice(bridge string) (*os.File, error) {
+func createTapDevice(bridge string) (*os.File, string, error) {
 	tapFile, tapName, err := libnet.CreateTapDevice()
 	if err != nil {
-		return nil, fmt.Errorf("error creating tap device: %s", err)
+		return nil, "", fmt.Errorf("error creating tap device: %s", err)
 	}
 	doAutoClose := true
 	defer func() {
@@ -95,14 +99,14 @@ func createTapDevice(bridge string) (*os.File, error) {
 	}()
 	cmd := exec.Command("ip", "link", "set", tapName, "up")
 	if output, err := cmd.CombinedOutput(); err != nil {
-		return nil, fmt.Errorf("error upping: %s: %s", err, output)
+		return nil, "", fmt.Errorf("error upping: %s: %s", err, output)
 	}
 	cmd = exec.Command("ip", "link", "set", tapName, "master", bridge)
 	if output, err := cmd.CombinedOutput(); err != nil {
-		return nil, fmt.Errorf("error attaching: %s: %s", err, output)
+		return nil, "", fmt.Errorf("error attaching: %s: %s", err, output)
 	}
 	doAutoClose = false
-	return tapFile, nil
+	return tapFile, tapName, nil
 }
 
 func extractKernel(volume proto.LocalVolume, extension string,
@@ -150,6 +154,26 @@ func maybeDrainAll(conn *srpc.Conn, request proto.CreateVmRequest) error {
 	return nil
 }
 
+// checkImageSourceTrusted returns an error if an image trust policy is
+// configured and the image is not specified by name (images specified by name
+// are verified when they are fetched). Streamed image data and images fetched
+// from a URL carry no signature which could be verified.
+func (m *Manager) checkImageSourceTrusted(imageName string,
+	imageDataSize uint64, imageURL string) error {
+	if m.ImageTrustPolicy == nil || imageName != "" {
+		return nil
+	}
+	if imageDataSize > 0 {
+		return errors.New(
+			"image data cannot be verified: an image trust policy is set")
+	}
+	if imageURL != "" {
+		return errors.New(
+			"image URLs cannot be verified: an image trust policy is set")
+	}
+	return nil
+}
+
 func maybeDrainImage(imageReader io.Reader, imageDataSize uint64) error {
 	if imageDataSize > 0 { // Drain data.
 		_, err := io.CopyN(ioutil.Discard, imageReader, int64(imageDataSize))
@@ -210,11 +234,73 @@ func (m *Manager) acknowledgeVm(ipAddr net.IP,
 	return nil
 }
 
+func (m *Manager) addVmVolume(ipAddr net.IP, authInfo *srpc.AuthInformation,
+	accessToken []byte, size uint64) error {
+	if size < 1 {
+		return errors.New("no volume size specified")
+	}
+	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, accessToken)
+	if err != nil {
+		return err
+	}
+	defer vm.mutex.Unlock()
+	if vm.State != proto.StateStopped {
+		return errors.New("VM is not stopped")
+	}
+	m.mutex.RLock()
+	err = m.checkQuotasWithLock(vm.OwnerUsers[0], vm.OwnerGroups, nil,
+		proto.Quota{VolumeBytes: size})
+	m.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+	freeSpaceTable := make(map[string]uint64, len(m.volumeDirectories))
+	position := 0
+	dirname, err := m.findFreeSpace(size, freeSpaceTable, &position)
+	if err != nil {
+		return err
+	}
+	volumeDirectory := filepath.Join(dirname, vm.ipAddress)
+	if err := os.MkdirAll(volumeDirectory, dirPerms); err != nil {
+		return err
+	}
+	filename := vm.makeSecondaryVolumeFilename(volumeDirectory)
+	cFlags := os.O_CREATE | os.O_EXCL | os.O_RDWR
+	file, err := os.OpenFile(filename, cFlags, privateFilePerms)
+	if err != nil {
+		return err
+	}
+	file.Close()
+	if err := setVolumeSize(filename, size); err != nil {
+		os.Remove(filename)
+		return err
+	}
+	volumeLocations := make([]proto.LocalVolume, 0, len(vm.VolumeLocations)+1)
+	volumeLocations = append(volumeLocations, vm.VolumeLocations...)
+	vm.VolumeLocations = append(volumeLocations, proto.LocalVolume{
+		DirectoryToCleanup: volumeDirectory,
+		Filename:           filename,
+	})
+	volumes := make([]proto.Volume, 0, len(vm.Volumes)+1)
+	volumes = append(volumes, vm.Volumes...)
+	vm.Volumes = append(volumes, proto.Volume{Size: size})
+	vm.writeAndSendInfo()
+	return nil
+}
+
 func (m *Manager) allocateVm(req proto.CreateVmRequest,
 	authInfo *srpc.AuthInformation) (*vmInfoType, error) {
 	if err := req.ConsoleType.CheckValid(); err != nil {
 		return nil, err
 	}
+	if err := m.checkFirewallPolicy(req.Firewall); err != nil {
+		return nil, err
+	}
+	if req.ConfigDrive {
+		if _, err := findIsoCommand(); err != nil {
+			return nil, err
+		}
+	}
 	if req.MemoryInMiB < 1 {
 		return nil, errors.New("no memory specified")
 	}
@@ -269,6 +355,21 @@ func (m *Manager) allocateVm(req proto.CreateVmRequest,
 	if err := m.checkSufficientMemoryWithLock(req.MemoryInMiB); err != nil {
 		return nil, err
 	}
+	quota := proto.Quota{
+		MemoryInMiB: req.MemoryInMiB,
+		MilliCPUs:   uint64(req.MilliCPUs),
+		NumVMs:      1,
+	}
+	for _, volume := range req.Volumes {
+		quota.VolumeBytes += volume.Size
+	}
+	for _, volume := range req.SecondaryVolumes {
+		quota.VolumeBytes += volume.Size
+	}
+	err = m.checkQuotasWithLock(authInfo.Username, req.OwnerGroups, nil, quota)
+	if err != nil {
+		return nil, err
+	}
 	var ipAddress string
 	if len(address.IpAddress) < 1 {
 		ipAddress = "0.0.0.0"
@@ -279,16 +380,20 @@ func (m *Manager) allocateVm(req proto.CreateVmRequest,
 		LocalVmInfo: proto.LocalVmInfo{
 			VmInfo: proto.VmInfo{
 				Address:            address,
+				ConfigDrive:        req.ConfigDrive,
 				ConsoleType:        req.ConsoleType,
 				DestroyProtection:  req.DestroyProtection,
 				DisableVirtIO:      req.DisableVirtIO,
+				Firewall:           req.Firewall,
 				Hostname:           req.Hostname,
 				ImageName:          req.ImageName,
 				ImageURL:           req.ImageURL,
+				Limits:             req.Limits,
 				MemoryInMiB:        req.MemoryInMiB,
 				MilliCPUs:          req.MilliCPUs,
 				OwnerGroups:        req.OwnerGroups,
 				SpreadVolumes:      req.SpreadVolumes,
+				SshPublicKeys:      req.SshPublicKeys,
 				SecondaryAddresses: secondaryAddresses,
 				SecondarySubnetIDs: req.SecondarySubnetIDs,
 				State:              proto.StateStarting,
@@ -384,6 +489,53 @@ func (m *Manager) changeVmOwnerUsers(ipAddr net.IP,
 	return nil
 }
 
+func (m *Manager) changeVmSize(ipAddr net.IP, authInfo *srpc.AuthInformation,
+	accessToken []byte, memoryInMiB uint64, milliCPUs uint) error {
+	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, accessToken)
+	if err != nil {
+		return err
+	}
+	defer vm.mutex.Unlock()
+	if memoryInMiB < 1 {
+		memoryInMiB = vm.MemoryInMiB
+	}
+	if milliCPUs < 1 {
+		milliCPUs = vm.MilliCPUs
+	}
+	if memoryInMiB == vm.MemoryInMiB && milliCPUs == vm.MilliCPUs {
+		return nil
+	}
+	if vm.State != proto.StateStopped {
+		return errorVmSizeHotPlug
+	}
+	m.mutex.RLock()
+	if milliCPUs > vm.MilliCPUs {
+		err = m.checkSufficientCPUWithLock(milliCPUs - vm.MilliCPUs)
+	}
+	if err == nil && memoryInMiB > vm.MemoryInMiB {
+		err = m.checkSufficientMemoryWithLock(memoryInMiB - vm.MemoryInMiB)
+	}
+	if err == nil {
+		var quota proto.Quota
+		if milliCPUs > vm.MilliCPUs {
+			quota.MilliCPUs = uint64(milliCPUs - vm.MilliCPUs)
+		}
+		if memoryInMiB > vm.MemoryInMiB {
+			quota.MemoryInMiB = memoryInMiB - vm.MemoryInMiB
+		}
+		err = m.checkQuotasWithLock(vm.OwnerUsers[0], vm.OwnerGroups, nil,
+			quota)
+	}
+	m.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+	vm.MemoryInMiB = memoryInMiB
+	vm.MilliCPUs = milliCPUs
+	vm.writeAndSendInfo()
+	return nil
+}
+
 func (m *Manager) changeVmTags(ipAddr net.IP, authInfo *srpc.AuthInformation,
 	tgs tags.Tags) error {
 	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, nil)
@@ -504,7 +656,7 @@ func (m *Manager) copyVm(conn *srpc.Conn, request proto.CopyVmRequest) error {
 		hypervisor.RequestReply("Hypervisor.DiscardVmAccessToken",
 			req, &reply)
 	}()
-	getInfoRequest := proto.GetVmInfoRequest{request.IpAddress}
+	getInfoRequest := proto.GetVmInfoRequest{IpAddress: request.IpAddress}
 	var getInfoReply proto.GetVmInfoResponse
 	err = hypervisor.RequestReply("Hypervisor.GetVmInfo", getInfoRequest,
 		&getInfoReply)
@@ -634,13 +786,40 @@ func (m *Manager) createVm(conn *srpc.Conn) error {
 	if err := conn.Decode(&request); err != nil {
 		return err
 	}
+	authInfo := conn.GetAuthInformation()
+	if request.OnBehalfOfUser != "" {
+		// A trusted proxy (such as the Fleet Manager) is creating the VM.
+		if !authInfo.HaveMethodAccess {
+			if err := maybeDrainAll(conn, request); err != nil {
+				return err
+			}
+			return sendError(conn, errors.New(
+				"no permission to create VM on behalf of another user"))
+		}
+		authInfo = &srpc.AuthInformation{
+			GroupList: make(map[string]struct{},
+				len(request.OnBehalfOfGroups)),
+			Username: request.OnBehalfOfUser,
+		}
+		for _, group := range request.OnBehalfOfGroups {
+			authInfo.GroupList[group] = struct{}{}
+		}
+	}
 	ownerUsers := make([]string, 1, len(request.OwnerUsers)+1)
-	ownerUsers[0] = conn.Username()
+	ownerUsers[0] = authInfo.Username
 	if ownerUsers[0] == "" {
 		return sendError(conn, errors.New("no authentication data"))
 	}
 	ownerUsers = append(ownerUsers, request.OwnerUsers...)
-	vm, err := m.allocateVm(request, conn.GetAuthInformation())
+	err := m.checkImageSourceTrusted(request.ImageName, request.ImageDataSize,
+		request.ImageURL)
+	if err != nil {
+		if err := maybeDrainAll(conn, request); err != nil {
+			return err
+		}
+		return sendError(conn, err)
+	}
+	vm, err := m.allocateVm(request, authInfo)
 	if err != nil {
 		if err := maybeDrainAll(conn, request); err != nil {
 			return err
@@ -760,6 +939,10 @@ func (m *Manager) createVm(conn *srpc.Conn) error {
 			vm.Volumes = append(vm.Volumes, volume)
 		}
 	}
+	vm.updateQuotaInfo()
+	if err := m.checkVmQuotas(vm); err != nil {
+		return sendError(conn, err)
+	}
 	if len(memoryError) < 1 {
 		msg := "waiting for test memory allocation"
 		sendUpdate(conn, msg)
@@ -841,9 +1024,10 @@ func (m *Manager) destroyVm(ipAddr net.IP, authInfo *srpc.AuthInformation,
 		vm.commandChannel <- "quit"
 	case proto.StateStopping:
 		return errors.New("VM is stopping")
-	case proto.StateStopped, proto.StateFailedToStart, proto.StateMigrating,
-		proto.StateExporting:
+	case proto.StateStopped, proto.StateFailedToStart, proto.StateExporting:
 		vm.delete()
+	case proto.StateMigrating:
+		vm.destroy() // QEMU is still running after a live migration.
 	case proto.StateDestroying:
 		return errors.New("VM is already destroying")
 	default:
@@ -887,13 +1071,16 @@ func (m *Manager) discardVmOldUserData(ipAddr net.IP,
 }
 
 func (m *Manager) discardVmSnapshot(ipAddr net.IP,
-	authInfo *srpc.AuthInformation) error {
+	authInfo *srpc.AuthInformation, name string) error {
+	if err := checkSnapshotName(name); err != nil {
+		return err
+	}
 	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, nil)
 	if err != nil {
 		return err
 	}
 	defer vm.mutex.Unlock()
-	return vm.discardSnapshot()
+	return vm.removeSnapshot(name)
 }
 
 func (m *Manager) exportLocalVm(authInfo *srpc.AuthInformation,
@@ -950,6 +1137,10 @@ func (m *Manager) getImage(searchName string, imageTimeout time.Duration) (
 		if err != nil {
 			return nil, nil, "", err
 		}
+		if err := m.ImageTrustPolicy.Verify(img); err != nil {
+			return nil, nil, "", fmt.Errorf("untrusted image: %s: %s",
+				imageName, err)
+		}
 		img.FileSystem.RebuildInodePointers()
 		doClose = false
 		return client, img, imageName, nil
@@ -961,6 +1152,10 @@ func (m *Manager) getImage(searchName string, imageTimeout time.Duration) (
 	if img == nil {
 		return nil, nil, "", errors.New("timeout getting image")
 	}
+	if err := m.ImageTrustPolicy.Verify(img); err != nil {
+		return nil, nil, "", fmt.Errorf("untrusted image: %s: %s",
+			searchName, err)
+	}
 	if err := img.FileSystem.RebuildInodePointers(); err != nil {
 		return nil, nil, "", err
 	}
@@ -1126,6 +1321,69 @@ func (m *Manager) getVmVolume(conn *srpc.Conn) error {
 		vm.Volumes[request.VolumeIndex].Size)
 }
 
+func (m *Manager) growVmVolume(ipAddr net.IP, authInfo *srpc.AuthInformation,
+	accessToken []byte, volumeIndex uint, size uint64) error {
+	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, accessToken)
+	if err != nil {
+		return err
+	}
+	defer vm.mutex.Unlock()
+	if volumeIndex >= uint(len(vm.VolumeLocations)) ||
+		volumeIndex >= uint(len(vm.Volumes)) {
+		return errors.New("volume index too large")
+	}
+	volume := vm.Volumes[volumeIndex]
+	if volume.Format != proto.VolumeFormatRaw {
+		return fmt.Errorf("cannot grow %s volume", volume.Format)
+	}
+	if size <= volume.Size {
+		return fmt.Errorf("new size: %s is not larger than current size: %s",
+			format.FormatBytes(size), format.FormatBytes(volume.Size))
+	}
+	switch vm.State {
+	case proto.StateStopped:
+	case proto.StateRunning:
+	default:
+		return errors.New("VM is not running or stopped")
+	}
+	m.mutex.RLock()
+	err = m.checkQuotasWithLock(vm.OwnerUsers[0], vm.OwnerGroups, nil,
+		proto.Quota{VolumeBytes: size - volume.Size})
+	m.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+	filename := vm.VolumeLocations[volumeIndex].Filename
+	freeSpace, err := getFreeSpace(filepath.Dir(filename),
+		make(map[string]uint64, 1))
+	if err != nil {
+		return err
+	}
+	if size-volume.Size >= freeSpace {
+		return fmt.Errorf("not enough free space to grow volume by %s",
+			format.FormatBytes(size-volume.Size))
+	}
+	if err := setVolumeSize(filename, size); err != nil {
+		return err
+	}
+	volumes := make([]proto.Volume, len(vm.Volumes))
+	copy(volumes, vm.Volumes)
+	volumes[volumeIndex].Size = size
+	vm.Volumes = volumes
+	vm.writeAndSendInfo()
+	if vm.State == proto.StateRunning {
+		// Tell QEMU (and thus the guest) about the new size.
+		err := vm.qmpCommand("block_resize", map[string]interface{}{
+			"device": vm.getVolumeDeviceName(volumeIndex),
+			"size":   size,
+		}, nil)
+		if err != nil {
+			return fmt.Errorf("volume grown but VM not notified: %s", err)
+		}
+	}
+	return nil
+}
+
 func (m *Manager) importLocalVm(authInfo *srpc.AuthInformation,
 	request proto.ImportLocalVmRequest) error {
 	requestedIpAddrs := make(map[string]struct{},
@@ -1252,7 +1510,9 @@ func (m *Manager) importLocalVm(authInfo *srpc.AuthInformation,
 			return err
 		}
 		vm.VolumeLocations = append(vm.VolumeLocations, proto.LocalVolume{
-			dirname, destFilename})
+			DirectoryToCleanup: dirname,
+			Filename:           destFilename,
+		})
 	}
 	m.vms[ipAddress] = vm
 	if _, err := vm.startManaging(0, true); err != nil {
@@ -1316,7 +1576,7 @@ func (m *Manager) migrateVm(conn *srpc.Conn) error {
 	if subnetId == "" {
 		return fmt.Errorf("no matching subnet for: %s\n", request.IpAddress)
 	}
-	getInfoRequest := proto.GetVmInfoRequest{request.IpAddress}
+	getInfoRequest := proto.GetVmInfoRequest{IpAddress: request.IpAddress}
 	var getInfoReply proto.GetVmInfoResponse
 	err = hypervisor.RequestReply("Hypervisor.GetVmInfo", getInfoRequest,
 		&getInfoReply)
@@ -1336,6 +1596,23 @@ func (m *Manager) migrateVm(conn *srpc.Conn) error {
 	if err := m.migrateVmChecks(vmInfo); err != nil {
 		return err
 	}
+	live := false
+	if request.Live && vmInfo.State == proto.StateRunning {
+		err := checkLiveMigrationVolumes(vmInfo.Volumes)
+		if err == nil {
+			err = checkLiveMigration(hypervisor, request.IpAddress,
+				accessToken)
+		}
+		if err != nil {
+			err = sendVmMigrationMessage(conn, fmt.Sprintf(
+				"cannot live migrate: %s, falling back to stopping VM", err))
+			if err != nil {
+				return err
+			}
+		} else {
+			live = true
+		}
+	}
 	volumeDirectories, err := m.getVolumeDirectories(vmInfo.Volumes[0].Size,
 		vmInfo.Volumes[1:], vmInfo.SpreadVolumes)
 	if err != nil {
@@ -1355,11 +1632,17 @@ func (m *Manager) migrateVm(conn *srpc.Conn) error {
 		metadataChannels: make(map[chan<- string]struct{}),
 	}
 	vm.Uncommitted = true
+	var sourcePausedTime time.Time
 	defer func() { // Evaluate vm at return time, not defer time.
 		if vm == nil {
 			return
 		}
 		vm.cleanup()
+		if !sourcePausedTime.IsZero() {
+			hyperclient.ResumeMigratingVm(hypervisor, request.IpAddress,
+				accessToken)
+			return
+		}
 		hyperclient.PrepareVmForMigration(hypervisor, request.IpAddress,
 			accessToken, false)
 		if vmInfo.State == proto.StateRunning {
@@ -1397,16 +1680,30 @@ func (m *Manager) migrateVm(conn *srpc.Conn) error {
 			return err
 		}
 	}
-	// Begin copying over the volumes.
-	err = sendVmMigrationMessage(conn, "initial volume(s) copy")
-	if err != nil {
-		return err
-	}
-	err = vm.migrateVmVolumes(hypervisor, vm.Address.IpAddress, accessToken)
-	if err != nil {
-		return err
+	if live {
+		// The volumes are mirrored by the source.
+		if err := vm.createMigrationVolumes(); err != nil {
+			return err
+		}
+	} else {
+		// Begin copying over the volumes.
+		err = sendVmMigrationMessage(conn, "initial volume(s) copy")
+		if err != nil {
+			return err
+		}
+		err = vm.migrateVmVolumes(hypervisor, vm.Address.IpAddress,
+			accessToken)
+		if err != nil {
+			return err
+		}
 	}
-	if vmInfo.State != proto.StateStopped {
+	if live {
+		sourcePausedTime, err = vm.migrateVmLive(conn, hypervisor,
+			request.SourceHypervisor, accessToken)
+		if err != nil {
+			return err
+		}
+	} else if vmInfo.State != proto.StateStopped {
 		err = sendVmMigrationMessage(conn, "stopping VM")
 		if err != nil {
 			return err
@@ -1436,21 +1733,74 @@ func (m *Manager) migrateVm(conn *srpc.Conn) error {
 	if err != nil {
 		return err
 	}
-	if err := sendVmMigrationMessage(conn, "starting VM"); err != nil {
-		return err
+	if live {
+		// Commit while the VM is paused on both sides: once it is resumed
+		// here, the source must never be resumed.
+		if err := requestVmMigrationCommit(conn); err != nil {
+			return err
+		}
+		if err := sendVmMigrationMessage(conn, "resuming VM"); err != nil {
+			return err
+		}
+		vm.mutex.RLock()
+		err = vm.qmpCommand("cont", nil, nil)
+		vm.mutex.RUnlock()
+		if err != nil {
+			return err
+		}
+		err = sendVmMigrationMessage(conn, fmt.Sprintf("VM was paused for %s",
+			format.Duration(time.Since(sourcePausedTime))))
+		if err != nil {
+			vm.logger.Println(err)
+		}
+		for _, address := range append([]proto.Address{vm.Address},
+			vm.SecondaryAddresses...) {
+			if err := m.registerAddress(address); err != nil {
+				vm.logger.Println(err)
+			}
+		}
+	} else {
+		if err := sendVmMigrationMessage(conn, "starting VM"); err != nil {
+			return err
+		}
+		vm.State = proto.StateStarting
+		m.mutex.Lock()
+		m.vms[ipAddress] = vm
+		m.mutex.Unlock()
+		dhcpTimedOut, err := vm.startManaging(request.DhcpTimeout, false)
+		if err != nil {
+			return err
+		}
+		if dhcpTimedOut {
+			return fmt.Errorf("DHCP timed out")
+		}
+		if err := requestVmMigrationCommit(conn); err != nil {
+			return err
+		}
+		if err := m.registerAddress(vm.Address); err != nil {
+			return err
+		}
+		for _, address := range vm.SecondaryAddresses {
+			if err := m.registerAddress(address); err != nil {
+				return err
+			}
+		}
 	}
-	vm.State = proto.StateStarting
-	m.mutex.Lock()
-	m.vms[ipAddress] = vm
-	m.mutex.Unlock()
-	dhcpTimedOut, err := vm.startManaging(request.DhcpTimeout, false)
+	vm.doNotWriteOrSend = false
+	vm.Uncommitted = false
+	vm.writeAndSendInfo()
+	err = hyperclient.DestroyVm(hypervisor, request.IpAddress, accessToken)
 	if err != nil {
-		return err
-	}
-	if dhcpTimedOut {
-		return fmt.Errorf("DHCP timed out")
+		m.Logger.Printf("error cleaning up old migrated VM: %s\n", ipAddress)
 	}
-	err = conn.Encode(proto.MigrateVmResponse{RequestCommit: true})
+	vm = nil // Cancel cleanup.
+	return nil
+}
+
+// requestVmMigrationCommit will ask the client to commit the migration and
+// returns an error if it does not.
+func requestVmMigrationCommit(conn *srpc.Conn) error {
+	err := conn.Encode(proto.MigrateVmResponse{RequestCommit: true})
 	if err != nil {
 		return err
 	}
@@ -1464,22 +1814,6 @@ func (m *Manager) migrateVm(conn *srpc.Conn) error {
 	if !reply.Commit {
 		return fmt.Errorf("VM migration abandoned")
 	}
-	if err := m.registerAddress(vm.Address); err != nil {
-		return err
-	}
-	for _, address := range vm.SecondaryAddresses {
-		if err := m.registerAddress(address); err != nil {
-			return err
-		}
-	}
-	vm.doNotWriteOrSend = false
-	vm.Uncommitted = false
-	vm.writeAndSendInfo()
-	err = hyperclient.DestroyVm(hypervisor, request.IpAddress, accessToken)
-	if err != nil {
-		m.Logger.Printf("error cleaning up old migrated VM: %s\n", ipAddress)
-	}
-	vm = nil // Cancel cleanup.
 	return nil
 }
 
@@ -1499,6 +1833,14 @@ func sendVmMigrationMessage(conn *srpc.Conn, message string) error {
 	return conn.Flush()
 }
 
+func sendVmMigrateStateMessage(conn *srpc.Conn, message string) error {
+	request := proto.MigrateVmStateResponse{ProgressMessage: message}
+	if err := conn.Encode(request); err != nil {
+		return err
+	}
+	return conn.Flush()
+}
+
 func sendVmPatchImageMessage(conn *srpc.Conn, message string) error {
 	request := proto.PatchVmImageResponse{ProgressMessage: message}
 	if err := conn.Encode(request); err != nil {
@@ -1538,6 +1880,332 @@ func (m *Manager) migrateVmChecks(vmInfo proto.VmInfo) error {
 	return nil
 }
 
+func (m *Manager) migrateVmState(conn *srpc.Conn) (string, error) {
+	var request proto.MigrateVmStateRequest
+	if err := conn.Decode(&request); err != nil {
+		return "", err
+	}
+	authInfo := *conn.GetAuthInformation()
+	authInfo.HaveMethodAccess = false // Require VM ownership or token.
+	if request.Probe {
+		vm, err := m.getVmLockAndAuth(request.IpAddress, false, &authInfo,
+			request.AccessToken)
+		if err != nil {
+			return "", err
+		}
+		defer vm.mutex.RUnlock()
+		if vm.Uncommitted {
+			return "", errors.New("VM is uncommitted")
+		}
+		if vm.State != proto.StateRunning {
+			return "", errors.New("VM is not running")
+		}
+		if err := checkLiveMigrationVolumes(vm.Volumes); err != nil {
+			return "", err
+		}
+		var version qmpVersionInfo
+		if err := vm.qmpCommand("query-version", nil, &version); err != nil {
+			return "", err
+		}
+		return version.String(), nil
+	}
+	vm, err := m.getVmLockAndAuth(request.IpAddress, true, &authInfo,
+		request.AccessToken)
+	if err != nil {
+		return "", err
+	}
+	if request.Resume {
+		defer vm.mutex.Unlock()
+		return "", vm.resumeMigratingVm()
+	}
+	// Only addresses are accepted, so that QEMU is only ever asked to migrate
+	// to URIs built here.
+	migrationUri, err := makeMigrationUri(request.DestinationAddress)
+	if err != nil {
+		vm.mutex.Unlock()
+		return "", err
+	}
+	nbdAddress, err := checkMigrationAddress(request.NbdAddress)
+	if err != nil {
+		vm.mutex.Unlock()
+		return "", err
+	}
+	if err := vm.checkCanSendState(); err != nil {
+		vm.mutex.Unlock()
+		return "", err
+	}
+	numVolumes := len(vm.VolumeLocations)
+	if err := vm.startVolumeMirrors(nbdAddress); err != nil {
+		vm.mutex.Unlock()
+		return "", err
+	}
+	vm.mutex.Unlock()
+	progressFunc := func(message string) error {
+		return sendVmMigrateStateMessage(conn, message)
+	}
+	if err := progressFunc("mirroring volume(s)"); err != nil {
+		vm.mutex.RLock()
+		vm.cancelVolumeMirrors(numVolumes)
+		vm.mutex.RUnlock()
+		return "", err
+	}
+	err = vm.waitForVolumeMirrors(numVolumes, progressFunc)
+	if err == nil {
+		err = progressFunc("volume(s) synchronised, copying memory")
+	}
+	if err == nil {
+		vm.mutex.Lock()
+		err = vm.sendVmState(migrationUri)
+		vm.mutex.Unlock()
+	}
+	if err != nil {
+		vm.mutex.RLock()
+		vm.cancelVolumeMirrors(numVolumes)
+		vm.mutex.RUnlock()
+		return "", err
+	}
+	err = vm.waitForMigration(progressFunc)
+	if err != nil {
+		vm.mutex.Lock()
+		defer vm.mutex.Unlock()
+		vm.cancelVolumeMirrors(numVolumes)
+		if vm.State == proto.StateMigrating && vm.commandChannel != nil {
+			vm.setState(proto.StateRunning) // QEMU continues on failure.
+		}
+		return "", err
+	}
+	// The VM is now paused: complete the copy of the volumes.
+	err = vm.completeVolumeMirrors(numVolumes)
+	vm.mutex.Lock()
+	defer vm.mutex.Unlock()
+	if err != nil {
+		vm.resumeAfterFailedMigration()
+		return "", err
+	}
+	// Block reallocation of addresses until VM is destroyed, then release
+	// claims on addresses.
+	vm.Uncommitted = true
+	if err := m.unregisterAddress(vm.Address, true); err != nil {
+		vm.resumeAfterFailedMigration()
+		return "", err
+	}
+	for _, address := range vm.SecondaryAddresses {
+		if err := m.unregisterAddress(address, true); err != nil {
+			vm.logger.Printf("error unregistering address: %s\n",
+				address.IpAddress)
+			vm.resumeAfterFailedMigration()
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// migrateVmLive will start the VM waiting for an incoming live migration and
+// export its volumes with an NBD server. The source mirrors its volumes to the
+// exports while it is running and then copies the memory and device state,
+// after which it is left paused. The time the source was paused is returned,
+// or the zero time if it was not paused.
+func (vm *vmInfoType) migrateVmLive(conn *srpc.Conn, hypervisor *srpc.Client,
+	sourceHypervisor string, accessToken []byte) (time.Time, error) {
+	localAddress, err := getLocalAddressFor(sourceHypervisor)
+	if err != nil {
+		return time.Time{}, err
+	}
+	migrationAddress, err := getFreeAddress(localAddress)
+	if err != nil {
+		return time.Time{}, err
+	}
+	nbdAddress, err := getFreeAddress(localAddress)
+	if err != nil {
+		return time.Time{}, err
+	}
+	err = sendVmMigrationMessage(conn, "starting VM for incoming migration")
+	if err != nil {
+		return time.Time{}, err
+	}
+	vm.incomingMigrationUri, err = makeMigrationUri(migrationAddress)
+	if err != nil {
+		return time.Time{}, err
+	}
+	vm.State = proto.StateStarting
+	vm.manager.mutex.Lock()
+	vm.manager.vms[vm.ipAddress] = vm
+	vm.manager.mutex.Unlock()
+	_, err = vm.startManaging(0, false)
+	vm.incomingMigrationUri = ""
+	if err != nil {
+		return time.Time{}, err
+	}
+	vm.mutex.RLock()
+	err = vm.startNbdServer(nbdAddress)
+	vm.mutex.RUnlock()
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer func() {
+		vm.mutex.RLock()
+		vm.stopNbdServer()
+		vm.mutex.RUnlock()
+	}()
+	startTime := time.Now()
+	err = hyperclient.MigrateVmState(hypervisor, vm.Address.IpAddress,
+		accessToken, migrationAddress, nbdAddress,
+		func(message string) error {
+			return sendVmMigrationMessage(conn, "source: "+message)
+		})
+	if err != nil {
+		return time.Time{}, err
+	}
+	pausedTime := time.Now()
+	err = sendVmMigrationMessage(conn, fmt.Sprintf(
+		"mirrored volume(s) and copied memory and device state in %s",
+		format.Duration(pausedTime.Sub(startTime))))
+	return pausedTime, err
+}
+
+// checkLiveMigrationVolumes checks that the volumes may be mirrored during a
+// live migration.
+func checkLiveMigrationVolumes(volumes []proto.Volume) error {
+	for index, volume := range volumes {
+		if volume.Format != proto.VolumeFormatRaw {
+			return fmt.Errorf("volume: %d format: %s is not raw",
+				index, volume.Format)
+		}
+	}
+	return nil
+}
+
+// checkLiveMigration checks if the VM on the source Hypervisor can be live
+// migrated to this Hypervisor.
+func checkLiveMigration(hypervisor *srpc.Client, ipAddr net.IP,
+	accessToken []byte) error {
+	sourceVersion, err := hyperclient.ProbeVmLiveMigration(hypervisor, ipAddr,
+		accessToken)
+	if err != nil {
+		return err
+	}
+	if verstr.Less(sourceVersion, minimumLiveMigrationQemuVersion) {
+		return fmt.Errorf("source QEMU version: %s is older than: %s",
+			sourceVersion, minimumLiveMigrationQemuVersion)
+	}
+	localVersion, err := getQemuVersion()
+	if err != nil {
+		return err
+	}
+	if verstr.Less(localVersion, sourceVersion) {
+		return fmt.Errorf("QEMU version: %s is older than source version: %s",
+			localVersion, sourceVersion)
+	}
+	return nil
+}
+
+// createMigrationVolumes will create empty volumes, to be written by the
+// source of a live migration.
+func (vm *vmInfoType) createMigrationVolumes() error {
+	for index, volume := range vm.VolumeLocations {
+		file, err := os.OpenFile(volume.Filename,
+			os.O_WRONLY|os.O_CREATE|os.O_EXCL, privateFilePerms)
+		if err != nil {
+			return err
+		}
+		err = file.Truncate(int64(vm.Volumes[index].Size))
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getFreeAddress returns a local address (IP address and port) which is
+// available for listening on.
+func getFreeAddress(localAddress string) (string, error) {
+	listener, err := net.Listen("tcp", net.JoinHostPort(localAddress, "0"))
+	if err != nil {
+		return "", err
+	}
+	defer listener.Close()
+	return listener.Addr().String(), nil
+}
+
+// getLocalAddressFor returns the local IP address used to reach address.
+func getLocalAddressFor(address string) (string, error) {
+	conn, err := net.Dial("udp", address) // No packets are sent.
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	host, _, err := net.SplitHostPort(conn.LocalAddr().String())
+	return host, err
+}
+
+// resumeAfterFailedMigration will continue running a VM that was paused after
+// sending its state. The VM lock must be held.
+func (vm *vmInfoType) resumeAfterFailedMigration() {
+	vm.Uncommitted = false
+	vm.setState(proto.StateRunning)
+	if err := vm.qmpCommand("cont", nil, nil); err != nil {
+		vm.logger.Println(err)
+	}
+}
+
+// resumeMigratingVm will reclaim addresses and continue running a VM whose
+// state was sent to another Hypervisor. The VM lock must be held.
+func (vm *vmInfoType) resumeMigratingVm() error {
+	if vm.State != proto.StateMigrating {
+		return errors.New("VM is not migrating")
+	}
+	if vm.commandChannel == nil {
+		return errors.New("VM is not running")
+	}
+	if err := vm.manager.registerAddress(vm.Address); err != nil {
+		return err
+	}
+	for _, address := range vm.SecondaryAddresses {
+		if err := vm.manager.registerAddress(address); err != nil {
+			vm.logger.Printf("error registering address: %s\n",
+				address.IpAddress)
+			return err
+		}
+	}
+	vm.Uncommitted = false
+	vm.setState(proto.StateRunning)
+	return vm.qmpCommand("cont", nil, nil)
+}
+
+// checkCanSendState checks if the state of the VM may be sent. The VM lock must
+// be held.
+func (vm *vmInfoType) checkCanSendState() error {
+	if vm.Uncommitted {
+		return errors.New("VM is uncommitted")
+	}
+	if vm.State != proto.StateRunning {
+		return errors.New("VM is not running")
+	}
+	return nil
+}
+
+// sendVmState will start sending the memory and device state of a running VM
+// to destinationUri, which must be made by makeMigrationUri. The VM lock must
+// be held.
+func (vm *vmInfoType) sendVmState(destinationUri string) error {
+	if err := vm.checkCanSendState(); err != nil {
+		return err
+	}
+	err := vm.qmpCommand("migrate-set-capabilities",
+		makeMigrationCapabilities("auto-converge"), nil)
+	if err != nil {
+		return err
+	}
+	err = vm.qmpCommand("migrate",
+		map[string]string{"uri": destinationUri}, nil)
+	if err != nil {
+		return err
+	}
+	vm.setState(proto.StateMigrating)
+	return nil
+}
+
 func migratevmUserData(hypervisor *srpc.Client, filename string,
 	ipAddr net.IP, accessToken []byte) error {
 	conn, err := hypervisor.Call("Hypervisor.GetVmUserData")
@@ -1804,7 +2472,7 @@ func (m *Manager) patchVmImage(conn *srpc.Conn,
 	}
 	vm.logger.Debugf(0, "update(%s) starting\n", imageName)
 	startTime = time.Now()
-	_, _, err = sublib.Update(subRequest, rootDir, objectsDir, nil, nil, nil,
+	_, err = sublib.Update(subRequest, rootDir, objectsDir, nil, nil, nil,
 		vm.logger)
 	if err != nil {
 		return err
@@ -1931,6 +2599,14 @@ func (m *Manager) replaceVmImage(conn *srpc.Conn,
 		}
 		return sendError(conn, errors.New("VM is not stopped"))
 	}
+	err = m.checkImageSourceTrusted(request.ImageName, request.ImageDataSize,
+		request.ImageURL)
+	if err != nil {
+		if err := maybeDrainImage(conn, request.ImageDataSize); err != nil {
+			return err
+		}
+		return sendError(conn, err)
+	}
 	initrdFilename := vm.getInitrdPath()
 	tmpInitrdFilename := initrdFilename + ".new"
 	defer os.Remove(tmpInitrdFilename)
@@ -2066,7 +2742,11 @@ func (m *Manager) replaceVmUserData(ipAddr net.IP, reader io.Reader,
 }
 
 func (m *Manager) restoreVmFromSnapshot(ipAddr net.IP,
-	authInfo *srpc.AuthInformation, forceIfNotStopped bool) error {
+	authInfo *srpc.AuthInformation, forceIfNotStopped bool,
+	name string) error {
+	if err := checkSnapshotName(name); err != nil {
+		return err
+	}
 	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, nil)
 	if err != nil {
 		return err
@@ -2077,15 +2757,7 @@ func (m *Manager) restoreVmFromSnapshot(ipAddr net.IP,
 			return errors.New("VM is not stopped")
 		}
 	}
-	for _, volume := range vm.VolumeLocations {
-		snapshotFilename := volume.Filename + ".snapshot"
-		if err := os.Rename(snapshotFilename, volume.Filename); err != nil {
-			if !os.IsNotExist(err) {
-				return err
-			}
-		}
-	}
-	return nil
+	return vm.restoreSnapshot(name)
 }
 
 func (m *Manager) restoreVmImage(ipAddr net.IP,
@@ -2138,42 +2810,25 @@ func (m *Manager) sendVmInfo(ipAddress string, vm *proto.VmInfo) {
 			VMs:     map[string]*proto.VmInfo{ipAddress: vm},
 		})
 	}
+	m.triggerFirewallRefresh()
 }
 
 func (m *Manager) snapshotVm(ipAddr net.IP, authInfo *srpc.AuthInformation,
-	forceIfNotStopped, snapshotRootOnly bool) error {
+	forceIfNotStopped, snapshotRootOnly bool, name string) error {
+	if err := checkSnapshotName(name); err != nil {
+		return err
+	}
 	vm, err := m.getVmLockAndAuth(ipAddr, true, authInfo, nil)
 	if err != nil {
 		return err
 	}
 	defer vm.mutex.Unlock()
-	// TODO(rgooch): First check for sufficient free space.
 	if vm.State != proto.StateStopped {
 		if !forceIfNotStopped {
 			return errors.New("VM is not stopped")
 		}
 	}
-	if err := vm.discardSnapshot(); err != nil {
-		return err
-	}
-	doCleanup := true
-	defer func() {
-		if doCleanup {
-			vm.discardSnapshot()
-		}
-	}()
-	for index, volume := range vm.VolumeLocations {
-		snapshotFilename := volume.Filename + ".snapshot"
-		if index == 0 || !snapshotRootOnly {
-			err := fsutil.CopyFile(snapshotFilename, volume.Filename,
-				privateFilePerms)
-			if err != nil {
-				return err
-			}
-		}
-	}
-	doCleanup = false
-	return nil
+	return vm.takeSnapshot(name, snapshotRootOnly, 0, time.Now())
 }
 
 func (m *Manager) startVm(ipAddr net.IP, authInfo *srpc.AuthInformation,
@@ -2445,6 +3100,10 @@ func (vm *vmInfoType) delete() {
 	if err != nil {
 		vm.manager.Logger.Println(err)
 	}
+	vm.removeSnapshotFiles("")
+	for _, snapshot := range vm.Snapshots {
+		vm.removeSnapshotFiles(snapshot.Name)
+	}
 	for _, volume := range vm.VolumeLocations {
 		os.Remove(volume.Filename)
 		if volume.DirectoryToCleanup != "" {
@@ -2462,17 +3121,6 @@ func (vm *vmInfoType) destroy() {
 	vm.delete()
 }
 
-func (vm *vmInfoType) discardSnapshot() error {
-	for _, volume := range vm.VolumeLocations {
-		if err := os.Remove(volume.Filename + ".snapshot"); err != nil {
-			if !os.IsNotExist(err) {
-				return err
-			}
-		}
-	}
-	return nil
-}
-
 func (vm *vmInfoType) getActiveInitrdPath() string {
 	initrdPath := vm.getInitrdPath()
 	if _, err := os.Stat(initrdPath); err == nil {
@@ -2514,7 +3162,12 @@ func (vm *vmInfoType) monitor(monitorSock net.Conn,
 	go vm.probeHealthAgent(cancelChannel)
 	go vm.serialManager()
 	for command := range commandChannel {
-		_, err := fmt.Fprintf(monitorSock, `{"execute":"%s"}`, command)
+		var err error
+		if strings.HasPrefix(command, "{") { // Full command from qmpCommand.
+			_, err = io.WriteString(monitorSock, command)
+		} else {
+			_, err = fmt.Fprintf(monitorSock, `{"execute":"%s"}`, command)
+		}
 		if err != nil {
 			vm.logger.Println(err)
 		} else {
@@ -2550,11 +3203,12 @@ func (vm *vmInfoType) probeHealthAgent(cancel <-chan struct{}) {
 }
 
 func (vm *vmInfoType) processMonitorResponses(monitorSock net.Conn) {
-	io.Copy(ioutil.Discard, monitorSock) // Read all and drop.
+	vm.readMonitorResponses(monitorSock)
 	vm.mutex.Lock()
 	defer vm.mutex.Unlock()
 	close(vm.commandChannel)
 	vm.commandChannel = nil
+	vm.removeFirewall()
 	switch vm.State {
 	case proto.StateStarting:
 		select {
@@ -2652,6 +3306,26 @@ func (vm *vmInfoType) setState(state proto.State) {
 	}
 }
 
+// getVolumeDeviceName returns the name which QEMU gives the drive for a volume.
+func (vm *vmInfoType) getVolumeDeviceName(volumeIndex uint) string {
+	if vm.DisableVirtIO {
+		return fmt.Sprintf("ide%d-hd%d", volumeIndex/2, volumeIndex%2)
+	}
+	return fmt.Sprintf("virtio%d", volumeIndex)
+}
+
+// makeSecondaryVolumeFilename returns an unused name for a new secondary
+// volume. Deleting volumes does not rename the remaining volumes.
+func (vm *vmInfoType) makeSecondaryVolumeFilename(dirname string) string {
+	for index := len(vm.VolumeLocations) - 1; ; index++ {
+		filename := filepath.Join(dirname,
+			fmt.Sprintf("secondary-volume.%d", index))
+		if _, err := os.Stat(filename); os.IsNotExist(err) {
+			return filename
+		}
+	}
+}
+
 func (vm *vmInfoType) setupVolumes(rootSize uint64,
 	secondaryVolumes []proto.Volume, spreadVolumes bool) error {
 	volumeDirectories, err := vm.manager.getVolumeDirectories(rootSize,
@@ -2665,8 +3339,10 @@ func (vm *vmInfoType) setupVolumes(rootSize uint64,
 		return err
 	}
 	filename := filepath.Join(volumeDirectory, "root")
-	vm.VolumeLocations = append(vm.VolumeLocations,
-		proto.LocalVolume{volumeDirectory, filename})
+	vm.VolumeLocations = append(vm.VolumeLocations, proto.LocalVolume{
+		DirectoryToCleanup: volumeDirectory,
+		Filename:           filename,
+	})
 	for index := range secondaryVolumes {
 		volumeDirectory := filepath.Join(volumeDirectories[index+1],
 			vm.ipAddress)
@@ -2676,8 +3352,10 @@ func (vm *vmInfoType) setupVolumes(rootSize uint64,
 		}
 		filename := filepath.Join(volumeDirectory,
 			fmt.Sprintf("secondary-volume.%d", index))
-		vm.VolumeLocations = append(vm.VolumeLocations,
-			proto.LocalVolume{volumeDirectory, filename})
+		vm.VolumeLocations = append(vm.VolumeLocations, proto.LocalVolume{
+			DirectoryToCleanup: volumeDirectory,
+			Filename:           filename,
+		})
 	}
 	return nil
 }
@@ -2686,6 +3364,8 @@ func (vm *vmInfoType) setupVolumes(rootSize uint64,
 func (vm *vmInfoType) startManaging(dhcpTimeout time.Duration,
 	haveManagerLock bool) (bool, error) {
 	vm.monitorSockname = filepath.Join(vm.dirname, "monitor.sock")
+	vm.updateFirewallInfo()
+	vm.updateQuotaInfo()
 	vm.logger.Debugln(1, "startManaging() starting")
 	switch vm.State {
 	case proto.StateStarting:
@@ -2822,18 +3502,36 @@ func (vm *vmInfoType) startVm(haveManagerLock bool) error {
 	if nCpus*1000 < vm.MilliCPUs {
 		nCpus++
 	}
+	if vm.ConfigDrive {
+		if err := vm.writeConfigDrive(); err != nil {
+			return err
+		}
+	}
 	bridges, netOptions, err := vm.getBridgesAndOptions(haveManagerLock)
 	if err != nil {
 		return err
 	}
 	var tapFiles []*os.File
+	var tapNames []string
 	for _, bridge := range bridges {
-		tapFile, err := createTapDevice(bridge)
+		tapFile, tapName, err := createTapDevice(bridge)
 		if err != nil {
 			return fmt.Errorf("error creating tap device: %s", err)
 		}
 		defer tapFile.Close()
 		tapFiles = append(tapFiles, tapFile)
+		tapNames = append(tapNames, tapName)
+	}
+	vm.TapDevices = tapNames
+	if err := vm.programFirewall(haveManagerLock); err != nil {
+		vm.removeFirewall()
+		return err
+	}
+	if vm.Limits != nil {
+		if err := vm.setNetworkLimits(); err != nil {
+			vm.removeFirewall()
+			return err
+		}
 	}
 	cmd := exec.Command("qemu-system-x86_64", "-machine", "pc,accel=kvm",
 		"-cpu", "host", // Allow the VM to take full advantage of host CPU.
@@ -2847,6 +3545,10 @@ func (vm *vmInfoType) startVm(haveManagerLock bool) error {
 		"-runas", vm.manager.Username,
 		"-qmp", "unix:"+vm.monitorSockname+",server,nowait",
 		"-daemonize")
+	if vm.incomingMigrationUri != "" {
+		// Wait for the state of a live migration and remain paused.
+		cmd.Args = append(cmd.Args, "-incoming", vm.incomingMigrationUri, "-S")
+	}
 	if kernelPath := vm.getActiveKernelPath(); kernelPath != "" {
 		cmd.Args = append(cmd.Args, "-kernel", kernelPath)
 		if initrdPath := vm.getActiveInitrdPath(); initrdPath != "" {
@@ -2887,11 +3589,17 @@ func (vm *vmInfoType) startVm(haveManagerLock bool) error {
 		}
 		cmd.Args = append(cmd.Args,
 			"-drive", "file="+volume.Filename+",format="+volumeFormat.String()+
-				interfaceDriver)
+				interfaceDriver+makeDriveThrottleOptions(vm.Limits))
+	}
+	if vm.ConfigDrive {
+		cmd.Args = append(cmd.Args, "-drive",
+			"file="+filepath.Join(vm.dirname, configDriveFilename)+
+				",format=raw,media=cdrom")
 	}
 	os.Remove(filepath.Join(vm.dirname, "bootlog"))
 	cmd.ExtraFiles = tapFiles // Start at fd=3 for QEMU.
 	if output, err := cmd.CombinedOutput(); err != nil {
+		vm.removeFirewall()
 		return fmt.Errorf("error starting QEMU: %s: %s", err, output)
 	} else if len(output) > 0 {
 		vm.logger.Printf("QEMU started. Output: \"%s\"\n", string(output))
@@ -2902,6 +3610,8 @@ func (vm *vmInfoType) startVm(haveManagerLock bool) error {
 }
 
 func (vm *vmInfoType) writeAndSendInfo() {
+	vm.updateFirewallInfo()
+	vm.updateQuotaInfo()
 	if err := vm.writeInfo(); err != nil {
 		vm.logger.Println(err)
 		return
@@ -80,6 +80,23 @@ func getMounts() (map[string]string, error) {
 	return mounts, nil
 }
 
+// getTotalSpace returns the total size of the file-systems containing the
+// volume directories.
+func getTotalSpace(volumeDirectories []string) uint64 {
+	var totalSpace uint64
+	for _, dirname := range volumeDirectories {
+		var statbuf syscall.Statfs_t
+		if err := syscall.Statfs(dirname, &statbuf); err != nil {
+			// The directory may not yet exist: use the mount point.
+			if syscall.Statfs(filepath.Dir(dirname), &statbuf) != nil {
+				continue
+			}
+		}
+		totalSpace += uint64(statbuf.Blocks * uint64(statbuf.Bsize))
+	}
+	return totalSpace
+}
+
 func getVolumeDirectories() ([]string, error) {
 	mounts, err := getMounts()
 	if err != nil {
@@ -0,0 +1,122 @@
+package metadatad
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Symantec/Dominator/hypervisor/manager"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+const ec2MetadataPath = "/latest/meta-data"
+
+// getPublicKeyName returns the name for an SSH public key, which is the key
+// comment if present.
+func getPublicKeyName(key string, index int) string {
+	if fields := strings.Fields(key); len(fields) > 2 {
+		return fields[2]
+	}
+	return "key" + strconv.Itoa(index)
+}
+
+// makeEc2Metadata returns the EC2 meta-data tree for the VM, keyed by the path
+// relative to the meta-data directory.
+func makeEc2Metadata(ipAddr net.IP, vmInfo proto.VmInfo) map[string]string {
+	hostname := vmInfo.GetLocalHostname(ipAddr)
+	metadata := map[string]string{
+		"hostname":       hostname,
+		"instance-id":    manager.GetInstanceId(ipAddr),
+		"local-hostname": hostname,
+		"local-ipv4":     ipAddr.String(),
+		"mac":            vmInfo.Address.MacAddress,
+	}
+	addresses := append([]proto.Address{vmInfo.Address},
+		vmInfo.SecondaryAddresses...)
+	for index, address := range addresses {
+		dirname := "network/interfaces/macs/" + address.MacAddress + "/"
+		metadata[dirname+"device-number"] = strconv.Itoa(index)
+		metadata[dirname+"mac"] = address.MacAddress
+		if index == 0 {
+			metadata[dirname+"local-ipv4s"] = ipAddr.String()
+		} else if len(address.IpAddress) > 0 {
+			metadata[dirname+"local-ipv4s"] = address.IpAddress.String()
+		}
+		if len(address.Ipv6Address) > 0 {
+			metadata[dirname+"ipv6s"] = address.Ipv6Address.String()
+		}
+	}
+	for index, key := range vmInfo.SshPublicKeys {
+		metadata[fmt.Sprintf("public-keys/%d/openssh-key", index)] = key
+	}
+	for key, value := range vmInfo.Tags {
+		if key != "" && !strings.Contains(key, "/") {
+			metadata["tags/instance/"+key] = value
+		}
+	}
+	return metadata
+}
+
+// normaliseVersion maps the dated EC2 API versions to the latest version.
+func normaliseVersion(path string) string {
+	splitPath := strings.SplitN(path, "/", 3)
+	if len(splitPath) < 3 || splitPath[0] != "" {
+		return path
+	}
+	version := splitPath[1]
+	if len(version) != 10 || version[4] != '-' || version[7] != '-' {
+		return path
+	}
+	return "/latest/" + splitPath[2]
+}
+
+// showEc2Metadata writes the value or directory listing for the path in the
+// EC2 meta-data tree. It returns false if the path does not exist.
+func showEc2Metadata(writer io.Writer, path string, ipAddr net.IP,
+	vmInfo proto.VmInfo) bool {
+	metadata := makeEc2Metadata(ipAddr, vmInfo)
+	if value, ok := metadata[path]; ok {
+		io.WriteString(writer, value)
+		return true
+	}
+	if path == "public-keys" || path == "public-keys/" {
+		if len(vmInfo.SshPublicKeys) < 1 {
+			return false
+		}
+		for index, key := range vmInfo.SshPublicKeys {
+			fmt.Fprintf(writer, "%d=%s\n",
+				index, getPublicKeyName(key, index))
+		}
+		return true
+	}
+	dirname := path
+	if dirname != "" && !strings.HasSuffix(dirname, "/") {
+		dirname += "/"
+	}
+	entriesSet := make(map[string]struct{})
+	for key := range metadata {
+		if !strings.HasPrefix(key, dirname) {
+			continue
+		}
+		entry := key[len(dirname):]
+		if index := strings.IndexByte(entry, '/'); index >= 0 {
+			entry = entry[:index+1]
+		}
+		entriesSet[entry] = struct{}{}
+	}
+	if len(entriesSet) < 1 {
+		return false
+	}
+	entries := make([]string, 0, len(entriesSet))
+	for entry := range entriesSet {
+		entries = append(entries, entry)
+	}
+	sort.Strings(entries)
+	for _, entry := range entries {
+		fmt.Fprintln(writer, entry)
+	}
+	return true
+}
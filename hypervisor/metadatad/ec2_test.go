@@ -0,0 +1,52 @@
+package metadatad
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/tags"
+	proto "github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func TestShowEc2Metadata(t *testing.T) {
+	ipAddr := net.ParseIP("10.20.0.5")
+	vmInfo := proto.VmInfo{
+		Address: proto.Address{
+			IpAddress:  ipAddr,
+			MacAddress: "52:54:0a:14:00:05",
+		},
+		SshPublicKeys: []string{"ssh-ed25519 AAAA user@host"},
+		Tags:          tags.Tags{"Name": "web"},
+	}
+	macDir := "network/interfaces/macs/52:54:0a:14:00:05/"
+	tests := map[string]string{
+		"": "hostname\ninstance-id\nlocal-hostname\nlocal-ipv4\nmac\n" +
+			"network/\npublic-keys/\ntags/\n",
+		"instance-id":               "i-0a140005",
+		"local-hostname":            "ip-10-20-0-5",
+		"network/interfaces/":       "macs/\n",
+		macDir:                      "device-number\nlocal-ipv4s\nmac\n",
+		macDir + "local-ipv4s":      "10.20.0.5",
+		"public-keys/":              "0=user@host\n",
+		"public-keys/0/":            "openssh-key\n",
+		"public-keys/0/openssh-key": "ssh-ed25519 AAAA user@host",
+		"tags/instance":             "Name\n",
+		"tags/instance/Name":        "web",
+	}
+	for path, expected := range tests {
+		buffer := &bytes.Buffer{}
+		if !showEc2Metadata(buffer, path, ipAddr, vmInfo) {
+			t.Errorf("%s: not found", path)
+		} else if got := buffer.String(); got != expected {
+			t.Errorf("%s: expected: %q, got: %q", path, expected, got)
+		}
+	}
+	if showEc2Metadata(&bytes.Buffer{}, "missing", ipAddr, vmInfo) {
+		t.Error("missing path found")
+	}
+	if got := normaliseVersion("/2009-04-04/meta-data/instance-id"); got !=
+		"/latest/meta-data/instance-id" {
+		t.Errorf("unexpected normalised path: %s", got)
+	}
+}
@@ -22,6 +22,7 @@ func (s *server) computePaths() {
 	for path := range s.rawHandlers {
 		s.paths[path] = struct{}{}
 	}
+	s.paths[ec2MetadataPath+"/"] = struct{}{}
 }
 
 func (s *server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
@@ -38,23 +39,33 @@ func (s *server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		w.WriteHeader(http.StatusNotFound)
 		return
 	}
-	if rawHandler, ok := s.rawHandlers[req.URL.Path]; ok {
+	reqPath := normaliseVersion(req.URL.Path)
+	if rawHandler, ok := s.rawHandlers[reqPath]; ok {
 		rawHandler(w, ipAddr)
 		return
 	}
 	writer := bufio.NewWriter(w)
 	defer writer.Flush()
-	if infoHandler, ok := s.infoHandlers[req.URL.Path]; ok {
+	if infoHandler, ok := s.infoHandlers[reqPath]; ok {
 		if err := infoHandler(writer, vmInfo); err != nil {
 			fmt.Fprintln(writer, err)
 		}
 		return
 	}
+	if reqPath == ec2MetadataPath ||
+		strings.HasPrefix(reqPath, ec2MetadataPath+"/") {
+		path := strings.TrimPrefix(reqPath[len(ec2MetadataPath):], "/")
+		if !showEc2Metadata(writer, path, ipAddr, vmInfo) {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(http.StatusNotFound)
+		}
+		return
+	}
 	paths := make([]string, 0)
 	pathsSet := make(map[string]struct{})
 	for path := range s.paths {
-		if strings.HasPrefix(path, req.URL.Path) {
-			splitPath := strings.Split(path[len(req.URL.Path):], "/")
+		if strings.HasPrefix(path, reqPath) {
+			splitPath := strings.Split(path[len(reqPath):], "/")
 			result := splitPath[0]
 			if result == "" {
 				result = splitPath[1]
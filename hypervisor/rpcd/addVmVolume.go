@@ -0,0 +1,16 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (t *srpcType) AddVmVolume(conn *srpc.Conn,
+	request hypervisor.AddVmVolumeRequest,
+	reply *hypervisor.AddVmVolumeResponse) error {
+	*reply = hypervisor.AddVmVolumeResponse{
+		errors.ErrorToString(t.manager.AddVmVolume(request.IpAddress,
+			conn.GetAuthInformation(), request.AccessToken, request.Size))}
+	return nil
+}
@@ -51,10 +51,14 @@ func Setup(manager *manager.Manager, dhcpServer DhcpServer,
 	srpc.RegisterNameWithOptions("Hypervisor", srpcObj, srpc.ReceiverOptions{
 		PublicMethods: []string{
 			"AcknowledgeVm",
+			"AddVmVolume",
+			"BackupVm",
 			"BecomePrimaryVmOwner",
 			"ChangeVmConsoleType",
 			"ChangeVmDestroyProtection",
+			"ChangeVmFirewall",
 			"ChangeVmOwnerUsers",
+			"ChangeVmSize",
 			"ChangeVmTags",
 			"CommitImportedVm",
 			"ConnectToVmConsole",
@@ -73,17 +77,21 @@ func Setup(manager *manager.Manager, dhcpServer DhcpServer,
 			"GetVmInfo",
 			"GetVmUserData",
 			"GetVmVolume",
+			"GrowVmVolume",
 			"ImportLocalVm",
 			"ListVMs",
+			"ListVmSnapshots",
 			"ListVolumeDirectories",
 			"MigrateVm",
 			"PatchVmImage",
 			"ProbeVmPort",
 			"ReplaceVmImage",
 			"ReplaceVmUserData",
+			"RestoreVmFromBackup",
 			"RestoreVmFromSnapshot",
 			"RestoreVmImage",
 			"RestoreVmUserData",
+			"SetVmSnapshotPolicy",
 			"SnapshotVm",
 			"StartVm",
 			"StopVm",
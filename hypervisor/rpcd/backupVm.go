@@ -0,0 +1,16 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (t *srpcType) BackupVm(conn *srpc.Conn,
+	request hypervisor.BackupVmRequest,
+	reply *hypervisor.BackupVmResponse) error {
+	response, err := t.manager.BackupVm(conn.GetAuthInformation(), request)
+	response.Error = errors.ErrorToString(err)
+	*reply = response
+	return nil
+}
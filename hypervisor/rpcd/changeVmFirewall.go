@@ -0,0 +1,18 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (t *srpcType) ChangeVmFirewall(conn *srpc.Conn,
+	request hypervisor.ChangeVmFirewallRequest,
+	reply *hypervisor.ChangeVmFirewallResponse) error {
+	response := hypervisor.ChangeVmFirewallResponse{
+		errors.ErrorToString(
+			t.manager.ChangeVmFirewall(request.IpAddress,
+				conn.GetAuthInformation(), request.Firewall))}
+	*reply = response
+	return nil
+}
@@ -0,0 +1,18 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (t *srpcType) ChangeVmLimits(conn *srpc.Conn,
+	request hypervisor.ChangeVmLimitsRequest,
+	reply *hypervisor.ChangeVmLimitsResponse) error {
+	response := hypervisor.ChangeVmLimitsResponse{
+		errors.ErrorToString(
+			t.manager.ChangeVmLimits(request.IpAddress,
+				conn.GetAuthInformation(), request.Limits))}
+	*reply = response
+	return nil
+}
@@ -0,0 +1,17 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (t *srpcType) ChangeVmSize(conn *srpc.Conn,
+	request hypervisor.ChangeVmSizeRequest,
+	reply *hypervisor.ChangeVmSizeResponse) error {
+	*reply = hypervisor.ChangeVmSizeResponse{
+		errors.ErrorToString(t.manager.ChangeVmSize(request.IpAddress,
+			conn.GetAuthInformation(), request.AccessToken,
+			request.MemoryInMiB, request.MilliCPUs))}
+	return nil
+}
@@ -0,0 +1,17 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (t *srpcType) GrowVmVolume(conn *srpc.Conn,
+	request hypervisor.GrowVmVolumeRequest,
+	reply *hypervisor.GrowVmVolumeResponse) error {
+	*reply = hypervisor.GrowVmVolumeResponse{
+		errors.ErrorToString(t.manager.GrowVmVolume(request.IpAddress,
+			conn.GetAuthInformation(), request.AccessToken,
+			request.VolumeIndex, request.Size))}
+	return nil
+}
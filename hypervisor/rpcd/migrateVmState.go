@@ -0,0 +1,18 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (t *srpcType) MigrateVmState(conn *srpc.Conn) error {
+	qemuVersion, err := t.manager.MigrateVmState(conn)
+	if err != nil {
+		return conn.Encode(hypervisor.MigrateVmStateResponse{
+			Error: err.Error()})
+	}
+	return conn.Encode(hypervisor.MigrateVmStateResponse{
+		Final:       true,
+		QemuVersion: qemuVersion,
+	})
+}
@@ -0,0 +1,22 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (t *srpcType) RestoreVmFromBackup(conn *srpc.Conn) error {
+	if err := t.restoreVmFromBackup(conn); err != nil {
+		return conn.Encode(
+			hypervisor.RestoreVmFromBackupResponse{Error: err.Error()})
+	}
+	return nil
+}
+
+func (t *srpcType) restoreVmFromBackup(conn *srpc.Conn) error {
+	var request hypervisor.RestoreVmFromBackupRequest
+	if err := conn.Decode(&request); err != nil {
+		return err
+	}
+	return t.manager.RestoreVmFromBackup(conn, request)
+}
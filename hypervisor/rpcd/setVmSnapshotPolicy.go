@@ -0,0 +1,17 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (t *srpcType) SetVmSnapshotPolicy(conn *srpc.Conn,
+	request hypervisor.SetVmSnapshotPolicyRequest,
+	reply *hypervisor.SetVmSnapshotPolicyResponse) error {
+	err := t.manager.SetVmSnapshotPolicy(request.IpAddress,
+		conn.GetAuthInformation(), request.Policy)
+	*reply = hypervisor.SetVmSnapshotPolicyResponse{
+		errors.ErrorToString(err)}
+	return nil
+}
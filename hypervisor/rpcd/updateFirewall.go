@@ -0,0 +1,15 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (t *srpcType) UpdateFirewall(conn *srpc.Conn,
+	request hypervisor.UpdateFirewallRequest,
+	reply *hypervisor.UpdateFirewallResponse) error {
+	*reply = hypervisor.UpdateFirewallResponse{
+		errors.ErrorToString(t.manager.UpdateFirewall(request))}
+	return nil
+}
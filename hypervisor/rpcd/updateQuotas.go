@@ -0,0 +1,15 @@
+package rpcd
+
+import (
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/hypervisor"
+)
+
+func (t *srpcType) UpdateQuotas(conn *srpc.Conn,
+	request hypervisor.UpdateQuotasRequest,
+	reply *hypervisor.UpdateQuotasResponse) error {
+	*reply = hypervisor.UpdateQuotasResponse{
+		errors.ErrorToString(t.manager.UpdateQuotas(request))}
+	return nil
+}
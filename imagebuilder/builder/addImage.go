@@ -1,14 +1,18 @@
 package builder
 
 import (
+	"bufio"
 	"bytes"
 	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"os"
 	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	imageclient "github.com/Symantec/Dominator/imageserver/client"
@@ -19,6 +23,8 @@ import (
 	"github.com/Symantec/Dominator/lib/format"
 	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/image/sbom"
+	"github.com/Symantec/Dominator/lib/image/trust"
 	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
 	"github.com/Symantec/Dominator/lib/srpc"
 	"github.com/Symantec/Dominator/lib/triggers"
@@ -27,7 +33,12 @@ import (
 
 const timeFormat = "2006-01-02:15:04:05"
 
-var errorTestTimedOut = errors.New("test timed out")
+var (
+	errorTestTimedOut = errors.New("test timed out")
+
+	imageSigningKeyFile = flag.String("imageSigningKeyFile", "",
+		"Filename of PEM private key to sign images with")
+)
 
 type hasher struct {
 	objQ *objectclient.ObjectAdderQueue
@@ -55,12 +66,42 @@ func addImage(client *srpc.Client, request proto.BuildImageRequest,
 		img.ExpiresAt = time.Now().Add(request.ExpiresIn)
 	}
 	name := path.Join(request.StreamName, time.Now().Format(timeFormat))
+	if *imageSigningKeyFile != "" {
+		signer, err := trust.LoadSigner(*imageSigningKeyFile)
+		if err != nil {
+			return "", err
+		}
+		if err := trust.Sign(img, signer); err != nil {
+			return "", errors.New("error signing image: " + err.Error())
+		}
+	}
 	if err := imageclient.AddImage(client, name, img); err != nil {
 		return "", errors.New("remote error: " + err.Error())
 	}
 	return name, nil
 }
 
+func addProvenance(objClient *objectclient.ObjectClient,
+	request proto.BuildImageRequest, provenance *sbom.Provenance,
+	packages []image.Package) (hash.Hash, error) {
+	doc := sbom.Document{Packages: packages}
+	if provenance != nil {
+		doc.Provenance = *provenance
+	}
+	doc.Provenance.StreamName = request.StreamName
+	doc.Provenance.Timestamp = time.Now()
+	for name := range request.Variables {
+		doc.Provenance.BuildVariables = append(doc.Provenance.BuildVariables,
+			name)
+	}
+	buffer := &bytes.Buffer{}
+	if err := doc.Write(buffer); err != nil {
+		return hash.Hash{}, err
+	}
+	hashVal, _, err := objClient.AddObject(buffer, uint64(buffer.Len()), nil)
+	return hashVal, err
+}
+
 func buildFileSystem(client *srpc.Client, dirname string,
 	scanFilter *filter.Filter) (
 	*filesystem.FileSystem, error) {
@@ -115,25 +156,32 @@ func listPackages(rootDir string) ([]image.Package, error) {
 		return nil, err
 	}
 	packageMap := make(map[string]image.Package)
-	for {
-		var name, version string
-		var size uint64
-		nScanned, err := fmt.Fscanf(output, "%s %s %d\n",
-			&name, &version, &size)
-		if err != nil {
-			if err == io.EOF {
-				break
-			}
-			return nil, err
+	scanner := bufio.NewScanner(output)
+	for scanner.Scan() {
+		// Format: name version size [digest]
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 1 {
+			continue
 		}
-		if nScanned != 3 {
+		if len(fields) != 3 && len(fields) != 4 {
 			return nil, errors.New("malformed line")
 		}
-		packageMap[name] = image.Package{
-			Name:    name,
+		size, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		pkg := image.Package{
+			Name:    fields[0],
 			Size:    size * sizeMultiplier,
-			Version: version,
+			Version: fields[1],
 		}
+		if len(fields) == 4 {
+			pkg.Digest = fields[3]
+		}
+		packageMap[pkg.Name] = pkg
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
 	}
 	packageNames := make([]string, 0, len(packageMap))
 	for name := range packageMap {
@@ -150,7 +198,8 @@ func listPackages(rootDir string) ([]image.Package, error) {
 func packImage(client *srpc.Client, request proto.BuildImageRequest,
 	dirname string, scanFilter *filter.Filter,
 	computedFilesList []util.ComputedFile, imageFilter *filter.Filter,
-	trig *triggers.Triggers, buildLog buildLogger) (*image.Image, error) {
+	trig *triggers.Triggers, provenance *sbom.Provenance,
+	buildLog buildLogger) (*image.Image, error) {
 	packages, err := listPackages(dirname)
 	if err != nil {
 		return nil, fmt.Errorf("error listing packages: %s", err)
@@ -190,6 +239,11 @@ func packImage(client *srpc.Client, request proto.BuildImageRequest,
 	if err != nil {
 		return nil, err
 	}
+	provenanceHash, err := addProvenance(objClient, request, provenance,
+		packages)
+	if err != nil {
+		return nil, fmt.Errorf("error adding provenance: %s", err)
+	}
 	if err := objClient.Close(); err != nil {
 		return nil, err
 	}
@@ -199,6 +253,7 @@ func packImage(client *srpc.Client, request proto.BuildImageRequest,
 		Filter:     imageFilter,
 		Triggers:   trig,
 		Packages:   packages,
+		Provenance: &image.Annotation{Object: &provenanceHash},
 	}
 	if err := img.Verify(); err != nil {
 		return nil, err
@@ -8,6 +8,7 @@ import (
 
 	"github.com/Symantec/Dominator/lib/filter"
 	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/image/sbom"
 	"github.com/Symantec/Dominator/lib/log"
 	"github.com/Symantec/Dominator/lib/slavedriver"
 	"github.com/Symantec/Dominator/lib/srpc"
@@ -90,8 +91,10 @@ type packagerType struct {
 }
 
 type sourceImageInfoType struct {
-	filter   *filter.Filter
-	triggers *triggers.Triggers
+	filter       *filter.Filter
+	imageName    string
+	packagerType string
+	triggers     *triggers.Triggers
 }
 
 type Builder struct {
@@ -154,7 +157,7 @@ func BuildImageFromManifest(client *srpc.Client, manifestDir, streamName string,
 			StreamName: streamName,
 			ExpiresIn:  expiresIn,
 		},
-		bindMounts, buildLog)
+		bindMounts, &sbom.Provenance{}, buildLog)
 	return name, err
 }
 
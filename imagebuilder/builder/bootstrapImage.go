@@ -19,6 +19,7 @@ import (
 	"github.com/Symantec/Dominator/lib/filter"
 	"github.com/Symantec/Dominator/lib/format"
 	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/image/sbom"
 	"github.com/Symantec/Dominator/lib/srpc"
 	"github.com/Symantec/Dominator/lib/wsyscall"
 	proto "github.com/Symantec/Dominator/proto/imaginator"
@@ -106,7 +107,8 @@ func (stream *bootstrapStream) build(b *Builder, client *srpc.Client,
 			return nil, err
 		}
 		return packImage(client, request, rootDir,
-			stream.Filter, nil, &filter.Filter{}, nil, buildLog)
+			stream.Filter, nil, &filter.Filter{}, nil,
+			&sbom.Provenance{PackagerType: stream.PackagerType}, buildLog)
 	}
 }
 
@@ -191,7 +191,7 @@ func (stream *imageStreamType) WriteHtml(writer io.Writer) {
 		stream.ManifestDirectory)
 	buildLog := new(bytes.Buffer)
 	manifestDirectory, err := stream.getManifest(stream.builder, stream.name,
-		"", nil, buildLog)
+		"", nil, nil, buildLog)
 	if err != nil {
 		fmt.Fprintf(writer, "<b>%s</b><br>\n", err)
 		return
@@ -1,6 +1,7 @@
 package builder
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -18,7 +19,9 @@ import (
 	"github.com/Symantec/Dominator/lib/filter"
 	"github.com/Symantec/Dominator/lib/format"
 	"github.com/Symantec/Dominator/lib/fsutil"
+	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/image/sbom"
 	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
 	"github.com/Symantec/Dominator/lib/srpc"
 	"github.com/Symantec/Dominator/lib/triggers"
@@ -28,26 +31,34 @@ import (
 func (stream *imageStreamType) build(b *Builder, client *srpc.Client,
 	request proto.BuildImageRequest, buildLog buildLogger) (
 	*image.Image, error) {
+	provenance := &sbom.Provenance{}
 	manifestDirectory, err := stream.getManifest(b, request.StreamName,
-		request.GitBranch, request.Variables, buildLog)
+		request.GitBranch, request.Variables, provenance, buildLog)
 	if err != nil {
 		return nil, err
 	}
 	defer os.RemoveAll(manifestDirectory)
 	img, err := buildImageFromManifest(client, manifestDirectory, request,
-		b.bindMounts, buildLog)
+		b.bindMounts, provenance, buildLog)
 	if err != nil {
 		return nil, err
 	}
 	return img, nil
 }
 
+// getManifest will fetch the manifest for the stream. If provenance is not
+// nil, it is updated with the manifest source.
 func (stream *imageStreamType) getManifest(b *Builder, streamName string,
 	gitBranch string, variables map[string]string,
-	buildLog io.Writer) (string, error) {
+	provenance *sbom.Provenance, buildLog io.Writer) (string, error) {
 	if gitBranch == "" {
 		gitBranch = "master"
 	}
+	if provenance != nil {
+		provenance.GitBranch = gitBranch
+		provenance.ManifestDirectory = stream.ManifestDirectory
+		provenance.ManifestUrl = stream.ManifestUrl
+	}
 	variableFunc := b.getVariableFunc(map[string]string{
 		"IMAGE_STREAM": streamName,
 	},
@@ -122,6 +133,17 @@ func (stream *imageStreamType) getManifest(b *Builder, streamName string,
 		}
 	}
 	loadTime := time.Since(startTime)
+	if provenance != nil {
+		output := &bytes.Buffer{}
+		cmd := exec.Command("git", "rev-parse", "HEAD")
+		cmd.Dir = manifestRoot
+		cmd.Stdout = output
+		cmd.Stderr = buildLog
+		if err := cmd.Run(); err != nil {
+			return "", err
+		}
+		provenance.GitCommit = strings.TrimSpace(output.String())
+	}
 	repoSize, err := getTreeSize(manifestRoot)
 	if err != nil {
 		return "", err
@@ -213,7 +235,7 @@ func runCommand(buildLog io.Writer, cwd string, args ...string) error {
 
 func buildImageFromManifest(client *srpc.Client, manifestDir string,
 	request proto.BuildImageRequest, bindMounts []string,
-	buildLog buildLogger) (*image.Image, error) {
+	provenance *sbom.Provenance, buildLog buildLogger) (*image.Image, error) {
 	// First load all the various manifest files (fail early on error).
 	computedFilesList, err := util.LoadComputedFiles(
 		path.Join(manifestDir, "computed-files.json"))
@@ -268,15 +290,20 @@ func buildImageFromManifest(client *srpc.Client, manifestDir string,
 		mergeableTriggers.Merge(imageTriggers)
 		imageTriggers = mergeableTriggers.ExportTriggers()
 	}
+	provenance.SourceImage = manifest.sourceImageInfo.imageName
+	if provenance.PackagerType == "" {
+		provenance.PackagerType = manifest.sourceImageInfo.packagerType
+	}
 	return packImage(client, request, rootDir, manifest.filter,
-		computedFilesList, imageFilter, imageTriggers, buildLog)
+		computedFilesList, imageFilter, imageTriggers, provenance, buildLog)
 }
 
 func buildImageFromManifestAndUpload(client *srpc.Client, manifestDir string,
 	request proto.BuildImageRequest, bindMounts []string,
-	buildLog buildLogger) (*image.Image, string, error) {
+	provenance *sbom.Provenance, buildLog buildLogger) (
+	*image.Image, string, error) {
 	img, err := buildImageFromManifest(client, manifestDir, request, bindMounts,
-		buildLog)
+		provenance, buildLog)
 	if err != nil {
 		return nil, "", err
 	}
@@ -365,5 +392,34 @@ func unpackImage(client *srpc.Client, streamName string,
 		return nil, err
 	}
 	fmt.Fprintf(buildLog, "Source image: %s\n", imageName)
-	return &sourceImageInfoType{sourceImage.Filter, sourceImage.Triggers}, nil
+	sourceImageInfo := &sourceImageInfoType{
+		filter:    sourceImage.Filter,
+		imageName: imageName,
+		triggers:  sourceImage.Triggers,
+	}
+	if sourceImage.Provenance != nil && sourceImage.Provenance.Object != nil {
+		sourceProvenance, err := readProvenance(objClient,
+			*sourceImage.Provenance.Object)
+		if err != nil {
+			fmt.Fprintf(buildLog,
+				"Error reading provenance for source image: %s\n", err)
+		} else {
+			sourceImageInfo.packagerType = sourceProvenance.PackagerType
+		}
+	}
+	return sourceImageInfo, nil
+}
+
+func readProvenance(objClient *objectclient.ObjectClient,
+	hashVal hash.Hash) (*sbom.Provenance, error) {
+	_, reader, err := objClient.GetObject(hashVal)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	doc, err := sbom.Read(reader)
+	if err != nil {
+		return nil, err
+	}
+	return &doc.Provenance, nil
 }
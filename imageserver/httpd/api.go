@@ -8,7 +8,8 @@ import (
 
 	"github.com/Symantec/Dominator/imageserver/scanner"
 	"github.com/Symantec/Dominator/lib/html"
-	"github.com/Symantec/Dominator/lib/objectserver/filesystem"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/objectserver"
 )
 
 type HtmlWriter interface {
@@ -19,17 +20,18 @@ var htmlWriters []HtmlWriter
 
 type state struct {
 	imageDataBase *scanner.ImageDataBase
-	objectServer  *filesystem.ObjectServer
+	objectServer  objectserver.ObjectGetter
 }
 
 func StartServer(portNum uint, imdb *scanner.ImageDataBase,
-	objSrv *filesystem.ObjectServer, daemon bool) error {
+	objSrv objectserver.ObjectGetter, daemon bool) error {
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", portNum))
 	if err != nil {
 		return err
 	}
 	myState := state{imageDataBase: imdb, objectServer: objSrv}
 	html.HandleFunc("/", statusHandler)
+	html.HandleFunc("/diffPackages", myState.diffPackagesHandler)
 	html.HandleFunc("/listBuildLog", myState.listBuildLogHandler)
 	html.HandleFunc("/listComputedInodes", myState.listComputedInodesHandler)
 	html.HandleFunc("/listDirectories", myState.listDirectoriesHandler)
@@ -37,6 +39,7 @@ func StartServer(portNum uint, imdb *scanner.ImageDataBase,
 	html.HandleFunc("/listImage", myState.listImageHandler)
 	html.HandleFunc("/listImages", myState.listImagesHandler)
 	html.HandleFunc("/listPackages", myState.listPackagesHandler)
+	html.HandleFunc("/listProvenance", myState.listProvenanceHandler)
 	html.HandleFunc("/listReleaseNotes", myState.listReleaseNotesHandler)
 	html.HandleFunc("/listTriggers", myState.listTriggersHandler)
 	html.HandleFunc("/showImage", myState.showImageHandler)
@@ -51,3 +54,10 @@ func StartServer(portNum uint, imdb *scanner.ImageDataBase,
 func AddHtmlWriter(htmlWriter HtmlWriter) {
 	htmlWriters = append(htmlWriters, htmlWriter)
 }
+
+// WatchVulnerabilityFeed will load and periodically reload the OSV
+// vulnerability feed from the specified file or URL. The feed is used to
+// report vulnerabilities when comparing the packages in images.
+func WatchVulnerabilityFeed(feedUrl string, logger log.DebugLogger) error {
+	return watchVulnerabilityFeed(feedUrl, logger)
+}
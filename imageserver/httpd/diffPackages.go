@@ -0,0 +1,129 @@
+package httpd
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	neturl "net/url"
+
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/image/packagediff"
+	"github.com/Symantec/Dominator/lib/url"
+)
+
+func formatSizeDelta(delta int64) string {
+	if delta < 0 {
+		return "-" + format.FormatBytes(uint64(-delta))
+	}
+	return "+" + format.FormatBytes(uint64(delta))
+}
+
+func (s state) diffPackagesHandler(w http.ResponseWriter, req *http.Request) {
+	parsedQuery := url.ParseQuery(req.URL)
+	leftName := parsedQuery.Table["left"]
+	rightName := parsedQuery.Table["right"]
+	if name, err := neturl.QueryUnescape(leftName); err == nil {
+		leftName = name
+	}
+	if name, err := neturl.QueryUnescape(rightName); err == nil {
+		rightName = name
+	}
+	leftImage := s.imageDataBase.GetImage(leftName)
+	rightImage := s.imageDataBase.GetImage(rightName)
+	if leftImage == nil || rightImage == nil {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	writer := bufio.NewWriter(w)
+	defer writer.Flush()
+	leftQuery := neturl.QueryEscape(leftName)
+	rightQuery := neturl.QueryEscape(rightName)
+	leftName = html.EscapeString(leftName)
+	rightName = html.EscapeString(rightName)
+	fmt.Fprintf(writer, "<title>packages %s -> %s</title>\n",
+		leftName, rightName)
+	fmt.Fprintln(writer, `<style>
+                          table, th, td {
+                          border-collapse: collapse;
+                          }
+                          </style>`)
+	fmt.Fprintln(writer, "<body>")
+	fmt.Fprintln(writer, "<h3>")
+	fmt.Fprintf(writer,
+		"Package changes from <a href=\"showImage?%s\">%s</a> to <a href=\"showImage?%s\">%s</a>\n",
+		leftQuery, leftName, rightQuery, rightName)
+	fmt.Fprintln(writer, "</h3>")
+	changes := packagediff.Diff(leftImage.Packages, rightImage.Packages)
+	fmt.Fprintf(writer, "Total size change: %s<br>\n",
+		formatSizeDelta(packagediff.TotalSizeDelta(changes)))
+	if len(changes) > 0 {
+		fmt.Fprintln(writer, `<table border="1" style="width:100%">`)
+		fmt.Fprintln(writer, "  <tr>")
+		fmt.Fprintln(writer, "    <th>Change</th>")
+		fmt.Fprintln(writer, "    <th>Name</th>")
+		fmt.Fprintln(writer, "    <th>Old Version</th>")
+		fmt.Fprintln(writer, "    <th>New Version</th>")
+		fmt.Fprintln(writer, "    <th>Size Change</th>")
+		fmt.Fprintln(writer, "  </tr>")
+		for _, change := range changes {
+			var oldVersion, newVersion string
+			if change.Old != nil {
+				oldVersion = change.Old.Version
+			}
+			if change.New != nil {
+				newVersion = change.New.Version
+			}
+			fmt.Fprintln(writer, "  <tr>")
+			fmt.Fprintf(writer, "    <td>%s</td>\n", change.Type())
+			fmt.Fprintf(writer, "    <td>%s</td>\n",
+				html.EscapeString(change.Name))
+			fmt.Fprintf(writer, "    <td>%s</td>\n",
+				html.EscapeString(oldVersion))
+			fmt.Fprintf(writer, "    <td>%s</td>\n",
+				html.EscapeString(newVersion))
+			fmt.Fprintf(writer, "    <td>%s</td>\n",
+				formatSizeDelta(change.SizeDelta()))
+			fmt.Fprintln(writer, "  </tr>")
+		}
+		fmt.Fprintln(writer, "</table>")
+	}
+	if db := getVulnerabilityDatabase(); db != nil {
+		report := packagediff.CheckVulnerabilities(leftImage.Packages,
+			rightImage.Packages, db)
+		writeFindings(writer, "Introduced vulnerabilities", report.Introduced)
+		writeFindings(writer, "Remaining vulnerabilities", report.Remaining)
+		writeFindings(writer, "Fixed vulnerabilities", report.Fixed)
+	}
+	fmt.Fprintln(writer, "</body>")
+}
+
+func writeFindings(writer io.Writer, title string,
+	findings []packagediff.Finding) {
+	fmt.Fprintf(writer, "<h3>%s: %d</h3>\n", title, len(findings))
+	if len(findings) < 1 {
+		return
+	}
+	fmt.Fprintln(writer, `<table border="1" style="width:100%">`)
+	fmt.Fprintln(writer, "  <tr>")
+	fmt.Fprintln(writer, "    <th>ID</th>")
+	fmt.Fprintln(writer, "    <th>Package</th>")
+	fmt.Fprintln(writer, "    <th>Version</th>")
+	fmt.Fprintln(writer, "    <th>Summary</th>")
+	fmt.Fprintln(writer, "  </tr>")
+	for _, finding := range findings {
+		fmt.Fprintln(writer, "  <tr>")
+		fmt.Fprintf(writer, "    <td>%s</td>\n",
+			html.EscapeString(finding.Vulnerability.Id))
+		fmt.Fprintf(writer, "    <td>%s</td>\n",
+			html.EscapeString(finding.Package.Name))
+		fmt.Fprintf(writer, "    <td>%s</td>\n",
+			html.EscapeString(finding.Package.Version))
+		fmt.Fprintf(writer, "    <td>%s</td>\n",
+			html.EscapeString(finding.Vulnerability.Summary))
+		fmt.Fprintln(writer, "  </tr>")
+	}
+	fmt.Fprintln(writer, "</table>")
+}
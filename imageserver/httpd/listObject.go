@@ -5,10 +5,10 @@ import (
 	"io"
 
 	"github.com/Symantec/Dominator/lib/hash"
-	"github.com/Symantec/Dominator/lib/objectserver/filesystem"
+	"github.com/Symantec/Dominator/lib/objectserver"
 )
 
-func listObject(writer io.Writer, objSrv *filesystem.ObjectServer,
+func listObject(writer io.Writer, objSrv objectserver.ObjectGetter,
 	hashP *hash.Hash) {
 	_, reader, err := objSrv.GetObject(*hashP)
 	if err != nil {
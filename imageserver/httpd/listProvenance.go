@@ -0,0 +1,30 @@
+package httpd
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+func (s state) listProvenanceHandler(w http.ResponseWriter,
+	req *http.Request) {
+	imageName := req.URL.RawQuery
+	image := s.imageDataBase.GetImage(imageName)
+	if image == nil {
+		http.Error(w, "unknown image: "+imageName, http.StatusNotFound)
+		return
+	}
+	if image.Provenance == nil || image.Provenance.Object == nil {
+		http.Error(w, "no provenance data for image: "+imageName,
+			http.StatusNotFound)
+		return
+	}
+	_, reader, err := s.objectServer.GetObject(*image.Provenance.Object)
+	if err != nil {
+		http.Error(w, fmt.Sprint(err), http.StatusInternalServerError)
+		return
+	}
+	defer reader.Close()
+	w.Header().Set("Content-Type", "application/json")
+	io.Copy(w, reader)
+}
@@ -5,10 +5,13 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"path"
 	"time"
 
 	"github.com/Symantec/Dominator/lib/format"
 	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/verstr"
 )
 
 var timeFormat string = "02 Jan 2006 15:04:05.99 MST"
@@ -17,6 +20,9 @@ func (s state) showImageHandler(w http.ResponseWriter, req *http.Request) {
 	writer := bufio.NewWriter(w)
 	defer writer.Flush()
 	imageName := req.URL.RawQuery
+	if name, err := url.PathUnescape(imageName); err == nil {
+		imageName = name
+	}
 	fmt.Fprintf(writer, "<title>image %s</title>\n", imageName)
 	fmt.Fprintln(writer, "<body>")
 	fmt.Fprintln(writer, "<h3>")
@@ -62,6 +68,8 @@ func (s state) showImageHandler(w http.ResponseWriter, req *http.Request) {
 		"listReleaseNotes")
 	showAnnotation(writer, image.BuildLog, imageName, "Build log",
 		"listBuildLog")
+	showAnnotation(writer, image.Provenance, imageName, "Provenance (SBOM)",
+		"listProvenance")
 	if image.CreatedBy != "" {
 		fmt.Fprintf(writer, "Created by: %s\n<br>", image.CreatedBy)
 	}
@@ -74,10 +82,35 @@ func (s state) showImageHandler(w http.ResponseWriter, req *http.Request) {
 		fmt.Fprintf(writer,
 			"Packages: <a href=\"listPackages?%s\">%d</a><br>\n",
 			imageName, len(image.Packages))
+		if previousName := s.findPreviousImage(imageName); previousName != "" {
+			fmt.Fprintf(writer,
+				"Package changes since previous image: <a href=\"diffPackages?left=%s&right=%s\">%s</a><br>\n",
+				url.QueryEscape(previousName), url.QueryEscape(imageName),
+				previousName)
+		}
 	}
 	fmt.Fprintln(writer, "</body>")
 }
 
+// findPreviousImage returns the name of the image in the same directory which
+// precedes imageName, or "" if there is none.
+func (s state) findPreviousImage(imageName string) string {
+	dirname := path.Dir(imageName)
+	var names []string
+	for _, name := range s.imageDataBase.ListImages() {
+		if path.Dir(name) == dirname {
+			names = append(names, name)
+		}
+	}
+	verstr.Sort(names)
+	for index, name := range names {
+		if name == imageName && index > 0 {
+			return names[index-1]
+		}
+	}
+	return ""
+}
+
 func showAnnotation(writer io.Writer, annotation *image.Annotation,
 	imageName string, linkName string, baseURL string) {
 	if annotation == nil {
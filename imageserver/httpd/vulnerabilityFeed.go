@@ -0,0 +1,47 @@
+package httpd
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/configwatch"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/osv"
+)
+
+const vulnerabilityFeedCheckInterval = time.Hour
+
+var (
+	vulnerabilityDatabaseLock sync.RWMutex
+	vulnerabilityDatabase     *osv.Database
+)
+
+func getVulnerabilityDatabase() *osv.Database {
+	vulnerabilityDatabaseLock.RLock()
+	defer vulnerabilityDatabaseLock.RUnlock()
+	return vulnerabilityDatabase
+}
+
+func watchVulnerabilityFeed(feedUrl string, logger log.DebugLogger) error {
+	feedChannel, err := configwatch.Watch(feedUrl,
+		vulnerabilityFeedCheckInterval,
+		func(reader io.Reader) (interface{}, error) {
+			return osv.Read(reader)
+		},
+		logger)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for db := range feedChannel {
+			db := db.(*osv.Database)
+			logger.Printf("Loaded vulnerability feed with %d records\n",
+				db.Len())
+			vulnerabilityDatabaseLock.Lock()
+			vulnerabilityDatabase = db
+			vulnerabilityDatabaseLock.Unlock()
+		}
+	}()
+	return nil
+}
@@ -29,6 +29,9 @@ func (t *srpcType) AddImageTrusted(conn *srpc.Conn,
 	if request.Image.FileSystem == nil {
 		return errors.New("nil file-system")
 	}
+	if err := t.trustPolicy.Verify(request.Image); err != nil {
+		return errors.New("untrusted image: " + err.Error())
+	}
 	err := request.Image.VerifyObjects(t.imageDataBase.ObjectServer())
 	if err != nil {
 		return err
@@ -7,6 +7,7 @@ import (
 	"sync"
 
 	"github.com/Symantec/Dominator/imageserver/scanner"
+	"github.com/Symantec/Dominator/lib/image/trust"
 	"github.com/Symantec/Dominator/lib/log"
 	"github.com/Symantec/Dominator/lib/objectserver"
 	"github.com/Symantec/Dominator/lib/srpc"
@@ -17,6 +18,8 @@ var (
 		"If true, replicate expiring images when in archive mode")
 	archiveMode = flag.Bool("archiveMode", false,
 		"If true, disable delete operations and require update server")
+	trustedImageKeysFile = flag.String("trustedImageKeysFile", "",
+		"Filename of PEM public keys. If specified, only accept images signed by one of the keys")
 )
 
 type srpcType struct {
@@ -31,6 +34,7 @@ type srpcType struct {
 	numReplicationClients     uint
 	imagesBeingInjectedLock   sync.Mutex // Protect imagesBeingInjected.
 	imagesBeingInjected       map[string]struct{}
+	trustPolicy               *trust.Policy
 }
 
 type htmlWriter srpcType
@@ -48,6 +52,14 @@ func Setup(imdb *scanner.ImageDataBase, replicationMaster string,
 	if *archiveMode && replicationMaster == "" {
 		return nil, errors.New("replication master required in archive mode")
 	}
+	var trustPolicy *trust.Policy
+	if *trustedImageKeysFile != "" {
+		var err error
+		trustPolicy, err = trust.LoadPolicy(*trustedImageKeysFile)
+		if err != nil {
+			return nil, err
+		}
+	}
 	finishedReplication := make(chan struct{})
 	srpcObj := &srpcType{
 		imageDataBase:       imdb,
@@ -58,6 +70,7 @@ func Setup(imdb *scanner.ImageDataBase, replicationMaster string,
 		logger:              logger,
 		archiveMode:         *archiveMode,
 		imagesBeingInjected: make(map[string]struct{}),
+		trustPolicy:         trustPolicy,
 	}
 	srpc.RegisterNameWithOptions("ImageServer", srpcObj, srpc.ReceiverOptions{
 		PublicMethods: []string{
@@ -0,0 +1,121 @@
+package rpcd
+
+import (
+	"errors"
+	"flag"
+	"io"
+	"path"
+
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/log"
+	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
+)
+
+var (
+	minimumDeltaReplicationSize = flag.Uint64("minimumDeltaReplicationSize",
+		1<<20,
+		"Minimum object size to replicate changed chunks only (0: never)")
+)
+
+// getObjectDeltas will fetch missing objects for an image using delta
+// transfers, using the objects in the latest image in the same directory as
+// the bases. Failures are logged and ignored, since the caller will fetch any
+// remaining missing objects in full.
+func (t *srpcType) getObjectDeltas(name string, img *image.Image,
+	objClient *objectclient.ObjectClient, logger log.DebugLogger) {
+	if img.FileSystem == nil {
+		return
+	}
+	baseName, err := t.imageDataBase.FindLatestImage(path.Dir(name), false)
+	if err != nil || baseName == "" {
+		return
+	}
+	baseImage := t.imageDataBase.GetImage(baseName)
+	if baseImage == nil || baseImage.FileSystem == nil {
+		return
+	}
+	missingObjects, err := img.ListMissingObjects(t.objSrv)
+	if err != nil {
+		logger.Println(err)
+		return
+	}
+	missing := make(map[hash.Hash]struct{}, len(missingObjects))
+	for _, hashVal := range missingObjects {
+		missing[hashVal] = struct{}{}
+	}
+	baseFilenameToInode := baseImage.FileSystem.FilenameToInodeTable()
+	baseInodeTable := baseImage.FileSystem.InodeTable
+	var numObjects, numRead, totalLength uint64
+	for inum, filenames := range img.FileSystem.InodeToFilenamesTable() {
+		inode, ok := img.FileSystem.InodeTable[inum].(*filesystem.RegularInode)
+		if !ok || inode.Size < *minimumDeltaReplicationSize {
+			continue
+		}
+		if _, ok := missing[inode.Hash]; !ok {
+			continue
+		}
+		for _, filename := range filenames {
+			baseInum, ok := baseFilenameToInode[filename]
+			if !ok {
+				continue
+			}
+			baseInode, ok := baseInodeTable[baseInum].(*filesystem.RegularInode)
+			if !ok || baseInode.Size < 1 {
+				continue
+			}
+			delete(missing, inode.Hash)
+			length, nRead, err := t.getObjectDelta(objClient, inode.Hash,
+				inode.Size, baseInode.Hash)
+			if err != nil {
+				logger.Printf("delta failed for: %s: %s\n", filename, err)
+			} else {
+				numObjects++
+				numRead += nRead
+				totalLength += length
+			}
+			break
+		}
+	}
+	if numObjects > 0 {
+		logger.Printf(
+			"replicated %d objects (%s) from %s with deltas, read: %s\n",
+			numObjects, format.FormatBytes(totalLength), baseName,
+			format.FormatBytes(numRead))
+	}
+}
+
+// getObjectDelta will fetch an object of the specified size using a delta
+// transfer, streaming it directly into the object server.
+func (t *srpcType) getObjectDelta(objClient *objectclient.ObjectClient,
+	hashVal hash.Hash, size uint64, baseHash hash.Hash) (
+	uint64, uint64, error) {
+	baseSize, base, err := t.objSrv.GetObject(baseHash)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer base.Close()
+	baseReaderAt, ok := base.(io.ReaderAt)
+	if !ok {
+		return 0, 0, errors.New("base object is compressed")
+	}
+	reader, writer := io.Pipe()
+	errorChannel := make(chan error, 1)
+	go func() {
+		_, _, err := t.objSrv.AddObject(reader, size, &hashVal)
+		reader.CloseWithError(err) // Unblock the writer if AddObject failed.
+		errorChannel <- err
+	}()
+	length, stats, err := objClient.GetObjectDelta(hashVal, baseReaderAt,
+		baseSize, writer, nil)
+	writer.CloseWithError(err)
+	if addErr := <-errorChannel; err == nil {
+		err = addErr
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return length, stats.NumRead, nil
+}
@@ -2,6 +2,7 @@ package rpcd
 
 import (
 	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"time"
@@ -14,8 +15,16 @@ import (
 	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
 	"github.com/Symantec/Dominator/lib/srpc"
 	"github.com/Symantec/Dominator/proto/imageserver"
+	"github.com/Symantec/Dominator/proto/objectserver"
 )
 
+var replicationCompression objectserver.Compression
+
+func init() {
+	flag.Var(&replicationCompression, "replicationCompression",
+		"Compression to request when replicating objects: none or gzip")
+}
+
 func (t *srpcType) replicator(finishedReplication chan<- struct{}) {
 	initialTimeout := time.Second * 15
 	timeout := initialTimeout
@@ -193,9 +202,14 @@ func (t *srpcType) addImage(name string) error {
 		logger.Println("ignoring expiring image in archiver mode")
 		return nil
 	}
+	if err := t.trustPolicy.Verify(img); err != nil {
+		logger.Printf("ignoring untrusted image: %s\n", err)
+		return nil
+	}
 	img.FileSystem.RebuildInodePointers()
 	err = t.imageDataBase.DoWithPendingImage(img, func() error {
-		if err := t.getMissingObjects(img, client, logger); err != nil {
+		if err := t.getMissingObjects(name, img, client,
+			logger); err != nil {
 			client.Close()
 			return err
 		}
@@ -220,9 +234,13 @@ func (t *srpcType) checkImageBeingInjected(name string) bool {
 	return ok
 }
 
-func (t *srpcType) getMissingObjects(img *image.Image, client *srpc.Client,
-	logger log.DebugLogger) error {
+func (t *srpcType) getMissingObjects(name string, img *image.Image,
+	client *srpc.Client, logger log.DebugLogger) error {
 	objClient := objectclient.AttachObjectClient(client)
 	defer objClient.Close()
+	objClient.SetCompression(replicationCompression)
+	if *minimumDeltaReplicationSize > 0 {
+		t.getObjectDeltas(name, img, objClient, logger)
+	}
 	return img.GetMissingObjects(t.objSrv, objClient, logger)
 }
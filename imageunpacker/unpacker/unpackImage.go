@@ -137,7 +137,7 @@ func (stream *streamManagerState) unpack(imageName string,
 	var request subproto.UpdateRequest
 	domlib.BuildUpdateRequest(subObj, desiredImage, &request, true, false,
 		stream.unpacker.logger)
-	_, _, err = sublib.Update(request, mountPoint, objectsDir, nil, nil, nil,
+	_, err = sublib.Update(request, mountPoint, objectsDir, nil, nil, nil,
 		stream.unpacker.logger)
 	writeImageName(imageName, mountPoint)
 	streamInfo.status = unpackproto.StatusStreamMounted
@@ -0,0 +1,37 @@
+/*
+	Package cron parses and evaluates cron-like schedule expressions.
+
+	An expression has five whitespace-separated fields: minute (0-59), hour
+	(0-23), day of month (1-31), month (1-12 or JAN-DEC) and day of week (0-7
+	or SUN-SAT, where both 0 and 7 are Sunday). Each field may be "*", a value,
+	a range ("a-b") or a comma-separated list of these, optionally followed by a
+	step ("/n"). As with cron, if both the day of month and day of week are
+	restricted, a time matches if either matches.
+*/
+package cron
+
+import (
+	"time"
+)
+
+type Schedule struct {
+	minutes     uint64
+	hours       uint64
+	daysOfMonth uint64
+	months      uint64
+	daysOfWeek  uint64
+	domStar     bool
+	dowStar     bool
+}
+
+// Parse will parse a schedule expression.
+func Parse(expression string) (*Schedule, error) {
+	return parse(expression)
+}
+
+// Next returns the first time after t which matches the schedule, evaluated in
+// the location of t. If there is no match within 5 years, the zero time is
+// returned.
+func (s *Schedule) Next(t time.Time) time.Time {
+	return s.next(t)
+}
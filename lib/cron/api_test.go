@@ -0,0 +1,75 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	start := time.Date(2018, time.March, 15, 10, 30, 0, 0, time.UTC)
+	tests := []struct {
+		expression string
+		expected   time.Time
+	}{
+		{"* * * * *", time.Date(2018, 3, 15, 10, 31, 0, 0, time.UTC)},
+		{"0 * * * *", time.Date(2018, 3, 15, 11, 0, 0, 0, time.UTC)},
+		{"*/20 * * * *", time.Date(2018, 3, 15, 10, 40, 0, 0, time.UTC)},
+		{"0 2 * * *", time.Date(2018, 3, 16, 2, 0, 0, 0, time.UTC)},
+		{"0 2 * * sat", time.Date(2018, 3, 17, 2, 0, 0, 0, time.UTC)},
+		{"0 2 * * 7", time.Date(2018, 3, 18, 2, 0, 0, 0, time.UTC)},
+		{"0 22 * * MON-FRI", time.Date(2018, 3, 15, 22, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2018, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * 1", time.Date(2018, 3, 19, 0, 0, 0, 0, time.UTC)},
+		{"15,45 9-11 * * *", time.Date(2018, 3, 15, 10, 45, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		schedule, err := Parse(test.expression)
+		if err != nil {
+			t.Errorf("%s: %s", test.expression, err)
+			continue
+		}
+		if next := schedule.Next(start); !next.Equal(test.expected) {
+			t.Errorf("%s: expected: %s, got: %s",
+				test.expression, test.expected, next)
+		}
+	}
+	if schedule, err := Parse("0 0 30 2 *"); err != nil {
+		t.Error(err)
+	} else if next := schedule.Next(start); !next.IsZero() {
+		t.Errorf("expected no match, got: %s", next)
+	}
+}
+
+func TestNextInLocation(t *testing.T) {
+	location := time.FixedZone("UTC-8", -8*3600)
+	schedule, err := Parse("0 2 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2018, time.March, 15, 10, 30, 0, 0, time.UTC)
+	expected := time.Date(2018, time.March, 16, 10, 0, 0, 0, time.UTC)
+	if next := schedule.Next(start.In(location)); !next.Equal(expected) {
+		t.Errorf("expected: %s, got: %s", expected, next)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expression := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * FOO *",
+	} {
+		if _, err := Parse(expression); err == nil {
+			t.Errorf("\"%s\": expected error", expression)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type fieldType struct {
+	name  string
+	min   uint
+	max   uint
+	names map[string]uint
+}
+
+var (
+	minuteField = fieldType{name: "minute", min: 0, max: 59}
+	hourField   = fieldType{name: "hour", min: 0, max: 23}
+	domField    = fieldType{name: "day of month", min: 1, max: 31}
+	monthField  = fieldType{name: "month", min: 1, max: 12,
+		names: map[string]uint{
+			"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6,
+			"JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12,
+		}}
+	dowField = fieldType{name: "day of week", min: 0, max: 7,
+		names: map[string]uint{
+			"SUN": 0, "MON": 1, "TUE": 2, "WED": 3, "THU": 4, "FRI": 5,
+			"SAT": 6,
+		}}
+)
+
+func parse(expression string) (*Schedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, got %d: \"%s\"",
+			len(fields), expression)
+	}
+	var schedule Schedule
+	var err error
+	if schedule.minutes, _, err = minuteField.parse(fields[0]); err != nil {
+		return nil, err
+	}
+	if schedule.hours, _, err = hourField.parse(fields[1]); err != nil {
+		return nil, err
+	}
+	schedule.daysOfMonth, schedule.domStar, err = domField.parse(fields[2])
+	if err != nil {
+		return nil, err
+	}
+	if schedule.months, _, err = monthField.parse(fields[3]); err != nil {
+		return nil, err
+	}
+	schedule.daysOfWeek, schedule.dowStar, err = dowField.parse(fields[4])
+	if err != nil {
+		return nil, err
+	}
+	if schedule.daysOfWeek&(1<<7) != 0 { // Sunday is both 0 and 7.
+		schedule.daysOfWeek |= 1
+	}
+	return &schedule, nil
+}
+
+// parse returns the bitmask of matching values and whether the field is "*".
+func (field fieldType) parse(text string) (uint64, bool, error) {
+	var bits uint64
+	for _, item := range strings.Split(text, ",") {
+		step := uint(1)
+		if index := strings.IndexByte(item, '/'); index >= 0 {
+			value, err := strconv.ParseUint(item[index+1:], 10, 8)
+			if err != nil || value < 1 {
+				return 0, false, fmt.Errorf("bad step in %s field: \"%s\"",
+					field.name, item)
+			}
+			step = uint(value)
+			item = item[:index]
+		}
+		var first, last uint
+		if item == "*" {
+			first, last = field.min, field.max
+		} else if index := strings.IndexByte(item, '-'); index >= 0 {
+			var err error
+			if first, err = field.parseValue(item[:index]); err != nil {
+				return 0, false, err
+			}
+			if last, err = field.parseValue(item[index+1:]); err != nil {
+				return 0, false, err
+			}
+			if last < first {
+				return 0, false, fmt.Errorf("bad range in %s field: \"%s\"",
+					field.name, item)
+			}
+		} else {
+			value, err := field.parseValue(item)
+			if err != nil {
+				return 0, false, err
+			}
+			first = value
+			last = value
+			if step > 1 {
+				last = field.max
+			}
+		}
+		for value := first; value <= last; value += step {
+			bits |= 1 << value
+		}
+	}
+	return bits, text == "*", nil
+}
+
+func (field fieldType) parseValue(text string) (uint, error) {
+	if value, ok := field.names[strings.ToUpper(text)]; ok {
+		return value, nil
+	}
+	value, err := strconv.ParseUint(text, 10, 8)
+	if err != nil || uint(value) < field.min || uint(value) > field.max {
+		return 0, fmt.Errorf("bad value in %s field: \"%s\"", field.name, text)
+	}
+	return uint(value), nil
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.daysOfMonth&(1<<uint(t.Day())) != 0
+	dowMatch := s.daysOfWeek&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+func (s *Schedule) next(t time.Time) time.Time {
+	location := t.Location()
+	// Start at the next whole minute.
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	yearLimit := t.Year() + 5
+wrap:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+	for s.months&(1<<uint(t.Month())) == 0 {
+		t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, location)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+	for !s.dayMatches(t) {
+		month := t.Month()
+		t = time.Date(t.Year(), month, t.Day()+1, 0, 0, 0, 0, location)
+		if t.Month() != month {
+			goto wrap
+		}
+	}
+	for s.hours&(1<<uint(t.Hour())) == 0 {
+		day := t.Day()
+		t = time.Date(t.Year(), t.Month(), day, t.Hour()+1, 0, 0, 0,
+			location)
+		if t.Day() != day {
+			goto wrap
+		}
+	}
+	for s.minutes&(1<<uint(t.Minute())) == 0 {
+		hour := t.Hour()
+		t = t.Truncate(time.Minute).Add(time.Minute)
+		if t.Hour() != hour {
+			goto wrap
+		}
+	}
+	return t
+}
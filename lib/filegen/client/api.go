@@ -2,6 +2,7 @@ package client
 
 import (
 	"sync"
+	"time"
 
 	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/log"
@@ -75,6 +76,15 @@ func (m *Manager) Add(machine Machine, size uint) <-chan []proto.FileInfo {
 	return updateChannel
 }
 
+// Generate will connect directly to the sources for the computed files of a
+// machine and will return the file information for the generated files. Object
+// data are not fetched. This is intended for one-off queries which should not
+// disturb the machines managed by a Manager.
+func Generate(machine Machine, timeout time.Duration) (
+	[]proto.FileInfo, error) {
+	return generate(machine, timeout)
+}
+
 // Remove will remove a machine from the Manager. The corresponding file info
 // channel will be closed.
 func (m *Manager) Remove(hostname string) {
@@ -0,0 +1,64 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/mdb"
+	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/filegenerator"
+)
+
+func generate(machine Machine, timeout time.Duration) (
+	[]proto.FileInfo, error) {
+	mach := buildMachine(machine)
+	var fileInfos []proto.FileInfo
+	for sourceName, pathnames := range mach.sourceToPaths {
+		files, err := generateFromSource(sourceName, mach.machine, pathnames,
+			timeout)
+		if err != nil {
+			return nil, fmt.Errorf("error generating from: %s: %s",
+				sourceName, err)
+		}
+		fileInfos = append(fileInfos, files...)
+	}
+	return fileInfos, nil
+}
+
+func generateFromSource(sourceName string, machine mdb.Machine,
+	pathnames []string, timeout time.Duration) ([]proto.FileInfo, error) {
+	client, err := srpc.DialHTTP("tcp", sourceName, timeout)
+	if err != nil {
+		return nil, err
+	}
+	var closeOnce sync.Once
+	closeClient := func() { closeOnce.Do(func() { client.Close() }) }
+	defer closeClient()
+	timer := time.AfterFunc(timeout, closeClient) // Unblock Decode.
+	defer timer.Stop()
+	conn, err := client.Call("FileGenerator.Connect")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	request := proto.ClientRequest{
+		YieldRequest: &proto.YieldRequest{machine, pathnames}}
+	if err := conn.Encode(request); err != nil {
+		return nil, err
+	}
+	if err := conn.Flush(); err != nil {
+		return nil, err
+	}
+	for {
+		var message proto.ServerMessage
+		if err := conn.Decode(&message); err != nil {
+			return nil, err
+		}
+		// Skip messages for other machines which the source may broadcast.
+		if msg := message.YieldResponse; msg != nil &&
+			msg.Hostname == machine.Hostname {
+			return msg.Files, nil
+		}
+	}
+}
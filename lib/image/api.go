@@ -35,14 +35,31 @@ type Image struct {
 	CreatedOn    time.Time
 	ExpiresAt    time.Time
 	Packages     []Package
+	Signatures   []Signature
+	Provenance   *Annotation // CycloneDX JSON SBOM (see the sbom package).
 }
 
 type Package struct {
+	Digest  string // Optional. Format: algorithm:hex, e.g. SHA-256:abcd...
 	Name    string
 	Size    uint64 // Bytes.
 	Version string
 }
 
+// Signature is a detached signature over the image signature digest (see the
+// ComputeSignatureDigest method).
+type Signature struct {
+	KeyId     string // Hex SHA-256 fingerprint of the PKIX public key.
+	Signature []byte
+}
+
+// ComputeSignatureDigest will compute the digest of the image which is signed.
+// The digest covers the file-system, filter, triggers and annotations but not
+// the metadata added by the imageserver (creation time, expiration and so on).
+func (image *Image) ComputeSignatureDigest() ([]byte, error) {
+	return image.computeSignatureDigest()
+}
+
 // ForEachObject will call objectFunc for all objects (including those for
 // annotations) for the image. If objectFunc returns a non-nil error, processing
 // stops and the error is returned.
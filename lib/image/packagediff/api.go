@@ -0,0 +1,63 @@
+/*
+	Package packagediff compares the package lists of two images.
+*/
+package packagediff
+
+import (
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/osv"
+)
+
+// Change describes a package which was added, removed or changed.
+type Change struct {
+	Name string
+	Old  *image.Package // nil if the package was added.
+	New  *image.Package // nil if the package was removed.
+}
+
+// Finding is a vulnerability which affects a package.
+type Finding struct {
+	Package       image.Package
+	Vulnerability osv.Vulnerability
+}
+
+// VulnerabilityReport describes the changes in known vulnerabilities between
+// two images.
+type VulnerabilityReport struct {
+	Fixed      []Finding // Only present in the left image.
+	Introduced []Finding // Only present in the right image.
+	Remaining  []Finding // Present in both images (reported for right).
+}
+
+// Diff returns the changes required to get from the left to the right package
+// list, sorted by package name.
+func Diff(left, right []image.Package) []Change {
+	return diff(left, right)
+}
+
+// CheckVulnerabilities will match the left and right package lists against
+// the vulnerability database.
+func CheckVulnerabilities(left, right []image.Package,
+	db *osv.Database) VulnerabilityReport {
+	return checkVulnerabilities(left, right, db)
+}
+
+// Type returns the type of change: "added", "removed", "upgraded",
+// "downgraded", "changed" (if the versions cannot be ordered) or "rebuilt".
+func (change Change) Type() string {
+	return change.changeType()
+}
+
+// SizeDelta returns the change in size for the package.
+func (change Change) SizeDelta() int64 {
+	return change.sizeDelta()
+}
+
+// TotalSizeDelta returns the total change in size for the changes.
+func TotalSizeDelta(changes []Change) int64 {
+	var total int64
+	for _, change := range changes {
+		total += change.sizeDelta()
+	}
+	return total
+}
@@ -0,0 +1,147 @@
+package packagediff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/osv"
+)
+
+const testFeed = `[
+  {
+    "id": "TEST-1",
+    "summary": "fixed in 1.5",
+    "affected": [{
+      "package": {"ecosystem": "Debian", "name": "openssl"},
+      "ranges": [{
+        "type": "ECOSYSTEM",
+        "events": [{"introduced": "1.0"}, {"fixed": "1.5"}]
+      }]
+    }]
+  },
+  {
+    "id": "TEST-2",
+    "summary": "all bash",
+    "affected": [{
+      "package": {"ecosystem": "Debian", "name": "bash"},
+      "ranges": [{
+        "type": "ECOSYSTEM",
+        "events": [{"introduced": "0"}]
+      }]
+    }]
+  },
+  {
+    "id": "TEST-3",
+    "summary": "new curl",
+    "affected": [{
+      "package": {"ecosystem": "Debian", "name": "curl"},
+      "ranges": [{
+        "type": "ECOSYSTEM",
+        "events": [{"introduced": "8.0"}]
+      }]
+    }]
+  }
+]`
+
+var (
+	leftPackages = []image.Package{
+		{Name: "bash", Version: "4.4", Size: 100},
+		{Name: "curl", Version: "7.0", Size: 50},
+		{Name: "openssl", Version: "1.1", Size: 200},
+		{Name: "removed", Version: "1.0", Size: 10},
+		{Name: "rebuilt", Version: "1.0", Digest: "a", Size: 10},
+	}
+	rightPackages = []image.Package{
+		{Name: "added", Version: "2.0", Size: 30},
+		{Name: "bash", Version: "4.4", Size: 100},
+		{Name: "curl", Version: "8.1", Size: 70},
+		{Name: "openssl", Version: "1.5", Size: 220},
+		{Name: "rebuilt", Version: "1.0", Digest: "b", Size: 12},
+	}
+)
+
+func TestDiff(t *testing.T) {
+	changes := Diff(leftPackages, rightPackages)
+	expected := []struct {
+		name       string
+		oldVersion string
+		newVersion string
+		changeType string
+		sizeDelta  int64
+	}{
+		{"added", "", "2.0", "added", 30},
+		{"curl", "7.0", "8.1", "upgraded", 20},
+		{"openssl", "1.1", "1.5", "upgraded", 20},
+		{"rebuilt", "1.0", "1.0", "rebuilt", 2},
+		{"removed", "1.0", "", "removed", -10},
+	}
+	if len(changes) != len(expected) {
+		t.Fatalf("expected %d changes, got: %v", len(expected), changes)
+	}
+	for index, change := range changes {
+		want := expected[index]
+		var oldVersion, newVersion string
+		if change.Old != nil {
+			oldVersion = change.Old.Version
+		}
+		if change.New != nil {
+			newVersion = change.New.Version
+		}
+		if change.Name != want.name || oldVersion != want.oldVersion ||
+			newVersion != want.newVersion {
+			t.Errorf("change: %d: got: %s %s->%s, expected: %s %s->%s",
+				index, change.Name, oldVersion, newVersion,
+				want.name, want.oldVersion, want.newVersion)
+		}
+		if changeType := change.Type(); changeType != want.changeType {
+			t.Errorf("%s: type: %s, expected: %s",
+				change.Name, changeType, want.changeType)
+		}
+		if delta := change.SizeDelta(); delta != want.sizeDelta {
+			t.Errorf("%s: size delta: %d, expected: %d",
+				change.Name, delta, want.sizeDelta)
+		}
+	}
+	if total := TotalSizeDelta(changes); total != 62 {
+		t.Errorf("total size delta: %d, expected: 62", total)
+	}
+	if changes := Diff(leftPackages, leftPackages); len(changes) != 0 {
+		t.Errorf("changes for identical lists: %v", changes)
+	}
+	changes = Diff(rightPackages, leftPackages)
+	if changeType := changes[1].Type(); changeType != "downgraded" {
+		t.Errorf("%s: type: %s, expected: downgraded",
+			changes[1].Name, changeType)
+	}
+}
+
+func listFindings(findings []Finding) string {
+	var names []string
+	for _, finding := range findings {
+		names = append(names,
+			finding.Package.Name+":"+finding.Vulnerability.Id)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestCheckVulnerabilities(t *testing.T) {
+	db, err := osv.Read(strings.NewReader(testFeed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := CheckVulnerabilities(leftPackages, rightPackages, db)
+	if got := listFindings(report.Fixed); got != "openssl:TEST-1" {
+		t.Errorf("fixed: %s", got)
+	}
+	if got := listFindings(report.Introduced); got != "curl:TEST-3" {
+		t.Errorf("introduced: %s", got)
+	}
+	if got := listFindings(report.Remaining); got != "bash:TEST-2" {
+		t.Errorf("remaining: %s", got)
+	}
+	report = CheckVulnerabilities(leftPackages, rightPackages, nil)
+	if len(report.Fixed)+len(report.Introduced)+len(report.Remaining) > 0 {
+		t.Error("findings without a database")
+	}
+}
@@ -0,0 +1,124 @@
+package packagediff
+
+import (
+	"sort"
+
+	"github.com/Symantec/Dominator/lib/image"
+	"github.com/Symantec/Dominator/lib/osv"
+	"github.com/Symantec/Dominator/lib/verstr"
+)
+
+func makePackageMap(packages []image.Package) map[string]*image.Package {
+	packageMap := make(map[string]*image.Package, len(packages))
+	for index := range packages {
+		packageMap[packages[index].Name] = &packages[index]
+	}
+	return packageMap
+}
+
+func diff(left, right []image.Package) []Change {
+	leftPackages := makePackageMap(left)
+	rightPackages := makePackageMap(right)
+	var changes []Change
+	for name, leftPkg := range leftPackages {
+		rightPkg := rightPackages[name]
+		if rightPkg == nil {
+			changes = append(changes, Change{Name: name, Old: leftPkg})
+		} else if leftPkg.Version != rightPkg.Version ||
+			leftPkg.Digest != rightPkg.Digest {
+			changes = append(changes,
+				Change{Name: name, Old: leftPkg, New: rightPkg})
+		}
+	}
+	for name, rightPkg := range rightPackages {
+		if _, ok := leftPackages[name]; !ok {
+			changes = append(changes, Change{Name: name, New: rightPkg})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes
+}
+
+func checkVulnerabilities(left, right []image.Package,
+	db *osv.Database) VulnerabilityReport {
+	leftFindings := findVulnerabilities(left, db)
+	rightFindings := findVulnerabilities(right, db)
+	var report VulnerabilityReport
+	for _, finding := range leftFindings {
+		if _, ok := rightFindings[findingKey(finding)]; !ok {
+			report.Fixed = append(report.Fixed, finding)
+		}
+	}
+	for key, finding := range rightFindings {
+		if _, ok := leftFindings[key]; ok {
+			report.Remaining = append(report.Remaining, finding)
+		} else {
+			report.Introduced = append(report.Introduced, finding)
+		}
+	}
+	sortFindings(report.Fixed)
+	sortFindings(report.Introduced)
+	sortFindings(report.Remaining)
+	return report
+}
+
+// findingKey identifies a vulnerability in a package independently of the
+// package version.
+func findingKey(finding Finding) string {
+	return finding.Package.Name + " " + finding.Vulnerability.Id
+}
+
+func findVulnerabilities(packages []image.Package,
+	db *osv.Database) map[string]Finding {
+	findings := make(map[string]Finding)
+	for _, pkg := range packages {
+		for _, vulnerability := range db.Match(pkg.Name, pkg.Version) {
+			finding := Finding{Package: pkg, Vulnerability: vulnerability}
+			findings[findingKey(finding)] = finding
+		}
+	}
+	return findings
+}
+
+func sortFindings(findings []Finding) {
+	sort.Slice(findings, func(i, j int) bool {
+		if findings[i].Package.Name != findings[j].Package.Name {
+			return findings[i].Package.Name < findings[j].Package.Name
+		}
+		return findings[i].Vulnerability.Id < findings[j].Vulnerability.Id
+	})
+}
+
+func (change Change) changeType() string {
+	if change.Old == nil {
+		return "added"
+	}
+	if change.New == nil {
+		return "removed"
+	}
+	oldVersion := change.Old.Version
+	newVersion := change.New.Version
+	if oldVersion == newVersion {
+		return "rebuilt"
+	}
+	if verstr.Less(oldVersion, newVersion) {
+		return "upgraded"
+	}
+	if verstr.Less(newVersion, oldVersion) {
+		return "downgraded"
+	}
+	return "changed"
+}
+
+func (change Change) sizeDelta() int64 {
+	var delta int64
+	if change.Old != nil {
+		delta -= int64(change.Old.Size)
+	}
+	if change.New != nil {
+		delta += int64(change.New.Size)
+	}
+	return delta
+}
@@ -0,0 +1,44 @@
+/*
+	Package sbom reads and writes the provenance and Software Bill of Materials
+	(SBOM) records which are attached to images.
+
+	Records are encoded as CycloneDX JSON documents. Dominator-specific
+	provenance information is stored in metadata properties with the
+	"dominator:" prefix.
+*/
+package sbom
+
+import (
+	"io"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/image"
+)
+
+// Provenance records how an image was built.
+type Provenance struct {
+	BuildVariables    []string // Names only: values may contain secrets.
+	GitBranch         string
+	GitCommit         string
+	ManifestDirectory string
+	ManifestUrl       string // Before variable expansion.
+	PackagerType      string
+	SourceImage       string
+	StreamName        string
+	Timestamp         time.Time
+}
+
+type Document struct {
+	Provenance Provenance
+	Packages   []image.Package
+}
+
+// Read will read a CycloneDX JSON document.
+func Read(reader io.Reader) (*Document, error) {
+	return read(reader)
+}
+
+// Write will write the document in CycloneDX JSON format.
+func (doc *Document) Write(writer io.Writer) error {
+	return doc.write(writer)
+}
@@ -0,0 +1,43 @@
+package sbom
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/image"
+)
+
+func TestWriteAndRead(t *testing.T) {
+	doc := &Document{
+		Provenance: Provenance{
+			BuildVariables:    []string{"VERSION", "ARCH"},
+			GitBranch:         "master",
+			GitCommit:         "0123456789abcdef",
+			ManifestDirectory: "web",
+			ManifestUrl:       "https://git.example.com/manifests.git",
+			PackagerType:      "deb",
+			SourceImage:       "base/2019-01-01:00:00:00",
+			StreamName:        "web",
+			Timestamp:         time.Unix(1546300800, 0).UTC(),
+		},
+		Packages: []image.Package{
+			{Name: "bash", Size: 1024, Version: "4.4-5"},
+			{Digest: "MD5:0123", Name: "coreutils", Size: 2048,
+				Version: "8.28"},
+		},
+	}
+	buffer := &bytes.Buffer{}
+	if err := doc.Write(buffer); err != nil {
+		t.Fatal(err)
+	}
+	readDoc, err := Read(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.Provenance.BuildVariables = []string{"ARCH", "VERSION"} // Sorted.
+	if !reflect.DeepEqual(doc, readDoc) {
+		t.Fatalf("expected: %v, got: %v", doc, readDoc)
+	}
+}
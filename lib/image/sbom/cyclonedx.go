@@ -0,0 +1,193 @@
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/image"
+	libjson "github.com/Symantec/Dominator/lib/json"
+)
+
+const (
+	bomFormat   = "CycloneDX"
+	specVersion = "1.4"
+
+	propertyBuildVariable     = "dominator:buildVariable"
+	propertyGitBranch         = "dominator:gitBranch"
+	propertyGitCommit         = "dominator:gitCommit"
+	propertyManifestDirectory = "dominator:manifestDirectory"
+	propertyManifestUrl       = "dominator:manifestUrl"
+	propertyPackagerType      = "dominator:packagerType"
+	propertySize              = "dominator:size"
+	propertySourceImage       = "dominator:sourceImage"
+)
+
+type bomType struct {
+	BomFormat   string          `json:"bomFormat"`
+	SpecVersion string          `json:"specVersion"`
+	Version     uint            `json:"version"`
+	Metadata    metadataType    `json:"metadata"`
+	Components  []componentType `json:"components,omitempty"`
+}
+
+type componentType struct {
+	Type       string         `json:"type"`
+	Name       string         `json:"name"`
+	Version    string         `json:"version,omitempty"`
+	Hashes     []hashType     `json:"hashes,omitempty"`
+	Properties []propertyType `json:"properties,omitempty"`
+}
+
+type hashType struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type metadataType struct {
+	Timestamp  string         `json:"timestamp,omitempty"`
+	Tools      []toolType     `json:"tools,omitempty"`
+	Component  *componentType `json:"component,omitempty"`
+	Properties []propertyType `json:"properties,omitempty"`
+}
+
+type propertyType struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type toolType struct {
+	Vendor string `json:"vendor,omitempty"`
+	Name   string `json:"name"`
+}
+
+func appendProperty(properties []propertyType,
+	name, value string) []propertyType {
+	if value == "" {
+		return properties
+	}
+	return append(properties, propertyType{Name: name, Value: value})
+}
+
+func read(reader io.Reader) (*Document, error) {
+	var bom bomType
+	if err := json.NewDecoder(reader).Decode(&bom); err != nil {
+		return nil, err
+	}
+	if bom.BomFormat != bomFormat {
+		return nil, fmt.Errorf("unsupported BOM format: \"%s\"", bom.BomFormat)
+	}
+	doc := &Document{}
+	provenance := &doc.Provenance
+	if bom.Metadata.Timestamp != "" {
+		timestamp, err := time.Parse(time.RFC3339, bom.Metadata.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		provenance.Timestamp = timestamp
+	}
+	if bom.Metadata.Component != nil {
+		provenance.StreamName = bom.Metadata.Component.Name
+	}
+	for _, property := range bom.Metadata.Properties {
+		switch property.Name {
+		case propertyBuildVariable:
+			provenance.BuildVariables = append(provenance.BuildVariables,
+				property.Value)
+		case propertyGitBranch:
+			provenance.GitBranch = property.Value
+		case propertyGitCommit:
+			provenance.GitCommit = property.Value
+		case propertyManifestDirectory:
+			provenance.ManifestDirectory = property.Value
+		case propertyManifestUrl:
+			provenance.ManifestUrl = property.Value
+		case propertyPackagerType:
+			provenance.PackagerType = property.Value
+		case propertySourceImage:
+			provenance.SourceImage = property.Value
+		}
+	}
+	for _, component := range bom.Components {
+		pkg := image.Package{Name: component.Name, Version: component.Version}
+		if len(component.Hashes) > 0 {
+			pkg.Digest = component.Hashes[0].Alg + ":" +
+				component.Hashes[0].Content
+		}
+		for _, property := range component.Properties {
+			if property.Name == propertySize {
+				size, err := strconv.ParseUint(property.Value, 10, 64)
+				if err != nil {
+					return nil, err
+				}
+				pkg.Size = size
+			}
+		}
+		doc.Packages = append(doc.Packages, pkg)
+	}
+	return doc, nil
+}
+
+func (doc *Document) write(writer io.Writer) error {
+	provenance := doc.Provenance
+	bom := bomType{
+		BomFormat:   bomFormat,
+		SpecVersion: specVersion,
+		Version:     1,
+		Metadata: metadataType{
+			Tools: []toolType{{Vendor: "Symantec", Name: "imaginator"}},
+		},
+	}
+	if !provenance.Timestamp.IsZero() {
+		bom.Metadata.Timestamp =
+			provenance.Timestamp.UTC().Format(time.RFC3339)
+	}
+	if provenance.StreamName != "" {
+		bom.Metadata.Component = &componentType{
+			Type: "operating-system",
+			Name: provenance.StreamName,
+		}
+	}
+	properties := appendProperty(nil, propertySourceImage,
+		provenance.SourceImage)
+	properties = appendProperty(properties, propertyManifestUrl,
+		provenance.ManifestUrl)
+	properties = appendProperty(properties, propertyManifestDirectory,
+		provenance.ManifestDirectory)
+	properties = appendProperty(properties, propertyGitBranch,
+		provenance.GitBranch)
+	properties = appendProperty(properties, propertyGitCommit,
+		provenance.GitCommit)
+	properties = appendProperty(properties, propertyPackagerType,
+		provenance.PackagerType)
+	variables := make([]string, len(provenance.BuildVariables))
+	copy(variables, provenance.BuildVariables)
+	sort.Strings(variables)
+	for _, name := range variables {
+		properties = appendProperty(properties, propertyBuildVariable, name)
+	}
+	bom.Metadata.Properties = properties
+	for _, pkg := range doc.Packages {
+		component := componentType{
+			Type:    "library",
+			Name:    pkg.Name,
+			Version: pkg.Version,
+			Properties: []propertyType{{
+				Name:  propertySize,
+				Value: strconv.FormatUint(pkg.Size, 10),
+			}},
+		}
+		if fields := strings.SplitN(pkg.Digest, ":", 2); len(fields) == 2 {
+			component.Hashes = []hashType{{
+				Alg:     fields[0],
+				Content: fields[1],
+			}}
+		}
+		bom.Components = append(bom.Components, component)
+	}
+	return libjson.WriteWithIndent(writer, "    ", bom)
+}
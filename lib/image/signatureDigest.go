@@ -0,0 +1,115 @@
+package image
+
+import (
+	"bufio"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/Symantec/Dominator/lib/filesystem"
+)
+
+const signatureDigestVersion = "Dominator image signature digest v1"
+
+func (image *Image) computeSignatureDigest() ([]byte, error) {
+	if image.FileSystem == nil {
+		return nil, errors.New("no file-system")
+	}
+	hasher := sha512.New()
+	writer := bufio.NewWriter(hasher)
+	fmt.Fprintln(writer, signatureDigestVersion)
+	fs := image.FileSystem
+	fmt.Fprintf(writer, "d %q %o %d %d\n",
+		"/", fs.DirectoryInode.Mode, fs.DirectoryInode.Uid,
+		fs.DirectoryInode.Gid)
+	if err := writeDirectoryDigest(writer, fs, &fs.DirectoryInode,
+		"/"); err != nil {
+		return nil, err
+	}
+	if image.Filter == nil {
+		fmt.Fprintln(writer, "filter none")
+	} else {
+		fmt.Fprintf(writer, "filter %d\n", len(image.Filter.FilterLines))
+		for _, line := range image.Filter.FilterLines {
+			fmt.Fprintf(writer, "%q\n", line)
+		}
+	}
+	if image.Triggers == nil {
+		fmt.Fprintln(writer, "triggers none")
+	} else {
+		fmt.Fprintf(writer, "triggers %d\n", len(image.Triggers.Triggers))
+		for _, trigger := range image.Triggers.Triggers {
+			fmt.Fprintf(writer, "%q %t %t %d\n", trigger.Service,
+				trigger.DoReboot, trigger.HighImpact, len(trigger.MatchLines))
+			for _, line := range trigger.MatchLines {
+				fmt.Fprintf(writer, "%q\n", line)
+			}
+			// Only present for triggers with dependencies or health checks,
+			// so that existing signatures remain valid.
+			if len(trigger.After) > 0 {
+				fmt.Fprintf(writer, "after %q\n", trigger.After)
+			}
+			if hc := trigger.HealthCheck; hc != nil {
+				fmt.Fprintf(writer, "healthCheck %q %q %q %d\n",
+					hc.Command, hc.HttpUrl, hc.TcpAddress, hc.TimeoutSeconds)
+			}
+		}
+	}
+	writeAnnotationDigest(writer, "releaseNotes", image.ReleaseNotes)
+	writeAnnotationDigest(writer, "buildLog", image.BuildLog)
+	if image.Provenance != nil {
+		writeAnnotationDigest(writer, "provenance", image.Provenance)
+	}
+	if err := writer.Flush(); err != nil {
+		return nil, err
+	}
+	return hasher.Sum(nil), nil
+}
+
+func writeAnnotationDigest(writer io.Writer, name string,
+	annotation *Annotation) {
+	if annotation == nil {
+		fmt.Fprintf(writer, "%s none\n", name)
+	} else if annotation.Object != nil {
+		fmt.Fprintf(writer, "%s object %x\n", name, *annotation.Object)
+	} else {
+		fmt.Fprintf(writer, "%s url %q\n", name, annotation.URL)
+	}
+}
+
+func writeDirectoryDigest(writer io.Writer, fs *filesystem.FileSystem,
+	directory *filesystem.DirectoryInode, dirname string) error {
+	for _, dirent := range directory.EntryList {
+		name := path.Join(dirname, dirent.Name)
+		inum := dirent.InodeNumber
+		switch inode := fs.InodeTable[inum].(type) {
+		case *filesystem.DirectoryInode:
+			fmt.Fprintf(writer, "d %q %d %o %d %d\n",
+				name, inum, inode.Mode, inode.Uid, inode.Gid)
+			if err := writeDirectoryDigest(writer, fs, inode,
+				name); err != nil {
+				return err
+			}
+		case *filesystem.RegularInode:
+			fmt.Fprintf(writer, "f %q %d %o %d %d %d.%09d %d %x\n",
+				name, inum, inode.Mode, inode.Uid, inode.Gid,
+				inode.MtimeSeconds, inode.MtimeNanoSeconds, inode.Size,
+				inode.Hash)
+		case *filesystem.ComputedRegularInode:
+			fmt.Fprintf(writer, "c %q %d %o %d %d %q\n",
+				name, inum, inode.Mode, inode.Uid, inode.Gid, inode.Source)
+		case *filesystem.SymlinkInode:
+			fmt.Fprintf(writer, "l %q %d %d %d %q\n",
+				name, inum, inode.Uid, inode.Gid, inode.Symlink)
+		case *filesystem.SpecialInode:
+			fmt.Fprintf(writer, "s %q %d %o %d %d %d.%09d %d\n",
+				name, inum, inode.Mode, inode.Uid, inode.Gid,
+				inode.MtimeSeconds, inode.MtimeNanoSeconds, inode.Rdev)
+		default:
+			return fmt.Errorf("unsupported inode type for: %s", name)
+		}
+	}
+	return nil
+}
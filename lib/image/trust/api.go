@@ -0,0 +1,55 @@
+package trust
+
+import (
+	"crypto"
+
+	"github.com/Symantec/Dominator/lib/image"
+)
+
+// Policy is a trust policy for images: a set of trusted public keys. An image
+// is trusted if it has a valid signature from at least one of the keys.
+type Policy struct {
+	keys map[string]crypto.PublicKey // Key: key ID.
+}
+
+// KeyId returns the identifier for a public key, which is the hexadecimal
+// SHA-256 fingerprint of the PKIX encoding of the key.
+func KeyId(publicKey crypto.PublicKey) (string, error) {
+	return keyId(publicKey)
+}
+
+// LoadPolicy will load a trust policy from a file containing one or more PEM
+// encoded public keys or certificates.
+func LoadPolicy(filename string) (*Policy, error) {
+	return loadPolicy(filename)
+}
+
+// LoadSigner will load a PEM encoded private key (RSA, ECDSA or Ed25519) from
+// a file, which may be used to sign images.
+func LoadSigner(filename string) (crypto.Signer, error) {
+	return loadSigner(filename)
+}
+
+// Sign will compute a signature for the image with signer and will add it to
+// the image, replacing any previous signature by the same key.
+func Sign(img *image.Image, signer crypto.Signer) error {
+	return sign(img, signer)
+}
+
+// PublicKey returns the trusted public key with the specified ID.
+func (policy *Policy) PublicKey(keyId string) (crypto.PublicKey, bool) {
+	publicKey, ok := policy.keys[keyId]
+	return publicKey, ok
+}
+
+// Verify will return an error if the image is not signed by a trusted key. If
+// policy is nil, all images are trusted.
+func (policy *Policy) Verify(img *image.Image) error {
+	return policy.verify(img)
+}
+
+// VerifySignature will verify a signature for a digest using a public key.
+func VerifySignature(publicKey crypto.PublicKey, digest []byte,
+	signature []byte) error {
+	return verifySignature(publicKey, digest, signature)
+}
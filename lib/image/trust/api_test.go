@@ -0,0 +1,88 @@
+package trust
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/image"
+)
+
+func makeImage() *image.Image {
+	fs := &filesystem.FileSystem{
+		InodeTable: filesystem.InodeTable{
+			1: &filesystem.RegularInode{Mode: 0644, Size: 1},
+		},
+	}
+	fs.EntryList = []*filesystem.DirectoryEntry{
+		{Name: "file", InodeNumber: 1},
+	}
+	return &image.Image{FileSystem: fs}
+}
+
+func makePolicy(t *testing.T, publicKeys ...crypto.PublicKey) *Policy {
+	file, err := ioutil.TempFile("", "trust_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	defer file.Close()
+	for _, publicKey := range publicKeys {
+		der, err := x509.MarshalPKIXPublicKey(publicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = pem.Encode(file, &pem.Block{Type: "PUBLIC KEY", Bytes: der})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	policy, err := LoadPolicy(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return policy
+}
+
+func TestSignAndVerify(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edPublicKey, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := makePolicy(t, edPublicKey)
+	img := makeImage()
+	if err := policy.Verify(img); err == nil {
+		t.Error("unsigned image verified")
+	}
+	if err := Sign(img, ecKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := policy.Verify(img); err == nil {
+		t.Error("image signed by untrusted key verified")
+	}
+	if err := Sign(img, edKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := policy.Verify(img); err != nil {
+		t.Error(err)
+	}
+	if err := makePolicy(t, &ecKey.PublicKey).Verify(img); err != nil {
+		t.Error(err)
+	}
+	img.FileSystem.InodeTable[1].(*filesystem.RegularInode).Size = 2
+	if err := policy.Verify(img); err == nil {
+		t.Error("modified image verified")
+	}
+}
@@ -0,0 +1,90 @@
+package trust
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+
+	"github.com/Symantec/Dominator/lib/image"
+)
+
+func sign(img *image.Image, signer crypto.Signer) error {
+	id, err := keyId(signer.Public())
+	if err != nil {
+		return err
+	}
+	digest, err := img.ComputeSignatureDigest()
+	if err != nil {
+		return err
+	}
+	var opts crypto.SignerOpts = crypto.SHA512
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		opts = crypto.Hash(0) // The digest is signed as the message.
+	}
+	signature, err := signer.Sign(rand.Reader, digest, opts)
+	if err != nil {
+		return err
+	}
+	signatures := make([]image.Signature, 0, len(img.Signatures)+1)
+	for _, oldSignature := range img.Signatures {
+		if oldSignature.KeyId != id {
+			signatures = append(signatures, oldSignature)
+		}
+	}
+	img.Signatures = append(signatures,
+		image.Signature{KeyId: id, Signature: signature})
+	return nil
+}
+
+func verifySignature(publicKey crypto.PublicKey, digest []byte,
+	signature []byte) error {
+	switch publicKey := publicKey.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(publicKey, digest, signature) {
+			return errors.New("invalid ECDSA signature")
+		}
+		return nil
+	case ed25519.PublicKey:
+		if !ed25519.Verify(publicKey, digest, signature) {
+			return errors.New("invalid Ed25519 signature")
+		}
+		return nil
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(publicKey, crypto.SHA512, digest, signature)
+	}
+	return fmt.Errorf("unsupported public key type: %T", publicKey)
+}
+
+func (policy *Policy) verify(img *image.Image) error {
+	if policy == nil {
+		return nil
+	}
+	if len(img.Signatures) < 1 {
+		return errors.New("image is not signed")
+	}
+	digest, err := img.ComputeSignatureDigest()
+	if err != nil {
+		return err
+	}
+	var lastError error
+	for _, signature := range img.Signatures {
+		publicKey, ok := policy.keys[signature.KeyId]
+		if !ok {
+			continue
+		}
+		err := verifySignature(publicKey, digest, signature.Signature)
+		if err == nil {
+			return nil
+		}
+		lastError = fmt.Errorf("bad signature from key: %s: %s",
+			signature.KeyId, err)
+	}
+	if lastError != nil {
+		return lastError
+	}
+	return errors.New("image is not signed by a trusted key")
+}
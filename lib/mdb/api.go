@@ -23,6 +23,7 @@ type AwsMetadata struct {
 type Machine struct {
 	Hostname       string
 	IpAddress      string       `json:",omitempty"`
+	Ipv6Address    string       `json:",omitempty"`
 	RequiredImage  string       `json:",omitempty"`
 	PlannedImage   string       `json:",omitempty"`
 	DisableUpdates bool         `json:",omitempty"`
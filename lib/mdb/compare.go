@@ -11,6 +11,9 @@ func (left Machine) compare(right Machine) bool {
 	if left.IpAddress != right.IpAddress {
 		return false
 	}
+	if left.Ipv6Address != right.Ipv6Address {
+		return false
+	}
 	if left.RequiredImage != right.RequiredImage {
 		return false
 	}
@@ -7,6 +7,16 @@ import (
 	"github.com/Symantec/Dominator/lib/hash"
 )
 
+// CompressedObjectsReader is an optional interface for an ObjectsReader which
+// can yield objects in their stored gzip-compressed form. If the boolean
+// returned by NextCompressedObject is true the reader yields a gzip stream,
+// else it yields the uncompressed data. The uncompressed length is always
+// returned.
+type CompressedObjectsReader interface {
+	ObjectsReader
+	NextCompressedObject() (uint64, io.ReadCloser, bool, error)
+}
+
 type FullObjectServer interface {
 	DeleteObject(hashVal hash.Hash) error
 	ObjectServer
@@ -0,0 +1,62 @@
+package bucket
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/objectcache"
+)
+
+func (objSrv *ObjectServer) addObject(reader io.Reader, length uint64,
+	expectedHash *hash.Hash) (hash.Hash, bool, error) {
+	hashVal, data, err := objectcache.ReadObject(reader, length, expectedHash)
+	if err != nil {
+		return hashVal, false, err
+	}
+	// Check for existing object and collision.
+	if size, err := objSrv.checkObject(hashVal); err != nil {
+		return hashVal, false, err
+	} else if size > 0 {
+		if err := collisionCheck(data, size); err != nil {
+			return hashVal, false, err
+		}
+		// No collision and no error: it's the same object. Go home early.
+		if objSrv.addCallback != nil {
+			objSrv.addCallback(hashVal, size, false)
+		}
+		return hashVal, false, nil
+	}
+	objSrv.garbageCollector(uint64(len(data)))
+	key := objectcache.HashToFilename(hashVal)
+	if err := objSrv.bucket.PutObject(key, data); err != nil {
+		return hashVal, false, err
+	}
+	objSrv.addToMap(hashVal, uint64(len(data)))
+	if objSrv.addCallback != nil {
+		objSrv.addCallback(hashVal, uint64(len(data)), true)
+	}
+	return hashVal, true, nil
+}
+
+func (objSrv *ObjectServer) addToMap(hashVal hash.Hash, size uint64) {
+	objSrv.rwLock.Lock()
+	defer objSrv.rwLock.Unlock()
+	if _, ok := objSrv.sizesMap[hashVal]; !ok {
+		objSrv.totalBytes += size
+	}
+	objSrv.sizesMap[hashVal] = size
+	objSrv.lastMutationTime = time.Now()
+}
+
+// collisionCheck only compares the length, since reading back the existing
+// object from the bucket would be expensive.
+func collisionCheck(data []byte, size uint64) error {
+	if uint64(len(data)) != size {
+		return fmt.Errorf(
+			"collision detected: length mismatch. Data=%d, existing object=%d",
+			len(data), size)
+	}
+	return nil
+}
@@ -0,0 +1,156 @@
+package bucket
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/objectserver"
+	"github.com/Symantec/Dominator/lib/objectserver/cachingreader"
+)
+
+// Bucket is the interface to a flat, key-based object store such as an S3
+// bucket. Keys are slash-separated. Implementations must be safe to use
+// concurrently.
+type Bucket interface {
+	CopyObject(sourceKey, destKey string) error
+	DeleteObject(key string) error
+	GetObject(key string) (uint64, io.ReadCloser, error)
+	// ListObjects calls listFunc for each object with a key starting with
+	// prefix.
+	ListObjects(prefix string, listFunc func(key string, size uint64)) error
+	PutObject(key string, data []byte) error
+}
+
+type Params struct {
+	Bucket         Bucket
+	CacheDirectory string // If specified, objects read are cached here.
+	MaxBytes       uint64 // If non-zero, garbage collect beyond this size.
+	MaxCachedBytes uint64 // Maximum size of the local cache.
+}
+
+type ObjectServer struct {
+	bucket                Bucket
+	cache                 *cachingreader.ObjectServer
+	maxBytes              uint64
+	addCallback           objectserver.AddCallback
+	gc                    objectserver.GarbageCollector
+	logger                log.DebugLogger
+	rwLock                sync.RWMutex // Protect the following fields.
+	sizesMap              map[hash.Hash]uint64
+	totalBytes            uint64
+	lastGarbageCollection time.Time
+	lastMutationTime      time.Time
+}
+
+// NewObjectServer creates an object server which stores objects in a bucket.
+// The bucket is listed to find existing objects. If params.CacheDirectory is
+// specified, objects which are read are cached in a local directory.
+func NewObjectServer(params Params, logger log.DebugLogger) (
+	*ObjectServer, error) {
+	return newObjectServer(params, logger)
+}
+
+// AddObject will add an object. Object data are read from reader (length bytes
+// are read). The object hash is computed and compared with expectedHash if not
+// nil. The following are returned:
+//   computed hash value
+//   a boolean which is true if the object is new
+//   an error or nil if no error.
+func (objSrv *ObjectServer) AddObject(reader io.Reader, length uint64,
+	expectedHash *hash.Hash) (hash.Hash, bool, error) {
+	return objSrv.addObject(reader, length, expectedHash)
+}
+
+func (objSrv *ObjectServer) CheckObjects(hashes []hash.Hash) ([]uint64, error) {
+	return objSrv.checkObjects(hashes)
+}
+
+// CommitObject will commit (add) a previously stashed object.
+func (objSrv *ObjectServer) CommitObject(hashVal hash.Hash) error {
+	return objSrv.commitObject(hashVal)
+}
+
+func (objSrv *ObjectServer) DeleteObject(hashVal hash.Hash) error {
+	return objSrv.deleteObject(hashVal)
+}
+
+func (objSrv *ObjectServer) DeleteStashedObject(hashVal hash.Hash) error {
+	return objSrv.deleteStashedObject(hashVal)
+}
+
+func (objSrv *ObjectServer) GetObject(hashVal hash.Hash) (
+	uint64, io.ReadCloser, error) {
+	return objectserver.GetObject(objSrv, hashVal)
+}
+
+func (objSrv *ObjectServer) GetObjects(hashes []hash.Hash) (
+	objectserver.ObjectsReader, error) {
+	return objSrv.getObjects(hashes)
+}
+
+func (objSrv *ObjectServer) LastMutationTime() time.Time {
+	objSrv.rwLock.RLock()
+	defer objSrv.rwLock.RUnlock()
+	return objSrv.lastMutationTime
+}
+
+func (objSrv *ObjectServer) ListObjectSizes() map[hash.Hash]uint64 {
+	return objSrv.listObjectSizes()
+}
+
+func (objSrv *ObjectServer) ListObjects() []hash.Hash {
+	return objSrv.listObjects()
+}
+
+func (objSrv *ObjectServer) NumObjects() uint64 {
+	objSrv.rwLock.RLock()
+	defer objSrv.rwLock.RUnlock()
+	return uint64(len(objSrv.sizesMap))
+}
+
+func (objSrv *ObjectServer) SetAddCallback(callback objectserver.AddCallback) {
+	objSrv.addCallback = callback
+}
+
+func (objSrv *ObjectServer) SetGarbageCollector(
+	gc objectserver.GarbageCollector) {
+	objSrv.gc = gc
+}
+
+// StashOrVerifyObject will stash an object if it is new or it will verify if it
+// already exists. Object data are read from reader (length bytes are read). The
+// object hash is computed and compared with expectedHash if not nil.
+// The following are returned:
+//   computed hash value
+//   the object data if the object is new, otherwise nil
+//   an error or nil if no error.
+func (objSrv *ObjectServer) StashOrVerifyObject(reader io.Reader,
+	length uint64, expectedHash *hash.Hash) (hash.Hash, []byte, error) {
+	return objSrv.stashOrVerifyObject(reader, length, expectedHash)
+}
+
+func (objSrv *ObjectServer) WriteHtml(writer io.Writer) {
+	objSrv.writeHtml(writer)
+}
+
+type ObjectsReader struct {
+	objectServer *ObjectServer
+	hashes       []hash.Hash
+	nextIndex    int64
+	sizes        []uint64
+}
+
+func (or *ObjectsReader) Close() error {
+	return nil
+}
+
+func (or *ObjectsReader) NextObject() (uint64, io.ReadCloser, error) {
+	return or.nextObject()
+}
+
+func (or *ObjectsReader) ObjectSizes() []uint64 {
+	return or.sizes
+}
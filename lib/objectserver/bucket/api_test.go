@@ -0,0 +1,191 @@
+package bucket
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+	"github.com/Symantec/Dominator/lib/objectcache"
+)
+
+type fakeBucket struct {
+	mutex   sync.Mutex
+	objects map[string][]byte
+}
+
+func newFakeBucket() *fakeBucket {
+	return &fakeBucket{objects: make(map[string][]byte)}
+}
+
+func (b *fakeBucket) CopyObject(sourceKey, destKey string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if data, ok := b.objects[sourceKey]; !ok {
+		return errors.New("no such key: " + sourceKey)
+	} else {
+		b.objects[destKey] = data
+		return nil
+	}
+}
+
+func (b *fakeBucket) DeleteObject(key string) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.objects, key)
+	return nil
+}
+
+func (b *fakeBucket) GetObject(key string) (uint64, io.ReadCloser, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if data, ok := b.objects[key]; !ok {
+		return 0, nil, errors.New("no such key: " + key)
+	} else {
+		return uint64(len(data)), ioutil.NopCloser(bytes.NewReader(data)), nil
+	}
+}
+
+func (b *fakeBucket) ListObjects(prefix string,
+	listFunc func(key string, size uint64)) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for key, data := range b.objects {
+		if strings.HasPrefix(key, prefix) {
+			listFunc(key, uint64(len(data)))
+		}
+	}
+	return nil
+}
+
+func (b *fakeBucket) PutObject(key string, data []byte) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.objects[key] = append([]byte(nil), data...)
+	return nil
+}
+
+func addObject(t *testing.T, objSrv *ObjectServer, data string) hash.Hash {
+	hashVal, isNew, err := objSrv.AddObject(strings.NewReader(data),
+		uint64(len(data)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isNew {
+		t.Fatalf("object: %q not new", data)
+	}
+	return hashVal
+}
+
+func readObject(t *testing.T, objSrv *ObjectServer, hashVal hash.Hash) string {
+	_, reader, err := objSrv.GetObject(hashVal)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestAddGetDelete(t *testing.T) {
+	bucket := newFakeBucket()
+	logger := testlogger.New(t)
+	objSrv, err := NewObjectServer(Params{Bucket: bucket}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashVal := addObject(t, objSrv, "hello, world")
+	if _, isNew, err := objSrv.AddObject(strings.NewReader("hello, world"),
+		12, nil); err != nil {
+		t.Fatal(err)
+	} else if isNew {
+		t.Fatal("duplicate object is new")
+	}
+	if got := readObject(t, objSrv, hashVal); got != "hello, world" {
+		t.Fatalf("read: %q", got)
+	}
+	// Objects must be found again by a new object server.
+	objSrv, err = NewObjectServer(Params{Bucket: bucket}, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sizes, err := objSrv.CheckObjects([]hash.Hash{hashVal}); err != nil {
+		t.Fatal(err)
+	} else if sizes[0] != 12 {
+		t.Fatalf("size: %d", sizes[0])
+	}
+	if err := objSrv.DeleteObject(hashVal); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := objSrv.GetObject(hashVal); err == nil {
+		t.Fatal("deleted object still readable")
+	}
+	if objSrv.NumObjects() != 0 {
+		t.Fatalf("objects remaining: %d", objSrv.NumObjects())
+	}
+}
+
+func TestStashAndCommit(t *testing.T) {
+	bucket := newFakeBucket()
+	objSrv, err := NewObjectServer(Params{Bucket: bucket}, testlogger.New(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := "stashed object"
+	hashVal, stashedData, err := objSrv.StashOrVerifyObject(
+		strings.NewReader(data), uint64(len(data)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(stashedData) != data {
+		t.Fatal("stashed object not returned")
+	}
+	if objSrv.NumObjects() != 0 {
+		t.Fatal("stashed object visible before commit")
+	}
+	if err := objSrv.CommitObject(hashVal); err != nil {
+		t.Fatal(err)
+	}
+	if got := readObject(t, objSrv, hashVal); got != data {
+		t.Fatalf("read: %q", got)
+	}
+	if len(bucket.objects) != 1 {
+		t.Fatalf("bucket has %d objects, want 1", len(bucket.objects))
+	}
+}
+
+func TestCache(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "bucket-cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(cacheDir)
+	bucket := newFakeBucket()
+	objSrv, err := NewObjectServer(Params{
+		Bucket:         bucket,
+		CacheDirectory: cacheDir,
+		MaxCachedBytes: 1 << 20,
+	}, testlogger.New(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hashVal := addObject(t, objSrv, "cached object")
+	if got := readObject(t, objSrv, hashVal); got != "cached object" {
+		t.Fatalf("read: %q", got)
+	}
+	// Remove the object from the bucket behind the back of the object server:
+	// it should still be readable from the cache.
+	bucket.DeleteObject(objectcache.HashToFilename(hashVal))
+	if got := readObject(t, objSrv, hashVal); got != "cached object" {
+		t.Fatalf("read from cache: %q", got)
+	}
+}
@@ -0,0 +1,24 @@
+package bucket
+
+import (
+	"github.com/Symantec/Dominator/lib/hash"
+)
+
+func (objSrv *ObjectServer) checkObjects(hashes []hash.Hash) ([]uint64, error) {
+	sizesList := make([]uint64, len(hashes))
+	objSrv.rwLock.RLock()
+	defer objSrv.rwLock.RUnlock()
+	for index, hashVal := range hashes {
+		sizesList[index] = objSrv.sizesMap[hashVal]
+	}
+	return sizesList, nil
+}
+
+// checkObject returns the size of the object if it is known, else 0. The
+// bucket is only written by this object server, so the table of object sizes
+// is authoritative.
+func (objSrv *ObjectServer) checkObject(hashVal hash.Hash) (uint64, error) {
+	objSrv.rwLock.RLock()
+	defer objSrv.rwLock.RUnlock()
+	return objSrv.sizesMap[hashVal], nil
+}
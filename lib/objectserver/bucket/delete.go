@@ -0,0 +1,23 @@
+package bucket
+
+import (
+	"time"
+
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/objectcache"
+)
+
+func (objSrv *ObjectServer) deleteObject(hashVal hash.Hash) error {
+	err := objSrv.bucket.DeleteObject(objectcache.HashToFilename(hashVal))
+	if err != nil {
+		return err
+	}
+	objSrv.rwLock.Lock()
+	if size, ok := objSrv.sizesMap[hashVal]; ok {
+		objSrv.totalBytes -= size
+		delete(objSrv.sizesMap, hashVal)
+	}
+	objSrv.lastMutationTime = time.Now()
+	objSrv.rwLock.Unlock()
+	return nil
+}
@@ -0,0 +1,40 @@
+package bucket
+
+import (
+	"time"
+
+	"github.com/Symantec/Dominator/lib/format"
+)
+
+const (
+	cleanupStartPercent = 95
+	cleanupStopPercent  = 90
+)
+
+// garbageCollector will call the registered garbage collector if adding
+// bytesToAdd would push utilisation of the bucket past the cleanup threshold.
+func (objSrv *ObjectServer) garbageCollector(bytesToAdd uint64) (
+	uint64, error) {
+	if objSrv.gc == nil || objSrv.maxBytes < 1 {
+		return 0, nil
+	}
+	objSrv.rwLock.Lock()
+	if time.Since(objSrv.lastGarbageCollection) < time.Second {
+		objSrv.rwLock.Unlock()
+		return 0, nil
+	}
+	objSrv.lastGarbageCollection = time.Now()
+	totalBytes := objSrv.totalBytes + bytesToAdd
+	objSrv.rwLock.Unlock()
+	if totalBytes*100 < objSrv.maxBytes*cleanupStartPercent {
+		return 0, nil
+	}
+	bytesToDelete := totalBytes - objSrv.maxBytes*cleanupStopPercent/100
+	bytesDeleted, err := objSrv.gc(bytesToDelete)
+	if err != nil {
+		objSrv.logger.Printf("Error collecting garbage, only deleted: %s: %s\n",
+			format.FormatBytes(bytesDeleted), err)
+		return 0, err
+	}
+	return bytesDeleted, nil
+}
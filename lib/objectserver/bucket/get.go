@@ -0,0 +1,66 @@
+package bucket
+
+import (
+	"errors"
+	"io"
+
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/objectcache"
+	"github.com/Symantec/Dominator/lib/objectserver"
+)
+
+func (objSrv *ObjectServer) getObjects(hashes []hash.Hash) (
+	objectserver.ObjectsReader, error) {
+	if objSrv.cache == nil {
+		return objSrv.getUncachedObjects(hashes)
+	}
+	// The cache may contain objects which have since been deleted, so check
+	// first.
+	if _, err := objSrv.getSizes(hashes); err != nil {
+		return nil, err
+	}
+	return objSrv.cache.GetObjects(hashes)
+}
+
+func (objSrv *ObjectServer) getSizes(hashes []hash.Hash) ([]uint64, error) {
+	sizes := make([]uint64, 0, len(hashes))
+	objSrv.rwLock.RLock()
+	defer objSrv.rwLock.RUnlock()
+	for _, hashVal := range hashes {
+		size, ok := objSrv.sizesMap[hashVal]
+		if !ok {
+			hashStr, _ := hashVal.MarshalText()
+			return nil, errors.New("missing object: " + string(hashStr))
+		}
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+func (objSrv *ObjectServer) getUncachedObjects(hashes []hash.Hash) (
+	*ObjectsReader, error) {
+	sizes, err := objSrv.getSizes(hashes)
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectsReader{
+		objectServer: objSrv,
+		hashes:       hashes,
+		nextIndex:    -1,
+		sizes:        sizes,
+	}, nil
+}
+
+func (or *ObjectsReader) nextObject() (uint64, io.ReadCloser, error) {
+	or.nextIndex++
+	if or.nextIndex >= int64(len(or.hashes)) {
+		return 0, nil, errors.New("all objects have been consumed")
+	}
+	return or.objectServer.bucket.GetObject(
+		objectcache.HashToFilename(or.hashes[or.nextIndex]))
+}
+
+func (getter *uncachedGetter) GetObjects(hashes []hash.Hash) (
+	objectserver.ObjectsReader, error) {
+	return getter.objectServer.getUncachedObjects(hashes)
+}
@@ -0,0 +1,25 @@
+package bucket
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/Symantec/Dominator/lib/format"
+)
+
+func (objSrv *ObjectServer) writeHtml(writer io.Writer) {
+	objSrv.rwLock.RLock()
+	numObjects := len(objSrv.sizesMap)
+	totalBytes := objSrv.totalBytes
+	objSrv.rwLock.RUnlock()
+	fmt.Fprintf(writer, "Number of objects: %d, consuming %s",
+		numObjects, format.FormatBytes(totalBytes))
+	if objSrv.maxBytes > 0 {
+		fmt.Fprintf(writer, " (bucket is %.1f%% full)",
+			float64(totalBytes)*100/float64(objSrv.maxBytes))
+	}
+	fmt.Fprintln(writer, "<br>")
+	if objSrv.cache != nil {
+		objSrv.cache.WriteHtml(writer)
+	}
+}
@@ -0,0 +1,63 @@
+package bucket
+
+import (
+	"time"
+
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/objectcache"
+	"github.com/Symantec/Dominator/lib/objectserver/cachingreader"
+)
+
+type uncachedGetter struct {
+	objectServer *ObjectServer
+}
+
+func newObjectServer(params Params, logger log.DebugLogger) (
+	*ObjectServer, error) {
+	startTime := time.Now()
+	sizesMap := make(map[hash.Hash]uint64)
+	var totalBytes uint64
+	err := params.Bucket.ListObjects("", func(key string, size uint64) {
+		if size < 1 {
+			return
+		}
+		hashVal, err := objectcache.FilenameToHash(key)
+		if err != nil {
+			return
+		}
+		// Skip stashed objects and anything else which is not an object.
+		if objectcache.HashToFilename(hashVal) != key {
+			return
+		}
+		sizesMap[hashVal] = size
+		totalBytes += size
+	})
+	if err != nil {
+		return nil, err
+	}
+	plural := ""
+	if len(sizesMap) != 1 {
+		plural = "s"
+	}
+	logger.Printf("Listed %d object%s in bucket in %s\n",
+		len(sizesMap), plural, time.Since(startTime))
+	objSrv := &ObjectServer{
+		bucket:                params.Bucket,
+		maxBytes:              params.MaxBytes,
+		logger:                logger,
+		sizesMap:              sizesMap,
+		totalBytes:            totalBytes,
+		lastGarbageCollection: time.Now(),
+		lastMutationTime:      time.Now(),
+	}
+	if params.CacheDirectory != "" {
+		objSrv.cache, err = cachingreader.NewObjectServerWithGetter(
+			params.CacheDirectory, params.MaxCachedBytes,
+			&uncachedGetter{objSrv}, logger)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return objSrv, nil
+}
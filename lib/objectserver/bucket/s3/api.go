@@ -0,0 +1,49 @@
+package s3
+
+import (
+	"io"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// Params specifies the bucket to use.
+type Params struct {
+	BucketName string
+	Endpoint   string // If specified, use path-style access (e.g. MinIO).
+	KeyPrefix  string // Prepended to all keys.
+	Region     string
+}
+
+// Bucket implements the bucket.Bucket interface using an S3-compatible
+// service.
+type Bucket struct {
+	bucketName string
+	keyPrefix  string
+	service    *s3.S3
+}
+
+func NewBucket(awsSession *session.Session, params Params) *Bucket {
+	return newBucket(awsSession, params)
+}
+
+func (b *Bucket) CopyObject(sourceKey, destKey string) error {
+	return b.copyObject(sourceKey, destKey)
+}
+
+func (b *Bucket) DeleteObject(key string) error {
+	return b.deleteObject(key)
+}
+
+func (b *Bucket) GetObject(key string) (uint64, io.ReadCloser, error) {
+	return b.getObject(key)
+}
+
+func (b *Bucket) ListObjects(prefix string,
+	listFunc func(key string, size uint64)) error {
+	return b.listObjects(prefix, listFunc)
+}
+
+func (b *Bucket) PutObject(key string, data []byte) error {
+	return b.putObject(key, data)
+}
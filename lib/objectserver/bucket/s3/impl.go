@@ -0,0 +1,95 @@
+package s3
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func newBucket(awsSession *session.Session, params Params) *Bucket {
+	config := &aws.Config{}
+	if params.Region != "" {
+		config.Region = aws.String(params.Region)
+	}
+	if params.Endpoint != "" {
+		config.Endpoint = aws.String(params.Endpoint)
+		config.S3ForcePathStyle = aws.Bool(true)
+	}
+	return &Bucket{
+		bucketName: params.BucketName,
+		keyPrefix:  params.KeyPrefix,
+		service:    s3.New(awsSession, config),
+	}
+}
+
+func (b *Bucket) copyObject(sourceKey, destKey string) error {
+	_, err := b.service.CopyObject(&s3.CopyObjectInput{
+		Bucket:     aws.String(b.bucketName),
+		CopySource: aws.String(b.bucketName + "/" + b.keyPrefix + sourceKey),
+		Key:        aws.String(b.keyPrefix + destKey),
+	})
+	if err != nil {
+		return fmt.Errorf("s3.CopyObject: %s", err)
+	}
+	return nil
+}
+
+func (b *Bucket) deleteObject(key string) error {
+	_, err := b.service.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(b.bucketName),
+		Key:    aws.String(b.keyPrefix + key),
+	})
+	if err != nil {
+		return fmt.Errorf("s3.DeleteObject: %s", err)
+	}
+	return nil
+}
+
+func (b *Bucket) getObject(key string) (uint64, io.ReadCloser, error) {
+	output, err := b.service.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(b.bucketName),
+		Key:    aws.String(b.keyPrefix + key),
+	})
+	if err != nil {
+		return 0, nil, fmt.Errorf("s3.GetObject: %s", err)
+	}
+	return uint64(aws.Int64Value(output.ContentLength)), output.Body, nil
+}
+
+func (b *Bucket) listObjects(prefix string,
+	listFunc func(key string, size uint64)) error {
+	err := b.service.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(b.bucketName),
+		Prefix: aws.String(b.keyPrefix + prefix),
+	},
+		func(output *s3.ListObjectsV2Output, lastPage bool) bool {
+			for _, object := range output.Contents {
+				listFunc(strings.TrimPrefix(aws.StringValue(object.Key),
+					b.keyPrefix),
+					uint64(aws.Int64Value(object.Size)))
+			}
+			return true
+		})
+	if err != nil {
+		return fmt.Errorf("s3.ListObjectsV2Pages: %s", err)
+	}
+	return nil
+}
+
+func (b *Bucket) putObject(key string, data []byte) error {
+	_, err := b.service.PutObject(&s3.PutObjectInput{
+		Body:          bytes.NewReader(data),
+		Bucket:        aws.String(b.bucketName),
+		ContentLength: aws.Int64(int64(len(data))),
+		Key:           aws.String(b.keyPrefix + key),
+	})
+	if err != nil {
+		return fmt.Errorf("s3.PutObject: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package bucket
+
+import (
+	"io"
+
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/objectcache"
+)
+
+const stashPrefix = ".stash/"
+
+func (objSrv *ObjectServer) commitObject(hashVal hash.Hash) error {
+	key := objectcache.HashToFilename(hashVal)
+	stashKey := stashPrefix + key
+	if size, _ := objSrv.checkObject(hashVal); size > 0 {
+		objSrv.bucket.DeleteObject(stashKey)
+		if objSrv.addCallback != nil {
+			objSrv.addCallback(hashVal, size, false)
+		}
+		return nil // Previously committed: return success.
+	}
+	size, reader, err := objSrv.bucket.GetObject(stashKey)
+	if err != nil {
+		return err
+	}
+	reader.Close()
+	objSrv.garbageCollector(size)
+	if err := objSrv.bucket.CopyObject(stashKey, key); err != nil {
+		return err
+	}
+	objSrv.bucket.DeleteObject(stashKey)
+	objSrv.addToMap(hashVal, size)
+	if objSrv.addCallback != nil {
+		objSrv.addCallback(hashVal, size, true)
+	}
+	return nil
+}
+
+func (objSrv *ObjectServer) deleteStashedObject(hashVal hash.Hash) error {
+	return objSrv.bucket.DeleteObject(
+		stashPrefix + objectcache.HashToFilename(hashVal))
+}
+
+func (objSrv *ObjectServer) stashOrVerifyObject(reader io.Reader,
+	length uint64, expectedHash *hash.Hash) (hash.Hash, []byte, error) {
+	hashVal, data, err := objectcache.ReadObject(reader, length, expectedHash)
+	if err != nil {
+		return hashVal, nil, err
+	}
+	// Check for existing object and collision.
+	if size, err := objSrv.checkObject(hashVal); err != nil {
+		return hashVal, nil, err
+	} else if size > 0 {
+		if err := collisionCheck(data, size); err != nil {
+			return hashVal, nil, err
+		}
+		return hashVal, nil, nil
+	}
+	stashKey := stashPrefix + objectcache.HashToFilename(hashVal)
+	if err := objSrv.bucket.PutObject(stashKey, data); err != nil {
+		return hashVal, nil, err
+	}
+	return hashVal, data, nil
+}
@@ -30,6 +30,7 @@ type ObjectServer struct {
 	lruUpdateNotifier   chan<- struct{}
 	maxCachedBytes      uint64
 	objectServerAddress string
+	objectsGetter       objectserver.ObjectsGetter
 	rwLock              sync.RWMutex // Protect the following fields.
 	cachedBytes         uint64       // Includes lruBytes.
 	downloadingBytes    uint64       // Objects being downloaded and cached.
@@ -41,7 +42,18 @@ type ObjectServer struct {
 
 func NewObjectServer(baseDir string, maxCachedBytes uint64,
 	objectServerAddress string, logger log.DebugLogger) (*ObjectServer, error) {
-	return newObjectServer(baseDir, maxCachedBytes, objectServerAddress, logger)
+	return newObjectServer(baseDir, maxCachedBytes, objectServerAddress, nil,
+		logger)
+}
+
+// NewObjectServerWithGetter is similar to NewObjectServer, except that objects
+// which are not cached are read from objectsGetter rather than from a remote
+// object server. The ObjectsReader returned by objectsGetter must also
+// implement the objectserver.FullObjectsReader interface.
+func NewObjectServerWithGetter(baseDir string, maxCachedBytes uint64,
+	objectsGetter objectserver.ObjectsGetter,
+	logger log.DebugLogger) (*ObjectServer, error) {
+	return newObjectServer(baseDir, maxCachedBytes, "", objectsGetter, logger)
 }
 
 func (objSrv *ObjectServer) GetObjects(hashes []hash.Hash) (
@@ -1,6 +1,7 @@
 package cachingreader
 
 import (
+	"errors"
 	"io"
 	"os"
 	"path/filepath"
@@ -84,12 +85,20 @@ func (objSrv *ObjectServer) getObjects(hashes []hash.Hash) (
 	if len(hashesToFetch) < 1 {
 		return &or, nil
 	}
-	or.objectClient = client.NewObjectClient(objSrv.objectServerAddress)
-	if realOR, err := or.objectClient.GetObjects(hashesToFetch); err != nil {
-		or.Close()
+	objectsGetter := objSrv.objectsGetter
+	if objectsGetter == nil {
+		or.objectClient = client.NewObjectClient(objSrv.objectServerAddress)
+		objectsGetter = or.objectClient
+	}
+	if realOR, err := objectsGetter.GetObjects(hashesToFetch); err != nil {
+		or.closeWithLock()
 		return nil, err
+	} else if fullOR, ok := realOR.(objectserver.FullObjectsReader); !ok {
+		realOR.Close()
+		or.closeWithLock()
+		return nil, errors.New("object sizes not available from upstream")
 	} else {
-		or.objectsReader = realOR.(objectserver.FullObjectsReader)
+		or.objectsReader = fullOR
 	}
 	sizes := or.objectsReader.ObjectSizes()
 	for index, hashVal := range hashesToFetch {
@@ -131,20 +140,29 @@ func (or *objectsReader) Close() error {
 		}
 	}
 	or.objSrv.rwLock.Unlock()
-	if or.objectClient == nil {
-		return nil
-	}
 	var err error
-	if e := or.objectsReader.Close(); err == nil && e != nil {
-		err = e
+	if or.objectsReader != nil {
+		err = or.objectsReader.Close()
 	}
-	if e := or.objectClient.Close(); err == nil && e != nil {
-		err = e
+	if or.objectClient != nil {
+		if e := or.objectClient.Close(); err == nil && e != nil {
+			err = e
+		}
 	}
-	if err != nil {
-		return err
+	return err
+}
+
+// closeWithLock releases the objects and closes the client. The lock must be
+// held by the caller.
+func (or *objectsReader) closeWithLock() {
+	for _, object := range or.objectsToRead {
+		if object != nil {
+			or.objSrv.putObjectWithLock(object)
+		}
+	}
+	if or.objectClient != nil {
+		or.objectClient.Close()
 	}
-	return nil
 }
 
 func (or *objectsReader) NextObject() (uint64, io.ReadCloser, error) {
@@ -208,6 +226,10 @@ func (ro *readerObject) Read(p []byte) (int, error) {
 	return ro.file.Read(p)
 }
 
+func (ro *readerObject) Seek(offset int64, whence int) (int64, error) {
+	return ro.file.Seek(offset, whence)
+}
+
 func timeoutFunction(f func(), timeout time.Duration) {
 	if timeout < 0 {
 		f()
@@ -7,11 +7,13 @@ import (
 
 	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/objectserver"
 	"github.com/Symantec/Dominator/lib/objectserver/filesystem/scan"
 )
 
 func newObjectServer(baseDir string, maxCachedBytes uint64,
-	objectServerAddress string, logger log.DebugLogger) (*ObjectServer, error) {
+	objectServerAddress string, objectsGetter objectserver.ObjectsGetter,
+	logger log.DebugLogger) (*ObjectServer, error) {
 	startTime := time.Now()
 	var rusageStart, rusageStop syscall.Rusage
 	syscall.Getrusage(syscall.RUSAGE_SELF, &rusageStart)
@@ -46,6 +48,7 @@ func newObjectServer(baseDir string, maxCachedBytes uint64,
 		lruUpdateNotifier:   lruUpdateNotifier,
 		maxCachedBytes:      maxCachedBytes,
 		objectServerAddress: objectServerAddress,
+		objectsGetter:       objectsGetter,
 		cachedBytes:         cachedBytes,
 		objects:             objects,
 	}
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"compress/gzip"
 	"errors"
 	"fmt"
 	"io"
@@ -21,15 +22,25 @@ func (objClient *ObjectClient) addObject(reader io.Reader, length uint64,
 	if err != nil {
 		return reply.Hash, false, err
 	}
-	conn, err := srpcClient.Call("ObjectServer.AddObjects")
+	conn, compression, err := callAddObjects(srpcClient, objClient.compression)
 	if err != nil {
 		return reply.Hash, false, err
 	}
 	defer conn.Close()
 	request.Length = length
 	request.ExpectedHash = expectedHash
+	request.Compression = compression
 	conn.Encode(request)
-	nCopied, err := io.Copy(conn, reader)
+	var nCopied int64
+	if compression == objectserver.CompressionGzip {
+		writer, _ := gzip.NewWriterLevel(conn, gzip.BestSpeed)
+		nCopied, err = io.Copy(writer, reader)
+		if err == nil {
+			err = writer.Close()
+		}
+	} else {
+		nCopied, err = io.Copy(conn, reader)
+	}
 	if err != nil {
 		return reply.Hash, false, err
 	}
@@ -1,16 +1,20 @@
 package client
 
 import (
+	"compress/gzip"
 	"io"
 
 	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/objectserver"
+	"github.com/Symantec/Dominator/lib/rsync"
 	"github.com/Symantec/Dominator/lib/srpc"
+	proto "github.com/Symantec/Dominator/proto/objectserver"
 )
 
 type ObjectClient struct {
 	address      string
 	client       *srpc.Client
+	compression  proto.Compression
 	exclusiveGet bool
 }
 
@@ -41,20 +45,52 @@ func (objClient *ObjectClient) GetObject(hashVal hash.Hash) (
 	return objectserver.GetObject(objClient, hashVal)
 }
 
+// GetObjectDelta will fetch the object with hash hashVal and write it to
+// writer, re-using content-defined chunks from base (an older version of the
+// object which has baseSize bytes) so that only changed chunks are
+// transferred. The object is written sequentially and is verified as it is
+// written: if an error is returned the data written must be discarded. If
+// limitReader is not nil it is used to wrap the reader for chunk data, such as
+// to limit the transfer speed. The object length and the transfer statistics
+// are returned. If the server does not support delta transfer for the object,
+// an error is returned and the caller should fall back to GetObjects.
+func (objClient *ObjectClient) GetObjectDelta(hashVal hash.Hash,
+	base io.ReaderAt, baseSize uint64, writer io.Writer,
+	limitReader func(io.Reader) io.Reader) (uint64, rsync.Stats, error) {
+	return objClient.getObjectDelta(hashVal, base, baseSize, writer,
+		limitReader)
+}
+
 func (objClient *ObjectClient) GetObjects(hashes []hash.Hash) (
 	objectserver.ObjectsReader, error) {
 	return objClient.getObjects(hashes)
 }
 
+// SetCompression will set the compression requested for objects fetched with
+// GetObjects and used for objects sent with AddObject. The server may choose
+// not to compress objects it sends.
+func (objClient *ObjectClient) SetCompression(compression proto.Compression) {
+	objClient.compression = compression
+}
+
 func (objClient *ObjectClient) SetExclusiveGetObjects(exclusive bool) {
 	objClient.exclusiveGet = exclusive
 }
 
 type ObjectsReader struct {
-	sizes     []uint64
-	client    *ObjectClient
-	reader    *srpc.Conn
-	nextIndex int64
+	sizes      []uint64
+	client     *ObjectClient
+	reader     *srpc.Conn
+	nextIndex  int64
+	gzip       bool
+	gzipReader *gzipObjectReader
+}
+
+type gzipObjectReader struct {
+	gzipReader    *gzip.Reader
+	limitedReader io.LimitedReader
+	closed        bool
+	err           error
 }
 
 func (or *ObjectsReader) Close() error {
@@ -70,6 +106,7 @@ func (or *ObjectsReader) ObjectSizes() []uint64 {
 }
 
 type ObjectAdderQueue struct {
+	compression     proto.Compression
 	conn            *srpc.Conn
 	getResponseChan chan<- struct{}
 	errorChan       <-chan error
@@ -77,7 +114,14 @@ type ObjectAdderQueue struct {
 }
 
 func NewObjectAdderQueue(client *srpc.Client) (*ObjectAdderQueue, error) {
-	return newObjectAdderQueue(client)
+	return newObjectAdderQueue(client, proto.CompressionNone)
+}
+
+// NewObjectAdderQueueWithCompression is similar to NewObjectAdderQueue, except
+// that objects are sent compressed if the server supports it.
+func NewObjectAdderQueueWithCompression(client *srpc.Client,
+	compression proto.Compression) (*ObjectAdderQueue, error) {
+	return newObjectAdderQueue(client, compression)
 }
 
 func (objQ *ObjectAdderQueue) Add(reader io.Reader, length uint64) (
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/rsync"
+	"github.com/Symantec/Dominator/proto/objectserver"
+)
+
+type limitedConn struct {
+	rsync.Conn
+	reader io.Reader
+}
+
+func (conn *limitedConn) Read(b []byte) (int, error) {
+	return conn.reader.Read(b)
+}
+
+func (objClient *ObjectClient) getObjectDelta(hashVal hash.Hash,
+	base io.ReaderAt, baseSize uint64, writer io.Writer,
+	limitReader func(io.Reader) io.Reader) (uint64, rsync.Stats, error) {
+	client, err := objClient.getClient()
+	if err != nil {
+		return 0, rsync.Stats{}, err
+	}
+	conn, err := client.Call("ObjectServer.GetObjectDelta")
+	if err != nil {
+		return 0, rsync.Stats{}, err
+	}
+	defer conn.Close()
+	request := objectserver.GetObjectDeltaRequest{Hash: hashVal}
+	if err := conn.Encode(request); err != nil {
+		return 0, rsync.Stats{}, err
+	}
+	if err := conn.Flush(); err != nil {
+		return 0, rsync.Stats{}, err
+	}
+	var reply objectserver.GetObjectDeltaResponse
+	if err := conn.Decode(&reply); err != nil {
+		return 0, rsync.Stats{}, err
+	}
+	if reply.Error != "" {
+		return 0, rsync.Stats{}, errors.New(reply.Error)
+	}
+	var rsyncConn rsync.Conn = conn
+	if limitReader != nil {
+		rsyncConn = &limitedConn{Conn: conn, reader: limitReader(conn)}
+	}
+	hasher := sha512.New()
+	stats, err := rsync.GetChunks(rsyncConn, conn, conn, base, baseSize,
+		io.MultiWriter(writer, hasher), reply.Size)
+	if err != nil {
+		return 0, rsync.Stats{}, err
+	}
+	var computedHash hash.Hash
+	copy(computedHash[:], hasher.Sum(nil))
+	if computedHash != hashVal {
+		return 0, rsync.Stats{}, fmt.Errorf("hash mismatch: got: %x, want: %x",
+			computedHash, hashVal)
+	}
+	return reply.Size, stats, nil
+}
@@ -1,6 +1,7 @@
 package client
 
 import (
+	"compress/gzip"
 	"errors"
 	"fmt"
 	"io"
@@ -22,6 +23,7 @@ func (objClient *ObjectClient) getObjects(hashes []hash.Hash) (
 	}
 	var request objectserver.GetObjectsRequest
 	var reply objectserver.GetObjectsResponse
+	request.Compression = objClient.compression
 	request.Exclusive = objClient.exclusiveGet
 	request.Hashes = hashes
 	conn.Encode(request)
@@ -36,6 +38,15 @@ func (objClient *ObjectClient) getObjects(hashes []hash.Hash) (
 	if reply.ResponseString != "" {
 		return nil, errors.New(reply.ResponseString)
 	}
+	switch reply.Compression {
+	case objectserver.CompressionNone:
+	case objectserver.CompressionGzip:
+		objectsReader.gzip = true
+	default:
+		conn.Close()
+		return nil, errors.New("unsupported compression: " +
+			reply.Compression.String())
+	}
 	objectsReader.nextIndex = -1
 	objectsReader.sizes = reply.ObjectSizes
 	return &objectsReader, nil
@@ -46,11 +57,51 @@ func (or *ObjectsReader) close() error {
 }
 
 func (or *ObjectsReader) nextObject() (uint64, io.ReadCloser, error) {
+	if or.gzipReader != nil {
+		if err := or.gzipReader.Close(); err != nil {
+			return 0, nil, err
+		}
+	}
 	or.nextIndex++
 	if or.nextIndex >= int64(len(or.sizes)) {
 		return 0, nil, errors.New("all objects have been consumed")
 	}
 	size := or.sizes[or.nextIndex]
-	return size,
-		ioutil.NopCloser(&io.LimitedReader{R: or.reader, N: int64(size)}), nil
+	if !or.gzip {
+		return size,
+			ioutil.NopCloser(&io.LimitedReader{R: or.reader, N: int64(size)}),
+			nil
+	}
+	// The connection is an io.ByteReader, so the gzip reader will not read
+	// beyond the end of the stream for this object.
+	gzipReader, err := gzip.NewReader(or.reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	gzipReader.Multistream(false)
+	or.gzipReader = &gzipObjectReader{
+		gzipReader:    gzipReader,
+		limitedReader: io.LimitedReader{R: gzipReader, N: int64(size)},
+	}
+	return size, or.gzipReader, nil
+}
+
+func (reader *gzipObjectReader) Read(p []byte) (int, error) {
+	return reader.limitedReader.Read(p)
+}
+
+// Close will consume the remainder of the gzip stream, so that the checksum
+// is verified and the next object may be read. It is safe to call Close more
+// than once.
+func (reader *gzipObjectReader) Close() error {
+	if reader.closed {
+		return reader.err
+	}
+	reader.closed = true
+	nRead, err := io.Copy(ioutil.Discard, reader.gzipReader)
+	if err == nil && nRead != reader.limitedReader.N {
+		err = errors.New("object size mismatch in compressed stream")
+	}
+	reader.err = err
+	return err
 }
@@ -1,6 +1,8 @@
 package client
 
 import (
+	"bytes"
+	"compress/gzip"
 	"crypto/sha512"
 	"fmt"
 	"io"
@@ -12,10 +14,11 @@ import (
 	"github.com/Symantec/Dominator/proto/objectserver"
 )
 
-func newObjectAdderQueue(client *srpc.Client) (*ObjectAdderQueue, error) {
+func newObjectAdderQueue(client *srpc.Client,
+	compression objectserver.Compression) (*ObjectAdderQueue, error) {
 	var objQ ObjectAdderQueue
 	var err error
-	objQ.conn, err = client.Call("ObjectServer.AddObjects")
+	objQ.conn, objQ.compression, err = callAddObjects(client, compression)
 	if err != nil {
 		return nil, err
 	}
@@ -63,6 +66,14 @@ func (objQ *ObjectAdderQueue) addData(data []byte, hashVal hash.Hash) error {
 		var request objectserver.AddObjectRequest
 		request.Length = uint64(len(data))
 		request.ExpectedHash = &hashVal
+		request.Compression = objQ.compression
+		if objQ.compression == objectserver.CompressionGzip {
+			if compressedData, err := compressData(data); err != nil {
+				request.Compression = objectserver.CompressionNone
+			} else {
+				data = compressedData
+			}
+		}
 		objQ.conn.Encode(request)
 		objQ.conn.Write(data)
 		objQ.getResponseChan <- struct{}{}
@@ -81,6 +92,40 @@ func (objQ *ObjectAdderQueue) close() error {
 	return updateError(err, objQ.conn.Close())
 }
 
+// callAddObjects will call the AddObjectsWithCompression method if compression
+// is requested, falling back to the AddObjects method (and no compression) if
+// the server does not support it. The compression to use is returned.
+func callAddObjects(client *srpc.Client,
+	compression objectserver.Compression) (
+	*srpc.Conn, objectserver.Compression, error) {
+	if compression != objectserver.CompressionNone {
+		if err := compression.CheckValid(); err != nil {
+			return nil, compression, err
+		}
+		conn, err := client.Call("ObjectServer.AddObjectsWithCompression")
+		if err == nil {
+			return conn, compression, nil
+		}
+	}
+	conn, err := client.Call("ObjectServer.AddObjects")
+	return conn, objectserver.CompressionNone, err
+}
+
+func compressData(data []byte) ([]byte, error) {
+	buffer := &bytes.Buffer{}
+	writer, err := gzip.NewWriterLevel(buffer, gzip.BestSpeed)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
 func updateError(oldError, newError error) error {
 	if oldError == nil {
 		return newError
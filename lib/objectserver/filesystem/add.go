@@ -11,7 +11,6 @@ import (
 	"syscall"
 	"time"
 
-	"github.com/Symantec/Dominator/lib/fsutil"
 	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/objectcache"
 )
@@ -45,35 +44,32 @@ func (objSrv *ObjectServer) addObject(reader io.Reader, length uint64,
 
 func (objSrv *ObjectServer) addOrCompare(hashVal hash.Hash, data []byte,
 	filename string) (bool, error) {
-	fi, err := os.Lstat(filename)
-	if err == nil {
-		if !fi.Mode().IsRegular() {
-			return false, errors.New("existing non-file: " + filename)
-		}
-		if err := collisionCheck(data, filename, fi.Size()); err != nil {
+	if _, _, err := statObject(filename); err == nil {
+		if err := collisionCheck(data, filename); err != nil {
 			return false, errors.New("collision detected: " + err.Error())
 		}
 		// No collision and no error: it's the same object. Go home early.
 		return false, nil
+	} else if !os.IsNotExist(err) {
+		return false, err
 	}
 	objSrv.garbageCollector()
-	if err = os.MkdirAll(path.Dir(filename), syscall.S_IRWXU); err != nil {
+	if err := os.MkdirAll(path.Dir(filename), syscall.S_IRWXU); err != nil {
 		return false, err
 	}
-	if err := fsutil.CopyToFile(filename, filePerms, bytes.NewReader(data),
-		uint64(len(data))); err != nil {
+	if err := writeObject(filename, data, objSrv.compression); err != nil {
 		return false, err
 	}
 	return true, nil
 }
 
-func collisionCheck(data []byte, filename string, size int64) error {
-	file, err := os.Open(filename)
+func collisionCheck(data []byte, filename string) error {
+	file, size, err := openObject(filename)
 	if err != nil {
 		return err
 	}
 	defer file.Close()
-	if int64(len(data)) != size {
+	if uint64(len(data)) != size {
 		return errors.New(fmt.Sprintf(
 			"length mismatch. Data=%d, existing object=%d",
 			len(data), size))
@@ -86,7 +82,7 @@ func collisionCheck(data []byte, filename string, size int64) error {
 			numToRead = cap(buffer)
 		}
 		buf := buffer[:numToRead]
-		nread, err := reader.Read(buf)
+		nread, err := io.ReadFull(reader, buf)
 		if err != nil {
 			return err
 		}
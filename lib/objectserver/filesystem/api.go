@@ -9,6 +9,7 @@ import (
 	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/log"
 	"github.com/Symantec/Dominator/lib/objectserver"
+	proto "github.com/Symantec/Dominator/proto/objectserver"
 )
 
 var (
@@ -21,6 +22,7 @@ var (
 type ObjectServer struct {
 	baseDir               string
 	addCallback           objectserver.AddCallback
+	compression           proto.Compression
 	gc                    objectserver.GarbageCollector
 	logger                log.Logger
 	rwLock                sync.RWMutex         // Protect the following fields.
@@ -66,6 +68,15 @@ func (objSrv *ObjectServer) SetAddCallback(callback objectserver.AddCallback) {
 	objSrv.addCallback = callback
 }
 
+// SetCompression will set the compression used when storing new objects.
+// Objects which do not compress well are stored uncompressed. Objects stored
+// compressed cannot be read randomly, so delta transfers of them (or using them
+// as the base for a delta transfer) are not supported and fall back to full
+// transfers.
+func (objSrv *ObjectServer) SetCompression(compression proto.Compression) {
+	objSrv.compression = compression
+}
+
 func (objSrv *ObjectServer) SetGarbageCollector(
 	gc objectserver.GarbageCollector) {
 	objSrv.gc = gc
@@ -128,6 +139,14 @@ func (or *ObjectsReader) Close() error {
 	return nil
 }
 
+// NextCompressedObject is similar to NextObject, except that if the object is
+// stored compressed, the reader yields the gzip-compressed data and the
+// returned boolean is true. The uncompressed length is always returned.
+func (or *ObjectsReader) NextCompressedObject() (
+	uint64, io.ReadCloser, bool, error) {
+	return or.nextCompressedObject()
+}
+
 func (or *ObjectsReader) NextObject() (uint64, io.ReadCloser, error) {
 	return or.nextObject()
 }
@@ -3,7 +3,6 @@ package filesystem
 import (
 	"errors"
 	"fmt"
-	"os"
 	"path"
 
 	"github.com/Symantec/Dominator/lib/hash"
@@ -30,19 +29,15 @@ func (objSrv *ObjectServer) checkObject(hash hash.Hash) (uint64, error) {
 		return size, nil
 	}
 	filename := path.Join(objSrv.baseDir, objectcache.HashToFilename(hash))
-	fi, err := os.Lstat(filename)
+	_, size, err := statObject(filename)
 	if err != nil {
 		return 0, nil
 	}
-	if fi.Mode().IsRegular() {
-		if fi.Size() < 1 {
-			return 0, errors.New(fmt.Sprintf("zero length file: %s", filename))
-		}
-		size := uint64(fi.Size())
-		objSrv.rwLock.Lock()
-		objSrv.sizesMap[hash] = size
-		objSrv.rwLock.Unlock()
-		return size, nil
+	if size < 1 {
+		return 0, errors.New(fmt.Sprintf("zero length file: %s", filename))
 	}
-	return 0, nil
+	objSrv.rwLock.Lock()
+	objSrv.sizesMap[hash] = size
+	objSrv.rwLock.Unlock()
+	return size, nil
 }
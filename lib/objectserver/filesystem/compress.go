@@ -0,0 +1,127 @@
+package filesystem
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/Symantec/Dominator/lib/fsutil"
+	"github.com/Symantec/Dominator/lib/objectserver/filesystem/scan"
+	proto "github.com/Symantec/Dominator/proto/objectserver"
+)
+
+type gzipFileReader struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (reader *gzipFileReader) Close() error {
+	reader.Reader.Close()
+	return reader.file.Close()
+}
+
+// openObject will open the object stored in filename (which may be stored
+// compressed) and will return a reader for the uncompressed data and the
+// uncompressed size.
+func openObject(filename string) (io.ReadCloser, uint64, error) {
+	file, size, compressed, err := openRawObject(filename)
+	if err != nil {
+		return nil, 0, err
+	}
+	if !compressed {
+		return file, size, nil
+	}
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, 0, err
+	}
+	gzipReader.Multistream(false)
+	return &gzipFileReader{Reader: gzipReader, file: file}, size, nil
+}
+
+// openRawObject will open the object stored in filename, which may be stored
+// compressed (with scan.CompressedSuffix appended to the filename). The file is
+// positioned at the start, the uncompressed size and whether the file is
+// compressed are also returned.
+func openRawObject(filename string) (*os.File, uint64, bool, error) {
+	compressed := false
+	file, err := os.Open(filename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, 0, false, err
+		}
+		file, err = os.Open(filename + scan.CompressedSuffix)
+		if err != nil {
+			return nil, 0, false, err
+		}
+		compressed = true
+	}
+	fi, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, false, err
+	}
+	if !fi.Mode().IsRegular() {
+		file.Close()
+		return nil, 0, false, errors.New("existing non-file: " + file.Name())
+	}
+	if !compressed {
+		return file, uint64(fi.Size()), false, nil
+	}
+	size, err := scan.ReadCompressedSize(file)
+	if err != nil {
+		file.Close()
+		return nil, 0, false, err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, 0, false, err
+	}
+	return file, size, true, nil
+}
+
+// statObject returns the pathname and the uncompressed size of the object
+// stored in filename, which may be stored compressed.
+func statObject(filename string) (string, uint64, error) {
+	if fi, err := os.Lstat(filename); err == nil {
+		if !fi.Mode().IsRegular() {
+			return "", 0, errors.New("existing non-file: " + filename)
+		}
+		return filename, uint64(fi.Size()), nil
+	} else if !os.IsNotExist(err) {
+		return "", 0, err
+	}
+	file, size, _, err := openRawObject(filename)
+	if err != nil {
+		return "", 0, err
+	}
+	file.Close()
+	return file.Name(), size, nil
+}
+
+// writeObject will write the object data to filename, compressing if
+// requested and if the data compress well.
+func writeObject(filename string, data []byte,
+	compression proto.Compression) error {
+	if compression == proto.CompressionGzip {
+		buffer := &bytes.Buffer{}
+		writer := gzip.NewWriter(buffer)
+		writer.Extra = scan.MakeCompressedSizeExtra(uint64(len(data)))
+		if _, err := writer.Write(data); err != nil {
+			return err
+		}
+		if err := writer.Close(); err != nil {
+			return err
+		}
+		// Only worth it if at least 10% is saved.
+		if buffer.Len() < len(data)-len(data)/10 {
+			return fsutil.CopyToFile(filename+scan.CompressedSuffix, filePerms,
+				buffer, uint64(buffer.Len()))
+		}
+	}
+	return fsutil.CopyToFile(filename, filePerms, bytes.NewReader(data),
+		uint64(len(data)))
+}
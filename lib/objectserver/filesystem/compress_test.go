@@ -0,0 +1,167 @@
+package filesystem
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+	"github.com/Symantec/Dominator/lib/objectcache"
+	"github.com/Symantec/Dominator/lib/objectserver/filesystem/scan"
+	proto "github.com/Symantec/Dominator/proto/objectserver"
+)
+
+type testObject struct {
+	data       []byte
+	compressed bool
+	hashVal    hash.Hash
+}
+
+func addTestObject(t *testing.T, objSrv *ObjectServer,
+	data []byte) hash.Hash {
+	hashVal, isNew, err := objSrv.AddObject(bytes.NewReader(data),
+		uint64(len(data)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !isNew {
+		t.Fatal("object not new")
+	}
+	return hashVal
+}
+
+func checkTestObjects(t *testing.T, objSrv *ObjectServer,
+	objects []testObject) {
+	for _, object := range objects {
+		filename := path.Join(objSrv.baseDir,
+			objectcache.HashToFilename(object.hashVal))
+		if object.compressed {
+			filename += scan.CompressedSuffix
+		}
+		if _, err := os.Stat(filename); err != nil {
+			t.Errorf("object not stored as expected: %s", err)
+		}
+		size, reader, err := objSrv.GetObject(object.hashVal)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != uint64(len(object.data)) {
+			t.Errorf("size: %d != %d", size, len(object.data))
+		}
+		if !bytes.Equal(data, object.data) {
+			t.Error("object data differ")
+		}
+	}
+	hashes := make([]hash.Hash, 0, len(objects))
+	for _, object := range objects {
+		hashes = append(hashes, object.hashVal)
+	}
+	lengths, err := objSrv.CheckObjects(hashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for index, object := range objects {
+		if lengths[index] != uint64(len(object.data)) {
+			t.Errorf("checked length: %d != %d",
+				lengths[index], len(object.data))
+		}
+	}
+	objectsReader, err := objSrv.GetObjects(hashes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer objectsReader.Close()
+	reader := objectsReader.(*ObjectsReader)
+	for _, object := range objects {
+		size, rawReader, compressed, err := reader.NextCompressedObject()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if size != uint64(len(object.data)) {
+			t.Errorf("raw size: %d != %d", size, len(object.data))
+		}
+		if compressed != object.compressed {
+			t.Errorf("compressed: %v, expected: %v",
+				compressed, object.compressed)
+		}
+		dataReader := rawReader
+		if compressed {
+			gzipReader, err := gzip.NewReader(rawReader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dataReader = gzipReader
+		}
+		data, err := ioutil.ReadAll(dataReader)
+		rawReader.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, object.data) {
+			t.Error("raw object data differ")
+		}
+	}
+}
+
+func TestCompressedRoundTrip(t *testing.T) {
+	baseDir, err := ioutil.TempDir("", "objectserver.test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(baseDir)
+	objSrv, err := NewObjectServer(baseDir, testlogger.New(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	randomData := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(randomData)
+	objects := []testObject{
+		{data: bytes.Repeat([]byte("uncompressed"), 100)},
+		{data: bytes.Repeat([]byte("compressible"), 100), compressed: true},
+		{data: randomData}, // Does not compress well.
+	}
+	objects[0].hashVal = addTestObject(t, objSrv, objects[0].data)
+	objSrv.SetCompression(proto.CompressionGzip)
+	for index := range objects[1:] {
+		object := &objects[index+1]
+		object.hashVal = addTestObject(t, objSrv, object.data)
+	}
+	checkTestObjects(t, objSrv, objects)
+	// Re-adding an object must find the existing (compressed) object.
+	_, isNew, err := objSrv.AddObject(bytes.NewReader(objects[1].data),
+		uint64(len(objects[1].data)), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isNew {
+		t.Error("existing compressed object added again")
+	}
+	// A fresh scan of the mixed store must find the same objects.
+	objSrv, err = NewObjectServer(baseDir, testlogger.New(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if num := objSrv.NumObjects(); num != uint64(len(objects)) {
+		t.Fatalf("scanned %d objects, expected: %d", num, len(objects))
+	}
+	checkTestObjects(t, objSrv, objects)
+	if err := objSrv.DeleteObject(objects[1].hashVal); err != nil {
+		t.Fatal(err)
+	}
+	if lengths, err := objSrv.CheckObjects(
+		[]hash.Hash{objects[1].hashVal}); err != nil {
+		t.Fatal(err)
+	} else if lengths[0] != 0 {
+		t.Error("deleted compressed object still present")
+	}
+}
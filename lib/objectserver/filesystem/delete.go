@@ -7,12 +7,18 @@ import (
 
 	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/objectcache"
+	"github.com/Symantec/Dominator/lib/objectserver/filesystem/scan"
 )
 
 func (objSrv *ObjectServer) deleteObject(hashVal hash.Hash) error {
 	filename := path.Join(objSrv.baseDir, objectcache.HashToFilename(hashVal))
 	if err := os.Remove(filename); err != nil {
-		return err
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if os.Remove(filename+scan.CompressedSuffix) != nil {
+			return err
+		}
 	}
 	objSrv.rwLock.Lock()
 	delete(objSrv.sizesMap, hashVal)
@@ -3,7 +3,6 @@ package filesystem
 import (
 	"errors"
 	"io"
-	"os"
 	"path"
 
 	"github.com/Symantec/Dominator/lib/hash"
@@ -40,14 +39,24 @@ func (or *ObjectsReader) nextObject() (uint64, io.ReadCloser, error) {
 	}
 	filename := path.Join(or.objectServer.baseDir,
 		objectcache.HashToFilename(or.hashes[or.nextIndex]))
-	file, err := os.Open(filename)
+	reader, size, err := openObject(filename)
 	if err != nil {
 		return 0, nil, err
 	}
-	fi, err := file.Stat()
+	return size, reader, nil
+}
+
+func (or *ObjectsReader) nextCompressedObject() (
+	uint64, io.ReadCloser, bool, error) {
+	or.nextIndex++
+	if or.nextIndex >= int64(len(or.hashes)) {
+		return 0, nil, false, errors.New("all objects have been consumed")
+	}
+	filename := path.Join(or.objectServer.baseDir,
+		objectcache.HashToFilename(or.hashes[or.nextIndex]))
+	file, size, compressed, err := openRawObject(filename)
 	if err != nil {
-		file.Close()
-		return 0, nil, err
+		return 0, nil, false, err
 	}
-	return uint64(fi.Size()), file, nil
+	return size, file, compressed, nil
 }
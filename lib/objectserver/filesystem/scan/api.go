@@ -1,9 +1,28 @@
 package scan
 
 import (
+	"io"
+
 	"github.com/Symantec/Dominator/lib/hash"
 )
 
+// CompressedSuffix is appended to the filename of objects which are stored
+// gzip-compressed. The uncompressed size of the object is stored in the Extra
+// field of the gzip header.
+const CompressedSuffix = ".gz"
+
+// MakeCompressedSizeExtra returns the gzip header Extra field which records the
+// uncompressed size of an object.
+func MakeCompressedSizeExtra(size uint64) []byte {
+	return makeCompressedSizeExtra(size)
+}
+
+// ReadCompressedSize reads the gzip header from reader and returns the
+// uncompressed size of the object.
+func ReadCompressedSize(reader io.Reader) (uint64, error) {
+	return readCompressedSize(reader)
+}
+
 // ScanTree will scan a directory tree for objects and will call registerFunc
 // for each object. Multiple calls to registerFunc may be called concurrently.
 func ScanTree(baseDir string, registerFunc func(hash.Hash, uint64)) error {
@@ -0,0 +1,41 @@
+package scan
+
+import (
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// The Extra field contains a single subfield (see RFC 1952) with the
+// uncompressed size as a little-endian 64 bit integer.
+var sizeSubfieldId = [2]byte{'D', 'S'}
+
+func makeCompressedSizeExtra(size uint64) []byte {
+	extra := make([]byte, 12)
+	extra[0] = sizeSubfieldId[0]
+	extra[1] = sizeSubfieldId[1]
+	binary.LittleEndian.PutUint16(extra[2:], 8)
+	binary.LittleEndian.PutUint64(extra[4:], size)
+	return extra
+}
+
+func readCompressedSize(reader io.Reader) (uint64, error) {
+	gzipReader, err := gzip.NewReader(reader)
+	if err != nil {
+		return 0, err
+	}
+	extra := gzipReader.Extra
+	for len(extra) >= 4 {
+		length := int(binary.LittleEndian.Uint16(extra[2:]))
+		if len(extra) < 4+length {
+			break
+		}
+		if extra[0] == sizeSubfieldId[0] && extra[1] == sizeSubfieldId[1] &&
+			length == 8 {
+			return binary.LittleEndian.Uint64(extra[4:]), nil
+		}
+		extra = extra[4+length:]
+	}
+	return 0, errors.New("no size recorded in gzip header")
+}
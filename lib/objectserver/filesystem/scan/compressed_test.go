@@ -0,0 +1,104 @@
+package scan
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/objectcache"
+)
+
+func writeCompressed(t *testing.T, extra []byte, data []byte) []byte {
+	buffer := &bytes.Buffer{}
+	writer := gzip.NewWriter(buffer)
+	writer.Extra = extra
+	if _, err := writer.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestReadCompressedSize(t *testing.T) {
+	data := bytes.Repeat([]byte("data"), 1000)
+	compressed := writeCompressed(t, MakeCompressedSizeExtra(4000), data)
+	size, err := ReadCompressedSize(bytes.NewReader(compressed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size != 4000 {
+		t.Errorf("size: %d != 4000", size)
+	}
+	// The size subfield may follow other subfields.
+	extra := append([]byte{'X', 'Y', 2, 0, 1, 2},
+		MakeCompressedSizeExtra(4000)...)
+	compressed = writeCompressed(t, extra, data)
+	if size, err := ReadCompressedSize(bytes.NewReader(compressed)); err != nil {
+		t.Fatal(err)
+	} else if size != 4000 {
+		t.Errorf("size after other subfield: %d != 4000", size)
+	}
+	compressed = writeCompressed(t, nil, data)
+	if _, err := ReadCompressedSize(bytes.NewReader(compressed)); err == nil {
+		t.Error("no error for missing size")
+	}
+	if _, err := ReadCompressedSize(bytes.NewReader(data)); err == nil {
+		t.Error("no error for uncompressed data")
+	}
+}
+
+func TestScanTreeMixed(t *testing.T) {
+	baseDir, err := ioutil.TempDir("", "scan.test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(baseDir)
+	plainData := []byte("plain object")
+	compressedData := bytes.Repeat([]byte("compressed object"), 100)
+	plainHash := hash.Hash{1}
+	compressedHash := hash.Hash{2}
+	compressedFilename := objectcache.HashToFilename(compressedHash) +
+		CompressedSuffix
+	files := map[string][]byte{
+		objectcache.HashToFilename(plainHash): plainData,
+		compressedFilename: writeCompressed(t,
+			MakeCompressedSizeExtra(uint64(len(compressedData))),
+			compressedData),
+	}
+	for filename, data := range files {
+		pathname := filepath.Join(baseDir, filename)
+		if err := os.MkdirAll(filepath.Dir(pathname), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(pathname, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sizes := make(map[hash.Hash]uint64)
+	var mutex sync.Mutex
+	err = ScanTree(baseDir, func(hashVal hash.Hash, size uint64) {
+		mutex.Lock()
+		sizes[hashVal] = size
+		mutex.Unlock()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sizes) != 2 {
+		t.Fatalf("expected 2 objects, got: %d", len(sizes))
+	}
+	if size := sizes[plainHash]; size != uint64(len(plainData)) {
+		t.Errorf("plain object size: %d != %d", size, len(plainData))
+	}
+	if size := sizes[compressedHash]; size != uint64(len(compressedData)) {
+		t.Errorf("compressed object size: %d != %d",
+			size, len(compressedData))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/Symantec/Dominator/lib/concurrent"
 	"github.com/Symantec/Dominator/lib/hash"
@@ -73,8 +74,27 @@ func scanDirectory(baseDir string, subpath string, state *concurrent.State,
 			if err != nil {
 				return err
 			}
-			registerFunc(hashVal, uint64(fi.Size()))
+			size := uint64(fi.Size())
+			if strings.HasSuffix(name, CompressedSuffix) {
+				if size, err = getCompressedSize(fullPathName); err != nil {
+					return err
+				}
+			}
+			registerFunc(hashVal, size)
 		}
 	}
 	return nil
 }
+
+func getCompressedSize(filename string) (uint64, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	size, err := readCompressedSize(file)
+	if err != nil {
+		return 0, fmt.Errorf("error reading: %s: %s", filename, err)
+	}
+	return size, nil
+}
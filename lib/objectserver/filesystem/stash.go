@@ -1,7 +1,6 @@
 package filesystem
 
 import (
-	"errors"
 	"io"
 	"os"
 	"path"
@@ -11,6 +10,7 @@ import (
 	"github.com/Symantec/Dominator/lib/fsutil"
 	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/objectcache"
+	"github.com/Symantec/Dominator/lib/objectserver/filesystem/scan"
 )
 
 var stashDirectory string = ".stash"
@@ -19,42 +19,52 @@ func (objSrv *ObjectServer) commitObject(hashVal hash.Hash) error {
 	hashName := objectcache.HashToFilename(hashVal)
 	filename := path.Join(objSrv.baseDir, hashName)
 	stashFilename := path.Join(objSrv.baseDir, stashDirectory, hashName)
-	fi, err := os.Lstat(stashFilename)
+	stashPathname, size, err := statObject(stashFilename)
 	if err != nil {
+		if !os.IsNotExist(err) {
+			fsutil.ForceRemove(stashFilename)
+			return err
+		}
 		if length, _ := objSrv.checkObject(hashVal); length > 0 {
 			return nil // Previously committed: return success.
 		}
 		return err
 	}
-	if !fi.Mode().IsRegular() {
-		fsutil.ForceRemove(stashFilename)
-		return errors.New("Existing non-file: " + stashFilename)
-	}
+	// Keep any suffix for compressed objects.
+	filename += stashPathname[len(stashFilename):]
 	if err = os.MkdirAll(path.Dir(filename), syscall.S_IRWXU); err != nil {
 		return err
 	}
 	objSrv.rwLock.Lock()
 	defer objSrv.rwLock.Unlock()
 	if _, ok := objSrv.sizesMap[hashVal]; ok {
-		fsutil.ForceRemove(stashFilename)
+		fsutil.ForceRemove(stashPathname)
 		// Run in a goroutine to keep outside of the lock.
-		go objSrv.addCallback(hashVal, uint64(fi.Size()), false)
+		go objSrv.addCallback(hashVal, size, false)
 		return nil
 	} else {
-		objSrv.sizesMap[hashVal] = uint64(fi.Size())
+		objSrv.sizesMap[hashVal] = size
 		objSrv.lastMutationTime = time.Now()
 		if objSrv.addCallback != nil {
 			// Run in a goroutine to keep outside of the lock.
-			go objSrv.addCallback(hashVal, uint64(fi.Size()), true)
+			go objSrv.addCallback(hashVal, size, true)
 		}
-		return os.Rename(stashFilename, filename)
+		return os.Rename(stashPathname, filename)
 	}
 }
 
 func (objSrv *ObjectServer) deleteStashedObject(hashVal hash.Hash) error {
 	filename := path.Join(objSrv.baseDir, stashDirectory,
 		objectcache.HashToFilename(hashVal))
-	return os.Remove(filename)
+	if err := os.Remove(filename); err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		if os.Remove(filename+scan.CompressedSuffix) != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (objSrv *ObjectServer) stashOrVerifyObject(reader io.Reader,
@@ -69,7 +79,7 @@ func (objSrv *ObjectServer) stashOrVerifyObject(reader io.Reader,
 	if length, err := objSrv.checkObject(hashVal); err != nil {
 		return hashVal, nil, err
 	} else if length > 0 {
-		if err := collisionCheck(data, filename, int64(length)); err != nil {
+		if err := collisionCheck(data, filename); err != nil {
 			return hashVal, nil, err
 		}
 		return hashVal, nil, nil
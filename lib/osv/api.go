@@ -0,0 +1,50 @@
+/*
+	Package osv matches package versions against an offline vulnerability feed
+	in the Open Source Vulnerability (OSV) JSON format.
+
+	The feed may contain a JSON array of OSV records or a sequence of OSV
+	records. Packages are matched by name only, so the feed should only contain
+	records for the ecosystem (distribution) of the images being checked.
+	Versions are compared as version strings (see the verstr package).
+*/
+package osv
+
+import (
+	"io"
+)
+
+type Database struct {
+	byPackage map[string][]*affectedPackage // Key: package name.
+	count     uint
+}
+
+type Vulnerability struct {
+	Aliases   []string
+	Ecosystem string
+	Id        string
+	Summary   string
+}
+
+// Load will read a vulnerability feed from a file.
+func Load(filename string) (*Database, error) {
+	return load(filename)
+}
+
+// Read will read a vulnerability feed.
+func Read(reader io.Reader) (*Database, error) {
+	return read(reader)
+}
+
+// Len returns the number of vulnerability records in the database.
+func (db *Database) Len() uint {
+	if db == nil {
+		return 0
+	}
+	return db.count
+}
+
+// Match returns the vulnerabilities affecting the specified version of a
+// package. A nil database matches nothing.
+func (db *Database) Match(packageName, version string) []Vulnerability {
+	return db.match(packageName, version)
+}
@@ -0,0 +1,68 @@
+package osv
+
+import (
+	"strings"
+	"testing"
+)
+
+const testFeed = `[
+  {
+    "id": "TEST-1",
+    "summary": "ranges",
+    "affected": [{
+      "package": {"ecosystem": "Debian", "name": "openssl"},
+      "ranges": [{
+        "type": "ECOSYSTEM",
+        "events": [
+          {"introduced": "1.0"}, {"fixed": "1.5"},
+          {"introduced": "2.0"}, {"last_affected": "2.3"}
+        ]
+      }]
+    }]
+  }
+]
+{
+  "id": "TEST-2",
+  "summary": "versions",
+  "affected": [{
+    "package": {"ecosystem": "Debian", "name": "bash"},
+    "versions": ["4.4-5"]
+  }]
+}`
+
+func TestMatch(t *testing.T) {
+	db, err := Read(strings.NewReader(testFeed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Len() != 2 {
+		t.Fatalf("expected 2 records, got: %d", db.Len())
+	}
+	tests := []struct {
+		name     string
+		version  string
+		affected bool
+	}{
+		{"openssl", "0.9", false},
+		{"openssl", "1.0", true},
+		{"openssl", "1.4.9", true},
+		{"openssl", "1.5", false},
+		{"openssl", "1.10", false},
+		{"openssl", "2.3", true},
+		{"openssl", "2.4", false},
+		{"bash", "4.4-5", true},
+		{"bash", "4.4-6", false},
+		{"zsh", "1.0", false},
+	}
+	for _, test := range tests {
+		vulnerabilities := db.Match(test.name, test.version)
+		if affected := len(vulnerabilities) > 0; affected != test.affected {
+			t.Errorf("%s %s: expected affected=%t, got: %t",
+				test.name, test.version, test.affected, affected)
+		}
+	}
+	var nilDb *Database
+	if len(nilDb.Match("bash", "4.4-5")) != 0 {
+		t.Error("nil database matched")
+	}
+}
@@ -0,0 +1,157 @@
+package osv
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+
+	"github.com/Symantec/Dominator/lib/verstr"
+)
+
+type affectedPackage struct {
+	ranges        []rangeType
+	versions      map[string]struct{}
+	vulnerability *Vulnerability
+}
+
+type affectedType struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges   []rangeType `json:"ranges"`
+	Versions []string    `json:"versions"`
+}
+
+type eventType struct {
+	Introduced   string `json:"introduced"`
+	Fixed        string `json:"fixed"`
+	LastAffected string `json:"last_affected"`
+}
+
+type rangeType struct {
+	Type   string      `json:"type"`
+	Events []eventType `json:"events"`
+}
+
+type recordType struct {
+	Affected []affectedType `json:"affected"`
+	Aliases  []string       `json:"aliases"`
+	Id       string         `json:"id"`
+	Summary  string         `json:"summary"`
+}
+
+func load(filename string) (*Database, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return read(bufio.NewReader(file))
+}
+
+func read(reader io.Reader) (*Database, error) {
+	db := &Database{byPackage: make(map[string][]*affectedPackage)}
+	decoder := json.NewDecoder(reader)
+	for {
+		var rawMessage json.RawMessage
+		if err := decoder.Decode(&rawMessage); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+		if len(rawMessage) > 0 && rawMessage[0] == '[' {
+			var records []recordType
+			if err := json.Unmarshal(rawMessage, &records); err != nil {
+				return nil, err
+			}
+			for _, record := range records {
+				db.addRecord(record)
+			}
+		} else {
+			var record recordType
+			if err := json.Unmarshal(rawMessage, &record); err != nil {
+				return nil, err
+			}
+			db.addRecord(record)
+		}
+	}
+	return db, nil
+}
+
+func (db *Database) addRecord(record recordType) {
+	if record.Id == "" {
+		return
+	}
+	db.count++
+	for _, affected := range record.Affected {
+		pkg := &affectedPackage{
+			versions: make(map[string]struct{}, len(affected.Versions)),
+			vulnerability: &Vulnerability{
+				Aliases:   record.Aliases,
+				Ecosystem: affected.Package.Ecosystem,
+				Id:        record.Id,
+				Summary:   record.Summary,
+			},
+		}
+		for _, version := range affected.Versions {
+			pkg.versions[version] = struct{}{}
+		}
+		for _, versionRange := range affected.Ranges {
+			if versionRange.Type != "GIT" {
+				pkg.ranges = append(pkg.ranges, versionRange)
+			}
+		}
+		db.byPackage[affected.Package.Name] = append(
+			db.byPackage[affected.Package.Name], pkg)
+	}
+}
+
+func (db *Database) match(packageName, version string) []Vulnerability {
+	if db == nil {
+		return nil
+	}
+	var vulnerabilities []Vulnerability
+	for _, pkg := range db.byPackage[packageName] {
+		if pkg.isAffected(version) {
+			vulnerabilities = append(vulnerabilities, *pkg.vulnerability)
+		}
+	}
+	return vulnerabilities
+}
+
+func (pkg *affectedPackage) isAffected(version string) bool {
+	if _, ok := pkg.versions[version]; ok {
+		return true
+	}
+	for _, versionRange := range pkg.ranges {
+		if versionRange.isAffected(version) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAffected evaluates the events (which are sorted by version) in order.
+func (versionRange rangeType) isAffected(version string) bool {
+	affected := false
+	for _, event := range versionRange.Events {
+		if event.Introduced != "" {
+			if event.Introduced == "0" ||
+				!verstr.Less(version, event.Introduced) {
+				affected = true
+			}
+		} else if event.Fixed != "" {
+			if !verstr.Less(version, event.Fixed) {
+				affected = false
+			}
+		} else if event.LastAffected != "" {
+			if verstr.Less(event.LastAffected, version) {
+				affected = false
+			}
+		}
+	}
+	return affected
+}
@@ -29,7 +29,24 @@ func GetBlocks(conn Conn, decoder Decoder, encoder Encoder, reader io.Reader,
 		readerBytes)
 }
 
+// GetChunks will fetch an object of totalBytes from a server using the
+// proto/rsync.GetChunks protocol, re-using any chunks of the object which are
+// also in base (which has baseBytes). The object is written sequentially to
+// writer. Base may be nil. The caller should verify the object.
+func GetChunks(conn Conn, decoder Decoder, encoder Encoder, base io.ReaderAt,
+	baseBytes uint64, writer io.Writer, totalBytes uint64) (Stats, error) {
+	return getChunks(conn, decoder, encoder, base, baseBytes, writer,
+		totalBytes)
+}
+
 func ServeBlocks(conn Conn, decoder Decoder, encoder Encoder,
 	reader io.ReadSeeker, length uint64) error {
 	return serveBlocks(conn, decoder, encoder, reader, length)
 }
+
+// ServeChunks will serve an object of length bytes read from reader using the
+// proto/rsync.GetChunks protocol.
+func ServeChunks(conn Conn, decoder Decoder, encoder Encoder,
+	reader io.ReadSeeker, length uint64) error {
+	return serveChunks(conn, decoder, encoder, reader, length)
+}
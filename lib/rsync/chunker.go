@@ -0,0 +1,85 @@
+package rsync
+
+import (
+	"crypto/sha512"
+	gohash "hash"
+
+	"github.com/Symantec/Dominator/lib/hash"
+)
+
+const (
+	chunkMinSize = 16 << 10
+	chunkMaxSize = 256 << 10
+	chunkMask    = 1<<16 - 1 // Average chunk size is ~64 KiB above minimum.
+)
+
+// The gear table must never change, since both ends of a transfer must find
+// the same chunk boundaries.
+var gearTable [256]uint64
+
+type chunkFunc func(offset, size uint64, hashVal hash.Hash) error
+
+// chunker is an io.Writer which splits the data written to it into
+// content-defined chunks using a gear rolling hash, calling chunkFunc for each.
+type chunker struct {
+	chunkFunc chunkFunc
+	gear      uint64
+	hasher    gohash.Hash
+	offset    uint64
+	size      uint64
+}
+
+func init() {
+	seed := uint64(0x446f6d696e61746f) // Fixed: see gearTable.
+	for index := range gearTable {
+		seed += 0x9e3779b97f4a7c15 // splitmix64.
+		value := seed
+		value = (value ^ value>>30) * 0xbf58476d1ce4e5b9
+		value = (value ^ value>>27) * 0x94d049bb133111eb
+		gearTable[index] = value ^ value>>31
+	}
+}
+
+func newChunker(chunkFunc chunkFunc) *chunker {
+	return &chunker{chunkFunc: chunkFunc, hasher: sha512.New()}
+}
+
+func (c *chunker) Write(data []byte) (int, error) {
+	start := 0
+	for index, value := range data {
+		c.gear = c.gear<<1 + gearTable[value]
+		c.size++
+		if c.size < chunkMinSize {
+			continue
+		}
+		if c.size < chunkMaxSize && c.gear&chunkMask != 0 {
+			continue
+		}
+		c.hasher.Write(data[start : index+1])
+		start = index + 1
+		if err := c.emit(); err != nil {
+			return start, err
+		}
+	}
+	c.hasher.Write(data[start:])
+	return len(data), nil
+}
+
+// flush emits the final chunk, if any.
+func (c *chunker) flush() error {
+	if c.size < 1 {
+		return nil
+	}
+	return c.emit()
+}
+
+func (c *chunker) emit() error {
+	var hashVal hash.Hash
+	copy(hashVal[:], c.hasher.Sum(nil))
+	err := c.chunkFunc(c.offset, c.size, hashVal)
+	c.offset += c.size
+	c.size = 0
+	c.gear = 0
+	c.hasher.Reset()
+	return err
+}
@@ -0,0 +1,154 @@
+package rsync
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"io"
+	"math/rand"
+	"net"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/hash"
+)
+
+type testConn struct {
+	*bufio.ReadWriter
+	*gob.Decoder
+	*gob.Encoder
+}
+
+func makeTestData(size int, seed int64) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func makeTestConn(conn net.Conn) *testConn {
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	return &testConn{rw, gob.NewDecoder(rw), gob.NewEncoder(rw)}
+}
+
+func insertData(data []byte, offset int, newData []byte) []byte {
+	result := make([]byte, 0, len(data)+len(newData))
+	result = append(result, data[:offset]...)
+	result = append(result, newData...)
+	return append(result, data[offset:]...)
+}
+
+func listChunks(t *testing.T, data []byte) map[hash.Hash]uint64 {
+	chunks := make(map[hash.Hash]uint64)
+	var total uint64
+	chunker := newChunker(func(offset, size uint64, hashVal hash.Hash) error {
+		if offset != total {
+			t.Fatalf("chunk offset: %d, expected: %d", offset, total)
+		}
+		if size > chunkMaxSize {
+			t.Fatalf("chunk size: %d exceeds maximum", size)
+		}
+		if size < chunkMinSize && offset+size < uint64(len(data)) {
+			t.Fatalf("chunk size: %d below minimum", size)
+		}
+		chunks[hashVal] = size
+		total += size
+		return nil
+	})
+	// Odd write sizes must not change the boundaries.
+	for remaining := data; len(remaining) > 0; {
+		length := 1000
+		if length > len(remaining) {
+			length = len(remaining)
+		}
+		chunker.Write(remaining[:length])
+		remaining = remaining[length:]
+	}
+	if err := chunker.flush(); err != nil {
+		t.Fatal(err)
+	}
+	if total != uint64(len(data)) {
+		t.Fatalf("chunks total: %d, expected: %d", total, len(data))
+	}
+	return chunks
+}
+
+func transfer(t *testing.T, base, data []byte) (Stats, []byte) {
+	clientSide, serverSide := net.Pipe()
+	defer clientSide.Close()
+	defer serverSide.Close()
+	errorChannel := make(chan error, 1)
+	go func() {
+		conn := makeTestConn(serverSide)
+		err := ServeChunks(conn, conn, conn, bytes.NewReader(data),
+			uint64(len(data)))
+		if err == nil {
+			err = conn.Flush()
+		}
+		errorChannel <- err
+	}()
+	conn := makeTestConn(clientSide)
+	var baseReader io.ReaderAt
+	if base != nil {
+		baseReader = bytes.NewReader(base)
+	}
+	buffer := &bytes.Buffer{}
+	stats, err := GetChunks(conn, conn, conn, baseReader, uint64(len(base)),
+		buffer, uint64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errorChannel; err != nil {
+		t.Fatal(err)
+	}
+	return stats, buffer.Bytes()
+}
+
+func TestChunkerInsertion(t *testing.T) {
+	base := makeTestData(4<<20, 1)
+	baseChunks := listChunks(t, base)
+	if len(baseChunks) < 16 {
+		t.Fatalf("only %d chunks for 4 MiB", len(baseChunks))
+	}
+	data := insertData(base, 2<<20, []byte("inserted data"))
+	var numChanged int
+	for hashVal := range listChunks(t, data) {
+		if _, ok := baseChunks[hashVal]; !ok {
+			numChanged++
+		}
+	}
+	if numChanged > 2 {
+		t.Errorf("insertion changed %d chunks", numChanged)
+	}
+}
+
+func TestGetChunks(t *testing.T) {
+	base := makeTestData(4<<20, 2)
+	data := insertData(base, 1<<20, []byte("some inserted data"))
+	data = append(data[:3<<20], data[3<<20+100:]...) // And a deletion.
+	stats, result := transfer(t, base, data)
+	if !bytes.Equal(result, data) {
+		t.Fatal("transferred data differ")
+	}
+	if stats.NumRead > 1<<20 {
+		t.Errorf("read: %d bytes for small changes", stats.NumRead)
+	}
+}
+
+func TestGetChunksNoBase(t *testing.T) {
+	data := makeTestData(1<<20, 3)
+	stats, result := transfer(t, nil, data)
+	if !bytes.Equal(result, data) {
+		t.Fatal("transferred data differ")
+	}
+	if stats.NumRead < uint64(len(data)) {
+		t.Errorf("read: %d bytes, expected at least: %d",
+			stats.NumRead, len(data))
+	}
+}
+
+func TestGetChunksUnrelatedBase(t *testing.T) {
+	base := makeTestData(100, 4)
+	data := makeTestData(1<<20+1, 5)
+	if _, result := transfer(t, base, data); !bytes.Equal(result, data) {
+		t.Fatal("transferred data differ")
+	}
+}
@@ -0,0 +1,80 @@
+package rsync
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/Symantec/Dominator/lib/errors"
+	"github.com/Symantec/Dominator/lib/hash"
+	proto "github.com/Symantec/Dominator/proto/rsync"
+)
+
+type baseChunk struct {
+	offset uint64
+	size   uint64
+}
+
+func getChunks(rawConn Conn, decoder Decoder, encoder Encoder,
+	base io.ReaderAt, baseBytes uint64, writer io.Writer,
+	totalBytes uint64) (Stats, error) {
+	baseChunks := make(map[hash.Hash]baseChunk)
+	if base != nil && baseBytes > 0 {
+		chunker := newChunker(
+			func(offset, size uint64, hashVal hash.Hash) error {
+				if _, ok := baseChunks[hashVal]; !ok {
+					baseChunks[hashVal] = baseChunk{offset, size}
+				}
+				return nil
+			})
+		_, err := io.Copy(chunker,
+			io.NewSectionReader(base, 0, int64(baseBytes)))
+		if err != nil {
+			return Stats{}, err
+		}
+		if err := chunker.flush(); err != nil {
+			return Stats{}, err
+		}
+	}
+	var chunks []proto.Chunk
+	var request proto.GetChunksRequest
+	var receivedBytes uint64
+	for {
+		var chunk proto.Chunk
+		if err := decoder.Decode(&chunk); err != nil {
+			return Stats{}, fmt.Errorf("error decoding chunk: %s", err)
+		}
+		if err := errors.New(chunk.Error); err != nil {
+			return Stats{}, err
+		}
+		if chunk.Size < 1 {
+			break
+		}
+		if _, ok := baseChunks[chunk.Hash]; !ok {
+			request.Indices = append(request.Indices, uint64(len(chunks)))
+		}
+		chunks = append(chunks, chunk)
+		receivedBytes += chunk.Size
+	}
+	if receivedBytes != totalBytes {
+		return Stats{}, fmt.Errorf("chunks total: %d bytes, expected: %d",
+			receivedBytes, totalBytes)
+	}
+	conn := &measuringConn{Conn: rawConn}
+	if err := encoder.Encode(request); err != nil {
+		return Stats{}, fmt.Errorf("error encoding request: %s", err)
+	}
+	if err := conn.Flush(); err != nil {
+		return Stats{}, err
+	}
+	for _, chunk := range chunks {
+		var reader io.Reader = conn
+		if baseChunk, ok := baseChunks[chunk.Hash]; ok {
+			reader = io.NewSectionReader(base, int64(baseChunk.offset),
+				int64(baseChunk.size))
+		}
+		if _, err := io.CopyN(writer, reader, int64(chunk.Size)); err != nil {
+			return Stats{}, err
+		}
+	}
+	return conn.stats, nil
+}
@@ -0,0 +1,48 @@
+package rsync
+
+import (
+	"errors"
+	"io"
+
+	"github.com/Symantec/Dominator/lib/hash"
+	proto "github.com/Symantec/Dominator/proto/rsync"
+)
+
+func serveChunks(conn Conn, decoder Decoder, encoder Encoder,
+	reader io.ReadSeeker, length uint64) error {
+	var offsets, sizes []uint64
+	chunker := newChunker(func(offset, size uint64, hashVal hash.Hash) error {
+		offsets = append(offsets, offset)
+		sizes = append(sizes, size)
+		return encoder.Encode(proto.Chunk{Hash: hashVal, Size: size})
+	})
+	if _, err := io.CopyN(chunker, reader, int64(length)); err != nil {
+		return encoder.Encode(proto.Chunk{Error: err.Error()})
+	}
+	if err := chunker.flush(); err != nil {
+		return err
+	}
+	if err := encoder.Encode(proto.Chunk{}); err != nil {
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	var request proto.GetChunksRequest
+	if err := decoder.Decode(&request); err != nil {
+		return err
+	}
+	for _, index := range request.Indices {
+		if index >= uint64(len(offsets)) {
+			return errors.New("bad chunk index")
+		}
+		_, err := reader.Seek(int64(offsets[index]), io.SeekStart)
+		if err != nil {
+			return err
+		}
+		if _, err := io.CopyN(conn, reader, int64(sizes[index])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
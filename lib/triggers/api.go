@@ -2,6 +2,7 @@ package triggers
 
 import (
 	"regexp"
+	"time"
 )
 
 type MergeableTriggers struct {
@@ -9,17 +10,41 @@ type MergeableTriggers struct {
 }
 
 type mergeableTrigger struct {
-	matchLines map[string]struct{}
-	doReboot   bool
-	highImpact bool
+	matchLines  map[string]struct{}
+	doReboot    bool
+	highImpact  bool
+	after       map[string]struct{}
+	healthCheck *HealthCheck
+}
+
+// HealthCheck describes how to check that a service is healthy after it has
+// been started. Exactly one of Command, HttpUrl or TcpAddress should be given.
+// The check is repeated until it succeeds or the timeout expires.
+type HealthCheck struct {
+	Command        []string `json:",omitempty"` // Must exit successfully.
+	HttpUrl        string   `json:",omitempty"` // Must return a 2xx status.
+	TcpAddress     string   `json:",omitempty"` // Must accept a connection.
+	TimeoutSeconds uint     `json:",omitempty"` // Default: 30.
 }
 
 type Trigger struct {
 	MatchLines   []string
 	matchRegexes []*regexp.Regexp
 	Service      string
-	DoReboot     bool `json:",omitempty"`
-	HighImpact   bool `json:",omitempty"`
+	DoReboot     bool         `json:",omitempty"`
+	HighImpact   bool         `json:",omitempty"`
+	After        []string     `json:",omitempty"` // Services to start first.
+	HealthCheck  *HealthCheck `json:",omitempty"`
+}
+
+func (healthCheck *HealthCheck) ReplaceStrings(
+	replaceFunc func(string) string) {
+	healthCheck.replaceStrings(replaceFunc)
+}
+
+// Timeout returns the health check timeout, applying the default.
+func (healthCheck *HealthCheck) Timeout() time.Duration {
+	return healthCheck.timeout()
 }
 
 func (trigger *Trigger) ReplaceStrings(replaceFunc func(string) string) {
@@ -74,6 +99,8 @@ func (triggers *Triggers) Match(line string) {
 	triggers.match(line)
 }
 
+// GetMatchedTriggers returns the matched triggers in start order (see
+// SortByDependencies) and resets the matching state.
 func (triggers *Triggers) GetMatchedTriggers() []*Trigger {
 	return triggers.getMatchedTriggers()
 }
@@ -81,3 +108,11 @@ func (triggers *Triggers) GetMatchedTriggers() []*Trigger {
 func (triggers *Triggers) GetMatchStatistics() (nMatched, nUnmatched uint) {
 	return triggers.getMatchStatistics()
 }
+
+// SortByDependencies sorts the triggers in start order. A trigger is placed
+// after the triggers for the services listed in its After field, where those
+// are present. Otherwise triggers are ordered by service name. Dependency
+// cycles are broken by service name.
+func SortByDependencies(triggers []*Trigger) {
+	sortByDependencies(triggers)
+}
@@ -1,9 +1,5 @@
 package triggers
 
-import (
-	"sort"
-)
-
 func (triggers *Triggers) match(line string) {
 	triggers.compile()
 	if triggers.matchedTriggers == nil {
@@ -30,9 +26,7 @@ func (triggers *Triggers) getMatchedTriggers() []*Trigger {
 	for trigger := range triggers.matchedTriggers {
 		mTriggers = append(mTriggers, trigger)
 	}
-	sort.Slice(mTriggers, func(i, j int) bool {
-		return mTriggers[i].Service < mTriggers[j].Service
-	})
+	sortByDependencies(mTriggers)
 	triggers.matchedTriggers = nil
 	triggers.unmatchedTriggers = nil
 	return mTriggers
@@ -21,11 +21,18 @@ func (mt *MergeableTriggers) exportTriggers() *Triggers {
 			matchLines = append(matchLines, matchLine)
 		}
 		sort.Strings(matchLines)
+		var after []string
+		for service := range trigger.after {
+			after = append(after, service)
+		}
+		sort.Strings(after)
 		triggerList = append(triggerList, &Trigger{
-			MatchLines: matchLines,
-			Service:    service,
-			DoReboot:   trigger.doReboot,
-			HighImpact: trigger.highImpact,
+			MatchLines:  matchLines,
+			Service:     service,
+			DoReboot:    trigger.doReboot,
+			HighImpact:  trigger.highImpact,
+			After:       after,
+			HealthCheck: trigger.healthCheck,
 		})
 	}
 	triggers := New()
@@ -56,5 +63,14 @@ func (mt *MergeableTriggers) merge(triggers *Triggers) {
 		if trigger.HighImpact {
 			trig.highImpact = true
 		}
+		for _, service := range trigger.After {
+			if trig.after == nil {
+				trig.after = make(map[string]struct{})
+			}
+			trig.after[service] = struct{}{}
+		}
+		if trigger.HealthCheck != nil {
+			trig.healthCheck = trigger.HealthCheck
+		}
 	}
 }
@@ -0,0 +1,61 @@
+package triggers
+
+import (
+	"sort"
+	"time"
+)
+
+const defaultHealthCheckTimeout = 30 * time.Second
+
+func (healthCheck *HealthCheck) timeout() time.Duration {
+	if healthCheck.TimeoutSeconds < 1 {
+		return defaultHealthCheckTimeout
+	}
+	return time.Duration(healthCheck.TimeoutSeconds) * time.Second
+}
+
+func sortByDependencies(triggers []*Trigger) {
+	sort.Slice(triggers, func(i, j int) bool {
+		return triggers[i].Service < triggers[j].Service
+	})
+	present := make(map[string]struct{}, len(triggers))
+	for _, trigger := range triggers {
+		present[trigger.Service] = struct{}{}
+	}
+	// Count the dependencies which are present for each trigger.
+	numPending := make(map[*Trigger]int, len(triggers))
+	dependents := make(map[string][]*Trigger)
+	for _, trigger := range triggers {
+		for _, service := range trigger.After {
+			if _, ok := present[service]; ok && service != trigger.Service {
+				numPending[trigger]++
+				dependents[service] = append(dependents[service], trigger)
+			}
+		}
+	}
+	ordered := make([]*Trigger, 0, len(triggers))
+	done := make(map[*Trigger]struct{}, len(triggers))
+	for len(ordered) < len(triggers) {
+		// Pick the first (by name) trigger which is ready. If none are ready
+		// there is a cycle: pick the first remaining trigger.
+		var next *Trigger
+		for _, trigger := range triggers {
+			if _, ok := done[trigger]; ok {
+				continue
+			}
+			if numPending[trigger] < 1 {
+				next = trigger
+				break
+			}
+			if next == nil {
+				next = trigger
+			}
+		}
+		ordered = append(ordered, next)
+		done[next] = struct{}{}
+		for _, trigger := range dependents[next.Service] {
+			numPending[trigger]--
+		}
+	}
+	copy(triggers, ordered)
+}
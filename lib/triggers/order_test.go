@@ -0,0 +1,48 @@
+package triggers
+
+import (
+	"testing"
+)
+
+func TestSortByDependencies(t *testing.T) {
+	tests := []struct {
+		triggers []*Trigger
+		expected []string
+	}{
+		{
+			[]*Trigger{
+				{Service: "web", After: []string{"db", "cache"}},
+				{Service: "db"},
+				{Service: "cache", After: []string{"missing"}},
+				{Service: "agent"},
+			},
+			[]string{"agent", "cache", "db", "web"},
+		},
+		{
+			[]*Trigger{
+				{Service: "a", After: []string{"c"}},
+				{Service: "b"},
+				{Service: "c", After: []string{"b"}},
+			},
+			[]string{"b", "c", "a"},
+		},
+		{
+			[]*Trigger{
+				{Service: "x", After: []string{"y"}},
+				{Service: "y", After: []string{"x"}},
+				{Service: "z", After: []string{"y"}},
+			},
+			[]string{"x", "y", "z"},
+		},
+	}
+	for _, test := range tests {
+		SortByDependencies(test.triggers)
+		for index, trigger := range test.triggers {
+			if trigger.Service != test.expected[index] {
+				t.Errorf("expected order: %v, got: %s at %d",
+					test.expected, trigger.Service, index)
+				break
+			}
+		}
+	}
+}
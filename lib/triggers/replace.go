@@ -5,6 +5,21 @@ func (trigger *Trigger) replaceStrings(replaceFunc func(string) string) {
 		trigger.MatchLines[index] = replaceFunc(str)
 	}
 	trigger.Service = replaceFunc(trigger.Service)
+	for index, service := range trigger.After {
+		trigger.After[index] = replaceFunc(service)
+	}
+	if trigger.HealthCheck != nil {
+		trigger.HealthCheck.ReplaceStrings(replaceFunc)
+	}
+}
+
+func (healthCheck *HealthCheck) replaceStrings(
+	replaceFunc func(string) string) {
+	for index, arg := range healthCheck.Command {
+		healthCheck.Command[index] = replaceFunc(arg)
+	}
+	healthCheck.HttpUrl = replaceFunc(healthCheck.HttpUrl)
+	healthCheck.TcpAddress = replaceFunc(healthCheck.TcpAddress)
 }
 
 func (triggers *Triggers) replaceStrings(replaceFunc func(string) string) {
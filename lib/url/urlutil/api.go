@@ -33,9 +33,10 @@ func (rc *CachedReadCloser) SaveCache() error {
 }
 
 // WatchUrl watches the URL given by url and yields a new io.ReadCloser
-// periodically. If the URL is a local file, a new io.ReadCloser is yielded
-// when a new inode is found and it is a regular file. If url is a HTTP/HTTPS
-// URL a new io.ReadCloser is yielded every checkInterval.
+// periodically. If the URL is a local file (a file:// URL or a plain
+// pathname), a new io.ReadCloser is yielded when a new inode is found and it is
+// a regular file. If url is a HTTP/HTTPS URL a new io.ReadCloser is yielded
+// every checkInterval.
 // Each yielded io.ReadCloser must be closed after use.
 // Any errors are logged to the logger.
 func WatchUrl(url string, checkInterval time.Duration,
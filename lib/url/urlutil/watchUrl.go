@@ -17,6 +17,9 @@ func watchUrl(rawurl string, checkInterval time.Duration,
 	if err != nil {
 		return nil, err
 	}
+	if u.Scheme == "" { // A plain pathname.
+		return fsutil.WatchFile(rawurl, logger), nil
+	}
 	if u.Scheme == "file" {
 		return fsutil.WatchFile(u.Path, logger), nil
 	}
@@ -15,3 +15,9 @@ func (t *srpcType) AddObjects(conn *srpc.Conn) error {
 	return lib.AddObjectsWithMaster(conn, conn, conn, t.objectServer,
 		t.replicationMaster, t.logger)
 }
+
+// AddObjectsWithCompression is the same as AddObjects. Clients use it to detect
+// that the server supports compressed objects.
+func (t *srpcType) AddObjectsWithCompression(conn *srpc.Conn) error {
+	return t.AddObjects(conn)
+}
@@ -0,0 +1,48 @@
+package rpcd
+
+import (
+	"io"
+
+	"github.com/Symantec/Dominator/lib/rsync"
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/objectserver"
+)
+
+func (objSrv *srpcType) GetObjectDelta(conn *srpc.Conn) error {
+	defer conn.Flush()
+	exclusive.RLock()
+	defer exclusive.RUnlock()
+	objSrv.getSemaphore <- true
+	defer releaseSemaphore(objSrv.getSemaphore)
+	var request objectserver.GetObjectDeltaRequest
+	var response objectserver.GetObjectDeltaResponse
+	if err := conn.Decode(&request); err != nil {
+		response.Error = err.Error()
+		return conn.Encode(response)
+	}
+	size, reader, err := objSrv.objectServer.GetObject(request.Hash)
+	if err != nil {
+		response.Error = err.Error()
+		return conn.Encode(response)
+	}
+	defer reader.Close()
+	readSeeker, ok := reader.(io.ReadSeeker)
+	if !ok {
+		response.Error = "delta transfer not supported for object"
+		return conn.Encode(response)
+	}
+	response.Size = size
+	if err := conn.Encode(response); err != nil {
+		return err
+	}
+	if err := conn.Flush(); err != nil {
+		return err
+	}
+	if err := rsync.ServeChunks(conn, conn, conn, readSeeker, size); err != nil {
+		objSrv.logger.Printf("Error serving chunks for: %x: %s\n",
+			request.Hash, err)
+		return err
+	}
+	objSrv.logger.Debugf(0, "GetObjectDelta(%x) served\n", request.Hash)
+	return nil
+}
@@ -1,12 +1,14 @@
 package rpcd
 
 import (
+	"compress/gzip"
 	"errors"
 	"fmt"
 	"io"
 	"sync"
 
 	"github.com/Symantec/Dominator/lib/hash"
+	objserver "github.com/Symantec/Dominator/lib/objectserver"
 	"github.com/Symantec/Dominator/lib/srpc"
 	"github.com/Symantec/Dominator/proto/objectserver"
 )
@@ -62,23 +64,52 @@ func (objSrv *srpcType) GetObjects(conn *srpc.Conn) error {
 		return conn.Encode(response)
 	}
 	defer objectsReader.Close()
+	if request.Compression == objectserver.CompressionGzip {
+		response.Compression = objectserver.CompressionGzip
+	}
 	if err := conn.Encode(response); err != nil {
 		return err
 	}
 	conn.Flush()
 	buffer := make([]byte, 32<<10)
+	var gzipWriter *gzip.Writer
+	if response.Compression == objectserver.CompressionGzip {
+		gzipWriter, _ = gzip.NewWriterLevel(conn, gzip.BestSpeed)
+	}
+	compressedReader, _ := objectsReader.(objserver.CompressedObjectsReader)
 	for _, hashVal := range request.Hashes {
-		length, reader, err := objectsReader.NextObject()
+		var length uint64
+		var reader io.ReadCloser
+		var compressed bool
+		var err error
+		if gzipWriter != nil && compressedReader != nil {
+			length, reader, compressed, err =
+				compressedReader.NextCompressedObject()
+		} else {
+			length, reader, err = objectsReader.NextObject()
+		}
 		if err != nil {
 			objSrv.logger.Println(err)
 			return err
 		}
-		nCopied, err := io.CopyBuffer(conn, reader, buffer)
+		var nCopied int64
+		if gzipWriter == nil || compressed {
+			nCopied, err = io.CopyBuffer(conn, reader, buffer)
+		} else {
+			gzipWriter.Reset(conn)
+			nCopied, err = io.CopyBuffer(gzipWriter, reader, buffer)
+			if err == nil {
+				err = gzipWriter.Close()
+			}
+		}
 		reader.Close()
 		if err != nil {
 			objSrv.logger.Printf("Error copying: %s\n", err)
 			return err
 		}
+		if compressed {
+			continue // Stored stream is not decompressed to check length.
+		}
 		if nCopied != int64(length) {
 			txt := fmt.Sprintf("Expected length: %d, got: %d for: %x",
 				length, nCopied, hashVal)
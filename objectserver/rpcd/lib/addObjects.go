@@ -27,9 +27,14 @@ func addObjects(conn *srpc.Conn, decoder srpc.Decoder, encoder srpc.Encoder,
 		if request.Length < 1 {
 			break
 		}
-		var err error
-		response.Hash, response.Added, err =
-			adder.AddObject(conn, request.Length, request.ExpectedHash)
+		reader, finish, err := newObjectReader(conn, request.Compression)
+		if err == nil {
+			response.Hash, response.Added, err =
+				adder.AddObject(reader, request.Length, request.ExpectedHash)
+			if err == nil {
+				err = finish()
+			}
+		}
 		response.ErrorString = errors.ErrorToString(err)
 		if err := encoder.Encode(response); err != nil {
 			return errors.New("error encoding: " + err.Error())
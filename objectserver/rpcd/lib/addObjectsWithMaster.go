@@ -56,9 +56,17 @@ func addObjectsWithMaster(conn *srpc.Conn, decoder srpc.Decoder,
 		if request.Length < 1 {
 			break
 		}
+		reader, finish, err := newObjectReader(conn, request.Compression)
+		if err != nil {
+			sendError(outgoingQueueSendChan, err)
+			break
+		}
 		var data []byte
-		response.Hash, data, err = objSrv.StashOrVerifyObject(conn,
+		response.Hash, data, err = objSrv.StashOrVerifyObject(reader,
 			request.Length, request.ExpectedHash)
+		if err == nil {
+			err = finish()
+		}
 		if err != nil {
 			sendError(outgoingQueueSendChan, err)
 			break
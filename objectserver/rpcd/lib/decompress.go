@@ -0,0 +1,40 @@
+package lib
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/objectserver"
+)
+
+// newObjectReader returns a reader for the uncompressed object data which
+// follow an AddObjectRequest and a function which must be called after the
+// object has been read, to consume the remainder of any compressed stream.
+func newObjectReader(conn *srpc.Conn, compression objectserver.Compression) (
+	io.Reader, func() error, error) {
+	switch compression {
+	case objectserver.CompressionNone:
+		return conn, func() error { return nil }, nil
+	case objectserver.CompressionGzip:
+		// The connection is an io.ByteReader, so the gzip reader will not read
+		// beyond the end of the stream for this object.
+		gzipReader, err := gzip.NewReader(conn)
+		if err != nil {
+			return nil, nil, err
+		}
+		gzipReader.Multistream(false)
+		return gzipReader, func() error {
+			if nRead, err := io.Copy(ioutil.Discard, gzipReader); err != nil {
+				return err
+			} else if nRead > 0 {
+				return errors.New("compressed object longer than length")
+			}
+			return nil
+		}, nil
+	}
+	return nil, nil, errors.New("unsupported compression: " +
+		compression.String())
+}
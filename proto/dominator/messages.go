@@ -1,9 +1,25 @@
 package dominator
 
 import (
+	"time"
+
+	"github.com/Symantec/Dominator/lib/tags"
 	"github.com/Symantec/Dominator/proto/sub"
 )
 
+const (
+	RolloutStateRunning    RolloutState = 0
+	RolloutStatePaused     RolloutState = 1
+	RolloutStateCompleted  RolloutState = 2
+	RolloutStateHalted     RolloutState = 3
+	RolloutStateRolledBack RolloutState = 4
+	RolloutStateAborted    RolloutState = 5
+)
+
+type AbortRolloutRequest struct{}
+
+type AbortRolloutResponse struct{}
+
 type ClearSafetyShutoffRequest struct {
 	Hostname string
 }
@@ -32,12 +48,97 @@ type GetDefaultImageResponse struct {
 	ImageName string
 }
 
+type GetRolloutStatusRequest struct{}
+
+type GetRolloutStatusResponse struct {
+	Status *RolloutStatus // nil if there is no rollout.
+}
+
 type GetSubsConfigurationRequest struct{}
 
 type GetSubsConfigurationResponse sub.Configuration
 
+type PauseRolloutRequest struct {
+	Resume bool // If true, resume a paused rollout.
+}
+
+type PauseRolloutResponse struct{}
+
+type PlanUpdateRequest struct {
+	Hostnames          []string `json:",omitempty"` // If empty, all subs.
+	ImageName          string   `json:",omitempty"` // Default: required image.
+	IncludeSubPlans    bool     `json:",omitempty"`
+	SubsRequiringImage string   `json:",omitempty"` // Select subs by image.
+}
+
+type PlanUpdateResponse struct {
+	Report   UpdatePlanReport
+	SubPlans []SubUpdatePlan `json:",omitempty"`
+}
+
+type RolloutSpec struct {
+	ImageName         string        // The image to roll out.
+	PreviousImageName string        // Subs requiring this image are eligible.
+	Waves             []RolloutWave `json:",omitempty"`
+	FailureThreshold  float64       // Maximum ratio of failed subs in rollout.
+	AutoRollback      bool          `json:",omitempty"`
+	WaveSoakTime      time.Duration `json:",omitempty"`
+}
+
+type RolloutState uint
+
+type RolloutStatus struct {
+	Spec            RolloutSpec
+	State           RolloutState
+	StartedBy       string `json:",omitempty"`
+	StartTime       time.Time
+	StateChangeTime time.Time
+	Message         string `json:",omitempty"`
+	CurrentWave     uint   // Index into Spec.Waves.
+	NumEligible     uint
+	NumAdmitted     uint
+	NumSynced       uint
+	NumFailed       uint
+	FailedSubs      []string `json:",omitempty"`
+}
+
+// RolloutWave describes the cumulative set of eligible subs which should
+// receive the new image once the wave has started. Only subs which match all
+// of the RequiredTags are counted. If Percent is zero, all matching subs are
+// included.
+type RolloutWave struct {
+	Percent      uint      `json:",omitempty"`
+	RequiredTags tags.Tags `json:",omitempty"`
+}
+
+type SubUpdatePlan struct {
+	Hostname          string
+	ImageName         string
+	Error             string   `json:",omitempty"`
+	DirectoriesToMake []string `json:",omitempty"`
+	HardlinksToMake   []string `json:",omitempty"`
+	InodesToChange    []string `json:",omitempty"`
+	InodesToMake      []string `json:",omitempty"`
+	PathsToDelete     []string `json:",omitempty"`
+	ServicesToRestart []string `json:",omitempty"`
+	Reboot            bool     `json:",omitempty"`
+}
+
 type SetDefaultImageRequest struct {
 	ImageName string
 }
 
 type SetDefaultImageResponse struct{}
+
+type StartRolloutRequest RolloutSpec
+
+type StartRolloutResponse struct{}
+
+type UpdatePlanReport struct {
+	NumSubs         uint
+	NumSubsToUpdate uint
+	FailedSubs      map[string]string `json:",omitempty"` // Value: error.
+	PathCounts      map[string]uint   `json:",omitempty"` // Key: pathname.
+	ServiceCounts   map[string]uint   `json:",omitempty"` // Key: service.
+	SubsToReboot    []string          `json:",omitempty"`
+}
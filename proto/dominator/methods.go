@@ -0,0 +1,58 @@
+package dominator
+
+import (
+	"errors"
+)
+
+const rolloutStateUnknown = "UNKNOWN RolloutState"
+
+var (
+	rolloutStateToText = map[RolloutState]string{
+		RolloutStateRunning:    "running",
+		RolloutStatePaused:     "paused",
+		RolloutStateCompleted:  "completed",
+		RolloutStateHalted:     "halted",
+		RolloutStateRolledBack: "rolled back",
+		RolloutStateAborted:    "aborted",
+	}
+	textToRolloutState map[string]RolloutState
+)
+
+func init() {
+	textToRolloutState = make(map[string]RolloutState,
+		len(rolloutStateToText))
+	for state, text := range rolloutStateToText {
+		textToRolloutState[text] = state
+	}
+}
+
+// IsActive returns true if the rollout may still admit subs.
+func (state RolloutState) IsActive() bool {
+	return state == RolloutStateRunning || state == RolloutStatePaused
+}
+
+func (state RolloutState) MarshalText() ([]byte, error) {
+	if text := state.String(); text == rolloutStateUnknown {
+		return nil, errors.New(text)
+	} else {
+		return []byte(text), nil
+	}
+}
+
+func (state RolloutState) String() string {
+	if text, ok := rolloutStateToText[state]; ok {
+		return text
+	} else {
+		return rolloutStateUnknown
+	}
+}
+
+func (state *RolloutState) UnmarshalText(text []byte) error {
+	txt := string(text)
+	if val, ok := textToRolloutState[txt]; ok {
+		*state = val
+		return nil
+	} else {
+		return errors.New("unknown RolloutState: " + txt)
+	}
+}
@@ -16,6 +16,43 @@ type ChangeMachineTagsResponse struct {
 	Error string
 }
 
+// The CreateVm() RPC is fully streamed. The client sends a single
+// CreateVmRequest message, followed by any RAW image data and user data (as
+// for Hypervisor.CreateVm) unless DryRun is true. The server sends a stream of
+// CreateVmResponse messages until Final is true or Error is set.
+
+type CreateVmRequest struct {
+	DryRun    bool // If true, only choose the Hypervisor.
+	Location  string
+	Placement PlacementPolicy
+	proto.CreateVmRequest
+}
+
+type CreateVmResponse struct {
+	HypervisorAddress string // host:port. Sent once chosen.
+	PlacementReason   string // Why the Hypervisor was chosen.
+	proto.CreateVmResponse
+}
+
+// The DrainHypervisor() RPC is fully streamed. The client sends a single
+// DrainHypervisorRequest message. The Hypervisor is cordoned and its VMs are
+// migrated to other Hypervisors. The server sends a stream of
+// DrainHypervisorResponse messages until Final is true or Error is set. If any
+// VMs failed to migrate, the Final message will also have Error set.
+
+type DrainHypervisorRequest struct {
+	Hostname    string
+	MaxParallel uint // Maximum concurrent migrations. Zero means default.
+}
+
+type DrainHypervisorResponse struct {
+	Error           string `json:",omitempty"`
+	Final           bool   `json:",omitempty"`
+	ProgressMessage string `json:",omitempty"`
+	VmError         string `json:",omitempty"` // Migration of VM failed.
+	VmIpAddress     net.IP `json:",omitempty"` // VM the message is about.
+}
+
 type GetHypervisorForVMRequest struct {
 	IpAddress net.IP
 }
@@ -75,6 +112,15 @@ type ListHypervisorsInLocationResponse struct {
 	Error               string
 }
 
+type ListQuotasRequest struct {
+	Location string // Empty: all locations.
+}
+
+type ListQuotasResponse struct {
+	Quotas []proto.OwnerQuota // Used is the usage in the whole location.
+	Error  string
+}
+
 type ListVMsInLocationRequest struct {
 	Location string
 }
@@ -109,3 +155,39 @@ type NetworkEntry struct {
 	HostIpAddress  net.IP       `json:",omitempty"`
 	HostMacAddress HardwareAddr `json:",omitempty"`
 }
+
+// PlacementPolicy controls where a VM is placed in relation to existing VMs.
+type PlacementPolicy struct {
+	AffinityTagKeys []string `json:",omitempty"` // Prefer same tag values.
+	SpreadByOwner   bool     `json:",omitempty"` // Avoid same primary owner.
+	SpreadTagKeys   []string `json:",omitempty"` // Avoid same tag values.
+	StrictSpread    bool     `json:",omitempty"` // Fail rather than co-locate.
+}
+
+type SetMachineCordonRequest struct {
+	Cordoned bool // If true, no new VMs will be placed on the Hypervisor.
+	Hostname string
+}
+
+type SetMachineCordonResponse struct {
+	Error string
+}
+
+// VmGroup is a set of identical VMs which the Fleet Manager maintains. It is
+// defined in a topology directory and its VMs are placed in that directory.
+type VmGroup struct {
+	ImageName          string          // Image or image stream.
+	Locations          []string        `json:",omitempty"` // Relative. Spread.
+	MaxUnavailable     uint            `json:",omitempty"` // Default: 1.
+	MemoryInMiB        uint64          // Per VM.
+	MilliCPUs          uint            // Per VM.
+	MinimumFreeBytes   uint64          `json:",omitempty"`
+	OwnerGroups        []string        `json:",omitempty"`
+	OwnerUsers         []string        // The first is the primary owner.
+	Placement          PlacementPolicy `json:",omitempty"`
+	Replicas           uint
+	SecondarySubnetIDs []string       `json:",omitempty"`
+	SecondaryVolumes   []proto.Volume `json:",omitempty"`
+	SubnetId           string         `json:",omitempty"`
+	Tags               tags.Tags      `json:",omitempty"`
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"net"
+	"strings"
 )
 
 func listsEqual(left, right []string) bool {
@@ -108,3 +109,86 @@ func (addr *HardwareAddr) UnmarshalText(text []byte) error {
 		return nil
 	}
 }
+
+func (left *PlacementPolicy) Equal(right *PlacementPolicy) bool {
+	if !listsEqual(left.AffinityTagKeys, right.AffinityTagKeys) {
+		return false
+	}
+	if left.SpreadByOwner != right.SpreadByOwner {
+		return false
+	}
+	if !listsEqual(left.SpreadTagKeys, right.SpreadTagKeys) {
+		return false
+	}
+	return left.StrictSpread == right.StrictSpread
+}
+
+func (group *VmGroup) CheckValid() error {
+	if group.ImageName == "" {
+		return errors.New("no image specified")
+	}
+	if group.MemoryInMiB < 1 {
+		return errors.New("no memory specified")
+	}
+	if group.MilliCPUs < 1 {
+		return errors.New("no CPUs specified")
+	}
+	if len(group.OwnerUsers) < 1 {
+		return errors.New("no owner users specified")
+	}
+	for _, location := range group.Locations {
+		if location == "" || strings.HasPrefix(location, "/") ||
+			strings.Contains(location, "..") {
+			return errors.New("bad location: " + location)
+		}
+	}
+	return nil
+}
+
+func (left *VmGroup) Equal(right *VmGroup) bool {
+	if left.ImageName != right.ImageName {
+		return false
+	}
+	if !listsEqual(left.Locations, right.Locations) {
+		return false
+	}
+	if left.MaxUnavailable != right.MaxUnavailable {
+		return false
+	}
+	if left.MemoryInMiB != right.MemoryInMiB {
+		return false
+	}
+	if left.MilliCPUs != right.MilliCPUs {
+		return false
+	}
+	if left.MinimumFreeBytes != right.MinimumFreeBytes {
+		return false
+	}
+	if !listsEqual(left.OwnerGroups, right.OwnerGroups) {
+		return false
+	}
+	if !listsEqual(left.OwnerUsers, right.OwnerUsers) {
+		return false
+	}
+	if !left.Placement.Equal(&right.Placement) {
+		return false
+	}
+	if left.Replicas != right.Replicas {
+		return false
+	}
+	if !listsEqual(left.SecondarySubnetIDs, right.SecondarySubnetIDs) {
+		return false
+	}
+	if len(left.SecondaryVolumes) != len(right.SecondaryVolumes) {
+		return false
+	}
+	for index, leftVolume := range left.SecondaryVolumes {
+		if leftVolume != right.SecondaryVolumes[index] {
+			return false
+		}
+	}
+	if left.SubnetId != right.SubnetId {
+		return false
+	}
+	return left.Tags.Equal(right.Tags)
+}
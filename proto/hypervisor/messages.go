@@ -4,6 +4,7 @@ import (
 	"net"
 	"time"
 
+	"github.com/Symantec/Dominator/lib/hash"
 	"github.com/Symantec/Dominator/lib/tags"
 )
 
@@ -33,9 +34,34 @@ type AcknowledgeVmResponse struct {
 	Error string
 }
 
+type AddVmVolumeRequest struct {
+	AccessToken []byte
+	IpAddress   net.IP
+	Size        uint64
+}
+
+type AddVmVolumeResponse struct {
+	Error string
+}
+
 type Address struct {
-	IpAddress  net.IP `json:",omitempty"`
-	MacAddress string
+	IpAddress   net.IP `json:",omitempty"`
+	Ipv6Address net.IP `json:",omitempty"`
+	MacAddress  string
+}
+
+type BackupVmRequest struct {
+	IpAddress         net.IP
+	ForceIfNotStopped bool
+	ObjectServer      string // host:port. If empty, the backup server is used.
+	RootOnly          bool
+}
+
+type BackupVmResponse struct {
+	Error        string
+	ManifestHash hash.Hash // Identifies the backup.
+	NewBytes     uint64    // Bytes not already present in the object server.
+	TotalBytes   uint64    // Bytes excluding zero-filled chunks.
 }
 
 type BecomePrimaryVmOwnerRequest struct {
@@ -83,6 +109,24 @@ type ChangeVmDestroyProtectionResponse struct {
 	Error string
 }
 
+type ChangeVmFirewallRequest struct {
+	IpAddress net.IP
+	Firewall  *FirewallPolicy // If nil, all traffic is allowed.
+}
+
+type ChangeVmFirewallResponse struct {
+	Error string
+}
+
+type ChangeVmLimitsRequest struct {
+	IpAddress net.IP
+	Limits    *VmLimits // If nil, the VM is not throttled.
+}
+
+type ChangeVmLimitsResponse struct {
+	Error string
+}
+
 type ChangeVmOwnerUsersRequest struct {
 	IpAddress  net.IP
 	OwnerUsers []string
@@ -92,6 +136,19 @@ type ChangeVmOwnerUsersResponse struct {
 	Error string
 }
 
+// ChangeVmSizeRequest changes the CPU and memory of a stopped VM. A zero value
+// leaves the corresponding size unchanged.
+type ChangeVmSizeRequest struct {
+	AccessToken []byte
+	IpAddress   net.IP
+	MemoryInMiB uint64
+	MilliCPUs   uint
+}
+
+type ChangeVmSizeResponse struct {
+	Error string
+}
+
 type ChangeVmTagsRequest struct {
 	IpAddress net.IP
 	Tags      tags.Tags
@@ -153,6 +210,8 @@ type CreateVmRequest struct {
 	ImageDataSize    uint64
 	ImageTimeout     time.Duration
 	MinimumFreeBytes uint64
+	OnBehalfOfGroups []string // Only honoured for callers with method access.
+	OnBehalfOfUser   string   // Only honoured for callers with method access.
 	RoundupPower     uint64
 	SecondaryVolumes []Volume
 	SkipBootloader   bool
@@ -215,6 +274,7 @@ type DiscardVmOldUserDataResponse struct {
 
 type DiscardVmSnapshotRequest struct {
 	IpAddress net.IP
+	Name      string // If empty, the unnamed snapshot.
 }
 
 type DiscardVmSnapshotResponse struct {
@@ -240,19 +300,56 @@ type ExportLocalVmResponse struct {
 // The client may or may not send GetUpdateRequest messages to the server.
 // The server sends a stream of Update messages.
 
+// FirewallPeer is a VM on another Hypervisor which may be referenced by tag in
+// firewall rules.
+type FirewallPeer struct {
+	IpAddress net.IP
+	Tags      tags.Tags
+}
+
+// FirewallPolicy lists the traffic which is allowed to and from a VM. All
+// ingress traffic not matching a rule is dropped. If there are no egress rules
+// all egress traffic is allowed. Traffic for established connections, ARP and
+// DHCP is always allowed. Traffic which is spoofed is always dropped.
+type FirewallPolicy struct {
+	Egress  []FirewallRule `json:",omitempty"`
+	Groups  []string       `json:",omitempty"` // Named rule groups to include.
+	Ingress []FirewallRule `json:",omitempty"`
+}
+
+// FirewallRule matches traffic to/from remote addresses. If neither Networks
+// nor RemoteTags are specified, all addresses are matched. Ports are the
+// destination ports, and are only matched for TCP and UDP.
+type FirewallRule struct {
+	FirstPort  uint16    `json:",omitempty"`
+	LastPort   uint16    `json:",omitempty"` // If zero, FirstPort.
+	Networks   []string  `json:",omitempty"` // CIDRs or addresses.
+	Protocol   string    `json:",omitempty"` // icmp, tcp, udp or empty: all.
+	RemoteTags tags.Tags `json:",omitempty"` // VMs with all these tags.
+}
+
+// FirewallRuleGroup is a named set of rules distributed by the Fleet Manager.
+type FirewallRuleGroup struct {
+	Egress  []FirewallRule `json:",omitempty"`
+	Ingress []FirewallRule `json:",omitempty"`
+}
+
 type GetUpdateRequest struct{}
 
 type Update struct {
-	HaveAddressPool  bool               `json:",omitempty"`
-	AddressPool      []Address          `json:",omitempty"` // Used & free.
-	NumFreeAddresses map[string]uint    `json:",omitempty"` // Key: subnet ID.
-	HealthStatus     string             `json:",omitempty"`
-	HaveSerialNumber bool               `json:",omitempty"`
-	SerialNumber     string             `json:",omitempty"`
-	HaveSubnets      bool               `json:",omitempty"`
-	Subnets          []Subnet           `json:",omitempty"`
-	HaveVMs          bool               `json:",omitempty"`
-	VMs              map[string]*VmInfo `json:",omitempty"` // Key: IP address.
+	HaveAddressPool  bool                `json:",omitempty"`
+	AddressPool      []Address           `json:",omitempty"` // Used & free.
+	NumFreeAddresses map[string]uint     `json:",omitempty"` // Key: subnet ID.
+	HealthStatus     string              `json:",omitempty"`
+	Resources        *Resources          `json:",omitempty"`
+	HaveSerialNumber bool                `json:",omitempty"`
+	SerialNumber     string              `json:",omitempty"`
+	HaveSubnets      bool                `json:",omitempty"`
+	Subnets          []Subnet            `json:",omitempty"`
+	HaveVMs          bool                `json:",omitempty"`
+	VMs              map[string]*VmInfo  `json:",omitempty"` // Key: IP address.
+	HaveVmUsage      bool                `json:",omitempty"`
+	VmUsage          map[string]*VmUsage `json:",omitempty"` // Key: IP address.
 }
 
 type GetVmAccessTokenRequest struct {
@@ -296,6 +393,19 @@ type GetVmVolumeResponse struct {
 	Error string
 }
 
+// GrowVmVolumeRequest grows a raw volume to the specified size. Volumes of a
+// running VM are grown online.
+type GrowVmVolumeRequest struct {
+	AccessToken []byte
+	IpAddress   net.IP
+	Size        uint64 // New size.
+	VolumeIndex uint
+}
+
+type GrowVmVolumeResponse struct {
+	Error string
+}
+
 type ImportLocalVmRequest struct {
 	VerificationCookie []byte `json:",omitempty"`
 	VmInfo
@@ -315,6 +425,15 @@ type ListVMsResponse struct {
 	IpAddresses []net.IP
 }
 
+type ListVmSnapshotsRequest struct {
+	IpAddress net.IP
+}
+
+type ListVmSnapshotsResponse struct {
+	Error     string
+	Snapshots []VmSnapshot
+}
+
 type ListVolumeDirectoriesRequest struct{}
 
 type ListVolumeDirectoriesResponse struct {
@@ -329,6 +448,9 @@ type LocalVolume struct {
 
 type LocalVmInfo struct {
 	VmInfo
+	SnapshotPolicy  *SnapshotPolicy `json:",omitempty"`
+	Snapshots       []VmSnapshot    `json:",omitempty"`
+	TapDevices      []string        `json:",omitempty"` // While running.
 	VolumeLocations []LocalVolume
 }
 
@@ -336,6 +458,7 @@ type MigrateVmRequest struct {
 	AccessToken      []byte
 	DhcpTimeout      time.Duration
 	IpAddress        net.IP
+	Live             bool // If true, try to migrate without stopping the VM.
 	SourceHypervisor string
 }
 
@@ -350,6 +473,29 @@ type MigrateVmResponseResponse struct {
 	Commit bool
 }
 
+// MigrateVmStateRequest is sent to the source Hypervisor during a live
+// migration. If Probe is true, the source checks whether the VM can be live
+// migrated. If Resume is true, a VM paused by a completed migration is resumed.
+// Otherwise the volumes of the VM are mirrored to the NBD server at NbdAddress
+// while the VM is running, then the memory and device state of the VM is sent
+// to DestinationAddress and the VM is left paused once complete. Both
+// addresses must be an IP address and port.
+type MigrateVmStateRequest struct {
+	AccessToken        []byte
+	DestinationAddress string
+	IpAddress          net.IP
+	NbdAddress         string
+	Probe              bool
+	Resume             bool
+}
+
+type MigrateVmStateResponse struct { // Multiple responses are sent.
+	Error           string
+	Final           bool // If true, this is the final response.
+	ProgressMessage string
+	QemuVersion     string // Sent in response to a probe.
+}
+
 type NetbootMachineRequest struct {
 	Address                      Address
 	Files                        map[string][]byte
@@ -365,6 +511,17 @@ type NetbootMachineResponse struct {
 	Error string
 }
 
+// OwnerQuota is a quota for the VMs of an owner user or group in a location.
+// Used is the usage counted against the quota on other Hypervisors in the
+// location, or in the whole location when listing quotas.
+type OwnerQuota struct {
+	Group    string `json:",omitempty"`
+	Limit    Quota
+	Location string
+	Used     Quota
+	User     string `json:",omitempty"`
+}
+
 type PatchVmImageRequest struct {
 	ImageName    string
 	ImageTimeout time.Duration
@@ -398,6 +555,16 @@ type ProbeVmPortResponse struct {
 	Error      string
 }
 
+// Quota contains the resources counted against a quota. Zero limits are
+// unlimited. A VM is counted against the quota of its primary owner user and
+// the quotas of its owner groups.
+type Quota struct {
+	MemoryInMiB uint64 `json:",omitempty"`
+	MilliCPUs   uint64 `json:",omitempty"`
+	NumVMs      uint   `json:",omitempty"`
+	VolumeBytes uint64 `json:",omitempty"`
+}
+
 type ReplaceVmImageRequest struct {
 	DhcpTimeout      time.Duration
 	ImageDataSize    uint64
@@ -426,9 +593,30 @@ type ReplaceVmUserDataResponse struct {
 	Error string
 }
 
+// Resources describes the capacity of a Hypervisor available for VMs.
+type Resources struct {
+	MemoryInMiB      uint64
+	NumCPUs          uint
+	TotalVolumeBytes uint64
+}
+
+type RestoreVmFromBackupRequest struct {
+	ManifestHash hash.Hash
+	ObjectServer string // host:port. If empty, the backup server is used.
+	VmInfo              // Empty fields are taken from the backup.
+}
+
+type RestoreVmFromBackupResponse struct { // Multiple responses are sent.
+	Error           string
+	Final           bool // If true, this is the final response.
+	IpAddress       net.IP
+	ProgressMessage string
+}
+
 type RestoreVmFromSnapshotRequest struct {
 	IpAddress         net.IP
 	ForceIfNotStopped bool
+	Name              string // If empty, the unnamed snapshot.
 }
 
 type RestoreVmFromSnapshotResponse struct {
@@ -451,9 +639,40 @@ type RestoreVmUserDataResponse struct {
 	Error string
 }
 
+type SetVmSnapshotPolicyRequest struct {
+	IpAddress net.IP
+	Policy    *SnapshotPolicy // If nil, use the policy for the VM tags.
+}
+
+type SetVmSnapshotPolicyResponse struct {
+	Error string
+}
+
+// SnapshotPolicy specifies when snapshots of a VM are taken automatically and
+// how long they are kept.
+type SnapshotPolicy struct {
+	ForceIfNotStopped bool               `json:",omitempty"` // Include running.
+	RootOnly          bool               `json:",omitempty"`
+	Schedules         []SnapshotSchedule `json:",omitempty"`
+}
+
+// SnapshotPolicyRule applies a policy to VMs which have all the tags.
+type SnapshotPolicyRule struct {
+	MatchTags tags.Tags
+	Policy    SnapshotPolicy
+}
+
+// SnapshotSchedule takes a snapshot every Interval and keeps it for Retain,
+// for example: hourly for 24 hours.
+type SnapshotSchedule struct {
+	Interval time.Duration
+	Retain   time.Duration
+}
+
 type SnapshotVmRequest struct {
 	IpAddress         net.IP
 	ForceIfNotStopped bool
+	Name              string // If empty, replace the unnamed snapshot.
 	RootOnly          bool
 }
 
@@ -487,6 +706,8 @@ type Subnet struct {
 	Id                string
 	IpGateway         net.IP
 	IpMask            net.IP // net.IPMask can't be JSON {en,de}coded.
+	Ipv6Gateway       net.IP `json:",omitempty"`
+	Ipv6Mask          net.IP `json:",omitempty"` // Prefix length <= 64.
 	DomainName        string `json:",omitempty"`
 	DomainNameServers []net.IP
 	Manage            bool     `json:",omitempty"`
@@ -503,6 +724,23 @@ type TraceVmMetadataResponse struct {
 	Error string
 } // A stream of strings (trace paths) follow.
 
+type UpdateFirewallRequest struct {
+	Groups map[string]FirewallRuleGroup
+	Peers  []FirewallPeer
+}
+
+type UpdateFirewallResponse struct {
+	Error string
+}
+
+type UpdateQuotasRequest struct {
+	Quotas []OwnerQuota
+}
+
+type UpdateQuotasResponse struct {
+	Error string
+}
+
 type UpdateSubnetsRequest struct {
 	Add    []Subnet
 	Change []Subnet
@@ -513,19 +751,37 @@ type UpdateSubnetsResponse struct {
 	Error string
 }
 
+// VmBackupManifest is stored as a GOB-encoded object in the object server.
+type VmBackupManifest struct {
+	ChunkSize uint64
+	CreatedOn time.Time
+	UserData  *hash.Hash // If nil, there is no user data.
+	VmInfo    VmInfo
+	Volumes   []VmBackupVolume
+}
+
+type VmBackupVolume struct {
+	Chunks []hash.Hash // The zero hash is used for zero-filled chunks.
+	Size   uint64
+}
+
 type VmInfo struct {
 	Address            Address
-	ConsoleType        ConsoleType `json:",omitempty"`
-	DestroyProtection  bool        `json:",omitempty"`
-	DisableVirtIO      bool        `json:",omitempty"`
-	Hostname           string      `json:",omitempty"`
-	ImageName          string      `json:",omitempty"`
-	ImageURL           string      `json:",omitempty"`
+	ConfigDrive        bool            `json:",omitempty"` // NoCloud ISO.
+	ConsoleType        ConsoleType     `json:",omitempty"`
+	DestroyProtection  bool            `json:",omitempty"`
+	DisableVirtIO      bool            `json:",omitempty"`
+	Firewall           *FirewallPolicy `json:",omitempty"`
+	Hostname           string          `json:",omitempty"`
+	ImageName          string          `json:",omitempty"`
+	ImageURL           string          `json:",omitempty"`
+	Limits             *VmLimits       `json:",omitempty"`
 	MemoryInMiB        uint64
 	MilliCPUs          uint
 	OwnerGroups        []string `json:",omitempty"`
 	OwnerUsers         []string `json:",omitempty"`
 	SpreadVolumes      bool     `json:",omitempty"`
+	SshPublicKeys      []string `json:",omitempty"`
 	State              State
 	Tags               tags.Tags `json:",omitempty"`
 	SecondaryAddresses []Address `json:",omitempty"`
@@ -535,6 +791,41 @@ type VmInfo struct {
 	Volumes            []Volume  `json:",omitempty"`
 }
 
+// VmLimits throttle the resources a VM may use. Zero values are unlimited.
+type VmLimits struct {
+	DiskBytesPerSecond    uint64 `json:",omitempty"` // Per volume.
+	DiskOpsPerSecond      uint64 `json:",omitempty"` // Per volume.
+	EgressBytesPerSecond  uint64 `json:",omitempty"` // Per interface.
+	IngressBytesPerSecond uint64 `json:",omitempty"` // Per interface.
+}
+
+type VmSnapshot struct {
+	CreatedOn time.Time
+	Interval  time.Duration `json:",omitempty"` // Schedule, if automatic.
+	Name      string        `json:",omitempty"`
+	RootOnly  bool          `json:",omitempty"`
+	Size      uint64        // Bytes used in the volume directories.
+}
+
+// VmUsage summarises the resources used by a running VM. Counters are
+// cumulative since the VM was started and rates are averaged over the last
+// sample interval.
+type VmUsage struct {
+	CpuTime               time.Duration
+	DiskBytesPerSecond    uint64 `json:",omitempty"` // Read and write.
+	DiskReadBytes         uint64
+	DiskReadOps           uint64
+	DiskWriteBytes        uint64
+	DiskWriteOps          uint64
+	IdleSince             time.Time // Zero if busy.
+	MemoryUsedInMiB       uint64
+	MilliCPUsUsed         uint   `json:",omitempty"`
+	NetworkBytesPerSecond uint64 `json:",omitempty"` // Receive and transmit.
+	NetworkRxBytes        uint64 // Received by the VM.
+	NetworkTxBytes        uint64 // Transmitted by the VM.
+	SampleTime            time.Time
+}
+
 type Volume struct {
 	Size   uint64
 	Format VolumeFormat
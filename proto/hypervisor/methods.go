@@ -1,8 +1,11 @@
 package hypervisor
 
 import (
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"net"
+	"strings"
 )
 
 const consoleTypeUnknown = "UNKNOWN ConsoleType"
@@ -70,6 +73,9 @@ func (left *Address) Equal(right *Address) bool {
 	if !left.IpAddress.Equal(right.IpAddress) {
 		return false
 	}
+	if !left.Ipv6Address.Equal(right.Ipv6Address) {
+		return false
+	}
 	if left.MacAddress != right.MacAddress {
 		return false
 	}
@@ -135,6 +141,182 @@ func (consoleType *ConsoleType) UnmarshalText(text []byte) error {
 	}
 }
 
+func (policy *FirewallPolicy) CheckValid() error {
+	if err := checkFirewallRules(policy.Egress); err != nil {
+		return errors.New("egress: " + err.Error())
+	}
+	for _, group := range policy.Groups {
+		if group == "" {
+			return errors.New("empty firewall group name")
+		}
+	}
+	if err := checkFirewallRules(policy.Ingress); err != nil {
+		return errors.New("ingress: " + err.Error())
+	}
+	return nil
+}
+
+func (left *FirewallPolicy) Equal(right *FirewallPolicy) bool {
+	if left == nil || right == nil {
+		return left == right
+	}
+	if !firewallRulesEqual(left.Egress, right.Egress) {
+		return false
+	}
+	if !stringSlicesEqual(left.Groups, right.Groups) {
+		return false
+	}
+	return firewallRulesEqual(left.Ingress, right.Ingress)
+}
+
+func (rule *FirewallRule) CheckValid() error {
+	switch rule.Protocol {
+	case "", "icmp":
+		if rule.FirstPort != 0 || rule.LastPort != 0 {
+			return errors.New("ports may only be specified for tcp or udp")
+		}
+	case "tcp", "udp":
+	default:
+		return errors.New("unsupported protocol: " + rule.Protocol)
+	}
+	if rule.LastPort != 0 && rule.LastPort < rule.FirstPort {
+		return fmt.Errorf("last port: %d less than first port: %d",
+			rule.LastPort, rule.FirstPort)
+	}
+	for _, network := range rule.Networks {
+		if strings.Contains(network, "/") {
+			if _, _, err := net.ParseCIDR(network); err != nil {
+				return err
+			}
+		} else if net.ParseIP(network) == nil {
+			return errors.New("invalid IP address: " + network)
+		}
+	}
+	return nil
+}
+
+func (left *FirewallRule) Equal(right *FirewallRule) bool {
+	if left.FirstPort != right.FirstPort {
+		return false
+	}
+	if left.LastPort != right.LastPort {
+		return false
+	}
+	if !stringSlicesEqual(left.Networks, right.Networks) {
+		return false
+	}
+	if left.Protocol != right.Protocol {
+		return false
+	}
+	return left.RemoteTags.Equal(right.RemoteTags)
+}
+
+func checkFirewallRules(rules []FirewallRule) error {
+	for index := range rules {
+		if err := rules[index].CheckValid(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func firewallRulesEqual(left, right []FirewallRule) bool {
+	if len(left) != len(right) {
+		return false
+	}
+	for index := range left {
+		if !left[index].Equal(&right[index]) {
+			return false
+		}
+	}
+	return true
+}
+
+func (group *FirewallRuleGroup) CheckValid() error {
+	if err := checkFirewallRules(group.Egress); err != nil {
+		return errors.New("egress: " + err.Error())
+	}
+	if err := checkFirewallRules(group.Ingress); err != nil {
+		return errors.New("ingress: " + err.Error())
+	}
+	return nil
+}
+
+func (left *FirewallRuleGroup) Equal(right *FirewallRuleGroup) bool {
+	if !firewallRulesEqual(left.Egress, right.Egress) {
+		return false
+	}
+	return firewallRulesEqual(left.Ingress, right.Ingress)
+}
+
+// AppliesTo returns true if the quota applies to VMs with the specified
+// primary owner user and owner groups.
+func (quota *OwnerQuota) AppliesTo(ownerUser string,
+	ownerGroups []string) bool {
+	if quota.User != "" {
+		return quota.User == ownerUser
+	}
+	for _, group := range ownerGroups {
+		if group == quota.Group {
+			return true
+		}
+	}
+	return false
+}
+
+// AppliesToVm returns true if the quota applies to the VM.
+func (quota *OwnerQuota) AppliesToVm(vmInfo *VmInfo) bool {
+	var ownerUser string
+	if len(vmInfo.OwnerUsers) > 0 {
+		ownerUser = vmInfo.OwnerUsers[0]
+	}
+	return quota.AppliesTo(ownerUser, vmInfo.OwnerGroups)
+}
+
+// Add adds the resources in other to quota.
+func (quota *Quota) Add(other Quota) {
+	quota.MemoryInMiB += other.MemoryInMiB
+	quota.MilliCPUs += other.MilliCPUs
+	quota.NumVMs += other.NumVMs
+	quota.VolumeBytes += other.VolumeBytes
+}
+
+// AddVm adds the resources allocated to a VM to quota.
+func (quota *Quota) AddVm(vmInfo *VmInfo) {
+	quota.MemoryInMiB += vmInfo.MemoryInMiB
+	quota.MilliCPUs += uint64(vmInfo.MilliCPUs)
+	quota.NumVMs++
+	for _, volume := range vmInfo.Volumes {
+		quota.VolumeBytes += volume.Size
+	}
+}
+
+// CheckAvailable returns an error if adding extra resources to used would
+// exceed the limits in quota. Only resources which are increased by extra are
+// checked, so that owners who are already over quota may reduce their usage.
+func (quota *Quota) CheckAvailable(used, extra Quota) error {
+	if extra.MemoryInMiB > 0 && quota.MemoryInMiB > 0 &&
+		used.MemoryInMiB+extra.MemoryInMiB > quota.MemoryInMiB {
+		return fmt.Errorf("memory: %d MiB used of %d MiB",
+			used.MemoryInMiB, quota.MemoryInMiB)
+	}
+	if extra.MilliCPUs > 0 && quota.MilliCPUs > 0 &&
+		used.MilliCPUs+extra.MilliCPUs > quota.MilliCPUs {
+		return fmt.Errorf("MilliCPUs: %d used of %d",
+			used.MilliCPUs, quota.MilliCPUs)
+	}
+	if extra.NumVMs > 0 && quota.NumVMs > 0 &&
+		used.NumVMs+extra.NumVMs > quota.NumVMs {
+		return fmt.Errorf("VMs: %d used of %d", used.NumVMs, quota.NumVMs)
+	}
+	if extra.VolumeBytes > 0 && quota.VolumeBytes > 0 &&
+		used.VolumeBytes+extra.VolumeBytes > quota.VolumeBytes {
+		return fmt.Errorf("volume bytes: %d used of %d",
+			used.VolumeBytes, quota.VolumeBytes)
+	}
+	return nil
+}
+
 func (state State) MarshalText() ([]byte, error) {
 	if text := state.String(); text == stateUnknown {
 		return nil, errors.New(text)
@@ -161,6 +343,23 @@ func (state *State) UnmarshalText(text []byte) error {
 	}
 }
 
+// CheckValid returns an error if the IPv6 prefix of the subnet is not usable.
+func (subnet *Subnet) CheckValid() error {
+	if len(subnet.Ipv6Gateway) < 1 && len(subnet.Ipv6Mask) < 1 {
+		return nil
+	}
+	if subnet.Ipv6Gateway.To16() == nil || subnet.Ipv6Gateway.To4() != nil {
+		return fmt.Errorf("subnet: %s: bad IPv6 gateway: %s",
+			subnet.Id, subnet.Ipv6Gateway)
+	}
+	mask := net.IPMask(subnet.Ipv6Mask.To16())
+	if ones, bits := mask.Size(); bits != 128 || ones > 64 {
+		return fmt.Errorf("subnet: %s: bad IPv6 mask: %s",
+			subnet.Id, subnet.Ipv6Mask)
+	}
+	return nil
+}
+
 func (left *Subnet) Equal(right *Subnet) bool {
 	if left.Id != right.Id {
 		return false
@@ -171,6 +370,12 @@ func (left *Subnet) Equal(right *Subnet) bool {
 	if !left.IpMask.Equal(right.IpMask) {
 		return false
 	}
+	if !left.Ipv6Gateway.Equal(right.Ipv6Gateway) {
+		return false
+	}
+	if !left.Ipv6Mask.Equal(right.Ipv6Mask) {
+		return false
+	}
 	if left.DomainName != right.DomainName {
 		return false
 	}
@@ -204,6 +409,41 @@ func IpListsEqual(left, right []net.IP) bool {
 	return true
 }
 
+// HaveIpv6 returns true if the subnet has an IPv6 prefix.
+func (subnet *Subnet) HaveIpv6() bool {
+	return len(subnet.Ipv6Gateway) > 0 && len(subnet.Ipv6Mask) > 0
+}
+
+// MakeIpv6Address returns the IPv6 address in the subnet prefix for the
+// specified MAC address, using the modified EUI-64 interface identifier. If
+// the subnet does not have an IPv6 prefix, nil is returned.
+func (subnet *Subnet) MakeIpv6Address(macAddress string) (net.IP, error) {
+	if !subnet.HaveIpv6() {
+		return nil, nil
+	}
+	if err := subnet.CheckValid(); err != nil {
+		return nil, err
+	}
+	hwAddr, err := net.ParseMAC(macAddress)
+	if err != nil {
+		return nil, err
+	}
+	if len(hwAddr) != 6 {
+		return nil, fmt.Errorf("not an EUI-48 MAC address: %s", macAddress)
+	}
+	ipAddr := subnet.Ipv6Gateway.To16().Mask(
+		net.IPMask(subnet.Ipv6Mask.To16()))
+	ipAddr[8] = hwAddr[0] ^ 0x02
+	ipAddr[9] = hwAddr[1]
+	ipAddr[10] = hwAddr[2]
+	ipAddr[11] = 0xff
+	ipAddr[12] = 0xfe
+	ipAddr[13] = hwAddr[3]
+	ipAddr[14] = hwAddr[4]
+	ipAddr[15] = hwAddr[5]
+	return ipAddr, nil
+}
+
 func (subnet *Subnet) Shrink() {
 	subnet.IpGateway = ShrinkIP(subnet.IpGateway)
 	subnet.IpMask = ShrinkIP(subnet.IpMask)
@@ -216,6 +456,9 @@ func (left *VmInfo) Equal(right *VmInfo) bool {
 	if !left.Address.Equal(&right.Address) {
 		return false
 	}
+	if left.ConfigDrive != right.ConfigDrive {
+		return false
+	}
 	if left.ConsoleType != right.ConsoleType {
 		return false
 	}
@@ -225,6 +468,9 @@ func (left *VmInfo) Equal(right *VmInfo) bool {
 	if left.DisableVirtIO != right.DisableVirtIO {
 		return false
 	}
+	if !left.Firewall.Equal(right.Firewall) {
+		return false
+	}
 	if left.Hostname != right.Hostname {
 		return false
 	}
@@ -234,6 +480,13 @@ func (left *VmInfo) Equal(right *VmInfo) bool {
 	if left.ImageURL != right.ImageURL {
 		return false
 	}
+	if left.Limits == nil || right.Limits == nil {
+		if left.Limits != right.Limits {
+			return false
+		}
+	} else if *left.Limits != *right.Limits {
+		return false
+	}
 	if left.MemoryInMiB != right.MemoryInMiB {
 		return false
 	}
@@ -249,6 +502,9 @@ func (left *VmInfo) Equal(right *VmInfo) bool {
 	if left.SpreadVolumes != right.SpreadVolumes {
 		return false
 	}
+	if !stringSlicesEqual(left.SshPublicKeys, right.SshPublicKeys) {
+		return false
+	}
 	if left.State != right.State {
 		return false
 	}
@@ -283,6 +539,22 @@ func (left *VmInfo) Equal(right *VmInfo) bool {
 	return true
 }
 
+// GetLocalHostname returns the hostname which the VM should configure, which
+// is the VM hostname if set, else a name derived from ipAddr, the primary IP
+// address of the VM. Derived names are valid DNS labels for IPv4 and IPv6.
+func (vmInfo *VmInfo) GetLocalHostname(ipAddr net.IP) string {
+	if vmInfo.Hostname != "" {
+		return vmInfo.Hostname
+	}
+	if ip4 := ipAddr.To4(); ip4 != nil {
+		return "ip-" + strings.Replace(ip4.String(), ".", "-", -1)
+	}
+	if ip16 := ipAddr.To16(); ip16 != nil {
+		return "ip-" + hex.EncodeToString(ip16)
+	}
+	return ""
+}
+
 func (volumeFormat VolumeFormat) MarshalText() ([]byte, error) {
 	if text := volumeFormat.String(); text == volumeFormatUnknown {
 		return nil, errors.New(text)
@@ -0,0 +1,59 @@
+package hypervisor
+
+import (
+	"net"
+	"testing"
+)
+
+func TestSubnetCheckValid(t *testing.T) {
+	tests := []struct {
+		name    string
+		gateway net.IP
+		mask    net.IP
+		valid   bool
+	}{
+		{"no IPv6", nil, nil, true},
+		{"/64", net.ParseIP("fd00::1"), net.ParseIP("ffff:ffff:ffff:ffff::"),
+			true},
+		{"/48", net.ParseIP("fd00::1"), net.ParseIP("ffff:ffff:ffff::"),
+			true},
+		{"/96", net.ParseIP("fd00::1"),
+			net.ParseIP("ffff:ffff:ffff:ffff:ffff:ffff::"), false},
+		{"no mask", net.ParseIP("fd00::1"), nil, false},
+		{"no gateway", nil, net.ParseIP("ffff:ffff:ffff:ffff::"), false},
+		{"IPv4 gateway", net.IP{10, 0, 0, 1},
+			net.ParseIP("ffff:ffff:ffff:ffff::"), false},
+		{"non-contiguous mask", net.ParseIP("fd00::1"),
+			net.ParseIP("ffff:0:ffff:ffff::"), false},
+	}
+	for _, test := range tests {
+		subnet := Subnet{Id: test.name, Ipv6Gateway: test.gateway,
+			Ipv6Mask: test.mask}
+		if err := subnet.CheckValid(); err != nil && test.valid {
+			t.Errorf("%s: %s", test.name, err)
+		} else if err == nil && !test.valid {
+			t.Errorf("%s: invalid subnet accepted", test.name)
+		}
+	}
+}
+
+func TestGetLocalHostname(t *testing.T) {
+	tests := []struct {
+		hostname string
+		ipAddr   net.IP
+		expected string
+	}{
+		{"web1", net.IP{10, 20, 0, 5}, "web1"},
+		{"", net.IP{10, 20, 0, 5}, "ip-10-20-0-5"},
+		{"", net.ParseIP("10.20.0.5"), "ip-10-20-0-5"},
+		{"", net.ParseIP("fd00::"), "ip-fd000000000000000000000000000000"},
+		{"", nil, ""},
+	}
+	for _, test := range tests {
+		vmInfo := VmInfo{Hostname: test.hostname}
+		if got := vmInfo.GetLocalHostname(test.ipAddr); got != test.expected {
+			t.Errorf("%s: expected: %s, got: %s",
+				test.ipAddr, test.expected, got)
+		}
+	}
+}
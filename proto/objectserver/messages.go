@@ -5,14 +5,27 @@ import (
 	"time"
 )
 
+const (
+	CompressionNone = iota
+	CompressionGzip
+)
+
+// Compression specifies how object data are compressed when streamed. Objects
+// are always identified by the hash of their uncompressed data.
+type Compression uint
+
 // The AddObjects() RPC requires the client to send a stream of AddObjectRequest
 // objects in Gob format. To signify the end of the stream, the client should
 // send an AddObjectRequest object with .Length == 0.
 // The server will send one AddObjectResponse for each AddObjectRequest, but it
 // will not flush the connection until the client signals the end of the stream.
+// The AddObjectsWithCompression() RPC is the same, except that the object data
+// may be compressed. Length is always the uncompressed length. Compressed
+// objects are sent as an independent gzip stream per object.
 type AddObjectRequest struct {
 	Length       uint64
 	ExpectedHash *hash.Hash
+	Compression  Compression
 } // Object data are streamed afterwards.
 
 type AddObjectResponse struct {
@@ -29,13 +42,34 @@ type CheckObjectsResponse struct {
 	ObjectSizes []uint64 // size == 0: object not found.
 }
 
+// The GetObjectDelta() RPC is used to fetch an object when the client already
+// has an older version of the object, so that only changed chunks need to be
+// transferred. The client sends a GetObjectDeltaRequest and the server sends a
+// GetObjectDeltaResponse. If there is no error, the proto/rsync.GetChunks
+// protocol follows, with the server serving the requested object. Objects
+// which the server stores compressed are not supported.
+type GetObjectDeltaRequest struct {
+	Hash hash.Hash
+}
+
+type GetObjectDeltaResponse struct {
+	Error string
+	Size  uint64
+}
+
 // This is used in the special GetObjects streaming HTTP/RPC protocol.
+// The client may request that objects be compressed and the server responds
+// with the compression it will use (older servers never compress). If
+// compressed, each object is sent as an independent gzip stream and
+// ObjectSizes are the uncompressed sizes.
 type GetObjectsRequest struct {
-	Exclusive bool // For initial performance benchmarking only.
-	Hashes    []hash.Hash
+	Compression Compression
+	Exclusive   bool // For initial performance benchmarking only.
+	Hashes      []hash.Hash
 }
 
 type GetObjectsResponse struct {
+	Compression    Compression
 	ResponseString string
 	ObjectSizes    []uint64
 } // Object datas are streamed afterwards.
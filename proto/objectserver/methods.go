@@ -0,0 +1,65 @@
+package objectserver
+
+import (
+	"errors"
+)
+
+const compressionUnknown = "UNKNOWN Compression"
+
+var (
+	compressionToText = map[Compression]string{
+		CompressionNone: "none",
+		CompressionGzip: "gzip",
+	}
+	textToCompression map[string]Compression
+)
+
+func init() {
+	textToCompression = make(map[string]Compression, len(compressionToText))
+	for compression, text := range compressionToText {
+		textToCompression[text] = compression
+	}
+}
+
+func (compression *Compression) CheckValid() error {
+	if _, ok := compressionToText[*compression]; !ok {
+		return errors.New(compressionUnknown)
+	} else {
+		return nil
+	}
+}
+
+func (compression Compression) MarshalText() ([]byte, error) {
+	if text := compression.String(); text == compressionUnknown {
+		return nil, errors.New(text)
+	} else {
+		return []byte(text), nil
+	}
+}
+
+func (compression *Compression) Set(value string) error {
+	if val, ok := textToCompression[value]; !ok {
+		return errors.New(compressionUnknown)
+	} else {
+		*compression = val
+		return nil
+	}
+}
+
+func (compression Compression) String() string {
+	if str, ok := compressionToText[compression]; !ok {
+		return compressionUnknown
+	} else {
+		return str
+	}
+}
+
+func (compression *Compression) UnmarshalText(text []byte) error {
+	txt := string(text)
+	if val, ok := textToCompression[txt]; ok {
+		*compression = val
+		return nil
+	} else {
+		return errors.New("unknown Compression: " + txt)
+	}
+}
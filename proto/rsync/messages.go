@@ -1,5 +1,7 @@
 package rsync
 
+import "github.com/Symantec/Dominator/lib/hash"
+
 // The GetBlocks() protocol is fully streamed.
 // The client sends a GetBlocksRequest to the server.
 // The server sends a stream of Block messages.
@@ -14,3 +16,21 @@ type Block struct {
 	Index uint64
 	Size  uint64 // If zero: no more blocks coming.
 } // Block data are streamed afterwards.
+
+// The GetChunks() protocol is fully streamed and is used to fetch an object
+// when the client has an older version of it. The object is split into
+// content-defined chunks, so that data inserted into or removed from the object
+// only change the chunks around the edit.
+// The server sends a stream of Chunk messages describing the object.
+// The client sends a GetChunksRequest listing the chunks it does not have.
+// The server streams the data for the requested chunks, in order.
+
+type Chunk struct {
+	Error string
+	Hash  hash.Hash
+	Size  uint64 // If zero: no more chunks coming.
+}
+
+type GetChunksRequest struct {
+	Indices []uint64 // Must be in ascending order.
+}
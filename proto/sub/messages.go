@@ -20,10 +20,19 @@ type Configuration struct {
 	ScanExclusionList   []string
 }
 
+// FetchDelta suggests an older version of an object which the sub may already
+// have, so that only the changed chunks need to be fetched.
+type FetchDelta struct {
+	Hash         hash.Hash // The object to fetch.
+	BaseHash     hash.Hash // The older object, which may be in the object cache.
+	BasePathname string    // The older file, which may be on the file-system.
+}
+
 type FetchRequest struct {
 	ServerAddress string
 	Wait          bool
 	Hashes        []hash.Hash
+	Deltas        []FetchDelta // Optional: objects must also be in Hashes.
 }
 
 type FetchResponse struct{}
@@ -55,7 +64,9 @@ type PollResponse struct {
 	UpdateInProgress             bool
 	LastFetchError               string
 	LastUpdateError              string
-	LastUpdateHadTriggerFailures bool
+	LastUpdateHadTriggerFailures bool // Deprecated: use TriggerResults.
+	LastUpdateRolledBack         bool
+	LastUpdateTriggerResults     []TriggerResult
 	LastSuccessfulImageName      string
 	FreeSpace                    *uint64
 	StartTime                    time.Time
@@ -68,6 +79,16 @@ type PollResponse struct {
 	ObjectCache                  objectcache.ObjectCache // Streamed separately.
 } // FileSystem is encoded afterwards, followed by ObjectCache.
 
+// TriggerResult is the result of stopping or starting the service for a single
+// trigger during an update.
+type TriggerResult struct {
+	Service          string
+	Action           string // "stop" or "start".
+	Rollback         bool   // True if part of rolling back the update.
+	Error            string `json:",omitempty"`
+	HealthCheckError string `json:",omitempty"`
+}
+
 type SetConfigurationRequest Configuration
 
 type SetConfigurationResponse struct{}
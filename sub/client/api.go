@@ -16,6 +16,10 @@ func Cleanup(client *srpc.Client, hashes []hash.Hash) error {
 	return cleanup(client, hashes)
 }
 
+func CallFetch(client *srpc.Client, request sub.FetchRequest) error {
+	return callFetch(client, request)
+}
+
 func Fetch(client *srpc.Client, serverAddress string,
 	hashes []hash.Hash) error {
 	return fetch(client, serverAddress, hashes)
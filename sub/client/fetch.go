@@ -6,6 +6,11 @@ import (
 	"github.com/Symantec/Dominator/proto/sub"
 )
 
+func callFetch(client *srpc.Client, request sub.FetchRequest) error {
+	var reply sub.FetchResponse
+	return client.RequestReply("Subd.Fetch", request, &reply)
+}
+
 func fetch(client *srpc.Client, serverAddress string,
 	hashes []hash.Hash) error {
 	request := sub.FetchRequest{ServerAddress: serverAddress, Hashes: hashes}
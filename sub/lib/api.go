@@ -6,29 +6,54 @@ import (
 	"github.com/Symantec/Dominator/lib/filter"
 	"github.com/Symantec/Dominator/lib/log"
 	"github.com/Symantec/Dominator/lib/triggers"
+	"github.com/Symantec/Dominator/lib/wsyscall"
 	"github.com/Symantec/Dominator/proto/sub"
 )
 
+// TriggersRunner runs the action ("stop" or "start") for the triggers in the
+// order given and returns a result for each trigger. When starting, any health
+// checks are run after each service is started, and if one fails the remaining
+// services are not started.
 type TriggersRunner func(triggers []*triggers.Trigger, action string,
-	logger log.Logger) bool
+	logger log.Logger) []sub.TriggerResult
+
+// UpdateResult describes the outcome of an update.
+type UpdateResult struct {
+	FsChangeDuration   time.Duration
+	HadTriggerFailures bool
+	RolledBack         bool // True if a health check failed.
+	TriggerResults     []sub.TriggerResult
+}
+
+type journalEntry struct {
+	pathname      string // Relative to the root directory.
+	exists        bool
+	savedPathname string // If empty, only the metadata are restored.
+	stat          wsyscall.Stat_t
+}
 
 type uType struct {
-	rootDirectoryName  string
-	objectsDir         string
-	skipFilter         *filter.Filter
-	runTriggers        TriggersRunner
-	disableTriggers    bool
-	logger             log.Logger
-	lastError          error
-	hadTriggerFailures bool
-	fsChangeDuration   time.Duration
+	rootDirectoryName string
+	objectsDir        string
+	skipFilter        *filter.Filter
+	runTriggers       TriggersRunner
+	disableTriggers   bool
+	logger            log.Logger
+	lastError         error
+	result            UpdateResult
+	rollbackDir       string // If empty, no rollback journal is kept.
+	journal           []journalEntry
+	journalledPaths   map[string]struct{}
 }
 
+// Update will apply the update request to the file-system at
+// rootDirectoryName, running triggers with triggersRunner (if not nil). If any
+// of the triggers in the request have health checks, a journal of the changes
+// is kept and the changes are rolled back if a health check fails.
 func Update(request sub.UpdateRequest, rootDirectoryName string,
 	objectsDir string, oldTriggers *triggers.Triggers,
 	skipFilter *filter.Filter, triggersRunner TriggersRunner,
-	logger log.Logger) (
-	bool, time.Duration, error) {
+	logger log.Logger) (UpdateResult, error) {
 	if skipFilter == nil {
 		skipFilter = new(filter.Filter)
 	}
@@ -40,5 +65,5 @@ func Update(request sub.UpdateRequest, rootDirectoryName string,
 		logger:            logger,
 	}
 	err := updateObj.update(request, oldTriggers)
-	return updateObj.hadTriggerFailures, updateObj.fsChangeDuration, err
+	return updateObj.result, err
 }
@@ -0,0 +1,204 @@
+package lib
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"syscall"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/fsutil"
+	"github.com/Symantec/Dominator/lib/triggers"
+	"github.com/Symantec/Dominator/lib/wsyscall"
+	"github.com/Symantec/Dominator/proto/sub"
+)
+
+// startJournal prepares the rollback directory, which is next to the object
+// cache so that old inodes can be saved with hardlinks and renames.
+func (t *uType) startJournal() {
+	rollbackDir := path.Join(path.Dir(t.objectsDir), "rollback")
+	if err := fsutil.ForceRemoveAll(rollbackDir); err != nil {
+		t.logger.Printf("Error cleaning rollback directory: %s\n", err)
+		return
+	}
+	if err := os.Mkdir(rollbackDir, syscall.S_IRWXU); err != nil {
+		t.logger.Printf("Error making rollback directory: %s\n", err)
+		return
+	}
+	t.rollbackDir = rollbackDir
+	t.journalledPaths = make(map[string]struct{})
+}
+
+func (t *uType) removeJournal() {
+	if t.rollbackDir == "" {
+		return
+	}
+	if err := fsutil.ForceRemoveAll(t.rollbackDir); err != nil {
+		t.logger.Printf("Error removing rollback directory: %s\n", err)
+	}
+	t.rollbackDir = ""
+	t.journal = nil
+	t.journalledPaths = nil
+}
+
+// addJournalEntry records the current state of the inode at pathname. It
+// returns nil if no journal is being kept or the pathname was already
+// recorded.
+func (t *uType) addJournalEntry(pathname string) *journalEntry {
+	if t.rollbackDir == "" {
+		return nil
+	}
+	if _, ok := t.journalledPaths[pathname]; ok {
+		return nil
+	}
+	t.journalledPaths[pathname] = struct{}{}
+	entry := journalEntry{pathname: pathname}
+	fullPathname := path.Join(t.rootDirectoryName, pathname)
+	if err := wsyscall.Lstat(fullPathname, &entry.stat); err == nil {
+		entry.exists = true
+	}
+	t.journal = append(t.journal, entry)
+	return &t.journal[len(t.journal)-1]
+}
+
+// saveForRollback records the inode at pathname before it is replaced. Inodes
+// other than directories are saved with a hardlink.
+func (t *uType) saveForRollback(pathname string) {
+	entry := t.addJournalEntry(pathname)
+	if entry == nil || !entry.exists {
+		return
+	}
+	if entry.stat.Mode&syscall.S_IFMT == syscall.S_IFDIR {
+		return
+	}
+	savedPathname := path.Join(t.rollbackDir, fmt.Sprint(len(t.journal)))
+	fullPathname := path.Join(t.rootDirectoryName, pathname)
+	if err := os.Link(fullPathname, savedPathname); err != nil {
+		t.logger.Printf("Error saving: %s for rollback: %s\n",
+			fullPathname, err)
+		return
+	}
+	entry.savedPathname = savedPathname
+}
+
+// saveMetadataForRollback records the metadata for the inode at pathname.
+func (t *uType) saveMetadataForRollback(pathname string) {
+	t.addJournalEntry(pathname)
+}
+
+// moveAsideForRollback will move the inode (or directory tree) at pathname
+// into the rollback directory rather than deleting it. It returns true if the
+// inode was moved.
+func (t *uType) moveAsideForRollback(pathname string) bool {
+	entry := t.addJournalEntry(pathname)
+	if entry == nil || !entry.exists {
+		return false
+	}
+	savedPathname := path.Join(t.rollbackDir, fmt.Sprint(len(t.journal)))
+	fullPathname := path.Join(t.rootDirectoryName, pathname)
+	if err := os.Rename(fullPathname, savedPathname); err != nil {
+		t.logger.Printf("Error moving: %s aside for rollback: %s\n",
+			fullPathname, err)
+		return false
+	}
+	entry.savedPathname = savedPathname
+	return true
+}
+
+// rollback will stop the services which were started, restore the journalled
+// inodes and then start the services which were stopped or started without
+// running health checks.
+func (t *uType) rollback(stoppedTriggers, startedTriggers []*triggers.Trigger,
+	startResults []sub.TriggerResult, failedService string) {
+	t.result.RolledBack = true
+	t.lastError = fmt.Errorf("health check failed for: %s, rolled back update",
+		failedService)
+	t.logger.Println(t.lastError)
+	// Stop the services which were started (whether healthy or not).
+	started := make(map[string]struct{}, len(startResults))
+	for _, result := range startResults {
+		if result.Error == "" {
+			started[result.Service] = struct{}{}
+		}
+	}
+	var toStop []*triggers.Trigger
+	for _, trigger := range startedTriggers {
+		if _, ok := started[trigger.Service]; ok {
+			toStop = append(toStop, trigger)
+		}
+	}
+	t.runTriggerAction(reverseTriggers(toStop), "stop", true)
+	if t.rollbackDir == "" {
+		t.logger.Println("No rollback journal: cannot restore files")
+	} else {
+		t.restoreJournal()
+	}
+	// Start everything which was stopped, without health checks.
+	toStart := make(map[string]*triggers.Trigger)
+	for _, trigger := range stoppedTriggers {
+		toStart[trigger.Service] = trigger
+	}
+	for _, trigger := range startedTriggers {
+		toStart[trigger.Service] = trigger
+	}
+	startList := make([]*triggers.Trigger, 0, len(toStart))
+	for _, trigger := range toStart {
+		trigger := *trigger
+		trigger.HealthCheck = nil
+		startList = append(startList, &trigger)
+	}
+	triggers.SortByDependencies(startList)
+	t.runTriggerAction(startList, "start", true)
+}
+
+// restoreJournal restores the journalled inodes, in reverse order.
+func (t *uType) restoreJournal() {
+	for index := len(t.journal) - 1; index >= 0; index-- {
+		entry := t.journal[index]
+		fullPathname := path.Join(t.rootDirectoryName, entry.pathname)
+		if !entry.exists {
+			if err := fsutil.ForceRemoveAll(fullPathname); err != nil {
+				t.logger.Println(err)
+			} else {
+				t.logger.Printf("Rollback: deleted: %s\n", fullPathname)
+			}
+			continue
+		}
+		if entry.savedPathname != "" {
+			if err := fsutil.ForceRemoveAll(fullPathname); err != nil {
+				t.logger.Println(err)
+				continue
+			}
+			if err := os.Rename(entry.savedPathname, fullPathname); err != nil {
+				t.logger.Println(err)
+				continue
+			}
+		} else if entry.stat.Mode&syscall.S_IFMT == syscall.S_IFDIR {
+			if err := os.Mkdir(fullPathname, syscall.S_IRWXU); err != nil &&
+				!os.IsExist(err) {
+				t.logger.Println(err)
+				continue
+			}
+		}
+		if err := restoreMetadata(fullPathname, entry.stat); err != nil {
+			t.logger.Println(err)
+			continue
+		}
+		t.logger.Printf("Rollback: restored: %s\n", fullPathname)
+	}
+}
+
+func restoreMetadata(pathname string, stat wsyscall.Stat_t) error {
+	if err := os.Lchown(pathname, int(stat.Uid), int(stat.Gid)); err != nil {
+		return err
+	}
+	if stat.Mode&syscall.S_IFMT == syscall.S_IFLNK {
+		return nil
+	}
+	if err := syscall.Chmod(pathname, stat.Mode&07777); err != nil {
+		return err
+	}
+	return os.Chtimes(pathname,
+		time.Unix(int64(stat.Atim.Sec), int64(stat.Atim.Nsec)),
+		time.Unix(int64(stat.Mtim.Sec), int64(stat.Mtim.Nsec)))
+}
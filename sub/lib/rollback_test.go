@@ -0,0 +1,235 @@
+package lib
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/filesystem"
+	"github.com/Symantec/Dominator/lib/log"
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+	"github.com/Symantec/Dominator/lib/triggers"
+	"github.com/Symantec/Dominator/proto/sub"
+)
+
+type triggerCall struct {
+	action        string
+	service       string
+	healthChecked bool
+}
+
+type testRunner struct {
+	calls         []triggerCall
+	failedService string
+}
+
+func (runner *testRunner) run(triggerList []*triggers.Trigger, action string,
+	logger log.Logger) []sub.TriggerResult {
+	var results []sub.TriggerResult
+	for _, trigger := range triggerList {
+		runner.calls = append(runner.calls, triggerCall{
+			action:        action,
+			service:       trigger.Service,
+			healthChecked: action == "start" && trigger.HealthCheck != nil,
+		})
+		result := sub.TriggerResult{Service: trigger.Service, Action: action}
+		if action == "start" && trigger.HealthCheck != nil &&
+			trigger.Service == runner.failedService {
+			result.HealthCheckError = "unhealthy"
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func writeTestFile(t *testing.T, filename, data string) {
+	if err := ioutil.WriteFile(filename, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkTestFile(t *testing.T, filename, expected string) {
+	if data, err := ioutil.ReadFile(filename); err != nil {
+		t.Error(err)
+	} else if string(data) != expected {
+		t.Errorf("%s: got: \"%s\", expected: \"%s\"", filename, data, expected)
+	}
+}
+
+func makeRollbackRequest() sub.UpdateRequest {
+	request := sub.UpdateRequest{
+		DirectoriesToMake: []sub.Inode{{
+			Name:         "/newdir",
+			GenericInode: &filesystem.DirectoryInode{Mode: 0755},
+		}},
+		PathsToDelete: []string{"/etc/app.conf"},
+		Triggers:      triggers.New(),
+	}
+	request.Triggers.Triggers = []*triggers.Trigger{
+		{MatchLines: []string{"/etc/.*"}, Service: "db"},
+		{
+			MatchLines:  []string{"/newdir"},
+			Service:     "app",
+			After:       []string{"db"},
+			HealthCheck: &triggers.HealthCheck{TcpAddress: "localhost:1"},
+		},
+	}
+	return request
+}
+
+func TestRestoreJournal(t *testing.T) {
+	baseDir, err := ioutil.TempDir("", "sub.lib.test.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(baseDir)
+	rootDir := path.Join(baseDir, "root")
+	if err := os.Mkdir(rootDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, path.Join(rootDir, "modified"), "old data")
+	writeTestFile(t, path.Join(rootDir, "deleted"), "deleted data")
+	if err := os.Chmod(path.Join(rootDir, "deleted"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	updateObj := &uType{
+		rootDirectoryName: rootDir,
+		objectsDir:        path.Join(baseDir, "objects"),
+		logger:            testlogger.New(t),
+	}
+	updateObj.startJournal()
+	if updateObj.rollbackDir == "" {
+		t.Fatal("no rollback directory")
+	}
+	// Replace a file the way makeInodes does: by renaming over it.
+	updateObj.saveForRollback("/modified")
+	writeTestFile(t, path.Join(rootDir, "modified.new"), "new data")
+	if err := os.Rename(path.Join(rootDir, "modified.new"),
+		path.Join(rootDir, "modified")); err != nil {
+		t.Fatal(err)
+	}
+	updateObj.saveForRollback("/created")
+	writeTestFile(t, path.Join(rootDir, "created"), "created data")
+	if !updateObj.moveAsideForRollback("/deleted") {
+		t.Fatal("file not moved aside")
+	}
+	if updateObj.moveAsideForRollback("/missing") {
+		t.Error("missing file moved aside")
+	}
+	updateObj.restoreJournal()
+	checkTestFile(t, path.Join(rootDir, "modified"), "old data")
+	checkTestFile(t, path.Join(rootDir, "deleted"), "deleted data")
+	if fi, err := os.Stat(path.Join(rootDir, "deleted")); err != nil {
+		t.Error(err)
+	} else if fi.Mode().Perm() != 0600 {
+		t.Errorf("restored mode: %s, expected: 0600", fi.Mode())
+	}
+	if _, err := os.Lstat(path.Join(rootDir, "created")); err == nil {
+		t.Error("created file not removed")
+	}
+	updateObj.removeJournal()
+	if _, err := os.Stat(path.Join(baseDir, "rollback")); err == nil {
+		t.Error("rollback directory not removed")
+	}
+}
+
+func TestUpdateRollback(t *testing.T) {
+	tests := []struct {
+		name          string
+		failedService string
+		oldTriggers   []*triggers.Trigger
+		rolledBack    bool
+		expectedCalls []triggerCall
+	}{
+		{
+			name: "healthy",
+			expectedCalls: []triggerCall{
+				{"start", "db", false},
+				{"start", "app", true},
+			},
+		},
+		{
+			// Stop db, start db then app (which fails), stop app, then start
+			// both without health checks.
+			name:          "unhealthy",
+			failedService: "app",
+			oldTriggers: []*triggers.Trigger{
+				{MatchLines: []string{"/etc/.*"}, Service: "db"},
+			},
+			rolledBack: true,
+			expectedCalls: []triggerCall{
+				{"stop", "db", false},
+				{"start", "db", false},
+				{"start", "app", true},
+				{"stop", "app", false},
+				{"stop", "db", false},
+				{"start", "db", false},
+				{"start", "app", false},
+			},
+		},
+	}
+	for _, test := range tests {
+		baseDir, err := ioutil.TempDir("", "sub.lib.test.")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(baseDir)
+		rootDir := path.Join(baseDir, "root")
+		objectsDir := path.Join(baseDir, "objects")
+		for _, dirname := range []string{rootDir, objectsDir,
+			path.Join(rootDir, "etc")} {
+			if err := os.Mkdir(dirname, 0755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		writeTestFile(t, path.Join(rootDir, "etc", "app.conf"), "config")
+		runner := &testRunner{failedService: test.failedService}
+		var oldTriggers *triggers.Triggers
+		if test.oldTriggers != nil {
+			oldTriggers = triggers.New()
+			oldTriggers.Triggers = test.oldTriggers
+		}
+		result, err := Update(makeRollbackRequest(), rootDir, objectsDir,
+			oldTriggers, nil, runner.run, testlogger.New(t))
+		if test.rolledBack {
+			if err == nil {
+				t.Errorf("%s: no error for rolled back update", test.name)
+			}
+			if !result.RolledBack {
+				t.Fatalf("%s: update not rolled back", test.name)
+			}
+			checkTestFile(t, path.Join(rootDir, "etc", "app.conf"), "config")
+			if _, err := os.Lstat(path.Join(rootDir, "newdir")); err == nil {
+				t.Errorf("%s: new directory not removed", test.name)
+			}
+		} else {
+			if err != nil {
+				t.Fatalf("%s: %s", test.name, err)
+			}
+			if result.RolledBack || result.HadTriggerFailures {
+				t.Errorf("%s: unexpected result: %v", test.name, result)
+			}
+			if _, err := os.Lstat(
+				path.Join(rootDir, "etc", "app.conf")); err == nil {
+				t.Errorf("%s: file not deleted", test.name)
+			}
+			if _, err := os.Lstat(path.Join(rootDir, "newdir")); err != nil {
+				t.Errorf("%s: %s", test.name, err)
+			}
+		}
+		if _, err := os.Stat(path.Join(baseDir, "rollback")); err == nil {
+			t.Errorf("%s: rollback directory not removed", test.name)
+		}
+		if len(runner.calls) != len(test.expectedCalls) {
+			t.Fatalf("%s: calls: %v, expected: %v",
+				test.name, runner.calls, test.expectedCalls)
+		}
+		for index, call := range runner.calls {
+			if call != test.expectedCalls[index] {
+				t.Errorf("%s: call %d: %v, expected: %v",
+					test.name, index, call, test.expectedCalls[index])
+			}
+		}
+	}
+}
@@ -27,6 +27,7 @@ func (t *uType) update(request sub.UpdateRequest,
 	}
 	t.copyFilesToCache(request.FilesToCopyToCache)
 	t.makeObjectCopies(request.MultiplyUsedObjects)
+	var matchedOldTriggers []*triggers.Trigger
 	if t.runTriggers != nil &&
 		oldTriggers != nil && len(oldTriggers.Triggers) > 0 {
 		t.makeDirectories(request.DirectoriesToMake,
@@ -36,10 +37,11 @@ func (t *uType) update(request sub.UpdateRequest,
 		t.makeHardlinks(request.HardlinksToMake, oldTriggers, false)
 		t.doDeletes(request.PathsToDelete, oldTriggers, false)
 		t.changeInodes(request.InodesToChange, oldTriggers, false)
-		matchedOldTriggers := oldTriggers.GetMatchedTriggers()
-		if t.runTriggers(matchedOldTriggers, "stop", t.logger) {
-			t.hadTriggerFailures = true
-		}
+		matchedOldTriggers = oldTriggers.GetMatchedTriggers()
+		t.runTriggerAction(reverseTriggers(matchedOldTriggers), "stop", false)
+	}
+	if t.runTriggers != nil && hasHealthChecks(request.Triggers) {
+		t.startJournal()
 	}
 	fsChangeStartTime := time.Now()
 	t.makeDirectories(request.DirectoriesToMake, request.Triggers, true)
@@ -48,15 +50,63 @@ func (t *uType) update(request sub.UpdateRequest,
 	t.makeHardlinks(request.HardlinksToMake, request.Triggers, true)
 	t.doDeletes(request.PathsToDelete, request.Triggers, true)
 	t.changeInodes(request.InodesToChange, request.Triggers, true)
-	t.fsChangeDuration = time.Since(fsChangeStartTime)
+	t.result.FsChangeDuration = time.Since(fsChangeStartTime)
 	matchedNewTriggers := request.Triggers.GetMatchedTriggers()
-	if t.runTriggers != nil &&
-		t.runTriggers(matchedNewTriggers, "start", t.logger) {
-		t.hadTriggerFailures = true
+	if t.runTriggers != nil {
+		results := t.runTriggerAction(matchedNewTriggers, "start", false)
+		if failedService := getFailedHealthCheck(results); failedService != "" {
+			t.rollback(matchedOldTriggers, matchedNewTriggers, results,
+				failedService)
+		}
 	}
+	t.removeJournal()
 	return t.lastError
 }
 
+// runTriggerAction runs the action for the triggers and records the results.
+func (t *uType) runTriggerAction(triggerList []*triggers.Trigger,
+	action string, rollback bool) []sub.TriggerResult {
+	if len(triggerList) < 1 {
+		return nil
+	}
+	results := t.runTriggers(triggerList, action, t.logger)
+	for index := range results {
+		result := &results[index]
+		result.Rollback = rollback
+		if result.Error != "" || result.HealthCheckError != "" {
+			t.result.HadTriggerFailures = true
+		}
+	}
+	t.result.TriggerResults = append(t.result.TriggerResults, results...)
+	return results
+}
+
+func getFailedHealthCheck(results []sub.TriggerResult) string {
+	for _, result := range results {
+		if result.HealthCheckError != "" {
+			return result.Service
+		}
+	}
+	return ""
+}
+
+func hasHealthChecks(trig *triggers.Triggers) bool {
+	for _, trigger := range trig.Triggers {
+		if trigger.HealthCheck != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func reverseTriggers(triggerList []*triggers.Trigger) []*triggers.Trigger {
+	reversed := make([]*triggers.Trigger, 0, len(triggerList))
+	for index := len(triggerList) - 1; index >= 0; index-- {
+		reversed = append(reversed, triggerList[index])
+	}
+	return reversed
+}
+
 func (t *uType) copyFilesToCache(filesToCopyToCache []sub.FileToCopyToCache) {
 	for _, fileToCopy := range filesToCopyToCache {
 		sourcePathname := path.Join(t.rootDirectoryName, fileToCopy.Name)
@@ -125,6 +175,7 @@ func (t *uType) makeInodes(inodesToMake []sub.Inode,
 		fullPathname := path.Join(t.rootDirectoryName, inode.Name)
 		triggers.Match(inode.Name)
 		if takeAction {
+			t.saveForRollback(inode.Name)
 			var err error
 			switch inode := inode.GenericInode.(type) {
 			case *filesystem.RegularInode:
@@ -212,6 +263,7 @@ func (t *uType) makeHardlinks(hardlinksToMake []sub.Hardlink,
 	for _, hardlink := range hardlinksToMake {
 		triggers.Match(hardlink.NewLink)
 		if takeAction {
+			t.saveForRollback(hardlink.NewLink)
 			targetPathname := path.Join(t.rootDirectoryName, hardlink.Target)
 			linkPathname := path.Join(t.rootDirectoryName, hardlink.NewLink)
 			// A Link directly to linkPathname will fail if it exists, so do a
@@ -241,6 +293,10 @@ func (t *uType) doDeletes(pathsToDelete []string, triggers *triggers.Triggers,
 		fullPathname := path.Join(t.rootDirectoryName, pathname)
 		triggers.Match(pathname)
 		if takeAction {
+			if t.moveAsideForRollback(pathname) {
+				t.logger.Printf("Deleted: %s\n", fullPathname)
+				continue
+			}
 			if err := fsutil.ForceRemoveAll(fullPathname); err != nil {
 				t.lastError = err
 				t.logger.Println(err)
@@ -265,6 +321,7 @@ func (t *uType) makeDirectories(directoriesToMake []sub.Inode,
 				t.logger.Println("%s is not a directory!\n", newdir.Name)
 				continue
 			}
+			t.saveForRollback(newdir.Name)
 			if err := inode.Write(fullPathname); err != nil {
 				t.lastError = err
 				t.logger.Println(err)
@@ -284,6 +341,7 @@ func (t *uType) changeInodes(inodesToChange []sub.Inode,
 			triggers.Match(inode.Name)
 		}
 		if takeAction {
+			t.saveMetadataForRollback(inode.Name)
 			if err := filesystem.ForceWriteMetadata(inode,
 				fullPathname); err != nil {
 				t.lastError = err
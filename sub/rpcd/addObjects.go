@@ -28,6 +28,13 @@ func (t *addObjectsHandlerType) AddObjects(conn *srpc.Conn) error {
 	return lib.AddObjects(conn, conn, conn, objSrv, t.logger)
 }
 
+// AddObjectsWithCompression is the same as AddObjects. Clients use it to detect
+// that the server supports compressed objects.
+func (t *addObjectsHandlerType) AddObjectsWithCompression(
+	conn *srpc.Conn) error {
+	return t.AddObjects(conn)
+}
+
 func (objSrv *objectServer) AddObject(reader io.Reader, length uint64,
 	expectedHash *hash.Hash) (hash.Hash, bool, error) {
 	hashVal, data, err := objectcache.ReadObject(reader, length, expectedHash)
@@ -8,6 +8,7 @@ import (
 	"github.com/Symantec/Dominator/lib/rateio"
 	"github.com/Symantec/Dominator/lib/srpc"
 	"github.com/Symantec/Dominator/lib/srpc/serverutil"
+	"github.com/Symantec/Dominator/sub/lib"
 	"github.com/Symantec/Dominator/sub/scanner"
 	"github.com/Symantec/tricorder/go/tricorder"
 	"github.com/Symantec/tricorder/go/tricorder/units"
@@ -25,16 +26,16 @@ type rpcType struct {
 	disableScannerFunc        func(disableScanner bool)
 	logger                    log.Logger
 	*serverutil.PerUserMethodLimiter
-	rwLock                       sync.RWMutex
-	getFilesLock                 sync.Mutex
-	fetchInProgress              bool // Fetch() & Update() mutually exclusive.
-	updateInProgress             bool
-	startTimeNanoSeconds         int32 // For Fetch() or Update().
-	startTimeSeconds             int64
-	lastFetchError               error
-	lastUpdateError              error
-	lastUpdateHadTriggerFailures bool
-	lastSuccessfulImageName      string
+	rwLock                  sync.RWMutex
+	getFilesLock            sync.Mutex
+	fetchInProgress         bool // Fetch() & Update() mutually exclusive.
+	updateInProgress        bool
+	startTimeNanoSeconds    int32 // For Fetch() or Update().
+	startTimeSeconds        int64
+	lastFetchError          error
+	lastUpdateError         error
+	lastUpdateResult        lib.UpdateResult
+	lastSuccessfulImageName string
 }
 
 type addObjectsHandlerType struct {
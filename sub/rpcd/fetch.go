@@ -18,6 +18,7 @@ import (
 	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
 	"github.com/Symantec/Dominator/lib/rateio"
 	"github.com/Symantec/Dominator/lib/srpc"
+	"github.com/Symantec/Dominator/proto/objectserver"
 	"github.com/Symantec/Dominator/proto/sub"
 )
 
@@ -26,8 +27,15 @@ const filePerms = syscall.S_IRUSR | syscall.S_IWUSR | syscall.S_IRGRP
 var (
 	exitOnFetchFailure = flag.Bool("exitOnFetchFailure", false,
 		"If true, exit if there are fetch failures. For debugging only")
+
+	fetchCompression objectserver.Compression
 )
 
+func init() {
+	flag.Var(&fetchCompression, "fetchCompression",
+		"Compression to request when fetching objects: none or gzip")
+}
+
 func (t *rpcType) Fetch(conn *srpc.Conn, request sub.FetchRequest,
 	reply *sub.FetchResponse) error {
 	if *readOnly {
@@ -95,32 +103,47 @@ func (t *rpcType) doFetch(request sub.FetchRequest) error {
 			t.logFetch(request, t.networkReaderContext.MaximumSpeed())
 		}
 	}
-	objectsReader, err := objectServer.GetObjects(request.Hashes)
+	if !benchmark {
+		objectServer.SetCompression(fetchCompression)
+	}
+	defer t.rescanObjectCacheFunction()
+	limitReader := func(reader io.Reader) io.Reader { return reader }
+	if haveLinkSpeed {
+		if linkSpeed > 0 {
+			limitReader = func(reader io.Reader) io.Reader {
+				return rateio.NewReaderContext(linkSpeed,
+					uint64(t.networkReaderContext.SpeedPercent()),
+					&rateio.ReadMeasurer{}).NewReader(reader)
+			}
+		}
+	} else if !benchmark {
+		limitReader = func(reader io.Reader) io.Reader {
+			return t.networkReaderContext.NewReader(reader)
+		}
+	}
+	hashes := request.Hashes
+	if len(request.Deltas) > 0 && !benchmark {
+		hashes = t.fetchDeltas(objectServer, request, limitReader)
+		if len(hashes) < 1 {
+			t.logger.Println("Fetch() complete")
+			return nil
+		}
+	}
+	objectsReader, err := objectServer.GetObjects(hashes)
 	if err != nil {
 		t.logger.Printf("Error getting object reader: %s\n", err.Error())
 		return err
 	}
 	defer objectsReader.Close()
 	var totalLength uint64
-	defer t.rescanObjectCacheFunction()
 	timeStart := time.Now()
-	for _, hash := range request.Hashes {
+	for _, hash := range hashes {
 		length, reader, err := objectsReader.NextObject()
 		if err != nil {
 			t.logger.Println(err)
 			return err
 		}
-		r := io.Reader(reader)
-		if haveLinkSpeed {
-			if linkSpeed > 0 {
-				r = rateio.NewReaderContext(linkSpeed,
-					uint64(t.networkReaderContext.SpeedPercent()),
-					&rateio.ReadMeasurer{}).NewReader(reader)
-			}
-		} else if !benchmark {
-			r = t.networkReaderContext.NewReader(reader)
-		}
-		err = readOne(t.objectsDir, hash, length, r)
+		err = readOne(t.objectsDir, hash, length, limitReader(reader))
 		reader.Close()
 		if err != nil {
 			t.logger.Println(err)
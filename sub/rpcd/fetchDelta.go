@@ -0,0 +1,121 @@
+package rpcd
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"syscall"
+
+	"github.com/Symantec/Dominator/lib/format"
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/objectcache"
+	objectclient "github.com/Symantec/Dominator/lib/objectserver/client"
+	"github.com/Symantec/Dominator/proto/sub"
+)
+
+// fetchDeltas will fetch objects using delta transfers where a base object is
+// available. Deltas for objects which are not in the list of hashes are
+// ignored. The objects which still need to be fetched in full are returned.
+func (t *rpcType) fetchDeltas(objectServer *objectclient.ObjectClient,
+	request sub.FetchRequest,
+	limitReader func(io.Reader) io.Reader) []hash.Hash {
+	wanted := make(map[hash.Hash]struct{}, len(request.Hashes))
+	for _, hashVal := range request.Hashes {
+		wanted[hashVal] = struct{}{}
+	}
+	fetched := make(map[hash.Hash]struct{}, len(request.Deltas))
+	var numRead, totalLength uint64
+	for _, delta := range request.Deltas {
+		if _, ok := wanted[delta.Hash]; !ok {
+			t.logger.Printf("Fetch(): ignoring delta for unrequested: %x\n",
+				delta.Hash)
+			continue
+		}
+		if _, ok := fetched[delta.Hash]; ok {
+			continue
+		}
+		length, nRead, err := t.fetchDelta(objectServer, delta,
+			limitReader)
+		if err != nil {
+			t.logger.Printf("Fetch(): delta failed for: %x: %s\n",
+				delta.Hash, err)
+			continue
+		}
+		fetched[delta.Hash] = struct{}{}
+		numRead += nRead
+		totalLength += length
+	}
+	if len(fetched) > 0 {
+		t.logger.Printf(
+			"Fetch(): fetched %d objects (%s) with deltas, read: %s\n",
+			len(fetched), format.FormatBytes(totalLength),
+			format.FormatBytes(numRead))
+	}
+	hashes := make([]hash.Hash, 0, len(request.Hashes)-len(fetched))
+	for _, hashVal := range request.Hashes {
+		if _, ok := fetched[hashVal]; !ok {
+			hashes = append(hashes, hashVal)
+		}
+	}
+	return hashes
+}
+
+func (t *rpcType) fetchDelta(objectServer *objectclient.ObjectClient,
+	delta sub.FetchDelta, limitReader func(io.Reader) io.Reader) (
+	uint64, uint64, error) {
+	base, baseSize, err := t.openDeltaBase(delta)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer base.Close()
+	filename := path.Join(t.objectsDir, objectcache.HashToFilename(delta.Hash))
+	if err := os.MkdirAll(path.Dir(filename), syscall.S_IRWXU); err != nil {
+		return 0, 0, err
+	}
+	tmpFilename := filename + "~"
+	file, err := os.OpenFile(tmpFilename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC,
+		filePerms)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer os.Remove(tmpFilename)
+	length, stats, err := objectServer.GetObjectDelta(delta.Hash, base,
+		baseSize, file, limitReader)
+	if err != nil {
+		file.Close()
+		return 0, 0, err
+	}
+	if err := file.Close(); err != nil {
+		return 0, 0, err
+	}
+	if err := os.Rename(tmpFilename, filename); err != nil {
+		return 0, 0, err
+	}
+	return length, stats.NumRead, nil
+}
+
+// openDeltaBase opens the base for a delta transfer, preferring the object
+// cache over the file-system.
+func (t *rpcType) openDeltaBase(delta sub.FetchDelta) (*os.File, uint64,
+	error) {
+	filenames := []string{
+		path.Join(t.objectsDir, objectcache.HashToFilename(delta.BaseHash)),
+	}
+	if delta.BasePathname != "" {
+		filenames = append(filenames, path.Join(t.rootDir, delta.BasePathname))
+	}
+	for _, filename := range filenames {
+		file, err := os.Open(filename)
+		if err != nil {
+			continue
+		}
+		if fi, err := file.Stat(); err != nil || !fi.Mode().IsRegular() {
+			file.Close()
+			continue
+		} else {
+			return file, uint64(fi.Size()), nil
+		}
+	}
+	return nil, 0, errors.New("no base object available")
+}
@@ -0,0 +1,21 @@
+package rpcd
+
+import (
+	"testing"
+
+	"github.com/Symantec/Dominator/lib/hash"
+	"github.com/Symantec/Dominator/lib/log/testlogger"
+	"github.com/Symantec/Dominator/proto/sub"
+)
+
+func TestFetchDeltasIgnoresUnrequested(t *testing.T) {
+	server := &rpcType{logger: testlogger.New(t)}
+	request := sub.FetchRequest{
+		Hashes: []hash.Hash{{1}},
+		Deltas: []sub.FetchDelta{{Hash: hash.Hash{2}}, {Hash: hash.Hash{3}}},
+	}
+	hashes := server.fetchDeltas(nil, request, nil)
+	if len(hashes) != 1 || hashes[0] != (hash.Hash{1}) {
+		t.Errorf("hashes: %x, expected: %x", hashes, request.Hashes)
+	}
+}
@@ -0,0 +1,70 @@
+package rpcd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/triggers"
+)
+
+const healthCheckRetryInterval = time.Second
+
+// runHealthCheck repeats the health check until it succeeds or times out.
+func runHealthCheck(healthCheck *triggers.HealthCheck, ppid string) error {
+	stopTime := time.Now().Add(healthCheck.Timeout())
+	for {
+		err := checkHealth(healthCheck, ppid, time.Until(stopTime))
+		if err == nil {
+			return nil
+		}
+		if time.Until(stopTime) < healthCheckRetryInterval {
+			return err
+		}
+		time.Sleep(healthCheckRetryInterval)
+	}
+}
+
+func checkHealth(healthCheck *triggers.HealthCheck, ppid string,
+	timeout time.Duration) error {
+	if timeout < healthCheckRetryInterval {
+		timeout = healthCheckRetryInterval
+	}
+	switch {
+	case len(healthCheck.Command) > 0:
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, "run-in-mntns",
+			append([]string{ppid}, healthCheck.Command...)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			return fmt.Errorf("%s: %s: %s",
+				strings.Join(healthCheck.Command, " "), err,
+				strings.TrimSpace(string(output)))
+		}
+		return nil
+	case healthCheck.HttpUrl != "":
+		client := &http.Client{Timeout: timeout}
+		resp, err := client.Get(healthCheck.HttpUrl)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("%s: %s", healthCheck.HttpUrl, resp.Status)
+		}
+		return nil
+	case healthCheck.TcpAddress != "":
+		conn, err := net.DialTimeout("tcp", healthCheck.TcpAddress, timeout)
+		if err != nil {
+			return err
+		}
+		conn.Close()
+		return nil
+	}
+	return errors.New("no health check specified")
+}
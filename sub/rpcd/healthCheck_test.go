@@ -0,0 +1,95 @@
+package rpcd
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Symantec/Dominator/lib/triggers"
+)
+
+func TestCheckHealthHttp(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, req *http.Request) {
+			if req.URL.Path != "/healthy" {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+	defer server.Close()
+	healthCheck := &triggers.HealthCheck{HttpUrl: server.URL + "/healthy"}
+	if err := checkHealth(healthCheck, "1", time.Second); err != nil {
+		t.Error(err)
+	}
+	healthCheck.HttpUrl = server.URL + "/unhealthy"
+	if err := checkHealth(healthCheck, "1", time.Second); err == nil {
+		t.Error("no error for unhealthy status")
+	}
+}
+
+func TestCheckHealthTcp(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	healthCheck := &triggers.HealthCheck{TcpAddress: address}
+	if err := checkHealth(healthCheck, "1", time.Second); err != nil {
+		t.Error(err)
+	}
+	listener.Close()
+	if err := checkHealth(healthCheck, "1", time.Second); err == nil {
+		t.Error("no error for closed port")
+	}
+}
+
+func TestCheckHealthEmpty(t *testing.T) {
+	err := checkHealth(&triggers.HealthCheck{}, "1", time.Second)
+	if err == nil {
+		t.Error("no error for empty health check")
+	}
+}
+
+func TestRunHealthCheckRetries(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	listener.Close()
+	// The service starts listening after the first check has failed.
+	go func() {
+		time.Sleep(healthCheckRetryInterval / 2)
+		if listener, err := net.Listen("tcp", address); err == nil {
+			defer listener.Close()
+			time.Sleep(healthCheckRetryInterval * 2)
+		}
+	}()
+	healthCheck := &triggers.HealthCheck{
+		TcpAddress:     address,
+		TimeoutSeconds: 3,
+	}
+	if err := runHealthCheck(healthCheck, "1"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRunHealthCheckTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	healthCheck := &triggers.HealthCheck{
+		TcpAddress:     listener.Addr().String(),
+		TimeoutSeconds: 1,
+	}
+	listener.Close()
+	startTime := time.Now()
+	if err := runHealthCheck(healthCheck, "1"); err == nil {
+		t.Fatal("no error for failing health check")
+	}
+	if timeTaken := time.Since(startTime); timeTaken > 3*time.Second {
+		t.Errorf("health check took: %s", timeTaken)
+	}
+}
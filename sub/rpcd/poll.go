@@ -36,7 +36,10 @@ func (t *rpcType) Poll(conn *srpc.Conn) error {
 		if t.lastUpdateError != nil {
 			response.LastUpdateError = t.lastUpdateError.Error()
 		}
-		response.LastUpdateHadTriggerFailures = t.lastUpdateHadTriggerFailures
+		response.LastUpdateHadTriggerFailures =
+			t.lastUpdateResult.HadTriggerFailures
+		response.LastUpdateRolledBack = t.lastUpdateResult.RolledBack
+		response.LastUpdateTriggerResults = t.lastUpdateResult.TriggerResults
 	}
 	response.LastSuccessfulImageName = t.lastSuccessfulImageName
 	response.FreeSpace = t.getFreeSpace()
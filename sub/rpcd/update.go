@@ -6,6 +6,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/exec"
 	"time"
@@ -71,23 +72,23 @@ func (t *rpcType) updateAndUnlock(request sub.UpdateRequest,
 	defer t.disableScannerFunc(false)
 	startTime := time.Now()
 	oldTriggers := &triggers.MergeableTriggers{}
-	file, err := os.Open(t.oldTriggersFilename)
+	previousTriggersData, err := ioutil.ReadFile(t.oldTriggersFilename)
 	if err == nil {
-		decoder := json.NewDecoder(file)
 		var trig triggers.Triggers
-		err = decoder.Decode(&trig.Triggers)
-		file.Close()
+		err = json.Unmarshal(previousTriggersData, &trig.Triggers)
 		if err == nil {
 			oldTriggers.Merge(&trig)
 		} else {
 			t.logger.Printf("Error decoding old triggers: %s", err.Error())
 		}
+	} else {
+		previousTriggersData = nil
 	}
 	if request.Triggers != nil {
 		// Merge new triggers into old triggers. This supports initial
 		// Domination of a machine and when the old triggers are incomplete.
 		oldTriggers.Merge(request.Triggers)
-		file, err = os.Create(t.oldTriggersFilename)
+		file, err := os.Create(t.oldTriggersFilename)
 		if err == nil {
 			writer := bufio.NewWriter(file)
 			if err := jsonlib.WriteWithIndent(writer, "    ",
@@ -98,11 +99,16 @@ func (t *rpcType) updateAndUnlock(request sub.UpdateRequest,
 			file.Close()
 		}
 	}
-	hadTriggerFailures, fsChangeDuration, lastUpdateError := lib.Update(
+	result, lastUpdateError := lib.Update(
 		request, rootDirectoryName, t.objectsDir, oldTriggers.ExportTriggers(),
 		t.scannerConfiguration.ScanFilter, runTriggers, t.logger)
-	t.lastUpdateHadTriggerFailures = hadTriggerFailures
+	if result.RolledBack && request.Triggers != nil {
+		t.restoreOldTriggers(previousTriggersData)
+	}
+	t.rwLock.Lock()
+	t.lastUpdateResult = result
 	t.lastUpdateError = lastUpdateError
+	t.rwLock.Unlock()
 	timeTaken := time.Since(startTime)
 	if t.lastUpdateError != nil {
 		t.logger.Printf("Update(): last error: %s\n", t.lastUpdateError)
@@ -112,75 +118,114 @@ func (t *rpcType) updateAndUnlock(request sub.UpdateRequest,
 		t.rwLock.Unlock()
 	}
 	t.logger.Printf("Update() completed in %s (change window: %s)\n",
-		timeTaken, fsChangeDuration)
+		timeTaken, result.FsChangeDuration)
 	return t.lastUpdateError
 }
 
+// restoreOldTriggers restores the old triggers file after an update was rolled
+// back. If there was no file, it is removed.
+func (t *rpcType) restoreOldTriggers(data []byte) {
+	if data == nil {
+		if err := os.Remove(t.oldTriggersFilename); err != nil {
+			t.logger.Println(err)
+		}
+		return
+	}
+	if err := ioutil.WriteFile(t.oldTriggersFilename, data, 0644); err != nil {
+		t.logger.Println(err)
+	}
+}
+
 func (t *rpcType) clearUpdateInProgress() {
 	t.rwLock.Lock()
 	defer t.rwLock.Unlock()
 	t.updateInProgress = false
 }
 
-// Returns true if there were failures.
-func runTriggers(triggers []*triggers.Trigger, action string,
-	logger log.Logger) bool {
+// runTriggers runs the action for each trigger. When starting, health checks
+// are run after each service is started. If a health check fails, the remaining
+// services are not started and no reboot or restart of subd is performed, so
+// that the update may be rolled back.
+func runTriggers(triggerList []*triggers.Trigger, action string,
+	logger log.Logger) []sub.TriggerResult {
 	doReboot := false
-	hadFailures := false
-	needRestart := false
+	restartIndex := -1
+	failedService := ""
 	logPrefix := ""
 	if *disableTriggers {
 		logPrefix = "Disabled: "
 	}
 	ppid := fmt.Sprint(os.Getppid())
-	for _, trigger := range triggers {
+	for _, trigger := range triggerList {
 		if trigger.DoReboot && action == "start" {
 			doReboot = true
 			break
 		}
 	}
-	for _, trigger := range triggers {
+	results := make([]sub.TriggerResult, 0, len(triggerList))
+	for _, trigger := range triggerList {
+		result := sub.TriggerResult{Service: trigger.Service, Action: action}
 		if trigger.Service == "subd" {
 			// Never kill myself, just restart.
 			if action == "start" {
-				needRestart = true
+				restartIndex = len(results)
 			}
+			results = append(results, result)
+			continue
+		}
+		if failedService != "" {
+			result.Error = "not started: health check failed for: " +
+				failedService
+			results = append(results, result)
 			continue
 		}
 		logger.Printf("%sAction: service %s %s\n",
 			logPrefix, trigger.Service, action)
 		if *disableTriggers {
+			results = append(results, result)
 			continue
 		}
-		if !runCommand(logger,
-			"run-in-mntns", ppid, "service", trigger.Service, action) {
-			hadFailures = true
+		err := runCommand(logger,
+			"run-in-mntns", ppid, "service", trigger.Service, action)
+		if err != nil {
+			result.Error = err.Error()
 			if trigger.DoReboot && action == "start" {
 				doReboot = false
 			}
+		} else if action == "start" && trigger.HealthCheck != nil {
+			logger.Printf("Running health check for: %s\n", trigger.Service)
+			if err := runHealthCheck(trigger.HealthCheck, ppid); err != nil {
+				logger.Printf("Health check failed for: %s: %s\n",
+					trigger.Service, err)
+				result.HealthCheckError = err.Error()
+				failedService = trigger.Service
+				doReboot = false
+			}
 		}
+		results = append(results, result)
 	}
 	if doReboot {
 		logger.Print(logPrefix, "Rebooting")
 		if *disableTriggers {
-			return hadFailures
+			return results
 		}
-		if !runCommand(logger, "reboot") {
-			hadFailures = true
+		if err := runCommand(logger, "reboot"); err != nil {
+			results = append(results,
+				sub.TriggerResult{Action: "reboot", Error: err.Error()})
 		}
-		return hadFailures
-	} else if needRestart {
+		return results
+	} else if restartIndex >= 0 && failedService == "" {
 		logger.Printf("%sAction: service subd restart\n", logPrefix)
-		if !runCommand(logger,
-			"run-in-mntns", ppid, "service", "subd", "restart") {
-			hadFailures = true
+		err := runCommand(logger,
+			"run-in-mntns", ppid, "service", "subd", "restart")
+		if err != nil {
+			results[restartIndex].Error = err.Error()
 		}
 	}
-	return hadFailures
+	return results
 }
 
-// Returns true on success, else false.
-func runCommand(logger log.Logger, name string, args ...string) bool {
+func runCommand(logger log.Logger, name string, args ...string) error {
 	cmd := exec.Command(name, args...)
 	if logs, err := cmd.CombinedOutput(); err != nil {
 		errMsg := "error running: " + name
@@ -190,7 +235,7 @@ func runCommand(logger log.Logger, name string, args ...string) bool {
 		errMsg += ": " + err.Error()
 		logger.Printf("error running: %s\n", errMsg)
 		logger.Println(string(logs))
-		return false
+		return errors.New(errMsg)
 	}
-	return true
+	return nil
 }